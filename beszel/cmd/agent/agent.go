@@ -4,6 +4,7 @@ import (
 	"beszel"
 	"beszel/internal/agent"
 	"beszel/internal/agent/health"
+	"beszel/internal/diag"
 	"flag"
 	"fmt"
 	"log"
@@ -31,6 +32,7 @@ func (opts *cmdOptions) parse() bool {
 		builder.WriteString(os.Args[0])
 		builder.WriteString(" [command] [flags]\n")
 		builder.WriteString("\nCommands:\n")
+		builder.WriteString("  diag      Write a diagnostic bundle for bug reports\n")
 		builder.WriteString("  health    Check if the agent is running\n")
 		builder.WriteString("  help      Display this help message\n")
 		builder.WriteString("  update    Update to the latest version\n")
@@ -54,6 +56,15 @@ func (opts *cmdOptions) parse() bool {
 	case "update":
 		agent.Update()
 		return true
+	case "diag":
+		diagFlags := flag.NewFlagSet("diag", flag.ExitOnError)
+		output := diagFlags.String("o", diag.FileName(beszel.AppName+"-agent"), "Output file")
+		yes := diagFlags.Bool("y", false, "Write the bundle without reviewing it")
+		diagFlags.Parse(os.Args[2:])
+		if err := agent.Diag(*output, !*yes, os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return true
 	case "health":
 		err := health.Check()
 		if err != nil {
@@ -107,7 +118,8 @@ func main() {
 	var serverConfig agent.ServerOptions
 	var err error
 	serverConfig.Keys, err = opts.loadPublicKeys()
-	if err != nil {
+	// the key is only needed for WebSocket connections if serving over mTLS
+	if err != nil && !agent.MTLSEnabled() {
 		log.Fatal("Failed to load public keys:", err)
 	}
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"beszel"
+	"beszel/internal/diag"
 	"beszel/internal/hub"
 	_ "beszel/migrations"
 	"fmt"
@@ -28,6 +29,10 @@ func main() {
 
 	baseApp := getBaseApp()
 	h := hub.NewHub(baseApp)
+	// add diag command, which needs the hub to read systems and logs
+	baseApp.RootCmd.AddCommand(newDiagCmd(h))
+	// add standby command, which replaces the hub's database
+	baseApp.RootCmd.AddCommand(newStandbyCmd(h))
 	if err := h.StartHub(); err != nil {
 		log.Fatal(err)
 	}
@@ -37,10 +42,15 @@ func main() {
 func getBaseApp() *pocketbase.PocketBase {
 	isDev := os.Getenv("ENV") == "dev"
 
-	baseApp := pocketbase.NewWithConfig(pocketbase.Config{
+	config := pocketbase.Config{
 		DefaultDataDir: beszel.AppName + "_data",
 		DefaultDev:     isDev,
-	})
+	}
+	// disable autocheckpoints for Litestream / LiteFS
+	if hub.ReplicationEnabled() {
+		config.DBConnect = hub.ReplicationDBConnect
+	}
+	baseApp := pocketbase.NewWithConfig(config)
 	baseApp.RootCmd.Version = beszel.Version
 	baseApp.RootCmd.Use = beszel.AppName
 	baseApp.RootCmd.Short = ""
@@ -80,6 +90,55 @@ func newHealthCmd() *cobra.Command {
 	return healthCmd
 }
 
+func newDiagCmd(h *hub.Hub) *cobra.Command {
+	var output string
+	var yes bool
+
+	diagCmd := &cobra.Command{
+		Use:   "diag",
+		Short: "Write a diagnostic bundle for bug reports",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := h.Diag(output, !yes, os.Stdin, os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	diagCmd.Flags().StringVarP(&output, "output", "o", diag.FileName(beszel.AppName), "output file")
+	diagCmd.Flags().BoolVarP(&yes, "yes", "y", false, "write the bundle without reviewing it")
+	return diagCmd
+}
+
+func newStandbyCmd(h *hub.Hub) *cobra.Command {
+	var opts hub.StandbyOptions
+	var httpAddr string
+
+	standbyCmd := &cobra.Command{
+		Use:   "standby <primary url>",
+		Short: "Replicate a primary hub and take over when it goes down",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.PrimaryURL = args[0]
+			opts.Token, _ = hub.GetEnv("STANDBY_TOKEN")
+			if err := h.Standby(cmd.Context(), opts); err != nil {
+				return err
+			}
+			// promoted, so serve as the primary
+			serveCmd, _, err := cmd.Root().Find([]string{"serve"})
+			if err != nil {
+				return err
+			}
+			if err := serveCmd.ParseFlags([]string{"--http", httpAddr}); err != nil {
+				return err
+			}
+			return serveCmd.RunE(serveCmd, nil)
+		},
+	}
+	standbyCmd.Flags().DurationVar(&opts.Interval, "interval", time.Minute, "time between snapshots of the primary")
+	standbyCmd.Flags().DurationVar(&opts.PromoteAfter, "promote-after", 0, "promote when the primary is down for this long (0 to never promote)")
+	standbyCmd.Flags().StringVar(&httpAddr, "http", "0.0.0.0:8090", "TCP address to listen on after promotion")
+	return standbyCmd
+}
+
 // checkHealth checks the health of the hub.
 func checkHealth(baseURL string) error {
 	client := &http.Client{
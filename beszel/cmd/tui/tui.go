@@ -0,0 +1,94 @@
+package main
+
+import (
+	"beszel"
+	"beszel/internal/tui"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// cli options
+type cmdOptions struct {
+	url      string        // url is the hub URL.
+	email    string        // email is the user's email address.
+	interval time.Duration // interval is how often data is refreshed.
+}
+
+// getEnv retrieves an environment variable with a "BESZEL_TUI_" prefix, or falls back to the unprefixed key.
+func getEnv(key string) (value string, exists bool) {
+	if value, exists = os.LookupEnv("BESZEL_TUI_" + key); exists {
+		return value, exists
+	}
+	return os.LookupEnv(key)
+}
+
+// parse parses the command line flags and populates the config struct.
+// It returns true if a subcommand was handled and the program should exit.
+func (opts *cmdOptions) parse() bool {
+	defaultURL, _ := getEnv("HUB_URL")
+	defaultEmail, _ := getEnv("EMAIL")
+	flag.StringVar(&opts.url, "url", defaultURL, "Hub URL")
+	flag.StringVar(&opts.email, "email", defaultEmail, "User email address")
+	flag.DurationVar(&opts.interval, "interval", 30*time.Second, "Refresh interval")
+
+	flag.Usage = func() {
+		fmt.Printf("Usage: %s [flags]\n", os.Args[0])
+		fmt.Print("\nThe password is read from PASSWORD or prompted for.\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "-v", "version":
+			fmt.Println(beszel.AppName+"-tui", beszel.Version)
+			return true
+		case "help":
+			flag.Usage()
+			return true
+		}
+	}
+
+	flag.Parse()
+	return false
+}
+
+// password returns the password from the environment or prompts for it.
+func password() (string, error) {
+	if password, ok := getEnv("PASSWORD"); ok {
+		return password, nil
+	}
+	fmt.Print("Password: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	return string(password), err
+}
+
+func main() {
+	var opts cmdOptions
+	if opts.parse() {
+		return
+	}
+	if opts.url == "" || opts.email == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	password, err := password()
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := tui.NewClient(opts.url)
+	if err := client.Login(opts.email, password); err != nil {
+		log.Fatal(err)
+	}
+
+	_, noColor := os.LookupEnv("NO_COLOR")
+	if err := tui.Run(tui.NewModel(client, !noColor), opts.interval, os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
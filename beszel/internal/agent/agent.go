@@ -3,10 +3,14 @@ package agent
 
 import (
 	"beszel"
+	"beszel/internal/clock"
 	"beszel/internal/entities/system"
+	"beszel/plugin"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,32 +23,61 @@ import (
 )
 
 type Agent struct {
-	sync.Mutex                                   // Used to lock agent while collecting data
-	debug             bool                       // true if LOG_LEVEL is set to debug
-	zfs               bool                       // true if system has arcstats
-	memCalc           string                     // Memory calculation formula
-	fsNames           []string                   // List of filesystem device names being monitored
-	fsStats           map[string]*system.FsStats // Keeps track of disk stats for each filesystem
-	netInterfaces     map[string]struct{}        // Stores all valid network interfaces
-	netIoStats        system.NetIoStats          // Keeps track of bandwidth usage
-	dockerManager     *dockerManager             // Manages Docker API requests
-	sensorConfig      *SensorConfig              // Sensors config
-	systemInfo        system.Info                // Host system info
-	gpuManager        *GPUManager                // Manages GPU data
-	cache             *SessionCache              // Cache for system stats based on primary session ID
-	connectionManager *ConnectionManager         // Channel to signal connection events
-	server            *ssh.Server                // SSH server
-	dataDir           string                     // Directory for persisting data
-	keys              []gossh.PublicKey          // SSH public keys
+	sync.Mutex                                          // Used to lock agent while collecting data
+	debug             bool                              // true if LOG_LEVEL is set to debug
+	zfs               bool                              // true if system has arcstats
+	memCalc           string                            // Memory calculation formula
+	fsNames           []string                          // List of filesystem device names being monitored
+	fsStats           map[string]*system.FsStats        // Keeps track of disk stats for each filesystem
+	netInterfaces     map[string][2]uint64              // Valid network interfaces and their last [sent, recv] byte counters
+	netIoStats        system.NetIoStats                 // Keeps track of bandwidth usage
+	swapIo            swapIoStats                       // Keeps track of swap activity
+	dockerManager     *dockerManager                    // Manages Docker API requests
+	criManager        *criManager                       // Manages CRI (containerd, CRI-O) requests
+	podManager        *podManager                       // Manages kubelet requests in Kubernetes pod mode
+	sensorConfig      *SensorConfig                     // Sensors config
+	sensorsFile       *sensorsFile                      // Optional file with sensor settings that can change at runtime
+	systemInfo        system.Info                       // Host system info
+	gpuManager        *GPUManager                       // Manages GPU data
+	nutClient         *nutClient                        // Collects UPS data from NUT
+	cpuMonitor        *cpuMonitor                       // Reports core frequency and throttling from sysfs
+	pressureMonitor   *pressureMonitor                  // Reports pressure stall information from /proc/pressure
+	raidMonitor       *raidMonitor                      // Reports software RAID status from /proc/mdstat
+	smartMonitor      *smartMonitor                     // Reports disk health from smartctl
+	serviceMonitor    *serviceMonitor                   // Reports the state of watched systemd units
+	checkMonitor      *checkMonitor                     // Runs local TCP, HTTP and process health checks
+	publicIpMonitor   *publicIpMonitor                  // Looks up the public IP of the host
+	plugins           []plugin.Collector                // Registered and executable plugin collectors
+	metricRules       metricRules                       // Drops, renames or clamps metrics before they're sent
+	intervals         collectionIntervals               // Collection interval of slow subsystems
+	cache             *SessionCache                     // Cache for system stats based on primary session ID
+	connectionManager *ConnectionManager                // Channel to signal connection events
+	hubClients        []*WebSocketClient                // Clients of additional hubs in HUBS
+	server            *ssh.Server                       // SSH server
+	mtls              *mtlsServer                       // Certificates of the mTLS server, used instead of SSH if set
+	tlsListener       net.Listener                      // Listener of the mTLS server
+	dataDir           string                            // Directory for persisting data
+	keys              []gossh.PublicKey                 // SSH public keys
+	clock             clock.Clock                       // Time source for tickers and timers
+	collectionTimeout time.Duration                     // Max time for a single stats collection
+	collectorStatus   map[string]system.CollectorStatus // Result of each collector in the current collection
+	precision         precisionConfig                   // Rounding of values by metric
+	localHistory      *localHistory                     // Recent stats for local mode, if enabled
+	events            *eventQueue                       // Events reported to the event API, if enabled
 }
 
+// Default max time for a single stats collection. Must be less than the
+// time the hub waits for a response over WebSocket (10 seconds).
+const defaultCollectionTimeout = 8 * time.Second
+
 // NewAgent creates a new agent with the given data directory for persisting data.
 // If the data directory is not set, it will attempt to find the optimal directory.
 func NewAgent(dataDir ...string) (agent *Agent, err error) {
 	agent = &Agent{
 		fsStats: make(map[string]*system.FsStats),
-		cache:   NewSessionCache(69 * time.Second),
+		clock:   clock.New(),
 	}
+	agent.cache = newSessionCacheWithClock(69*time.Second, agent.clock)
 
 	agent.dataDir, err = getDataDir(dataDir...)
 	if err != nil {
@@ -54,6 +87,14 @@ func NewAgent(dataDir ...string) (agent *Agent, err error) {
 	}
 
 	agent.memCalc, _ = GetEnv("MEM_CALC")
+	agent.collectionTimeout = getCollectionTimeout()
+	agent.precision = newPrecisionConfig()
+	agent.metricRules = newMetricRules()
+	agent.intervals = newCollectionIntervals()
+	if agent.mtls, err = newMTLSServer(); err != nil {
+		return nil, err
+	}
+	agent.sensorsFile = newSensorsFile()
 	agent.sensorConfig = agent.newSensorConfig()
 	// Set up slog with a log level determined by the LOG_LEVEL env var
 	if logLevelStr, exists := GetEnv("LOG_LEVEL"); exists {
@@ -76,14 +117,24 @@ func NewAgent(dataDir ...string) (agent *Agent, err error) {
 	// initialize connection manager
 	agent.connectionManager = newConnectionManager(agent)
 
+	// initialize clients of additional hubs
+	if agent.hubClients, err = agent.newHubClients(); err != nil {
+		return nil, err
+	}
+
 	// initialize disk info
 	agent.initializeDiskInfo()
 
 	// initialize net io stats
 	agent.initializeNetIoStats()
 
-	// initialize docker manager
-	agent.dockerManager = newDockerManager(agent)
+	// initialize container runtime: kubelet pods if set, CRI if set or the only
+	// runtime found, otherwise Docker or Podman
+	if agent.podManager = newPodManager(agent); agent.podManager == nil {
+		if agent.criManager = newCriManager(agent); agent.criManager == nil {
+			agent.dockerManager = newDockerManager(agent)
+		}
+	}
 
 	// initialize GPU manager
 	if gm, err := NewGPUManager(); err != nil {
@@ -92,6 +143,43 @@ func NewAgent(dataDir ...string) (agent *Agent, err error) {
 		agent.gpuManager = gm
 	}
 
+	// initialize NUT client
+	agent.nutClient = newNutClient()
+
+	// initialize CPU frequency monitor
+	agent.cpuMonitor = newCpuMonitor()
+
+	// initialize pressure stall monitor
+	agent.pressureMonitor = newPressureMonitor()
+
+	// initialize RAID monitor
+	agent.raidMonitor = newRaidMonitor()
+
+	// initialize SMART monitor
+	agent.smartMonitor = newSmartMonitor()
+
+	// initialize systemd unit monitor
+	agent.serviceMonitor = newServiceMonitor()
+
+	// initialize public IP monitor
+	if agent.publicIpMonitor, err = newPublicIpMonitor(); err != nil {
+		slog.Warn("Public IP", "err", err)
+	}
+
+	// initialize plugin collectors
+	agent.plugins = newPlugins()
+
+	// initialize event API queue
+	if addr, _ := getEventsAddress(); addr != "" {
+		agent.events = newEventQueue(agent.clock.Now())
+	}
+
+	// initialize health checks
+	agent.checkMonitor = newCheckMonitor()
+	if agent.checkMonitor != nil {
+		agent.checkMonitor.events = agent.events
+	}
+
 	// if debugging, print stats
 	if agent.debug {
 		slog.Debug("Stats", "data", agent.gatherStats(""))
@@ -101,12 +189,31 @@ func NewAgent(dataDir ...string) (agent *Agent, err error) {
 }
 
 // GetEnv retrieves an environment variable with a "BESZEL_AGENT_" prefix, or falls back to the unprefixed key.
+// If neither is set, the value from the agent config file is used.
 func GetEnv(key string) (value string, exists bool) {
 	if value, exists = os.LookupEnv("BESZEL_AGENT_" + key); exists {
 		return value, exists
 	}
 	// Fallback to the old unprefixed key
-	return os.LookupEnv(key)
+	if value, exists = os.LookupEnv(key); exists {
+		return value, exists
+	}
+	return getConfigValue(key)
+}
+
+// getCollectionTimeout returns the COLLECTION_TIMEOUT env var duration or the default.
+func getCollectionTimeout() time.Duration {
+	timeoutStr, exists := GetEnv("COLLECTION_TIMEOUT")
+	if !exists {
+		return defaultCollectionTimeout
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil || timeout <= 0 {
+		slog.Warn("Invalid COLLECTION_TIMEOUT", "value", timeoutStr, "default", defaultCollectionTimeout)
+		return defaultCollectionTimeout
+	}
+	slog.Info("COLLECTION_TIMEOUT", "timeout", timeout)
+	return timeout
 }
 
 func (a *Agent) gatherStats(sessionID string) *system.CombinedData {
@@ -119,19 +226,58 @@ func (a *Agent) gatherStats(sessionID string) *system.CombinedData {
 		return data
 	}
 
+	// pick up changes to SENSORS_FILE
+	a.reloadSensorConfigIfChanged()
+
+	// cancel all in-flight reads if collection takes too long
+	timeout := a.collectionTimeout
+	if timeout <= 0 {
+		timeout = defaultCollectionTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// collectors report their status for this payload
+	a.collectorStatus = make(map[string]system.CollectorStatus)
+
 	*data = system.CombinedData{
-		Stats: a.getSystemStats(),
+		Stats: a.getSystemStats(ctx),
 		Info:  a.systemInfo,
 	}
 	slog.Debug("System data", "data", data)
 
 	if a.dockerManager != nil {
-		if containerStats, err := a.dockerManager.getDockerStats(); err == nil {
+		containerStats, err := a.dockerManager.getDockerStats(ctx)
+		a.setCollectorStatus(collectorDocker, err)
+		if err == nil {
 			data.Containers = containerStats
 			slog.Debug("Containers", "data", data.Containers)
 		} else {
 			slog.Debug("Containers", "err", err)
 		}
+		data.Stats.DockerDisk = a.dockerManager.getDiskUsage()
+	}
+
+	if a.criManager != nil {
+		containerStats, err := a.criManager.getContainerStats(ctx)
+		a.setCollectorStatus(collectorCri, err)
+		if err == nil {
+			data.Containers = containerStats
+			slog.Debug("Containers", "data", data.Containers)
+		} else {
+			slog.Debug("Containers", "err", err)
+		}
+	}
+
+	if a.podManager != nil {
+		podStats, err := a.podManager.getPodStats(ctx)
+		a.setCollectorStatus(collectorPods, err)
+		if err == nil {
+			data.Containers = podStats
+			slog.Debug("Pods", "data", data.Containers)
+		} else {
+			slog.Debug("Pods", "err", err)
+		}
 	}
 
 	data.Stats.ExtraFs = make(map[string]*system.FsStats)
@@ -142,13 +288,42 @@ func (a *Agent) gatherStats(sessionID string) *system.CombinedData {
 	}
 	slog.Debug("Extra FS", "data", data.Stats.ExtraFs)
 
+	// rules apply to everything sent, including the local history and metrics endpoints
+	a.metricRules.apply(data)
+
+	// events are sent once, to the hub
+	if a.events != nil && isHubSession(sessionID) {
+		data.Events = a.events.drain()
+	}
+
+	data.Info.Collectors = a.collectorStatus
+	data.Info.CollectedAt = a.clock.Now().UnixMilli()
+
 	a.cache.Set(sessionID, data)
+	if a.localHistory != nil {
+		a.localHistory.add(a.clock.Now(), data)
+	}
 	return data
 }
 
 // StartAgent initializes and starts the agent with optional WebSocket connection
 func (a *Agent) Start(serverOptions ServerOptions) error {
 	a.keys = serverOptions.Keys
+	if err := a.startLocalMode(); err != nil {
+		slog.Error("Error starting local mode", "err", err)
+	}
+	if err := a.startMetricsServer(); err != nil {
+		slog.Error("Error starting metrics server", "err", err)
+	}
+	if err := a.startOtlpExport(); err != nil {
+		slog.Error("Error starting OTLP export", "err", err)
+	}
+	if err := a.startSensorWatch(); err != nil {
+		slog.Error("Error starting sensor watch", "err", err)
+	}
+	if err := a.startEventServer(); err != nil {
+		slog.Error("Error starting event API", "err", err)
+	}
 	return a.connectionManager.Start(serverOptions)
 }
 
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"beszel/internal/clock"
 	"beszel/internal/entities/system"
 	"time"
 )
@@ -11,17 +12,24 @@ type SessionCache struct {
 	lastUpdate     time.Time
 	primarySession string
 	leaseTime      time.Duration
+	clock          clock.Clock
 }
 
 func NewSessionCache(leaseTime time.Duration) *SessionCache {
+	return newSessionCacheWithClock(leaseTime, clock.New())
+}
+
+// newSessionCacheWithClock creates a session cache that uses the given clock for lease expiry.
+func newSessionCacheWithClock(leaseTime time.Duration, clk clock.Clock) *SessionCache {
 	return &SessionCache{
 		leaseTime: leaseTime,
 		data:      &system.CombinedData{},
+		clock:     clk,
 	}
 }
 
 func (c *SessionCache) Get(sessionID string) (stats *system.CombinedData, isCached bool) {
-	if sessionID != c.primarySession && time.Since(c.lastUpdate) < c.leaseTime {
+	if sessionID != c.primarySession && c.clock.Since(c.lastUpdate) < c.leaseTime {
 		return c.data, true
 	}
 	return c.data, false
@@ -32,5 +40,5 @@ func (c *SessionCache) Set(sessionID string, data *system.CombinedData) {
 		*c.data = *data
 	}
 	c.primarySession = sessionID
-	c.lastUpdate = time.Now()
+	c.lastUpdate = c.clock.Now()
 }
@@ -4,6 +4,7 @@
 package agent
 
 import (
+	"beszel/internal/clock"
 	"beszel/internal/entities/system"
 	"testing"
 	"testing/synctest"
@@ -86,3 +87,25 @@ func TestSessionCache_NilData(t *testing.T) {
 	data, _ := cache.Get("session2")
 	assert.NotNil(t, data, "Expected data to not be nil after setting nil data")
 }
+
+func TestSessionCache_MockClock(t *testing.T) {
+	mock := clock.NewMock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache := newSessionCacheWithClock(60*time.Second, mock)
+
+	cache.Set("primary", &system.CombinedData{Info: system.Info{Hostname: "host"}})
+
+	// other sessions get cached data within the lease time
+	mock.Advance(59 * time.Second)
+	data, isCached := cache.Get("secondary")
+	assert.True(t, isCached)
+	assert.Equal(t, "host", data.Info.Hostname)
+
+	// primary session is never served from cache
+	_, isCached = cache.Get("primary")
+	assert.False(t, isCached)
+
+	// lease expires exactly at leaseTime
+	mock.Advance(time.Second)
+	_, isCached = cache.Get("secondary")
+	assert.False(t, isCached)
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+// Mount point of the cgroup filesystem, and the file listing the agent's cgroups
+const (
+	cgroupPath     = "/sys/fs/cgroup"
+	procCgroupPath = "/proc/self/cgroup"
+)
+
+// cgroup v1 reports a limit near the max int64 value when there is none
+const cgroupV1NoLimit = 1 << 62
+
+var errNoCgroupLimit = errors.New("no cgroup memory limit")
+
+// cgroupMemory is the memory limit and usage of a cgroup in bytes
+type cgroupMemory struct {
+	limit uint64
+	used  uint64 // usage without inactive page cache, like docker stats
+	cache uint64 // inactive page cache, which the kernel reclaims first
+}
+
+// readCgroupMemory returns the memory limit and usage of the agent's cgroup,
+// for cgroup v2 or v1. Returns errNoCgroupLimit if the cgroup has no limit.
+func readCgroupMemory(root, procCgroup string) (cgroupMemory, error) {
+	if dir := cgroupV2Dir(root, procCgroup); dir != "" {
+		return readCgroupMemoryFiles(dir, "memory.max", "memory.current", "inactive_file")
+	}
+	return readCgroupMemoryFiles(filepath.Join(root, "memory"),
+		"memory.limit_in_bytes", "memory.usage_in_bytes", "total_inactive_file")
+}
+
+// cgroupV2Dir returns the directory of the agent's cgroup v2, or "" if the
+// system uses cgroup v1. Inside a cgroup namespace the cgroup is the root.
+func cgroupV2Dir(root, procCgroup string) string {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return ""
+	}
+	data, _ := os.ReadFile(procCgroup)
+	for line := range strings.SplitSeq(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			dir := filepath.Join(root, path)
+			if _, err := os.Stat(filepath.Join(dir, "memory.max")); err == nil {
+				return dir
+			}
+		}
+	}
+	return root
+}
+
+// readCgroupMemoryFiles reads the limit, usage and inactive cache of a cgroup.
+func readCgroupMemoryFiles(dir, limitFile, usageFile, inactiveKey string) (cgroupMemory, error) {
+	var memory cgroupMemory
+	data, err := os.ReadFile(filepath.Join(dir, limitFile))
+	if err != nil {
+		return memory, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return memory, errNoCgroupLimit
+	}
+	if memory.limit, err = strconv.ParseUint(value, 10, 64); err != nil {
+		return memory, err
+	}
+	if memory.limit >= cgroupV1NoLimit {
+		return memory, errNoCgroupLimit
+	}
+	usage, err := readUintFile(filepath.Join(dir, usageFile))
+	if err != nil {
+		return memory, err
+	}
+	file, err := os.Open(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return memory, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		if key == inactiveKey {
+			memory.cache, _ = strconv.ParseUint(value, 10, 64)
+			break
+		}
+	}
+	memory.cache = min(memory.cache, usage)
+	memory.used = usage - memory.cache
+	return memory, scanner.Err()
+}
+
+// applyTo replaces the host memory stats with the cgroup's and returns the
+// cache to show alongside used memory.
+func (m cgroupMemory) applyTo(v *mem.VirtualMemoryStat) (cacheBuff uint64) {
+	v.Total = m.limit
+	v.Used = min(m.used, m.limit)
+	v.UsedPercent = float64(v.Used) / float64(v.Total) * 100.0
+	v.Available = v.Total - v.Used
+	v.Cached = min(m.cache, v.Available)
+	v.Buffers = 0
+	v.Free = v.Available - v.Cached
+	return v.Cached
+}
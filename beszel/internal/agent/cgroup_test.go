@@ -0,0 +1,77 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCgroupFiles writes files relative to a cgroup root, e.g. "memory.max".
+func writeCgroupFiles(t *testing.T, root string, files map[string]string) {
+	for name, value := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(value+"\n"), 0644))
+	}
+}
+
+func TestReadCgroupMemoryV2(t *testing.T) {
+	root := t.TempDir()
+	procCgroup := filepath.Join(t.TempDir(), "cgroup")
+	require.NoError(t, os.WriteFile(procCgroup, []byte("0::/system.slice/beszel-agent.service\n"), 0644))
+	writeCgroupFiles(t, root, map[string]string{
+		"cgroup.controllers": "cpu io memory pids",
+		"memory.max":         "max",
+		"system.slice/beszel-agent.service/memory.max":     "1073741824",
+		"system.slice/beszel-agent.service/memory.current": "536870912",
+		"system.slice/beszel-agent.service/memory.stat":    "anon 268435456\nfile 268435456\nactive_file 134217728\ninactive_file 134217728",
+	})
+
+	// the agent's own cgroup
+	memory, err := readCgroupMemory(root, procCgroup)
+	require.NoError(t, err)
+	assert.Equal(t, cgroupMemory{limit: 1 << 30, used: 3 << 27, cache: 1 << 27}, memory)
+
+	// the root cgroup inside a cgroup namespace
+	require.NoError(t, os.WriteFile(procCgroup, []byte("0::/\n"), 0644))
+	_, err = readCgroupMemory(root, procCgroup)
+	assert.ErrorIs(t, err, errNoCgroupLimit)
+}
+
+func TestReadCgroupMemoryV1(t *testing.T) {
+	root := t.TempDir()
+	writeCgroupFiles(t, root, map[string]string{
+		"memory/memory.limit_in_bytes": "2147483648",
+		"memory/memory.usage_in_bytes": "1073741824",
+		"memory/memory.stat":           "cache 536870912\ntotal_inactive_file 268435456",
+	})
+	memory, err := readCgroupMemory(root, filepath.Join(root, "missing"))
+	require.NoError(t, err)
+	assert.Equal(t, cgroupMemory{limit: 2 << 30, used: 3 << 28, cache: 1 << 28}, memory)
+
+	writeCgroupFiles(t, root, map[string]string{"memory/memory.limit_in_bytes": "9223372036854771712"})
+	_, err = readCgroupMemory(root, "")
+	assert.ErrorIs(t, err, errNoCgroupLimit)
+
+	_, err = readCgroupMemory(t.TempDir(), "")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCgroupMemoryApplyTo(t *testing.T) {
+	v := &mem.VirtualMemoryStat{Total: 16 << 30, Used: 8 << 30, Available: 8 << 30, Cached: 4 << 30, Buffers: 1 << 30}
+	cacheBuff := cgroupMemory{limit: 4 << 30, used: 1 << 30, cache: 1 << 30}.applyTo(v)
+	assert.Equal(t, uint64(1<<30), cacheBuff)
+	assert.Equal(t, uint64(4<<30), v.Total)
+	assert.Equal(t, uint64(1<<30), v.Used)
+	assert.Equal(t, 25.0, v.UsedPercent)
+	assert.Equal(t, uint64(3<<30), v.Available)
+	assert.Equal(t, uint64(2<<30), v.Free)
+	assert.Zero(t, v.Buffers)
+}
@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// Timeout for each health check
+const checkTimeout = 5 * time.Second
+
+// Types of health checks
+const (
+	checkTCP     = "tcp"
+	checkHTTP    = "http"
+	checkProcess = "process"
+	checkEvent   = "event"
+)
+
+// healthCheck is a local check configured with CHECKS, a comma separated list of
+// name=target, where target is tcp:host:port, an http(s) URL, process:name, or
+// event:name:max-age for an event that must be reported to the event API at
+// least once in max-age (a dead man's switch).
+//
+// Example: CHECKS=web=http://localhost:8080/health,db=tcp:localhost:5432,cron=process:cron,backup=event:backup:25h
+type healthCheck struct {
+	name   string
+	kind   string
+	target string
+	maxAge time.Duration // for event checks
+}
+
+// checkMonitor runs the health checks on each collection
+type checkMonitor struct {
+	checks []healthCheck
+	client *http.Client
+	events *eventQueue // for event checks, nil if the event API is disabled
+}
+
+// newCheckMonitor creates a check monitor if CHECKS is set. Invalid checks are logged and skipped.
+func newCheckMonitor() *checkMonitor {
+	value, _ := GetEnv("CHECKS")
+	checks, err := parseChecks(value)
+	if err != nil {
+		slog.Error("Invalid CHECKS", "err", err)
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+	slog.Info("CHECKS", "count", len(checks))
+	return &checkMonitor{
+		checks: checks,
+		client: &http.Client{
+			Timeout: checkTimeout,
+			// report the status of the checked URL, not where it redirects to
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// parseChecks parses the CHECKS value, returning the valid checks and an error
+// for the invalid ones.
+func parseChecks(value string) ([]healthCheck, error) {
+	var checks []healthCheck
+	var invalid []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, target, ok := strings.Cut(item, "=")
+		name, target = strings.TrimSpace(name), strings.TrimSpace(target)
+		if !ok || name == "" || target == "" {
+			invalid = append(invalid, item)
+			continue
+		}
+		check := healthCheck{name: name}
+		switch {
+		case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+			check.kind, check.target = checkHTTP, target
+		case strings.HasPrefix(target, "tcp:"):
+			check.kind, check.target = checkTCP, strings.TrimPrefix(target, "tcp:")
+			if _, _, err := net.SplitHostPort(check.target); err != nil {
+				invalid = append(invalid, item)
+				continue
+			}
+		case strings.HasPrefix(target, "process:"):
+			check.kind, check.target = checkProcess, strings.TrimPrefix(target, "process:")
+		case strings.HasPrefix(target, "event:"):
+			event, maxAge, found := cutLast(strings.TrimPrefix(target, "event:"), ":")
+			duration, err := time.ParseDuration(maxAge)
+			if !found || event == "" || err != nil || duration <= 0 {
+				invalid = append(invalid, item)
+				continue
+			}
+			check.kind, check.target, check.maxAge = checkEvent, event, duration
+		default:
+			invalid = append(invalid, item)
+			continue
+		}
+		checks = append(checks, check)
+	}
+	if len(invalid) > 0 {
+		return checks, fmt.Errorf("invalid checks: %s", strings.Join(invalid, ", "))
+	}
+	return checks, nil
+}
+
+// run runs the checks concurrently and returns the results in the configured order.
+func (m *checkMonitor) run(ctx context.Context) []system.CheckResult {
+	results := make([]system.CheckResult, len(m.checks))
+	var processNames map[string]bool
+	var processErr error
+	var wg sync.WaitGroup
+	for i, check := range m.checks {
+		results[i] = system.CheckResult{Name: check.name, Type: check.kind}
+		if check.kind == checkProcess {
+			// list processes once for all process checks
+			if processNames == nil && processErr == nil {
+				processNames, processErr = listProcessNames(ctx)
+			}
+			if processErr != nil {
+				results[i].Error = processErr.Error()
+			} else if !processNames[check.target] {
+				results[i].Error = "process not running"
+			} else {
+				results[i].Up = true
+			}
+			continue
+		}
+		if check.kind == checkEvent {
+			if err := m.checkEvent(check); err != nil {
+				results[i].Error = err.Error()
+			} else {
+				results[i].Up = true
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(result *system.CheckResult) {
+			defer wg.Done()
+			start := time.Now()
+			err := m.probe(ctx, check)
+			result.Latency = float64(time.Since(start).Microseconds()) / 1000
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Up = true
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// probe runs a TCP or HTTP check.
+func (m *checkMonitor) probe(ctx context.Context, check healthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	if check.kind == checkTCP {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", check.target)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkEvent checks that the event was reported within its max age, or that
+// the agent started less than max age ago.
+func (m *checkMonitor) checkEvent(check healthCheck) error {
+	if m.events == nil {
+		return errors.New("event API not enabled, set EVENTS_LISTEN")
+	}
+	if since := m.events.since(check.target, time.Now()); since > check.maxAge {
+		return fmt.Errorf("no event in %s", since.Truncate(time.Minute))
+	}
+	return nil
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// listProcessNames returns the names of the running processes.
+func listProcessNames(ctx context.Context) (map[string]bool, error) {
+	processes, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(processes))
+	for _, p := range processes {
+		if name, err := p.NameWithContext(ctx); err == nil {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// checkSensorName returns the name of the sensor of a check, e.g. check_web or check_web_ms
+func checkSensorName(name, suffix string) string {
+	return "check_" + strings.ReplaceAll(name, " ", "_") + suffix
+}
+
+// updateChecks runs the health checks and adds the results to the system info,
+// a sensor for each check (1 if up, 0 if down) and its latency in ms, and the
+// number of failing checks to the stats.
+func (a *Agent) updateChecks(ctx context.Context, systemStats *system.Stats) {
+	if a.checkMonitor == nil {
+		return
+	}
+	results := a.checkMonitor.run(ctx)
+	a.systemInfo.Checks = results
+	if systemStats.GenericSensors == nil {
+		systemStats.GenericSensors = make(map[string]system.SensorData, len(results)*2)
+	}
+	failing := 0
+	for _, result := range results {
+		up := 0.0
+		if result.Up {
+			up = 1
+		} else {
+			failing++
+			slog.Debug("Check failed", "name", result.Name, "err", result.Error)
+		}
+		systemStats.GenericSensors[checkSensorName(result.Name, "")] = system.SensorData{Value: up, Max: 1}
+		if result.Type == checkTCP || result.Type == checkHTTP {
+			latency := a.precision.round(metricSensors, result.Latency)
+			systemStats.GenericSensors[checkSensorName(result.Name, "_ms")] = system.SensorData{Value: latency, Unit: "ms"}
+		}
+	}
+	systemStats.ChecksFailing = float64(failing)
+}
@@ -0,0 +1,95 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecks(t *testing.T) {
+	checks, err := parseChecks("web=http://localhost:8080/health, db=tcp:localhost:5432,cron=process:cron,,")
+	require.NoError(t, err)
+	assert.Equal(t, []healthCheck{
+		{name: "web", kind: checkHTTP, target: "http://localhost:8080/health"},
+		{name: "db", kind: checkTCP, target: "localhost:5432"},
+		{name: "cron", kind: checkProcess, target: "cron"},
+	}, checks)
+
+	checks, err = parseChecks("ok=tcp:localhost:22,bad=ftp://host,noport=tcp:localhost,missing")
+	assert.ErrorContains(t, err, "bad=ftp://host, noport=tcp:localhost, missing")
+	assert.Len(t, checks, 1)
+
+	checks, err = parseChecks("backup=event:nightly:backup:25h,noage=event:deploy,badage=event:deploy:soon")
+	assert.ErrorContains(t, err, "noage=event:deploy, badage=event:deploy:soon")
+	assert.Equal(t, []healthCheck{{name: "backup", kind: checkEvent, target: "nightly:backup", maxAge: 25 * time.Hour}}, checks)
+
+	checks, err = parseChecks("")
+	assert.NoError(t, err)
+	assert.Empty(t, checks)
+}
+
+func TestUpdateChecks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	// a closed port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	checks, err := parseChecks("web=" + server.URL + "/health,api=" + server.URL + "/down," +
+		"port=tcp:" + server.Listener.Addr().String() + ",closed=tcp:" + closedAddr + ",ghost=process:no-such-process-xyz")
+	require.NoError(t, err)
+
+	a := &Agent{checkMonitor: &checkMonitor{checks: checks, client: http.DefaultClient}, precision: newPrecisionConfig()}
+	var stats system.Stats
+	a.updateChecks(context.Background(), &stats)
+
+	require.Len(t, a.systemInfo.Checks, 5)
+	up := map[string]bool{}
+	for _, result := range a.systemInfo.Checks {
+		up[result.Name] = result.Up
+	}
+	assert.Equal(t, map[string]bool{"web": true, "api": false, "port": true, "closed": false, "ghost": false}, up)
+	assert.Equal(t, "status 503", a.systemInfo.Checks[1].Error)
+	assert.Equal(t, 3.0, stats.ChecksFailing)
+
+	assert.Equal(t, 1.0, stats.GenericSensors["check_web"].Value)
+	assert.Equal(t, 0.0, stats.GenericSensors["check_api"].Value)
+	assert.Equal(t, "ms", stats.GenericSensors["check_web_ms"].Unit)
+	assert.Contains(t, stats.GenericSensors, "check_ghost")
+	assert.NotContains(t, stats.GenericSensors, "check_ghost_ms", "process checks have no latency")
+}
+
+func TestEventChecks(t *testing.T) {
+	checks, err := parseChecks("backup=event:backup:1h,deploy=event:deploy:1h")
+	require.NoError(t, err)
+	m := &checkMonitor{checks: checks}
+
+	results := m.run(context.Background())
+	assert.False(t, results[0].Up)
+	assert.Contains(t, results[0].Error, "EVENTS_LISTEN")
+
+	// events count as seen when the agent starts
+	m.events = newEventQueue(time.Now().Add(-2 * time.Hour))
+	m.events.add(system.Event{Name: "backup", Time: time.Now().Add(-10 * time.Minute).UnixMilli()})
+	results = m.run(context.Background())
+	assert.True(t, results[0].Up)
+	assert.False(t, results[1].Up)
+	assert.Equal(t, "no event in 2h0m0s", results[1].Error)
+}
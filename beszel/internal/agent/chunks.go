@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"beszel/internal/common"
+	"hash/crc32"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/lxzan/gws"
+)
+
+const (
+	// How long a payload sent in chunks is kept for the hub to resume the transfer
+	chunkedPayloadTTL = 2 * time.Minute
+	// Room left in each message for the chunk fields around the data
+	chunkOverhead = 64
+)
+
+// chunkedPayload is the latest payload sent in chunks, kept so the hub can
+// request the rest of it if the transfer is interrupted.
+type chunkedPayload struct {
+	chunks  []common.Chunk
+	created time.Time
+}
+
+// splitPayload splits a payload into chunks that fit in messages of the given size.
+func splitPayload(id uint32, payload []byte, size int) []common.Chunk {
+	dataSize := max(size, common.MinChunkSize) - chunkOverhead
+	total := (len(payload) + dataSize - 1) / dataSize
+	sum := crc32.ChecksumIEEE(payload)
+	chunks := make([]common.Chunk, total)
+	for i := range chunks {
+		end := min((i+1)*dataSize, len(payload))
+		chunks[i] = common.Chunk{
+			Id:    id,
+			Index: i,
+			Total: total,
+			Size:  len(payload),
+			Sum:   sum,
+			Data:  payload[i*dataSize : end],
+		}
+	}
+	return chunks
+}
+
+// sendPayload sends an encoded payload to the hub, in chunks if it is larger
+// than the max message size requested by the hub.
+func (client *WebSocketClient) sendPayload(payload []byte, chunkSize int) error {
+	if chunkSize <= 0 || len(payload) <= chunkSize {
+		return client.Conn.WriteMessage(gws.OpcodeBinary, payload)
+	}
+	client.chunked = &chunkedPayload{
+		chunks:  splitPayload(rand.Uint32(), payload, chunkSize),
+		created: client.clock.Now(),
+	}
+	slog.Debug("Sending payload in chunks", "size", len(payload), "chunks", len(client.chunked.chunks))
+	return client.sendChunks(client.chunked.chunks)
+}
+
+// handleChunkRequest resends the chunks of the latest chunked payload from the
+// requested index. If the payload is gone, an empty chunk tells the hub to
+// request new data.
+func (client *WebSocketClient) handleChunkRequest(msg *common.HubRequest[cbor.RawMessage]) error {
+	var request common.ChunkRequest
+	if err := cbor.Unmarshal(msg.Data, &request); err != nil {
+		return err
+	}
+	chunked := client.chunked
+	if chunked == nil || chunked.chunks[0].Id != request.Id ||
+		client.clock.Now().Sub(chunked.created) > chunkedPayloadTTL ||
+		request.Index < 0 || request.Index >= len(chunked.chunks) {
+		client.chunked = nil
+		return client.sendChunks([]common.Chunk{{Id: request.Id}})
+	}
+	slog.Debug("Resuming chunked payload", "chunk", request.Index, "chunks", len(chunked.chunks))
+	return client.sendChunks(chunked.chunks[request.Index:])
+}
+
+// sendChunks sends chunks as CBOR tagged messages.
+func (client *WebSocketClient) sendChunks(chunks []common.Chunk) error {
+	for _, chunk := range chunks {
+		if err := client.sendMessage(cbor.Tag{Number: common.ChunkTag, Content: chunk}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
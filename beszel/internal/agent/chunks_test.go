@@ -0,0 +1,36 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/common"
+	"bytes"
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitPayload(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 5000)
+	chunks := splitPayload(7, payload, common.MinChunkSize)
+
+	dataSize := common.MinChunkSize - chunkOverhead
+	assert.Len(t, chunks, (len(payload)+dataSize-1)/dataSize)
+
+	var joined []byte
+	for i, chunk := range chunks {
+		assert.Equal(t, uint32(7), chunk.Id)
+		assert.Equal(t, i, chunk.Index)
+		assert.Equal(t, len(chunks), chunk.Total)
+		assert.Equal(t, len(payload), chunk.Size)
+		assert.Equal(t, crc32.ChecksumIEEE(payload), chunk.Sum)
+		assert.LessOrEqual(t, len(chunk.Data), dataSize)
+		joined = append(joined, chunk.Data...)
+	}
+	assert.Equal(t, payload, joined)
+
+	// chunk sizes below the minimum are raised to it
+	assert.Len(t, splitPayload(1, payload, 100), len(chunks))
+}
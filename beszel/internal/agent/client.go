@@ -2,7 +2,9 @@ package agent
 
 import (
 	"beszel"
+	"beszel/internal/clock"
 	"beszel/internal/common"
+	"beszel/internal/entities/system"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -37,6 +39,13 @@ type WebSocketClient struct {
 	hubRequest         *common.HubRequest[cbor.RawMessage] // Reusable request structure for message parsing
 	lastConnectAttempt time.Time                           // Timestamp of last connection attempt
 	hubVerified        bool                                // Whether the hub has been cryptographically verified
+	clock              clock.Clock                         // Time source for connection attempts
+	chunked            *chunkedPayload                     // Latest payload sent in chunks, for resuming
+	pushInterval       time.Duration                       // Time between pushes of system data, zero if disabled
+	pushConn           *gws.Conn                           // Connection that system data is pushed on
+	keys               []ssh.PublicKey                     // Public keys of the hub, the agent's keys if nil
+	rules              metricRules                         // Rules applied only to data sent to this hub
+	events             chan<- ConnectionEvent              // Connection events of an additional hub, nil for the main hub
 }
 
 // newWebSocketClient creates a new WebSocket client for the given agent.
@@ -46,23 +55,33 @@ func newWebSocketClient(agent *Agent) (client *WebSocketClient, err error) {
 	if !exists {
 		return nil, errors.New("HUB_URL environment variable not set")
 	}
+	// get registration token
+	token, err := getToken()
+	if err != nil {
+		return nil, err
+	}
+	return newHubClient(agent, hubURLStr, token)
+}
 
-	client = &WebSocketClient{}
+// newHubClient creates a WebSocket client for a hub URL and token.
+func newHubClient(agent *Agent, hubURLStr, token string) (client *WebSocketClient, err error) {
+	client = &WebSocketClient{token: token}
 
 	client.hubURL, err = url.Parse(hubURLStr)
 	if err != nil {
 		return nil, errors.New("invalid hub URL")
 	}
-	// get registration token
-	client.token, err = getToken()
-	if err != nil {
-		return nil, err
-	}
 
 	client.agent = agent
+	client.clock = agent.clock
 	client.hubRequest = &common.HubRequest[cbor.RawMessage]{}
 	client.fingerprint = agent.getFingerprint()
 
+	// push mode is optional, so an invalid interval doesn't stop the client
+	if client.pushInterval, err = getPushInterval(); err != nil {
+		slog.Warn("Push mode disabled", "err", err)
+	}
+
 	return client, nil
 }
 
@@ -117,7 +136,7 @@ func (client *WebSocketClient) getOptions() *gws.ClientOption {
 // Connect establishes a WebSocket connection to the hub.
 // It closes any existing connection before attempting to reconnect.
 func (client *WebSocketClient) Connect() (err error) {
-	client.lastConnectAttempt = time.Now()
+	client.lastConnectAttempt = client.clock.Now()
 
 	// make sure previous connection is closed
 	client.Close()
@@ -142,7 +161,7 @@ func (client *WebSocketClient) OnOpen(conn *gws.Conn) {
 // It logs the closure reason and notifies the connection manager.
 func (client *WebSocketClient) OnClose(conn *gws.Conn, err error) {
 	slog.Warn("Connection closed", "err", strings.TrimPrefix(err.Error(), "gws: "))
-	client.agent.connectionManager.eventChan <- WebSocketDisconnect
+	client.sendEvent(WebSocketDisconnect)
 }
 
 // OnMessage handles incoming WebSocket messages from the hub.
@@ -155,6 +174,8 @@ func (client *WebSocketClient) OnMessage(conn *gws.Conn, message *gws.Message) {
 		return
 	}
 
+	// clear the data of the previous request, which isn't overwritten if missing
+	client.hubRequest.Data = nil
 	if err := cbor.NewDecoder(message.Data).Decode(client.hubRequest); err != nil {
 		slog.Error("Error parsing message", "err", err)
 		return
@@ -183,7 +204,7 @@ func (client *WebSocketClient) handleAuthChallenge(msg *common.HubRequest[cbor.R
 	}
 
 	client.hubVerified = true
-	client.agent.connectionManager.eventChan <- WebSocketConnect
+	client.sendEvent(WebSocketConnect)
 
 	response := &common.FingerprintResponse{
 		Fingerprint: client.fingerprint,
@@ -193,14 +214,44 @@ func (client *WebSocketClient) handleAuthChallenge(msg *common.HubRequest[cbor.R
 		response.Hostname = client.agent.systemInfo.Hostname
 		serverAddr := client.agent.connectionManager.serverOptions.Addr
 		_, response.Port, _ = net.SplitHostPort(serverAddr)
+		response.Name, _ = GetEnv("SYSTEM_NAME")
+		response.Labels = client.agent.systemInfo.Labels
 	}
 
 	return client.sendMessage(response)
 }
 
+// getLabels returns the labels set by LABELS in the format "env=prod,role=web".
+// They're sent with the system info and when registering with a token.
+func getLabels() map[string]string {
+	value, _ := GetEnv("LABELS")
+	if value == "" {
+		return nil
+	}
+	labels := make(map[string]string)
+	for key, value := range parseKeyValues("LABELS", value) {
+		labels[key] = value
+	}
+	return labels
+}
+
+// sendEvent reports a connection event to the connection manager, or to the
+// loop of an additional hub.
+func (client *WebSocketClient) sendEvent(event ConnectionEvent) {
+	if client.events != nil {
+		client.events <- event
+		return
+	}
+	client.agent.connectionManager.eventChan <- event
+}
+
 // verifySignature verifies the signature of the token using the public keys.
 func (client *WebSocketClient) verifySignature(signature []byte) (err error) {
-	for _, pubKey := range client.agent.keys {
+	keys := client.keys
+	if keys == nil {
+		keys = client.agent.keys
+	}
+	for _, pubKey := range keys {
 		sig := ssh.Signature{
 			Format: pubKey.Type(),
 			Blob:   signature,
@@ -228,17 +279,43 @@ func (client *WebSocketClient) handleHubRequest(msg *common.HubRequest[cbor.RawM
 	}
 	switch msg.Action {
 	case common.GetData:
-		return client.sendSystemData()
+		// older hubs don't send a data request
+		var request common.DataRequest
+		if len(msg.Data) > 0 {
+			_ = cbor.Unmarshal(msg.Data, &request)
+		}
+		if request.Push {
+			client.startPushing(request.ChunkSize)
+		}
+		return client.sendSystemData(request.ChunkSize)
 	case common.CheckFingerprint:
 		return client.handleAuthChallenge(msg)
+	case common.GetChunks:
+		return client.handleChunkRequest(msg)
 	}
 	return nil
 }
 
 // sendSystemData gathers and sends current system statistics to the hub.
-func (client *WebSocketClient) sendSystemData() error {
-	sysStats := client.agent.gatherStats(client.token)
-	return client.sendMessage(sysStats)
+// Payloads larger than chunkSize are sent in chunks.
+func (client *WebSocketClient) sendSystemData(chunkSize int) error {
+	bytes, err := cbor.Marshal(client.systemData())
+	if err != nil {
+		return err
+	}
+	return client.sendPayload(bytes, chunkSize)
+}
+
+// systemData gathers the system data with the rules of the hub applied.
+func (client *WebSocketClient) systemData() *system.CombinedData {
+	data := client.agent.gatherStats(client.token)
+	if len(client.rules) == 0 {
+		return data
+	}
+	// the rules replace the entries of a copy, so the cached data isn't changed
+	filtered := *data
+	client.rules.apply(&filtered)
+	return &filtered
 }
 
 // sendMessage encodes the given data to CBOR and sends it as a binary message over the WebSocket connection to the hub.
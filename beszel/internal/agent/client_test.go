@@ -536,3 +536,30 @@ func TestGetToken(t *testing.T) {
 		assert.Equal(t, "", token, "Empty file should return empty string")
 	})
 }
+
+func TestGetPushInterval(t *testing.T) {
+	interval, err := getPushInterval()
+	require.NoError(t, err)
+	assert.Zero(t, interval)
+
+	t.Setenv("PUSH_INTERVAL", "30s")
+	interval, err = getPushInterval()
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, interval)
+
+	t.Setenv("BESZEL_AGENT_PUSH_INTERVAL", "1s")
+	_, err = getPushInterval()
+	assert.ErrorContains(t, err, "invalid PUSH_INTERVAL")
+
+	// the client isn't started without an interval or a connection
+	client := &WebSocketClient{}
+	client.startPushing(0)
+	assert.Nil(t, client.pushConn)
+}
+
+func TestGetLabels(t *testing.T) {
+	assert.Nil(t, getLabels())
+
+	t.Setenv("LABELS", "env=prod, role = web,invalid,")
+	assert.Equal(t, map[string]string{"env": "prod", "role": "web"}, getLabels())
+}
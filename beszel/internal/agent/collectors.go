@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// Names of the stats collectors reported to the hub
+const (
+	collectorCpu            = "cpu"
+	collectorCpuFreq        = "cpu_freq"
+	collectorLoad           = "load"
+	collectorMemory         = "memory"
+	collectorPressure       = "pressure"
+	collectorDisk           = "disk"
+	collectorDiskIo         = "disk_io"
+	collectorNetwork        = "network"
+	collectorTemperatures   = "temperatures"
+	collectorGenericSensors = "generic_sensors"
+	collectorNut            = "nut"
+	collectorRaid           = "raid"
+	collectorSmart          = "smart"
+	collectorServices       = "services"
+	collectorPublicIp       = "public_ip"
+	collectorDocker         = "docker"
+	collectorCri            = "cri"
+	collectorPods           = "pods"
+)
+
+// collectorError is an error with an explicit kind, for errors that can't be classified
+// from the underlying error alone (e.g. a sensor value out of range).
+type collectorError struct {
+	kind system.CollectorErrorKind
+	err  error
+}
+
+func (e *collectorError) Error() string {
+	return e.err.Error()
+}
+
+func (e *collectorError) Unwrap() error {
+	return e.err
+}
+
+// newCollectorError wraps err with the given kind.
+func newCollectorError(kind system.CollectorErrorKind, err error) error {
+	return &collectorError{kind: kind, err: err}
+}
+
+// getCollectorErrorKind classifies an error returned by a collector.
+func getCollectorErrorKind(err error) system.CollectorErrorKind {
+	if err == nil {
+		return system.CollectorOk
+	}
+	var (
+		collectorErr *collectorError
+		netErr       net.Error
+		numErr       *strconv.NumError
+		syntaxErr    *json.SyntaxError
+		typeErr      *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &collectorErr):
+		return collectorErr.kind
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return system.CollectorTimeout
+	case errors.Is(err, fs.ErrPermission):
+		return system.CollectorPermissionDenied
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, errNutReconnectWait):
+		return system.CollectorUnavailable
+	case errors.As(err, &numErr), errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return system.CollectorParseError
+	}
+	return system.CollectorError
+}
+
+// setCollectorStatus records the result of a collector for the current stats payload.
+// Only the first error of each collector is kept.
+func (a *Agent) setCollectorStatus(name string, err error) {
+	if a.collectorStatus == nil {
+		a.collectorStatus = make(map[string]system.CollectorStatus)
+	}
+	if status, ok := a.collectorStatus[name]; ok && status.Kind != system.CollectorOk {
+		return
+	}
+	status := system.CollectorStatus{Kind: getCollectorErrorKind(err)}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	a.collectorStatus[name] = status
+}
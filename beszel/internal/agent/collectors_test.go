@@ -0,0 +1,58 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCollectorErrorKind(t *testing.T) {
+	_, parseErr := strconv.ParseFloat("abc", 64)
+	var jsonErr error = &json.SyntaxError{}
+	tests := []struct {
+		name     string
+		err      error
+		expected system.CollectorErrorKind
+	}{
+		{"nil", nil, system.CollectorOk},
+		{"deadline", fmt.Errorf("reading: %w", context.DeadlineExceeded), system.CollectorTimeout},
+		{"io deadline", os.ErrDeadlineExceeded, system.CollectorTimeout},
+		{"permission", &os.PathError{Op: "open", Path: "/sys", Err: syscall.EACCES}, system.CollectorPermissionDenied},
+		{"not exist", fmt.Errorf("sensor: %w", os.ErrNotExist), system.CollectorUnavailable},
+		{"connection refused", &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}, system.CollectorUnavailable},
+		{"nut reconnect", errNutReconnectWait, system.CollectorUnavailable},
+		{"parse float", fmt.Errorf("sensor: %w", parseErr), system.CollectorParseError},
+		{"json", jsonErr, system.CollectorParseError},
+		{"explicit kind", newCollectorError(system.CollectorParseError, errors.New("out of range")), system.CollectorParseError},
+		{"other", errors.New("boom"), system.CollectorError},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, getCollectorErrorKind(tt.err), tt.name)
+	}
+}
+
+func TestSetCollectorStatus(t *testing.T) {
+	a := &Agent{}
+	a.setCollectorStatus(collectorCpu, nil)
+	a.setCollectorStatus(collectorDisk, nil)
+	a.setCollectorStatus(collectorDisk, fmt.Errorf("/mnt/nfs: %w", context.DeadlineExceeded))
+	// first error is kept
+	a.setCollectorStatus(collectorDisk, errors.New("second error"))
+	a.setCollectorStatus(collectorDisk, nil)
+
+	assert.Equal(t, map[string]system.CollectorStatus{
+		collectorCpu:  {Kind: system.CollectorOk},
+		collectorDisk: {Kind: system.CollectorTimeout, Error: "/mnt/nfs: context deadline exceeded"},
+	}, a.collectorStatus)
+}
@@ -0,0 +1,281 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is used if CONFIG_FILE is not set and the file exists
+const defaultConfigFile = "/etc/beszel/agent.yml"
+
+// configFile is the optional agent config file. Settings are converted to their
+// env var equivalents, and env vars take precedence over values in the file.
+//
+// Example:
+//
+//	sensors:
+//	  include: [coretemp*, nvme_composite]
+//	  primary: coretemp_package_id_0
+//	  aliases:
+//	    coretemp_package_id_0: CPU Package
+//	  generic:
+//	    - name: pressure
+//	      unit: Pa
+//	      min: 0
+//	      max: 1000
+//	      format: comma
+//	  check_interval: 10s
+//	  webhook:
+//	    url: http://localhost:8080/overheat
+//	    thresholds: [coretemp_package_id_0>90, pressure<900]
+//	  actions:
+//	    - sensor: coretemp_package_id_0
+//	      above: 95
+//	      for: 3
+//	      cooldown: 10m
+//	      command: systemctl stop backup.service
+//	filesystems:
+//	  root: /dev/sda1
+//	  extra: [/mnt/data]
+//	network:
+//	  interfaces: [eth0]
+//	rules:
+//	  - metric: network
+//	    match: veth*
+//	    drop: true
+//	  - match: k10temp_tctl
+//	    rename: cpu
+//	intervals:
+//	  smart: 30m
+//	  raid: 1m
+//	labels:
+//	  env: prod
+//	hubs:
+//	  - url: https://team-hub.example.com
+//	    token: team-token
+//	    key: ssh-ed25519 AAAA...
+//	    rules:
+//	      - metric: containers
+//	        match: "*"
+//	        drop: true
+//	env:
+//	  DOCKER_HOST: tcp://localhost:2375
+type configFile struct {
+	Sensors     configSensors      `yaml:"sensors"`
+	Filesystems configFilesystems  `yaml:"filesystems"`
+	Network     configNetwork      `yaml:"network"`
+	Precision   map[string]int     `yaml:"precision"`
+	Quantize    map[string]float64 `yaml:"quantize"`
+	Rules       []metricRule       `yaml:"rules"`
+	Intervals   map[string]string  `yaml:"intervals"`
+	Labels      map[string]string  `yaml:"labels"`
+	Hubs        []hubConfig        `yaml:"hubs"`
+	// Any other setting by env var name, without the BESZEL_AGENT_ prefix
+	Env map[string]string `yaml:"env"`
+}
+
+type configSensors struct {
+	Disabled      bool                  `yaml:"disabled"`
+	Include       []string              `yaml:"include"`
+	Exclude       []string              `yaml:"exclude"`
+	Primary       string                `yaml:"primary"`
+	Sys           string                `yaml:"sys"`
+	Aliases       map[string]string     `yaml:"aliases"`
+	Categories    map[string]string     `yaml:"categories"`
+	StaleTimeout  string                `yaml:"stale_timeout"`
+	Generic       []configGenericSensor `yaml:"generic"`
+	CheckInterval string                `yaml:"check_interval"`
+	Webhook       configSensorWebhook   `yaml:"webhook"`
+	Actions       []sensorActionConfig  `yaml:"actions"`
+}
+
+type configGenericSensor struct {
+	Name   string  `yaml:"name"`
+	Unit   string  `yaml:"unit"`
+	Min    float64 `yaml:"min"`
+	Max    float64 `yaml:"max"`
+	Format string  `yaml:"format"`
+}
+
+type configSensorWebhook struct {
+	URL        string   `yaml:"url"`
+	Thresholds []string `yaml:"thresholds"`
+}
+
+type configFilesystems struct {
+	Root  string   `yaml:"root"`
+	Extra []string `yaml:"extra"`
+}
+
+type configNetwork struct {
+	Interfaces []string `yaml:"interfaces"`
+}
+
+var (
+	configValues     map[string]string
+	configValuesOnce sync.Once
+)
+
+// getConfigValue returns a setting from the config file, loading it on first use.
+func getConfigValue(key string) (value string, exists bool) {
+	configValuesOnce.Do(func() {
+		var err error
+		if configValues, err = loadConfigFile(); err != nil {
+			slog.Error("Error reading config file", "err", err)
+		}
+	})
+	value, exists = configValues[key]
+	return value, exists
+}
+
+// loadConfigFile reads the file from CONFIG_FILE, or the default location if it exists.
+func loadConfigFile() (map[string]string, error) {
+	path, ok := os.LookupEnv("BESZEL_AGENT_CONFIG_FILE")
+	if !ok {
+		path, ok = os.LookupEnv("CONFIG_FILE")
+	}
+	if !ok {
+		path = defaultConfigFile
+	}
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// the default file is optional
+		if !ok && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	slog.Info("Config file", "path", path)
+	return parseConfigFile(data)
+}
+
+// parseConfigFile parses YAML config data into env var values.
+func parseConfigFile(data []byte) (map[string]string, error) {
+	var config configFile
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return config.toEnv()
+}
+
+// toEnv converts the config to env var values.
+func (c *configFile) toEnv() (map[string]string, error) {
+	values := make(map[string]string)
+	for key, value := range c.Env {
+		values[strings.TrimPrefix(strings.ToUpper(key), "BESZEL_AGENT_")] = value
+	}
+
+	// sensors
+	sensors := c.Sensors
+	if len(sensors.Include) > 0 && len(sensors.Exclude) > 0 {
+		return nil, errors.New("sensors: include and exclude can't be used together")
+	}
+	if sensors.Disabled {
+		values["SENSORS"] = ""
+	} else if len(sensors.Include) > 0 || len(sensors.Exclude) > 0 || len(sensors.Generic) > 0 {
+		entries := sensors.Include
+		if len(sensors.Exclude) > 0 {
+			entries = sensors.Exclude
+		}
+		entries = slices.Clone(entries)
+		for _, generic := range sensors.Generic {
+			if generic.Name == "" || generic.Unit == "" {
+				return nil, errors.New("sensors: generic sensors require a name and unit")
+			}
+			entry := fmt.Sprintf("(%s,%s,%s,%s", generic.Name, generic.Unit,
+				strconv.FormatFloat(generic.Max, 'f', -1, 64), strconv.FormatFloat(generic.Min, 'f', -1, 64))
+			if generic.Format != "" {
+				entry += "," + generic.Format
+			}
+			entries = append(entries, entry+")")
+		}
+		value := strings.Join(entries, ",")
+		if len(sensors.Exclude) > 0 {
+			value = "-" + value
+		}
+		values["SENSORS"] = value
+	}
+	setConfigValue(values, "PRIMARY_SENSOR", sensors.Primary)
+	setConfigValue(values, "SYS_SENSORS", sensors.Sys)
+	setConfigValue(values, "SENSOR_ALIASES", joinConfigMap(sensors.Aliases))
+	setConfigValue(values, "SENSOR_CATEGORIES", joinConfigMap(sensors.Categories))
+	setConfigValue(values, "SENSOR_STALE_TIMEOUT", sensors.StaleTimeout)
+	setConfigValue(values, "SENSOR_CHECK_INTERVAL", sensors.CheckInterval)
+	setConfigValue(values, "SENSOR_WEBHOOK_URL", sensors.Webhook.URL)
+	setConfigValue(values, "SENSOR_THRESHOLDS", strings.Join(sensors.Webhook.Thresholds, ","))
+	if len(sensors.Actions) > 0 {
+		actions, err := json.Marshal(sensors.Actions)
+		if err != nil {
+			return nil, err
+		}
+		values["SENSOR_ACTIONS"] = string(actions)
+	}
+
+	// filesystems and network
+	setConfigValue(values, "FILESYSTEM", c.Filesystems.Root)
+	setConfigValue(values, "EXTRA_FILESYSTEMS", strings.Join(c.Filesystems.Extra, ","))
+	setConfigValue(values, "NICS", strings.Join(c.Network.Interfaces, ","))
+
+	// precision
+	precision := make(map[string]string, len(c.Precision))
+	for metric, decimals := range c.Precision {
+		precision[metric] = strconv.Itoa(decimals)
+	}
+	setConfigValue(values, "PRECISION", joinConfigMap(precision))
+	quantize := make(map[string]string, len(c.Quantize))
+	for metric, step := range c.Quantize {
+		quantize[metric] = strconv.FormatFloat(step, 'f', -1, 64)
+	}
+	setConfigValue(values, "QUANTIZE", joinConfigMap(quantize))
+	setConfigValue(values, "INTERVALS", joinConfigMap(c.Intervals))
+	setConfigValue(values, "LABELS", joinConfigMap(c.Labels))
+
+	// metric rules
+	if len(c.Rules) > 0 {
+		rules, err := json.Marshal(c.Rules)
+		if err != nil {
+			return nil, err
+		}
+		values["METRIC_RULES"] = string(rules)
+	}
+
+	// additional hubs
+	if len(c.Hubs) > 0 {
+		hubs, err := json.Marshal(c.Hubs)
+		if err != nil {
+			return nil, err
+		}
+		values["HUBS"] = string(hubs)
+	}
+
+	return values, nil
+}
+
+// setConfigValue sets a value if not empty. Structured settings override the env section.
+func setConfigValue(values map[string]string, key, value string) {
+	if value != "" {
+		values[key] = value
+	}
+}
+
+// joinConfigMap joins a map into the "key=value,key=value" format, sorted by key.
+func joinConfigMap(m map[string]string) string {
+	entries := make([]string, 0, len(m))
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		entries = append(entries, key+"="+m[key])
+	}
+	return strings.Join(entries, ",")
+}
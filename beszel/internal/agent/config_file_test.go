@@ -0,0 +1,147 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	values, err := parseConfigFile([]byte(`
+sensors:
+  include: [coretemp*, nvme_composite]
+  primary: coretemp_package_id_0
+  aliases:
+    nvme_composite: NVMe
+    coretemp_package_id_0: CPU Package
+  categories:
+    nvme_*: chassis
+  generic:
+    - name: pressure
+      unit: Pa
+      min: 0
+      max: 1000.5
+filesystems:
+  root: /dev/sda1
+  extra: [/mnt/data, /mnt/backup]
+network:
+  interfaces: [eth0]
+precision:
+  network: 0
+quantize:
+  temperature: 0.5
+env:
+  DOCKER_HOST: tcp://localhost:2375
+  BESZEL_AGENT_FILESYSTEM: ignored
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"SENSORS":           "coretemp*,nvme_composite,(pressure,Pa,1000.5,0)",
+		"PRIMARY_SENSOR":    "coretemp_package_id_0",
+		"SENSOR_ALIASES":    "coretemp_package_id_0=CPU Package,nvme_composite=NVMe",
+		"SENSOR_CATEGORIES": "nvme_*=chassis",
+		"FILESYSTEM":        "/dev/sda1",
+		"EXTRA_FILESYSTEMS": "/mnt/data,/mnt/backup",
+		"NICS":              "eth0",
+		"PRECISION":         "network=0",
+		"QUANTIZE":          "temperature=0.5",
+		"DOCKER_HOST":       "tcp://localhost:2375",
+	}, values)
+
+	// sensors parse back into the same config as the env var
+	config := (&Agent{}).newSensorConfigWithEnv("", "", values["SENSORS"], false)
+	assert.Len(t, config.sensors, 2)
+	assert.Equal(t, GenericSensorConfig{Name: "pressure", Unit: "Pa", Maximum: 1000.5}, config.genericSensors["pressure"])
+}
+
+func TestParseConfigFileSensors(t *testing.T) {
+	values, err := parseConfigFile([]byte("sensors:\n  exclude: [acpitz, nvme*]\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"SENSORS": "-acpitz,nvme*"}, values)
+
+	values, err = parseConfigFile([]byte("sensors:\n  disabled: true\n  include: [acpitz]\n"))
+	require.NoError(t, err)
+	sensors, ok := values["SENSORS"]
+	assert.True(t, ok)
+	assert.Empty(t, sensors)
+
+	_, err = parseConfigFile([]byte("sensors:\n  include: [a]\n  exclude: [b]\n"))
+	assert.Error(t, err)
+
+	_, err = parseConfigFile([]byte("sensors:\n  generic:\n    - name: pressure\n"))
+	assert.Error(t, err)
+
+	values, err = parseConfigFile([]byte("sensors:\n  check_interval: 5s\n  webhook:\n    url: http://localhost/hook\n    thresholds: [cpu>90, pressure<900]\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"SENSOR_WEBHOOK_URL":    "http://localhost/hook",
+		"SENSOR_CHECK_INTERVAL": "5s",
+		"SENSOR_THRESHOLDS":     "cpu>90,pressure<900",
+	}, values)
+
+	values, err = parseConfigFile([]byte("sensors:\n  actions:\n    - sensor: cpu\n      above: 95\n      for: 3\n      cooldown: 10m\n      command: echo hot\n"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"sensor":"cpu","above":95,"for":3,"cooldown":"10m","command":"echo hot"}]`, values["SENSOR_ACTIONS"])
+
+	_, err = parseConfigFile([]byte("sensors: [invalid"))
+	assert.Error(t, err)
+}
+
+func TestParseConfigFileRules(t *testing.T) {
+	values, err := parseConfigFile([]byte("rules:\n  - metric: network\n    match: veth*\n    drop: true\n  - match: k10temp_tctl\n    rename: cpu\n    max: 110\n"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"metric":"network","match":"veth*","drop":true},{"match":"k10temp_tctl","rename":"cpu","max":110}]`, values["METRIC_RULES"])
+
+	rules, err := parseMetricRules(values["METRIC_RULES"])
+	require.NoError(t, err)
+	assert.Len(t, rules, 2)
+}
+
+func TestParseConfigFileIntervals(t *testing.T) {
+	values, err := parseConfigFile([]byte("intervals:\n  smart: 30m\n  raid: 1m\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "raid=1m,smart=30m", values["INTERVALS"])
+}
+
+func TestParseConfigFileLabels(t *testing.T) {
+	values, err := parseConfigFile([]byte("labels:\n  role: web\n  env: prod\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "env=prod,role=web", values["LABELS"])
+}
+
+func TestGetEnvConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.yml")
+	require.NoError(t, os.WriteFile(path, []byte("filesystems:\n  root: /dev/sdb1\nnetwork:\n  interfaces: [eth0]\n"), 0644))
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("BESZEL_AGENT_NICS", "wlan0")
+	configValuesOnce = sync.Once{}
+	t.Cleanup(func() {
+		configValues = nil
+		configValuesOnce = sync.Once{}
+	})
+
+	// file value is used when the env var is not set
+	value, exists := GetEnv("FILESYSTEM")
+	assert.True(t, exists)
+	assert.Equal(t, "/dev/sdb1", value)
+
+	// env var overrides the file
+	value, _ = GetEnv("NICS")
+	assert.Equal(t, "wlan0", value)
+
+	_, exists = GetEnv("EXTRA_FILESYSTEMS")
+	assert.False(t, exists)
+}
+
+func TestSplitSensors(t *testing.T) {
+	assert.Equal(t, []string{"cpu_temp", "(pressure,Pa,1000,0)", "gpu_temp"}, splitSensors("cpu_temp,(pressure,Pa,1000,0),gpu_temp"))
+	assert.Equal(t, []string{""}, splitSensors(""))
+}
@@ -2,6 +2,7 @@ package agent
 
 import (
 	"beszel/internal/agent/health"
+	"beszel/internal/clock"
 	"errors"
 	"log/slog"
 	"os"
@@ -19,8 +20,9 @@ type ConnectionManager struct {
 	eventChan     chan ConnectionEvent // Channel for connection events
 	wsClient      *WebSocketClient     // WebSocket client for hub communication
 	serverOptions ServerOptions        // Configuration for SSH server
-	wsTicker      *time.Ticker         // Ticker for WebSocket connection attempts
+	wsTicker      clock.Ticker         // Ticker for WebSocket connection attempts
 	isConnecting  bool                 // Prevents multiple simultaneous reconnection attempts
+	clock         clock.Clock          // Time source for tickers and reconnection delays
 }
 
 // ConnectionState represents the current connection state of the agent.
@@ -51,6 +53,7 @@ func newConnectionManager(agent *Agent) *ConnectionManager {
 	cm := &ConnectionManager{
 		agent: agent,
 		State: Disconnected,
+		clock: agent.clock,
 	}
 	return cm
 }
@@ -58,7 +61,7 @@ func newConnectionManager(agent *Agent) *ConnectionManager {
 // startWsTicker starts or resets the WebSocket connection attempt ticker.
 func (c *ConnectionManager) startWsTicker() {
 	if c.wsTicker == nil {
-		c.wsTicker = time.NewTicker(wsTickerInterval)
+		c.wsTicker = c.clock.NewTicker(wsTickerInterval)
 	} else {
 		c.wsTicker.Reset(wsTickerInterval)
 	}
@@ -91,25 +94,37 @@ func (c *ConnectionManager) Start(serverOptions ServerOptions) error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// reload sensor config on SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	defer signal.Stop(reloadChan)
+
 	c.startWsTicker()
 	c.connect()
+	c.agent.startHubClients()
 
 	// update health status immediately and every 90 seconds
 	_ = health.Update()
-	healthTicker := time.Tick(90 * time.Second)
+	healthTicker := c.clock.NewTicker(90 * time.Second)
+	defer healthTicker.Stop()
 
 	for {
 		select {
 		case connectionEvent := <-c.eventChan:
 			c.handleEvent(connectionEvent)
-		case <-c.wsTicker.C:
+		case <-c.wsTicker.C():
 			_ = c.startWebSocketConnection()
-		case <-healthTicker:
+		case <-healthTicker.C():
 			_ = health.Update()
+		case <-reloadChan:
+			c.agent.Lock()
+			c.agent.reloadSensorConfig()
+			c.agent.Unlock()
 		case <-sigChan:
 			slog.Info("Shutting down")
 			_ = c.agent.StopServer()
 			c.closeWebSocket()
+			c.agent.closeHubClients()
 			return health.CleanUp()
 		}
 	}
@@ -173,8 +188,8 @@ func (c *ConnectionManager) connect() {
 		c.isConnecting = false
 	}()
 
-	if c.wsClient != nil && time.Since(c.wsClient.lastConnectAttempt) < 5*time.Second {
-		time.Sleep(5 * time.Second)
+	if c.wsClient != nil && c.clock.Since(c.wsClient.lastConnectAttempt) < 5*time.Second {
+		c.clock.Sleep(5 * time.Second)
 	}
 
 	// Try WebSocket first, if it fails, start SSH server
@@ -193,7 +208,7 @@ func (c *ConnectionManager) startWebSocketConnection() error {
 	if c.wsClient == nil {
 		return errors.New("WebSocket client not initialized")
 	}
-	if time.Since(c.wsClient.lastConnectAttempt) < 5*time.Second {
+	if c.clock.Since(c.wsClient.lastConnectAttempt) < 5*time.Second {
 		return errors.New("already connecting")
 	}
 
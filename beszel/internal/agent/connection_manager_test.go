@@ -4,6 +4,7 @@
 package agent
 
 import (
+	"beszel/internal/clock"
 	"crypto/ed25519"
 	"fmt"
 	"net"
@@ -67,6 +68,7 @@ func TestConnectionManager_StateTransitions(t *testing.T) {
 		hubURL: &url.URL{
 			Host: "localhost:8080",
 		},
+		clock: agent.clock,
 	}
 	assert.NotNil(t, cm, "Connection manager should not be nil")
 	assert.Equal(t, Disconnected, initialState, "Initial state should be Disconnected")
@@ -95,6 +97,7 @@ func TestConnectionManager_EventHandling(t *testing.T) {
 		hubURL: &url.URL{
 			Host: "localhost:8080",
 		},
+		clock: agent.clock,
 	}
 
 	testCases := []struct {
@@ -263,6 +266,52 @@ func TestConnectionManager_ConnectWithRateLimit(t *testing.T) {
 	assert.NotContains(t, err.Error(), "already connecting", "Error should not indicate rate limiting")
 }
 
+// TestConnectionManager_MockClock tests ticker and rate limiting with an injected clock
+func TestConnectionManager_MockClock(t *testing.T) {
+	agent := createTestAgent(t)
+	mock := clock.NewMock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	cm := agent.connectionManager
+	cm.clock = mock
+
+	os.Setenv("BESZEL_AGENT_HUB_URL", "ws://localhost:8080")
+	os.Setenv("BESZEL_AGENT_TOKEN", "test-token")
+	defer func() {
+		os.Unsetenv("BESZEL_AGENT_HUB_URL")
+		os.Unsetenv("BESZEL_AGENT_TOKEN")
+	}()
+	agent.clock = mock
+	wsClient, err := newWebSocketClient(agent)
+	require.NoError(t, err)
+	cm.wsClient = wsClient
+
+	// ws ticker fires on the mock interval
+	cm.startWsTicker()
+	defer cm.stopWsTicker()
+	mock.Advance(wsTickerInterval - time.Second)
+	select {
+	case <-cm.wsTicker.C():
+		t.Fatal("ticker fired early")
+	default:
+	}
+	mock.Advance(time.Second)
+	select {
+	case tick := <-cm.wsTicker.C():
+		assert.Equal(t, mock.Now(), tick)
+	default:
+		t.Fatal("ticker did not fire")
+	}
+
+	// rate limit uses mock time
+	wsClient.lastConnectAttempt = mock.Now()
+	err = cm.startWebSocketConnection()
+	assert.ErrorContains(t, err, "already connecting")
+	mock.Advance(5 * time.Second)
+	err = cm.startWebSocketConnection()
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "already connecting")
+	assert.Equal(t, mock.Now(), wsClient.lastConnectAttempt, "connect attempt should use mock time")
+}
+
 // TestConnectionManager_StartWithInvalidConfig tests starting with invalid configuration
 func TestConnectionManager_StartWithInvalidConfig(t *testing.T) {
 	agent := createTestAgent(t)
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+)
+
+// Directory of the kernel's CPU devices
+const cpuSysfsPath = "/sys/devices/system/cpu"
+
+// cpuMonitor reads the current frequency and throttling state of each core
+// from /sys/devices/system/cpu. Per-core usage is read with gopsutil.
+type cpuMonitor struct {
+	sysfsPath      string
+	throttleCounts map[int]uint64 // last thermal throttle count of each core
+}
+
+// cpuFreqStats is the frequency and throttling state of the cores.
+type cpuFreqStats struct {
+	freqs     []float64 // current frequency of each core in MHz, zero if offline
+	throttled int       // number of throttled cores
+}
+
+// newCpuMonitor creates a CPU monitor if the cores report their frequency,
+// unless the CPU_FREQ env var is "false".
+func newCpuMonitor() *cpuMonitor {
+	if enabled, _ := GetEnv("CPU_FREQ"); enabled == "false" {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(cpuSysfsPath, "cpu0", "cpufreq")); err != nil {
+		return nil
+	}
+	return &cpuMonitor{sysfsPath: cpuSysfsPath, throttleCounts: make(map[int]uint64)}
+}
+
+// collect returns the frequency of each core and the number of throttled
+// cores. A core is throttled if its max frequency is capped below the hardware
+// max, as the thermal drivers of SBCs do, or if its thermal throttle count
+// increased since the previous collection, as on x86.
+func (m *cpuMonitor) collect() (cpuFreqStats, error) {
+	cores, err := m.cores()
+	if err != nil {
+		return cpuFreqStats{}, err
+	}
+	var stats cpuFreqStats
+	if len(cores) > 0 {
+		stats.freqs = make([]float64, cores[len(cores)-1]+1)
+	}
+	for _, core := range cores {
+		dir := filepath.Join(m.sysfsPath, "cpu"+strconv.Itoa(core))
+		if freq, err := readUintFile(filepath.Join(dir, "cpufreq", "scaling_cur_freq")); err == nil {
+			// kHz to MHz
+			stats.freqs[core] = float64(freq / 1000)
+		}
+		throttled := false
+		maxFreq, err1 := readUintFile(filepath.Join(dir, "cpufreq", "scaling_max_freq"))
+		hwMaxFreq, err2 := readUintFile(filepath.Join(dir, "cpufreq", "cpuinfo_max_freq"))
+		if err1 == nil && err2 == nil && maxFreq < hwMaxFreq {
+			throttled = true
+		}
+		if count, err := readUintFile(filepath.Join(dir, "thermal_throttle", "core_throttle_count")); err == nil {
+			if last, ok := m.throttleCounts[core]; ok && count > last {
+				throttled = true
+			}
+			m.throttleCounts[core] = count
+		}
+		if throttled {
+			stats.throttled++
+		}
+	}
+	return stats, nil
+}
+
+// cores returns the numbers of the cores in sysfs, in order.
+func (m *cpuMonitor) cores() ([]int, error) {
+	entries, err := os.ReadDir(m.sysfsPath)
+	if err != nil {
+		return nil, err
+	}
+	var cores []int
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), "cpu")
+		if !ok {
+			continue
+		}
+		if core, err := strconv.Atoi(name); err == nil && core >= 0 {
+			cores = append(cores, core)
+		}
+	}
+	slices.Sort(cores)
+	return cores, nil
+}
+
+// readUintFile reads a file containing a single unsigned integer.
+func readUintFile(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// updateCpuCores sets the usage of each core, and their frequency and
+// throttling state if available.
+func (a *Agent) updateCpuCores(ctx context.Context, systemStats *system.Stats) {
+	if corePct, err := cpu.PercentWithContext(ctx, 0, true); err == nil && len(corePct) > 1 {
+		systemStats.CpuCores = make([]float64, len(corePct))
+		for i, pct := range corePct {
+			systemStats.CpuCores[i] = a.precision.round(metricCpu, pct)
+		}
+	}
+	if a.cpuMonitor == nil {
+		return
+	}
+	stats, err := a.cpuMonitor.collect()
+	a.setCollectorStatus(collectorCpuFreq, err)
+	if err != nil {
+		return
+	}
+	systemStats.CpuFreq = stats.freqs
+	systemStats.CpuThrottled = float64(stats.throttled)
+}
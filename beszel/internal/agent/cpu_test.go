@@ -0,0 +1,71 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCpuFiles writes sysfs files of a core, e.g. "cpufreq/scaling_cur_freq".
+func writeCpuFiles(t *testing.T, root, core string, files map[string]string) {
+	for name, value := range files {
+		path := filepath.Join(root, core, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(value+"\n"), 0644))
+	}
+}
+
+func TestCpuMonitorCollect(t *testing.T) {
+	root := t.TempDir()
+	// not cores
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "cpufreq"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "cpuidle"), 0755))
+	writeCpuFiles(t, root, "cpu0", map[string]string{
+		"cpufreq/scaling_cur_freq":             "1800000",
+		"cpufreq/scaling_max_freq":             "1800000",
+		"cpufreq/cpuinfo_max_freq":             "1800000",
+		"thermal_throttle/core_throttle_count": "4",
+	})
+	// capped below the hardware max by a thermal driver
+	writeCpuFiles(t, root, "cpu1", map[string]string{
+		"cpufreq/scaling_cur_freq": "600000",
+		"cpufreq/scaling_max_freq": "600000",
+		"cpufreq/cpuinfo_max_freq": "1800000",
+	})
+	// offline core without cpufreq
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "cpu3"), 0755))
+	writeCpuFiles(t, root, "cpu10", map[string]string{
+		"cpufreq/scaling_cur_freq": "2400500",
+	})
+
+	monitor := &cpuMonitor{sysfsPath: root, throttleCounts: make(map[int]uint64)}
+	stats, err := monitor.collect()
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1800, 600, 0, 0, 0, 0, 0, 0, 0, 0, 2400}, stats.freqs)
+	assert.Equal(t, 1, stats.throttled)
+
+	// throttle count increased since the previous collection
+	writeCpuFiles(t, root, "cpu0", map[string]string{"thermal_throttle/core_throttle_count": "6"})
+	stats, err = monitor.collect()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.throttled)
+
+	stats, err = monitor.collect()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.throttled)
+}
+
+func TestCpuMonitorMissingSysfs(t *testing.T) {
+	monitor := &cpuMonitor{sysfsPath: filepath.Join(t.TempDir(), "missing"), throttleCounts: make(map[int]uint64)}
+	_, err := monitor.collect()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	t.Setenv("CPU_FREQ", "false")
+	assert.Nil(t, newCpuMonitor())
+}
@@ -0,0 +1,349 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// gRPC methods of the CRI runtime service
+const (
+	criVersionPath            = "/runtime.v1.RuntimeService/Version"
+	criListContainersPath     = "/runtime.v1.RuntimeService/ListContainers"
+	criListContainerStatsPath = "/runtime.v1.RuntimeService/ListContainerStats"
+)
+
+// CONTAINER_RUNNING in the CRI ContainerState enum
+const criContainerRunning = 1
+
+// Kubernetes label of the pod a container belongs to
+const criPodNameLabel = "io.kubernetes.pod.name"
+
+// Sockets of CRI runtimes, checked in order if CRI_ENDPOINT is not set
+var criSockets = []string{
+	"/run/containerd/containerd.sock",
+	"/run/k3s/containerd/containerd.sock",
+	"/var/snap/microk8s/common/run/containerd.sock",
+	"/run/crio/crio.sock",
+}
+
+// criManager collects container stats from a Container Runtime Interface (CRI)
+// runtime such as containerd or CRI-O, as used on Kubernetes nodes.
+//
+// CRI reports CPU and memory usage of containers. Network and block I/O are
+// not available per container.
+type criManager struct {
+	client            *http.Client                // HTTP/2 client connected to the runtime socket
+	endpoint          string                      // Path of the runtime socket
+	containerStatsMap map[string]*container.Stats // Keeps track of container stats by container id
+	numCPU            float64                     // Number of CPUs, CPU usage is relative to all of them
+	precision         precisionConfig             // Rounding of container stats
+}
+
+// criContainer is a running container from ListContainers
+type criContainer struct {
+	id      string
+	name    string
+	state   uint64
+	pod     string
+	attempt uint32 // restarts of the container in its pod
+}
+
+// criContainerStats is the usage of a container from ListContainerStats
+type criContainerStats struct {
+	id           string
+	cpuTimestamp int64  // nanoseconds
+	cpuUsage     uint64 // cumulative CPU time in nanoseconds
+	workingSet   uint64 // bytes
+}
+
+// newCriManager returns a CRI client if CRI_ENDPOINT is set, or if no Docker or
+// Podman socket is found and a CRI runtime socket is. Returns nil otherwise, or
+// if the runtime doesn't respond.
+func newCriManager(a *Agent) *criManager {
+	endpoint, set := GetEnv("CRI_ENDPOINT")
+	if set {
+		if endpoint == "" {
+			return nil
+		}
+	} else {
+		if _, dockerHostSet := GetEnv("DOCKER_HOST"); dockerHostSet || findSocket(dockerSockets()) != "" {
+			return nil
+		}
+		if endpoint = findSocket(criSockets); endpoint == "" {
+			return nil
+		}
+	}
+	endpoint = strings.TrimPrefix(endpoint, "unix://")
+
+	transport := &http2.Transport{
+		// gRPC over a unix socket uses HTTP/2 with prior knowledge
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", endpoint)
+		},
+	}
+	m := &criManager{
+		client:            &http.Client{Transport: transport, Timeout: 5 * time.Second},
+		endpoint:          endpoint,
+		containerStatsMap: make(map[string]*container.Stats),
+		numCPU:            float64(runtime.NumCPU()),
+		precision:         a.precision,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	name, version, err := m.version(ctx)
+	if err != nil {
+		slog.Warn("CRI runtime not available", "endpoint", endpoint, "err", err)
+		return nil
+	}
+	slog.Info("CRI", "runtime", name, "version", version, "endpoint", endpoint)
+	a.systemInfo.ContainerRuntime = name
+	return m
+}
+
+// findSocket returns the first of the socket paths that exists, or an empty string.
+func findSocket(paths []string) string {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// call makes a unary call to a method of the runtime service.
+func (m *criManager) call(ctx context.Context, path string, msg []byte) ([]byte, error) {
+	return grpcCall(ctx, m.client, "http://localhost"+path, nil, msg)
+}
+
+// version returns the name and version of the runtime, e.g. containerd and v1.7.2.
+func (m *criManager) version(ctx context.Context) (name, version string, err error) {
+	res, err := m.call(ctx, criVersionPath, nil)
+	if err != nil {
+		return "", "", err
+	}
+	err = rangeProtoFields(res, func(num protowire.Number, _ uint64, value []byte) {
+		switch num {
+		case 2:
+			name = string(value)
+		case 3:
+			version = string(value)
+		}
+	})
+	return name, version, err
+}
+
+// getContainerStats returns stats for all running containers
+func (m *criManager) getContainerStats(ctx context.Context) ([]*container.Stats, error) {
+	containers, err := m.listRunningContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := m.call(ctx, criListContainerStatsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := parseCriContainerStats(res)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*container.Stats, 0, len(containers))
+	for _, u := range usage {
+		ctr, running := containers[u.id]
+		if !running {
+			continue
+		}
+		stats = append(stats, m.updateContainerStats(ctr, u))
+	}
+
+	// remove stats of containers that are no longer running
+	for id := range m.containerStatsMap {
+		if _, running := containers[id]; !running {
+			delete(m.containerStatsMap, id)
+		}
+	}
+	return stats, nil
+}
+
+// updateContainerStats calculates the CPU usage since the previous collection
+// and returns the updated stats of the container.
+func (m *criManager) updateContainerStats(ctr criContainer, u criContainerStats) *container.Stats {
+	stats, initialized := m.containerStatsMap[u.id]
+	if !initialized {
+		stats = &container.Stats{}
+		m.containerStatsMap[u.id] = stats
+	}
+	stats.Name = ctr.displayName()
+	// kubelet creates a new container with the next attempt number on restart
+	stats.Restarts = ctr.attempt
+
+	var cpuPct float64
+	readTime := time.Unix(0, u.cpuTimestamp)
+	// counters reset when the container restarts
+	if initialized && u.cpuUsage >= stats.CpuContainer && readTime.After(stats.PrevReadTime) {
+		elapsed := float64(readTime.Sub(stats.PrevReadTime).Nanoseconds())
+		cpuPct = float64(u.cpuUsage-stats.CpuContainer) / elapsed / m.numCPU * 100
+	}
+	stats.CpuContainer = u.cpuUsage
+	stats.PrevReadTime = readTime
+
+	stats.Cpu = m.precision.round(metricContainers, min(cpuPct, 100))
+	stats.Mem = m.precision.megabytes(metricContainers, float64(u.workingSet))
+	return stats
+}
+
+// displayName returns the container name, prefixed with its pod on Kubernetes
+// since container names are only unique within a pod.
+func (c criContainer) displayName() string {
+	if c.pod != "" {
+		return c.pod + "/" + c.name
+	}
+	return c.name
+}
+
+// listRunningContainers returns the running containers by id.
+func (m *criManager) listRunningContainers(ctx context.Context) (map[string]criContainer, error) {
+	// ListContainersRequest with a ContainerFilter for the running state
+	var state []byte
+	state = protowire.AppendTag(state, 1, protowire.VarintType)
+	state = protowire.AppendVarint(state, criContainerRunning)
+	var filter []byte
+	filter = protowire.AppendTag(filter, 2, protowire.BytesType)
+	filter = protowire.AppendBytes(filter, state)
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType)
+	req = protowire.AppendBytes(req, filter)
+
+	res, err := m.call(ctx, criListContainersPath, req)
+	if err != nil {
+		return nil, err
+	}
+	return parseCriContainers(res)
+}
+
+// parseCriContainers parses the running containers of a ListContainersResponse.
+func parseCriContainers(res []byte) (map[string]criContainer, error) {
+	containers := make(map[string]criContainer)
+	var parseErr error
+	err := rangeProtoFields(res, func(num protowire.Number, _ uint64, value []byte) {
+		if num != 1 || parseErr != nil {
+			return
+		}
+		var ctr criContainer
+		parseErr = rangeProtoFields(value, func(num protowire.Number, varint uint64, value []byte) {
+			switch num {
+			case 1:
+				ctr.id = string(value)
+			case 3: // ContainerMetadata
+				_ = rangeProtoFields(value, func(num protowire.Number, varint uint64, value []byte) {
+					switch num {
+					case 1:
+						ctr.name = string(value)
+					case 2:
+						ctr.attempt = uint32(varint)
+					}
+				})
+			case 6:
+				ctr.state = varint
+			case 8: // labels map entry
+				var key, labelValue string
+				_ = rangeProtoFields(value, func(num protowire.Number, _ uint64, value []byte) {
+					switch num {
+					case 1:
+						key = string(value)
+					case 2:
+						labelValue = string(value)
+					}
+				})
+				if key == criPodNameLabel {
+					ctr.pod = labelValue
+				}
+			}
+		})
+		if ctr.id != "" && ctr.state == criContainerRunning {
+			if ctr.name == "" {
+				ctr.name = ctr.id[:min(12, len(ctr.id))]
+			}
+			containers[ctr.id] = ctr
+		}
+	})
+	if err == nil {
+		err = parseErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid ListContainers response: %w", err)
+	}
+	return containers, nil
+}
+
+// parseCriContainerStats parses a ListContainerStatsResponse.
+func parseCriContainerStats(res []byte) ([]criContainerStats, error) {
+	var stats []criContainerStats
+	var parseErr error
+	err := rangeProtoFields(res, func(num protowire.Number, _ uint64, value []byte) {
+		if num != 1 || parseErr != nil {
+			return
+		}
+		var s criContainerStats
+		parseErr = rangeProtoFields(value, func(num protowire.Number, _ uint64, value []byte) {
+			switch num {
+			case 1: // ContainerAttributes
+				_ = rangeProtoFields(value, func(num protowire.Number, _ uint64, value []byte) {
+					if num == 1 {
+						s.id = string(value)
+					}
+				})
+			case 2: // CpuUsage
+				_ = rangeProtoFields(value, func(num protowire.Number, varint uint64, value []byte) {
+					switch num {
+					case 1:
+						s.cpuTimestamp = int64(varint)
+					case 2:
+						s.cpuUsage = parseProtoUInt64Value(value)
+					}
+				})
+			case 3: // MemoryUsage
+				_ = rangeProtoFields(value, func(num protowire.Number, _ uint64, value []byte) {
+					if num == 2 {
+						s.workingSet = parseProtoUInt64Value(value)
+					}
+				})
+			}
+		})
+		if s.id != "" {
+			stats = append(stats, s)
+		}
+	})
+	if err == nil {
+		err = parseErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid ListContainerStats response: %w", err)
+	}
+	return stats, nil
+}
+
+// parseProtoUInt64Value returns the value of a UInt64Value message.
+func parseProtoUInt64Value(b []byte) (value uint64) {
+	_ = rangeProtoFields(b, func(num protowire.Number, varint uint64, _ []byte) {
+		if num == 1 {
+			value = varint
+		}
+	})
+	return value
+}
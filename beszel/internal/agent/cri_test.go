@@ -0,0 +1,152 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoMessage encodes fields given as number, value pairs, where values are
+// strings, nested messages ([]byte) or varints (uint64).
+func protoMessage(fields ...any) []byte {
+	var b []byte
+	for i := 0; i+1 < len(fields); i += 2 {
+		num := protowire.Number(fields[i].(int))
+		switch v := fields[i+1].(type) {
+		case string:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		case []byte:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, v)
+		case uint64:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, v)
+		}
+	}
+	return b
+}
+
+func criTestContainer(id, name, pod string, state, attempt uint64) []byte {
+	return protoMessage(1, id, 3, protoMessage(1, name, 2, attempt), 6, state,
+		8, protoMessage(1, criPodNameLabel, 2, pod), 8, protoMessage(1, "app", 2, "web"))
+}
+
+func criTestStats(id string, timestamp time.Time, cpu, memory uint64) []byte {
+	return protoMessage(
+		1, protoMessage(1, id, 2, protoMessage(1, "ignored")),
+		2, protoMessage(1, uint64(timestamp.UnixNano()), 2, protoMessage(1, cpu)),
+		3, protoMessage(1, uint64(timestamp.UnixNano()), 2, protoMessage(1, memory), 4, protoMessage(1, memory*2)),
+	)
+}
+
+func TestParseCriContainers(t *testing.T) {
+	res := protoMessage(
+		1, criTestContainer("abc123", "nginx", "web-7d9f", criContainerRunning, 3),
+		1, criTestContainer("def456", "sidecar", "", criContainerRunning, 0),
+		1, criTestContainer("exited", "job", "job-1", 2, 0),
+	)
+	containers, err := parseCriContainers(res)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]criContainer{
+		"abc123": {id: "abc123", name: "nginx", state: criContainerRunning, pod: "web-7d9f", attempt: 3},
+		"def456": {id: "def456", name: "sidecar", state: criContainerRunning},
+	}, containers)
+	assert.Equal(t, "web-7d9f/nginx", containers["abc123"].displayName())
+	assert.Equal(t, "sidecar", containers["def456"].displayName())
+
+	_, err = parseCriContainers([]byte{0x0a, 0x10})
+	assert.Error(t, err)
+}
+
+func TestCriManagerGetContainerStats(t *testing.T) {
+	start := time.Now()
+	var statsRes []byte
+	var containersReq []byte
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var msg []byte
+		switch r.URL.Path {
+		case criVersionPath:
+			msg = protoMessage(1, "0.1.0", 2, "containerd", 3, "v1.7.2", 4, "v1")
+		case criListContainersPath:
+			containersReq = body[5:]
+			msg = protoMessage(1, criTestContainer("abc123", "nginx", "web-7d9f", criContainerRunning, 2))
+		case criListContainerStatsPath:
+			msg = statsRes
+		default:
+			w.Header().Set("Grpc-Status", "12")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		frame := make([]byte, 5, 5+len(msg))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+		_, _ = w.Write(append(frame, msg...))
+		w.Header().Set("Grpc-Status", "0")
+	})
+
+	socket := filepath.Join(t.TempDir(), "containerd.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := &http.Server{Handler: h2c.NewHandler(handler, &http2.Server{})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	t.Setenv("BESZEL_AGENT_CRI_ENDPOINT", "unix://"+socket)
+	agent := &Agent{}
+	m := newCriManager(agent)
+	require.NotNil(t, m)
+	assert.Equal(t, "containerd", agent.systemInfo.ContainerRuntime)
+	m.numCPU = 2
+
+	// first collection has no CPU usage yet
+	statsRes = protoMessage(
+		1, criTestStats("abc123", start, 1e9, 64*1024*1024),
+		1, criTestStats("exited", start, 5e9, 1024),
+	)
+	stats, err := m.getContainerStats(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "web-7d9f/nginx", stats[0].Name)
+	assert.Equal(t, 0.0, stats[0].Cpu)
+	assert.Equal(t, 64.0, stats[0].Mem)
+	assert.Equal(t, uint32(2), stats[0].Restarts)
+	// only running containers are requested
+	assert.Equal(t, protoMessage(1, protoMessage(2, protoMessage(1, uint64(criContainerRunning)))), containersReq)
+
+	// one CPU second in ten seconds on two CPUs
+	statsRes = protoMessage(1, criTestStats("abc123", start.Add(10*time.Second), 2e9, 32*1024*1024))
+	stats, err = m.getContainerStats(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, 5.0, stats[0].Cpu)
+	assert.Equal(t, 32.0, stats[0].Mem)
+
+	// stats of containers that stopped running are removed
+	m.containerStatsMap["stopped"] = stats[0]
+	_, err = m.getContainerStats(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, m.containerStatsMap, "stopped")
+
+	// disabled with an empty endpoint, or if the runtime doesn't respond
+	t.Setenv("BESZEL_AGENT_CRI_ENDPOINT", "")
+	assert.Nil(t, newCriManager(agent))
+	t.Setenv("BESZEL_AGENT_CRI_ENDPOINT", filepath.Join(t.TempDir(), "missing.sock"))
+	assert.Nil(t, newCriManager(agent))
+}
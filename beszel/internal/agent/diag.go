@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"beszel"
+	"beszel/internal/diag"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Number of recent journal lines included in diagnostic bundles
+const diagLogLines = 500
+
+// Diag writes a diagnostic bundle for bug reports to path, with the config,
+// recent logs, collector status and a sample of the stats sent to the hub.
+// If review is true, the user reviews the bundle before it's written.
+func Diag(path string, review bool, in io.Reader, out io.Writer) error {
+	a, err := NewAgent()
+	if err != nil {
+		return err
+	}
+	data := a.gatherStats("")
+
+	bundle := diag.New()
+	bundle.Add("version.txt", []byte(beszel.AppName+"-agent "+beszel.Version+" "+runtime.GOOS+"/"+runtime.GOARCH+"\n"))
+
+	// env vars and config file values, which use the unprefixed names
+	config := diag.Env("BESZEL_AGENT_")
+	getConfigValue("")
+	for key, value := range configValues {
+		config["config_file:"+key] = diag.RedactSecret(key, value)
+	}
+	if err := bundle.AddJSON("config.json", config); err != nil {
+		return err
+	}
+	if err := bundle.AddJSON("collectors.json", data.Info.Collectors); err != nil {
+		return err
+	}
+	if err := bundle.AddJSON("sample.json", data); err != nil {
+		return err
+	}
+	if logs := agentLogs(); len(logs) > 0 {
+		bundle.Add("logs.txt", logs)
+	}
+
+	hostname, _ := os.Hostname()
+	bundle.MaskHostnames(hostname, data.Info.Hostname)
+	for _, container := range data.Containers {
+		bundle.MaskContainers(container.Name)
+	}
+	return bundle.Save(path, review, in, out)
+}
+
+// agentLogs returns recent logs of the agent service from the systemd journal,
+// or nil if they aren't available.
+func agentLogs() []byte {
+	journalctl, err := exec.LookPath("journalctl")
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, journalctl, "--unit", beszel.AppName+"-agent", "--lines", strconv.Itoa(diagLogLines), "--no-pager", "--output", "short-iso").Output()
+	if err != nil {
+		return nil
+	}
+	return output
+}
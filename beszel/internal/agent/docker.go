@@ -2,6 +2,7 @@ package agent
 
 import (
 	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -31,6 +32,11 @@ type dockerManager struct {
 	buf                 *bytes.Buffer               // Buffer to store and read response bodies
 	decoder             *json.Decoder               // Reusable JSON decoder that reads from buf
 	apiStats            *container.ApiStats         // Reusable API stats object
+	precision           precisionConfig             // Rounding of container stats
+	diskUsageMutex      sync.Mutex                  // Mutex to prevent concurrent access to diskUsage
+	diskUsage           *system.DockerDiskUsage     // Latest disk usage, updated in the background
+	diskUsageTime       time.Time                   // Time diskUsage was last requested
+	diskUsageInterval   time.Duration               // Time between disk usage requests
 }
 
 // userAgentRoundTripper is a custom http.RoundTripper that adds a User-Agent header to all requests
@@ -62,8 +68,8 @@ func (d *dockerManager) dequeue() {
 }
 
 // Returns stats for all running containers
-func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
-	resp, err := dm.client.Get("http://localhost/containers/json")
+func (dm *dockerManager) getDockerStats(ctx context.Context) ([]*container.Stats, error) {
+	resp, err := dm.get(ctx, "http://localhost/containers/json")
 	if err != nil {
 		return nil, err
 	}
@@ -99,7 +105,12 @@ func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
 		dm.queue()
 		go func() {
 			defer dm.dequeue()
-			err := dm.updateContainerStats(ctr)
+			// restarting containers have no stats to read between restarts
+			if ctr.State == "restarting" {
+				dm.updateRestartingContainer(ctx, ctr)
+				return
+			}
+			err := dm.updateContainerStats(ctx, ctr)
 			// if error, delete from map and add to failed list to retry
 			if err != nil {
 				dm.containerStatsMutex.Lock()
@@ -113,14 +124,15 @@ func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
 	dm.wg.Wait()
 
 	// retry failed containers separately so we can run them in parallel (docker 24 bug)
-	if len(failedContainers) > 0 {
+	// skipped if the collection was cancelled since the retries would fail as well
+	if len(failedContainers) > 0 && ctx.Err() == nil {
 		slog.Debug("Retrying failed containers", "count", len(failedContainers))
 		for i := range failedContainers {
 			ctr := failedContainers[i]
 			dm.queue()
 			go func() {
 				defer dm.dequeue()
-				err = dm.updateContainerStats(ctr)
+				err = dm.updateContainerStats(ctx, ctr)
 				if err != nil {
 					slog.Error("Error getting container stats", "err", err)
 				}
@@ -143,10 +155,20 @@ func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
 }
 
 // Updates stats for individual container
-func (dm *dockerManager) updateContainerStats(ctr *container.ApiInfo) error {
+func (dm *dockerManager) updateContainerStats(ctx context.Context, ctr *container.ApiInfo) error {
 	name := ctr.Names[0][1:]
 
-	resp, err := dm.client.Get("http://localhost/containers/" + ctr.IdShort + "/stats?stream=0&one-shot=1")
+	// the restart count only changes when the container starts, which removes
+	// its stats, so it's read for containers without stats
+	dm.containerStatsMutex.RLock()
+	_, initialized := dm.containerStatsMap[ctr.IdShort]
+	dm.containerStatsMutex.RUnlock()
+	var restarts uint32
+	if !initialized {
+		restarts = dm.getRestartCount(ctx, ctr.IdShort)
+	}
+
+	resp, err := dm.get(ctx, "http://localhost/containers/"+ctr.IdShort+"/stats?stream=0&one-shot=1")
 	if err != nil {
 		return err
 	}
@@ -158,15 +180,19 @@ func (dm *dockerManager) updateContainerStats(ctr *container.ApiInfo) error {
 	// add empty values if they doesn't exist in map
 	stats, initialized := dm.containerStatsMap[ctr.IdShort]
 	if !initialized {
-		stats = &container.Stats{Name: name}
+		stats = &container.Stats{Name: name, Restarts: restarts}
 		dm.containerStatsMap[ctr.IdShort] = stats
 	}
+	stats.Health = ctr.Health()
+	stats.Project = ctr.Project()
 
 	// reset current stats
 	stats.Cpu = 0
 	stats.Mem = 0
 	stats.NetworkSent = 0
 	stats.NetworkRecv = 0
+	stats.DiskRead = 0
+	stats.DiskWrite = 0
 
 	// docker host container stats response
 	// res := dm.getApiStats()
@@ -175,6 +201,8 @@ func (dm *dockerManager) updateContainerStats(ctr *container.ApiInfo) error {
 
 	res := dm.apiStats
 	res.Networks = nil
+	res.BlkioStats.IoServiceBytesRecursive = nil
+	res.StorageStats = container.StorageStats{}
 	if err := dm.decode(resp, res); err != nil {
 		return err
 	}
@@ -229,15 +257,151 @@ func (dm *dockerManager) updateContainerStats(ctr *container.ApiInfo) error {
 	}
 	stats.PrevNet.Sent, stats.PrevNet.Recv = total_sent, total_recv
 
-	stats.Cpu = twoDecimals(cpuPct)
-	stats.Mem = bytesToMegabytes(float64(usedMemory))
-	stats.NetworkSent = bytesToMegabytes(float64(sent_delta))
-	stats.NetworkRecv = bytesToMegabytes(float64(recv_delta))
+	// block i/o
+	totalRead, totalWrite := res.DiskIO()
+	var readDelta, writeDelta uint64
+	// counters reset when the container restarts
+	if initialized && millisecondsElapsed > 0 && totalRead >= stats.PrevDisk.Read && totalWrite >= stats.PrevDisk.Write {
+		readDelta = (totalRead - stats.PrevDisk.Read) * 1000 / millisecondsElapsed
+		writeDelta = (totalWrite - stats.PrevDisk.Write) * 1000 / millisecondsElapsed
+	}
+	stats.PrevDisk.Read, stats.PrevDisk.Write = totalRead, totalWrite
+
+	stats.Cpu = dm.precision.round(metricContainers, cpuPct)
+	stats.Mem = dm.precision.megabytes(metricContainers, float64(usedMemory))
+	stats.NetworkSent = dm.precision.megabytes(metricContainers, float64(sent_delta))
+	stats.NetworkRecv = dm.precision.megabytes(metricContainers, float64(recv_delta))
+	stats.DiskRead = dm.precision.megabytes(metricContainers, float64(readDelta))
+	stats.DiskWrite = dm.precision.megabytes(metricContainers, float64(writeDelta))
 	stats.PrevReadTime = res.Read
 
 	return nil
 }
 
+// updateRestartingContainer reports a restarting container with its restart
+// count and no usage
+func (dm *dockerManager) updateRestartingContainer(ctx context.Context, ctr *container.ApiInfo) {
+	restarts := dm.getRestartCount(ctx, ctr.IdShort)
+	dm.containerStatsMutex.Lock()
+	defer dm.containerStatsMutex.Unlock()
+	if prev, ok := dm.containerStatsMap[ctr.IdShort]; ok {
+		restarts = max(restarts, prev.Restarts)
+	}
+	// usage counters reset when the container starts again
+	dm.containerStatsMap[ctr.IdShort] = &container.Stats{
+		Name:     ctr.Names[0][1:],
+		Health:   container.HealthRestarting,
+		Restarts: restarts,
+		Project:  ctr.Project(),
+	}
+}
+
+// getRestartCount returns the number of times the container was restarted by
+// its restart policy, or 0 if it can't be read
+func (dm *dockerManager) getRestartCount(ctx context.Context, id string) uint32 {
+	resp, err := dm.get(ctx, "http://localhost/containers/"+id+"/json")
+	if err != nil {
+		slog.Debug("Error inspecting container", "id", id, "err", err)
+		return 0
+	}
+	var inspect container.ApiInspect
+	// decode in place since the shared decoder is used by other goroutines
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		slog.Debug("Error inspecting container", "id", id, "err", err)
+		return 0
+	}
+	return inspect.RestartCount
+}
+
+// getDiskUsage returns the latest disk usage of images, containers, volumes and
+// build cache, and requests it in the background if it's older than
+// diskUsageInterval. Returns nil until the first request completes.
+func (dm *dockerManager) getDiskUsage() *system.DockerDiskUsage {
+	dm.diskUsageMutex.Lock()
+	defer dm.diskUsageMutex.Unlock()
+	if time.Since(dm.diskUsageTime) >= dm.diskUsageInterval {
+		dm.diskUsageTime = time.Now()
+		go dm.updateDiskUsage()
+	}
+	return dm.diskUsage
+}
+
+// updateDiskUsage requests the disk usage from /system/df
+func (dm *dockerManager) updateDiskUsage() {
+	// the request can take much longer than the container stats requests
+	client := *dm.client
+	client.Timeout = time.Minute
+	resp, err := client.Get("http://localhost/system/df")
+	if err != nil {
+		slog.Debug("Error getting Docker disk usage", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Debug("Error getting Docker disk usage", "status", resp.Status)
+		return
+	}
+	var df container.ApiDiskUsage
+	if err := json.NewDecoder(resp.Body).Decode(&df); err != nil {
+		slog.Debug("Error getting Docker disk usage", "err", err)
+		return
+	}
+	usage := dm.calculateDiskUsage(&df)
+	dm.diskUsageMutex.Lock()
+	dm.diskUsage = usage
+	dm.diskUsageMutex.Unlock()
+}
+
+// calculateDiskUsage sums the sizes like docker system df. Images share
+// layers, so their total is the size of all layers.
+func (dm *dockerManager) calculateDiskUsage(df *container.ApiDiskUsage) *system.DockerDiskUsage {
+	var containers, volumes, buildCache, reclaimable int64
+	for _, image := range df.Images {
+		if image.Containers == 0 && image.Size > 0 {
+			reclaimable += image.Size - max(image.SharedSize, 0)
+		}
+	}
+	for _, ctr := range df.Containers {
+		containers += max(ctr.SizeRw, 0)
+	}
+	for _, volume := range df.Volumes {
+		size := max(volume.UsageData.Size, 0)
+		volumes += size
+		if volume.UsageData.RefCount == 0 {
+			reclaimable += size
+		}
+	}
+	for _, cache := range df.BuildCache {
+		if cache.Shared {
+			continue
+		}
+		buildCache += cache.Size
+		if !cache.InUse {
+			reclaimable += cache.Size
+		}
+	}
+	gigabytes := func(b int64) float64 {
+		return dm.precision.gigabytes(metricDisk, uint64(max(b, 0)))
+	}
+	return &system.DockerDiskUsage{
+		Images:      gigabytes(df.LayersSize),
+		Containers:  gigabytes(containers),
+		Volumes:     gigabytes(volumes),
+		BuildCache:  gigabytes(buildCache),
+		Reclaimable: gigabytes(reclaimable),
+	}
+}
+
+// get sends a GET request to the Docker API that is cancelled with ctx
+func (dm *dockerManager) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return dm.client.Do(req)
+}
+
 // Delete container stats from map using mutex
 func (dm *dockerManager) deleteContainerStatsSync(id string) {
 	dm.containerStatsMutex.Lock()
@@ -307,6 +471,8 @@ func newDockerManager(a *Agent) *dockerManager {
 		sem:               make(chan struct{}, 5),
 		apiContainerList:  []*container.ApiInfo{},
 		apiStats:          &container.ApiStats{},
+		precision:         a.precision,
+		diskUsageInterval: a.intervals.get(intervalDockerDisk),
 	}
 
 	// If using podman, return client
@@ -329,6 +495,7 @@ func newDockerManager(a *Agent) *dockerManager {
 	if err := manager.decode(resp, &versionInfo); err != nil {
 		return manager
 	}
+	a.systemInfo.DockerVersion = versionInfo.Version
 
 	// if version > 24, one-shot works correctly and we can limit concurrent operations
 	if dockerVersion, err := semver.Parse(versionInfo.Version); err == nil && dockerVersion.Major > 24 {
@@ -356,14 +523,21 @@ func (dm *dockerManager) decode(resp *http.Response, d any) error {
 	return dm.decoder.Decode(d)
 }
 
+// dockerSockets returns the Docker and Podman socket paths, checked in order
+func dockerSockets() []string {
+	socks := []string{"/var/run/docker.sock", "/run/podman/podman.sock"}
+	// rootless podman
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		socks = append(socks, runtimeDir+"/podman/podman.sock")
+	}
+	return append(socks, fmt.Sprintf("/run/user/%v/podman/podman.sock", os.Getuid()))
+}
+
 // Test docker / podman sockets and return if one exists
 func getDockerHost() string {
 	scheme := "unix://"
-	socks := []string{"/var/run/docker.sock", fmt.Sprintf("/run/user/%v/podman/podman.sock", os.Getuid())}
-	for _, sock := range socks {
-		if _, err := os.Stat(sock); err == nil {
-			return scheme + sock
-		}
+	if sock := findSocket(dockerSockets()); sock != "" {
+		return scheme + sock
 	}
-	return scheme + socks[0]
+	return scheme + dockerSockets()[0]
 }
@@ -0,0 +1,150 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerHealth(t *testing.T) {
+	tests := []struct {
+		state  string
+		status string
+		health string
+	}{
+		{"running", "Up 2 hours (healthy)", container.HealthHealthy},
+		{"running", "Up 3 minutes (unhealthy)", container.HealthUnhealthy},
+		{"running", "Up 5 seconds (health: starting)", container.HealthStarting},
+		{"restarting", "Restarting (1) 5 seconds ago", container.HealthRestarting},
+		{"running", "Up 2 hours", ""},
+	}
+	for _, tt := range tests {
+		ctr := container.ApiInfo{State: tt.state, Status: tt.status}
+		assert.Equal(t, tt.health, ctr.Health(), tt.status)
+	}
+}
+
+func TestContainerProject(t *testing.T) {
+	docker := container.ApiInfo{Labels: map[string]string{"com.docker.compose.project": "blog", "com.docker.compose.service": "web"}}
+	assert.Equal(t, "blog", docker.Project())
+	podman := container.ApiInfo{Labels: map[string]string{"io.podman.compose.project": "media"}}
+	assert.Equal(t, "media", podman.Project())
+	assert.Empty(t, (&container.ApiInfo{}).Project())
+}
+
+func TestGetDockerStatsHealth(t *testing.T) {
+	var mu sync.Mutex
+	inspected := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res any
+		switch path := r.URL.Path; {
+		case path == "/version":
+			res = map[string]string{"Version": "28.0.0"}
+		case path == "/containers/json":
+			res = []map[string]any{
+				{"Id": strings.Repeat("a", 64), "Names": []string{"/web"}, "State": "running", "Status": "Up 3 minutes (unhealthy)",
+					"Labels": map[string]string{"com.docker.compose.project": "blog"}},
+				{"Id": strings.Repeat("b", 64), "Names": []string{"/worker"}, "State": "restarting", "Status": "Restarting (1) 5 seconds ago"},
+			}
+		case strings.HasSuffix(path, "/json"):
+			mu.Lock()
+			inspected[strings.Split(path, "/")[2]]++
+			mu.Unlock()
+			res = map[string]any{"RestartCount": 3}
+		case strings.HasSuffix(path, "/stats"):
+			res = map[string]any{"memory_stats": map[string]any{"usage": 1024 * 1024}}
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+	defer server.Close()
+
+	t.Setenv("BESZEL_AGENT_DOCKER_HOST", strings.Replace(server.URL, "http", "tcp", 1))
+	dm := newDockerManager(&Agent{})
+	require.NotNil(t, dm)
+
+	for range 2 {
+		stats, err := dm.getDockerStats(context.Background())
+		require.NoError(t, err)
+		byName := map[string]*container.Stats{}
+		for _, s := range stats {
+			byName[s.Name] = s
+		}
+		require.Len(t, byName, 2)
+		assert.Equal(t, container.HealthUnhealthy, byName["web"].Health)
+		assert.Equal(t, "blog", byName["web"].Project)
+		assert.Empty(t, byName["worker"].Project)
+		assert.Equal(t, uint32(3), byName["web"].Restarts)
+		assert.Equal(t, 1.0, byName["web"].Mem)
+		assert.Equal(t, container.HealthRestarting, byName["worker"].Health)
+		assert.Equal(t, uint32(3), byName["worker"].Restarts)
+		assert.Zero(t, byName["worker"].Mem)
+	}
+	// running containers are only inspected when first seen
+	assert.Equal(t, 1, inspected[strings.Repeat("a", 12)])
+	assert.Equal(t, 2, inspected[strings.Repeat("b", 12)])
+}
+
+func TestDockerDiskUsage(t *testing.T) {
+	const gb = 1 << 30
+	requests := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res any
+		switch r.URL.Path {
+		case "/version":
+			res = map[string]string{"Version": "28.0.0"}
+		case "/system/df":
+			requests <- struct{}{}
+			res = map[string]any{
+				"LayersSize": 6 * gb,
+				"Images": []map[string]any{
+					{"Size": 3 * gb, "SharedSize": gb, "Containers": 1},
+					{"Size": 3 * gb, "SharedSize": gb, "Containers": 0},
+				},
+				"Containers": []map[string]any{{"SizeRw": gb / 2}, {"SizeRw": -1}},
+				"Volumes": []map[string]any{
+					{"UsageData": map[string]any{"Size": 4 * gb, "RefCount": 1}},
+					{"UsageData": map[string]any{"Size": gb, "RefCount": 0}},
+					{"UsageData": map[string]any{"Size": -1, "RefCount": -1}},
+				},
+				"BuildCache": []map[string]any{
+					{"Size": 8 * gb, "InUse": false, "Shared": false},
+					{"Size": gb, "InUse": true, "Shared": false},
+					{"Size": gb, "InUse": false, "Shared": true},
+				},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+	defer server.Close()
+
+	t.Setenv("BESZEL_AGENT_DOCKER_HOST", strings.Replace(server.URL, "http", "tcp", 1))
+	dm := newDockerManager(&Agent{})
+	require.NotNil(t, dm)
+
+	// the first call starts the request in the background
+	assert.Nil(t, dm.getDiskUsage())
+	<-requests
+	require.Eventually(t, func() bool { return dm.getDiskUsage() != nil }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, &system.DockerDiskUsage{
+		Images:      6,
+		Containers:  0.5,
+		Volumes:     5,
+		BuildCache:  9,
+		Reclaimable: 11,
+	}, dm.getDiskUsage())
+	// and is not repeated until the interval passes
+	assert.Empty(t, requests)
+}
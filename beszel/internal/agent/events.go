@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Max number of events kept until the hub collects them. Older events are
+// dropped when the queue is full.
+const maxQueuedEvents = 500
+
+// Max length of event names and messages
+const (
+	maxEventNameLength    = 64
+	maxEventMessageLength = 256
+)
+
+// eventQueue holds events reported to the event API until they're sent to the
+// hub with the next collection, and the time each event name was last seen
+// for event health checks.
+type eventQueue struct {
+	sync.Mutex
+	events   []system.Event
+	dropped  int                  // events dropped since the queue was last drained
+	lastSeen map[string]time.Time // by event name
+	started  time.Time            // events are considered seen when the agent starts
+}
+
+func newEventQueue(now time.Time) *eventQueue {
+	return &eventQueue{lastSeen: make(map[string]time.Time), started: now}
+}
+
+// add queues an event, dropping the oldest event if the queue is full.
+func (q *eventQueue) add(event system.Event) {
+	q.Lock()
+	defer q.Unlock()
+	if len(q.events) >= maxQueuedEvents {
+		q.events = q.events[1:]
+		q.dropped++
+	}
+	q.events = append(q.events, event)
+	if t := time.UnixMilli(event.Time); t.After(q.lastSeen[event.Name]) {
+		q.lastSeen[event.Name] = t
+	}
+}
+
+// drain returns the queued events and empties the queue.
+func (q *eventQueue) drain() []system.Event {
+	q.Lock()
+	defer q.Unlock()
+	if q.dropped > 0 {
+		slog.Warn("Events dropped before the hub collected them", "count", q.dropped)
+		q.dropped = 0
+	}
+	events := q.events
+	q.events = nil
+	return events
+}
+
+// since returns the time since the event was last seen, or since the agent
+// started if it hasn't been seen yet.
+func (q *eventQueue) since(name string, now time.Time) time.Duration {
+	q.Lock()
+	defer q.Unlock()
+	last, ok := q.lastSeen[name]
+	if !ok {
+		last = q.started
+	}
+	return now.Sub(last)
+}
+
+// getEventsAddress returns the EVENTS_LISTEN address and network. Paths are
+// Unix sockets, and a port without a host binds to localhost, since the API is
+// unauthenticated.
+func getEventsAddress() (addr, network string) {
+	addr, _ = GetEnv("EVENTS_LISTEN")
+	switch {
+	case addr == "":
+		return "", ""
+	case strings.HasPrefix(addr, "/"):
+		return addr, "unix"
+	case !strings.Contains(addr, ":"):
+		addr = "127.0.0.1:" + addr
+	}
+	return addr, "tcp"
+}
+
+// startEventServer serves the event API if EVENTS_LISTEN is set.
+func (a *Agent) startEventServer() error {
+	addr, network := getEventsAddress()
+	if addr == "" || a.events == nil {
+		return nil
+	}
+	if network == "unix" {
+		// remove a socket left by a previous run
+		if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("Starting event API", "addr", ln.Addr().String())
+	go func() {
+		if err := http.Serve(ln, a.eventsHandler()); err != nil {
+			slog.Error("Event API", "err", err)
+		}
+	}()
+	return nil
+}
+
+// eventsHandler returns the handler of the event API. Events are posted to
+// /events as a JSON object, or as query parameters without a body:
+//
+//	curl -X POST http://localhost:45878/events -d '{"name": "backup", "message": "Backup completed", "value": 1284}'
+//	curl -X POST 'http://localhost:45878/events?name=deploy&message=Deploy+started'
+func (a *Agent) eventsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /events", func(w http.ResponseWriter, r *http.Request) {
+		event, err := parseEventRequest(r, a.clock.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.events.add(event)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
+
+// eventRequest is the JSON body of an event posted to the event API
+type eventRequest struct {
+	Name    string    `json:"name"`
+	Message string    `json:"message"`
+	Value   *float64  `json:"value"`
+	Time    time.Time `json:"time"` // RFC 3339, the time received if not set
+}
+
+// parseEventRequest reads an event from the JSON body or the query parameters
+// of a request.
+func parseEventRequest(r *http.Request, now time.Time) (system.Event, error) {
+	var req eventRequest
+	body, err := io.ReadAll(io.LimitReader(r.Body, 4096))
+	if err != nil {
+		return system.Event{}, err
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return system.Event{}, fmt.Errorf("invalid event: %w", err)
+		}
+	} else {
+		query := r.URL.Query()
+		req.Name, req.Message = query.Get("name"), query.Get("message")
+		if value := query.Get("value"); value != "" {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return system.Event{}, fmt.Errorf("invalid value '%s'", value)
+			}
+			req.Value = &number
+		}
+		if t := query.Get("time"); t != "" {
+			if req.Time, err = time.Parse(time.RFC3339, t); err != nil {
+				return system.Event{}, fmt.Errorf("invalid time '%s'", t)
+			}
+		}
+	}
+
+	event := system.Event{
+		Name:    strings.TrimSpace(req.Name),
+		Message: strings.TrimSpace(req.Message),
+		Value:   req.Value,
+		Time:    now.UnixMilli(),
+	}
+	switch {
+	case event.Name == "":
+		return event, errors.New("missing event name")
+	case len(event.Name) > maxEventNameLength:
+		return event, fmt.Errorf("event name longer than %d characters", maxEventNameLength)
+	case len(event.Message) > maxEventMessageLength:
+		return event, fmt.Errorf("event message longer than %d characters", maxEventMessageLength)
+	}
+	// events can't be reported ahead of the agent's clock
+	if !req.Time.IsZero() && req.Time.Before(now) {
+		event.Time = req.Time.UnixMilli()
+	}
+	return event, nil
+}
+
+// isHubSession returns true if stats are collected for the hub, which receives
+// the queued events, rather than for local mode, metrics or OTLP export.
+func isHubSession(sessionID string) bool {
+	switch sessionID {
+	case "", localSessionID, metricsSessionID, otlpSessionID:
+		return false
+	}
+	return true
+}
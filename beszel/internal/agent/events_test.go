@@ -0,0 +1,124 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/clock"
+	"beszel/internal/entities/system"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEventRequest(t *testing.T) {
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	value := 1284.0
+	tests := []struct {
+		name     string
+		target   string
+		body     string
+		expected system.Event
+	}{
+		{
+			name:     "json",
+			target:   "/events",
+			body:     `{"name": " backup ", "message": "Backup completed", "value": 1284}`,
+			expected: system.Event{Name: "backup", Message: "Backup completed", Value: &value, Time: now.UnixMilli()},
+		},
+		{
+			name:     "json with time",
+			target:   "/events",
+			body:     `{"name": "deploy", "time": "2025-01-02T15:00:00Z"}`,
+			expected: system.Event{Name: "deploy", Time: now.Add(-4*time.Minute - 5*time.Second).UnixMilli()},
+		},
+		{
+			name:     "future time",
+			target:   "/events",
+			body:     `{"name": "deploy", "time": "2025-01-03T15:00:00Z"}`,
+			expected: system.Event{Name: "deploy", Time: now.UnixMilli()},
+		},
+		{
+			name:     "query",
+			target:   "/events?name=backup&message=Backup+completed&value=1284",
+			expected: system.Event{Name: "backup", Message: "Backup completed", Value: &value, Time: now.UnixMilli()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			event, err := parseEventRequest(req, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, event)
+		})
+	}
+
+	for _, target := range []string{"/events", "/events?name=x&value=abc", "/events?name=" + strings.Repeat("x", 65)} {
+		_, err := parseEventRequest(httptest.NewRequest(http.MethodPost, target, nil), now)
+		assert.Error(t, err, target)
+	}
+	_, err := parseEventRequest(httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"name":`)), now)
+	assert.Error(t, err)
+}
+
+func TestEventQueue(t *testing.T) {
+	started := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	q := newEventQueue(started)
+	for i := range maxQueuedEvents + 2 {
+		q.add(system.Event{Name: "tick", Time: started.Add(time.Duration(i) * time.Second).UnixMilli()})
+	}
+	events := q.drain()
+	require.Len(t, events, maxQueuedEvents)
+	// oldest events are dropped
+	assert.Equal(t, started.Add(2*time.Second).UnixMilli(), events[0].Time)
+	assert.Empty(t, q.drain())
+
+	now := started.Add(time.Hour)
+	assert.Equal(t, time.Hour-(maxQueuedEvents+1)*time.Second, q.since("tick", now))
+	assert.Equal(t, time.Hour, q.since("other", now))
+}
+
+func TestEventsHandler(t *testing.T) {
+	a := &Agent{clock: clock.New(), events: newEventQueue(time.Now())}
+	handler := a.eventsHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"name": "backup"}`)))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	events := a.events.drain()
+	require.Len(t, events, 1)
+	assert.Equal(t, "backup", events[0].Name)
+
+	assert.True(t, isHubSession("hub-token"))
+	for _, session := range []string{"", localSessionID, metricsSessionID, otlpSessionID} {
+		assert.False(t, isHubSession(session), session)
+	}
+}
+
+func TestGetEventsAddress(t *testing.T) {
+	tests := map[string][2]string{
+		"":                   {"", ""},
+		"45878":              {"127.0.0.1:45878", "tcp"},
+		"0.0.0.0:45878":      {"0.0.0.0:45878", "tcp"},
+		"/run/beszel/events": {"/run/beszel/events", "unix"},
+	}
+	for value, expected := range tests {
+		t.Setenv("BESZEL_AGENT_EVENTS_LISTEN", value)
+		addr, network := getEventsAddress()
+		assert.Equal(t, expected, [2]string{addr, network}, value)
+	}
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"beszel"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// grpcCall sends a protobuf message in a unary gRPC call to url and returns
+// the response message. The client must use an HTTP/2 transport.
+func grpcCall(ctx context.Context, client *http.Client, url string, headers map[string]string, msg []byte) ([]byte, error) {
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("User-Agent", beszel.AppName+"-agent/"+beszel.Version)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	// trailers are only available after reading the body
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New(res.Status)
+	}
+	if err := grpcStatus(res); err != nil {
+		return nil, err
+	}
+	// length-prefixed response message, empty if the body is
+	if len(resBody) < 5 {
+		return nil, nil
+	}
+	if resBody[0] != 0 {
+		return nil, errors.New("compressed grpc response")
+	}
+	length := binary.BigEndian.Uint32(resBody[1:5])
+	if uint32(len(resBody)-5) < length {
+		return nil, errors.New("truncated grpc response")
+	}
+	return resBody[5 : 5+length], nil
+}
+
+// grpcStatus returns an error if the gRPC status of the response is not OK.
+func grpcStatus(res *http.Response) error {
+	status, message := res.Trailer.Get("Grpc-Status"), res.Trailer.Get("Grpc-Message")
+	if status == "" {
+		// trailers-only response
+		status, message = res.Header.Get("Grpc-Status"), res.Header.Get("Grpc-Message")
+	}
+	if status != "0" {
+		message, _ = url.PathUnescape(message)
+		return fmt.Errorf("grpc status %s: %s", status, message)
+	}
+	return nil
+}
+
+// rangeProtoFields calls fn with each varint and length-delimited field of a
+// protobuf message. Fields of other types are skipped.
+func rangeProtoFields(b []byte, fn func(num protowire.Number, varint uint64, value []byte)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, v, nil)
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, 0, v)
+			b = b[n:]
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
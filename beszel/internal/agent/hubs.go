@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// hubConfig is an additional hub in HUBS, which is a JSON array. The agent
+// reports to each over WebSocket, independently of the hub in HUB_URL.
+type hubConfig struct {
+	URL       string       `json:"url" yaml:"url"`
+	Token     string       `json:"token,omitempty" yaml:"token"`
+	TokenFile string       `json:"token_file,omitempty" yaml:"token_file"`
+	Key       string       `json:"key" yaml:"key"`                         // public key of the hub
+	Rules     []metricRule `json:"rules,omitempty" yaml:"rules,omitempty"` // applied only to data sent to the hub
+}
+
+// newHubClients creates a WebSocket client for each hub in HUBS.
+func (a *Agent) newHubClients() ([]*WebSocketClient, error) {
+	value, _ := GetEnv("HUBS")
+	if value == "" {
+		return nil, nil
+	}
+	configs, err := parseHubConfigs(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HUBS: %w", err)
+	}
+	clients := make([]*WebSocketClient, 0, len(configs))
+	for _, config := range configs {
+		client, err := a.newHubClientFromConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HUBS: %s: %w", config.URL, err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// parseHubConfigs parses and validates a JSON array of hubs.
+func parseHubConfigs(value string) ([]hubConfig, error) {
+	var configs []hubConfig
+	if err := json.Unmarshal([]byte(value), &configs); err != nil {
+		return nil, err
+	}
+	for i, config := range configs {
+		if config.URL == "" {
+			return nil, fmt.Errorf("hub %d: url is required", i+1)
+		}
+		if config.Token == "" && config.TokenFile == "" {
+			return nil, fmt.Errorf("hub %d: token or token_file is required", i+1)
+		}
+		if config.Key == "" {
+			return nil, fmt.Errorf("hub %d: key is required", i+1)
+		}
+		for j, rule := range config.Rules {
+			if err := rule.validate(); err != nil {
+				return nil, fmt.Errorf("hub %d: rule %d: %w", i+1, j+1, err)
+			}
+		}
+	}
+	return configs, nil
+}
+
+// newHubClientFromConfig creates the client of an additional hub, with its own
+// token, public key and rules.
+func (a *Agent) newHubClientFromConfig(config hubConfig) (*WebSocketClient, error) {
+	token := config.Token
+	if token == "" {
+		data, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	keys, err := ParseKeys(config.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no valid key")
+	}
+	client, err := newHubClient(a, config.URL, token)
+	if err != nil {
+		return nil, err
+	}
+	client.keys = keys
+	client.rules = config.Rules
+	return client, nil
+}
+
+// startHubClients connects to each additional hub and reconnects when the
+// connection is lost. Unlike the main hub, there's no SSH fallback.
+func (a *Agent) startHubClients() {
+	for _, client := range a.hubClients {
+		events := make(chan ConnectionEvent, 1)
+		client.events = events
+		go a.runHubClient(client, events)
+	}
+}
+
+// closeHubClients closes the connections to the additional hubs.
+func (a *Agent) closeHubClients() {
+	for _, client := range a.hubClients {
+		client.Close()
+	}
+}
+
+// runHubClient keeps the client of an additional hub connected.
+func (a *Agent) runHubClient(client *WebSocketClient, events <-chan ConnectionEvent) {
+	host := client.hubURL.Host
+	connected := false
+	connect := func() {
+		if err := client.Connect(); err != nil {
+			slog.Warn("WebSocket connection failed", "host", host, "err", err)
+		}
+	}
+	connect()
+	ticker := a.clock.NewTicker(wsTickerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-events:
+			switch event {
+			case WebSocketConnect:
+				connected = true
+				slog.Info("WebSocket connected", "host", host)
+			case WebSocketDisconnect:
+				connected = false
+			}
+		case <-ticker.C():
+			if !connected && a.clock.Since(client.lastConnectAttempt) >= wsTickerInterval {
+				connect()
+			}
+		}
+	}
+}
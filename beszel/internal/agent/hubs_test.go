@@ -0,0 +1,117 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParseHubConfigs(t *testing.T) {
+	configs, err := parseHubConfigs(`[
+		{"url": "https://team.example.com", "token": "abc", "key": "ssh-ed25519 AAAA", "rules": [{"metric": "containers", "match": "*", "drop": true}]},
+		{"url": "https://dr.example.com", "token_file": "/run/secrets/token", "key": "ssh-ed25519 BBBB"}
+	]`)
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+	assert.Equal(t, "https://team.example.com", configs[0].URL)
+	assert.Len(t, configs[0].Rules, 1)
+	assert.Equal(t, "/run/secrets/token", configs[1].TokenFile)
+
+	for _, value := range []string{
+		`{"url": "https://team.example.com"}`,
+		`[{"token": "abc", "key": "ssh-ed25519 AAAA"}]`,
+		`[{"url": "https://team.example.com", "key": "ssh-ed25519 AAAA"}]`,
+		`[{"url": "https://team.example.com", "token": "abc"}]`,
+		`[{"url": "https://team.example.com", "token": "abc", "key": "ssh-ed25519 AAAA", "rules": [{"match": "*"}]}]`,
+	} {
+		_, err := parseHubConfigs(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestNewHubClients(t *testing.T) {
+	agent := createTestAgent(t)
+
+	_, privKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	pubKey, err := ssh.NewPublicKey(privKey.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	key := string(ssh.MarshalAuthorizedKey(pubKey))
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+
+	configs := []hubConfig{
+		{URL: "https://team.example.com", Token: "team-token", Key: key},
+		{URL: "http://dr.example.com:8090/base", TokenFile: tokenFile, Key: key},
+	}
+	clients := make([]*WebSocketClient, 0, len(configs))
+	for _, config := range configs {
+		client, err := agent.newHubClientFromConfig(config)
+		require.NoError(t, err)
+		clients = append(clients, client)
+	}
+	assert.Equal(t, "team.example.com", clients[0].hubURL.Host)
+	assert.Equal(t, "team-token", clients[0].token)
+	assert.Equal(t, "dr.example.com:8090", clients[1].hubURL.Host)
+	assert.Equal(t, "file-token", clients[1].token)
+
+	// each client verifies the hub with its own key, not the agent's keys
+	agent.keys = nil
+	signature := ed25519.Sign(privKey, []byte("team-token"))
+	assert.NoError(t, clients[0].verifySignature(signature))
+
+	_, err = agent.newHubClientFromConfig(hubConfig{URL: "https://team.example.com", Token: "abc", Key: "invalid"})
+	assert.Error(t, err)
+	_, err = agent.newHubClientFromConfig(hubConfig{URL: "https://team.example.com", TokenFile: filepath.Join(t.TempDir(), "missing"), Key: key})
+	assert.Error(t, err)
+}
+
+func TestHubClientSystemData(t *testing.T) {
+	agent := createTestAgent(t)
+	rules, err := parseMetricRules(`[{"metric": "network", "match": "veth*", "drop": true}]`)
+	require.NoError(t, err)
+
+	data := &system.CombinedData{
+		Stats: system.Stats{NetworkInterfaces: map[string][2]uint64{"eth0": {1, 2}, "veth1a2b": {3, 4}}},
+	}
+	agent.cache.Set("primary", data)
+
+	team := &WebSocketClient{agent: agent, token: "team", rules: rules}
+	personal := &WebSocketClient{agent: agent, token: "personal"}
+
+	// the rules only apply to the data sent to their hub
+	assert.Equal(t, map[string][2]uint64{"eth0": {1, 2}}, team.systemData().Stats.NetworkInterfaces)
+	assert.Len(t, personal.systemData().Stats.NetworkInterfaces, 2)
+	assert.Len(t, agent.cache.data.Stats.NetworkInterfaces, 2)
+}
+
+func TestHubClientEvents(t *testing.T) {
+	agent := createTestAgent(t)
+	events := make(chan ConnectionEvent, 1)
+	client := &WebSocketClient{agent: agent, events: events}
+
+	client.sendEvent(WebSocketConnect)
+	assert.Equal(t, WebSocketConnect, <-events)
+	// the main connection manager doesn't receive the events of additional hubs
+	assert.Empty(t, agent.connectionManager.eventChan)
+}
+
+func TestParseConfigFileHubs(t *testing.T) {
+	values, err := parseConfigFile([]byte("hubs:\n  - url: https://team.example.com\n    token: abc\n    key: ssh-ed25519 AAAA\n    rules:\n      - metric: containers\n        match: \"*\"\n        drop: true\n"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"url":"https://team.example.com","token":"abc","key":"ssh-ed25519 AAAA","rules":[{"metric":"containers","match":"*","drop":true}]}]`, values["HUBS"])
+
+	configs, err := parseHubConfigs(values["HUBS"])
+	require.NoError(t, err)
+	assert.Len(t, configs, 1)
+}
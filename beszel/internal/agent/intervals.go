@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Subsystems with a configurable collection interval (INTERVALS env var).
+// Other metrics are collected on every hub request.
+const (
+	intervalSmart      = "smart"
+	intervalDockerDisk = "docker_df"
+	intervalRaid       = "raid"
+	intervalServices   = "services"
+)
+
+// Default intervals, used if a subsystem isn't set in INTERVALS. Zero collects
+// on every hub request.
+var defaultIntervals = map[string]time.Duration{
+	intervalSmart:      5 * time.Minute,  // SMART data changes slowly
+	intervalDockerDisk: 10 * time.Minute, // slow with many images or volumes
+	intervalRaid:       0,
+	intervalServices:   0,
+}
+
+// collectionIntervals holds the configured interval of each subsystem.
+// The zero value uses the default intervals.
+type collectionIntervals map[string]time.Duration
+
+// newCollectionIntervals creates collectionIntervals from the INTERVALS env var.
+func newCollectionIntervals() collectionIntervals {
+	value, _ := GetEnv("INTERVALS")
+	return parseCollectionIntervals(value)
+}
+
+// parseCollectionIntervals parses intervals in the format "smart=30m,raid=1m",
+// skipping unknown subsystems and invalid durations.
+func parseCollectionIntervals(value string) collectionIntervals {
+	intervals := make(collectionIntervals)
+	for entry := range strings.SplitSeq(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := defaultIntervals[name]; !ok {
+			slog.Warn("Unknown subsystem in INTERVALS", "subsystem", name)
+			continue
+		}
+		interval, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || interval < 0 {
+			slog.Warn("Invalid INTERVALS", "subsystem", name, "value", value)
+			continue
+		}
+		intervals[name] = interval
+	}
+	if len(intervals) > 0 {
+		slog.Info("Intervals", "intervals", intervals)
+	}
+	return intervals
+}
+
+// get returns the interval of a subsystem, or its default if not configured.
+func (ci collectionIntervals) get(subsystem string) time.Duration {
+	if interval, ok := ci[subsystem]; ok {
+		return interval
+	}
+	return defaultIntervals[subsystem]
+}
+
+// intervalCache keeps the last result of a collector so it can be returned to
+// hub requests until the subsystem's interval has passed.
+type intervalCache[T any] struct {
+	lastRun time.Time
+	value   T
+	err     error
+}
+
+// get returns the cached result if it's newer than the interval, otherwise it
+// runs collect and caches its result.
+func (c *intervalCache[T]) get(interval time.Duration, collect func() (T, error)) (T, error) {
+	if interval > 0 && !c.lastRun.IsZero() && time.Since(c.lastRun) < interval {
+		return c.value, c.err
+	}
+	c.lastRun = time.Now()
+	c.value, c.err = collect()
+	return c.value, c.err
+}
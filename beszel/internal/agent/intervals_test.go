@@ -0,0 +1,74 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCollectionIntervals(t *testing.T) {
+	intervals := parseCollectionIntervals(" SMART=30m, raid=1m, docker_df=-1s, unknown=1m, services=x")
+	assert.Equal(t, collectionIntervals{intervalSmart: 30 * time.Minute, intervalRaid: time.Minute}, intervals)
+
+	assert.Equal(t, 30*time.Minute, intervals.get(intervalSmart))
+	assert.Equal(t, 10*time.Minute, intervals.get(intervalDockerDisk))
+	assert.Zero(t, intervals.get(intervalServices))
+
+	// the zero value uses the defaults
+	assert.Equal(t, 5*time.Minute, collectionIntervals(nil).get(intervalSmart))
+}
+
+func TestIntervalCache(t *testing.T) {
+	var cache intervalCache[int]
+	runs := 0
+	collect := func() (int, error) {
+		runs++
+		return runs, errors.New("failed")
+	}
+
+	// every call collects without an interval
+	value, _ := cache.get(0, collect)
+	assert.Equal(t, 1, value)
+	value, _ = cache.get(0, collect)
+	assert.Equal(t, 2, value)
+
+	// the result and error are cached until the interval passes
+	value, err := cache.get(time.Hour, collect)
+	assert.Equal(t, 2, value)
+	assert.EqualError(t, err, "failed")
+
+	cache.lastRun = time.Now().Add(-time.Hour)
+	value, _ = cache.get(time.Hour, collect)
+	assert.Equal(t, 3, value)
+}
+
+func TestUpdateRaidInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mdstat")
+	require.NoError(t, os.WriteFile(path, []byte(testMdstat), 0o644))
+
+	a := &Agent{
+		raidMonitor: &raidMonitor{mdstatPath: path},
+		intervals:   collectionIntervals{intervalRaid: time.Hour},
+	}
+	var stats system.Stats
+	a.updateRaid(context.Background(), &stats)
+	assert.Equal(t, 2.0, stats.RaidMissing)
+
+	// the last values are reported until the interval passes
+	require.NoError(t, os.Remove(path))
+	stats = system.Stats{}
+	a.updateRaid(context.Background(), &stats)
+	assert.Equal(t, 2.0, stats.RaidMissing)
+	assert.Len(t, a.systemInfo.Raid, 4)
+	assert.Equal(t, system.CollectorOk, a.collectorStatus[collectorRaid].Kind)
+}
@@ -0,0 +1,213 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Service account credentials mounted in pods
+const (
+	kubeServiceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	kubeServiceAccountCA    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// Path of the kubelet summary API
+const kubeletSummaryPath = "/stats/summary"
+
+// podManager collects pod stats from the kubelet summary API when the agent
+// runs as a Kubernetes DaemonSet. Pods are reported to the hub as containers,
+// named namespace/pod.
+//
+// The summary API reports CPU, memory and network usage of pods. Block I/O is
+// not available per pod.
+type podManager struct {
+	client      *http.Client                // HTTPS client for the kubelet
+	url         string                      // URL of the summary API
+	tokenFile   string                      // Service account token, read on each request since it's rotated
+	podStatsMap map[string]*container.Stats // Keeps track of pod stats by pod uid
+	numCPU      float64                     // Number of CPUs, CPU usage is relative to all of them
+	precision   precisionConfig             // Rounding of pod stats
+}
+
+// kubeletSummary is the part of the summary API response used by the agent
+type kubeletSummary struct {
+	Pods []kubeletPodStats `json:"pods"`
+}
+
+type kubeletPodStats struct {
+	PodRef struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		UID       string `json:"uid"`
+	} `json:"podRef"`
+	CPU *struct {
+		Time                 time.Time `json:"time"`
+		UsageNanoCores       *uint64   `json:"usageNanoCores"`
+		UsageCoreNanoSeconds *uint64   `json:"usageCoreNanoSeconds"`
+	} `json:"cpu"`
+	Memory *struct {
+		WorkingSetBytes *uint64 `json:"workingSetBytes"`
+	} `json:"memory"`
+	Network *struct {
+		Time    time.Time `json:"time"`
+		RxBytes *uint64   `json:"rxBytes"`
+		TxBytes *uint64   `json:"txBytes"`
+	} `json:"network"`
+}
+
+// newPodManager returns a kubelet client if KUBELET_URL is set, e.g.
+// https://$(NODE_IP):10250. Returns nil otherwise.
+//
+// Requests are authenticated with the pod's service account token, which needs
+// get access to nodes/stats. The kubelet certificate is verified with the
+// service account CA unless KUBELET_INSECURE is true, since kubelets often use
+// self-signed certificates.
+func newPodManager(a *Agent) *podManager {
+	url, _ := GetEnv("KUBELET_URL")
+	if url == "" {
+		return nil
+	}
+	tokenFile, set := GetEnv("KUBELET_TOKEN_FILE")
+	if !set {
+		tokenFile = kubeServiceAccountToken
+	}
+
+	tlsConfig := &tls.Config{}
+	if insecure, _ := GetEnv("KUBELET_INSECURE"); insecure == "true" {
+		tlsConfig.InsecureSkipVerify = true
+	} else if ca, err := os.ReadFile(kubeServiceAccountCA); err == nil {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+	}
+
+	m := &podManager{
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout:   5 * time.Second,
+		},
+		url:         strings.TrimSuffix(url, "/") + kubeletSummaryPath,
+		tokenFile:   tokenFile,
+		podStatsMap: make(map[string]*container.Stats),
+		numCPU:      float64(runtime.NumCPU()),
+		precision:   a.precision,
+	}
+	slog.Info("Kubelet", "url", m.url)
+	a.systemInfo.ContainerRuntime = "Kubernetes"
+	return m
+}
+
+// getSummary requests the summary API of the kubelet.
+func (m *podManager) getSummary(ctx context.Context) (*kubeletSummary, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if m.tokenFile != "" {
+		token, err := os.ReadFile(m.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	res, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 256))
+		return nil, fmt.Errorf("kubelet returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	var summary kubeletSummary
+	if err := json.NewDecoder(res.Body).Decode(&summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// getPodStats returns stats for all pods on the node
+func (m *podManager) getPodStats(ctx context.Context) ([]*container.Stats, error) {
+	summary, err := m.getSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*container.Stats, 0, len(summary.Pods))
+	pods := make(map[string]struct{}, len(summary.Pods))
+	for _, pod := range summary.Pods {
+		// pods without usage are starting or being removed
+		if pod.CPU == nil || pod.Memory == nil {
+			continue
+		}
+		pods[pod.PodRef.UID] = struct{}{}
+		stats = append(stats, m.updatePodStats(pod))
+	}
+
+	// remove stats of pods that no longer exist
+	for uid := range m.podStatsMap {
+		if _, ok := pods[uid]; !ok {
+			delete(m.podStatsMap, uid)
+		}
+	}
+	return stats, nil
+}
+
+// updatePodStats calculates the CPU and network usage since the previous
+// collection and returns the updated stats of the pod.
+func (m *podManager) updatePodStats(pod kubeletPodStats) *container.Stats {
+	stats, initialized := m.podStatsMap[pod.PodRef.UID]
+	if !initialized {
+		stats = &container.Stats{}
+		m.podStatsMap[pod.PodRef.UID] = stats
+	}
+	stats.Name = pod.PodRef.Namespace + "/" + pod.PodRef.Name
+
+	// cpu usage since the previous collection, or the kubelet's recent usage
+	// on the first collection
+	var cpuPct float64
+	if usage := pod.CPU.UsageCoreNanoSeconds; usage != nil {
+		if initialized && *usage >= stats.CpuContainer && pod.CPU.Time.After(stats.PrevReadTime) {
+			elapsed := float64(pod.CPU.Time.Sub(stats.PrevReadTime).Nanoseconds())
+			cpuPct = float64(*usage-stats.CpuContainer) / elapsed / m.numCPU * 100
+		} else if !initialized && pod.CPU.UsageNanoCores != nil {
+			cpuPct = float64(*pod.CPU.UsageNanoCores) / 1e9 / m.numCPU * 100
+		}
+		stats.CpuContainer = *usage
+	} else if pod.CPU.UsageNanoCores != nil {
+		cpuPct = float64(*pod.CPU.UsageNanoCores) / 1e9 / m.numCPU * 100
+	}
+
+	var workingSet uint64
+	if pod.Memory.WorkingSetBytes != nil {
+		workingSet = *pod.Memory.WorkingSetBytes
+	}
+
+	// network, counters reset when the pod sandbox is recreated
+	var sentPerSecond, recvPerSecond float64
+	if net := pod.Network; net != nil && net.TxBytes != nil && net.RxBytes != nil {
+		elapsed := net.Time.Sub(stats.PrevReadTime).Seconds()
+		if initialized && elapsed > 0 && *net.TxBytes >= stats.PrevNet.Sent && *net.RxBytes >= stats.PrevNet.Recv {
+			sentPerSecond = float64(*net.TxBytes-stats.PrevNet.Sent) / elapsed
+			recvPerSecond = float64(*net.RxBytes-stats.PrevNet.Recv) / elapsed
+		}
+		stats.PrevNet.Sent, stats.PrevNet.Recv = *net.TxBytes, *net.RxBytes
+	}
+	stats.PrevReadTime = pod.CPU.Time
+
+	stats.Cpu = m.precision.round(metricContainers, min(cpuPct, 100))
+	stats.Mem = m.precision.megabytes(metricContainers, float64(workingSet))
+	stats.NetworkSent = m.precision.megabytes(metricContainers, sentPerSecond)
+	stats.NetworkRecv = m.precision.megabytes(metricContainers, recvPerSecond)
+	return stats
+}
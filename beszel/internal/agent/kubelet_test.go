@@ -0,0 +1,91 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func kubeletTestPod(name, uid string, timestamp time.Time, cpu, memory, rx, tx uint64) string {
+	return fmt.Sprintf(`{
+		"podRef": {"name": "%s", "namespace": "default", "uid": "%s"},
+		"cpu": {"time": "%s", "usageNanoCores": 250000000, "usageCoreNanoSeconds": %d},
+		"memory": {"time": "%s", "workingSetBytes": %d},
+		"network": {"time": "%s", "rxBytes": %d, "txBytes": %d}
+	}`, name, uid, timestamp.Format(time.RFC3339), cpu, timestamp.Format(time.RFC3339), memory, timestamp.Format(time.RFC3339), rx, tx)
+}
+
+func TestPodManager(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0600))
+
+	start := time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC)
+	var summary string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != kubeletSummaryPath || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(summary))
+	}))
+	defer server.Close()
+
+	t.Setenv("BESZEL_AGENT_KUBELET_URL", server.URL+"/")
+	t.Setenv("BESZEL_AGENT_KUBELET_TOKEN_FILE", tokenFile)
+	t.Setenv("BESZEL_AGENT_KUBELET_INSECURE", "true")
+	agent := &Agent{}
+	m := newPodManager(agent)
+	require.NotNil(t, m)
+	assert.Equal(t, "Kubernetes", agent.systemInfo.ContainerRuntime)
+	m.numCPU = 2
+
+	// the first collection uses the kubelet's recent cpu usage
+	summary = `{"node": {}, "pods": [` +
+		kubeletTestPod("web-7d9f", "uid-1", start, 10e9, 512*1024*1024, 1000, 2000) + `,
+		{"podRef": {"name": "starting", "namespace": "default", "uid": "uid-2"}}
+	]}`
+	stats, err := m.getPodStats(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "default/web-7d9f", stats[0].Name)
+	assert.Equal(t, 12.5, stats[0].Cpu)
+	assert.Equal(t, 512.0, stats[0].Mem)
+	assert.Zero(t, stats[0].NetworkSent)
+
+	// then the usage since the previous collection
+	summary = `{"pods": [` + kubeletTestPod("web-7d9f", "uid-1", start.Add(10*time.Second), 15e9, 256*1024*1024, 1000+10*1024*1024, 2000+20*1024*1024) + `]}`
+	stats, err = m.getPodStats(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, 25.0, stats[0].Cpu)
+	assert.Equal(t, 256.0, stats[0].Mem)
+	assert.Equal(t, 2.0, stats[0].NetworkSent)
+	assert.Equal(t, 1.0, stats[0].NetworkRecv)
+
+	// removed pods are forgotten
+	summary = `{"pods": []}`
+	stats, err = m.getPodStats(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, stats)
+	assert.Empty(t, m.podStatsMap)
+
+	m.tokenFile = ""
+	_, err = m.getPodStats(context.Background())
+	assert.ErrorContains(t, err, "403")
+}
+
+func TestNewPodManagerDisabled(t *testing.T) {
+	t.Setenv("BESZEL_AGENT_KUBELET_URL", "")
+	assert.Nil(t, newPodManager(&Agent{}))
+}
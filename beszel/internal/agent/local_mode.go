@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"beszel"
+	"beszel/internal/entities/system"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of samples kept for local mode (one hour of one minute samples)
+const localHistorySize = 60
+
+// Session ID used by local mode to collect stats when the hub isn't polling
+const localSessionID = "local"
+
+// localSample is a collected system.Stats with the time it was collected
+type localSample struct {
+	Time  time.Time    `json:"time"`
+	Stats system.Stats `json:"stats"`
+}
+
+// localHistory is a ring buffer of the latest collected stats.
+type localHistory struct {
+	sync.RWMutex
+	samples [localHistorySize]localSample
+	next    int  // index of the next sample to write
+	full    bool // true once the buffer has wrapped
+	info    system.Info
+}
+
+// add records stats collected at t, overwriting the oldest sample if full.
+func (h *localHistory) add(t time.Time, data *system.CombinedData) {
+	h.Lock()
+	defer h.Unlock()
+	h.samples[h.next] = localSample{Time: t, Stats: data.Stats}
+	h.info = data.Info
+	h.next = (h.next + 1) % localHistorySize
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns samples newer than since, oldest first.
+func (h *localHistory) list(since time.Time) []localSample {
+	h.RLock()
+	defer h.RUnlock()
+	samples := make([]localSample, 0, localHistorySize)
+	start, count := 0, h.next
+	if h.full {
+		start, count = h.next, localHistorySize
+	}
+	for i := range count {
+		sample := h.samples[(start+i)%localHistorySize]
+		if sample.Time.After(since) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// last returns the time of the latest sample.
+func (h *localHistory) last() time.Time {
+	h.RLock()
+	defer h.RUnlock()
+	return h.samples[(h.next+localHistorySize-1)%localHistorySize].Time
+}
+
+// getLocalModeAddress returns the LOCAL_LISTEN address. A port without a host
+// binds to localhost, since the page is unauthenticated.
+func getLocalModeAddress() string {
+	addr, _ := GetEnv("LOCAL_LISTEN")
+	if addr != "" && !strings.Contains(addr, ":") {
+		addr = "127.0.0.1:" + addr
+	}
+	return addr
+}
+
+// startLocalMode serves the local web view if LOCAL_LISTEN is set and collects
+// stats in the background if the hub stops requesting them.
+func (a *Agent) startLocalMode() error {
+	addr := getLocalModeAddress()
+	if addr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("Starting local mode", "addr", ln.Addr().String())
+
+	a.localHistory = &localHistory{}
+	go func() {
+		if err := http.Serve(ln, a.localModeHandler()); err != nil {
+			slog.Error("Local mode", "err", err)
+		}
+	}()
+	go a.collectLocalStats()
+	return nil
+}
+
+// collectLocalStats gathers stats if none were collected for longer than the
+// session cache lease, which means the hub is not connected.
+func (a *Agent) collectLocalStats() {
+	ticker := a.clock.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C() {
+		if a.clock.Since(a.localHistory.last()) > a.cache.leaseTime {
+			a.gatherStats(localSessionID)
+		}
+	}
+}
+
+// localModeHandler returns the handler for the local web view and JSON API.
+func (a *Agent) localModeHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/stats", func(w http.ResponseWriter, r *http.Request) {
+		info, samples := a.localModeData()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"info": info, "stats": samples})
+	})
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		info, samples := a.localModeData()
+		// newest first
+		for i, j := 0, len(samples)-1; i < j; i, j = i+1, j-1 {
+			samples[i], samples[j] = samples[j], samples[i]
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := localModeTemplate.Execute(w, map[string]any{
+			"Version": beszel.Version,
+			"Info":    info,
+			"Samples": samples,
+			"Cpu":     localModeChartPoints(samples, func(s *system.Stats) float64 { return s.Cpu }),
+			"Mem":     localModeChartPoints(samples, func(s *system.Stats) float64 { return s.MemPct }),
+		})
+		if err != nil {
+			slog.Error("Local mode", "err", err)
+		}
+	})
+	return mux
+}
+
+// localModeData returns the latest system info and the last hour of samples.
+func (a *Agent) localModeData() (system.Info, []localSample) {
+	samples := a.localHistory.list(a.clock.Now().Add(-time.Hour))
+	a.localHistory.RLock()
+	defer a.localHistory.RUnlock()
+	return a.localHistory.info, samples
+}
+
+// localModeChartPoints returns SVG polyline points for a percentage over the
+// last hour. Samples must be sorted newest first.
+func localModeChartPoints(samples []localSample, value func(*system.Stats) float64) string {
+	if len(samples) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	newest := samples[0].Time
+	for _, sample := range samples {
+		x := 600 - sample.Time.Sub(newest).Abs().Seconds()/6
+		y := 100 - min(max(value(&sample.Stats), 0), 100)
+		fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+var localModeTemplate = template.Must(template.New("local").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Format(time.TimeOnly) },
+	"mb":   func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}).Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>{{.Info.Hostname}} - Beszel Agent</title>
+<style>
+body { font: 14px system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { padding: 0.25em 0.75em; text-align: right; border-bottom: 1px solid #ddd; }
+th:first-child, td:first-child { text-align: left; }
+svg { border: 1px solid #ddd; margin: 1em 0; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>{{.Info.Hostname}}</h1>
+<p class="muted">Beszel agent {{.Version}} local mode · {{.Info.CpuModel}} · {{.Info.Cores}} cores · last hour of stats, refreshed every minute</p>
+{{if .Samples}}
+<svg width="600" height="100" viewBox="0 0 600 100" preserveAspectRatio="none">
+<polyline fill="none" stroke="#2563eb" stroke-width="1.5" points="{{.Cpu}}"/>
+<polyline fill="none" stroke="#16a34a" stroke-width="1.5" points="{{.Mem}}"/>
+</svg>
+<p><span style="color:#2563eb">CPU %</span> · <span style="color:#16a34a">Memory %</span></p>
+<table>
+<tr><th>Time</th><th>CPU</th><th>Memory</th><th>Disk</th><th>Load</th><th>Net sent MB/s</th><th>Net recv MB/s</th></tr>
+{{range .Samples}}<tr><td>{{time .Time}}</td><td>{{.Stats.Cpu}}%</td><td>{{.Stats.MemPct}}%</td><td>{{.Stats.DiskPct}}%</td><td>{{index .Stats.LoadAvg 0}}</td><td>{{mb .Stats.NetworkSent}}</td><td>{{mb .Stats.NetworkRecv}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>No stats collected yet.</p>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,82 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/clock"
+	"beszel/internal/entities/system"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalHistory(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var history localHistory
+	assert.Empty(t, history.list(time.Time{}))
+	assert.True(t, history.last().IsZero())
+
+	for i := range localHistorySize + 5 {
+		history.add(start.Add(time.Duration(i)*time.Minute), &system.CombinedData{Stats: system.Stats{Cpu: float64(i)}})
+	}
+
+	samples := history.list(time.Time{})
+	require.Len(t, samples, localHistorySize, "oldest samples are overwritten")
+	assert.Equal(t, 5.0, samples[0].Stats.Cpu)
+	assert.Equal(t, float64(localHistorySize+4), samples[len(samples)-1].Stats.Cpu)
+	assert.Equal(t, start.Add(time.Duration(localHistorySize+4)*time.Minute), history.last())
+
+	samples = history.list(start.Add(time.Duration(localHistorySize+2) * time.Minute))
+	require.Len(t, samples, 2)
+	assert.Equal(t, float64(localHistorySize+3), samples[0].Stats.Cpu)
+}
+
+func TestGetLocalModeAddress(t *testing.T) {
+	assert.Equal(t, "", getLocalModeAddress())
+	t.Setenv("BESZEL_AGENT_LOCAL_LISTEN", "45877")
+	assert.Equal(t, "127.0.0.1:45877", getLocalModeAddress())
+	t.Setenv("BESZEL_AGENT_LOCAL_LISTEN", "0.0.0.0:45877")
+	assert.Equal(t, "0.0.0.0:45877", getLocalModeAddress())
+}
+
+func TestLocalModeHandler(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := &Agent{clock: clock.NewMock(now), localHistory: &localHistory{}}
+	info := system.Info{Hostname: "test-host", Cores: 4}
+	a.localHistory.add(now.Add(-2*time.Hour), &system.CombinedData{Stats: system.Stats{Cpu: 99}, Info: info})
+	a.localHistory.add(now.Add(-2*time.Minute), &system.CombinedData{Stats: system.Stats{Cpu: 10, MemPct: 20}, Info: info})
+	a.localHistory.add(now.Add(-time.Minute), &system.CombinedData{Stats: system.Stats{Cpu: 30, MemPct: 40}, Info: info})
+	handler := a.localModeHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res struct {
+		Info  system.Info   `json:"info"`
+		Stats []localSample `json:"stats"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "test-host", res.Info.Hostname)
+	require.Len(t, res.Stats, 2, "samples older than an hour are excluded")
+	assert.Equal(t, 10.0, res.Stats[0].Stats.Cpu)
+	assert.Equal(t, 30.0, res.Stats[1].Stats.Cpu)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "<h1>test-host</h1>")
+	assert.Contains(t, body, `points="600.0,70.0 590.0,90.0"`)
+	assert.Contains(t, body, "<td>11:59:00</td><td>30%</td><td>40%</td>")
+	assert.NotContains(t, body, "99%")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
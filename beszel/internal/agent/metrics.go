@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"beszel"
+	"beszel/internal/entities/system"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Session ID used when stats are collected for a Prometheus scrape
+const metricsSessionID = "metrics"
+
+// startMetricsServer serves stats in the Prometheus text format on
+// METRICS_PORT, if set.
+func (a *Agent) startMetricsServer() error {
+	addr, _ := GetEnv("METRICS_PORT")
+	if addr == "" {
+		return nil
+	}
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("Starting metrics server", "addr", ln.Addr().String())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", a.handleMetrics)
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			slog.Error("Metrics server", "err", err)
+		}
+	}()
+	return nil
+}
+
+// handleMetrics writes the latest stats in the Prometheus text format. Stats are
+// shared with the hub through the session cache, so scrapes don't reset deltas.
+func (a *Agent) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	data := a.gatherStats(metricsSessionID)
+	// the cached data is overwritten by the next collection
+	a.Lock()
+	snapshot := *data
+	a.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w, &snapshot)
+}
+
+// metricsWriter writes gauges in the Prometheus text format. Samples of the
+// same metric must be written consecutively.
+type metricsWriter struct {
+	w    io.Writer
+	last string
+}
+
+// gauge writes a sample, preceded by HELP and TYPE lines for a new metric.
+// Labels are given as name, value pairs.
+func (m *metricsWriter) gauge(name, help string, value float64, labels ...string) {
+	name = "beszel_" + name
+	if name != m.last {
+		fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		m.last = name
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i] + `="` + escapeLabelValue(labels[i+1]) + `"`)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	b.WriteByte('\n')
+	io.WriteString(m.w, b.String())
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+// writeMetrics writes host info and all collected stats as Prometheus gauges.
+func writeMetrics(w io.Writer, data *system.CombinedData) {
+	m := &metricsWriter{w: w}
+	info := &data.Info
+
+	m.gauge("info", "Agent and host information", 1,
+		"version", beszel.Version, "hostname", info.Hostname, "kernel", info.KernelVersion, "cpu_model", info.CpuModel)
+	m.gauge("uptime_seconds", "System uptime", float64(info.Uptime))
+	m.gauge("cpu_cores", "Number of CPU cores", float64(info.Cores))
+
+	for _, metric := range data.Stats.Metrics() {
+		m.gauge(metric.Name, metric.Help, metric.Value, metric.Labels...)
+	}
+}
@@ -0,0 +1,65 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	data := &system.CombinedData{
+		Info: system.Info{Hostname: `host "one"`, Cores: 4, Uptime: 3600},
+		Stats: system.Stats{
+			Cpu:          12.5,
+			Mem:          2,
+			MemUsed:      1,
+			MemPct:       50,
+			DiskTotal:    10,
+			DiskUsed:     5,
+			DiskReadPs:   1.5,
+			LoadAvg:      [3]float64{0.5, 0.25, 0.1},
+			Bandwidth:    [2]uint64{1000, 2000},
+			Temperatures: map[string]float64{"nvme": 40, "cpu": 55},
+			GenericSensors: map[string]system.SensorData{
+				"pressure": {Value: 1013, Unit: "hPa"},
+			},
+			ExtraFs: map[string]*system.FsStats{"data": {DiskTotal: 100, DiskUsed: 25}},
+			GPUData: map[string]system.GPUData{"0": {Name: "RTX", Usage: 30, MemoryUsed: 512, Power: 75}},
+		},
+	}
+	var b strings.Builder
+	writeMetrics(&b, data)
+	out := b.String()
+
+	for _, line := range []string{
+		"# HELP beszel_cpu_usage_percent CPU usage\n# TYPE beszel_cpu_usage_percent gauge\nbeszel_cpu_usage_percent 12.5\n",
+		`beszel_info{version="`,
+		`hostname="host \"one\""`,
+		"beszel_uptime_seconds 3600\n",
+		`beszel_load_average{period="5m"} 0.25` + "\n",
+		"beszel_memory_total_bytes 2.147483648e+09\n",
+		"beszel_memory_usage_percent 50\n",
+		`beszel_filesystem_size_bytes{filesystem="root"} 1.073741824e+10` + "\n",
+		`beszel_filesystem_used_bytes{filesystem="data"} 2.68435456e+10` + "\n",
+		`beszel_disk_read_bytes_per_second{filesystem="root"} 1.572864e+06` + "\n",
+		"beszel_network_received_bytes_per_second 2000\n",
+		`beszel_temperature_celsius{sensor="cpu"} 55` + "\n" + `beszel_temperature_celsius{sensor="nvme"} 40` + "\n",
+		`beszel_sensor_value{sensor="pressure",unit="hPa"} 1013` + "\n",
+		`beszel_gpu_usage_percent{gpu="0",name="RTX"} 30` + "\n",
+		`beszel_gpu_memory_used_bytes{gpu="0",name="RTX"} 5.36870912e+08` + "\n",
+		`beszel_gpu_power_watts{gpu="0",name="RTX"} 75` + "\n",
+	} {
+		assert.Contains(t, out, line)
+	}
+	assert.NotContains(t, out, "zfs_arc", "zfs arc is only written if used")
+	assert.Equal(t, 1, strings.Count(out, "# TYPE beszel_temperature_celsius gauge"), "metric declared once")
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	assert.Equal(t, `a\\b\"c\nd`, escapeLabelValue("a\\b\"c\nd"))
+}
@@ -0,0 +1,250 @@
+package agent
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	// How often a revocation list from a URL is fetched again
+	crlRefreshInterval = time.Hour
+	// Max time to complete the handshake and send the stats
+	mtlsConnTimeout = 10 * time.Second
+)
+
+var errCertRevoked = errors.New("certificate is revoked")
+
+// mtlsServer holds the certificates of the TLS server that serves stats to the
+// hub over mTLS instead of SSH. The server certificate and revocation list are
+// reloaded when they change, so they can be rotated without a restart.
+type mtlsServer struct {
+	certFile, keyFile string
+	caCerts           []*x509.Certificate
+	pool              *x509.CertPool
+	crlSource         string // file path or URL, empty if not set
+	client            *http.Client
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	crl         *x509.RevocationList
+	crlModTime  time.Time // modification time of a CRL file
+	crlChecked  time.Time // time a CRL URL was last fetched
+}
+
+// MTLSEnabled reports whether the agent serves stats over mTLS, which is set
+// with TLS_CA. The SSH key is then only used for WebSocket connections.
+func MTLSEnabled() bool {
+	value, _ := GetEnv("TLS_CA")
+	return value != ""
+}
+
+// newMTLSServer creates the mTLS server from TLS_CA, TLS_CERT and TLS_KEY, or
+// returns nil if TLS_CA is not set. TLS_CRL is an optional revocation list,
+// as a file or a URL such as the hub's /api/beszel/mtls/crl.
+func newMTLSServer() (*mtlsServer, error) {
+	caFile, _ := GetEnv("TLS_CA")
+	if caFile == "" {
+		return nil, nil
+	}
+	s := &mtlsServer{pool: x509.NewCertPool(), client: &http.Client{Timeout: 5 * time.Second}}
+	s.certFile, _ = GetEnv("TLS_CERT")
+	s.keyFile, _ = GetEnv("TLS_KEY")
+	s.crlSource, _ = GetEnv("TLS_CRL")
+	if s.certFile == "" || s.keyFile == "" {
+		return nil, errors.New("TLS_CA requires TLS_CERT and TLS_KEY")
+	}
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS_CA: %w", err)
+		}
+		s.caCerts = append(s.caCerts, cert)
+		s.pool.AddCert(cert)
+	}
+	if len(s.caCerts) == 0 {
+		return nil, errors.New("no certificates in TLS_CA")
+	}
+	if _, err := s.certificate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// tlsConfig returns the server config, which requires a client certificate
+// issued by the CA that is not revoked.
+func (s *mtlsServer) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  s.pool,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.certificate()
+		},
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) > 0 && s.isRevoked(state.PeerCertificates[0]) {
+				return errCertRevoked
+			}
+			return nil
+		},
+	}
+}
+
+// certificate returns the server certificate, loading it again if the file changed.
+func (s *mtlsServer) certificate() (*tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.certFile)
+	if err != nil {
+		if s.cert != nil {
+			return s.cert, nil
+		}
+		return nil, err
+	}
+	if s.cert != nil && info.ModTime().Equal(s.certModTime) {
+		return s.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		// the key may not be written yet while rotating, so keep the old pair
+		if s.cert != nil {
+			slog.Warn("Error loading TLS certificate", "err", err)
+			return s.cert, nil
+		}
+		return nil, err
+	}
+	if s.cert != nil {
+		slog.Info("TLS certificate reloaded", "file", s.certFile)
+	}
+	s.cert, s.certModTime = &cert, info.ModTime()
+	return s.cert, nil
+}
+
+// isRevoked reports whether a certificate is in the revocation list.
+func (s *mtlsServer) isRevoked(cert *x509.Certificate) bool {
+	if s.crlSource == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshCRL()
+	return s.crl != nil && slices.ContainsFunc(s.crl.RevokedCertificateEntries, func(entry x509.RevocationListEntry) bool {
+		return entry.SerialNumber.Cmp(cert.SerialNumber) == 0
+	})
+}
+
+// refreshCRL loads the revocation list if the file changed or the URL wasn't
+// fetched recently. The previous list is kept if loading fails.
+func (s *mtlsServer) refreshCRL() {
+	var data []byte
+	var modTime time.Time
+	var err error
+	if strings.HasPrefix(s.crlSource, "http://") || strings.HasPrefix(s.crlSource, "https://") {
+		if time.Since(s.crlChecked) < crlRefreshInterval {
+			return
+		}
+		s.crlChecked = time.Now()
+		data, err = s.fetchCRL()
+	} else {
+		var info os.FileInfo
+		if info, err = os.Stat(s.crlSource); err == nil {
+			if modTime = info.ModTime(); modTime.Equal(s.crlModTime) {
+				return
+			}
+			data, err = os.ReadFile(s.crlSource)
+		}
+	}
+	if err == nil {
+		err = s.setCRL(data)
+	}
+	if err != nil {
+		slog.Warn("Error loading TLS_CRL", "err", err)
+		return
+	}
+	s.crlModTime = modTime
+}
+
+// fetchCRL downloads the revocation list from its URL.
+func (s *mtlsServer) fetchCRL() ([]byte, error) {
+	resp, err := s.client.Get(s.crlSource)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+}
+
+// setCRL parses a PEM or DER revocation list and checks it's signed by the CA.
+func (s *mtlsServer) setCRL(data []byte) error {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return err
+	}
+	for _, ca := range s.caCerts {
+		if crl.CheckSignatureFrom(ca) == nil {
+			s.crl = crl
+			return nil
+		}
+	}
+	return errors.New("revocation list is not signed by TLS_CA")
+}
+
+// serveTLS serves stats over mTLS on the listener until it's closed.
+func (a *Agent) serveTLS(ln net.Listener) error {
+	a.tlsListener = tls.NewListener(ln, a.mtls.tlsConfig())
+	for {
+		conn, err := a.tlsListener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go a.handleTLSConn(conn.(*tls.Conn))
+	}
+}
+
+// handleTLSConn sends the stats as CBOR to a hub that completed the handshake.
+// Hubs are told apart by the serial of their client certificate.
+func (a *Agent) handleTLSConn(conn *tls.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(mtlsConnTimeout))
+	if err := conn.Handshake(); err != nil {
+		slog.Warn("TLS handshake failed", "addr", conn.RemoteAddr(), "err", err)
+		return
+	}
+	a.connectionManager.eventChan <- SSHConnect
+
+	hubCert := conn.ConnectionState().PeerCertificates[0]
+	stats := a.gatherStats(hubCert.SerialNumber.Text(16))
+	if err := cbor.NewEncoder(conn).Encode(stats); err != nil {
+		slog.Error("Error encoding stats", "err", err)
+	}
+}
@@ -0,0 +1,94 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/hub/pki"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupMTLS creates a hub CA and writes an agent certificate issued by it
+func setupMTLS(t *testing.T) (*pki.CA, string) {
+	dir := t.TempDir()
+	ca, err := pki.Load(dir, "", "")
+	require.NoError(t, err)
+	cert, err := ca.IssueAgent("agent", []string{"agent.lan"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "agent.crt"), []byte(cert.Cert), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "agent.key"), []byte(cert.Key), 0o600))
+	t.Setenv("TLS_CA", filepath.Join(dir, "mtls_ca.crt"))
+	t.Setenv("TLS_CERT", filepath.Join(dir, "agent.crt"))
+	t.Setenv("TLS_KEY", filepath.Join(dir, "agent.key"))
+	return ca, dir
+}
+
+// serverHandshake returns the result of the server side of a handshake with the hub
+func serverHandshake(t *testing.T, s *mtlsServer, ca *pki.CA) error {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		_ = tls.Client(clientConn, ca.ClientConfig("agent.lan")).Handshake()
+		clientConn.Close()
+	}()
+	return tls.Server(serverConn, s.tlsConfig()).Handshake()
+}
+
+func TestNewMTLSServer(t *testing.T) {
+	s, err := newMTLSServer()
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	assert.False(t, MTLSEnabled())
+
+	t.Setenv("TLS_CA", "/missing/ca.crt")
+	_, err = newMTLSServer()
+	assert.ErrorContains(t, err, "TLS_CERT")
+
+	ca, _ := setupMTLS(t)
+	s, err = newMTLSServer()
+	require.NoError(t, err)
+	assert.True(t, MTLSEnabled())
+	assert.NoError(t, serverHandshake(t, s, ca))
+}
+
+func TestMTLSServerRevocation(t *testing.T) {
+	ca, dir := setupMTLS(t)
+	t.Setenv("TLS_CRL", filepath.Join(dir, "mtls_crl.pem"))
+	s, err := newMTLSServer()
+	require.NoError(t, err)
+
+	// the list is loaded once it exists
+	client, err := ca.ClientCertificate()
+	require.NoError(t, err)
+	assert.False(t, s.isRevoked(client.Leaf))
+	require.NoError(t, ca.Revoke(client.Leaf.SerialNumber))
+	assert.ErrorContains(t, serverHandshake(t, s, ca), "revoked")
+}
+
+func TestMTLSServerCertificateReload(t *testing.T) {
+	ca, dir := setupMTLS(t)
+	s, err := newMTLSServer()
+	require.NoError(t, err)
+	first, err := s.certificate()
+	require.NoError(t, err)
+
+	// a rotated certificate is loaded when the file changes
+	cert, err := ca.IssueAgent("agent", []string{"agent.lan"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "agent.crt"), []byte(cert.Cert), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "agent.key"), []byte(cert.Key), 0o600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "agent.crt"), later, later))
+	rotated, err := s.certificate()
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Leaf.SerialNumber, rotated.Leaf.SerialNumber)
+	assert.NoError(t, serverHandshake(t, s, ca))
+}
@@ -2,24 +2,65 @@ package agent
 
 import (
 	"log/slog"
+	"path"
 	"strings"
 	"time"
 
 	psutilNet "github.com/shirou/gopsutil/v4/net"
 )
 
+// nicFilter is the list of network interfaces in the NICS env var. Names may
+// contain wildcards, e.g. "eth*". A leading "-" makes it a blacklist of
+// interfaces to exclude from the detected ones, e.g. "-tailscale*,virbr*".
+type nicFilter struct {
+	nics         map[string]struct{}
+	isBlacklist  bool
+	hasWildcards bool
+}
+
+// newNicFilter parses the value of the NICS env var
+func newNicFilter(value string) *nicFilter {
+	filter := &nicFilter{nics: make(map[string]struct{})}
+	if strings.HasPrefix(value, "-") {
+		filter.isBlacklist = true
+		value = value[1:]
+	}
+	for nic := range strings.SplitSeq(value, ",") {
+		if nic = strings.TrimSpace(nic); nic != "" {
+			filter.nics[nic] = struct{}{}
+			filter.hasWildcards = filter.hasWildcards || strings.Contains(nic, "*")
+		}
+	}
+	return filter
+}
+
+// matches reports whether an interface name is in the list
+func (f *nicFilter) matches(name string) bool {
+	if _, exactMatch := f.nics[name]; exactMatch {
+		return true
+	}
+	if !f.hasWildcards {
+		return false
+	}
+	for pattern := range f.nics {
+		if !strings.Contains(pattern, "*") {
+			continue
+		}
+		if match, _ := path.Match(pattern, name); match {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *Agent) initializeNetIoStats() {
 	// reset valid network interfaces
-	a.netInterfaces = make(map[string]struct{}, 0)
+	a.netInterfaces = make(map[string][2]uint64, 0)
 
-	// map of network interface names passed in via NICS env var
-	var nicsMap map[string]struct{}
-	nics, nicsEnvExists := GetEnv("NICS")
-	if nicsEnvExists {
-		nicsMap = make(map[string]struct{}, 0)
-		for nic := range strings.SplitSeq(nics, ",") {
-			nicsMap[nic] = struct{}{}
-		}
+	// network interfaces passed in via NICS env var
+	var filter *nicFilter
+	if nics, exists := GetEnv("NICS"); exists {
+		filter = newNicFilter(nics)
 	}
 
 	// reset network I/O stats
@@ -31,14 +72,15 @@ func (a *Agent) initializeNetIoStats() {
 		a.netIoStats.Time = time.Now()
 		for _, v := range netIO {
 			switch {
-			// skip if nics exists and the interface is not in the list
-			case nicsEnvExists:
-				if _, nameInNics := nicsMap[v.Name]; !nameInNics {
+			// skip if nics is a whitelist and the interface is not in the list
+			case filter != nil && !filter.isBlacklist:
+				if !filter.matches(v.Name) {
 					continue
 				}
 			// otherwise run the interface name through the skipNetworkInterface function
+			// and skip blacklisted interfaces
 			default:
-				if a.skipNetworkInterface(v) {
+				if a.skipNetworkInterface(v) || (filter != nil && filter.matches(v.Name)) {
 					continue
 				}
 			}
@@ -46,7 +88,7 @@ func (a *Agent) initializeNetIoStats() {
 			a.netIoStats.BytesSent += v.BytesSent
 			a.netIoStats.BytesRecv += v.BytesRecv
 			// store as a valid network interface
-			a.netInterfaces[v.Name] = struct{}{}
+			a.netInterfaces[v.Name] = [2]uint64{v.BytesSent, v.BytesRecv}
 		}
 	}
 }
@@ -65,3 +107,11 @@ func (a *Agent) skipNetworkInterface(v psutilNet.IOCountersStat) bool {
 		return false
 	}
 }
+
+// bytesPerSecond returns the rate of a byte counter, or 0 if the counter was reset.
+func bytesPerSecond(prev, cur, msElapsed uint64) uint64 {
+	if msElapsed == 0 || cur < prev {
+		return 0
+	}
+	return (cur - prev) * 1000 / msElapsed
+}
@@ -0,0 +1,57 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNicFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		blacklist bool
+		matches   map[string]bool
+	}{
+		{
+			name:    "exact names",
+			value:   "eth0, wlan0",
+			matches: map[string]bool{"eth0": true, "wlan0": true, "eth1": false},
+		},
+		{
+			name:    "wildcards",
+			value:   "eth*,enp*s0",
+			matches: map[string]bool{"eth0": true, "enp3s0": true, "enp3s1": false, "wlan0": false},
+		},
+		{
+			name:      "blacklist",
+			value:     "-tailscale*,virbr0",
+			blacklist: true,
+			matches:   map[string]bool{"tailscale0": true, "virbr0": true, "virbr1": false, "eth0": false},
+		},
+		{
+			name:    "empty",
+			value:   "",
+			matches: map[string]bool{"eth0": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := newNicFilter(tt.value)
+			assert.Equal(t, tt.blacklist, filter.isBlacklist)
+			for name, want := range tt.matches {
+				assert.Equal(t, want, filter.matches(name), name)
+			}
+		})
+	}
+}
+
+func TestBytesPerSecond(t *testing.T) {
+	assert.Equal(t, uint64(500), bytesPerSecond(1000, 2000, 2000))
+	// counter reset
+	assert.Zero(t, bytesPerSecond(2000, 1000, 2000))
+	assert.Zero(t, bytesPerSecond(1000, 2000, 0))
+}
@@ -0,0 +1,317 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Default port of the NUT upsd daemon
+	nutDefaultPort = "3493"
+	// Timeout for connecting to upsd and for each request
+	nutTimeout = 3 * time.Second
+	// Minimum time between reconnection attempts after a failure
+	nutReconnectDelay = 30 * time.Second
+)
+
+var errNutReconnectWait = errors.New("waiting to reconnect to upsd")
+
+// nutVariable describes how an upsd variable is reported as a generic sensor
+type nutVariable struct {
+	unit    string
+	minimum float64
+	maximum float64
+}
+
+// nutVariables are the upsd variables collected as generic sensors
+var nutVariables = map[string]nutVariable{
+	"battery.charge":  {unit: "%", minimum: 0, maximum: 100},
+	"battery.runtime": {unit: "s", minimum: 0, maximum: 1_000_000},
+	"battery.voltage": {unit: "V", minimum: 0, maximum: 1000},
+	"ups.load":        {unit: "%", minimum: 0, maximum: 200},
+	"ups.realpower":   {unit: "W", minimum: 0, maximum: 100_000},
+	"input.voltage":   {unit: "V", minimum: 0, maximum: 1000},
+	"input.frequency": {unit: "Hz", minimum: 0, maximum: 100},
+	"output.voltage":  {unit: "V", minimum: 0, maximum: 1000},
+}
+
+// nutClient collects UPS variables from a NUT (Network UPS Tools) upsd server.
+// Not thread safe since we only access from gatherStats which is already locked.
+type nutClient struct {
+	addr          string        // upsd address (host:port)
+	upsNames      []string      // UPS names to monitor (all if empty)
+	username      string        // Optional upsd username
+	password      string        // Optional upsd password
+	conn          net.Conn      // Active connection to upsd
+	reader        *bufio.Reader // Reader for conn
+	lastConnError time.Time     // Time of the last failed connection attempt
+}
+
+// newNutClient creates a NUT client if the NUT_HOST env var is set.
+func newNutClient() *nutClient {
+	host, _ := GetEnv("NUT_HOST")
+	if host == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, nutDefaultPort)
+	}
+	client := &nutClient{addr: host}
+	client.username, _ = GetEnv("NUT_USERNAME")
+	client.password, _ = GetEnv("NUT_PASSWORD")
+	if upsNames, _ := GetEnv("NUT_UPS"); upsNames != "" {
+		for name := range strings.SplitSeq(upsNames, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				client.upsNames = append(client.upsNames, name)
+			}
+		}
+	}
+	slog.Info("NUT_HOST", "addr", client.addr, "ups", client.upsNames)
+	return client
+}
+
+// connect opens a connection to upsd and logs in if credentials are set.
+func (c *nutClient) connect(ctx context.Context) error {
+	if c.conn != nil {
+		return nil
+	}
+	if time.Since(c.lastConnError) < nutReconnectDelay {
+		return errNutReconnectWait
+	}
+	dialer := net.Dialer{Timeout: nutTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		c.lastConnError = time.Now()
+		return err
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	if c.username != "" {
+		if err := c.command(ctx, "USERNAME "+c.username); err != nil {
+			c.close()
+			c.lastConnError = time.Now()
+			return err
+		}
+		if err := c.command(ctx, "PASSWORD "+c.password); err != nil {
+			c.close()
+			c.lastConnError = time.Now()
+			return err
+		}
+	}
+	return nil
+}
+
+// close closes the connection to upsd.
+func (c *nutClient) close() {
+	if c.conn != nil {
+		_, _ = c.conn.Write([]byte("LOGOUT\n"))
+		c.conn.Close()
+		c.conn = nil
+		c.reader = nil
+	}
+}
+
+// command sends a command that expects a single OK line in response.
+func (c *nutClient) command(ctx context.Context, cmd string) error {
+	line, err := c.request(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK") {
+		return fmt.Errorf("unexpected response: %s", line)
+	}
+	return nil
+}
+
+// request sends a command and returns the first line of the response.
+// The request times out after nutTimeout or at the ctx deadline, whichever is first.
+func (c *nutClient) request(ctx context.Context, cmd string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	deadline := time.Now().Add(nutTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+	if _, err := c.conn.Write([]byte(cmd + "\n")); err != nil {
+		return "", err
+	}
+	return c.readLine()
+}
+
+// readLine reads a single line from upsd, returning an error for ERR responses.
+func (c *nutClient) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if after, ok := strings.CutPrefix(line, "ERR "); ok {
+		return "", fmt.Errorf("upsd: %s", after)
+	}
+	return line, nil
+}
+
+// list sends a LIST command and returns the fields of each line between BEGIN and END.
+func (c *nutClient) list(ctx context.Context, query string) ([][]string, error) {
+	line, err := c.request(ctx, "LIST "+query)
+	if err != nil {
+		return nil, err
+	}
+	if line != "BEGIN LIST "+query {
+		return nil, fmt.Errorf("unexpected response: %s", line)
+	}
+	var items [][]string
+	for {
+		line, err = c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END LIST "+query {
+			return items, nil
+		}
+		items = append(items, splitNutFields(line))
+	}
+}
+
+// getUpsNames returns the configured UPS names or all UPS names known to upsd.
+func (c *nutClient) getUpsNames(ctx context.Context) ([]string, error) {
+	if len(c.upsNames) > 0 {
+		return c.upsNames, nil
+	}
+	items, err := c.list(ctx, "UPS")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(items))
+	for _, fields := range items {
+		// UPS <upsname> "<description>"
+		if len(fields) >= 2 && fields[0] == "UPS" {
+			names = append(names, fields[1])
+		}
+	}
+	return names, nil
+}
+
+// getVariables returns the known numeric variables of a UPS.
+func (c *nutClient) getVariables(ctx context.Context, upsName string) (map[string]float64, error) {
+	items, err := c.list(ctx, "VAR "+upsName)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]float64, len(nutVariables))
+	for _, fields := range items {
+		// VAR <upsname> <varname> "<value>"
+		if len(fields) < 4 || fields[0] != "VAR" {
+			continue
+		}
+		if _, ok := nutVariables[fields[2]]; !ok {
+			continue
+		}
+		if value, err := strconv.ParseFloat(fields[3], 64); err == nil {
+			vars[fields[2]] = value
+		}
+	}
+	return vars, nil
+}
+
+// collect returns the generic sensor data for all monitored UPS devices.
+// The connection is dropped on error and re-established on a later call.
+func (c *nutClient) collect(ctx context.Context) (map[string]system.SensorData, error) {
+	if err := c.connect(ctx); err != nil {
+		return nil, err
+	}
+	upsNames, err := c.getUpsNames(ctx)
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	sensors := make(map[string]system.SensorData)
+	for _, upsName := range upsNames {
+		vars, err := c.getVariables(ctx, upsName)
+		if err != nil {
+			c.close()
+			return sensors, err
+		}
+		for name, value := range vars {
+			config := nutVariables[name]
+			sensors[nutSensorName(upsName, name)] = system.SensorData{
+				Value: value,
+				Unit:  config.unit,
+				Min:   config.minimum,
+				Max:   config.maximum,
+			}
+		}
+	}
+	return sensors, nil
+}
+
+// nutSensorName returns the generic sensor name for a UPS variable (e.g. ups_battery_charge)
+func nutSensorName(upsName, variable string) string {
+	return upsName + "_" + strings.ReplaceAll(variable, ".", "_")
+}
+
+// splitNutFields splits a upsd response line into fields, respecting double-quoted values.
+func splitNutFields(line string) []string {
+	var fields []string
+	var field strings.Builder
+	inQuotes, escaped, hasField := false, false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\' && inQuotes:
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+			hasField = true
+		case r == ' ' && !inQuotes:
+			if hasField {
+				fields = append(fields, field.String())
+				field.Reset()
+				hasField = false
+			}
+		default:
+			field.WriteRune(r)
+			hasField = true
+		}
+	}
+	if hasField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// updateNutSensors adds UPS variables from NUT to the generic sensors
+func (a *Agent) updateNutSensors(ctx context.Context, systemStats *system.Stats) {
+	if a.nutClient == nil {
+		return
+	}
+	sensors, err := a.nutClient.collect(ctx)
+	a.setCollectorStatus(collectorNut, err)
+	if err != nil && !errors.Is(err, errNutReconnectWait) {
+		slog.Debug("Error collecting NUT data", "addr", a.nutClient.addr, "err", err)
+	}
+	if len(sensors) == 0 {
+		return
+	}
+	if systemStats.GenericSensors == nil {
+		systemStats.GenericSensors = make(map[string]system.SensorData, len(sensors))
+	}
+	for name, data := range sensors {
+		data.Value = a.precision.round(metricSensors, data.Value)
+		systemStats.GenericSensors[name] = data
+	}
+}
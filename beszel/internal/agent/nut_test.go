@@ -0,0 +1,174 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startFakeUpsd starts a minimal upsd server that answers LIST UPS and LIST VAR commands.
+func startFakeUpsd(t *testing.T, vars map[string]string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					switch line := scanner.Text(); line {
+					case "LIST UPS":
+						conn.Write([]byte("BEGIN LIST UPS\nUPS myups \"Test \\\"UPS\\\"\"\nEND LIST UPS\n"))
+					case "LIST VAR myups":
+						var b strings.Builder
+						b.WriteString("BEGIN LIST VAR myups\n")
+						for name, value := range vars {
+							b.WriteString("VAR myups " + name + " \"" + value + "\"\n")
+						}
+						b.WriteString("END LIST VAR myups\n")
+						conn.Write([]byte(b.String()))
+					case "LOGOUT":
+						conn.Write([]byte("OK Goodbye\n"))
+						return
+					default:
+						conn.Write([]byte("ERR UNKNOWN-UPS\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+func TestSplitNutFields(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{`VAR myups battery.charge "100"`, []string{"VAR", "myups", "battery.charge", "100"}},
+		{`UPS myups "Back-UPS \"ES\" 700"`, []string{"UPS", "myups", `Back-UPS "ES" 700`}},
+		{`VAR myups ups.status ""`, []string{"VAR", "myups", "ups.status", ""}},
+		{`  END   LIST UPS `, []string{"END", "LIST", "UPS"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, splitNutFields(tt.line), tt.line)
+	}
+}
+
+func TestNutSensorName(t *testing.T) {
+	assert.Equal(t, "myups_battery_charge", nutSensorName("myups", "battery.charge"))
+	assert.Equal(t, "rack_input_voltage", nutSensorName("rack", "input.voltage"))
+}
+
+func TestNewNutClient(t *testing.T) {
+	t.Setenv("NUT_HOST", "")
+	assert.Nil(t, newNutClient())
+
+	t.Setenv("NUT_HOST", "192.168.1.10")
+	t.Setenv("NUT_UPS", "ups1, ups2")
+	client := newNutClient()
+	require.NotNil(t, client)
+	assert.Equal(t, "192.168.1.10:3493", client.addr)
+	assert.Equal(t, []string{"ups1", "ups2"}, client.upsNames)
+
+	t.Setenv("NUT_HOST", "localhost:4000")
+	t.Setenv("NUT_UPS", "")
+	client = newNutClient()
+	require.NotNil(t, client)
+	assert.Equal(t, "localhost:4000", client.addr)
+	assert.Empty(t, client.upsNames)
+}
+
+func TestNutClientCollect(t *testing.T) {
+	listener := startFakeUpsd(t, map[string]string{
+		"battery.charge": "95",
+		"ups.load":       "23.456",
+		"input.voltage":  "230.1",
+		"ups.status":     "OL",
+		"ups.model":      "Back-UPS",
+	})
+
+	client := &nutClient{addr: listener.Addr().String()}
+	sensors, err := client.collect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, sensors, 3)
+	assert.Equal(t, system.SensorData{Value: 95, Unit: "%", Min: 0, Max: 100}, sensors["myups_battery_charge"])
+	assert.Equal(t, 23.456, sensors["myups_ups_load"].Value)
+	assert.Equal(t, "V", sensors["myups_input_voltage"].Unit)
+
+	// connection is reused
+	conn := client.conn
+	_, err = client.collect(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, conn, client.conn)
+}
+
+func TestNutClientReconnect(t *testing.T) {
+	listener := startFakeUpsd(t, map[string]string{"battery.charge": "50"})
+	addr := listener.Addr().String()
+
+	// unknown ups returns an error and drops the connection
+	client := &nutClient{addr: addr, upsNames: []string{"missing"}}
+	_, err := client.collect(context.Background())
+	assert.ErrorContains(t, err, "UNKNOWN-UPS")
+	assert.Nil(t, client.conn)
+
+	// failed dial waits before reconnecting
+	client = &nutClient{addr: "127.0.0.1:1"}
+	_, err = client.collect(context.Background())
+	assert.Error(t, err)
+	_, err = client.collect(context.Background())
+	assert.ErrorIs(t, err, errNutReconnectWait)
+
+	// reconnects after the delay has passed
+	client.addr = addr
+	client.lastConnError = time.Now().Add(-nutReconnectDelay)
+	sensors, err := client.collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, sensors["myups_battery_charge"].Value)
+}
+
+func TestNutClientContext(t *testing.T) {
+	listener := startFakeUpsd(t, map[string]string{"battery.charge": "80"})
+	client := &nutClient{addr: listener.Addr().String()}
+	require.NoError(t, client.connect(context.Background()))
+
+	// cancelled context stops the collection and drops the connection
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.collect(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, client.conn)
+}
+
+func TestUpdateNutSensors(t *testing.T) {
+	listener := startFakeUpsd(t, map[string]string{"ups.load": "40.126"})
+	a := &Agent{nutClient: &nutClient{addr: listener.Addr().String()}}
+
+	stats := &system.Stats{GenericSensors: map[string]system.SensorData{"pressure": {Value: 1}}}
+	a.updateNutSensors(context.Background(), stats)
+	assert.Len(t, stats.GenericSensors, 2)
+	assert.Equal(t, 40.13, stats.GenericSensors["myups_ups_load"].Value)
+
+	// no client is a no-op
+	a.nutClient = nil
+	stats = &system.Stats{}
+	a.updateNutSensors(context.Background(), stats)
+	assert.Nil(t, stats.GenericSensors)
+}
@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"beszel"
+	"beszel/internal/entities/system"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"iter"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// Session ID used when stats are collected for OpenTelemetry export
+	otlpSessionID = "otlp"
+	// Default time between exports
+	defaultOtlpInterval = time.Minute
+	// gRPC method of the OTLP metrics service
+	otlpExportPath = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+)
+
+// otlpExporter pushes stats to an OpenTelemetry collector over OTLP/gRPC.
+type otlpExporter struct {
+	url        string            // URL of the Export method
+	headers    map[string]string // Extra request headers, e.g. for auth
+	attributes []string          // Resource attribute key, value pairs from OTLP_ATTRIBUTES
+	interval   time.Duration     // Time between exports
+	client     *http.Client
+}
+
+// newOtlpExporter returns an exporter for OTLP_ENDPOINT, or nil if it is not set.
+// The endpoint is host:port, using TLS only if it starts with https://.
+func newOtlpExporter() (*otlpExporter, error) {
+	endpoint, _ := GetEnv("OTLP_ENDPOINT")
+	if endpoint == "" {
+		return nil, nil
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid OTLP_ENDPOINT %q", endpoint)
+	}
+
+	transport := &http2.Transport{}
+	if u.Scheme == "http" {
+		// gRPC without TLS uses HTTP/2 with prior knowledge
+		transport.AllowHTTP = true
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+	e := &otlpExporter{
+		url:      u.Scheme + "://" + u.Host + otlpExportPath,
+		headers:  make(map[string]string),
+		interval: defaultOtlpInterval,
+		client:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+	headers, _ := GetEnv("OTLP_HEADERS")
+	for name, value := range parseKeyValues("OTLP_HEADERS", headers) {
+		e.headers[strings.ToLower(name)] = value
+	}
+	attributes, _ := GetEnv("OTLP_ATTRIBUTES")
+	for key, value := range parseKeyValues("OTLP_ATTRIBUTES", attributes) {
+		e.attributes = append(e.attributes, key, value)
+	}
+	if v, ok := GetEnv("OTLP_INTERVAL"); ok {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("invalid OTLP_INTERVAL %q", v)
+		}
+		e.interval = interval
+	}
+	return e, nil
+}
+
+// parseKeyValues parses a list in the format "key=value,other=value", in order.
+func parseKeyValues(envName, list string) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for entry := range strings.SplitSeq(list, ",") {
+			key, value, ok := strings.Cut(entry, "=")
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if !ok || key == "" {
+				if entry = strings.TrimSpace(entry); entry != "" {
+					slog.Warn("Invalid "+envName+" entry", "entry", entry)
+				}
+				continue
+			}
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// startOtlpExport pushes stats to OTLP_ENDPOINT every OTLP_INTERVAL, if set.
+func (a *Agent) startOtlpExport() error {
+	e, err := newOtlpExporter()
+	if e == nil || err != nil {
+		return err
+	}
+	slog.Info("Exporting metrics to OpenTelemetry collector", "url", e.url, "interval", e.interval)
+	go func() {
+		ticker := a.clock.NewTicker(e.interval)
+		defer ticker.Stop()
+		for range ticker.C() {
+			if err := a.exportOtlp(e); err != nil {
+				slog.Error("OTLP export failed", "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// exportOtlp collects stats and sends them to the collector. Stats are shared
+// with the hub through the session cache, so exports don't reset deltas.
+func (a *Agent) exportOtlp(e *otlpExporter) error {
+	data := a.gatherStats(otlpSessionID)
+	// the cached data is overwritten by the next collection
+	a.Lock()
+	snapshot := *data
+	a.Unlock()
+
+	info := &snapshot.Info
+	resource := append([]string{
+		"service.name", "beszel-agent",
+		"service.version", beszel.Version,
+		"host.name", info.Hostname,
+	}, e.attributes...)
+	metrics := append([]system.Metric{
+		{Name: "uptime_seconds", Help: "System uptime", Value: float64(info.Uptime)},
+		{Name: "cpu_cores", Help: "Number of CPU cores", Value: float64(info.Cores)},
+	}, snapshot.Stats.Metrics()...)
+	return e.export(encodeOtlpMetrics(resource, metrics, a.clock.Now()))
+}
+
+// export sends an ExportMetricsServiceRequest message as a unary gRPC call.
+func (e *otlpExporter) export(msg []byte) error {
+	_, err := grpcCall(context.Background(), e.client, e.url, e.headers, msg)
+	return err
+}
+
+// encodeOtlpMetrics encodes metrics as gauges in an OTLP ExportMetricsServiceRequest
+// protobuf message. Samples of the same metric must be consecutive.
+func encodeOtlpMetrics(resource []string, metrics []system.Metric, t time.Time) []byte {
+	timestamp := uint64(t.UnixNano())
+
+	var resourceb []byte
+	for i := 0; i+1 < len(resource); i += 2 {
+		resourceb = protowire.AppendTag(resourceb, 1, protowire.BytesType)
+		resourceb = protowire.AppendBytes(resourceb, encodeOtlpAttribute(resource[i], resource[i+1]))
+	}
+
+	var scopeb []byte
+	scopeb = protowire.AppendTag(scopeb, 1, protowire.BytesType)
+	scopeb = protowire.AppendString(scopeb, beszel.AppName)
+	scopeb = protowire.AppendTag(scopeb, 2, protowire.BytesType)
+	scopeb = protowire.AppendString(scopeb, beszel.Version)
+
+	var scopeMetricsb []byte
+	scopeMetricsb = protowire.AppendTag(scopeMetricsb, 1, protowire.BytesType)
+	scopeMetricsb = protowire.AppendBytes(scopeMetricsb, scopeb)
+	for i := 0; i < len(metrics); {
+		// gauge with the data points of all consecutive samples of the metric
+		var gaugeb []byte
+		j := i
+		for ; j < len(metrics) && metrics[j].Name == metrics[i].Name; j++ {
+			var pointb []byte
+			for k := 0; k+1 < len(metrics[j].Labels); k += 2 {
+				pointb = protowire.AppendTag(pointb, 7, protowire.BytesType)
+				pointb = protowire.AppendBytes(pointb, encodeOtlpAttribute(metrics[j].Labels[k], metrics[j].Labels[k+1]))
+			}
+			pointb = protowire.AppendTag(pointb, 3, protowire.Fixed64Type)
+			pointb = protowire.AppendFixed64(pointb, timestamp)
+			pointb = protowire.AppendTag(pointb, 4, protowire.Fixed64Type)
+			pointb = protowire.AppendFixed64(pointb, math.Float64bits(metrics[j].Value))
+			gaugeb = protowire.AppendTag(gaugeb, 1, protowire.BytesType)
+			gaugeb = protowire.AppendBytes(gaugeb, pointb)
+		}
+		var metricb []byte
+		metricb = protowire.AppendTag(metricb, 1, protowire.BytesType)
+		metricb = protowire.AppendString(metricb, "beszel_"+metrics[i].Name)
+		metricb = protowire.AppendTag(metricb, 2, protowire.BytesType)
+		metricb = protowire.AppendString(metricb, metrics[i].Help)
+		metricb = protowire.AppendTag(metricb, 5, protowire.BytesType)
+		metricb = protowire.AppendBytes(metricb, gaugeb)
+		scopeMetricsb = protowire.AppendTag(scopeMetricsb, 2, protowire.BytesType)
+		scopeMetricsb = protowire.AppendBytes(scopeMetricsb, metricb)
+		i = j
+	}
+
+	var resourceMetricsb []byte
+	resourceMetricsb = protowire.AppendTag(resourceMetricsb, 1, protowire.BytesType)
+	resourceMetricsb = protowire.AppendBytes(resourceMetricsb, resourceb)
+	resourceMetricsb = protowire.AppendTag(resourceMetricsb, 2, protowire.BytesType)
+	resourceMetricsb = protowire.AppendBytes(resourceMetricsb, scopeMetricsb)
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, resourceMetricsb)
+	return b
+}
+
+// encodeOtlpAttribute encodes a KeyValue message with a string value.
+func encodeOtlpAttribute(key, value string) []byte {
+	var valueb []byte
+	valueb = protowire.AppendTag(valueb, 1, protowire.BytesType)
+	valueb = protowire.AppendString(valueb, value)
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, key)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, valueb)
+	return b
+}
@@ -0,0 +1,135 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoFields returns the length-delimited fields of a message by number.
+func protoFields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	fields := make(map[protowire.Number][][]byte)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		if typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			require.GreaterOrEqual(t, n, 0)
+			fields[num] = append(fields[num], v)
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+	}
+	return fields
+}
+
+func TestEncodeOtlpMetrics(t *testing.T) {
+	metrics := []system.Metric{
+		{Name: "cpu_usage_percent", Help: "CPU usage", Value: 12.5},
+		{Name: "temperature_celsius", Help: "Temperature", Value: 40, Labels: []string{"sensor", "nvme"}},
+		{Name: "temperature_celsius", Help: "Temperature", Value: 55, Labels: []string{"sensor", "cpu"}},
+	}
+	msg := encodeOtlpMetrics([]string{"host.name", "server", "env", "prod"}, metrics, time.Unix(1700000000, 0))
+
+	resourceMetrics := protoFields(t, msg)[1]
+	require.Len(t, resourceMetrics, 1)
+	rm := protoFields(t, resourceMetrics[0])
+
+	attributes := protoFields(t, rm[1][0])[1]
+	require.Len(t, attributes, 2)
+	attribute := protoFields(t, attributes[1])
+	assert.Equal(t, "env", string(attribute[1][0]))
+	assert.Equal(t, "prod", string(protoFields(t, attribute[2][0])[1][0]))
+
+	scopeMetrics := protoFields(t, rm[2][0])
+	assert.Equal(t, "beszel", string(protoFields(t, scopeMetrics[1][0])[1][0]))
+	require.Len(t, scopeMetrics[2], 2, "samples of the same metric are grouped")
+
+	temperature := protoFields(t, scopeMetrics[2][1])
+	assert.Equal(t, "beszel_temperature_celsius", string(temperature[1][0]))
+	assert.Equal(t, "Temperature", string(temperature[2][0]))
+	points := protoFields(t, temperature[5][0])[1]
+	require.Len(t, points, 2)
+	point := protoFields(t, points[1])
+	label := protoFields(t, point[7][0])
+	assert.Equal(t, "sensor", string(label[1][0]))
+	assert.Equal(t, "cpu", string(protoFields(t, label[2][0])[1][0]))
+}
+
+func TestOtlpExport(t *testing.T) {
+	var requests []*http.Request
+	var bodies [][]byte
+	status := "0"
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", status)
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "invalid%20token")
+	}), &http2.Server{}))
+	defer server.Close()
+
+	t.Setenv("BESZEL_AGENT_OTLP_ENDPOINT", server.Listener.Addr().String())
+	t.Setenv("BESZEL_AGENT_OTLP_HEADERS", "Authorization=Bearer secret, invalid")
+	t.Setenv("BESZEL_AGENT_OTLP_ATTRIBUTES", "env=prod")
+	t.Setenv("BESZEL_AGENT_OTLP_INTERVAL", "30s")
+	e, err := newOtlpExporter()
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, 30*time.Second, e.interval)
+	assert.Equal(t, []string{"env", "prod"}, e.attributes)
+
+	msg := encodeOtlpMetrics(nil, []system.Metric{{Name: "cpu_cores", Value: 4}}, time.Now())
+	require.NoError(t, e.export(msg))
+	require.Len(t, requests, 1)
+	assert.Equal(t, otlpExportPath, requests[0].URL.Path)
+	assert.Equal(t, "application/grpc", requests[0].Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", requests[0].Header.Get("Authorization"))
+	// length-prefixed message
+	require.Len(t, bodies[0], 5+len(msg))
+	assert.Equal(t, byte(0), bodies[0][0])
+	assert.Equal(t, uint32(len(msg)), binary.BigEndian.Uint32(bodies[0][1:5]))
+	assert.Equal(t, msg, bodies[0][5:])
+
+	status = "16"
+	assert.EqualError(t, e.export(msg), "grpc status 16: invalid token")
+}
+
+func TestNewOtlpExporterConfig(t *testing.T) {
+	e, err := newOtlpExporter()
+	assert.NoError(t, err)
+	assert.Nil(t, e, "disabled without endpoint")
+
+	t.Setenv("BESZEL_AGENT_OTLP_ENDPOINT", "https://collector:4317")
+	e, err = newOtlpExporter()
+	require.NoError(t, err)
+	assert.Equal(t, "https://collector:4317"+otlpExportPath, e.url)
+	assert.Equal(t, defaultOtlpInterval, e.interval)
+
+	t.Setenv("BESZEL_AGENT_OTLP_INTERVAL", "10ms")
+	_, err = newOtlpExporter()
+	assert.Error(t, err)
+
+	t.Setenv("BESZEL_AGENT_OTLP_ENDPOINT", "ftp://collector")
+	_, err = newOtlpExporter()
+	assert.Error(t, err)
+}
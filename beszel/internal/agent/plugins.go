@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/plugin"
+	"context"
+	"fmt"
+	"iter"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Time a plugin collector has to return its metrics, so a hung plugin doesn't
+// hold up the rest of the stats
+const pluginTimeout = 10 * time.Second
+
+// Prefix of the collector status of each plugin, e.g. plugin:queue
+const collectorPluginPrefix = "plugin:"
+
+// newPlugins returns the collectors registered by a custom build of the agent
+// and the executables in the PLUGINS and EXECD_PLUGINS env vars. Both are comma
+// separated lists of paths or name=path pairs. An execd path can end with
+// @timeout, e.g. queue=/opt/queue@5m, to set how long its last batch is used.
+// Executables named like an earlier collector are skipped, as they would share
+// its sensors and collector status.
+func newPlugins() []plugin.Collector {
+	collectors := plugin.Collectors()
+	names := make(map[string]bool, len(collectors))
+	for _, collector := range collectors {
+		names[collector.Name()] = true
+	}
+	add := func(collector plugin.Collector, path string) {
+		if names[collector.Name()] {
+			slog.Warn("Skipping plugin with duplicate name", "plugin", collector.Name(), "path", path)
+			return
+		}
+		names[collector.Name()] = true
+		collectors = append(collectors, collector)
+	}
+	value, _ := GetEnv("PLUGINS")
+	for name, path := range parsePluginList(value) {
+		add(plugin.NewExec(name, path), path)
+	}
+	value, _ = GetEnv("EXECD_PLUGINS")
+	for name, path := range parsePluginList(value) {
+		var timeout time.Duration
+		if i := strings.LastIndex(path, "@"); i > 0 {
+			if d, err := time.ParseDuration(path[i+1:]); err == nil {
+				path, timeout = path[:i], d
+			}
+		}
+		add(plugin.NewExecd(name, path, timeout), path)
+	}
+	if len(collectors) > 0 {
+		slog.Debug("Plugins", "count", len(collectors))
+	}
+	return collectors
+}
+
+// parsePluginList yields the name and path of each entry of a comma separated
+// list of paths or name=path pairs. The name is empty if not set.
+func parsePluginList(value string) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for entry := range strings.SplitSeq(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			name, path, ok := strings.Cut(entry, "=")
+			if !ok {
+				name, path = "", entry
+			}
+			if !yield(strings.TrimSpace(name), strings.TrimSpace(path)) {
+				return
+			}
+		}
+	}
+}
+
+// updatePlugins adds the metrics of each plugin to the generic sensors.
+func (a *Agent) updatePlugins(ctx context.Context, systemStats *system.Stats) {
+	for _, collector := range a.plugins {
+		name := collector.Name()
+		metrics, err := collectPlugin(ctx, collector)
+		a.setCollectorStatus(collectorPluginPrefix+name, err)
+		if err != nil {
+			slog.Debug("Error collecting plugin metrics", "plugin", name, "err", err)
+			continue
+		}
+		readings := make(map[string]system.SensorData, len(metrics))
+		for metric, value := range metrics {
+			readings[name+"_"+metric] = system.SensorData{Value: value}
+		}
+		a.addSensorReadings(systemStats, readings)
+	}
+}
+
+// collectPlugin runs a collector with a timeout, recovering from panics in
+// third party code. A collector that is still running from a previous
+// collection is skipped rather than started again.
+func collectPlugin(ctx context.Context, collector plugin.Collector) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+	return runWithContext(ctx, collectorPluginPrefix+collector.Name(), func() (metrics map[string]float64, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return collector.Collect(ctx)
+	})
+}
@@ -0,0 +1,96 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/plugin"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCollector is a plugin collector that returns fixed metrics or panics.
+type testCollector struct {
+	name    string
+	metrics map[string]float64
+	err     error
+	panics  bool
+	block   chan struct{} // Collect waits until closed
+}
+
+func (c testCollector) Name() string { return c.name }
+
+func (c testCollector) Collect(ctx context.Context) (map[string]float64, error) {
+	if c.panics {
+		panic("boom")
+	}
+	if c.block != nil {
+		<-c.block
+	}
+	return c.metrics, c.err
+}
+
+func TestUpdatePlugins(t *testing.T) {
+	a := &Agent{precision: newPrecisionConfig()}
+	a.sensorConfig = a.newSensorConfigWithEnv("", "", "", false)
+	a.plugins = []plugin.Collector{
+		testCollector{name: "queue", metrics: map[string]float64{"depth": 12, "lag": 0.456}},
+		testCollector{name: "broken", err: errors.New("unreachable")},
+		testCollector{name: "panics", panics: true},
+	}
+	var stats system.Stats
+	a.updatePlugins(context.Background(), &stats)
+
+	assert.Equal(t, map[string]system.SensorData{
+		"queue_depth": {Value: 12},
+		"queue_lag":   {Value: 0.46},
+	}, stats.GenericSensors)
+	assert.Equal(t, system.CollectorOk, a.collectorStatus["plugin:queue"].Kind)
+	assert.Equal(t, "unreachable", a.collectorStatus["plugin:broken"].Error)
+	assert.Equal(t, "panic: boom", a.collectorStatus["plugin:panics"].Error)
+}
+
+func TestCollectPluginHung(t *testing.T) {
+	hung := testCollector{name: "hung", metrics: map[string]float64{"depth": 1}, block: make(chan struct{})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := collectPlugin(ctx, hung)
+	assert.Equal(t, system.CollectorTimeout, getCollectorErrorKind(err))
+
+	// the hung call isn't started again
+	_, err = collectPlugin(context.Background(), hung)
+	assert.Equal(t, system.CollectorTimeout, getCollectorErrorKind(err))
+	assert.ErrorContains(t, err, "still pending")
+
+	close(hung.block)
+	assert.Eventually(t, func() bool {
+		metrics, err := collectPlugin(context.Background(), hung)
+		return err == nil && metrics["depth"] == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestNewPlugins(t *testing.T) {
+	t.Setenv("PLUGINS", "/opt/plugins/queue.sh, disk=/usr/local/bin/disk-plugin,")
+	t.Setenv("EXECD_PLUGINS", "/opt/plugins/mqtt@5m,gpio=/opt/gpio@bin/reader")
+	collectors := newPlugins()
+	names := make([]string, len(collectors))
+	for i, collector := range collectors {
+		names[i] = collector.Name()
+	}
+	require.Len(t, names, 4)
+	assert.Equal(t, []string{"queue", "disk", "mqtt", "gpio"}, names)
+
+	// duplicate names are skipped
+	t.Setenv("PLUGINS", "/opt/plugins/queue.sh,queue=/opt/other-queue")
+	t.Setenv("EXECD_PLUGINS", "/opt/execd/queue@5m")
+	collectors = newPlugins()
+	require.Len(t, collectors, 1)
+	assert.Equal(t, "queue", collectors[0].Name())
+}
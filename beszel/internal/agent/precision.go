@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Metrics with configurable precision (PRECISION and QUANTIZE env vars)
+const (
+	metricDefault     = "default"
+	metricCpu         = "cpu"
+	metricMemory      = "memory"
+	metricDisk        = "disk"
+	metricDiskIo      = "disk_io"
+	metricNetwork     = "network"
+	metricTemperature = "temperature"
+	metricSensors     = "sensors"
+	metricContainers  = "containers"
+)
+
+var precisionMetrics = map[string]struct{}{
+	metricDefault:     {},
+	metricCpu:         {},
+	metricMemory:      {},
+	metricDisk:        {},
+	metricDiskIo:      {},
+	metricNetwork:     {},
+	metricTemperature: {},
+	metricSensors:     {},
+	metricContainers:  {},
+}
+
+// precision controls how values of a metric are rounded before being sent to the hub
+type precision struct {
+	decimals int     // Number of decimal places
+	step     float64 // Quantization step (e.g. 0.5), zero to disable
+}
+
+// Two decimal places, used when no precision is configured
+var defaultPrecision = precision{decimals: 2}
+
+// round quantizes the value to the step and rounds it to the configured decimals.
+func (p precision) round(value float64) float64 {
+	if p.step > 0 {
+		value = math.Round(value/p.step) * p.step
+	}
+	pow := math.Pow10(p.decimals)
+	return math.Round(value*pow) / pow
+}
+
+// precisionConfig holds the configured decimals and quantization steps by metric.
+// The zero value uses the default precision for all metrics.
+type precisionConfig struct {
+	decimals map[string]int
+	steps    map[string]float64
+}
+
+// newPrecisionConfig creates a precisionConfig from the PRECISION and QUANTIZE env vars.
+func newPrecisionConfig() precisionConfig {
+	precisionEnv, _ := GetEnv("PRECISION")
+	quantizeEnv, _ := GetEnv("QUANTIZE")
+	return newPrecisionConfigWithEnv(precisionEnv, quantizeEnv)
+}
+
+// newPrecisionConfigWithEnv parses precision in the format "sensors=3,network=3"
+// and quantization steps in the format "temperature=0.5". Decimals apply in the
+// units sent to the hub, e.g. MB/s for disk_io and network.
+func newPrecisionConfigWithEnv(precisionEnv, quantizeEnv string) precisionConfig {
+	config := precisionConfig{
+		decimals: make(map[string]int),
+		steps:    make(map[string]float64),
+	}
+	for metric, value := range parseMetricValues("PRECISION", precisionEnv) {
+		decimals, err := strconv.Atoi(value)
+		if err != nil || decimals < 0 || decimals > 10 {
+			slog.Warn("Invalid PRECISION", "metric", metric, "value", value)
+			continue
+		}
+		config.decimals[metric] = decimals
+	}
+	for metric, value := range parseMetricValues("QUANTIZE", quantizeEnv) {
+		step, err := strconv.ParseFloat(value, 64)
+		if err != nil || step < 0 {
+			slog.Warn("Invalid QUANTIZE", "metric", metric, "value", value)
+			continue
+		}
+		config.steps[metric] = step
+	}
+	if len(config.decimals) > 0 || len(config.steps) > 0 {
+		slog.Info("Precision", "decimals", config.decimals, "quantize", config.steps)
+	}
+	return config
+}
+
+// parseMetricValues parses "metric=value" pairs, skipping unknown metrics.
+func parseMetricValues(envName, envVal string) map[string]string {
+	values := make(map[string]string)
+	for entry := range strings.SplitSeq(envVal, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		metric, value, _ := strings.Cut(entry, "=")
+		metric = strings.ToLower(strings.TrimSpace(metric))
+		if _, ok := precisionMetrics[metric]; !ok {
+			slog.Warn("Unknown metric in "+envName, "metric", metric)
+			continue
+		}
+		values[metric] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+// get returns the precision of a metric, falling back to the default metric and then two decimals.
+func (pc precisionConfig) get(metric string) precision {
+	p := defaultPrecision
+	if decimals, ok := pc.decimals[metric]; ok {
+		p.decimals = decimals
+	} else if decimals, ok := pc.decimals[metricDefault]; ok {
+		p.decimals = decimals
+	}
+	if step, ok := pc.steps[metric]; ok {
+		p.step = step
+	} else if step, ok := pc.steps[metricDefault]; ok {
+		p.step = step
+	}
+	return p
+}
+
+// round rounds a value of the given metric with its configured precision.
+func (pc precisionConfig) round(metric string, value float64) float64 {
+	return pc.get(metric).round(value)
+}
+
+// megabytes converts bytes to megabytes rounded with the metric's precision.
+func (pc precisionConfig) megabytes(metric string, b float64) float64 {
+	return pc.round(metric, b/1048576)
+}
+
+// gigabytes converts bytes to gigabytes rounded with the metric's precision.
+func (pc precisionConfig) gigabytes(metric string, b uint64) float64 {
+	return pc.round(metric, float64(b)/1073741824)
+}
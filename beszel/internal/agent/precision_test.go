@@ -0,0 +1,52 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecisionRound(t *testing.T) {
+	tests := []struct {
+		precision precision
+		value     float64
+		expected  float64
+	}{
+		{defaultPrecision, 12.3456, 12.35},
+		{precision{decimals: 3}, 12.3456, 12.346},
+		{precision{decimals: 0}, 12.5, 13},
+		{precision{decimals: 1, step: 0.5}, 42.74, 42.5},
+		{precision{decimals: 1, step: 0.5}, 42.76, 43},
+		{precision{decimals: 0, step: 5}, 1234, 1235},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.precision.round(tt.value), "%+v %v", tt.precision, tt.value)
+	}
+}
+
+func TestNewPrecisionConfigWithEnv(t *testing.T) {
+	config := newPrecisionConfigWithEnv("sensors=3, network=0,Temperature=1,unknown=4,cpu=abc,disk=-1", "temperature=0.5,default=0.1,memory=x")
+
+	assert.Equal(t, map[string]int{metricSensors: 3, metricNetwork: 0, metricTemperature: 1}, config.decimals)
+	assert.Equal(t, map[string]float64{metricTemperature: 0.5, metricDefault: 0.1}, config.steps)
+
+	assert.Equal(t, precision{decimals: 3, step: 0.1}, config.get(metricSensors))
+	assert.Equal(t, precision{decimals: 1, step: 0.5}, config.get(metricTemperature))
+	assert.Equal(t, precision{decimals: 2, step: 0.1}, config.get(metricCpu))
+
+	assert.Equal(t, 12.3, config.round(metricSensors, 12.3456))
+	assert.Equal(t, 45.5, config.round(metricTemperature, 45.6))
+	assert.Equal(t, 2.0, config.megabytes(metricNetwork, 2.4*1048576))
+}
+
+func TestPrecisionConfigZeroValue(t *testing.T) {
+	var config precisionConfig
+	assert.Equal(t, defaultPrecision, config.get(metricDisk))
+	assert.Equal(t, 1.5, config.gigabytes(metricDisk, 1610612736))
+
+	config = newPrecisionConfigWithEnv("default=1", "")
+	assert.Equal(t, 12.3, config.round(metricDiskIo, 12.34))
+}
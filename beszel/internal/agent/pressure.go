@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Directory of the kernel's pressure stall information (PSI)
+const pressurePath = "/proc/pressure"
+
+// pressureMonitor reports the share of time tasks were stalled waiting for CPU,
+// memory and I/O, from /proc/pressure on Linux 4.20+.
+type pressureMonitor struct {
+	path string
+}
+
+// newPressureMonitor creates a pressure monitor if PSI is enabled in the
+// kernel, unless the PSI env var is "false".
+func newPressureMonitor() *pressureMonitor {
+	if enabled, _ := GetEnv("PSI"); enabled == "false" {
+		return nil
+	}
+	// the files exist but can't be read if PSI is disabled with psi=0
+	if _, err := os.ReadFile(filepath.Join(pressurePath, "cpu")); err != nil {
+		return nil
+	}
+	return &pressureMonitor{path: pressurePath}
+}
+
+// collect returns the 60 second averages of the cpu, memory and io pressure.
+func (m *pressureMonitor) collect() (*system.Pressure, error) {
+	var pressure system.Pressure
+	for name, dest := range map[string]*[2]float64{
+		"cpu":    &pressure.Cpu,
+		"memory": &pressure.Memory,
+		"io":     &pressure.Io,
+	} {
+		values, err := readPressureFile(filepath.Join(m.path, name))
+		if err != nil {
+			return nil, err
+		}
+		*dest = values
+	}
+	return &pressure, nil
+}
+
+// readPressureFile returns the avg60 values of the "some" and "full" lines of
+// a pressure file, e.g.
+//
+//	some avg10=1.53 avg60=0.87 avg300=0.24 total=27193835
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readPressureFile(path string) ([2]float64, error) {
+	var values [2]float64
+	file, err := os.Open(path)
+	if err != nil {
+		return values, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		var i int
+		switch fields[0] {
+		case "some":
+			i = 0
+		case "full":
+			i = 1
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			if value, ok := strings.CutPrefix(field, "avg60="); ok {
+				if values[i], err = strconv.ParseFloat(value, 64); err != nil {
+					return values, err
+				}
+			}
+		}
+	}
+	return values, scanner.Err()
+}
+
+// updatePressure adds the pressure stall averages to the system stats
+func (a *Agent) updatePressure(systemStats *system.Stats) {
+	if a.pressureMonitor == nil {
+		return
+	}
+	pressure, err := a.pressureMonitor.collect()
+	a.setCollectorStatus(collectorPressure, err)
+	if err != nil {
+		slog.Debug("Error reading pressure stall information", "err", err)
+		return
+	}
+	systemStats.Pressure = pressure
+}
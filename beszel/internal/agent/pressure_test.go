@@ -0,0 +1,46 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPressureMonitorCollect(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// older kernels don't report full cpu pressure
+		"cpu": "some avg10=1.53 avg60=0.87 avg300=0.24 total=27193835\n",
+		"memory": "some avg10=0.00 avg60=4.20 avg300=1.00 total=1234\n" +
+			"full avg10=0.00 avg60=2.10 avg300=0.50 total=567\n",
+		"io": "some avg10=9.00 avg60=12.34 avg300=3.00 total=99\n" +
+			"full avg10=5.00 avg60=6.78 avg300=1.00 total=44\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	monitor := &pressureMonitor{path: dir}
+	pressure, err := monitor.collect()
+	require.NoError(t, err)
+	assert.Equal(t, &system.Pressure{
+		Cpu:    [2]float64{0.87, 0},
+		Memory: [2]float64{4.2, 2.1},
+		Io:     [2]float64{12.34, 6.78},
+	}, pressure)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "io"), []byte("some avg10=0.00 avg60=bad avg300=0.00 total=0\n"), 0644))
+	_, err = monitor.collect()
+	assert.Error(t, err)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "io")))
+	_, err = monitor.collect()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
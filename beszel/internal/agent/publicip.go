@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Default and min time between public IP lookups
+	defaultPublicIpInterval = 10 * time.Minute
+	minPublicIpInterval     = time.Minute
+	// Timeout for each lookup
+	publicIpTimeout = 10 * time.Second
+)
+
+// Services used to look up the public IP when PUBLIC_IP=true, tried in order
+var defaultPublicIpUrls = []string{
+	"https://api.ipify.org",
+	"https://ifconfig.me/ip",
+	"https://icanhazip.com",
+}
+
+// publicIpMonitor periodically looks up the public IP of the host from
+// services that return it as plain text. Enabled with PUBLIC_IP=true, or a
+// comma separated list of lookup URLs.
+type publicIpMonitor struct {
+	urls     []string
+	interval time.Duration
+	client   *http.Client
+
+	mu         sync.Mutex
+	ip         string
+	err        error
+	lastLookup time.Time
+}
+
+// newPublicIpMonitor creates a public IP monitor if PUBLIC_IP is set.
+func newPublicIpMonitor() (*publicIpMonitor, error) {
+	value, _ := GetEnv("PUBLIC_IP")
+	var urls []string
+	switch value = strings.TrimSpace(value); strings.ToLower(value) {
+	case "", "false", "0":
+		return nil, nil
+	case "true", "1":
+		urls = defaultPublicIpUrls
+	default:
+		for url := range strings.SplitSeq(value, ",") {
+			if url = strings.TrimSpace(url); url == "" {
+				continue
+			}
+			if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+				return nil, fmt.Errorf("invalid PUBLIC_IP url %q", url)
+			}
+			urls = append(urls, url)
+		}
+	}
+	m := &publicIpMonitor{
+		urls:     urls,
+		interval: defaultPublicIpInterval,
+		client:   &http.Client{Timeout: publicIpTimeout},
+	}
+	if v, ok := GetEnv("PUBLIC_IP_INTERVAL"); ok {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < minPublicIpInterval {
+			return nil, fmt.Errorf("invalid PUBLIC_IP_INTERVAL %q", v)
+		}
+		m.interval = interval
+	}
+	return m, nil
+}
+
+// get returns the latest public IP and lookup error, and looks up the IP in
+// the background if the last lookup is older than the interval. Returns an
+// empty IP until the first lookup completes.
+func (m *publicIpMonitor) get() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if time.Since(m.lastLookup) >= m.interval {
+		m.lastLookup = time.Now()
+		go m.update()
+	}
+	return m.ip, m.err
+}
+
+// update looks up the public IP from each url until one succeeds. The previous
+// IP is kept if all lookups fail.
+func (m *publicIpMonitor) update() {
+	var errs []error
+	for _, url := range m.urls {
+		ip, err := m.lookup(url)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.mu.Lock()
+		if m.ip != "" && m.ip != ip {
+			slog.Info("Public IP changed", "old", m.ip, "new", ip)
+		}
+		m.ip, m.err = ip, nil
+		m.mu.Unlock()
+		return
+	}
+	m.mu.Lock()
+	m.err = errors.Join(errs...)
+	m.mu.Unlock()
+}
+
+// lookup requests the public IP from a url that returns it as plain text.
+func (m *publicIpMonitor) lookup(url string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), publicIpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	// an IPv6 address is at most 45 characters
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return "", fmt.Errorf("%s returned an invalid IP", url)
+	}
+	return ip.String(), nil
+}
+
+// updatePublicIp sets the public IP in the system info.
+func (a *Agent) updatePublicIp() {
+	if a.publicIpMonitor == nil {
+		return
+	}
+	ip, err := a.publicIpMonitor.get()
+	a.setCollectorStatus(collectorPublicIp, err)
+	if err != nil {
+		slog.Debug("Error looking up public IP", "err", err)
+	}
+	a.systemInfo.PublicIp = ip
+}
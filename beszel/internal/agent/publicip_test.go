@@ -0,0 +1,110 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPublicIpMonitor(t *testing.T) {
+	t.Setenv("PUBLIC_IP", "")
+	monitor, err := newPublicIpMonitor()
+	require.NoError(t, err)
+	assert.Nil(t, monitor)
+
+	t.Setenv("PUBLIC_IP", "true")
+	monitor, err = newPublicIpMonitor()
+	require.NoError(t, err)
+	require.NotNil(t, monitor)
+	assert.Equal(t, defaultPublicIpUrls, monitor.urls)
+	assert.Equal(t, defaultPublicIpInterval, monitor.interval)
+
+	t.Setenv("PUBLIC_IP", "https://ip.example.com, http://other.example.com/ip,")
+	t.Setenv("PUBLIC_IP_INTERVAL", "5m")
+	monitor, err = newPublicIpMonitor()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://ip.example.com", "http://other.example.com/ip"}, monitor.urls)
+	assert.Equal(t, 5*time.Minute, monitor.interval)
+
+	t.Setenv("PUBLIC_IP_INTERVAL", "10s")
+	_, err = newPublicIpMonitor()
+	assert.ErrorContains(t, err, "invalid PUBLIC_IP_INTERVAL")
+
+	t.Setenv("PUBLIC_IP", "ip.example.com")
+	_, err = newPublicIpMonitor()
+	assert.ErrorContains(t, err, "invalid PUBLIC_IP url")
+}
+
+func TestPublicIpMonitorUpdate(t *testing.T) {
+	ip := "203.0.113.7\n"
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(ip))
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>not an ip</html>"))
+	}))
+	defer bad.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	monitor := &publicIpMonitor{
+		urls:     []string{down.URL, bad.URL, good.URL},
+		interval: time.Hour,
+		client:   &http.Client{Timeout: time.Second},
+	}
+	// falls back to the next url until one succeeds
+	monitor.update()
+	assert.Equal(t, "203.0.113.7", monitor.ip)
+	assert.NoError(t, monitor.err)
+
+	ip = "2001:db8::1"
+	monitor.update()
+	assert.Equal(t, "2001:db8::1", monitor.ip)
+
+	// keeps the previous IP if all lookups fail
+	monitor.urls = []string{down.URL, bad.URL}
+	monitor.update()
+	assert.Equal(t, "2001:db8::1", monitor.ip)
+	assert.ErrorContains(t, monitor.err, "503")
+	assert.ErrorContains(t, monitor.err, "invalid IP")
+}
+
+func TestPublicIpMonitorGet(t *testing.T) {
+	requests := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("198.51.100.1"))
+		requests <- struct{}{}
+	}))
+	defer server.Close()
+
+	monitor := &publicIpMonitor{
+		urls:     []string{server.URL},
+		interval: time.Hour,
+		client:   &http.Client{Timeout: time.Second},
+	}
+	// the first call starts a lookup in the background
+	ip, err := monitor.get()
+	assert.Empty(t, ip)
+	assert.NoError(t, err)
+	select {
+	case <-requests:
+	case <-time.After(time.Second):
+		t.Fatal("lookup not requested")
+	}
+	require.Eventually(t, func() bool {
+		ip, _ := monitor.get()
+		return ip == "198.51.100.1"
+	}, time.Second, 10*time.Millisecond)
+	// no new lookup within the interval
+	assert.Empty(t, requests)
+}
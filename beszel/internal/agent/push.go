@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"beszel/internal/common"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/lxzan/gws"
+)
+
+// Shortest time between pushes, to not flood the hub
+const minPushInterval = 5 * time.Second
+
+// getPushInterval returns PUSH_INTERVAL, or zero if push mode is not enabled.
+func getPushInterval() (time.Duration, error) {
+	v, ok := GetEnv("PUSH_INTERVAL")
+	if !ok || v == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(v)
+	if err != nil || interval < minPushInterval {
+		return 0, fmt.Errorf("invalid PUSH_INTERVAL %q", v)
+	}
+	return interval, nil
+}
+
+// startPushing pushes system data to the hub every push interval until the
+// connection closes. It's started by the first data request of a hub that
+// accepts pushed data, so older hubs are only sent data they request.
+func (client *WebSocketClient) startPushing(chunkSize int) {
+	conn := client.Conn
+	if client.pushInterval <= 0 || conn == nil || client.pushConn == conn {
+		return
+	}
+	client.pushConn = conn
+	slog.Info("Pushing system data", "interval", client.pushInterval)
+	go client.push(conn, chunkSize)
+}
+
+// push sends system data on the connection every push interval.
+func (client *WebSocketClient) push(conn *gws.Conn, chunkSize int) {
+	ticker := client.clock.NewTicker(client.pushInterval)
+	defer ticker.Stop()
+	for range ticker.C() {
+		if err := client.pushSystemData(conn, chunkSize); err != nil {
+			slog.Debug("Stopped pushing system data", "err", err)
+			return
+		}
+	}
+}
+
+// pushSystemData gathers system data and sends it as a push message. Data too
+// large for a single message isn't pushed, since the hub requests it in chunks.
+func (client *WebSocketClient) pushSystemData(conn *gws.Conn, chunkSize int) error {
+	bytes, err := cbor.Marshal(cbor.Tag{Number: common.PushTag, Content: client.systemData()})
+	if err != nil {
+		return err
+	}
+	if chunkSize > 0 && len(bytes) > chunkSize {
+		slog.Debug("Not pushing system data larger than the max message size", "size", len(bytes))
+		return nil
+	}
+	return conn.WriteMessage(gws.OpcodeBinary, bytes)
+}
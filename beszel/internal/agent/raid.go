@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Status of the kernel's software RAID (md) arrays
+	mdstatPath = "/proc/mdstat"
+	// Timeout for each mdadm --detail call
+	mdadmTimeout = 5 * time.Second
+)
+
+var (
+	// "[2/1]" in the status line of an array: members it should have / members in sync
+	mdstatMembersRe = regexp.MustCompile(`\[(\d+)/(\d+)\]`)
+	// "recovery = 12.6%" or "resync=DELAYED" in the progress line of an array
+	mdstatSyncRe = regexp.MustCompile(`\b(resync|recovery|reshape|check|repair)\s*=\s*(?:([\d.]+)%)?`)
+	// "State : clean, degraded" in mdadm --detail output
+	mdadmStateRe = regexp.MustCompile(`(?m)^\s*State\s*:\s*(.+?)\s*$`)
+)
+
+// raidMonitor reports the status of software RAID arrays from /proc/mdstat,
+// with the state from mdadm --detail if mdadm is installed.
+type raidMonitor struct {
+	mdstatPath string
+	mdadmPath  string // empty if mdadm is not installed
+	cache      intervalCache[[]system.RaidArray]
+}
+
+// newRaidMonitor creates a RAID monitor if /proc/mdstat exists, unless the RAID env var is "false".
+func newRaidMonitor() *raidMonitor {
+	if enabled, _ := GetEnv("RAID"); enabled == "false" {
+		return nil
+	}
+	if _, err := os.Stat(mdstatPath); err != nil {
+		return nil
+	}
+	monitor := &raidMonitor{mdstatPath: mdstatPath}
+	monitor.mdadmPath, _ = exec.LookPath("mdadm")
+	return monitor
+}
+
+// collect returns the status of each array.
+func (m *raidMonitor) collect(ctx context.Context) ([]system.RaidArray, error) {
+	data, err := os.ReadFile(m.mdstatPath)
+	if err != nil {
+		return nil, err
+	}
+	arrays := parseMdstat(string(data))
+	if m.mdadmPath == "" {
+		return arrays, nil
+	}
+	for i := range arrays {
+		state, err := m.detailState(ctx, arrays[i].Name)
+		if err != nil {
+			// mdadm needs root, so keep the mdstat state if it fails
+			slog.Debug("mdadm", "array", arrays[i].Name, "err", err)
+			continue
+		}
+		if state != "" {
+			arrays[i].State = state
+		}
+	}
+	return arrays, nil
+}
+
+// detailState returns the state of an array from mdadm --detail.
+func (m *raidMonitor) detailState(ctx context.Context, name string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, mdadmTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, m.mdadmPath, "--detail", "/dev/"+name).Output()
+	if err != nil {
+		return "", err
+	}
+	return parseMdadmState(string(output)), nil
+}
+
+// parseMdadmState returns the state line of mdadm --detail output.
+func parseMdadmState(output string) string {
+	if match := mdadmStateRe.FindStringSubmatch(output); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// parseMdstat parses the arrays of /proc/mdstat, e.g.
+//
+//	md1 : active raid5 sdc1[3] sdb1[1](F) sda1[0]
+//	      2093056 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/1] [U__]
+//	      [==>..................]  recovery = 12.6% (132096/1046528) finish=0.6min speed=22012K/sec
+func parseMdstat(data string) []system.RaidArray {
+	var arrays []system.RaidArray
+	var array *system.RaidArray
+	members := 0
+	// finish sets the member counts of arrays without them in the status line (raid0, linear)
+	finish := func() {
+		if array != nil && array.Devices == 0 && array.State != "inactive" {
+			array.Devices, array.Active = members, members
+		}
+	}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, rest, ok := strings.Cut(line, " : "); ok && strings.HasPrefix(name, "md") && !strings.ContainsAny(name, " \t") {
+			finish()
+			arrays = append(arrays, system.RaidArray{Name: name})
+			array, members = &arrays[len(arrays)-1], 0
+			fields := strings.Fields(rest)
+			if len(fields) > 0 {
+				array.State = fields[0]
+			}
+			for _, field := range fields[min(1, len(fields)):] {
+				switch {
+				case strings.HasPrefix(field, "("):
+					// (read-only), (auto-read-only)
+				case !strings.Contains(field, "["):
+					array.Level = field
+				case strings.HasSuffix(field, "(F)"):
+					array.Failed++
+				case strings.HasSuffix(field, "(S)"), strings.HasSuffix(field, "(J)"), strings.HasSuffix(field, "(R)"):
+					// spares, journals and replacements aren't members
+				default:
+					members++
+				}
+			}
+			continue
+		}
+		if array == nil || !strings.HasPrefix(line, " ") {
+			finish()
+			array = nil
+			continue
+		}
+		if match := mdstatMembersRe.FindStringSubmatch(line); match != nil && array.Devices == 0 {
+			array.Devices, _ = strconv.Atoi(match[1])
+			array.Active, _ = strconv.Atoi(match[2])
+		}
+		if match := mdstatSyncRe.FindStringSubmatch(line); match != nil {
+			array.Sync = match[1]
+			array.SyncPct, _ = strconv.ParseFloat(match[2], 64)
+		}
+	}
+	finish()
+	return arrays
+}
+
+// updateRaid adds the status of software RAID arrays to the system info
+func (a *Agent) updateRaid(ctx context.Context, systemStats *system.Stats) {
+	if a.raidMonitor == nil {
+		return
+	}
+	arrays, err := a.raidMonitor.cache.get(a.intervals.get(intervalRaid), func() ([]system.RaidArray, error) {
+		return a.raidMonitor.collect(ctx)
+	})
+	a.setCollectorStatus(collectorRaid, err)
+	if err != nil {
+		slog.Debug("Error reading RAID status", "err", err)
+	}
+	a.systemInfo.Raid = arrays
+	missing := 0
+	for i := range arrays {
+		missing += arrays[i].Missing()
+	}
+	systemStats.RaidMissing = float64(missing)
+}
@@ -0,0 +1,76 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMdstat = `Personalities : [raid0] [raid1] [raid6] [raid5] [raid4]
+md1 : active raid5 sdc1[3] sdb1[1](F) sda1[0] sdd1[4](S)
+      2093056 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/1] [U__]
+      [==>..................]  recovery = 12.6% (132096/1046528) finish=0.6min speed=22012K/sec
+
+md0 : active raid1 sdb2[1] sda2[0]
+      1046528 blocks super 1.2 [2/2] [UU]
+      	resync=DELAYED
+
+md2 : active raid0 sde[1] sdf[0]
+      2093056 blocks super 1.2 512k chunks
+
+md3 : inactive sdg[0](S)
+      1046528 blocks super 1.2
+
+unused devices: <none>
+`
+
+func TestParseMdstat(t *testing.T) {
+	arrays := parseMdstat(testMdstat)
+	assert.Equal(t, []system.RaidArray{
+		{Name: "md1", Level: "raid5", State: "active", Devices: 3, Active: 1, Failed: 1, Sync: "recovery", SyncPct: 12.6},
+		{Name: "md0", Level: "raid1", State: "active", Devices: 2, Active: 2, Sync: "resync"},
+		{Name: "md2", Level: "raid0", State: "active", Devices: 2, Active: 2},
+		{Name: "md3", State: "inactive"},
+	}, arrays)
+	assert.Equal(t, 2, arrays[0].Missing())
+	assert.Zero(t, arrays[3].Missing())
+
+	assert.Empty(t, parseMdstat("Personalities : \nunused devices: <none>\n"))
+}
+
+func TestParseMdadmState(t *testing.T) {
+	output := `/dev/md1:
+           Version : 1.2
+        Raid Level : raid5
+             State : clean, degraded, recovering 
+    Active Devices : 1
+`
+	assert.Equal(t, "clean, degraded, recovering", parseMdadmState(output))
+	assert.Empty(t, parseMdadmState("nothing"))
+}
+
+func TestUpdateRaid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mdstat")
+	require.NoError(t, os.WriteFile(path, []byte(testMdstat), 0o644))
+
+	a := &Agent{raidMonitor: &raidMonitor{mdstatPath: path}}
+	var stats system.Stats
+	a.updateRaid(context.Background(), &stats)
+	assert.Equal(t, 2.0, stats.RaidMissing)
+	assert.Len(t, a.systemInfo.Raid, 4)
+	assert.Equal(t, system.CollectorOk, a.collectorStatus[collectorRaid].Kind)
+
+	a.raidMonitor.mdstatPath = filepath.Join(t.TempDir(), "missing")
+	a.collectorStatus = nil
+	a.updateRaid(context.Background(), &stats)
+	assert.Zero(t, stats.RaidMissing)
+	assert.Equal(t, system.CollectorUnavailable, a.collectorStatus[collectorRaid].Kind)
+}
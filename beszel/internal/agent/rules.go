@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+)
+
+// GPUs, which don't have a configurable precision
+const metricGpu = "gpu"
+
+// Metrics that rules apply to, by the name of each entry
+var ruleMetrics = []string{metricTemperature, metricSensors, metricNetwork, metricDisk, metricContainers, metricGpu}
+
+// metricRule is a rule in METRIC_RULES, which is a JSON array. It drops,
+// renames or clamps the entries of a metric whose name matches a glob, before
+// they're sent to the hub. Totals such as bandwidth are not affected.
+type metricRule struct {
+	Metric string   `json:"metric,omitempty" yaml:"metric"` // one of ruleMetrics, or empty for all
+	Match  string   `json:"match" yaml:"match"`
+	Drop   bool     `json:"drop,omitempty" yaml:"drop"`
+	Rename string   `json:"rename,omitempty" yaml:"rename"`
+	Min    *float64 `json:"min,omitempty" yaml:"min"` // clamps temperature and sensor values
+	Max    *float64 `json:"max,omitempty" yaml:"max"`
+}
+
+// metricRules are applied in order, each to the name left by the previous rules.
+type metricRules []metricRule
+
+// ruleResult is the outcome of the rules for an entry
+type ruleResult struct {
+	name     string
+	drop     bool
+	min, max *float64
+}
+
+// newMetricRules returns the rules of METRIC_RULES, or nil if not set or invalid.
+func newMetricRules() metricRules {
+	value, _ := GetEnv("METRIC_RULES")
+	if value == "" {
+		return nil
+	}
+	rules, err := parseMetricRules(value)
+	if err != nil {
+		slog.Error("Invalid METRIC_RULES", "err", err)
+		return nil
+	}
+	slog.Info("Metric rules", "rules", len(rules))
+	return rules
+}
+
+// parseMetricRules parses and validates a JSON array of rules.
+func parseMetricRules(value string) (metricRules, error) {
+	var rules metricRules
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+// validate checks that the rule has a valid pattern and an action.
+func (r metricRule) validate() error {
+	if r.Match == "" {
+		return errors.New("match is required")
+	}
+	if _, err := path.Match(r.Match, ""); err != nil {
+		return fmt.Errorf("invalid match %q: %w", r.Match, err)
+	}
+	if r.Metric != "" && !slices.Contains(ruleMetrics, r.Metric) {
+		return fmt.Errorf("unknown metric %q", r.Metric)
+	}
+	if !r.Drop && r.Rename == "" && r.Min == nil && r.Max == nil {
+		return errors.New("drop, rename, min or max is required")
+	}
+	if r.Drop && (r.Rename != "" || r.Min != nil || r.Max != nil) {
+		return errors.New("drop can't be combined with other actions")
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return errors.New("min is greater than max")
+	}
+	return nil
+}
+
+// resolve applies the rules of a metric to an entry name.
+func (rules metricRules) resolve(metric, name string) ruleResult {
+	result := ruleResult{name: name}
+	for _, rule := range rules {
+		if rule.Metric != "" && rule.Metric != metric {
+			continue
+		}
+		if match, _ := path.Match(rule.Match, result.name); !match {
+			continue
+		}
+		if rule.Drop {
+			result.drop = true
+			return result
+		}
+		if rule.Rename != "" {
+			result.name = rule.Rename
+		}
+		if rule.Min != nil {
+			result.min = rule.Min
+		}
+		if rule.Max != nil {
+			result.max = rule.Max
+		}
+	}
+	return result
+}
+
+// clamp limits a value to the min and max of the rules.
+func (r ruleResult) clamp(value float64) float64 {
+	if r.min != nil {
+		value = max(value, *r.min)
+	}
+	if r.max != nil {
+		value = min(value, *r.max)
+	}
+	return value
+}
+
+// apply applies the rules to the entries of the data.
+func (rules metricRules) apply(data *system.CombinedData) {
+	if len(rules) == 0 {
+		return
+	}
+	stats := &data.Stats
+	// categories are keyed by the names of both temperatures and generic sensors
+	if len(stats.SensorCategories) > 0 {
+		categories := make(map[string]string, len(stats.SensorCategories))
+		for name, category := range stats.SensorCategories {
+			metric := metricSensors
+			if _, ok := stats.Temperatures[name]; ok {
+				metric = metricTemperature
+			}
+			if result := rules.resolve(metric, name); !result.drop {
+				categories[result.name] = category
+			}
+		}
+		stats.SensorCategories = categories
+	}
+	stats.Temperatures = applyRules(rules, metricTemperature, stats.Temperatures, func(value float64, result ruleResult) float64 {
+		return result.clamp(value)
+	})
+	stats.GenericSensors = applyRules(rules, metricSensors, stats.GenericSensors, func(sensor system.SensorData, result ruleResult) system.SensorData {
+		// the value of a state sensor is the code of its state
+		if sensor.State == "" {
+			sensor.Value = result.clamp(sensor.Value)
+		}
+		return sensor
+	})
+	stats.NetworkInterfaces = applyRules(rules, metricNetwork, stats.NetworkInterfaces, nil)
+	stats.ExtraFs = applyRules(rules, metricDisk, stats.ExtraFs, nil)
+	stats.GPUData = applyRules(rules, metricGpu, stats.GPUData, nil)
+
+	containers := make([]*container.Stats, 0, len(data.Containers))
+	for _, ctr := range data.Containers {
+		result := rules.resolve(metricContainers, ctr.Name)
+		if result.drop {
+			continue
+		}
+		if result.name != ctr.Name {
+			// the stats are reused by the container manager
+			renamed := *ctr
+			renamed.Name = result.name
+			ctr = &renamed
+		}
+		containers = append(containers, ctr)
+	}
+	if data.Containers != nil {
+		data.Containers = containers
+	}
+}
+
+// applyRules returns a copy of the entries of a metric with the rules applied.
+// update, if not nil, changes the value of an entry that's kept.
+func applyRules[V any](rules metricRules, metric string, entries map[string]V, update func(V, ruleResult) V) map[string]V {
+	if len(entries) == 0 {
+		return entries
+	}
+	result := make(map[string]V, len(entries))
+	for name, value := range entries {
+		r := rules.resolve(metric, name)
+		if r.drop {
+			continue
+		}
+		if update != nil {
+			value = update(value, r)
+		}
+		result[r.name] = value
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricRulesApply(t *testing.T) {
+	rules, err := parseMetricRules(`[
+		{"metric": "network", "match": "veth*", "drop": true},
+		{"match": "k10temp_tctl", "rename": "cpu"},
+		{"metric": "temperature", "match": "cpu", "max": 110},
+		{"metric": "sensors", "match": "*", "min": 0},
+		{"metric": "containers", "match": "buildkit_*", "drop": true},
+		{"metric": "containers", "match": "web-1", "rename": "web"},
+		{"metric": "disk", "match": "scratch", "drop": true}
+	]`)
+	require.NoError(t, err)
+
+	web := &container.Stats{Name: "web-1", Cpu: 1}
+	data := &system.CombinedData{
+		Stats: system.Stats{
+			Temperatures:      map[string]float64{"k10temp_tctl": 255, "nvme_composite": 40},
+			GenericSensors:    map[string]system.SensorData{"flow": {Value: -3, Unit: "L/min"}, "door": {Value: -1, State: "open"}},
+			SensorCategories:  map[string]string{"k10temp_tctl": "cpu", "flow": "custom"},
+			NetworkInterfaces: map[string][2]uint64{"eth0": {1, 2}, "veth1a2b": {3, 4}},
+			ExtraFs:           map[string]*system.FsStats{"data": {DiskTotal: 1}, "scratch": {DiskTotal: 2}},
+		},
+		Containers: []*container.Stats{web, {Name: "buildkit_builder"}, {Name: "db"}},
+	}
+	rules.apply(data)
+
+	stats := data.Stats
+	assert.Equal(t, map[string]float64{"cpu": 110, "nvme_composite": 40}, stats.Temperatures)
+	assert.Equal(t, map[string]system.SensorData{"flow": {Value: 0, Unit: "L/min"}, "door": {Value: -1, State: "open"}}, stats.GenericSensors)
+	assert.Equal(t, map[string]string{"cpu": "cpu", "flow": "custom"}, stats.SensorCategories)
+	assert.Equal(t, map[string][2]uint64{"eth0": {1, 2}}, stats.NetworkInterfaces)
+	assert.Len(t, stats.ExtraFs, 1)
+	assert.Contains(t, stats.ExtraFs, "data")
+	require.Len(t, data.Containers, 2)
+	assert.Equal(t, "web", data.Containers[0].Name)
+	assert.Equal(t, "db", data.Containers[1].Name)
+	// the container manager's stats are not changed
+	assert.Equal(t, "web-1", web.Name)
+}
+
+func TestParseMetricRulesInvalid(t *testing.T) {
+	for _, value := range []string{
+		`{"match": "veth*"}`,
+		`[{"drop": true}]`,
+		`[{"match": "veth*"}]`,
+		`[{"match": "[", "drop": true}]`,
+		`[{"metric": "cpu", "match": "*", "drop": true}]`,
+		`[{"match": "*", "drop": true, "rename": "x"}]`,
+		`[{"match": "*", "min": 10, "max": 5}]`,
+	} {
+		_, err := parseMetricRules(value)
+		assert.Error(t, err, value)
+	}
+	var rules metricRules
+	rules.apply(&system.CombinedData{})
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Default time before an action can run again
+	defaultSensorActionCooldown = 5 * time.Minute
+	// Maximum run time of an action command
+	sensorActionTimeout = time.Minute
+)
+
+// sensorActionConfig is an action in SENSOR_ACTIONS, which is a JSON array.
+// Exactly one of Above or Below must be set.
+type sensorActionConfig struct {
+	Sensor   string   `json:"sensor" yaml:"sensor"`
+	Above    *float64 `json:"above,omitempty" yaml:"above"`
+	Below    *float64 `json:"below,omitempty" yaml:"below"`
+	For      int      `json:"for,omitempty" yaml:"for"`           // consecutive checks beyond the threshold, default 1
+	Cooldown string   `json:"cooldown,omitempty" yaml:"cooldown"` // minimum time between runs, default 5m
+	Command  string   `json:"command" yaml:"command"`
+}
+
+// sensorAction runs a command when a sensor stays beyond a threshold
+type sensorAction struct {
+	threshold sensorThreshold
+	intervals int
+	cooldown  time.Duration
+	command   string
+	count     int       // consecutive checks beyond the threshold
+	lastRun   time.Time // time the command was last started
+	running   atomic.Bool
+}
+
+// sensorActions runs the actions of SENSOR_ACTIONS
+type sensorActions struct {
+	actions []*sensorAction
+	run     func(action *sensorAction, value float64)
+}
+
+// newSensorActions returns the configured actions, or nil if SENSOR_ACTIONS is not set.
+func newSensorActions() (*sensorActions, error) {
+	value, _ := GetEnv("SENSOR_ACTIONS")
+	if value == "" {
+		return nil, nil
+	}
+	var configs []sensorActionConfig
+	if err := json.Unmarshal([]byte(value), &configs); err != nil {
+		return nil, fmt.Errorf("invalid SENSOR_ACTIONS: %w", err)
+	}
+	s := &sensorActions{run: runSensorAction}
+	for i, config := range configs {
+		action, err := config.toAction()
+		if err != nil {
+			return nil, fmt.Errorf("invalid SENSOR_ACTIONS entry %d: %w", i+1, err)
+		}
+		s.actions = append(s.actions, action)
+	}
+	if len(s.actions) == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// toAction validates the config and returns the action.
+func (c sensorActionConfig) toAction() (*sensorAction, error) {
+	action := &sensorAction{
+		threshold: sensorThreshold{sensor: c.Sensor},
+		intervals: max(c.For, 1),
+		cooldown:  defaultSensorActionCooldown,
+		command:   c.Command,
+	}
+	switch {
+	case c.Sensor == "":
+		return nil, errors.New("sensor is required")
+	case c.Command == "":
+		return nil, errors.New("command is required")
+	case (c.Above == nil) == (c.Below == nil):
+		return nil, errors.New("one of above or below is required")
+	case c.Above != nil:
+		action.threshold.above, action.threshold.value = true, *c.Above
+	default:
+		action.threshold.value = *c.Below
+	}
+	if c.Cooldown != "" {
+		cooldown, err := time.ParseDuration(c.Cooldown)
+		if err != nil || cooldown < 0 {
+			return nil, fmt.Errorf("invalid cooldown %q", c.Cooldown)
+		}
+		action.cooldown = cooldown
+	}
+	return action, nil
+}
+
+// check counts the checks each sensor has been beyond its threshold and runs
+// actions that reached their count and are not in cooldown. A missing reading
+// resets the count.
+func (s *sensorActions) check(readings map[string]float64, now time.Time) {
+	for _, action := range s.actions {
+		value, ok := readings[action.threshold.sensor]
+		if !ok || !action.threshold.breached(value) {
+			action.count = 0
+			continue
+		}
+		action.count++
+		if action.count < action.intervals {
+			continue
+		}
+		if !action.lastRun.IsZero() && now.Sub(action.lastRun) < action.cooldown {
+			continue
+		}
+		if !action.running.CompareAndSwap(false, true) {
+			continue
+		}
+		action.count = 0
+		action.lastRun = now
+		slog.Info("Sensor action", "sensor", action.threshold.sensor, "value", value, "command", action.command)
+		go func() {
+			defer action.running.Store(false)
+			s.run(action, value)
+		}()
+	}
+}
+
+// runSensorAction runs the action's command in a shell with the sensor
+// reading in BESZEL_SENSOR* environment variables.
+func runSensorAction(action *sensorAction, value float64) {
+	ctx, cancel := context.WithTimeout(context.Background(), sensorActionTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", action.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", action.command)
+	}
+	cmd.Env = append(os.Environ(),
+		"BESZEL_SENSOR="+action.threshold.sensor,
+		"BESZEL_SENSOR_VALUE="+strconv.FormatFloat(value, 'f', -1, 64),
+		"BESZEL_SENSOR_THRESHOLD="+strconv.FormatFloat(action.threshold.value, 'f', -1, 64),
+		"BESZEL_SENSOR_CONDITION="+action.threshold.condition(),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		slog.Error("Sensor action failed", "sensor", action.threshold.sensor, "err", err, "output", string(output[:min(len(output), 512)]))
+	}
+}
@@ -0,0 +1,103 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSensorActions(t *testing.T) {
+	s, err := newSensorActions()
+	assert.NoError(t, err)
+	assert.Nil(t, s, "disabled without SENSOR_ACTIONS")
+
+	t.Setenv("BESZEL_AGENT_SENSOR_ACTIONS", `[
+		{"sensor":"cpu","above":90,"for":3,"cooldown":"10m","command":"echo hot"},
+		{"sensor":"pressure","below":900,"command":"echo low"}
+	]`)
+	s, err = newSensorActions()
+	require.NoError(t, err)
+	require.Len(t, s.actions, 2)
+	assert.Equal(t, sensorThreshold{sensor: "cpu", above: true, value: 90}, s.actions[0].threshold)
+	assert.Equal(t, 3, s.actions[0].intervals)
+	assert.Equal(t, 10*time.Minute, s.actions[0].cooldown)
+	assert.Equal(t, sensorThreshold{sensor: "pressure", value: 900}, s.actions[1].threshold)
+	assert.Equal(t, 1, s.actions[1].intervals)
+	assert.Equal(t, defaultSensorActionCooldown, s.actions[1].cooldown)
+
+	for _, invalid := range []string{
+		`{"sensor":"cpu"}`,
+		`[{"sensor":"cpu","above":90}]`,
+		`[{"sensor":"cpu","command":"echo"}]`,
+		`[{"sensor":"cpu","above":90,"below":10,"command":"echo"}]`,
+		`[{"above":90,"command":"echo"}]`,
+		`[{"sensor":"cpu","above":90,"command":"echo","cooldown":"soon"}]`,
+	} {
+		t.Setenv("BESZEL_AGENT_SENSOR_ACTIONS", invalid)
+		_, err = newSensorActions()
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSensorActionsCheck(t *testing.T) {
+	var mu sync.Mutex
+	var runs []float64
+	done := make(chan struct{}, 10)
+	action, err := sensorActionConfig{Sensor: "cpu", Above: new(float64), For: 2, Cooldown: "5m", Command: "true"}.toAction()
+	require.NoError(t, err)
+	action.threshold.value = 90
+	s := &sensorActions{
+		actions: []*sensorAction{action},
+		run: func(action *sensorAction, value float64) {
+			mu.Lock()
+			runs = append(runs, value)
+			mu.Unlock()
+			done <- struct{}{}
+		},
+	}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	check := func(value float64, elapsed time.Duration) {
+		s.check(map[string]float64{"cpu": value}, now.Add(elapsed))
+	}
+
+	check(95, 0)
+	check(80, 10*time.Second)
+	check(95, 20*time.Second)
+	assert.Empty(t, runs, "must stay above for 2 checks")
+
+	check(96, 30*time.Second)
+	<-done
+	assert.Equal(t, []float64{96}, runs)
+
+	check(97, 40*time.Second)
+	check(97, 50*time.Second)
+	s.check(map[string]float64{}, now.Add(4*time.Minute))
+	check(97, 5*time.Minute)
+	assert.Len(t, runs, 1, "cooldown and missing readings")
+
+	check(98, 5*time.Minute+30*time.Second)
+	<-done
+	assert.Equal(t, []float64{96, 98}, runs)
+}
+
+func TestRunSensorAction(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	action, err := sensorActionConfig{Sensor: "cpu", Below: new(float64), Command: `echo "$BESZEL_SENSOR $BESZEL_SENSOR_CONDITION $BESZEL_SENSOR_THRESHOLD $BESZEL_SENSOR_VALUE" > ` + out}.toAction()
+	require.NoError(t, err)
+	runSensorAction(action, -1.5)
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "cpu below 0 -1.5\n", string(data))
+}
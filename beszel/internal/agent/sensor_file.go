@@ -0,0 +1,233 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sensorReading is a value read from a generic sensor file
+type sensorReading struct {
+	value     string    // unparsed value
+	json      bool      // value is a JSON number, which always uses a dot decimal separator
+	unit      string    // unit set in a JSON file, used if the sensor has none
+	timestamp time.Time // time of the reading set in a JSON file, zero if not set
+}
+
+// sensorFile is the content of a generic sensor file, in one of the formats:
+//   - a single value, e.g. "23.5 °C"
+//   - a "(name,unit,maximum,minimum)" header line followed by the value
+//   - "key=value" lines, one for each sensor
+//   - a JSON object with a value and optional unit and timestamp:
+//     {"value": 23.5, "unit": "°C", "timestamp": "2025-01-02T15:04:05Z"}
+//   - a JSON object of values by key, with an optional timestamp for all of them:
+//     {"temp": 21.5, "humidity": {"value": 45.2, "unit": "%"}, "timestamp": 1735830245}
+type sensorFile struct {
+	header string                   // "(name,unit,maximum,minimum)" definition, if any
+	value  sensorReading            // value of a single-value file
+	values map[string]sensorReading // values of a multi-value file by key, nil for single-value files
+	keys   []string                 // keys of a multi-value file, in file order for "key=value" lines
+	json   bool                     // file is a JSON object
+}
+
+// readSensorFile reads and parses a generic sensor file.
+func readSensorFile(filePath string) (sensorFile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return sensorFile{}, fmt.Errorf("failed to read sensor file %s: %w", filePath, err)
+	}
+	file, err := parseSensorFile(data)
+	if err != nil {
+		return file, fmt.Errorf("failed to parse sensor file %s: %w", filePath, err)
+	}
+	return file, nil
+}
+
+// parseSensorFile detects the format of a generic sensor file and parses it.
+func parseSensorFile(data []byte) (sensorFile, error) {
+	content := strings.TrimSpace(string(data))
+	var file sensorFile
+	if header, rest, _ := strings.Cut(content, "\n"); isGenericSensorHeader(strings.TrimSpace(header)) {
+		file.header = strings.TrimSpace(header)
+		content = strings.TrimSpace(rest)
+	}
+	switch {
+	case strings.HasPrefix(content, "{"):
+		file.json = true
+		return file, parseJSONSensorFile(&file, []byte(content))
+	case file.header == "":
+		if values, keys, ok := parseKeyValueLines(content); ok {
+			file.values, file.keys = values, keys
+			return file, nil
+		}
+	}
+	file.value = sensorReading{value: content}
+	return file, nil
+}
+
+// parseKeyValueLines parses "key=value" lines, ignoring blank lines and lines
+// starting with #. Returns false if any other line is found.
+func parseKeyValueLines(content string) (map[string]sensorReading, []string, bool) {
+	values := make(map[string]sensorReading)
+	var keys []string
+	for line := range strings.Lines(content) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if key = strings.TrimSpace(key); !found || key == "" {
+			return nil, nil, false
+		}
+		if _, exists := values[key]; !exists {
+			keys = append(keys, key)
+		}
+		values[key] = sensorReading{value: strings.TrimSpace(value)}
+	}
+	return values, keys, len(keys) > 0
+}
+
+// parseJSONSensorFile parses a JSON object with a single value, or values by key.
+func parseJSONSensorFile(file *sensorFile, data []byte) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return newCollectorError(system.CollectorParseError, err)
+	}
+	if _, ok := object["value"]; ok {
+		reading, err := parseJSONSensorReading(object)
+		file.value = reading
+		return err
+	}
+
+	var timestamp time.Time
+	if raw, ok := object["timestamp"]; ok {
+		var err error
+		if timestamp, err = parseJSONSensorTimestamp(raw); err != nil {
+			return err
+		}
+		delete(object, "timestamp")
+	}
+	file.values = make(map[string]sensorReading, len(object))
+	file.keys = slices.Sorted(maps.Keys(object))
+	for _, key := range file.keys {
+		raw := bytes.TrimSpace(object[key])
+		var reading sensorReading
+		var err error
+		if bytes.HasPrefix(raw, []byte("{")) {
+			var keyObject map[string]json.RawMessage
+			if err = json.Unmarshal(raw, &keyObject); err == nil {
+				reading, err = parseJSONSensorReading(keyObject)
+			}
+		} else {
+			reading, err = parseJSONSensorValue(raw)
+		}
+		if err != nil {
+			return fmt.Errorf("key '%s': %w", key, err)
+		}
+		if reading.timestamp.IsZero() {
+			reading.timestamp = timestamp
+		}
+		file.values[key] = reading
+	}
+	if len(file.values) == 0 {
+		return newCollectorError(system.CollectorParseError, fmt.Errorf("no values in JSON object"))
+	}
+	return nil
+}
+
+// parseJSONSensorReading parses an object with a value and optional unit and timestamp.
+func parseJSONSensorReading(object map[string]json.RawMessage) (sensorReading, error) {
+	raw, ok := object["value"]
+	if !ok {
+		return sensorReading{}, newCollectorError(system.CollectorParseError, fmt.Errorf("missing value"))
+	}
+	reading, err := parseJSONSensorValue(raw)
+	if err != nil {
+		return reading, err
+	}
+	if raw, ok := object["unit"]; ok {
+		if err := json.Unmarshal(raw, &reading.unit); err != nil {
+			return reading, newCollectorError(system.CollectorParseError, fmt.Errorf("invalid unit: %w", err))
+		}
+		reading.unit = strings.TrimSpace(reading.unit)
+	}
+	if raw, ok := object["timestamp"]; ok {
+		reading.timestamp, err = parseJSONSensorTimestamp(raw)
+	}
+	return reading, err
+}
+
+// parseJSONSensorValue parses a JSON number, or a string or boolean parsed like
+// a plain text value.
+func parseJSONSensorValue(raw json.RawMessage) (sensorReading, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return sensorReading{value: text}, nil
+	}
+	var boolean bool
+	if err := json.Unmarshal(raw, &boolean); err == nil {
+		return sensorReading{value: strconv.FormatBool(boolean)}, nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return sensorReading{}, newCollectorError(system.CollectorParseError, fmt.Errorf("invalid value %s", raw))
+	}
+	return sensorReading{value: number.String(), json: true}, nil
+}
+
+// parseJSONSensorTimestamp parses an RFC 3339 timestamp or Unix time in seconds
+// or milliseconds.
+func parseJSONSensorTimestamp(raw json.RawMessage) (time.Time, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		timestamp, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return time.Time{}, newCollectorError(system.CollectorParseError, fmt.Errorf("invalid timestamp '%s'", text))
+		}
+		return timestamp, nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err != nil || seconds <= 0 {
+		return time.Time{}, newCollectorError(system.CollectorParseError, fmt.Errorf("invalid timestamp %s", raw))
+	}
+	// Unix time in milliseconds has more than 12 digits until the year 33658
+	if seconds > 1e12 {
+		seconds /= 1000
+	}
+	return time.UnixMilli(int64(seconds * 1000)), nil
+}
+
+// reading returns the value of a key in a multi-value file, or the value of a
+// single-value file if key is empty.
+func (f sensorFile) reading(key string) (sensorReading, error) {
+	if key == "" {
+		if f.values != nil {
+			return sensorReading{}, newCollectorError(system.CollectorParseError, fmt.Errorf("file has multiple values"))
+		}
+		return f.value, nil
+	}
+	reading, ok := f.values[key]
+	if !ok {
+		return reading, newCollectorError(system.CollectorUnavailable, fmt.Errorf("key '%s' not found", key))
+	}
+	return reading, nil
+}
+
+// number parses the value in the given number format. JSON numbers always use a dot.
+func (r sensorReading) number(format string) (float64, error) {
+	if r.json {
+		format = numberFormatDot
+	}
+	value, err := parseSensorNumber(r.value, format)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse sensor value '%s': %w", r.value, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,145 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/clock"
+	"beszel/internal/entities/system"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSensorFile(t *testing.T) {
+	timestamp := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		content  string
+		expected sensorFile
+	}{
+		{
+			name:     "single value",
+			content:  "23.5 °C\n",
+			expected: sensorFile{value: sensorReading{value: "23.5 °C"}},
+		},
+		{
+			name:     "header",
+			content:  "(voltage,V,12,0)\n11,8\n",
+			expected: sensorFile{header: "(voltage,V,12,0)", value: sensorReading{value: "11,8"}},
+		},
+		{
+			name:    "key value lines",
+			content: "# exporter\ntemp=21.5\nhumidity = 45\n",
+			expected: sensorFile{
+				values: map[string]sensorReading{"temp": {value: "21.5"}, "humidity": {value: "45"}},
+				keys:   []string{"temp", "humidity"},
+			},
+		},
+		{
+			name:    "json value",
+			content: `{"value": 23.5, "unit": " °C ", "timestamp": "2025-01-02T15:04:05Z"}`,
+			expected: sensorFile{
+				value: sensorReading{value: "23.5", json: true, unit: "°C", timestamp: timestamp},
+				json:  true,
+			},
+		},
+		{
+			name:    "json values by key",
+			content: `{"temp": 21.5, "humidity": {"value": "45 %", "unit": "%"}, "timestamp": 1735830245}`,
+			expected: sensorFile{
+				values: map[string]sensorReading{
+					"temp":     {value: "21.5", json: true, timestamp: timestamp},
+					"humidity": {value: "45 %", unit: "%", timestamp: timestamp},
+				},
+				keys: []string{"humidity", "temp"},
+				json: true,
+			},
+		},
+		{
+			name:    "json timestamp in milliseconds",
+			content: `{"value": 1, "timestamp": 1735830245000}`,
+			expected: sensorFile{
+				value: sensorReading{value: "1", json: true, timestamp: timestamp},
+				json:  true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parseSensorFile([]byte(tt.content))
+			require.NoError(t, err)
+			// compare times by instant
+			for key, reading := range file.values {
+				reading.timestamp = reading.timestamp.UTC()
+				file.values[key] = reading
+			}
+			if !file.value.timestamp.IsZero() {
+				file.value.timestamp = file.value.timestamp.UTC()
+			}
+			assert.Equal(t, tt.expected, file)
+		})
+	}
+
+	for _, content := range []string{`{"value": [1]}`, `{"value": 1, "timestamp": "yesterday"}`, `{"temp": {"unit": "°C"}}`, `{}`, `{"value":`} {
+		_, err := parseSensorFile([]byte(content))
+		assert.Equal(t, system.CollectorParseError, getCollectorErrorKind(err), content)
+	}
+
+	// JSON numbers always use a dot, strings are parsed in the sensor's format
+	file, err := parseSensorFile([]byte(`{"a": 1.234, "b": "1.234"}`))
+	require.NoError(t, err)
+	value, err := file.values["a"].number(numberFormatComma)
+	require.NoError(t, err)
+	assert.Equal(t, 1.234, value)
+	value, err = file.values["b"].number(numberFormatComma)
+	require.NoError(t, err)
+	assert.Equal(t, 1234.0, value)
+
+	_, err = file.reading("")
+	assert.Error(t, err)
+	_, err = file.reading("c")
+	assert.Equal(t, system.CollectorUnavailable, getCollectorErrorKind(err))
+}
+
+func TestUpdateGenericSensorsFromJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	writeFile("power", `{"value": 412.5, "unit": "W", "timestamp": "`+now+`"}`)
+	writeFile("old", `{"value": 3, "unit": "V", "timestamp": "2025-01-02T15:04:05Z"}`)
+	writeFile("pressure", `{"value": "1.013,25"}`)
+	writeFile("pressure.json", `{"unit": "hPa", "min": 900, "max": 1100, "format": "comma"}`)
+	writeFile("bme280", `{"temp": {"value": 21.5, "unit": "°C"}, "humidity": 45}`)
+
+	agent := &Agent{clock: clock.New()}
+	agent.sensorConfig = agent.newSensorConfigWithEnv("", "", "", false)
+	agent.sensorConfig.staleTimeout = 10 * time.Minute
+	agent.sensorConfig.loadGenericSensorFiles(dir)
+	assert.Len(t, agent.sensorConfig.genericSensors, 5)
+
+	var stats system.Stats
+	agent.updateGenericSensors(context.Background(), &stats)
+	assert.Equal(t, map[string]system.SensorData{
+		"power":           {Value: 412.5, Unit: "W"},
+		"pressure":        {Value: 1013.25, Unit: "hPa", Min: 900, Max: 1100},
+		"bme280_temp":     {Value: 21.5, Unit: "°C"},
+		"bme280_humidity": {Value: 45},
+	}, stats.GenericSensors)
+	// the old reading is stale by its timestamp, although the file was just written
+	assert.Equal(t, system.CollectorStale, agent.collectorStatus[collectorGenericSensors].Kind)
+
+	value, err := ReadSensorFromFile(filepath.Join(dir, "power"))
+	require.NoError(t, err)
+	assert.Equal(t, 412.5, value)
+	values, err := ReadSensorValuesFromFile(filepath.Join(dir, "bme280"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"temp": 21.5, "humidity": 45}, values)
+}
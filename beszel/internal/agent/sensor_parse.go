@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Number formats of generic sensor values, set with "format" in sensor metadata
+// when the decimal separator can't be guessed, e.g. "1,234" could be 1.234 or 1234.
+const (
+	numberFormatAuto  = ""      // guess the decimal separator
+	numberFormatDot   = "dot"   // 1,234.5
+	numberFormatComma = "comma" // 1.234,5
+)
+
+// sensorNumberRe matches the first number in a sensor value, including thousands
+// separators (",", ".", "'", "_" or spaces followed by a digit), a comma or dot
+// decimal separator and an exponent
+var sensorNumberRe = regexp.MustCompile(`[-+]?(?:\d(?:[\d.,'_]|[ \x{a0}\x{202f}]\d)*|[.,]\d+)(?:[eE][-+]?\d+)?`)
+
+// validateNumberFormat returns an error if format is not a known number format.
+func validateNumberFormat(format string) error {
+	switch format {
+	case numberFormatAuto, numberFormatDot, numberFormatComma:
+		return nil
+	}
+	return fmt.Errorf("invalid number format '%s', expected '%s' or '%s'", format, numberFormatDot, numberFormatComma)
+}
+
+// parseSensorNumber parses the first number in a sensor value, ignoring units or
+// other text around it, e.g. "23.5 °C", "12,3V", "1.234,5 rpm" or "1.2e-3 A".
+//
+// With numberFormatAuto, the last of "." and "," is the decimal separator if both
+// are used, a separator used more than once separates thousands, and a single
+// separator is the decimal separator.
+func parseSensorNumber(value, format string) (float64, error) {
+	match := sensorNumberRe.FindString(value)
+	if match == "" {
+		return 0, newCollectorError(system.CollectorParseError, fmt.Errorf("no number in '%s'", strings.TrimSpace(value)))
+	}
+	mantissa, exponent := match, ""
+	if i := strings.IndexAny(match, "eE"); i >= 0 {
+		mantissa, exponent = match[:i], match[i:]
+	}
+	mantissa = strings.NewReplacer("'", "", "_", "", " ", "", "\u00a0", "", "\u202f", "").Replace(mantissa)
+	mantissa = strings.TrimRight(mantissa, ".,")
+
+	decimal := byte('.')
+	switch format {
+	case numberFormatComma:
+		decimal = ','
+	case numberFormatAuto:
+		lastDot, lastComma := strings.LastIndexByte(mantissa, '.'), strings.LastIndexByte(mantissa, ',')
+		switch {
+		case lastDot >= 0 && lastComma >= 0:
+			if lastComma > lastDot {
+				decimal = ','
+			}
+		case lastComma >= 0 && strings.Count(mantissa, ",") == 1:
+			decimal = ','
+		case lastDot >= 0 && strings.Count(mantissa, ".") > 1:
+			decimal = 0 // only thousands separators
+		}
+	}
+
+	var number strings.Builder
+	for i := 0; i < len(mantissa); i++ {
+		switch c := mantissa[i]; {
+		case c == decimal:
+			number.WriteByte('.')
+		case c == '.' || c == ',':
+			// thousands separator
+		default:
+			number.WriteByte(c)
+		}
+	}
+	return strconv.ParseFloat(number.String()+exponent, 64)
+}
@@ -0,0 +1,56 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSensorNumber(t *testing.T) {
+	tests := []struct {
+		value    string
+		format   string
+		expected float64
+	}{
+		{"42", "", 42},
+		{"23.5 °C", "", 23.5},
+		{"12,3V", "", 12.3},
+		{"1.234,5 rpm", "", 1234.5},
+		{"1,234.5", "", 1234.5},
+		{"1.234.567", "", 1234567},
+		{"1 234,5", "", 1234.5},
+		{"1 234,5", "", 1234.5},
+		{"1'234.5", "", 1234.5},
+		{"1.2e-3 A", "", 0.0012},
+		{"-4", "", -4},
+		{"value: 7.", "", 7},
+		{"1,234", numberFormatDot, 1234},
+		{"1,234", numberFormatComma, 1.234},
+		{"1.234", numberFormatComma, 1234},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value+"/"+tt.format, func(t *testing.T) {
+			value, err := parseSensorNumber(tt.value, tt.format)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.expected, value, 1e-9)
+		})
+	}
+
+	_, err := parseSensorNumber("warm", "")
+	var collectorErr *collectorError
+	require.True(t, errors.As(err, &collectorErr))
+	assert.Equal(t, system.CollectorParseError, collectorErr.kind)
+}
+
+func TestValidateNumberFormat(t *testing.T) {
+	assert.NoError(t, validateNumberFormat(""))
+	assert.NoError(t, validateNumberFormat("dot"))
+	assert.NoError(t, validateNumberFormat("comma"))
+	assert.Error(t, validateNumberFormat("space"))
+}
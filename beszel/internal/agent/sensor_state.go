@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Types of generic sensors with discrete states, set with "type" in sensor metadata
+const (
+	sensorTypeState   = "state"   // states mapped to codes with "states", e.g. {"closed": 0, "open": 1}
+	sensorTypeBoolean = "boolean" // off or on, also read from true/false, yes/no and 1/0
+)
+
+// Default states of boolean sensors
+var booleanStates = map[string]float64{"off": 0, "on": 1}
+
+// Values read as off or on by boolean sensors
+var booleanValues = map[string]bool{
+	"0": false, "false": false, "no": false, "off": false,
+	"1": true, "true": true, "yes": true, "on": true,
+}
+
+// setStates makes the sensor a state sensor of the given type, with the numeric
+// code of each state and the states that trigger the sensor state alert.
+// The range of the sensor is set to the range of the codes.
+func (c *GenericSensorConfig) setStates(sensorType string, states map[string]float64, alertStates []string) error {
+	c.Type = strings.TrimSpace(sensorType)
+	c.States = make(map[string]float64, len(states))
+	for label, code := range states {
+		if label = strings.TrimSpace(label); label != "" {
+			c.States[label] = code
+		}
+	}
+	if c.Type == sensorTypeBoolean && len(c.States) == 0 {
+		maps.Copy(c.States, booleanStates)
+	}
+	c.AlertStates = nil
+	for _, state := range alertStates {
+		if state = strings.TrimSpace(state); state != "" {
+			c.AlertStates = append(c.AlertStates, state)
+		}
+	}
+	if len(c.States) > 0 {
+		codes := slices.Collect(maps.Values(c.States))
+		c.Minimum, c.Maximum = slices.Min(codes), slices.Max(codes)
+	}
+	return c.validateStates()
+}
+
+// validateStates checks that a state sensor has a known type and states, and
+// that its alert states are among them.
+func (c GenericSensorConfig) validateStates() error {
+	if c.Type != sensorTypeState && c.Type != sensorTypeBoolean {
+		return fmt.Errorf("invalid sensor type '%s', expected '%s' or '%s'", c.Type, sensorTypeState, sensorTypeBoolean)
+	}
+	if len(c.States) == 0 {
+		return fmt.Errorf("state sensor '%s' has no states", c.Name)
+	}
+	for _, state := range c.AlertStates {
+		if _, ok := c.findState(state); !ok {
+			return fmt.Errorf("alert state '%s' is not a state of sensor '%s'", state, c.Name)
+		}
+	}
+	return nil
+}
+
+// findState returns the configured label of a state, matched without case.
+func (c GenericSensorConfig) findState(value string) (string, bool) {
+	for _, label := range slices.Sorted(maps.Keys(c.States)) {
+		if strings.EqualFold(label, value) {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// stateLabel returns the label of a state code, or false if no state has the code.
+func (c GenericSensorConfig) stateLabel(code float64) (string, bool) {
+	for _, label := range slices.Sorted(maps.Keys(c.States)) {
+		if c.States[label] == code {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// parseState returns the code and label of the state read from a sensor file.
+// The value is a state label, a state code, or a boolean value for boolean sensors.
+func (c GenericSensorConfig) parseState(value string) (code float64, label string, err error) {
+	value = strings.TrimSpace(value)
+	if label, ok := c.findState(value); ok {
+		return c.States[label], label, nil
+	}
+	if on, ok := booleanValues[strings.ToLower(value)]; ok && c.Type == sensorTypeBoolean {
+		code = 0
+		label = "off"
+		if on {
+			code, label = 1, "on"
+		}
+		if configured, ok := c.stateLabel(code); ok {
+			label = configured
+		}
+		return code, label, nil
+	}
+	if code, err := strconv.ParseFloat(value, 64); err == nil {
+		if label, ok := c.stateLabel(code); ok {
+			return code, label, nil
+		}
+	}
+	return 0, "", newCollectorError(system.CollectorParseError, fmt.Errorf("unknown state '%s' of sensor '%s'", value, c.Name))
+}
+
+// isAlertState returns true if the state triggers the sensor state alert.
+func (c GenericSensorConfig) isAlertState(label string) bool {
+	return slices.ContainsFunc(c.AlertStates, func(state string) bool { return strings.EqualFold(state, label) })
+}
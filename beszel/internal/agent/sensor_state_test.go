@@ -0,0 +1,97 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/clock"
+	"beszel/internal/entities/system"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseState(t *testing.T) {
+	health := GenericSensorConfig{Name: "raid"}
+	require.NoError(t, health.setStates(sensorTypeState, map[string]float64{"OK": 0, "DEGRADED": 1, "FAIL": 2}, []string{"fail", "DEGRADED"}))
+	assert.Equal(t, 0.0, health.Minimum)
+	assert.Equal(t, 2.0, health.Maximum)
+
+	tests := []struct {
+		config GenericSensorConfig
+		value  string
+		code   float64
+		label  string
+	}{
+		{health, "OK", 0, "OK"},
+		{health, " degraded\n", 1, "DEGRADED"},
+		{health, "2", 2, "FAIL"},
+		{GenericSensorConfig{Type: sensorTypeBoolean, States: booleanStates}, "true", 1, "on"},
+		{GenericSensorConfig{Type: sensorTypeBoolean, States: booleanStates}, "No", 0, "off"},
+		{GenericSensorConfig{Type: sensorTypeBoolean, States: map[string]float64{"closed": 0, "open": 1}}, "1", 1, "open"},
+		{GenericSensorConfig{Type: sensorTypeBoolean, States: map[string]float64{"closed": 0, "open": 1}}, "false", 0, "closed"},
+	}
+	for _, tt := range tests {
+		code, label, err := tt.config.parseState(tt.value)
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.code, code, tt.value)
+		assert.Equal(t, tt.label, label, tt.value)
+	}
+
+	_, _, err := health.parseState("unknown")
+	assert.Equal(t, system.CollectorParseError, getCollectorErrorKind(err))
+	// boolean values are only read by boolean sensors
+	_, _, err = health.parseState("true")
+	assert.Error(t, err)
+
+	assert.True(t, health.isAlertState("FAIL"))
+	assert.True(t, health.isAlertState("DEGRADED"))
+	assert.False(t, health.isAlertState("OK"))
+
+	invalid := GenericSensorConfig{Name: "door"}
+	assert.Error(t, invalid.setStates("switch", nil, nil))
+	assert.Error(t, invalid.setStates(sensorTypeState, nil, nil))
+	assert.Error(t, invalid.setStates(sensorTypeState, map[string]float64{"closed": 0}, []string{"open"}))
+	assert.NoError(t, invalid.setStates(sensorTypeBoolean, nil, []string{"on"}))
+}
+
+func TestUpdateStateSensors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	writeFile("door", "open\n")
+	writeFile("door.json", `{"type": "state", "states": {"closed": 0, "open": 1}, "alert": ["open"], "label": "Garage Door"}`)
+	writeFile("pump", `{"value": false}`)
+	writeFile("pump.json", `{"type": "boolean"}`)
+	writeFile("ups", "status=OL\nload=12\n")
+	writeFile("ups.json", `{"status": {"type": "state", "states": {"OL": 0, "OB": 1, "LB": 2}, "alert": ["OB", "LB"]}}`)
+	writeFile("invalid.json", `{"type": "state", "states": {"closed": 0}, "alert": ["open"]}`)
+
+	agent := &Agent{clock: clock.New()}
+	agent.sensorConfig = agent.newSensorConfigWithEnv("", "", "", false)
+	agent.sensorConfig.loadGenericSensorFiles(dir)
+	assert.NotContains(t, agent.sensorConfig.genericSensors, "invalid")
+
+	var stats system.Stats
+	agent.updateGenericSensors(context.Background(), &stats)
+	assert.Equal(t, map[string]system.SensorData{
+		"door":       {Value: 1, Max: 1, State: "open", Alert: true},
+		"pump":       {Value: 0, Max: 1, State: "off"},
+		"ups_status": {Value: 0, Max: 2, State: "OL"},
+		"ups_load":   {Value: 12},
+	}, stats.GenericSensors)
+	assert.Equal(t, 1.0, stats.SensorsAlerting)
+
+	writeFile("door", "closed\n")
+	writeFile("ups", "status=OB\nload=40\n")
+	stats = system.Stats{}
+	agent.updateGenericSensors(context.Background(), &stats)
+	assert.Equal(t, "OB", stats.GenericSensors["ups_status"].State)
+	assert.False(t, stats.GenericSensors["door"].Alert)
+	assert.Equal(t, 1.0, stats.SensorsAlerting)
+}
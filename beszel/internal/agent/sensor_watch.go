@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Default time between sensor reads for threshold webhooks and actions
+const defaultSensorCheckInterval = 10 * time.Second
+
+// sensorCheckInterval returns SENSOR_CHECK_INTERVAL, or the default if not set.
+func sensorCheckInterval() (time.Duration, error) {
+	v, ok := GetEnv("SENSOR_CHECK_INTERVAL")
+	if !ok {
+		return defaultSensorCheckInterval, nil
+	}
+	interval, err := time.ParseDuration(v)
+	if err != nil || interval < time.Second {
+		return 0, fmt.Errorf("invalid SENSOR_CHECK_INTERVAL %q", v)
+	}
+	return interval, nil
+}
+
+// startSensorWatch checks sensors against webhook thresholds and actions
+// independently of hub requests, so they respond as soon as a sensor changes.
+func (a *Agent) startSensorWatch() error {
+	w, err := newSensorWebhook()
+	if err != nil {
+		return err
+	}
+	actions, err := newSensorActions()
+	if err != nil {
+		return err
+	}
+	if w == nil && actions == nil {
+		return nil
+	}
+	interval, err := sensorCheckInterval()
+	if err != nil {
+		return err
+	}
+	if w != nil {
+		slog.Info("Sensor webhook", "thresholds", len(w.thresholds), "interval", interval)
+	}
+	if actions != nil {
+		slog.Info("Sensor actions", "actions", len(actions.actions), "interval", interval)
+	}
+	hostname := a.systemInfo.Hostname
+	go func() {
+		ticker := a.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C() {
+			readings := a.readSensors()
+			now := a.clock.Now()
+			if w != nil {
+				for _, payload := range w.check(readings, hostname, now) {
+					slog.Info("Sensor threshold", "sensor", payload.Sensor, "value", payload.Value, "state", payload.State)
+					if err := w.send(payload); err != nil {
+						slog.Error("Sensor webhook failed", "err", err)
+					}
+				}
+			}
+			if actions != nil {
+				actions.check(readings, now)
+			}
+		}
+	}()
+	return nil
+}
+
+// readSensors reads temperature and generic sensors outside of a stats
+// collection, keeping the state of the last collection.
+func (a *Agent) readSensors() map[string]float64 {
+	a.Lock()
+	defer a.Unlock()
+
+	timeout := a.collectionTimeout
+	if timeout <= 0 {
+		timeout = defaultCollectionTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	collectorStatus, dashboardTemp := a.collectorStatus, a.systemInfo.DashboardTemp
+	defer func() {
+		a.collectorStatus, a.systemInfo.DashboardTemp = collectorStatus, dashboardTemp
+	}()
+	a.collectorStatus = nil
+
+	var stats system.Stats
+	a.updateTemperatures(ctx, &stats)
+	a.updateGenericSensors(ctx, &stats)
+	a.updateNutSensors(ctx, &stats)
+
+	readings := make(map[string]float64, len(stats.Temperatures)+len(stats.GenericSensors))
+	for name, value := range stats.Temperatures {
+		readings[name] = value
+	}
+	for name, sensor := range stats.GenericSensors {
+		readings[name] = sensor.Value
+	}
+	return readings
+}
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"beszel"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sensorThreshold is a limit for a temperature or generic sensor
+type sensorThreshold struct {
+	sensor string
+	above  bool // breached above the value, otherwise below
+	value  float64
+}
+
+// parseSensorThresholds parses thresholds in the format "cpu_temp>80,pressure<900"
+func parseSensorThresholds(list string) []sensorThreshold {
+	var thresholds []sensorThreshold
+	for entry := range strings.SplitSeq(list, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.IndexAny(entry, "<>")
+		if i <= 0 {
+			slog.Warn("Invalid sensor threshold", "threshold", entry)
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(entry[i+1:]), 64)
+		if err != nil {
+			slog.Warn("Invalid sensor threshold", "threshold", entry)
+			continue
+		}
+		thresholds = append(thresholds, sensorThreshold{
+			sensor: strings.TrimSpace(entry[:i]),
+			above:  entry[i] == '>',
+			value:  value,
+		})
+	}
+	return thresholds
+}
+
+// breached reports whether a reading is beyond the threshold
+func (t sensorThreshold) breached(value float64) bool {
+	if t.above {
+		return value > t.value
+	}
+	return value < t.value
+}
+
+// condition returns "above" or "below"
+func (t sensorThreshold) condition() string {
+	if t.above {
+		return "above"
+	}
+	return "below"
+}
+
+// sensorWebhookPayload is the JSON body sent when a sensor crosses a threshold
+type sensorWebhookPayload struct {
+	Hostname  string    `json:"hostname"`
+	Sensor    string    `json:"sensor"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Condition string    `json:"condition"` // "above" or "below"
+	State     string    `json:"state"`     // "triggered" or "resolved"
+	Time      time.Time `json:"time"`
+	Version   string    `json:"version"` // agent version
+}
+
+// sensorWebhook posts to SENSOR_WEBHOOK_URL when a sensor crosses one of SENSOR_THRESHOLDS.
+type sensorWebhook struct {
+	url        string
+	thresholds []sensorThreshold
+	breached   []bool // current state of each threshold
+	client     *http.Client
+}
+
+// newSensorWebhook returns the configured webhook, or nil if SENSOR_WEBHOOK_URL is not set.
+func newSensorWebhook() (*sensorWebhook, error) {
+	url, _ := GetEnv("SENSOR_WEBHOOK_URL")
+	if url == "" {
+		return nil, nil
+	}
+	thresholds, _ := GetEnv("SENSOR_THRESHOLDS")
+	w := &sensorWebhook{
+		url:        url,
+		thresholds: parseSensorThresholds(thresholds),
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	if len(w.thresholds) == 0 {
+		return nil, errors.New("SENSOR_WEBHOOK_URL requires SENSOR_THRESHOLDS")
+	}
+	w.breached = make([]bool, len(w.thresholds))
+	return w, nil
+}
+
+// check returns a payload for each threshold that was crossed since the last
+// check. Thresholds of sensors without a reading keep their state.
+func (w *sensorWebhook) check(readings map[string]float64, hostname string, now time.Time) []sensorWebhookPayload {
+	var payloads []sensorWebhookPayload
+	for i, threshold := range w.thresholds {
+		value, ok := readings[threshold.sensor]
+		if !ok || threshold.breached(value) == w.breached[i] {
+			continue
+		}
+		w.breached[i] = !w.breached[i]
+		state := "resolved"
+		if w.breached[i] {
+			state = "triggered"
+		}
+		payloads = append(payloads, sensorWebhookPayload{
+			Hostname:  hostname,
+			Sensor:    threshold.sensor,
+			Value:     value,
+			Threshold: threshold.value,
+			Condition: threshold.condition(),
+			State:     state,
+			Time:      now.UTC(),
+			Version:   beszel.Version,
+		})
+	}
+	return payloads
+}
+
+// send posts a payload to the webhook URL.
+func (w *sensorWebhook) send(payload sensorWebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", beszel.AppName+"-agent/"+beszel.Version)
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
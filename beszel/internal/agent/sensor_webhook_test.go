@@ -0,0 +1,111 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSensorThresholds(t *testing.T) {
+	thresholds := parseSensorThresholds(" cpu > 90 ,pressure<900.5,invalid,>5,fan<abc,")
+	assert.Equal(t, []sensorThreshold{
+		{sensor: "cpu", above: true, value: 90},
+		{sensor: "pressure", above: false, value: 900.5},
+	}, thresholds)
+
+	assert.True(t, thresholds[0].breached(90.1))
+	assert.False(t, thresholds[0].breached(90))
+	assert.True(t, thresholds[1].breached(900))
+	assert.False(t, thresholds[1].breached(901))
+}
+
+func TestSensorWebhookCheck(t *testing.T) {
+	w := &sensorWebhook{
+		thresholds: parseSensorThresholds("cpu>90,pressure<900"),
+		breached:   make([]bool, 2),
+	}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Empty(t, w.check(map[string]float64{"cpu": 50, "pressure": 1000}, "host", now))
+
+	payloads := w.check(map[string]float64{"cpu": 95}, "host", now)
+	require.Len(t, payloads, 1)
+	assert.Equal(t, sensorWebhookPayload{
+		Hostname: "host", Sensor: "cpu", Value: 95, Threshold: 90,
+		Condition: "above", State: "triggered", Time: now, Version: payloads[0].Version,
+	}, payloads[0])
+
+	assert.Empty(t, w.check(map[string]float64{"cpu": 96}, "host", now), "only sent when crossing")
+	assert.Empty(t, w.check(map[string]float64{}, "host", now), "missing readings keep the state")
+
+	payloads = w.check(map[string]float64{"cpu": 80, "pressure": 850}, "host", now)
+	require.Len(t, payloads, 2)
+	assert.Equal(t, "resolved", payloads[0].State)
+	assert.Equal(t, "pressure", payloads[1].Sensor)
+	assert.Equal(t, "below", payloads[1].Condition)
+	assert.Equal(t, "triggered", payloads[1].State)
+}
+
+func TestSensorWebhookSend(t *testing.T) {
+	var received sensorWebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &received))
+		if received.Sensor == "bad" {
+			http.Error(w, "nope", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("BESZEL_AGENT_SENSOR_WEBHOOK_URL", server.URL)
+	t.Setenv("BESZEL_AGENT_SENSOR_THRESHOLDS", "cpu>90")
+	w, err := newSensorWebhook()
+	require.NoError(t, err)
+	require.NotNil(t, w)
+
+	require.NoError(t, w.send(sensorWebhookPayload{Sensor: "cpu", Value: 95, State: "triggered"}))
+	assert.Equal(t, "cpu", received.Sensor)
+	assert.Equal(t, 95.0, received.Value)
+
+	assert.EqualError(t, w.send(sensorWebhookPayload{Sensor: "bad"}), "400 Bad Request: nope")
+}
+
+func TestNewSensorWebhookConfig(t *testing.T) {
+	w, err := newSensorWebhook()
+	assert.NoError(t, err)
+	assert.Nil(t, w, "disabled without url")
+
+	t.Setenv("BESZEL_AGENT_SENSOR_WEBHOOK_URL", "http://localhost/hook")
+	_, err = newSensorWebhook()
+	assert.Error(t, err, "requires thresholds")
+
+	t.Setenv("BESZEL_AGENT_SENSOR_THRESHOLDS", "cpu>90")
+	w, err = newSensorWebhook()
+	require.NoError(t, err)
+	assert.Len(t, w.thresholds, 1)
+}
+
+func TestSensorCheckInterval(t *testing.T) {
+	interval, err := sensorCheckInterval()
+	require.NoError(t, err)
+	assert.Equal(t, defaultSensorCheckInterval, interval)
+
+	t.Setenv("BESZEL_AGENT_SENSOR_CHECK_INTERVAL", "2s")
+	interval, err = sensorCheckInterval()
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, interval)
+
+	t.Setenv("BESZEL_AGENT_SENSOR_CHECK_INTERVAL", "100ms")
+	_, err = sensorCheckInterval()
+	assert.Error(t, err)
+}
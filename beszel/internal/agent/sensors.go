@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/shirou/gopsutil/v4/common"
@@ -21,6 +22,9 @@ type SensorConfig struct {
 	context        context.Context
 	sensors        map[string]struct{}
 	genericSensors map[string]GenericSensorConfig
+	aliases        map[string]string // Display names keyed by original sensor name
+	categories     map[string]string // Categories keyed by sensor name or wildcard pattern
+	staleTimeout   time.Duration     // Generic sensor files not modified within this time are stale
 	primarySensor  string
 	isBlacklist    bool
 	hasWildcards   bool
@@ -28,19 +32,107 @@ type SensorConfig struct {
 }
 
 type GenericSensorConfig struct {
-	Name    string
-	Unit    string
-	Maximum float64
-	Minimum float64
+	Name     string
+	Unit     string
+	Maximum  float64
+	Minimum  float64
+	Label    string // Display name, used if no alias is set in SENSOR_ALIASES
+	Decimals *int   // Overrides the sensors precision if set
+	Path     string // Value file, defaults to the sensor name in the generic sensors directory
+	Key      string // Key in a multi-value file, empty for single value files
+	Format   string // Number format of the value, numberFormatDot or numberFormatComma, guessed if empty
+	// sensorTypeState or sensorTypeBoolean for sensors with discrete states, empty for numeric sensors
+	Type        string
+	States      map[string]float64 // Numeric code of each state of a state sensor
+	AlertStates []string           // States that trigger the sensor state alert
+	// Overrides SENSOR_STALE_TIMEOUT if set, negative to disable
+	StaleTimeout time.Duration
 }
 
 func (a *Agent) newSensorConfig() *SensorConfig {
-	primarySensor, _ := GetEnv("PRIMARY_SENSOR")
-	sysSensors, _ := GetEnv("SYS_SENSORS")
-	sensorsEnvVal, sensorsSet := GetEnv("SENSORS")
+	primarySensor, _ := a.getSensorEnv("PRIMARY_SENSOR")
+	sysSensors, _ := a.getSensorEnv("SYS_SENSORS")
+	sensorsEnvVal, sensorsSet := a.getSensorEnv("SENSORS")
 	skipCollection := sensorsSet && sensorsEnvVal == ""
 
-	return a.newSensorConfigWithEnv(primarySensor, sysSensors, sensorsEnvVal, skipCollection)
+	config := a.newSensorConfigWithEnv(primarySensor, sysSensors, sensorsEnvVal, skipCollection)
+	config.loadGenericSensorFiles(genericSensorsDir)
+	if sensorAliases, _ := a.getSensorEnv("SENSOR_ALIASES"); sensorAliases != "" {
+		config.aliases = parseSensorAliases(sensorAliases)
+		slog.Info("SENSOR_ALIASES", "aliases", config.aliases)
+	}
+	config.addGenericSensorLabels()
+	if sensorCategories, _ := a.getSensorEnv("SENSOR_CATEGORIES"); sensorCategories != "" {
+		config.categories = parseSensorCategories(sensorCategories)
+		slog.Info("SENSOR_CATEGORIES", "categories", config.categories)
+	}
+	if staleTimeout, _ := a.getSensorEnv("SENSOR_STALE_TIMEOUT"); staleTimeout != "" {
+		if timeout, err := time.ParseDuration(staleTimeout); err == nil && timeout >= 0 {
+			config.staleTimeout = timeout
+			slog.Info("SENSOR_STALE_TIMEOUT", "timeout", timeout)
+		} else {
+			slog.Warn("Invalid SENSOR_STALE_TIMEOUT", "value", staleTimeout)
+		}
+	}
+	return config
+}
+
+// addGenericSensorLabels uses generic sensor labels as aliases unless an alias is already set
+func (config *SensorConfig) addGenericSensorLabels() {
+	for name, sensor := range config.genericSensors {
+		if sensor.Label == "" {
+			continue
+		}
+		if config.aliases == nil {
+			config.aliases = make(map[string]string)
+		}
+		if _, ok := config.aliases[name]; !ok {
+			config.aliases[name] = sensor.Label
+		}
+	}
+}
+
+// validSensorCategories are the categories accepted in SENSOR_CATEGORIES
+var validSensorCategories = map[string]struct{}{
+	system.SensorCategoryCpu:     {},
+	system.SensorCategoryChassis: {},
+	system.SensorCategoryAmbient: {},
+	system.SensorCategoryCustom:  {},
+}
+
+// parseSensorCategories parses sensor categories in the format "name=category,pattern*=category"
+func parseSensorCategories(categoriesEnvVal string) map[string]string {
+	categories := make(map[string]string)
+	for entry := range strings.SplitSeq(categoriesEnvVal, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, category, _ := strings.Cut(entry, "=")
+		name, category = strings.TrimSpace(name), strings.ToLower(strings.TrimSpace(category))
+		if _, ok := validSensorCategories[category]; !ok || name == "" {
+			slog.Warn("Invalid sensor category", "category", entry)
+			continue
+		}
+		categories[name] = category
+	}
+	return categories
+}
+
+// parseSensorAliases parses sensor aliases in the format "name=Alias,other_name=Other Alias"
+func parseSensorAliases(aliasesEnvVal string) map[string]string {
+	aliases := make(map[string]string)
+	for entry := range strings.SplitSeq(aliasesEnvVal, ",") {
+		name, alias, ok := strings.Cut(entry, "=")
+		name, alias = strings.TrimSpace(name), strings.TrimSpace(alias)
+		if !ok || name == "" || alias == "" {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				slog.Warn("Invalid sensor alias", "alias", entry)
+			}
+			continue
+		}
+		aliases[name] = alias
+	}
+	return aliases
 }
 
 // Matches sensors.TemperaturesWithContext to allow for panic recovery (gopsutil/issues/1832)
@@ -71,7 +163,7 @@ func (a *Agent) newSensorConfigWithEnv(primarySensor, sysSensors, sensorsEnvVal
 		sensorsEnvVal = sensorsEnvVal[1:]
 	}
 
-	for sensor := range strings.SplitSeq(sensorsEnvVal, ",") {
+	for _, sensor := range splitSensors(sensorsEnvVal) {
 		sensor = strings.TrimSpace(sensor)
 		if sensor != "" {
 			// Check if it's new generic sensor format
@@ -93,54 +185,96 @@ func (a *Agent) newSensorConfigWithEnv(primarySensor, sysSensors, sensorsEnvVal
 	return config
 }
 
+// splitSensors splits a SENSORS value on commas, keeping generic sensors like
+// "(name,unit,maximum,minimum)" together.
+func splitSensors(sensorsEnvVal string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range sensorsEnvVal {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, sensorsEnvVal[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, sensorsEnvVal[start:])
+}
+
 // parseGenericSensor parses a generic sensor configuration in the format "(name,unit,maximum,minimum)"
 func (config *SensorConfig) parseGenericSensor(sensor string) error {
+	sensorConfig, err := parseGenericSensorConfig(sensor)
+	if err != nil {
+		return err
+	}
+	config.genericSensors[sensorConfig.Name] = sensorConfig
+
+	slog.Info("Configured generic sensor", "name", sensorConfig.Name, "unit", sensorConfig.Unit, "min", sensorConfig.Minimum, "max", sensorConfig.Maximum)
+	return nil
+}
+
+// parseGenericSensorConfig parses and validates a generic sensor in the format
+// "(name,unit,maximum,minimum)", with an optional number format: "(name,unit,maximum,minimum,comma)"
+func parseGenericSensorConfig(sensor string) (GenericSensorConfig, error) {
 	// Remove parentheses
 	content := sensor[1 : len(sensor)-1]
 	parts := strings.Split(content, ",")
-	if len(parts) != 4 {
-		return fmt.Errorf("expected 4 parts (name,unit,maximum,minimum), got %d", len(parts))
+	if len(parts) != 4 && len(parts) != 5 {
+		return GenericSensorConfig{}, fmt.Errorf("expected 4 parts (name,unit,maximum,minimum), got %d", len(parts))
 	}
 
-	name := strings.TrimSpace(parts[0])
-	unit := strings.TrimSpace(parts[1])
 	maximumStr := strings.TrimSpace(parts[2])
 	minimumStr := strings.TrimSpace(parts[3])
 
-	if name == "" {
-		return fmt.Errorf("sensor name cannot be empty")
-	}
-	if unit == "" {
-		return fmt.Errorf("sensor unit cannot be empty")
-	}
-
 	maximum, err := strconv.ParseFloat(maximumStr, 64)
 	if err != nil {
-		return fmt.Errorf("invalid maximum value '%s': %w", maximumStr, err)
+		return GenericSensorConfig{}, fmt.Errorf("invalid maximum value '%s': %w", maximumStr, err)
 	}
 
 	minimum, err := strconv.ParseFloat(minimumStr, 64)
 	if err != nil {
-		return fmt.Errorf("invalid minimum value '%s': %w", minimumStr, err)
+		return GenericSensorConfig{}, fmt.Errorf("invalid minimum value '%s': %w", minimumStr, err)
 	}
 
-	if minimum >= maximum {
-		return fmt.Errorf("minimum value (%f) must be less than maximum value (%f)", minimum, maximum)
-	}
-
-	config.genericSensors[name] = GenericSensorConfig{
-		Name:    name,
-		Unit:    unit,
+	sensorConfig := GenericSensorConfig{
+		Name:    strings.TrimSpace(parts[0]),
+		Unit:    strings.TrimSpace(parts[1]),
 		Maximum: maximum,
 		Minimum: minimum,
 	}
+	if len(parts) == 5 {
+		sensorConfig.Format = strings.TrimSpace(parts[4])
+	}
+	return sensorConfig, sensorConfig.validate()
+}
 
-	slog.Info("Configured generic sensor", "name", name, "unit", unit, "min", minimum, "max", maximum)
-	return nil
+// validate checks that the sensor has a name, unit and valid range, or valid states
+func (c GenericSensorConfig) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("sensor name cannot be empty")
+	}
+	if c.Type != "" {
+		// state sensors have no unit and the range of their state codes
+		return c.validateStates()
+	}
+	if c.Unit == "" {
+		return fmt.Errorf("sensor unit cannot be empty")
+	}
+	if c.Minimum >= c.Maximum {
+		return fmt.Errorf("minimum value (%f) must be less than maximum value (%f)", c.Minimum, c.Maximum)
+	}
+	return validateNumberFormat(c.Format)
 }
 
 // updateTemperatures updates the agent with the latest sensor temperatures
-func (a *Agent) updateTemperatures(systemStats *system.Stats) {
+func (a *Agent) updateTemperatures(ctx context.Context, systemStats *system.Stats) {
 	// skip if sensors whitelist is set to empty string
 	if a.sensorConfig.skipCollection {
 		slog.Debug("Skipping temperature collection")
@@ -150,17 +284,18 @@ func (a *Agent) updateTemperatures(systemStats *system.Stats) {
 	// reset high temp
 	a.systemInfo.DashboardTemp = 0
 
-	temps, err := a.getTempsWithPanicRecovery(getSensorTemps)
-	if err != nil {
+	temps, err := a.getTempsWithPanicRecovery(ctx, getSensorTemps)
+	if err != nil && ctx.Err() == nil {
 		// retry once on panic (gopsutil/issues/1832)
-		temps, err = a.getTempsWithPanicRecovery(getSensorTemps)
-		if err != nil {
-			slog.Warn("Error updating temperatures", "err", err)
-			if len(systemStats.Temperatures) > 0 {
-				systemStats.Temperatures = make(map[string]float64)
-			}
-			return
+		temps, err = a.getTempsWithPanicRecovery(ctx, getSensorTemps)
+	}
+	a.setCollectorStatus(collectorTemperatures, err)
+	if err != nil {
+		slog.Debug("Error updating temperatures", "err", err)
+		if len(systemStats.Temperatures) > 0 {
+			systemStats.Temperatures = make(map[string]float64)
 		}
+		return
 	}
 	slog.Debug("Temperature", "sensors", temps)
 
@@ -200,12 +335,12 @@ func (a *Agent) updateTemperatures(systemStats *system.Stats) {
 		case sensorName:
 			a.systemInfo.DashboardTemp = sensor.Temperature
 		}
-		systemStats.Temperatures[sensorName] = twoDecimals(sensor.Temperature)
+		systemStats.Temperatures[sensorName] = a.precision.round(metricTemperature, sensor.Temperature)
 	}
 }
 
 // updateGenericSensors updates the agent with the latest generic sensor data
-func (a *Agent) updateGenericSensors(systemStats *system.Stats) {
+func (a *Agent) updateGenericSensors(ctx context.Context, systemStats *system.Stats) {
 	// Skip if no generic sensors are configured
 	if len(a.sensorConfig.genericSensors) == 0 {
 		return
@@ -216,69 +351,365 @@ func (a *Agent) updateGenericSensors(systemStats *system.Stats) {
 		systemStats.GenericSensors = make(map[string]system.SensorData)
 	}
 
+	// Multi-value files, read once per collection so readings are consistent
+	files := make(map[string]sensorFile)
+	stateSensors, alerting, stale := 0, 0, 0
+	now := a.clock.Now()
+
 	// Collect data for each configured generic sensor
 	for name, config := range a.sensorConfig.genericSensors {
-		value, err := a.collectGenericSensorValue(name, config)
+		if err := config.checkStale(ctx, now, a.sensorConfig.staleTimeout); err != nil {
+			slog.Debug("Generic sensor is stale", "sensor", name, "err", err)
+			if getCollectorErrorKind(err) == system.CollectorStale {
+				stale++
+			}
+			a.setCollectorStatus(collectorGenericSensors, err)
+			continue
+		}
+
+		var reading sensorReading
+		var err error
+		if config.Key != "" {
+			reading, err = collectGenericSensorKey(ctx, config, files)
+		} else {
+			reading, err = a.collectGenericSensorValue(ctx, name, config)
+		}
+		var value float64
+		var state string
+		if err == nil && config.Type != "" {
+			value, state, err = config.parseState(reading.value)
+		} else if err == nil {
+			value, err = reading.number(config.Format)
+		}
+		// readings with a timestamp are stale by their own time rather than the file's
+		if err == nil && !reading.timestamp.IsZero() {
+			err = config.checkAge(reading.timestamp, now, a.sensorConfig.staleTimeout)
+		}
 		if err != nil {
-			slog.Warn("Failed to collect generic sensor data", "sensor", name, "err", err)
+			slog.Debug("Failed to collect generic sensor data", "sensor", name, "err", err)
+			if getCollectorErrorKind(err) == system.CollectorStale {
+				stale++
+			}
+			a.setCollectorStatus(collectorGenericSensors, err)
 			continue
 		}
 
-		// Validate the value is within the configured range
-		if value < config.Minimum || value > config.Maximum {
-			slog.Warn("Generic sensor value out of range", "sensor", name, "value", value, "min", config.Minimum, "max", config.Maximum)
+		// Validate the value is within the configured range, if any
+		if config.Minimum < config.Maximum && (value < config.Minimum || value > config.Maximum) {
+			slog.Debug("Generic sensor value out of range", "sensor", name, "value", value, "min", config.Minimum, "max", config.Maximum)
+			a.setCollectorStatus(collectorGenericSensors, newCollectorError(system.CollectorParseError,
+				fmt.Errorf("sensor '%s' value %v out of range [%v, %v]", name, value, config.Minimum, config.Maximum)))
 			continue
 		}
+		a.setCollectorStatus(collectorGenericSensors, nil)
 
-		systemStats.GenericSensors[name] = system.SensorData{
-			Value: twoDecimals(value),
-			Unit:  config.Unit,
+		precision := a.precision.get(metricSensors)
+		if config.Decimals != nil {
+			precision.decimals = *config.Decimals
+		}
+		unit := config.Unit
+		if unit == "" {
+			unit = reading.unit
+		}
+		sensorData := system.SensorData{
+			Value: precision.round(value),
+			Unit:  unit,
 			Min:   config.Minimum,
 			Max:   config.Maximum,
 		}
+		if config.Type != "" {
+			stateSensors++
+			sensorData.Value = value
+			sensorData.State = state
+			if sensorData.Alert = config.isAlertState(state); sensorData.Alert {
+				alerting++
+			}
+		}
+		systemStats.GenericSensors[name] = sensorData
+	}
+	if stateSensors > 0 {
+		systemStats.SensorsAlerting = float64(alerting)
+	}
+	systemStats.SensorsStale = float64(stale)
+}
+
+// addSensorReadings adds fans and other sensors read by platform sensor backends
+// and plugins to the generic sensors, filtered by SENSORS like temperatures.
+func (a *Agent) addSensorReadings(systemStats *system.Stats, readings map[string]system.SensorData) {
+	precision := a.precision.get(metricSensors)
+	for name, data := range readings {
+		if !isValidSensor(name, a.sensorConfig) {
+			continue
+		}
+		if systemStats.GenericSensors == nil {
+			systemStats.GenericSensors = make(map[string]system.SensorData)
+		}
+		data.Value = precision.round(data.Value)
+		systemStats.GenericSensors[name] = data
 	}
 }
 
-// collectGenericSensorValue collects the current value for a generic sensor
+// Substrings of sensor names used to detect the category of temperature sensors
+var (
+	cpuSensorPatterns     = []string{"cpu", "coretemp", "k10temp", "k8temp", "zenpower", "tctl", "tdie", "tccd", "package_id"}
+	chassisSensorPatterns = []string{"acpitz", "nct6", "nct7", "it87", "pch", "systin", "motherboard", "mainboard", "chipset"}
+	ambientSensorPatterns = []string{"ambient", "room", "outside", "inlet", "intake"}
+)
+
+// getSensorCategory returns the category of a sensor from SENSOR_CATEGORIES or its name.
+// Generic sensors default to custom. Returns an empty string if no category applies.
+func (config *SensorConfig) getSensorCategory(sensorName string, isGeneric bool) string {
+	if category, ok := config.categories[sensorName]; ok {
+		return category
+	}
+	for pattern, category := range config.categories {
+		if match, _ := path.Match(pattern, sensorName); match {
+			return category
+		}
+	}
+	lowerName := strings.ToLower(sensorName)
+	containsAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if strings.Contains(lowerName, pattern) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case containsAny(ambientSensorPatterns):
+		return system.SensorCategoryAmbient
+	case isGeneric:
+		return system.SensorCategoryCustom
+	case containsAny(cpuSensorPatterns):
+		return system.SensorCategoryCpu
+	case containsAny(chassisSensorPatterns):
+		return system.SensorCategoryChassis
+	}
+	return ""
+}
+
+// updateSensorCategories tags temperature and generic sensors with their category
+func (a *Agent) updateSensorCategories(systemStats *system.Stats) {
+	systemStats.SensorCategories = nil
+	setCategory := func(name string, isGeneric bool) {
+		category := a.sensorConfig.getSensorCategory(name, isGeneric)
+		if category == "" {
+			return
+		}
+		if systemStats.SensorCategories == nil {
+			systemStats.SensorCategories = make(map[string]string)
+		}
+		systemStats.SensorCategories[name] = category
+	}
+	for name := range systemStats.Temperatures {
+		setCategory(name, false)
+	}
+	for name := range systemStats.GenericSensors {
+		setCategory(name, true)
+	}
+}
+
+// applySensorAliases renames temperature and generic sensors (and their categories) to their configured aliases.
+// Done before stats are sent so the hub stores history under the alias.
+func (a *Agent) applySensorAliases(systemStats *system.Stats) {
+	if len(a.sensorConfig.aliases) == 0 {
+		return
+	}
+	names := make(map[string]struct{}, len(systemStats.Temperatures)+len(systemStats.GenericSensors))
+	for name := range systemStats.Temperatures {
+		names[name] = struct{}{}
+	}
+	for name := range systemStats.GenericSensors {
+		names[name] = struct{}{}
+	}
+	renames := a.sensorConfig.resolveAliases(names)
+	if len(renames) == 0 {
+		return
+	}
+	systemStats.Temperatures = renameSensors(systemStats.Temperatures, renames)
+	systemStats.GenericSensors = renameSensors(systemStats.GenericSensors, renames)
+	systemStats.SensorCategories = renameSensors(systemStats.SensorCategories, renames)
+}
+
+// resolveAliases returns the aliases of the sensor names to apply. Aliases are
+// resolved against the original names, so chained aliases like a=b,b=c rename
+// a to b and b to c regardless of order. Aliases that would collide with
+// another sensor, or that several sensors share, are skipped so the sensors
+// keep their names.
+func (config *SensorConfig) resolveAliases(names map[string]struct{}) map[string]string {
+	renames := make(map[string]string)
+	targets := make(map[string]int)
+	for name := range names {
+		if alias, ok := config.aliases[name]; ok && alias != name {
+			renames[name] = alias
+			targets[alias]++
+		}
+	}
+	// skipping an alias can make an alias of its sensor's name collide, so
+	// repeat until no more aliases are skipped
+	for skipped := true; skipped; {
+		skipped = false
+		for name, alias := range renames {
+			_, exists := names[alias]
+			_, renamed := renames[alias]
+			if (exists && !renamed) || targets[alias] > 1 {
+				slog.Debug("Sensor alias collides with another sensor", "sensor", name, "alias", alias)
+				delete(renames, name)
+				skipped = true
+			}
+		}
+	}
+	return renames
+}
+
+// renameSensors returns a copy of the values keyed by the new names of the renamed sensors
+func renameSensors[V any](values map[string]V, renames map[string]string) map[string]V {
+	if values == nil {
+		return nil
+	}
+	renamed := make(map[string]V, len(values))
+	for name, value := range values {
+		if alias, ok := renames[name]; ok {
+			name = alias
+		}
+		renamed[name] = value
+	}
+	return renamed
+}
+
+// collectGenericSensorValue collects the current reading of a generic sensor
 // It reads the value from the corresponding file in /generic-sensors/
-func (a *Agent) collectGenericSensorValue(sensorName string, config GenericSensorConfig) (float64, error) {
-	// Look for sensor file in /generic-sensors/
-	sensorPath := filepath.Join("/generic-sensors", sensorName)
-	
-	// Check if the sensor file exists
-	if _, err := os.Stat(sensorPath); os.IsNotExist(err) {
-		return 0, fmt.Errorf("sensor file not found at %s - create a file or symlink with the sensor value", sensorPath)
-	}
-	
+func (a *Agent) collectGenericSensorValue(ctx context.Context, sensorName string, config GenericSensorConfig) (sensorReading, error) {
+	// Look for sensor file in /generic-sensors/ unless a path is set
+	sensorPath := config.Path
+	if sensorPath == "" {
+		sensorPath = filepath.Join(genericSensorsDir, sensorName)
+	}
+
 	// Read the sensor value from the file
-	value, err := ReadSensorFromFile(sensorPath)
+	reading, err := runWithContext(ctx, "sensor:"+sensorPath, func() (sensorReading, error) {
+		// Check if the sensor file exists
+		if _, err := os.Stat(sensorPath); os.IsNotExist(err) {
+			return sensorReading{}, fmt.Errorf("sensor file not found at %s - create a file or symlink with the sensor value: %w", sensorPath, err)
+		}
+		file, err := readSensorFile(sensorPath)
+		if err != nil {
+			return sensorReading{}, err
+		}
+		return file.reading("")
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to read sensor '%s' from %s: %w", sensorName, sensorPath, err)
+		return reading, fmt.Errorf("failed to read sensor '%s' from %s: %w", sensorName, sensorPath, err)
 	}
-	
-	return value, nil
+
+	return reading, nil
+}
+
+// checkStale returns an error if the sensor's file was not modified within the stale timeout.
+func (config GenericSensorConfig) checkStale(ctx context.Context, now time.Time, defaultTimeout time.Duration) error {
+	sensorPath := config.Path
+	if sensorPath == "" {
+		sensorPath = filepath.Join(genericSensorsDir, config.Name)
+	}
+	// stat of a hung mount blocks like reading it, so it shares the read's key
+	info, err := runWithContext(ctx, "sensor:"+sensorPath, func() (os.FileInfo, error) {
+		return os.Stat(sensorPath)
+	})
+	if getCollectorErrorKind(err) == system.CollectorTimeout {
+		return err
+	}
+	if err != nil {
+		// missing files are reported when reading the value
+		return nil
+	}
+	return config.checkAge(info.ModTime(), now, defaultTimeout)
+}
+
+// checkAge returns an error if the sensor was last updated before the stale timeout.
+func (config GenericSensorConfig) checkAge(updated, now time.Time, defaultTimeout time.Duration) error {
+	timeout := defaultTimeout
+	if config.StaleTimeout != 0 {
+		timeout = config.StaleTimeout
+	}
+	if timeout <= 0 {
+		return nil
+	}
+	if age := now.Sub(updated); age > timeout {
+		return newCollectorError(system.CollectorStale,
+			fmt.Errorf("sensor '%s' not updated for %s", config.Name, age.Truncate(time.Second)))
+	}
+	return nil
+}
+
+// collectGenericSensorKey returns the reading of a key in a multi-value sensor file.
+// Each file is read once per collection and cached in files.
+func collectGenericSensorKey(ctx context.Context, config GenericSensorConfig, files map[string]sensorFile) (sensorReading, error) {
+	file, ok := files[config.Path]
+	if !ok {
+		var err error
+		file, err = runWithContext(ctx, "sensor:"+config.Path, func() (sensorFile, error) {
+			return readSensorFile(config.Path)
+		})
+		if err != nil {
+			return sensorReading{}, err
+		}
+		files[config.Path] = file
+	}
+	reading, err := file.reading(config.Key)
+	if err != nil {
+		return reading, fmt.Errorf("%s: %w", config.Path, err)
+	}
+	return reading, nil
 }
 
 // Helper functions for implementing custom sensor collection
 
-// ReadSensorFromFile reads a numeric value from a file path (useful for Linux sysfs sensors)
+// ReadSensorFromFile reads a numeric value from a file path (useful for Linux sysfs sensors).
+// Units around the value and comma decimal separators are allowed, see parseSensorNumber,
+// and the file may use any single-value format of sensorFile.
 func ReadSensorFromFile(filePath string) (float64, error) {
-	// Read the file content
-	data, err := os.ReadFile(filePath)
+	return readSensorFromFile(filePath, numberFormatAuto)
+}
+
+// readSensorFromFile reads a numeric value in the given number format from a file path
+func readSensorFromFile(filePath, format string) (float64, error) {
+	file, err := readSensorFile(filePath)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read sensor file %s: %w", filePath, err)
+		return 0, err
 	}
-
-	// Parse the numeric value
-	valueStr := strings.TrimSpace(string(data))
-	value, err := strconv.ParseFloat(valueStr, 64)
+	reading, err := file.reading("")
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse sensor value '%s' from %s: %w", valueStr, filePath, err)
+		return 0, fmt.Errorf("%s: %w", filePath, err)
+	}
+	value, err := reading.number(format)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", filePath, err)
 	}
-
 	return value, nil
 }
 
+// ReadSensorValuesFromFile reads the values of a multi-value sensor file by key,
+// from "key=value" lines or a JSON object. Blank lines and lines starting with # are ignored.
+func ReadSensorValuesFromFile(filePath string) (map[string]float64, error) {
+	file, err := readSensorFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if file.values == nil {
+		return nil, newCollectorError(system.CollectorParseError, fmt.Errorf("%s is not a multi-value file", filePath))
+	}
+	values := make(map[string]float64, len(file.values))
+	for key, reading := range file.values {
+		value, err := reading.number(numberFormatAuto)
+		if err != nil {
+			return nil, fmt.Errorf("key '%s' from %s: %w", key, filePath, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
 // GetGenericSensorNames returns the names of all configured generic sensors
 func (a *Agent) GetGenericSensorNames() []string {
 	names := make([]string, 0, len(a.sensorConfig.genericSensors))
@@ -304,14 +735,21 @@ func (config *SensorConfig) GetGenericSensors() map[string]GenericSensorConfig {
 }
 
 // getTempsWithPanicRecovery wraps sensors.TemperaturesWithContext to recover from panics (gopsutil/issues/1832)
-func (a *Agent) getTempsWithPanicRecovery(getTemps getTempsFn) (temps []sensors.TemperatureStat, err error) {
+// The collection context is merged with the sensors context so SYS_SENSORS is still respected.
+func (a *Agent) getTempsWithPanicRecovery(ctx context.Context, getTemps getTempsFn) (temps []sensors.TemperatureStat, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("panic: %v", r)
 		}
 	}()
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if envMap := a.sensorConfig.context.Value(common.EnvKey); envMap != nil {
+		ctx = context.WithValue(ctx, common.EnvKey, envMap)
+	}
 	// get sensor data (error ignored intentionally as it may be only with one sensor)
-	temps, _ = getTemps(a.sensorConfig.context)
+	temps, _ = getTemps(ctx)
 	return
 }
 
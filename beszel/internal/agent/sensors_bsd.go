@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/sensors"
+)
+
+// parseFreeBSDTemps parses the temperatures in the output of
+// `sysctl -i -e dev.cpu hw.acpi.thermal` on FreeBSD, e.g.
+//
+//	dev.cpu.0.temperature=45.0C
+//	hw.acpi.thermal.tz0.temperature=27.9C
+func parseFreeBSDTemps(output string) []sensors.TemperatureStat {
+	var temps []sensors.TemperatureStat
+	for line := range strings.SplitSeq(output, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || !strings.HasSuffix(name, ".temperature") {
+			continue
+		}
+		temp, err := strconv.ParseFloat(strings.TrimSuffix(value, "C"), 64)
+		if err != nil || temp <= 0 {
+			continue
+		}
+		parts := strings.Split(name, ".")
+		var key string
+		switch {
+		// coretemp and amdtemp
+		case len(parts) == 4 && parts[0] == "dev" && parts[1] == "cpu":
+			key = "cpu" + parts[2]
+		// named like on linux so it's categorized the same
+		case len(parts) == 5 && parts[1] == "acpi" && parts[2] == "thermal":
+			key = "acpitz_" + parts[3]
+		default:
+			key = strings.ReplaceAll(strings.TrimSuffix(name, ".temperature"), ".", "_")
+		}
+		temps = append(temps, sensors.TemperatureStat{SensorKey: key, Temperature: temp})
+	}
+	return temps
+}
+
+// parseOpenBSDSensors parses the temperatures and fans in the output of
+// `sysctl hw.sensors` on OpenBSD, e.g.
+//
+//	hw.sensors.cpu0.temp0=45.00 degC
+//	hw.sensors.acpitz0.temp0=27.80 degC (zone temperature)
+//	hw.sensors.it0.fan1=2732 RPM
+func parseOpenBSDSensors(output string) ([]sensors.TemperatureStat, map[string]system.SensorData) {
+	var temps []sensors.TemperatureStat
+	fans := make(map[string]system.SensorData)
+	for line := range strings.SplitSeq(output, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		name, ok = strings.CutPrefix(name, "hw.sensors.")
+		fields := strings.Fields(value)
+		if !ok || len(fields) < 2 {
+			continue
+		}
+		reading, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		// e.g. cpu0.temp0 -> cpu0_temp0
+		key := strings.ReplaceAll(name, ".", "_")
+		switch fields[1] {
+		case "degC":
+			if reading > 0 {
+				temps = append(temps, sensors.TemperatureStat{SensorKey: key, Temperature: reading})
+			}
+		case "RPM":
+			fans[key] = system.SensorData{Value: reading, Unit: "RPM"}
+		}
+	}
+	return temps, fans
+}
@@ -0,0 +1,46 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/sensors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFreeBSDTemps(t *testing.T) {
+	output := `dev.cpu.0.%desc=Intel(R) Celeron(R) J4125 CPU @ 2.00GHz
+dev.cpu.0.temperature=45.0C
+dev.cpu.0.coretemp.tjmax=105.0C
+dev.cpu.1.temperature=47.0C
+hw.acpi.thermal.tz0.temperature=27.9C
+hw.acpi.thermal.tz0._CRT=-1
+hw.acpi.thermal.tz1.temperature=0.0C
+`
+	assert.Equal(t, []sensors.TemperatureStat{
+		{SensorKey: "cpu0", Temperature: 45},
+		{SensorKey: "cpu1", Temperature: 47},
+		{SensorKey: "acpitz_tz0", Temperature: 27.9},
+	}, parseFreeBSDTemps(output))
+}
+
+func TestParseOpenBSDSensors(t *testing.T) {
+	output := `hw.sensors.cpu0.temp0=45.00 degC
+hw.sensors.acpitz0.temp0=27.80 degC (zone temperature)
+hw.sensors.it0.fan1=2732 RPM
+hw.sensors.it0.volt0=1.22 VDC (VCORE_A)
+hw.sensors.acpibat0.raw0=2 (battery charging), OK
+hw.sensors.softraid0.drive0=online (sd1), OK
+`
+	temps, fans := parseOpenBSDSensors(output)
+	assert.Equal(t, []sensors.TemperatureStat{
+		{SensorKey: "cpu0_temp0", Temperature: 45},
+		{SensorKey: "acpitz0_temp0", Temperature: 27.8},
+	}, temps)
+	assert.Equal(t, map[string]system.SensorData{
+		"it0_fan1": {Value: 2732, Unit: "RPM"},
+	}, fans)
+}
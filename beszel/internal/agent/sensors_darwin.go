@@ -0,0 +1,20 @@
+//go:build darwin
+
+package agent
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/v4/sensors"
+)
+
+// getSensorTemps reads temperatures from the SMC, falling back to gopsutil if
+// the SMC can't be opened or has no temperature keys.
+func getSensorTemps(ctx context.Context) ([]sensors.TemperatureStat, error) {
+	if smc, err := getSmc(); err == nil {
+		if temps := smc.temperatures(); len(temps) > 0 {
+			return temps, nil
+		}
+	}
+	return sensors.TemperaturesWithContext(ctx)
+}
@@ -0,0 +1,22 @@
+//go:build freebsd
+
+package agent
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/shirou/gopsutil/v4/sensors"
+)
+
+// getSensorTemps reads the CPU temperatures reported by the coretemp and
+// amdtemp drivers and the ACPI thermal zones. The drivers must be loaded,
+// e.g. with coretemp_load="YES" in /boot/loader.conf.
+func getSensorTemps(ctx context.Context) ([]sensors.TemperatureStat, error) {
+	// -i ignores nodes that don't exist, like hw.acpi.thermal without ACPI
+	output, err := exec.CommandContext(ctx, "sysctl", "-i", "-e", "dev.cpu", "hw.acpi.thermal").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseFreeBSDTemps(string(output)), nil
+}
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/sensors"
+)
+
+// WMI namespaces published by LibreHardwareMonitor and OpenHardwareMonitor
+// when they're running with the WMI provider enabled
+var lhmWmiNamespaces = []string{`root\LibreHardwareMonitor`, `root\OpenHardwareMonitor`}
+
+// lhmWmiSensor is a temperature sensor in the LibreHardwareMonitor WMI
+// namespace. Field names match the Sensor WMI class.
+type lhmWmiSensor struct {
+	Name   string  // e.g. "CPU Package" or "Temperature 2"
+	Parent string  // identifier of the hardware, e.g. "/amdcpu/0"
+	Value  float32 // degrees Celsius
+}
+
+// lhmSensorTemps converts LibreHardwareMonitor WMI sensors to temperatures,
+// naming them the same way as the embedded LHM process does.
+func lhmSensorTemps(lhmSensors []lhmWmiSensor) []sensors.TemperatureStat {
+	temps := make([]sensors.TemperatureStat, 0, len(lhmSensors))
+	for _, sensor := range lhmSensors {
+		if sensor.Name == "" || strings.Contains(sensor.Name, "Distance") {
+			continue
+		}
+		if sensor.Value <= 0 || sensor.Value > 150 {
+			continue
+		}
+		name := sensor.Name
+		// generic names like "Temperature 3" are prefixed with the hardware
+		if suffix, ok := strings.CutPrefix(name, "Temperature"); ok {
+			name = strings.TrimLeft(strings.ReplaceAll(sensor.Parent, "/", "_"), "_") + suffix
+		}
+		temps = append(temps, sensors.TemperatureStat{
+			SensorKey:   name,
+			Temperature: float64(sensor.Value),
+		})
+	}
+	return temps
+}
@@ -0,0 +1,27 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/sensors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLhmSensorTemps(t *testing.T) {
+	temps := lhmSensorTemps([]lhmWmiSensor{
+		{Name: "CPU Package", Parent: "/intelcpu/0", Value: 54.5},
+		{Name: "Temperature 3", Parent: "/lpc/nct6798d", Value: 38},
+		{Name: "Temperature", Parent: "/nvme/0", Value: 41},
+		{Name: "Distance to TjMax", Parent: "/intelcpu/0", Value: 45},
+		{Name: "GPU Hot Spot", Parent: "/gpu-nvidia/0", Value: 0},
+		{Name: "Core #1", Parent: "/intelcpu/0", Value: 200},
+	})
+	assert.Equal(t, []sensors.TemperatureStat{
+		{SensorKey: "CPU Package", Temperature: 54.5},
+		{SensorKey: "lpc_nct6798d 3", Temperature: 38},
+		{SensorKey: "nvme_0", Temperature: 41},
+	}, temps)
+}
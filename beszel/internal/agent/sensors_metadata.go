@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// genericSensorsDir is the directory generic sensor values and metadata are read from
+const genericSensorsDir = "/generic-sensors"
+
+// genericSensorMetadata is the content of a <name>.json companion file, which
+// defines the generic sensor <name> without adding it to SENSORS:
+//
+//	{"unit": "Pa", "min": 0, "max": 1000, "label": "Room Pressure", "decimals": 1, "stale": "5m", "format": "comma"}
+//
+// State sensors need no unit or range:
+//
+//	{"type": "state", "states": {"closed": 0, "open": 1}, "alert": ["open"], "label": "Garage Door"}
+type genericSensorMetadata struct {
+	Unit     string  `json:"unit"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Label    string  `json:"label"`
+	Decimals *int    `json:"decimals"`
+	Stale    string  `json:"stale"`  // Stale timeout, "0" to disable
+	Format   string  `json:"format"` // Number format of the value, "dot" or "comma", guessed if empty
+	Type     string  `json:"type"`   // "state" or "boolean" for sensors with discrete states
+	// Numeric code of each state of a state sensor, e.g. {"closed": 0, "open": 1}
+	States map[string]float64 `json:"states"`
+	// States that trigger the sensor state alert
+	Alert []string `json:"alert"`
+}
+
+// loadGenericSensorFiles registers generic sensors defined by files in dir:
+//   - a <name>.json metadata file next to the value file <name>
+//   - a "(name,unit,maximum,minimum)" first line in the value file
+//   - a JSON value file, {"value": 23.5, "unit": "°C"}
+//   - a multi-value file of "key=value" lines or a JSON object of values by key,
+//     registering <file>_<key> for each key
+//
+// See sensorFile for the file formats.
+//
+// Sensors configured in SENSORS take precedence.
+func (config *SensorConfig) loadGenericSensorFiles(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Error reading generic sensors directory", "dir", dir, "err", err)
+		}
+		return
+	}
+	// metadata files keyed by the name of their value file
+	metadataFiles := make(map[string]string)
+	var valueFiles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok {
+			metadataFiles[name] = filepath.Join(dir, entry.Name())
+		} else {
+			valueFiles = append(valueFiles, entry.Name())
+		}
+	}
+
+	for _, name := range valueFiles {
+		filePath := filepath.Join(dir, name)
+		file, err := readSensorFile(filePath)
+		switch {
+		case err != nil:
+			continue
+		case file.values != nil:
+			config.addMultiValueSensors(filePath, name, file.keys, metadataFiles[name])
+			delete(metadataFiles, name)
+		case file.header != "":
+			sensor, err := parseGenericSensorConfig(file.header)
+			sensor.Path = filePath
+			config.addFileSensor(sensor, filePath, err)
+		case file.json && metadataFiles[name] == "":
+			// JSON values describe themselves, the unit is read with the value
+			config.addFileSensor(GenericSensorConfig{Name: name, Path: filePath}, filePath, nil)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(metadataFiles)) {
+		sensor, err := readGenericSensorMetadata(metadataFiles[name], name)
+		sensor.Path = filepath.Join(dir, name)
+		config.addFileSensor(sensor, metadataFiles[name], err)
+	}
+}
+
+// addFileSensor registers a sensor defined by a file unless it is invalid or already configured.
+func (config *SensorConfig) addFileSensor(sensor GenericSensorConfig, filePath string, err error) {
+	if err != nil {
+		slog.Warn("Invalid generic sensor metadata", "file", filePath, "err", err)
+		return
+	}
+	if _, exists := config.genericSensors[sensor.Name]; exists {
+		return
+	}
+	config.genericSensors[sensor.Name] = sensor
+	slog.Info("Configured generic sensor", "name", sensor.Name, "file", filePath)
+}
+
+// addMultiValueSensors registers a sensor named <file>_<key> for each key in a multi-value file.
+// The optional <file>.json metadata file sets the unit, range, label and decimals of each key:
+//
+//	{"temp": {"unit": "°C", "min": -40, "max": 85}, "humidity": {"unit": "%", "min": 0, "max": 100}}
+//
+// Keys without metadata have no unit or range.
+func (config *SensorConfig) addMultiValueSensors(filePath, fileName string, keys []string, metadataPath string) {
+	metadata := make(map[string]genericSensorMetadata)
+	if metadataPath != "" {
+		data, err := os.ReadFile(metadataPath)
+		if err == nil {
+			err = json.Unmarshal(data, &metadata)
+		}
+		if err != nil {
+			slog.Warn("Invalid generic sensor metadata", "file", metadataPath, "err", err)
+		}
+	}
+	for _, key := range keys {
+		keyMetadata := metadata[key]
+		sensor, err := keyMetadata.sensorConfig(fileName + "_" + key)
+		if err == nil && keyMetadata.Type == "" && keyMetadata.Min >= keyMetadata.Max && (keyMetadata.Min != 0 || keyMetadata.Max != 0) {
+			err = fmt.Errorf("key '%s': minimum value (%f) must be less than maximum value (%f)", key, keyMetadata.Min, keyMetadata.Max)
+		}
+		sensor.Path = filePath
+		sensor.Key = key
+		config.addFileSensor(sensor, filePath, err)
+	}
+}
+
+// sensorConfig converts the metadata to a GenericSensorConfig.
+func (m genericSensorMetadata) sensorConfig(name string) (GenericSensorConfig, error) {
+	if m.Decimals != nil && (*m.Decimals < 0 || *m.Decimals > 10) {
+		return GenericSensorConfig{}, fmt.Errorf("decimals must be between 0 and 10, got %d", *m.Decimals)
+	}
+	sensor := GenericSensorConfig{
+		Name:     name,
+		Unit:     strings.TrimSpace(m.Unit),
+		Maximum:  m.Max,
+		Minimum:  m.Min,
+		Label:    strings.TrimSpace(m.Label),
+		Decimals: m.Decimals,
+		Format:   strings.TrimSpace(m.Format),
+	}
+	if err := validateNumberFormat(sensor.Format); err != nil {
+		return GenericSensorConfig{}, err
+	}
+	if m.Type != "" {
+		if err := sensor.setStates(m.Type, m.States, m.Alert); err != nil {
+			return GenericSensorConfig{}, err
+		}
+	}
+	if m.Stale != "" {
+		timeout, err := time.ParseDuration(m.Stale)
+		if err != nil || timeout < 0 {
+			return GenericSensorConfig{}, fmt.Errorf("invalid stale timeout '%s'", m.Stale)
+		}
+		sensor.StaleTimeout = timeout
+		if timeout == 0 {
+			sensor.StaleTimeout = -1
+		}
+	}
+	return sensor, nil
+}
+
+// readGenericSensorMetadata reads a generic sensor definition from a JSON metadata file.
+func readGenericSensorMetadata(filePath, name string) (GenericSensorConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return GenericSensorConfig{}, err
+	}
+	var metadata genericSensorMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return GenericSensorConfig{}, err
+	}
+	sensor, err := metadata.sensorConfig(name)
+	if err != nil {
+		return sensor, err
+	}
+	return sensor, sensor.validate()
+}
+
+// readMultiValueKeys returns the keys of a multi-value file, or false if
+// the file is not made of "key=value" lines or a JSON object of values by key.
+func readMultiValueKeys(filePath string) (keys []string, ok bool) {
+	file, err := readSensorFile(filePath)
+	if err != nil || file.values == nil {
+		return nil, false
+	}
+	return file.keys, true
+}
+
+// isGenericSensorHeader returns true if the line is a "(name,unit,maximum,minimum)" definition
+func isGenericSensorHeader(line string) bool {
+	return strings.HasPrefix(line, "(") && strings.HasSuffix(line, ")")
+}
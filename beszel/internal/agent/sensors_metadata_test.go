@@ -0,0 +1,168 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/clock"
+	"beszel/internal/entities/system"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadGenericSensorFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	writeFile("pressure.json", `{"unit": "Pa", "min": 0, "max": 1000, "label": "Room Pressure", "decimals": 1}`)
+	writeFile("pressure", "850.55\n")
+	writeFile("voltage", "(voltage,V,12,0)\n11.8\n")
+	writeFile("humidity", "45.2\n")
+	writeFile("invalid.json", `{"unit": "%", "min": 100, "max": 0}`)
+	writeFile("precise.json", `{"unit": "%", "min": 0, "max": 100, "decimals": 11}`)
+	writeFile("rpm.json", `{"unit": "rpm", "min": 0, "max": 5000}`)
+
+	decimals := 1
+	config := (&Agent{}).newSensorConfigWithEnv("", "", "(rpm,RPM,3000,0)", false)
+	config.loadGenericSensorFiles(dir)
+
+	assert.Equal(t, map[string]GenericSensorConfig{
+		"pressure": {Name: "pressure", Unit: "Pa", Maximum: 1000, Label: "Room Pressure", Decimals: &decimals, Path: filepath.Join(dir, "pressure")},
+		"voltage":  {Name: "voltage", Unit: "V", Maximum: 12, Path: filepath.Join(dir, "voltage")},
+		// SENSORS takes precedence over the metadata file
+		"rpm": {Name: "rpm", Unit: "RPM", Maximum: 3000},
+	}, config.genericSensors)
+
+	// missing directory is ignored
+	config.loadGenericSensorFiles(filepath.Join(dir, "missing"))
+	assert.Len(t, config.genericSensors, 3)
+
+	// labels are used as aliases unless set in SENSOR_ALIASES
+	config.aliases = map[string]string{"voltage": "Battery"}
+	config.genericSensors["voltage"] = GenericSensorConfig{Name: "voltage", Label: "Ignored"}
+	config.addGenericSensorLabels()
+	assert.Equal(t, map[string]string{"voltage": "Battery", "pressure": "Room Pressure"}, config.aliases)
+}
+
+func TestUpdateGenericSensorsFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pressure.json"), []byte(`{"unit": "Pa", "min": 0, "max": 1000, "decimals": 0}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pressure"), []byte("850.55"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "voltage"), []byte("(voltage,V,12,0)\n11.855\n"), 0644))
+
+	agent := &Agent{clock: clock.New()}
+	agent.sensorConfig = agent.newSensorConfigWithEnv("", "", "", false)
+	agent.sensorConfig.loadGenericSensorFiles(dir)
+
+	var stats system.Stats
+	agent.updateGenericSensors(context.Background(), &stats)
+	assert.Equal(t, map[string]system.SensorData{
+		"pressure": {Value: 851, Unit: "Pa", Max: 1000},
+		"voltage":  {Value: 11.86, Unit: "V", Max: 12},
+	}, stats.GenericSensors)
+}
+
+func TestLoadMultiValueSensorFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bme280"), []byte("# written by exporter\ntemp=21.55\nhumidity = 45.2\npressure=1013.25\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bme280.json"), []byte(`{"temp": {"unit": "°C", "min": -40, "max": 85, "label": "Room"}, "humidity": {"unit": "%", "min": 100, "max": 0}}`), 0644))
+
+	config := (&Agent{}).newSensorConfigWithEnv("", "", "", false)
+	config.loadGenericSensorFiles(dir)
+
+	path := filepath.Join(dir, "bme280")
+	assert.Equal(t, map[string]GenericSensorConfig{
+		"bme280_temp":     {Name: "bme280_temp", Unit: "°C", Minimum: -40, Maximum: 85, Label: "Room", Path: path, Key: "temp"},
+		"bme280_pressure": {Name: "bme280_pressure", Path: path, Key: "pressure"},
+	}, config.genericSensors)
+
+	keys, ok := readMultiValueKeys(path)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"temp", "humidity", "pressure"}, keys)
+	_, ok = readMultiValueKeys(filepath.Join(dir, "bme280.json"))
+	assert.False(t, ok)
+}
+
+func TestUpdateGenericSensorsMultiValue(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bme280")
+	require.NoError(t, os.WriteFile(path, []byte("temp=21.555\npressure=1013.25\n"), 0644))
+
+	agent := &Agent{clock: clock.New()}
+	agent.sensorConfig = agent.newSensorConfigWithEnv("", "", "", false)
+	agent.sensorConfig.loadGenericSensorFiles(dir)
+	agent.sensorConfig.genericSensors["bme280_missing"] = GenericSensorConfig{Name: "bme280_missing", Path: path, Key: "missing"}
+
+	var stats system.Stats
+	agent.updateGenericSensors(context.Background(), &stats)
+	assert.Equal(t, map[string]system.SensorData{
+		"bme280_temp":     {Value: 21.56},
+		"bme280_pressure": {Value: 1013.25},
+	}, stats.GenericSensors)
+	assert.Equal(t, system.CollectorUnavailable, agent.collectorStatus[collectorGenericSensors].Kind)
+
+	// invalid values fail the whole file
+	require.NoError(t, os.WriteFile(path, []byte("temp=warm\n"), 0644))
+	_, err := ReadSensorValuesFromFile(path)
+	assert.Equal(t, system.CollectorParseError, getCollectorErrorKind(err))
+}
+
+func TestGenericSensorStaleness(t *testing.T) {
+	dir := t.TempDir()
+	writeSensor := func(name, content string, age time.Duration) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		modTime := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	writeSensor("fresh", "1", time.Second)
+	writeSensor("fresh.json", `{"unit": "V", "min": 0, "max": 10}`, 0)
+	writeSensor("old", "2", time.Hour)
+	writeSensor("old.json", `{"unit": "V", "min": 0, "max": 10}`, 0)
+	writeSensor("sysfs", "3", 24*time.Hour)
+	writeSensor("sysfs.json", `{"unit": "V", "min": 0, "max": 10, "stale": "0"}`, 0)
+	writeSensor("short", "4", time.Minute)
+	writeSensor("short.json", `{"unit": "V", "min": 0, "max": 10, "stale": "30s"}`, 0)
+
+	mock := clock.NewMock(time.Now())
+	agent := &Agent{clock: mock}
+	agent.sensorConfig = agent.newSensorConfigWithEnv("", "", "", false)
+	agent.sensorConfig.staleTimeout = 10 * time.Minute
+	agent.sensorConfig.loadGenericSensorFiles(dir)
+
+	var stats system.Stats
+	agent.updateGenericSensors(context.Background(), &stats)
+	assert.Equal(t, map[string]system.SensorData{
+		"fresh": {Value: 1, Unit: "V", Max: 10},
+		"sysfs": {Value: 3, Unit: "V", Max: 10},
+	}, stats.GenericSensors)
+	assert.Equal(t, system.CollectorStale, agent.collectorStatus[collectorGenericSensors].Kind)
+	assert.Equal(t, 2.0, stats.SensorsStale)
+
+	// age is measured by the agent's clock
+	mock.Advance(time.Hour)
+	stats = system.Stats{}
+	agent.updateGenericSensors(context.Background(), &stats)
+	assert.Equal(t, map[string]system.SensorData{"sysfs": {Value: 3, Unit: "V", Max: 10}}, stats.GenericSensors)
+	assert.Equal(t, 3.0, stats.SensorsStale)
+
+	// disabled by default
+	agent.sensorConfig.staleTimeout = 0
+	agent.collectorStatus = nil
+	stats = system.Stats{}
+	agent.updateGenericSensors(context.Background(), &stats)
+	assert.Len(t, stats.GenericSensors, 3)
+
+	_, err := readGenericSensorMetadata(filepath.Join(dir, "sysfs.json"), "sysfs")
+	assert.NoError(t, err)
+	writeSensor("invalid.json", `{"unit": "V", "min": 0, "max": 10, "stale": "soon"}`, 0)
+	_, err = readGenericSensorMetadata(filepath.Join(dir, "invalid.json"), "invalid")
+	assert.Error(t, err)
+}
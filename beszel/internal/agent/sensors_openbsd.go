@@ -0,0 +1,37 @@
+//go:build openbsd
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"os/exec"
+
+	"github.com/shirou/gopsutil/v4/sensors"
+)
+
+// readOpenBSDSensors returns the temperatures and fans of the sensors framework.
+func readOpenBSDSensors(ctx context.Context) ([]sensors.TemperatureStat, map[string]system.SensorData, error) {
+	output, err := exec.CommandContext(ctx, "sysctl", "hw.sensors").Output()
+	if err != nil {
+		return nil, nil, err
+	}
+	temps, fans := parseOpenBSDSensors(string(output))
+	return temps, fans, nil
+}
+
+// getSensorTemps reads the temperatures of the sensors framework.
+func getSensorTemps(ctx context.Context) ([]sensors.TemperatureStat, error) {
+	temps, _, err := readOpenBSDSensors(ctx)
+	return temps, err
+}
+
+// updatePlatformSensors adds the fans of the sensors framework.
+func (a *Agent) updatePlatformSensors(ctx context.Context, systemStats *system.Stats) {
+	if a.sensorConfig.skipCollection {
+		return
+	}
+	if _, fans, err := readOpenBSDSensors(ctx); err == nil {
+		a.addSensorReadings(systemStats, fans)
+	}
+}
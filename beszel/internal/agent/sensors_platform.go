@@ -0,0 +1,12 @@
+//go:build !darwin && !openbsd
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+)
+
+// updatePlatformSensors is a no-op on platforms without fan or power sensors
+// beyond those configured as generic sensors.
+func (a *Agent) updatePlatformSensors(ctx context.Context, systemStats *system.Stats) {}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"bufio"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// sensorsFile holds sensor settings from the SENSORS_FILE env var, which can be
+// edited while the agent is running. Values in the file take precedence over env vars.
+//
+// The file uses env file syntax, for example:
+//
+//	SENSORS=coretemp*,(pressure,Pa,1000,0)
+//	PRIMARY_SENSOR=coretemp_package_id_0
+type sensorsFile struct {
+	path    string
+	modTime time.Time
+	values  map[string]string
+}
+
+// sensorsFileKeys are the settings that can be set in SENSORS_FILE
+var sensorsFileKeys = map[string]struct{}{
+	"SENSORS":              {},
+	"PRIMARY_SENSOR":       {},
+	"SYS_SENSORS":          {},
+	"SENSOR_ALIASES":       {},
+	"SENSOR_CATEGORIES":    {},
+	"SENSOR_STALE_TIMEOUT": {},
+}
+
+// newSensorsFile returns a sensorsFile if the SENSORS_FILE env var is set.
+func newSensorsFile() *sensorsFile {
+	path, _ := GetEnv("SENSORS_FILE")
+	if path == "" {
+		return nil
+	}
+	slog.Info("SENSORS_FILE", "path", path)
+	f := &sensorsFile{path: path}
+	if err := f.load(); err != nil {
+		slog.Warn("Error reading SENSORS_FILE", "err", err)
+	}
+	return f
+}
+
+// load reads the file and records its modification time.
+// A missing file clears the values so env vars are used.
+func (f *sensorsFile) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		f.modTime = time.Time{}
+		f.values = nil
+		return err
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimPrefix(strings.TrimSpace(key), "BESZEL_AGENT_")
+		if _, ok := sensorsFileKeys[key]; !ok {
+			slog.Warn("Unknown key in SENSORS_FILE", "key", key)
+			continue
+		}
+		values[key] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	f.values = values
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// changed returns true if the file was modified, created or removed since the last load.
+func (f *sensorsFile) changed() bool {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return !f.modTime.IsZero()
+	}
+	return !info.ModTime().Equal(f.modTime)
+}
+
+// getSensorEnv returns a sensor setting from SENSORS_FILE if set there, otherwise from env vars.
+func (a *Agent) getSensorEnv(key string) (value string, exists bool) {
+	if a.sensorsFile != nil {
+		if value, exists = a.sensorsFile.values[key]; exists {
+			return value, exists
+		}
+	}
+	return GetEnv(key)
+}
+
+// reloadSensorConfig re-reads SENSORS_FILE and rebuilds the sensor config.
+// Must be called with the agent locked.
+func (a *Agent) reloadSensorConfig() {
+	if a.sensorsFile != nil {
+		if err := a.sensorsFile.load(); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Error reading SENSORS_FILE", "err", err)
+			return
+		}
+	}
+	a.sensorConfig = a.newSensorConfig()
+	slog.Info("Reloaded sensor config")
+}
+
+// reloadSensorConfigIfChanged reloads the sensor config if SENSORS_FILE was modified.
+// Called at the start of each collection so changes apply within one cycle.
+func (a *Agent) reloadSensorConfigIfChanged() {
+	if a.sensorsFile != nil && a.sensorsFile.changed() {
+		a.reloadSensorConfig()
+	}
+}
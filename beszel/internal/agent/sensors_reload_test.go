@@ -0,0 +1,79 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSensorsFileLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sensors.env")
+	content := `# sensor settings
+SENSORS=coretemp*,(pressure,Pa,1000,0)
+export PRIMARY_SENSOR="coretemp_package_id_0"
+BESZEL_AGENT_SENSOR_ALIASES='coretemp_package_id_0=CPU'
+UNKNOWN=value
+invalid line
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	f := &sensorsFile{path: path}
+	require.NoError(t, f.load())
+	assert.Equal(t, map[string]string{
+		"SENSORS":        "coretemp*,(pressure,Pa,1000,0)",
+		"PRIMARY_SENSOR": "coretemp_package_id_0",
+		"SENSOR_ALIASES": "coretemp_package_id_0=CPU",
+	}, f.values)
+	assert.False(t, f.changed())
+
+	// modified file is detected
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	assert.True(t, f.changed())
+
+	// removed file is detected once and clears the values
+	require.NoError(t, os.Remove(path))
+	assert.True(t, f.changed())
+	assert.ErrorIs(t, f.load(), os.ErrNotExist)
+	assert.Nil(t, f.values)
+	assert.False(t, f.changed())
+}
+
+func TestSensorConfigReload(t *testing.T) {
+	t.Setenv("BESZEL_AGENT_SENSORS", "acpitz")
+	t.Setenv("BESZEL_AGENT_PRIMARY_SENSOR", "acpitz")
+	path := filepath.Join(t.TempDir(), "sensors.env")
+	t.Setenv("BESZEL_AGENT_SENSORS_FILE", path)
+
+	// env vars are used while the file doesn't exist
+	a := &Agent{sensorsFile: newSensorsFile()}
+	a.sensorConfig = a.newSensorConfig()
+	assert.Equal(t, map[string]struct{}{"acpitz": {}}, a.sensorConfig.sensors)
+	a.reloadSensorConfigIfChanged()
+	assert.Equal(t, "acpitz", a.sensorConfig.primarySensor)
+
+	// file values override env vars after a change
+	require.NoError(t, os.WriteFile(path, []byte("SENSORS=coretemp*,nvme_composite\n"), 0644))
+	a.reloadSensorConfigIfChanged()
+	assert.Equal(t, map[string]struct{}{"coretemp*": {}, "nvme_composite": {}}, a.sensorConfig.sensors)
+	assert.True(t, a.sensorConfig.hasWildcards)
+	assert.Equal(t, "acpitz", a.sensorConfig.primarySensor)
+
+	// empty SENSORS in the file skips collection
+	require.NoError(t, os.WriteFile(path, []byte("SENSORS=\n"), 0644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	a.reloadSensorConfigIfChanged()
+	assert.True(t, a.sensorConfig.skipCollection)
+
+	// removing the file falls back to env vars
+	require.NoError(t, os.Remove(path))
+	a.reloadSensorConfigIfChanged()
+	assert.Equal(t, map[string]struct{}{"acpitz": {}}, a.sensorConfig.sensors)
+	assert.False(t, a.sensorConfig.skipCollection)
+}
@@ -4,11 +4,14 @@
 package agent
 
 import (
+	"beszel/internal/clock"
 	"beszel/internal/entities/system"
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/shirou/gopsutil/v4/common"
 	"github.com/shirou/gopsutil/v4/sensors"
@@ -548,7 +551,7 @@ func TestGetTempsWithPanicRecovery(t *testing.T) {
 
 			// The function should not panic, regardless of what the injected function does
 			assert.NotPanics(t, func() {
-				temps, err = agent.getTempsWithPanicRecovery(tt.getTempsFn)
+				temps, err = agent.getTempsWithPanicRecovery(context.Background(), tt.getTempsFn)
 			}, "getTempsWithPanicRecovery should not panic")
 
 			if tt.expectError {
@@ -565,6 +568,38 @@ func TestGetTempsWithPanicRecovery(t *testing.T) {
 	}
 }
 
+func TestGetTempsWithContext(t *testing.T) {
+	agent := &Agent{
+		sensorConfig: &SensorConfig{
+			context: context.WithValue(context.Background(),
+				common.EnvKey, common.EnvMap{common.HostSysEnvKey: "/test/sys"},
+			),
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// collection deadline and SYS_SENSORS env are both passed to getTemps
+	_, err := agent.getTempsWithPanicRecovery(ctx, func(ctx context.Context) ([]sensors.TemperatureStat, error) {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		envMap, ok := ctx.Value(common.EnvKey).(common.EnvMap)
+		assert.True(t, ok)
+		assert.Equal(t, "/test/sys", envMap[common.HostSysEnvKey])
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	// cancelled context skips the read
+	cancel()
+	temps, err := agent.getTempsWithPanicRecovery(ctx, func(ctx context.Context) ([]sensors.TemperatureStat, error) {
+		t.Fatal("getTemps should not be called")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, temps)
+}
+
 // Test parseGenericSensor functionality
 func TestParseGenericSensor(t *testing.T) {
 	tests := []struct {
@@ -606,6 +641,18 @@ func TestParseGenericSensor(t *testing.T) {
 				Minimum: 500,
 			},
 		},
+		{
+			name:        "Valid with number format",
+			input:       "(voltage,V,12.5,0.5,comma)",
+			expectError: false,
+			expected: GenericSensorConfig{
+				Name:    "voltage",
+				Unit:    "V",
+				Maximum: 12.5,
+				Minimum: 0.5,
+				Format:  "comma",
+			},
+		},
 		{
 			name:        "Missing parts",
 			input:       "(pressure,Pa,1000)",
@@ -712,9 +759,147 @@ func TestNewSensorConfigWithEnvGeneric(t *testing.T) {
 	}
 }
 
+func TestParseSensorAliases(t *testing.T) {
+	aliases := parseSensorAliases("coretemp_package_id_0=CPU Package, nvme_composite = NVMe,invalid,empty=, =nameless")
+	assert.Equal(t, map[string]string{
+		"coretemp_package_id_0": "CPU Package",
+		"nvme_composite":        "NVMe",
+	}, aliases)
+
+	t.Setenv("BESZEL_AGENT_SENSOR_ALIASES", "acpitz=Motherboard")
+	agent := &Agent{}
+	config := agent.newSensorConfig()
+	assert.Equal(t, map[string]string{"acpitz": "Motherboard"}, config.aliases)
+}
+
+func TestApplySensorAliases(t *testing.T) {
+	agent := &Agent{
+		sensorConfig: &SensorConfig{
+			aliases: map[string]string{
+				"coretemp_package_id_0": "CPU Package",
+				"pressure":              "Tank Pressure",
+				"missing":               "Missing",
+			},
+		},
+	}
+	systemStats := &system.Stats{
+		Temperatures: map[string]float64{"coretemp_package_id_0": 55, "nvme_composite": 40},
+		GenericSensors: map[string]system.SensorData{
+			"pressure": {Value: 500, Unit: "Pa", Min: 0, Max: 1000},
+		},
+	}
+	agent.applySensorAliases(systemStats)
+
+	assert.Equal(t, map[string]float64{"CPU Package": 55, "nvme_composite": 40}, systemStats.Temperatures)
+	assert.Equal(t, map[string]system.SensorData{
+		"Tank Pressure": {Value: 500, Unit: "Pa", Min: 0, Max: 1000},
+	}, systemStats.GenericSensors)
+
+	// no aliases is a no-op
+	agent.sensorConfig.aliases = nil
+	agent.applySensorAliases(systemStats)
+	assert.Contains(t, systemStats.Temperatures, "CPU Package")
+}
+
+func TestApplySensorAliasesChainedAndColliding(t *testing.T) {
+	agent := &Agent{
+		sensorConfig: &SensorConfig{
+			aliases: map[string]string{
+				// chained: a is renamed to b, which is renamed to c
+				"a": "b",
+				"b": "c",
+				// collides with the sensor d, which has no alias
+				"x": "d",
+				// shared by two sensors
+				"y": "shared",
+				"z": "shared",
+				// collides with e once the alias of e is skipped
+				"f": "e",
+				"e": "x",
+			},
+		},
+	}
+	// the result doesn't depend on map iteration order
+	for range 20 {
+		systemStats := &system.Stats{
+			Temperatures:     map[string]float64{"a": 1, "b": 2, "d": 3, "x": 4, "y": 5, "z": 6, "e": 7, "f": 8},
+			SensorCategories: map[string]string{"a": system.SensorCategoryCpu, "x": system.SensorCategoryChassis},
+		}
+		agent.applySensorAliases(systemStats)
+		assert.Equal(t, map[string]float64{"b": 1, "c": 2, "d": 3, "x": 4, "y": 5, "z": 6, "e": 7, "f": 8}, systemStats.Temperatures)
+		assert.Equal(t, map[string]string{"b": system.SensorCategoryCpu, "x": system.SensorCategoryChassis}, systemStats.SensorCategories)
+	}
+}
+
+func TestParseSensorCategories(t *testing.T) {
+	categories := parseSensorCategories("nvme*=Chassis, room_temp=ambient,gpu=graphics,=cpu,invalid")
+	assert.Equal(t, map[string]string{
+		"nvme*":     "chassis",
+		"room_temp": "ambient",
+	}, categories)
+}
+
+func TestGetSensorCategory(t *testing.T) {
+	config := &SensorConfig{
+		categories: map[string]string{
+			"nvme_*":   system.SensorCategoryChassis,
+			"pressure": system.SensorCategoryAmbient,
+			"cpu_fan":  system.SensorCategoryCustom,
+		},
+	}
+	tests := []struct {
+		name      string
+		isGeneric bool
+		expected  string
+	}{
+		{"coretemp_package_id_0", false, system.SensorCategoryCpu},
+		{"k10temp_tctl", false, system.SensorCategoryCpu},
+		{"acpitz", false, system.SensorCategoryChassis},
+		{"nct6798_systin", false, system.SensorCategoryChassis},
+		{"ambient_temp", false, system.SensorCategoryAmbient},
+		{"room_temp", true, system.SensorCategoryAmbient},
+		{"voltage", true, system.SensorCategoryCustom},
+		{"nvme_composite", false, system.SensorCategoryChassis},
+		{"pressure", true, system.SensorCategoryAmbient},
+		{"cpu_fan", false, system.SensorCategoryCustom},
+		{"GeForce RTX 3080", false, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, config.getSensorCategory(tt.name, tt.isGeneric), tt.name)
+	}
+}
+
+func TestUpdateSensorCategories(t *testing.T) {
+	agent := &Agent{
+		sensorConfig: &SensorConfig{
+			aliases: map[string]string{"coretemp_package_id_0": "CPU Package"},
+		},
+	}
+	systemStats := &system.Stats{
+		Temperatures:   map[string]float64{"coretemp_package_id_0": 55, "nvme_composite": 40},
+		GenericSensors: map[string]system.SensorData{"pressure": {Value: 500}},
+	}
+	agent.updateSensorCategories(systemStats)
+	agent.applySensorAliases(systemStats)
+
+	assert.Equal(t, map[string]string{
+		"CPU Package": system.SensorCategoryCpu,
+		"pressure":    system.SensorCategoryCustom,
+	}, systemStats.SensorCategories)
+
+	// no sensors leaves categories empty
+	systemStats = &system.Stats{}
+	agent.updateSensorCategories(systemStats)
+	assert.Nil(t, systemStats.SensorCategories)
+}
+
 // Test updateGenericSensors
 func TestUpdateGenericSensors(t *testing.T) {
+	sensorPath := filepath.Join(t.TempDir(), "test_sensor")
+	require.NoError(t, os.WriteFile(sensorPath, []byte("50\n"), 0644))
+
 	agent := &Agent{
+		clock: clock.New(),
 		sensorConfig: &SensorConfig{
 			genericSensors: map[string]GenericSensorConfig{
 				"test_sensor": {
@@ -722,29 +907,24 @@ func TestUpdateGenericSensors(t *testing.T) {
 					Unit:    "test_unit",
 					Maximum: 100,
 					Minimum: 0,
+					Path:    sensorPath,
+				},
+				"missing_sensor": {
+					Name: "missing_sensor",
+					Path: filepath.Join(t.TempDir(), "missing_sensor"),
 				},
 			},
 		},
+		precision: newPrecisionConfig(),
 	}
 
-	// Mock the collectGenericSensorValue method for testing
-	originalCollectGenericSensorValue := agent.collectGenericSensorValue
-	agent.collectGenericSensorValue = func(sensorName string, config GenericSensorConfig) (float64, error) {
-		if sensorName == "test_sensor" {
-			return 50.0, nil
-		}
-		return 0, fmt.Errorf("sensor not found")
-	}
-	defer func() {
-		agent.collectGenericSensorValue = originalCollectGenericSensorValue
-	}()
-
 	systemStats := &system.Stats{}
-	agent.updateGenericSensors(systemStats)
+	agent.updateGenericSensors(context.Background(), systemStats)
 
 	assert.NotNil(t, systemStats.GenericSensors)
 	assert.Contains(t, systemStats.GenericSensors, "test_sensor")
-	
+	assert.NotContains(t, systemStats.GenericSensors, "missing_sensor")
+
 	sensor := systemStats.GenericSensors["test_sensor"]
 	assert.Equal(t, 50.0, sensor.Value)
 	assert.Equal(t, "test_unit", sensor.Unit)
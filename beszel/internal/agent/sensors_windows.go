@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/shirou/gopsutil/v4/sensors"
+	"github.com/yusufpapurcu/wmi"
 )
 
 // Note: This is always called from Agent.gatherStats() which holds Agent.Lock(),
@@ -140,8 +141,8 @@ func (lhm *lhmProcess) cleanupProcess() {
 
 func (lhm *lhmProcess) getTemps(ctx context.Context) (temps []sensors.TemperatureStat, err error) {
 	if lhm.stoppedNoSensors {
-		// Fall back to gopsutil if we can't get sensors from LHM
-		return sensors.TemperaturesWithContext(ctx)
+		// Fall back to WMI if we can't get sensors from LHM
+		return getWmiTemps(ctx)
 	}
 
 	// Start process if it's not running
@@ -205,7 +206,7 @@ func (lhm *lhmProcess) getTemps(ctx context.Context) (temps []sensors.Temperatur
 			slog.Warn(errNoSensors.Error())
 			lhm.cleanup()
 		}
-		return sensors.TemperaturesWithContext(ctx)
+		return getWmiTemps(ctx)
 	}
 
 	lhm.consecutiveNoSensors = 0
@@ -228,16 +229,34 @@ func getSensorTemps(ctx context.Context) (temps []sensors.TemperatureStat, err e
 	})
 
 	if err != nil {
-		return temps, fmt.Errorf("failed to initialize lhm: %w", err)
+		slog.Debug("Failed to initialize lhm", "err", err)
 	}
 
 	if beszelLhm == nil {
-		return temps, fmt.Errorf("lhm not available")
+		return getWmiTemps(ctx)
 	}
 
 	return beszelLhm.getTemps(ctx)
 }
 
+// getWmiTemps reads temperatures from the WMI namespace of a running
+// LibreHardwareMonitor or OpenHardwareMonitor if there is one, otherwise from
+// the ACPI thermal zones, which most hardware doesn't report.
+func getWmiTemps(ctx context.Context) ([]sensors.TemperatureStat, error) {
+	const query = "SELECT Name, Parent, Value FROM Sensor WHERE SensorType = 'Temperature'"
+	for _, namespace := range lhmWmiNamespaces {
+		var lhmSensors []lhmWmiSensor
+		// fails with an invalid namespace error if LHM isn't running
+		if err := wmi.QueryNamespace(query, &lhmSensors, namespace); err != nil {
+			continue
+		}
+		if temps := lhmSensorTemps(lhmSensors); len(temps) > 0 {
+			return temps, nil
+		}
+	}
+	return sensors.TemperaturesWithContext(ctx)
+}
+
 // cleanup terminates the process and closes resources
 func (lhm *lhmProcess) cleanup() {
 	lhm.cleanupProcess()
@@ -35,11 +35,15 @@ var hubVersions map[string]semver.Version
 // and begins listening for connections. Returns an error if the server
 // is already running or if there's an issue starting the server.
 func (a *Agent) StartServer(opts ServerOptions) error {
-	if a.server != nil {
+	if a.server != nil || a.tlsListener != nil {
 		return errors.New("server already started")
 	}
 
-	slog.Info("Starting SSH server", "addr", opts.Addr, "network", opts.Network)
+	if a.mtls != nil {
+		slog.Info("Starting TLS server", "addr", opts.Addr, "network", opts.Network)
+	} else {
+		slog.Info("Starting SSH server", "addr", opts.Addr, "network", opts.Network)
+	}
 
 	if opts.Network == "unix" {
 		// remove existing socket file if it exists
@@ -55,6 +59,11 @@ func (a *Agent) StartServer(opts ServerOptions) error {
 	}
 	defer ln.Close()
 
+	// serve stats over mTLS instead of SSH if TLS_CA is set
+	if a.mtls != nil {
+		return a.serveTLS(ln)
+	}
+
 	// base config (limit to allowed algorithms)
 	config := &gossh.ServerConfig{
 		ServerVersion: fmt.Sprintf("SSH-2.0-%s_%s", beszel.AppName, beszel.Version),
@@ -211,6 +220,13 @@ func GetNetwork(addr string) string {
 // StopServer stops the SSH server if it's running.
 // It returns an error if the server is not running or if there's an error stopping it.
 func (a *Agent) StopServer() error {
+	if a.tlsListener != nil {
+		slog.Info("Stopping TLS server")
+		_ = a.tlsListener.Close()
+		a.tlsListener = nil
+		a.connectionManager.eventChan <- SSHDisconnect
+		return nil
+	}
 	if a.server == nil {
 		return errors.New("SSH server not running")
 	}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timeout for each systemctl call
+const systemctlTimeout = 5 * time.Second
+
+// serviceMonitor reports the state of the systemd units listed in SERVICES,
+// e.g. SERVICES=nginx,postgresql. Units without a suffix are services.
+type serviceMonitor struct {
+	systemctl string
+	units     []string
+	cache     intervalCache[[]system.ServiceUnit]
+}
+
+// newServiceMonitor creates a service monitor if SERVICES is set and systemctl is installed.
+func newServiceMonitor() *serviceMonitor {
+	value, _ := GetEnv("SERVICES")
+	var units []string
+	for unit := range strings.SplitSeq(value, ",") {
+		if unit = strings.TrimSpace(unit); unit == "" {
+			continue
+		}
+		if !strings.Contains(unit, ".") {
+			unit += ".service"
+		}
+		units = append(units, unit)
+	}
+	if len(units) == 0 {
+		return nil
+	}
+	systemctl, err := exec.LookPath("systemctl")
+	if err != nil {
+		slog.Warn("SERVICES is set but systemctl was not found", "err", err)
+		return nil
+	}
+	return &serviceMonitor{systemctl: systemctl, units: units}
+}
+
+// collect returns the state of each unit, in the order of SERVICES.
+func (m *serviceMonitor) collect(ctx context.Context) ([]system.ServiceUnit, error) {
+	ctx, cancel := context.WithTimeout(ctx, systemctlTimeout)
+	defer cancel()
+	args := append([]string{"show", "--property=Id,LoadState,ActiveState,SubState,NRestarts", "--"}, m.units...)
+	output, err := exec.CommandContext(ctx, m.systemctl, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	units := parseSystemctlShow(string(output))
+	if len(units) != len(m.units) {
+		return nil, fmt.Errorf("systemctl returned %d units, expected %d", len(units), len(m.units))
+	}
+	return units, nil
+}
+
+// parseSystemctlShow parses systemctl show output: a block of key=value lines
+// for each unit, separated by blank lines. Units that don't exist are reported
+// with the "not-found" state.
+func parseSystemctlShow(output string) []system.ServiceUnit {
+	var units []system.ServiceUnit
+	props := make(map[string]string)
+	flush := func() {
+		if len(props) == 0 {
+			return
+		}
+		unit := system.ServiceUnit{
+			Name:     strings.TrimSuffix(props["Id"], ".service"),
+			State:    props["ActiveState"],
+			SubState: props["SubState"],
+		}
+		if props["LoadState"] == "not-found" {
+			unit.State = "not-found"
+		}
+		unit.Restarts, _ = strconv.ParseUint(props["NRestarts"], 10, 32)
+		units = append(units, unit)
+		clear(props)
+	}
+	for line := range strings.SplitSeq(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			flush()
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = value
+		}
+	}
+	flush()
+	return units
+}
+
+// updateServices adds the state of the watched units to the system info,
+// and the number of failed units to the stats.
+func (a *Agent) updateServices(ctx context.Context, systemStats *system.Stats) {
+	if a.serviceMonitor == nil {
+		return
+	}
+	units, err := a.serviceMonitor.cache.get(a.intervals.get(intervalServices), func() ([]system.ServiceUnit, error) {
+		return a.serviceMonitor.collect(ctx)
+	})
+	a.setCollectorStatus(collectorServices, err)
+	if err != nil {
+		slog.Debug("Error reading systemd units", "err", err)
+	}
+	a.systemInfo.Services = units
+	failed := 0
+	for _, unit := range units {
+		if unit.Failed() {
+			failed++
+		}
+	}
+	systemStats.ServicesFailed = float64(failed)
+}
@@ -0,0 +1,86 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSystemctlShow = `Id=nginx.service
+NRestarts=0
+LoadState=loaded
+ActiveState=active
+SubState=running
+
+Id=postgresql.service
+NRestarts=3
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+
+Id=missing.service
+NRestarts=0
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+`
+
+func TestParseSystemctlShow(t *testing.T) {
+	units := parseSystemctlShow(testSystemctlShow)
+	assert.Equal(t, []system.ServiceUnit{
+		{Name: "nginx", State: "active", SubState: "running"},
+		{Name: "postgresql", State: "failed", SubState: "failed", Restarts: 3},
+		{Name: "missing", State: "not-found", SubState: "dead"},
+	}, units)
+	assert.Empty(t, parseSystemctlShow(""))
+}
+
+func TestNewServiceMonitor(t *testing.T) {
+	t.Setenv("SERVICES", "")
+	assert.Nil(t, newServiceMonitor())
+
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		t.Skip("systemctl not installed")
+	}
+	t.Setenv("SERVICES", "nginx, cron.timer,,")
+	monitor := newServiceMonitor()
+	require.NotNil(t, monitor)
+	assert.Equal(t, []string{"nginx.service", "cron.timer"}, monitor.units)
+}
+
+func TestUpdateServices(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as systemctl")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "show.txt"), []byte(testSystemctlShow), 0o644))
+	systemctl := filepath.Join(dir, "systemctl")
+	require.NoError(t, os.WriteFile(systemctl, []byte("#!/bin/sh\ncat \""+dir+"/show.txt\"\n"), 0o755))
+
+	a := &Agent{serviceMonitor: &serviceMonitor{
+		systemctl: systemctl,
+		units:     []string{"nginx.service", "postgresql.service", "missing.service"},
+	}}
+	var stats system.Stats
+	a.updateServices(context.Background(), &stats)
+	assert.Equal(t, system.CollectorOk, a.collectorStatus[collectorServices].Kind)
+	require.Len(t, a.systemInfo.Services, 3)
+	assert.Equal(t, 1.0, stats.ServicesFailed)
+
+	// a unit count mismatch is an error
+	a.serviceMonitor.units = a.serviceMonitor.units[:2]
+	stats = system.Stats{}
+	a.updateServices(context.Background(), &stats)
+	assert.NotEqual(t, system.CollectorOk, a.collectorStatus[collectorServices].Kind)
+	assert.Empty(t, a.systemInfo.Services)
+}
@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// Timeout for each smartctl call
+	smartTimeout = 10 * time.Second
+	// ATA attribute IDs of reallocated and pending sectors
+	smartAttrReallocated = 5
+	smartAttrPending     = 197
+)
+
+// smartMonitor reports the S.M.A.R.T. health of disks with smartctl. Enabled with
+// SMART=true; SMART_DEVICES limits it to a comma separated list of devices.
+// Not thread safe since we only access from gatherStats which is already locked.
+type smartMonitor struct {
+	smartctl string
+	devices  []smartDevice // from SMART_DEVICES, scanned if empty
+	lastRun  time.Time
+	disks    []system.SmartDisk
+	err      error
+}
+
+// smartDevice is a device to query, with the type from smartctl --scan
+type smartDevice struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// smartctlOutput is the part of smartctl --json output we use
+type smartctlOutput struct {
+	Device      smartDevice `json:"device"`
+	ModelName   string      `json:"model_name"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime struct {
+		Hours uint64 `json:"hours"`
+	} `json:"power_on_time"`
+	AtaSmartAttributes struct {
+		Table []struct {
+			Id  int `json:"id"`
+			Raw struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NvmeSmartHealth *struct {
+		MediaErrors uint64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// newSmartMonitor creates a SMART monitor if SMART is "true" and smartctl is installed.
+func newSmartMonitor() *smartMonitor {
+	if enabled, _ := GetEnv("SMART"); enabled != "true" {
+		return nil
+	}
+	smartctl, err := exec.LookPath("smartctl")
+	if err != nil {
+		slog.Warn("SMART is enabled but smartctl was not found", "err", err)
+		return nil
+	}
+	monitor := &smartMonitor{smartctl: smartctl}
+	if devices, _ := GetEnv("SMART_DEVICES"); devices != "" {
+		for name := range strings.SplitSeq(devices, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				monitor.devices = append(monitor.devices, smartDevice{Name: name})
+			}
+		}
+	}
+	slog.Info("SMART", "devices", monitor.devices)
+	return monitor
+}
+
+// collect returns the health of each disk, running smartctl at most once per interval.
+func (m *smartMonitor) collect(ctx context.Context, interval time.Duration) ([]system.SmartDisk, error) {
+	if !m.lastRun.IsZero() && time.Since(m.lastRun) < interval {
+		return m.disks, m.err
+	}
+	m.lastRun = time.Now()
+	devices := m.devices
+	if len(devices) == 0 {
+		var scan struct {
+			Devices []smartDevice `json:"devices"`
+		}
+		output, err := m.run(ctx, "--scan", "--json")
+		if err == nil {
+			err = json.Unmarshal(output, &scan)
+		}
+		if err != nil {
+			m.err = err
+			return m.disks, err
+		}
+		devices = scan.Devices
+	}
+	previous := m.disks
+	m.disks, m.err = nil, nil
+	for _, device := range devices {
+		args := []string{"--all", "--json", "--nocheck=standby"}
+		if device.Type != "" {
+			args = append(args, "--device="+device.Type)
+		}
+		output, err := m.run(ctx, append(args, device.Name)...)
+		disk, ok := parseSmartctl(output)
+		if !ok {
+			// keep the last result of disks in standby
+			name := strings.TrimPrefix(device.Name, "/dev/")
+			for _, last := range previous {
+				if last.Name == name {
+					m.disks = append(m.disks, last)
+					break
+				}
+			}
+			if err != nil && m.err == nil {
+				m.err = err
+			}
+			continue
+		}
+		m.disks = append(m.disks, disk)
+	}
+	return m.disks, m.err
+}
+
+// run runs smartctl. smartctl exits with a bit mask of problems it found, so
+// its output is returned with the error if there is any.
+func (m *smartMonitor) run(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, smartTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, m.smartctl, args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(output) > 0 {
+		return output, nil
+	}
+	return output, err
+}
+
+// parseSmartctl parses smartctl --all --json output. Returns false if the
+// output has no health status, e.g. if the disk is in standby.
+func parseSmartctl(data []byte) (system.SmartDisk, bool) {
+	var output smartctlOutput
+	if err := json.Unmarshal(data, &output); err != nil || output.SmartStatus == nil {
+		return system.SmartDisk{}, false
+	}
+	disk := system.SmartDisk{
+		Name:         strings.TrimPrefix(output.Device.Name, "/dev/"),
+		Model:        output.ModelName,
+		Passed:       output.SmartStatus.Passed,
+		Temperature:  output.Temperature.Current,
+		PowerOnHours: output.PowerOnTime.Hours,
+	}
+	for _, attr := range output.AtaSmartAttributes.Table {
+		switch attr.Id {
+		case smartAttrReallocated:
+			disk.Reallocated = attr.Raw.Value
+		case smartAttrPending:
+			disk.PendingSectors = attr.Raw.Value
+		}
+	}
+	if output.NvmeSmartHealth != nil {
+		disk.MediaErrors = output.NvmeSmartHealth.MediaErrors
+	}
+	return disk, true
+}
+
+// updateSmart adds the SMART health of disks to the system info
+func (a *Agent) updateSmart(ctx context.Context, systemStats *system.Stats) {
+	if a.smartMonitor == nil {
+		return
+	}
+	disks, err := a.smartMonitor.collect(ctx, a.intervals.get(intervalSmart))
+	a.setCollectorStatus(collectorSmart, err)
+	if err != nil {
+		slog.Debug("Error reading SMART data", "err", err)
+	}
+	a.systemInfo.Smart = disks
+	failing := 0
+	for _, disk := range disks {
+		if !disk.Passed {
+			failing++
+		}
+	}
+	systemStats.SmartFailing = float64(failing)
+}
@@ -0,0 +1,97 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSmartctlAta = `{
+  "device": {"name": "/dev/sda", "type": "sat"},
+  "model_name": "WDC WD40EFRX",
+  "smart_status": {"passed": false},
+  "temperature": {"current": 38},
+  "power_on_time": {"hours": 41234},
+  "ata_smart_attributes": {"table": [
+    {"id": 5, "name": "Reallocated_Sector_Ct", "raw": {"value": 8}},
+    {"id": 9, "name": "Power_On_Hours", "raw": {"value": 41234}},
+    {"id": 197, "name": "Current_Pending_Sector", "raw": {"value": 3}}
+  ]}
+}`
+
+const testSmartctlNvme = `{
+  "device": {"name": "/dev/nvme0", "type": "nvme"},
+  "model_name": "Samsung SSD 980",
+  "smart_status": {"passed": true},
+  "temperature": {"current": 45},
+  "power_on_time": {"hours": 1200},
+  "nvme_smart_health_information_log": {"media_errors": 2}
+}`
+
+func TestParseSmartctl(t *testing.T) {
+	disk, ok := parseSmartctl([]byte(testSmartctlAta))
+	require.True(t, ok)
+	assert.Equal(t, system.SmartDisk{
+		Name: "sda", Model: "WDC WD40EFRX", Passed: false, Temperature: 38,
+		PowerOnHours: 41234, PendingSectors: 3, Reallocated: 8,
+	}, disk)
+
+	disk, ok = parseSmartctl([]byte(testSmartctlNvme))
+	require.True(t, ok)
+	assert.Equal(t, system.SmartDisk{
+		Name: "nvme0", Model: "Samsung SSD 980", Passed: true, Temperature: 45,
+		PowerOnHours: 1200, MediaErrors: 2,
+	}, disk)
+
+	_, ok = parseSmartctl([]byte(`{"device": {"name": "/dev/sdb"}, "smartctl": {"exit_status": 2}}`))
+	assert.False(t, ok, "disk in standby")
+	_, ok = parseSmartctl([]byte("not json"))
+	assert.False(t, ok)
+}
+
+func TestUpdateSmart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as smartctl")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sda.json"), []byte(testSmartctlAta), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nvme0.json"), []byte(testSmartctlNvme), 0o644))
+	// prints the scan, or the file of the device (last argument) and exits with
+	// status 8 like smartctl does for failing disks
+	script := `#!/bin/sh
+if [ "$1" = "--scan" ]; then
+  echo '{"devices": [{"name": "/dev/sda", "type": "sat"}, {"name": "/dev/nvme0", "type": "nvme"}]}'
+  exit 0
+fi
+for last; do :; done
+cat "` + dir + `/$(basename "$last").json"
+exit 8
+`
+	smartctl := filepath.Join(dir, "smartctl")
+	require.NoError(t, os.WriteFile(smartctl, []byte(script), 0o755))
+
+	a := &Agent{smartMonitor: &smartMonitor{smartctl: smartctl}}
+	var stats system.Stats
+	a.updateSmart(context.Background(), &stats)
+	assert.Equal(t, system.CollectorOk, a.collectorStatus[collectorSmart].Kind)
+	require.Len(t, a.systemInfo.Smart, 2)
+	assert.Equal(t, "sda", a.systemInfo.Smart[0].Name)
+	assert.Equal(t, "nvme0", a.systemInfo.Smart[1].Name)
+	assert.Equal(t, 1.0, stats.SmartFailing)
+
+	// results are cached between runs
+	require.NoError(t, os.Remove(smartctl))
+	stats = system.Stats{}
+	a.updateSmart(context.Background(), &stats)
+	assert.Len(t, a.systemInfo.Smart, 2)
+	assert.Equal(t, 1.0, stats.SmartFailing)
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+// SMC data types, as four character codes padded with spaces
+const (
+	smcTypeFlt  = "flt " // float32, little endian (Apple Silicon)
+	smcTypeSp78 = "sp78" // signed 7.8 fixed point, big endian (Intel)
+	smcTypeFpe2 = "fpe2" // unsigned 14.2 fixed point, big endian (Intel fans)
+	smcTypeUi8  = "ui8 "
+	smcTypeUi16 = "ui16"
+	smcTypeUi32 = "ui32"
+)
+
+// SMC keys of the fans and system power
+const (
+	smcKeyCount       = "#KEY" // number of keys
+	smcKeyFanCount    = "FNum"
+	smcKeyFanSpeed    = "F%dAc" // actual speed of a fan in RPM
+	smcKeySystemPower = "PSTR"  // total system power in watts
+)
+
+// Prefixes of SMC temperature keys and the names to report them with. The
+// remaining characters of a key number the sensor.
+var smcTempPrefixes = []struct{ prefix, name string }{
+	{"Tp", "cpu_pcore"}, // Apple Silicon performance cores
+	{"Te", "cpu_ecore"}, // Apple Silicon efficiency cores
+	{"TC", "cpu"},
+	{"Tg", "gpu"},
+	{"TG", "gpu"},
+	{"Tm", "memory"},
+	{"TM", "memory"},
+	{"TB", "battery"},
+	{"TH", "ssd"},
+	{"TA", "ambient"},
+	{"TW", "wifi"},
+}
+
+// smcValue is the raw value of an SMC key
+type smcValue struct {
+	dataType string
+	bytes    []byte
+}
+
+// float decodes the value as a number. Returns false for unsupported types.
+func (v smcValue) float() (float64, bool) {
+	b := v.bytes
+	switch {
+	case v.dataType == smcTypeFlt && len(b) >= 4:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), true
+	case v.dataType == smcTypeSp78 && len(b) >= 2:
+		return float64(int16(binary.BigEndian.Uint16(b))) / 256, true
+	case v.dataType == smcTypeFpe2 && len(b) >= 2:
+		return float64(binary.BigEndian.Uint16(b)) / 4, true
+	case v.dataType == smcTypeUi8 && len(b) >= 1:
+		return float64(b[0]), true
+	case v.dataType == smcTypeUi16 && len(b) >= 2:
+		return float64(binary.BigEndian.Uint16(b)), true
+	case v.dataType == smcTypeUi32 && len(b) >= 4:
+		return float64(binary.BigEndian.Uint32(b)), true
+	}
+	return 0, false
+}
+
+// isSmcTempKey returns true if the key is a temperature sensor of a type that
+// can be decoded. Other keys starting with T are mostly timers and counters.
+func isSmcTempKey(key, dataType string) bool {
+	return len(key) == 4 && key[0] == 'T' && (dataType == smcTypeFlt || dataType == smcTypeSp78)
+}
+
+// smcTempName returns the sensor name of a temperature key, e.g. "cpu_pcore_tp01".
+func smcTempName(key string) string {
+	for _, p := range smcTempPrefixes {
+		if strings.HasPrefix(key, p.prefix) {
+			return p.name + "_" + strings.ToLower(key)
+		}
+	}
+	return "smc_" + strings.ToLower(key)
+}
+
+// smcKeyCode converts a four character key to the integer the SMC expects
+func smcKeyCode(key string) uint32 {
+	if len(key) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32([]byte(key))
+}
+
+// smcKeyString converts an SMC key or data type code to its four characters
+func smcKeyString(code uint32) string {
+	return string(binary.BigEndian.AppendUint32(nil, code))
+}
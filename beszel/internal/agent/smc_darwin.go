@@ -0,0 +1,245 @@
+//go:build darwin
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+	"github.com/shirou/gopsutil/v4/sensors"
+)
+
+// IOKit is loaded at runtime so the agent builds without cgo
+const ioKitPath = "/System/Library/Frameworks/IOKit.framework/IOKit"
+
+// Selectors of the AppleSMC user client
+const (
+	smcHandleYPCEvent  = 2
+	smcCmdReadKey      = 5
+	smcCmdKeyFromIndex = 8
+	smcCmdKeyInfo      = 9
+)
+
+// smcKeyData mirrors the 80 byte SMCKeyData_t struct of the AppleSMC driver.
+type smcKeyData struct {
+	key  uint32
+	vers struct {
+		major, minor, build, reserved uint8
+		release                       uint16
+	}
+	pLimitData struct {
+		version, length                 uint16
+		cpuPLimit, gpuPLimit, memPLimit uint32
+	}
+	keyInfo struct {
+		dataSize, dataType uint32
+		dataAttributes     uint8
+	}
+	result uint8
+	status uint8
+	data8  uint8
+	data32 uint32
+	bytes  [32]byte
+}
+
+// smcKeyInfo is the size and data type of an SMC key
+type smcKeyInfo struct {
+	size     uint32
+	dataType uint32
+}
+
+// smcConn is an open connection to the SMC, which reports the temperatures,
+// fan speeds and power draw of Intel and Apple Silicon Macs.
+type smcConn struct {
+	conn       uint32
+	callStruct func(conn, selector uint32, in *smcKeyData, inSize uintptr, out *smcKeyData, outSize *uintptr) int32
+	infos      map[string]smcKeyInfo
+	tempKeys   []string // temperature keys found when the connection was opened
+	fans       int
+}
+
+var (
+	smcReader     *smcConn
+	smcReaderErr  error
+	smcReaderOnce sync.Once
+)
+
+// getSmc opens the SMC connection once and returns it for the life of the agent.
+func getSmc() (*smcConn, error) {
+	smcReaderOnce.Do(func() {
+		smcReader, smcReaderErr = openSmc()
+		if smcReaderErr != nil {
+			slog.Debug("Not reading SMC sensors", "err", smcReaderErr)
+		}
+	})
+	return smcReader, smcReaderErr
+}
+
+// openSmc connects to the AppleSMC service and finds its temperature keys.
+func openSmc() (*smcConn, error) {
+	lib, err := purego.Dlopen(ioKitPath, purego.RTLD_LAZY|purego.RTLD_GLOBAL)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		ioServiceMatching           func(name string) uintptr
+		ioServiceGetMatchingService func(mainPort uint32, matching uintptr) uint32
+		ioServiceOpen               func(service, owningTask, connType uint32, connect *uint32) int32
+		ioObjectRelease             func(object uint32) int32
+		machTaskSelf                func() uint32
+	)
+	purego.RegisterLibFunc(&ioServiceMatching, lib, "IOServiceMatching")
+	purego.RegisterLibFunc(&ioServiceGetMatchingService, lib, "IOServiceGetMatchingService")
+	purego.RegisterLibFunc(&ioServiceOpen, lib, "IOServiceOpen")
+	purego.RegisterLibFunc(&ioObjectRelease, lib, "IOObjectRelease")
+	purego.RegisterLibFunc(&machTaskSelf, lib, "mach_task_self")
+
+	// the matching dictionary is consumed by IOServiceGetMatchingService
+	service := ioServiceGetMatchingService(0, ioServiceMatching("AppleSMC"))
+	if service == 0 {
+		return nil, errors.New("AppleSMC service not found")
+	}
+	defer ioObjectRelease(service)
+
+	smc := &smcConn{infos: make(map[string]smcKeyInfo)}
+	if ret := ioServiceOpen(service, machTaskSelf(), 0, &smc.conn); ret != 0 {
+		return nil, fmt.Errorf("IOServiceOpen failed: %#x", ret)
+	}
+	purego.RegisterLibFunc(&smc.callStruct, lib, "IOConnectCallStructMethod")
+
+	if err := smc.discover(); err != nil {
+		return nil, err
+	}
+	return smc, nil
+}
+
+// discover finds the temperature keys and the number of fans. Listing the keys
+// takes a call per key, so it's only done once.
+func (s *smcConn) discover() error {
+	value, err := s.read(smcKeyCount)
+	if err != nil {
+		return err
+	}
+	count, _ := value.float()
+	for i := range uint32(count) {
+		key, err := s.keyAt(i)
+		if err != nil || key[0] != 'T' {
+			continue
+		}
+		if info, err := s.keyInfo(key); err == nil && isSmcTempKey(key, smcKeyString(info.dataType)) {
+			s.tempKeys = append(s.tempKeys, key)
+		}
+	}
+	if value, err := s.read(smcKeyFanCount); err == nil {
+		fans, _ := value.float()
+		s.fans = int(fans)
+	}
+	slog.Debug("SMC", "keys", count, "temperatures", len(s.tempKeys), "fans", s.fans)
+	return nil
+}
+
+// call sends a command to the SMC and returns its response.
+func (s *smcConn) call(in *smcKeyData) (*smcKeyData, error) {
+	var out smcKeyData
+	outSize := unsafe.Sizeof(out)
+	if ret := s.callStruct(s.conn, smcHandleYPCEvent, in, unsafe.Sizeof(*in), &out, &outSize); ret != 0 {
+		return nil, fmt.Errorf("IOConnectCallStructMethod failed: %#x", ret)
+	}
+	if out.result != 0 {
+		return nil, fmt.Errorf("SMC error %d", out.result)
+	}
+	return &out, nil
+}
+
+// keyAt returns the key at an index, from zero to the value of #KEY.
+func (s *smcConn) keyAt(index uint32) (string, error) {
+	out, err := s.call(&smcKeyData{data8: smcCmdKeyFromIndex, data32: index})
+	if err != nil {
+		return "", err
+	}
+	return smcKeyString(out.key), nil
+}
+
+// keyInfo returns the size and data type of a key.
+func (s *smcConn) keyInfo(key string) (smcKeyInfo, error) {
+	if info, ok := s.infos[key]; ok {
+		return info, nil
+	}
+	out, err := s.call(&smcKeyData{key: smcKeyCode(key), data8: smcCmdKeyInfo})
+	if err != nil {
+		return smcKeyInfo{}, err
+	}
+	info := smcKeyInfo{size: out.keyInfo.dataSize, dataType: out.keyInfo.dataType}
+	s.infos[key] = info
+	return info, nil
+}
+
+// read returns the value of a key.
+func (s *smcConn) read(key string) (smcValue, error) {
+	info, err := s.keyInfo(key)
+	if err != nil {
+		return smcValue{}, err
+	}
+	in := &smcKeyData{key: smcKeyCode(key), data8: smcCmdReadKey}
+	in.keyInfo.dataSize = info.size
+	out, err := s.call(in)
+	if err != nil {
+		return smcValue{}, err
+	}
+	return smcValue{
+		dataType: smcKeyString(info.dataType),
+		bytes:    out.bytes[:min(info.size, uint32(len(out.bytes)))],
+	}, nil
+}
+
+// temperatures reads the temperature keys, skipping sensors that aren't
+// connected or report values that can't be temperatures.
+func (s *smcConn) temperatures() []sensors.TemperatureStat {
+	temps := make([]sensors.TemperatureStat, 0, len(s.tempKeys))
+	for _, key := range s.tempKeys {
+		value, err := s.read(key)
+		if err != nil {
+			continue
+		}
+		if temp, ok := value.float(); ok && temp > 0 && temp < 150 {
+			temps = append(temps, sensors.TemperatureStat{SensorKey: smcTempName(key), Temperature: temp})
+		}
+	}
+	return temps
+}
+
+// fansAndPower returns the speed of each fan and the total system power.
+func (s *smcConn) fansAndPower() map[string]system.SensorData {
+	readings := make(map[string]system.SensorData, s.fans+1)
+	for i := range s.fans {
+		if value, err := s.read(fmt.Sprintf(smcKeyFanSpeed, i)); err == nil {
+			if rpm, ok := value.float(); ok && rpm >= 0 {
+				readings[fmt.Sprintf("fan%d", i)] = system.SensorData{Value: rpm, Unit: "RPM"}
+			}
+		}
+	}
+	if value, err := s.read(smcKeySystemPower); err == nil {
+		if watts, ok := value.float(); ok && watts > 0 {
+			readings["system_power"] = system.SensorData{Value: watts, Unit: "W"}
+		}
+	}
+	return readings
+}
+
+// updatePlatformSensors adds the fan speeds and system power reported by the SMC.
+func (a *Agent) updatePlatformSensors(ctx context.Context, systemStats *system.Stats) {
+	if a.sensorConfig.skipCollection {
+		return
+	}
+	smc, err := getSmc()
+	if err != nil {
+		return
+	}
+	a.addSensorReadings(systemStats, smc.fansAndPower())
+}
@@ -0,0 +1,47 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmcValueFloat(t *testing.T) {
+	tests := []struct {
+		value    smcValue
+		expected float64
+		ok       bool
+	}{
+		{smcValue{smcTypeFlt, []byte{0x00, 0x00, 0x5a, 0x42}}, 54.5, true},
+		{smcValue{smcTypeSp78, []byte{0x2d, 0x80}}, 45.5, true},
+		{smcValue{smcTypeFpe2, []byte{0x1f, 0x40}}, 2000, true},
+		{smcValue{smcTypeUi8, []byte{2}}, 2, true},
+		{smcValue{smcTypeUi32, []byte{0, 0, 0x05, 0xdc}}, 1500, true},
+		{smcValue{smcTypeFlt, []byte{0x00, 0x00}}, 0, false},
+		{smcValue{"flag", []byte{1}}, 0, false},
+	}
+	for _, tt := range tests {
+		value, ok := tt.value.float()
+		assert.Equal(t, tt.ok, ok, tt.value.dataType)
+		assert.Equal(t, tt.expected, value, tt.value.dataType)
+	}
+}
+
+func TestSmcKeys(t *testing.T) {
+	assert.Equal(t, uint32(0x23_4b_45_59), smcKeyCode("#KEY"))
+	assert.Zero(t, smcKeyCode("KEY"))
+	assert.Equal(t, "flt ", smcKeyString(smcKeyCode("flt ")))
+
+	assert.True(t, isSmcTempKey("Tp01", smcTypeFlt))
+	assert.True(t, isSmcTempKey("TC0P", smcTypeSp78))
+	assert.False(t, isSmcTempKey("Tp01", smcTypeUi32))
+	assert.False(t, isSmcTempKey("F0Ac", smcTypeFlt))
+
+	assert.Equal(t, "cpu_pcore_tp01", smcTempName("Tp01"))
+	assert.Equal(t, "cpu_ecore_te05", smcTempName("Te05"))
+	assert.Equal(t, "gpu_tg0f", smcTempName("Tg0f"))
+	assert.Equal(t, "smc_ts0p", smcTempName("Ts0P"))
+}
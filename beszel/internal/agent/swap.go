@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+// swapIoStats keeps the swap counters of the previous collection
+type swapIoStats struct {
+	in, out uint64 // bytes swapped in and out since boot
+	time    time.Time
+}
+
+// updateSwapIo sets the bytes per second swapped in and out since the previous
+// collection. Active swapping, unlike swap that's used but idle, means the
+// system is short of memory.
+func (a *Agent) updateSwapIo(ctx context.Context, systemStats *system.Stats) {
+	swap, err := mem.SwapMemoryWithContext(ctx)
+	if err != nil {
+		slog.Debug("Error getting swap activity", "err", err)
+		return
+	}
+	now := time.Now()
+	prev := a.swapIo
+	a.swapIo = swapIoStats{in: swap.Sin, out: swap.Sout, time: now}
+	if prev.time.IsZero() {
+		return
+	}
+	msElapsed := uint64(now.Sub(prev.time).Milliseconds())
+	systemStats.SwapIo = [2]uint64{
+		bytesPerSecond(prev.in, swap.Sin, msElapsed),
+		bytesPerSecond(prev.out, swap.Sout, msElapsed),
+	}
+}
@@ -0,0 +1,31 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateSwapIo(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("swap counters are only read on linux")
+	}
+	a := &Agent{}
+	var stats system.Stats
+	// no rate without a previous collection
+	a.updateSwapIo(context.Background(), &stats)
+	assert.Zero(t, stats.SwapIo)
+	assert.False(t, a.swapIo.time.IsZero())
+
+	// counters lower than the previous ones count as no activity
+	a.swapIo = swapIoStats{in: ^uint64(0), out: ^uint64(0), time: time.Now().Add(-time.Second)}
+	a.updateSwapIo(context.Background(), &stats)
+	assert.Zero(t, stats.SwapIo)
+}
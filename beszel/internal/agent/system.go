@@ -4,6 +4,7 @@ import (
 	"beszel"
 	"beszel/internal/entities/system"
 	"bufio"
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -23,6 +24,7 @@ import (
 func (a *Agent) initializeSystemInfo() {
 	a.systemInfo.AgentVersion = beszel.Version
 	a.systemInfo.Hostname, _ = os.Hostname()
+	a.systemInfo.Labels = getLabels()
 
 	platform, _, version, _ := host.PlatformInformation()
 
@@ -58,8 +60,10 @@ func (a *Agent) initializeSystemInfo() {
 		}
 	}
 
-	// zfs
-	if _, err := getARCSize(); err == nil {
+	// zfs, unless ZFS_ARC=used counts the ARC as used memory like the kernel does
+	if arc, _ := GetEnv("ZFS_ARC"); arc == "used" {
+		slog.Debug("Counting ZFS ARC as used memory")
+	} else if _, err := getARCSize(); err == nil {
 		a.zfs = true
 	} else {
 		slog.Debug("Not monitoring ZFS ARC", "err", err)
@@ -67,33 +71,41 @@ func (a *Agent) initializeSystemInfo() {
 }
 
 // Returns current info, stats about the host system
-func (a *Agent) getSystemStats() system.Stats {
+func (a *Agent) getSystemStats(ctx context.Context) system.Stats {
 	systemStats := system.Stats{}
 
 	// cpu percent
-	cpuPct, err := cpu.Percent(0, false)
+	cpuPct, err := cpu.PercentWithContext(ctx, 0, false)
+	a.setCollectorStatus(collectorCpu, err)
 	if err != nil {
-		slog.Error("Error getting cpu percent", "err", err)
+		slog.Debug("Error getting cpu percent", "err", err)
 	} else if len(cpuPct) > 0 {
-		systemStats.Cpu = twoDecimals(cpuPct[0])
+		systemStats.Cpu = a.precision.round(metricCpu, cpuPct[0])
 	}
 
+	// per-core usage, frequency and throttling
+	a.updateCpuCores(ctx, &systemStats)
+
 	// load average
-	if avgstat, err := load.Avg(); err == nil {
+	avgstat, err := load.AvgWithContext(ctx)
+	a.setCollectorStatus(collectorLoad, err)
+	if err == nil {
 		// TODO: remove these in future release in favor of load avg array
 		systemStats.LoadAvg[0] = avgstat.Load1
 		systemStats.LoadAvg[1] = avgstat.Load5
 		systemStats.LoadAvg[2] = avgstat.Load15
 		slog.Debug("Load average", "5m", avgstat.Load5, "15m", avgstat.Load15)
 	} else {
-		slog.Error("Error getting load average", "err", err)
+		slog.Debug("Error getting load average", "err", err)
 	}
 
 	// memory
-	if v, err := mem.VirtualMemory(); err == nil {
+	v, err := mem.VirtualMemoryWithContext(ctx)
+	a.setCollectorStatus(collectorMemory, err)
+	if err == nil {
 		// swap
-		systemStats.Swap = bytesToGigabytes(v.SwapTotal)
-		systemStats.SwapUsed = bytesToGigabytes(v.SwapTotal - v.SwapFree - v.SwapCached)
+		systemStats.Swap = a.precision.gigabytes(metricMemory, v.SwapTotal)
+		systemStats.SwapUsed = a.precision.gigabytes(metricMemory, v.SwapTotal-v.SwapFree-v.SwapCached)
 		// cache + buffers value for default mem calculation
 		cacheBuff := v.Total - v.Free - v.Used
 		// htop memory calculation overrides
@@ -103,33 +115,62 @@ func (a *Agent) getSystemStats() system.Stats {
 			v.Used = v.Total - (v.Free + cacheBuff)
 			v.UsedPercent = float64(v.Used) / float64(v.Total) * 100.0
 		}
+		// cgroup memory calculation reports the limit and usage of the agent's
+		// cgroup instead, if it has a limit below host memory
+		inCgroup := false
+		if a.memCalc == "cgroup" {
+			if cg, err := readCgroupMemory(cgroupPath, procCgroupPath); err != nil {
+				slog.Debug("Not using cgroup memory", "err", err)
+			} else if cg.limit < v.Total {
+				cacheBuff = cg.applyTo(v)
+				inCgroup = true
+			}
+		}
 		// subtract ZFS ARC size from used memory and add as its own category
-		if a.zfs {
+		if a.zfs && !inCgroup {
 			if arcSize, _ := getARCSize(); arcSize > 0 && arcSize < v.Used {
 				v.Used = v.Used - arcSize
 				v.UsedPercent = float64(v.Used) / float64(v.Total) * 100.0
-				systemStats.MemZfsArc = bytesToGigabytes(arcSize)
+				// the ARC shrinks when memory is needed, but the kernel doesn't count it as available
+				v.Available += arcSize
+				systemStats.MemZfsArc = a.precision.gigabytes(metricMemory, arcSize)
 			}
 		}
-		systemStats.Mem = bytesToGigabytes(v.Total)
-		systemStats.MemBuffCache = bytesToGigabytes(cacheBuff)
-		systemStats.MemUsed = bytesToGigabytes(v.Used)
-		systemStats.MemPct = twoDecimals(v.UsedPercent)
+		systemStats.MemCached = a.precision.gigabytes(metricMemory, v.Cached)
+		systemStats.MemBuffers = a.precision.gigabytes(metricMemory, v.Buffers)
+		systemStats.MemAvailable = a.precision.gigabytes(metricMemory, min(v.Available, v.Total))
+		systemStats.Mem = a.precision.gigabytes(metricMemory, v.Total)
+		systemStats.MemBuffCache = a.precision.gigabytes(metricMemory, cacheBuff)
+		systemStats.MemUsed = a.precision.gigabytes(metricMemory, v.Used)
+		systemStats.MemPct = a.precision.round(metricMemory, v.UsedPercent)
+		// hugepages are preallocated, mostly by databases and VMs, and count as used
+		if v.HugePagesTotal > 0 {
+			systemStats.MemHugePages = a.precision.gigabytes(metricMemory, v.HugePagesTotal*v.HugePageSize)
+			systemStats.MemHugePagesUsed = a.precision.gigabytes(metricMemory, (v.HugePagesTotal-v.HugePagesFree)*v.HugePageSize)
+		}
 	}
 
 	// disk usage
 	for _, stats := range a.fsStats {
-		if d, err := disk.Usage(stats.Mountpoint); err == nil {
-			stats.DiskTotal = bytesToGigabytes(d.Total)
-			stats.DiskUsed = bytesToGigabytes(d.Used)
+		mountpoint := stats.Mountpoint
+		d, err := runWithContext(ctx, "disk:"+mountpoint, func() (*disk.UsageStat, error) {
+			return disk.UsageWithContext(ctx, mountpoint)
+		})
+		if err != nil {
+			err = fmt.Errorf("%s: %w", mountpoint, err)
+		}
+		a.setCollectorStatus(collectorDisk, err)
+		if err == nil {
+			stats.DiskTotal = a.precision.gigabytes(metricDisk, d.Total)
+			stats.DiskUsed = a.precision.gigabytes(metricDisk, d.Used)
 			if stats.Root {
-				systemStats.DiskTotal = bytesToGigabytes(d.Total)
-				systemStats.DiskUsed = bytesToGigabytes(d.Used)
-				systemStats.DiskPct = twoDecimals(d.UsedPercent)
+				systemStats.DiskTotal = a.precision.gigabytes(metricDisk, d.Total)
+				systemStats.DiskUsed = a.precision.gigabytes(metricDisk, d.Used)
+				systemStats.DiskPct = a.precision.round(metricDisk, d.UsedPercent)
 			}
 		} else {
 			// reset stats if error (likely unmounted)
-			slog.Error("Error getting disk stats", "name", stats.Mountpoint, "err", err)
+			slog.Debug("Error getting disk stats", "err", err)
 			stats.DiskTotal = 0
 			stats.DiskUsed = 0
 			stats.TotalRead = 0
@@ -138,15 +179,17 @@ func (a *Agent) getSystemStats() system.Stats {
 	}
 
 	// disk i/o
-	if ioCounters, err := disk.IOCounters(a.fsNames...); err == nil {
+	ioCounters, err := disk.IOCountersWithContext(ctx, a.fsNames...)
+	a.setCollectorStatus(collectorDiskIo, err)
+	if err == nil {
 		for _, d := range ioCounters {
 			stats := a.fsStats[d.Name]
 			if stats == nil {
 				continue
 			}
 			secondsElapsed := time.Since(stats.Time).Seconds()
-			readPerSecond := bytesToMegabytes(float64(d.ReadBytes-stats.TotalRead) / secondsElapsed)
-			writePerSecond := bytesToMegabytes(float64(d.WriteBytes-stats.TotalWrite) / secondsElapsed)
+			readPerSecond := a.precision.megabytes(metricDiskIo, float64(d.ReadBytes-stats.TotalRead)/secondsElapsed)
+			writePerSecond := a.precision.megabytes(metricDiskIo, float64(d.WriteBytes-stats.TotalWrite)/secondsElapsed)
 			// check for invalid values and reset stats if so
 			if readPerSecond < 0 || writePerSecond < 0 || readPerSecond > 50_000 || writePerSecond > 50_000 {
 				slog.Warn("Invalid disk I/O. Resetting.", "name", d.Name, "read", readPerSecond, "write", writePerSecond)
@@ -174,19 +217,27 @@ func (a *Agent) getSystemStats() system.Stats {
 		// don't miss an interface that's been added after agent started in any circumstance
 		a.initializeNetIoStats()
 	}
-	if netIO, err := psutilNet.IOCounters(true); err == nil {
+	netIO, err := psutilNet.IOCountersWithContext(ctx, true)
+	a.setCollectorStatus(collectorNetwork, err)
+	if err == nil {
 		msElapsed := uint64(time.Since(a.netIoStats.Time).Milliseconds())
 		a.netIoStats.Time = time.Now()
 		totalBytesSent := uint64(0)
 		totalBytesRecv := uint64(0)
+		interfaceBandwidth := make(map[string][2]uint64, len(a.netInterfaces))
 		// sum all bytes sent and received
 		for _, v := range netIO {
 			// skip if not in valid network interfaces list
-			if _, exists := a.netInterfaces[v.Name]; !exists {
+			prev, exists := a.netInterfaces[v.Name]
+			if !exists {
 				continue
 			}
 			totalBytesSent += v.BytesSent
 			totalBytesRecv += v.BytesRecv
+			interfaceBandwidth[v.Name] = [2]uint64{
+				bytesPerSecond(prev[0], v.BytesSent, msElapsed),
+				bytesPerSecond(prev[1], v.BytesRecv, msElapsed),
+			}
 		}
 		// add to systemStats
 		var bytesSentPerSecond, bytesRecvPerSecond uint64
@@ -194,8 +245,8 @@ func (a *Agent) getSystemStats() system.Stats {
 			bytesSentPerSecond = (totalBytesSent - a.netIoStats.BytesSent) * 1000 / msElapsed
 			bytesRecvPerSecond = (totalBytesRecv - a.netIoStats.BytesRecv) * 1000 / msElapsed
 		}
-		networkSentPs := bytesToMegabytes(float64(bytesSentPerSecond))
-		networkRecvPs := bytesToMegabytes(float64(bytesRecvPerSecond))
+		networkSentPs := a.precision.megabytes(metricNetwork, float64(bytesSentPerSecond))
+		networkRecvPs := a.precision.megabytes(metricNetwork, float64(bytesRecvPerSecond))
 		// add check for issue (#150) where sent is a massive number
 		if networkSentPs > 10_000 || networkRecvPs > 10_000 {
 			slog.Warn("Invalid net stats. Resetting.", "sent", networkSentPs, "recv", networkRecvPs)
@@ -211,18 +262,54 @@ func (a *Agent) getSystemStats() system.Stats {
 			systemStats.NetworkSent = networkSentPs
 			systemStats.NetworkRecv = networkRecvPs
 			systemStats.Bandwidth[0], systemStats.Bandwidth[1] = bytesSentPerSecond, bytesRecvPerSecond
+			systemStats.NetworkInterfaces = interfaceBandwidth
 			// update netIoStats
 			a.netIoStats.BytesSent = totalBytesSent
 			a.netIoStats.BytesRecv = totalBytesRecv
+			for _, v := range netIO {
+				if _, exists := a.netInterfaces[v.Name]; exists {
+					a.netInterfaces[v.Name] = [2]uint64{v.BytesSent, v.BytesRecv}
+				}
+			}
 		}
 	}
 
 	// temperatures
 	// TODO: maybe refactor to methods on systemStats
-	a.updateTemperatures(&systemStats)
+	a.updateTemperatures(ctx, &systemStats)
 
 	// generic sensors
-	a.updateGenericSensors(&systemStats)
+	a.updateGenericSensors(ctx, &systemStats)
+
+	// fans and other sensors read along with the platform's temperatures
+	a.updatePlatformSensors(ctx, &systemStats)
+
+	// metrics of plugin collectors
+	a.updatePlugins(ctx, &systemStats)
+
+	// UPS data from NUT
+	a.updateNutSensors(ctx, &systemStats)
+
+	// swap activity
+	a.updateSwapIo(ctx, &systemStats)
+
+	// pressure stall information
+	a.updatePressure(&systemStats)
+
+	// software RAID status
+	a.updateRaid(ctx, &systemStats)
+
+	// disk health
+	a.updateSmart(ctx, &systemStats)
+
+	// watched systemd units
+	a.updateServices(ctx, &systemStats)
+
+	// local health checks
+	a.updateChecks(ctx, &systemStats)
+
+	// public IP
+	a.updatePublicIp()
 
 	// GPU data
 	if a.gpuManager != nil {
@@ -257,6 +344,10 @@ func (a *Agent) getSystemStats() system.Stats {
 		}
 	}
 
+	// tag sensors with categories and rename to their display aliases
+	a.updateSensorCategories(&systemStats)
+	a.applySensorAliases(&systemStats)
+
 	// update base system info
 	a.systemInfo.Cpu = systemStats.Cpu
 	a.systemInfo.LoadAvg = systemStats.LoadAvg
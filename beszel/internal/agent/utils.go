@@ -1,15 +1,51 @@
 package agent
 
-import "math"
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+)
 
 func bytesToMegabytes(b float64) float64 {
 	return twoDecimals(b / 1048576)
 }
 
-func bytesToGigabytes(b uint64) float64 {
-	return twoDecimals(float64(b) / 1073741824)
-}
-
 func twoDecimals(value float64) float64 {
 	return math.Round(value*100) / 100
 }
+
+// pendingCalls holds the keys of the calls of runWithContext that haven't returned yet
+var pendingCalls sync.Map
+
+// runWithContext runs fn in a goroutine and returns ctx.Err() if ctx is done first.
+// Used for blocking calls that can't be cancelled, like statfs on a stuck NFS mount.
+// The goroutine is left to finish on its own, so fn must not touch shared state.
+// Calls with the key of a call that is still blocked return a timeout error
+// without running fn, so a hung mount leaks at most one goroutine.
+func runWithContext[T any](ctx context.Context, key string, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if _, pending := pendingCalls.LoadOrStore(key, struct{}{}); pending {
+		return zero, newCollectorError(system.CollectorTimeout, fmt.Errorf("previous call of %s is still pending", key))
+	}
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		pendingCalls.Delete(key)
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
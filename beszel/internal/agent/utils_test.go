@@ -0,0 +1,69 @@
+//go:build testing
+// +build testing
+
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWithContext(t *testing.T) {
+	// returns the function result
+	value, err := runWithContext(context.Background(), "result", func() (int, error) {
+		return 42, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 42, value)
+
+	// returns the function error
+	_, err = runWithContext(context.Background(), "error", func() (int, error) {
+		return 0, errors.New("read failed")
+	})
+	assert.EqualError(t, err, "read failed")
+
+	// returns early if the function blocks past the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	start := time.Now()
+	_, err = runWithContext(ctx, "blocked", func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// skips the key while the blocked call is still pending
+	called := false
+	_, err = runWithContext(context.Background(), "blocked", func() (int, error) {
+		called = true
+		return 1, nil
+	})
+	assert.Equal(t, system.CollectorTimeout, getCollectorErrorKind(err))
+	assert.False(t, called)
+
+	// runs the key again once the blocked call returns
+	release <- struct{}{}
+	assert.Eventually(t, func() bool {
+		value, err := runWithContext(context.Background(), "blocked", func() (int, error) {
+			return 2, nil
+		})
+		return err == nil && value == 2
+	}, time.Second, time.Millisecond)
+
+	// does not run the function if the context is already done
+	called = false
+	_, err = runWithContext(ctx, "done", func() (int, error) {
+		called = true
+		return 1, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, called)
+}
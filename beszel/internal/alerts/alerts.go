@@ -2,9 +2,16 @@
 package alerts
 
 import (
+	"beszel/internal/clock"
+	"beszel/internal/hub/expirymap"
+	"beszel/internal/hub/storage"
+	"errors"
 	"fmt"
 	"net/mail"
 	"net/url"
+	"path"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +24,7 @@ import (
 type hubLike interface {
 	core.App
 	MakeLink(parts ...string) string
+	Storage() storage.Driver
 }
 
 type AlertManager struct {
@@ -24,6 +32,16 @@ type AlertManager struct {
 	alertQueue    chan alertTask
 	stopChan      chan struct{}
 	pendingAlerts sync.Map
+	clock         clock.Clock
+	providersMu   sync.RWMutex
+	providers     map[string]NotificationProvider // notification providers by URL scheme
+	rulesMu       sync.Mutex                      // serializes updates of the triggered systems of alert rules
+	flapsMu       sync.Mutex
+	flaps         map[string]*flapState // state changes of alerts by user, system and alert name
+	teamAlertsMu  sync.Mutex
+	teamAlerts    map[string]time.Time // when alerts were sent to teams by team, system and alert name
+
+	anomalyBaselines *expirymap.ExpiryMap[*anomalyBaseline] // learned baselines of Anomaly alerts by system and metric
 }
 
 type AlertMessageData struct {
@@ -32,21 +50,46 @@ type AlertMessageData struct {
 	Message  string
 	Link     string
 	LinkText string
+	// Optional details of the alert, for providers that take structured data
+	SystemID  string  // system id, which selects the maintenance windows
+	System    string  // system name
+	Alert     string  // alert name, e.g. "CPU" or "Status"
+	Resolved  bool    // whether the alert was resolved rather than triggered
+	Value     float64 // value that triggered or resolved the alert
+	Threshold float64
+	Unit      string
+	Labels    map[string]string // labels of the system, which select the notification routes
+	// whether the alert was sent during a maintenance window that marks alerts
+	Maintenance bool
+	// notifications grouped into this one while the alert was flapping
+	Occurrences int
+	// recent values of the alert's metric, oldest first, for sparklines
+	History []float64
 }
 
 type UserNotificationSettings struct {
-	Emails   []string `json:"emails"`
-	Webhooks []string `json:"webhooks"`
+	Emails   []string            `json:"emails"`
+	Webhooks []string            `json:"webhooks"`
+	Routes   []NotificationRoute `json:"routes,omitempty"`
+	// Notifications of an alert that changes state more than FlapThreshold
+	// times within FlapWindow minutes are grouped. Zero disables grouping.
+	FlapWindow    int `json:"flapWindow,omitempty"`
+	FlapThreshold int `json:"flapThreshold,omitempty"`
+	// webhooks with templated bodies, used with template://<name> URLs
+	WebhookTemplates []WebhookTemplate `json:"webhookTemplates,omitempty"`
 }
 
-type SystemAlertStats struct {
-	Cpu          float64            `json:"cpu"`
-	Mem          float64            `json:"mp"`
-	Disk         float64            `json:"dp"`
-	NetSent      float64            `json:"ns"`
-	NetRecv      float64            `json:"nr"`
-	Temperatures map[string]float32 `json:"t"`
-	LoadAvg      [3]float64         `json:"la"`
+// NotificationRoute sends the alerts that match all of its conditions to its
+// emails and webhooks instead of the default ones. Alerts match if their system
+// has all of the route's labels, their name is one of its alerts and their
+// severity is one of its severities. Empty conditions match any alert. Label
+// values can be glob patterns, such as "prod-*".
+type NotificationRoute struct {
+	Labels     map[string]string `json:"labels"`
+	Alerts     []string          `json:"alerts,omitempty"`     // alert names, e.g. "Status" or "CPU"
+	Severities []string          `json:"severities,omitempty"` // severities, e.g. "critical"
+	Emails     []string          `json:"emails,omitempty"`
+	Webhooks   []string          `json:"webhooks,omitempty"`
 }
 
 type SystemAlertData struct {
@@ -62,6 +105,8 @@ type SystemAlertData struct {
 	min          uint8
 	mapSums      map[string]float32
 	descriptor   string // override descriptor in notification body (for temp sensor, disk partition, etc)
+	target       string // optional target of the alert, e.g. a network interface
+	message      string // override the notification body
 }
 
 // notification services that support title param
@@ -85,11 +130,29 @@ var supportsTitle = map[string]struct{}{
 
 // NewAlertManager creates a new AlertManager instance.
 func NewAlertManager(app hubLike) *AlertManager {
+	return newAlertManagerWithClock(app, clock.New())
+}
+
+// newAlertManagerWithClock creates an AlertManager that uses the given clock for alert delays.
+func newAlertManagerWithClock(app hubLike, clk clock.Clock) *AlertManager {
 	am := &AlertManager{
 		hub:        app,
 		alertQueue: make(chan alertTask),
 		stopChan:   make(chan struct{}),
+		clock:      clk,
+		providers:  make(map[string]NotificationProvider),
+		flaps:      make(map[string]*flapState),
+		teamAlerts: make(map[string]time.Time),
+
+		anomalyBaselines: expirymap.New[*anomalyBaseline](time.Hour),
 	}
+	am.RegisterProvider(NewExternalProvider())
+	am.RegisterProvider(NewPagerDutyProvider())
+	am.RegisterProvider(NewOpsgenieProvider())
+	am.RegisterProvider(NewTelegramProvider())
+	am.RegisterProvider(NewDiscordProvider())
+	am.RegisterProvider(NewMatrixProvider())
+	am.RegisterProvider(NewTemplateProvider(am.webhookTemplate))
 	am.bindEvents()
 	go am.startWorker()
 	return am
@@ -99,38 +162,118 @@ func NewAlertManager(app hubLike) *AlertManager {
 func (am *AlertManager) bindEvents() {
 	am.hub.OnRecordAfterUpdateSuccess("alerts").BindFunc(updateHistoryOnAlertUpdate)
 	am.hub.OnRecordAfterDeleteSuccess("alerts").BindFunc(resolveHistoryOnAlertDelete)
+	am.hub.OnRecordAfterCreateSuccess("alerts").BindFunc(recordAlertCreate)
+	am.hub.OnRecordAfterUpdateSuccess("alerts").BindFunc(recordAlertUpdate)
+	am.hub.OnRecordAfterDeleteSuccess("alerts").BindFunc(recordAlertDelete)
+	am.hub.OnRecordCreateRequest("alert_rules").BindFunc(validateAlertRule)
+	am.hub.OnRecordUpdateRequest("alert_rules").BindFunc(validateAlertRule)
+	am.hub.OnRecordCreateRequest("maintenance_windows").BindFunc(validateMaintenanceWindow)
+	am.hub.OnRecordUpdateRequest("maintenance_windows").BindFunc(validateMaintenanceWindow)
+	am.hub.OnRecordCreateRequest("user_settings").BindFunc(validateUserSettings)
+	am.hub.OnRecordUpdateRequest("user_settings").BindFunc(validateUserSettings)
 }
 
-// SendAlert sends an alert to the user
+// SendAlert sends an alert to the user and the team of the system. Alerts of
+// systems in an active maintenance window are not sent or are marked,
+// depending on the window, and notifications of flapping alerts are grouped.
 func (am *AlertManager) SendAlert(data AlertMessageData) error {
-	// get user settings
+	if data.SystemID != "" {
+		switch am.maintenanceMode(data.UserID, data.SystemID, data.Labels) {
+		case MaintenanceSuppress:
+			am.hub.Logger().Debug("Suppressed alert during maintenance", "system", data.System, "title", data.Title)
+			return nil
+		case MaintenanceMark:
+			data.Maintenance = true
+			data.Title = "[Maintenance] " + data.Title
+		}
+	}
+	userAlertSettings, err := am.userNotificationSettings(data.UserID)
+	if err != nil {
+		return err
+	}
+	if am.dampenFlapping(data, userAlertSettings) {
+		return nil
+	}
+	am.deliverTeamAlert(data)
+	return am.deliverAlert(data, userAlertSettings)
+}
+
+// userNotificationSettings returns the notification settings of a user
+func (am *AlertManager) userNotificationSettings(userID string) (*UserNotificationSettings, error) {
 	record, err := am.hub.FindFirstRecordByFilter(
 		"user_settings", "user={:user}",
-		dbx.Params{"user": data.UserID},
+		dbx.Params{"user": userID},
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// unmarshal user settings
 	userAlertSettings := UserNotificationSettings{
-		Emails:   []string{},
-		Webhooks: []string{},
+		Emails:        []string{},
+		Webhooks:      []string{},
+		FlapWindow:    defaultFlapWindow,
+		FlapThreshold: defaultFlapThreshold,
 	}
 	if err := record.UnmarshalJSONField("settings", &userAlertSettings); err != nil {
 		am.hub.Logger().Error("Failed to unmarshal user settings", "err", err)
 	}
+	return &userAlertSettings, nil
+}
+
+// webhookTemplate returns a webhook template of a user by name
+func (am *AlertManager) webhookTemplate(userID, name string) (*WebhookTemplate, error) {
+	settings, err := am.userNotificationSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range settings.WebhookTemplates {
+		if settings.WebhookTemplates[i].Name == name {
+			return &settings.WebhookTemplates[i], nil
+		}
+	}
+	return nil, errors.New("template not found")
+}
+
+// validateUserSettings checks the webhook templates of saved user settings
+func validateUserSettings(e *core.RecordRequestEvent) error {
+	var settings UserNotificationSettings
+	if err := e.Record.UnmarshalJSONField("settings", &settings); err != nil {
+		return e.Next()
+	}
+	names := map[string]bool{}
+	for i := range settings.WebhookTemplates {
+		webhook := &settings.WebhookTemplates[i]
+		if err := webhook.Validate(); err != nil {
+			return e.BadRequestError("Invalid webhook template: "+err.Error(), nil)
+		}
+		if names[webhook.Name] {
+			return e.BadRequestError("Duplicate webhook template "+webhook.Name, nil)
+		}
+		names[webhook.Name] = true
+	}
+	return e.Next()
+}
+
+// deliverAlert sends an alert to the user's emails and webhooks
+func (am *AlertManager) deliverAlert(data AlertMessageData, userAlertSettings *UserNotificationSettings) error {
+	emails, webhooks := userAlertSettings.destinations(data)
 	// send alerts via webhooks
-	for _, webhook := range userAlertSettings.Webhooks {
-		if err := am.SendShoutrrrAlert(webhook, data.Title, data.Message, data.Link, data.LinkText); err != nil {
-			am.hub.Logger().Error("Failed to send shoutrrr alert", "err", err)
+	for _, webhook := range webhooks {
+		if err := am.sendNotification(webhook, data); err != nil {
+			am.hub.Logger().Error("Failed to send webhook alert", "err", err)
 		}
 	}
 	// send alerts via email
-	if len(userAlertSettings.Emails) == 0 {
+	return am.sendEmail(emails, data)
+}
+
+// sendEmail sends an alert to email addresses
+func (am *AlertManager) sendEmail(emails []string, data AlertMessageData) error {
+	if len(emails) == 0 {
 		return nil
 	}
 	addresses := []mail.Address{}
-	for _, email := range userAlertSettings.Emails {
+	for _, email := range emails {
 		addresses = append(addresses, mail.Address{Address: email})
 	}
 	message := mailer.Message{
@@ -142,14 +285,80 @@ func (am *AlertManager) SendAlert(data AlertMessageData) error {
 			Name:    am.hub.Settings().Meta.SenderName,
 		},
 	}
-	err = am.hub.NewMailClient().Send(&message)
-	if err != nil {
+	if err := am.hub.NewMailClient().Send(&message); err != nil {
 		return err
 	}
 	am.hub.Logger().Info("Sent email alert", "to", message.To, "subj", message.Subject)
 	return nil
 }
 
+// destinations returns the emails and webhooks of the routes that match an
+// alert, or the default ones if no route matches.
+func (s *UserNotificationSettings) destinations(data AlertMessageData) (emails, webhooks []string) {
+	matched := false
+	for _, route := range s.Routes {
+		if !route.matches(data) {
+			continue
+		}
+		matched = true
+		for _, email := range route.Emails {
+			if !slices.Contains(emails, email) {
+				emails = append(emails, email)
+			}
+		}
+		for _, webhook := range route.Webhooks {
+			if !slices.Contains(webhooks, webhook) {
+				webhooks = append(webhooks, webhook)
+			}
+		}
+	}
+	if !matched {
+		return s.Emails, s.Webhooks
+	}
+	return emails, webhooks
+}
+
+// matches reports whether an alert matches all of the route's conditions. A
+// route without conditions matches nothing, so it can't replace the default
+// destinations.
+func (r *NotificationRoute) matches(data AlertMessageData) bool {
+	if len(r.Labels) == 0 && len(r.Alerts) == 0 && len(r.Severities) == 0 {
+		return false
+	}
+	if !matchLabels(r.Labels, data.Labels) {
+		return false
+	}
+	if len(r.Alerts) > 0 && !slices.Contains(r.Alerts, data.Alert) {
+		return false
+	}
+	if len(r.Severities) > 0 && !slices.Contains(r.Severities, alertSeverity(data)) {
+		return false
+	}
+	return true
+}
+
+// matchLabels reports whether labels have all labels of a selector, whose
+// values can be glob patterns.
+func matchLabels(selector, labels map[string]string) bool {
+	for key, pattern := range selector {
+		value, ok := labels[key]
+		if !ok {
+			return false
+		}
+		if match, _ := path.Match(pattern, value); !match {
+			return false
+		}
+	}
+	return true
+}
+
+// SystemLabels returns the labels of a system record.
+func SystemLabels(systemRecord *core.Record) map[string]string {
+	var labels map[string]string
+	_ = systemRecord.UnmarshalJSONField("labels", &labels)
+	return labels
+}
+
 // SendShoutrrrAlert sends an alert via a Shoutrrr URL
 func (am *AlertManager) SendShoutrrrAlert(notificationUrl, title, message, link, linkText string) error {
 	// Parse the URL
@@ -212,9 +421,54 @@ func (am *AlertManager) SendTestNotification(e *core.RequestEvent) error {
 	if err != nil || data.URL == "" {
 		return e.BadRequestError("URL is required", err)
 	}
-	err = am.SendShoutrrrAlert(data.URL, "Test Alert", "This is a notification from Beszel.", am.hub.Settings().Meta.AppURL, "View Beszel")
+	err = am.sendNotification(data.URL, AlertMessageData{
+		UserID:   e.Auth.Id,
+		Title:    "Test Alert",
+		Message:  "This is a notification from Beszel.",
+		Link:     am.hub.Settings().Meta.AppURL,
+		LinkText: "View Beszel",
+	})
 	if err != nil {
 		return e.JSON(200, map[string]string{"err": err.Error()})
 	}
 	return e.JSON(200, map[string]bool{"err": false})
 }
+
+// SendTestRoute sends a test notification to the emails and webhooks of a
+// notification route, and returns the errors of the destinations that failed.
+func (am *AlertManager) SendTestRoute(e *core.RequestEvent) error {
+	var route NotificationRoute
+	if err := e.BindBody(&route); err != nil {
+		return e.BadRequestError("Invalid route", err)
+	}
+	if len(route.Emails) == 0 && len(route.Webhooks) == 0 {
+		return e.BadRequestError("Route has no emails or webhooks", nil)
+	}
+	for _, severity := range route.Severities {
+		if !slices.Contains([]string{SeverityCritical, SeverityError, SeverityWarning, SeverityInfo}, severity) {
+			return e.BadRequestError("Invalid severity: "+severity, nil)
+		}
+	}
+	data := AlertMessageData{
+		UserID:   e.Auth.Id,
+		Title:    "Test Alert",
+		Message:  "This is a notification from Beszel sent to a notification route.",
+		Link:     am.hub.Settings().Meta.AppURL,
+		LinkText: "View Beszel",
+	}
+	var errs []error
+	for _, webhook := range route.Webhooks {
+		if err := am.sendNotification(webhook, data); err != nil {
+			// the scheme identifies the webhook without exposing its credentials
+			scheme, _, _ := strings.Cut(webhook, "://")
+			errs = append(errs, fmt.Errorf("%s: %w", scheme, err))
+		}
+	}
+	if err := am.sendEmail(route.Emails, data); err != nil {
+		errs = append(errs, fmt.Errorf("email: %w", err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return e.JSON(200, map[string]string{"err": err.Error()})
+	}
+	return e.JSON(200, map[string]bool{"err": false})
+}
@@ -0,0 +1,201 @@
+package alerts
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	// time range of alert analytics if none is given
+	defaultAnalyticsRange = 30 * 24 * time.Hour
+	// longest time range of alert analytics
+	maxAnalyticsRange = 366 * 24 * time.Hour
+	// number of systems and alert types in alert analytics
+	analyticsTopCount = 10
+)
+
+// AlertAnalytics summarizes the alert history of a user over a time range
+type AlertAnalytics struct {
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Total        int       `json:"total"`        // alerts triggered in the range
+	Active       int       `json:"active"`       // alerts of the range that aren't resolved
+	Acknowledged int       `json:"acknowledged"` // alerts of the range that were acknowledged
+	// mean seconds from trigger to resolution of resolved alerts
+	MTTR float64 `json:"mttr"`
+	// mean seconds from trigger to acknowledgement of acknowledged alerts
+	MTTA     float64            `json:"mtta"`
+	Systems  []AlertGroupStats  `json:"systems"`  // systems with the most alerts
+	Alerts   []AlertGroupStats  `json:"alerts"`   // alert types with the most alerts
+	Timeline []AlertBucketStats `json:"timeline"` // alerts by hour or day
+}
+
+// AlertGroupStats is the number of alerts of a system or alert type
+type AlertGroupStats struct {
+	ID    string  `db:"id" json:"id,omitempty"` // system id
+	Name  string  `db:"name" json:"name"`
+	Count int     `db:"count" json:"count"`
+	MTTR  float64 `db:"mttr" json:"mttr"`
+}
+
+// AlertBucketStats is the number of alerts triggered in an hour or day
+type AlertBucketStats struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"`
+}
+
+// GetAlertAnalytics handles API requests for analytics of a user's alert
+// history between the from and to query params, which default to the last 30
+// days (GET /api/beszel/alert-analytics)
+func GetAlertAnalytics(e *core.RequestEvent) error {
+	to := time.Now().UTC()
+	if value := e.Request.URL.Query().Get("to"); value != "" {
+		parsed, err := types.ParseDateTime(value)
+		if err != nil {
+			return e.BadRequestError("Invalid to", err)
+		}
+		to = parsed.Time()
+	}
+	from := to.Add(-defaultAnalyticsRange)
+	if value := e.Request.URL.Query().Get("from"); value != "" {
+		parsed, err := types.ParseDateTime(value)
+		if err != nil {
+			return e.BadRequestError("Invalid from", err)
+		}
+		from = parsed.Time()
+	}
+	if !from.Before(to) || to.Sub(from) > maxAnalyticsRange {
+		return e.BadRequestError("The time range must be positive and at most 366 days", nil)
+	}
+	analytics, err := alertAnalytics(e.App, e.Auth.Id, from, to)
+	if err != nil {
+		return err
+	}
+	return e.JSON(http.StatusOK, analytics)
+}
+
+// AcknowledgeAlerts handles API requests to acknowledge alerts in the alert
+// history, which records when and by whom they were acknowledged. Users can
+// acknowledge their own alerts and the alerts of systems in teams they
+// operate. Alerts that were already acknowledged keep their time
+// (POST /api/beszel/alert-history/ack).
+func AcknowledgeAlerts(e *core.RequestEvent) error {
+	var reqData struct {
+		IDs []string `json:"ids"`
+	}
+	if err := e.BindBody(&reqData); err != nil || len(reqData.IDs) == 0 {
+		return e.BadRequestError("Bad data", err)
+	}
+	info, err := e.RequestInfo()
+	if err != nil {
+		return err
+	}
+	records, err := e.App.FindRecordsByIds("alerts_history", reqData.IDs, func(q *dbx.SelectQuery) error {
+		q.AndWhere(dbx.HashExp{"acknowledged": ""})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// keep the records the user can view, which are the ones they can acknowledge
+	records = slices.DeleteFunc(records, func(record *core.Record) bool {
+		canAccess, _ := e.App.CanAccessRecord(record, info, record.Collection().ViewRule)
+		return !canAccess
+	})
+	now := time.Now().UTC()
+	err = e.App.RunInTransaction(func(txApp core.App) error {
+		for _, record := range records {
+			record.Set("acknowledged", now)
+			if !e.Auth.IsSuperuser() {
+				record.Set("acknowledged_by", e.Auth.Id)
+			}
+			if err := txApp.Save(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return e.JSON(http.StatusOK, map[string]int{"acknowledged": len(records)})
+}
+
+// alertAnalytics summarizes the alerts of a user triggered between from and to.
+// The timeline has hourly buckets for ranges of up to two days, and daily
+// buckets for longer ranges, in UTC.
+func alertAnalytics(app core.App, userID string, from, to time.Time) (*AlertAnalytics, error) {
+	from, to = from.UTC(), to.UTC()
+	params := dbx.Params{
+		"user": userID,
+		"from": from.Format(types.DefaultDateLayout),
+		"to":   to.Format(types.DefaultDateLayout),
+	}
+	const where = " WHERE h.user = {:user} AND h.created >= {:from} AND h.created < {:to}"
+	const mttr = "COALESCE(AVG(CASE WHEN h.resolved != '' THEN h.duration END), 0)"
+
+	analytics := &AlertAnalytics{From: from, To: to, Systems: []AlertGroupStats{}, Alerts: []AlertGroupStats{}}
+	var summary struct {
+		Total        int     `db:"total"`
+		Active       int     `db:"active"`
+		Acknowledged int     `db:"acknowledged"`
+		MTTR         float64 `db:"mttr"`
+		MTTA         float64 `db:"mtta"`
+	}
+	err := app.DB().NewQuery("SELECT COUNT(*) AS total, " +
+		"COALESCE(SUM(h.resolved = ''), 0) AS active, " +
+		"COALESCE(SUM(h.acknowledged != ''), 0) AS acknowledged, " +
+		mttr + " AS mttr, " +
+		"COALESCE(AVG(CASE WHEN h.acknowledged != '' THEN (julianday(h.acknowledged) - julianday(h.created)) * 86400 END), 0) AS mtta " +
+		"FROM alerts_history h" + where).Bind(params).One(&summary)
+	if err != nil {
+		return nil, err
+	}
+	analytics.Total, analytics.Active, analytics.Acknowledged = summary.Total, summary.Active, summary.Acknowledged
+	analytics.MTTR, analytics.MTTA = summary.MTTR, summary.MTTA
+
+	err = app.DB().NewQuery("SELECT h.system AS id, COALESCE(s.name, h.system) AS name, COUNT(*) AS count, " + mttr + " AS mttr " +
+		"FROM alerts_history h LEFT JOIN systems s ON s.id = h.system" + where +
+		" GROUP BY h.system ORDER BY count DESC, name LIMIT {:limit}").
+		Bind(params).Bind(dbx.Params{"limit": analyticsTopCount}).All(&analytics.Systems)
+	if err != nil {
+		return nil, err
+	}
+	err = app.DB().NewQuery("SELECT h.name AS name, COUNT(*) AS count, " + mttr + " AS mttr " +
+		"FROM alerts_history h" + where +
+		" GROUP BY h.name ORDER BY count DESC, name LIMIT {:limit}").
+		Bind(params).Bind(dbx.Params{"limit": analyticsTopCount}).All(&analytics.Alerts)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, format, layout := 24*time.Hour, "%Y-%m-%d", time.DateOnly
+	if to.Sub(from) <= 48*time.Hour {
+		bucket, format, layout = time.Hour, "%Y-%m-%d %H:00:00", time.DateTime
+	}
+	var counts []struct {
+		Bucket string `db:"bucket"`
+		Count  int    `db:"count"`
+	}
+	err = app.DB().NewQuery("SELECT strftime('" + format + "', h.created) AS bucket, COUNT(*) AS count " +
+		"FROM alerts_history h" + where + " GROUP BY bucket").Bind(params).All(&counts)
+	if err != nil {
+		return nil, err
+	}
+	countByBucket := make(map[time.Time]int, len(counts))
+	for _, c := range counts {
+		if t, err := time.Parse(layout, c.Bucket); err == nil {
+			countByBucket[t] = c.Count
+		}
+	}
+	// include empty buckets, so the timeline covers the whole range
+	for t := from.Truncate(bucket); t.Before(to); t = t.Add(bucket) {
+		analytics.Timeline = append(analytics.Timeline, AlertBucketStats{Time: t, Count: countByBucket[t]})
+	}
+	return analytics, nil
+}
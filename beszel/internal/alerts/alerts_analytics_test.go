@@ -0,0 +1,210 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"beszel/internal/alerts"
+	beszelTests "beszel/internal/tests"
+
+	"github.com/pocketbase/pocketbase/core"
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertAnalytics(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "analytics@example.com", "password123")
+	require.NoError(t, err)
+	other, err := beszelTests.CreateUser(hub, "analyticsother@example.com", "password123")
+	require.NoError(t, err)
+	web, err := beszelTests.CreateRecord(hub, "systems", map[string]any{"name": "web", "host": "127.0.0.1", "users": []string{user.Id, other.Id}})
+	require.NoError(t, err)
+	db, err := beszelTests.CreateRecord(hub, "systems", map[string]any{"name": "db", "host": "127.0.0.2", "users": []string{user.Id}})
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Hour)
+	// history adds an alert triggered hours ago, resolved after some minutes
+	// and acknowledged after some minutes if they aren't zero
+	history := func(user *core.Record, system *core.Record, name string, hoursAgo, resolvedAfter, ackAfter int) {
+		created := now.Add(-time.Duration(hoursAgo) * time.Hour)
+		record, err := beszelTests.CreateRecord(hub, "alerts_history", map[string]any{"user": user.Id, "system": system.Id, "name": name})
+		require.NoError(t, err)
+		record.SetRaw("created", created.Format(types.DefaultDateLayout))
+		if resolvedAfter > 0 {
+			record.Set("resolved", created.Add(time.Duration(resolvedAfter)*time.Minute))
+			record.Set("duration", resolvedAfter*60)
+		}
+		if ackAfter > 0 {
+			record.Set("acknowledged", created.Add(time.Duration(ackAfter)*time.Minute))
+		}
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+	history(user, web, "CPU", 2, 10, 2)
+	history(user, web, "CPU", 5, 20, 0)
+	history(user, web, "Status", 30, 0, 4)
+	history(user, db, "Memory", 3, 30, 0)
+	history(user, db, "CPU", 24*40, 10, 0) // outside the default range
+	history(other, web, "Status", 1, 0, 0) // another user's alert
+
+	analytics, err := alerts.AlertAnalyticsFor(hub, user.Id, now.Add(-30*24*time.Hour), now)
+	require.NoError(t, err)
+	assert.Equal(t, 4, analytics.Total)
+	assert.Equal(t, 1, analytics.Active)
+	assert.Equal(t, 2, analytics.Acknowledged)
+	assert.InDelta(t, 20*60, analytics.MTTR, 0.01)
+	assert.InDelta(t, 3*60, analytics.MTTA, 0.01)
+	assert.Equal(t, []alerts.AlertGroupStats{
+		{ID: web.Id, Name: "web", Count: 3, MTTR: 15 * 60},
+		{ID: db.Id, Name: "db", Count: 1, MTTR: 30 * 60},
+	}, analytics.Systems)
+	assert.Equal(t, []alerts.AlertGroupStats{
+		{Name: "CPU", Count: 2, MTTR: 15 * 60},
+		{Name: "Memory", Count: 1, MTTR: 30 * 60},
+		{Name: "Status", Count: 1},
+	}, analytics.Alerts)
+	// daily buckets from the day of the start of the range
+	require.NotEmpty(t, analytics.Timeline)
+	assert.Equal(t, now.Add(-30*24*time.Hour).Truncate(24*time.Hour), analytics.Timeline[0].Time)
+	total := 0
+	for i, bucket := range analytics.Timeline {
+		if i > 0 {
+			assert.Equal(t, 24*time.Hour, bucket.Time.Sub(analytics.Timeline[i-1].Time))
+		}
+		total += bucket.Count
+	}
+	assert.Equal(t, 4, total)
+
+	// hourly buckets for short ranges
+	analytics, err = alerts.AlertAnalyticsFor(hub, user.Id, now.Add(-6*time.Hour), now)
+	require.NoError(t, err)
+	assert.Equal(t, 3, analytics.Total)
+	require.Len(t, analytics.Timeline, 6)
+	assert.Equal(t, now.Add(-6*time.Hour), analytics.Timeline[0].Time)
+	assert.Equal(t, 1, analytics.Timeline[1].Count) // 5 hours ago
+	assert.Equal(t, 1, analytics.Timeline[3].Count) // 3 hours ago
+	assert.Equal(t, 1, analytics.Timeline[4].Count) // 2 hours ago
+}
+
+func TestAlertHistoryDuration(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "duration@example.com", "password123")
+	require.NoError(t, err)
+	system, err := beszelTests.CreateRecord(hub, "systems", map[string]any{"name": "web", "host": "127.0.0.1", "users": []string{user.Id}})
+	require.NoError(t, err)
+	alert, err := beszelTests.CreateRecord(hub, "alerts", map[string]any{"user": user.Id, "system": system.Id, "name": "CPU", "value": 80})
+	require.NoError(t, err)
+
+	alert.Set("triggered", true)
+	require.NoError(t, hub.Save(alert))
+	record, err := hub.FindFirstRecordByFilter("alerts_history", "alert_id={:id}", map[string]any{"id": alert.Id})
+	require.NoError(t, err)
+	record.SetRaw("created", time.Now().UTC().Add(-90*time.Second).Format(types.DefaultDateLayout))
+	require.NoError(t, hub.SaveNoValidate(record))
+
+	// the original state of the alert is loaded from the database
+	alert, err = hub.FindRecordById("alerts", alert.Id)
+	require.NoError(t, err)
+	alert.Set("triggered", false)
+	require.NoError(t, hub.Save(alert))
+	record, err = hub.FindRecordById("alerts_history", record.Id)
+	require.NoError(t, err)
+	assert.False(t, record.GetDateTime("resolved").IsZero())
+	assert.InDelta(t, 90, record.GetInt("duration"), 2)
+}
+
+func TestAlertAnalyticsApi(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "analyticsapi@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	other, err := beszelTests.CreateUser(hub, "analyticsapiother@example.com", "password123")
+	require.NoError(t, err)
+	system, err := beszelTests.CreateRecord(hub, "systems", map[string]any{"name": "web", "host": "127.0.0.1", "users": []string{user.Id, other.Id}})
+	require.NoError(t, err)
+	own, err := beszelTests.CreateRecord(hub, "alerts_history", map[string]any{"user": user.Id, "system": system.Id, "name": "CPU"})
+	require.NoError(t, err)
+	others, err := beszelTests.CreateRecord(hub, "alerts_history", map[string]any{"user": other.Id, "system": system.Id, "name": "CPU"})
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "analytics without auth",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/alert-analytics",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "analytics of the last 30 days",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/alert-analytics",
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"total":1`, `"active":1`, `"name":"web"`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "analytics with a range over a year",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/alert-analytics?from=2024-01-01&to=2025-06-01",
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"at most 366 days"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "acknowledge own and other user's alerts",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-history/ack",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(map[string]any{"ids": []string{own.Id, others.Id}}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"acknowledged":1`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				record, err := app.FindRecordById("alerts_history", own.Id)
+				require.NoError(t, err)
+				assert.False(t, record.GetDateTime("acknowledged").IsZero())
+				record, err = app.FindRecordById("alerts_history", others.Id)
+				require.NoError(t, err)
+				assert.True(t, record.GetDateTime("acknowledged").IsZero())
+			},
+		},
+		{
+			Name:            "acknowledge acknowledged alert",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-history/ack",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(map[string]any{"ids": []string{own.Id}}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"acknowledged":0`},
+			TestAppFactory:  testAppFactory,
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
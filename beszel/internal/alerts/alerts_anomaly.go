@@ -0,0 +1,242 @@
+package alerts
+
+import (
+	"beszel/internal/hub/storage"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cast"
+)
+
+const (
+	// history the baseline of a metric is learned from, the retention of 120m records
+	anomalyHistory = 7 * 24 * time.Hour
+	// how long a learned baseline is used before it's learned again
+	anomalyBaselineTTL = time.Hour
+	// 120m records needed to learn a baseline, one day
+	anomalyMinRecords = 12
+	// records of a slot of the day needed to use the slot's own baseline
+	anomalyMinSlotRecords = 3
+	// hours of each slot of the day
+	anomalySlotHours = 2
+	// metric of Anomaly alerts without a target
+	defaultAnomalyMetric = "cpu"
+	// standard deviations of Anomaly alerts without a value
+	defaultAnomalyDeviations = 3
+)
+
+// Names of anomaly metrics in notifications. Sensors are named "Sensor <name>".
+var anomalyNames = map[string]string{
+	"cpu":         "CPU usage",
+	"memory":      "Memory usage",
+	"disk":        "Disk usage",
+	"swap":        "Swap usage",
+	"bandwidth":   "Bandwidth",
+	"diskread":    "Disk read",
+	"diskwrite":   "Disk write",
+	"load1":       "1m load",
+	"load5":       "5m load",
+	"load15":      "15m load",
+	"temperature": "Temperature",
+	"latency":     "Pipeline latency",
+}
+
+// Smallest standard deviation of metrics, so a metric that barely changes
+// doesn't alert on tiny changes. Other metrics use 0.1.
+var anomalyFloors = map[string]float64{
+	"cpu":         1,
+	"memory":      1,
+	"disk":        1,
+	"swap":        1,
+	"temperature": 1,
+	"latency":     10,
+}
+
+// anomalyStat is the mean and standard deviation of a metric in records
+type anomalyStat struct {
+	mean   float64
+	stddev float64
+	count  int
+}
+
+// newAnomalyStat returns the mean and standard deviation of values
+func newAnomalyStat(values []float64) anomalyStat {
+	stat := anomalyStat{count: len(values)}
+	if stat.count == 0 {
+		return stat
+	}
+	for _, value := range values {
+		stat.mean += value
+	}
+	stat.mean /= float64(stat.count)
+	for _, value := range values {
+		stat.stddev += (value - stat.mean) * (value - stat.mean)
+	}
+	stat.stddev = math.Sqrt(stat.stddev / float64(stat.count))
+	return stat
+}
+
+// anomalyBaseline is the usual level of a metric, overall and in each slot of
+// the day (UTC), so daily patterns like nightly backups aren't anomalies.
+type anomalyBaseline struct {
+	overall anomalyStat
+	slots   [24 / anomalySlotHours]anomalyStat
+}
+
+// anomalySlot returns the slot of the day of a time
+func anomalySlot(t time.Time) int {
+	return t.UTC().Hour() / anomalySlotHours
+}
+
+// learnAnomalyBaseline learns the baseline of a metric from 120m records. It
+// returns nil if the records don't have enough of the metric.
+func learnAnomalyBaseline(records []storage.SystemStats, metric string) *anomalyBaseline {
+	var all []float64
+	var slots [24 / anomalySlotHours][]float64
+	for i := range records {
+		value, _, ok := metricValue(metric, &records[i].Stats)
+		if !ok {
+			continue
+		}
+		all = append(all, value)
+		// records are created at the end of the period they average
+		slot := anomalySlot(records[i].Created.Add(-time.Hour))
+		slots[slot] = append(slots[slot], value)
+	}
+	if len(all) < anomalyMinRecords {
+		return nil
+	}
+	baseline := &anomalyBaseline{overall: newAnomalyStat(all)}
+	for i := range slots {
+		baseline.slots[i] = newAnomalyStat(slots[i])
+	}
+	return baseline
+}
+
+// at returns the usual mean and standard deviation of the metric at a time.
+// The standard deviation is at least a tenth of the mean and the metric's floor.
+func (b *anomalyBaseline) at(t time.Time, metric string) (mean, stddev float64) {
+	stat := b.slots[anomalySlot(t)]
+	if stat.count < anomalyMinSlotRecords {
+		stat = b.overall
+	}
+	floor, ok := anomalyFloors[metric]
+	if !ok {
+		floor = 0.1
+	}
+	return stat.mean, max(stat.stddev, math.Abs(stat.mean)/10, floor)
+}
+
+// anomalyName returns the name of a metric in notifications
+func anomalyName(metric string) string {
+	if sensor, ok := strings.CutPrefix(metric, "sensor:"); ok {
+		return "Sensor " + sensor
+	}
+	if name, ok := anomalyNames[metric]; ok {
+		return name
+	}
+	return metric
+}
+
+// lowerMetricName lowercases the first letter of a metric name for notification
+// titles, unless it's CPU like other alert titles
+func lowerMetricName(name string) string {
+	if name == "" || strings.HasPrefix(name, "CPU") {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// getAnomalyBaseline returns the baseline of a system's metric, learning it
+// from the system's 120m records if it's not cached. It returns nil while
+// there isn't enough history.
+func (am *AlertManager) getAnomalyBaseline(systemId, metric string, now time.Time) (*anomalyBaseline, error) {
+	key := systemId + "|" + metric
+	if baseline, ok := am.anomalyBaselines.GetOk(key); ok {
+		return baseline, nil
+	}
+	records, err := am.hub.Storage().SystemStats(storage.Query{
+		System: systemId,
+		Type:   storage.Type120m,
+		Since:  now.Add(-anomalyHistory),
+	})
+	if err != nil {
+		return nil, err
+	}
+	baseline := learnAnomalyBaseline(records, metric)
+	am.anomalyBaselines.Set(key, baseline, anomalyBaselineTTL)
+	return baseline, nil
+}
+
+// handleAnomalyAlert triggers the Anomaly alert when the alert's metric
+// averaged over the alert period is further from its baseline than the alert
+// value in standard deviations. The target is the metric, like the metrics of
+// alert rules, and CPU usage if empty.
+func (am *AlertManager) handleAnomalyAlert(systemRecord, alertRecord *core.Record, now time.Time) {
+	metric := alertRecord.GetString("target")
+	if metric == "" {
+		metric = defaultAnomalyMetric
+	}
+	if !validMetric(metric) {
+		return
+	}
+	deviations := alertRecord.GetFloat("value")
+	if deviations <= 0 {
+		deviations = defaultAnomalyDeviations
+	}
+	baseline, err := am.getAnomalyBaseline(systemRecord.Id, metric, now)
+	if err != nil || baseline == nil {
+		return
+	}
+
+	min := max(1, cast.ToUint8(alertRecord.Get("min")))
+	since := now.Add(-time.Duration(min) * time.Minute)
+	records, err := am.hub.Storage().SystemStats(storage.Query{
+		System: systemRecord.Id,
+		Type:   storage.Type1m,
+		// subtract some time to give us a bit of buffer
+		Since: since.Add(-time.Second * 90),
+	})
+	if err != nil {
+		return
+	}
+	var sum float64
+	var unit string
+	count := 0
+	for i := range records {
+		// subtract 10 seconds to give a small time buffer
+		if records[i].Created.Add(-time.Second * 10).Before(since) {
+			continue
+		}
+		if value, valueUnit, ok := metricValue(metric, &records[i].Stats); ok {
+			sum += value
+			unit = valueUnit
+			count++
+		}
+	}
+	if count == 0 || float32(count) < float32(min)/1.2 {
+		return
+	}
+
+	value := sum / float64(count)
+	mean, stddev := baseline.at(now, metric)
+	anomalous := math.Abs(value-mean) > deviations*stddev
+	if anomalous == alertRecord.GetBool("triggered") {
+		return
+	}
+	go am.sendSystemAlert(SystemAlertData{
+		systemRecord: systemRecord,
+		alertRecord:  alertRecord,
+		name:         "Anomaly",
+		unit:         unit,
+		val:          value,
+		threshold:    deviations,
+		triggered:    anomalous,
+		min:          min,
+		target:       metric,
+		descriptor:   fmt.Sprintf("%s (usually %.2f%s at this time)", anomalyName(metric), mean, unit),
+	})
+}
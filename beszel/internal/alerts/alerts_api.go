@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
@@ -18,6 +19,7 @@ func UpsertUserAlerts(e *core.RequestEvent) error {
 		Min       uint8    `json:"min"`
 		Value     float64  `json:"value"`
 		Name      string   `json:"name"`
+		Target    string   `json:"target"`
 		Systems   []string `json:"systems"`
 		Overwrite bool     `json:"overwrite"`
 	}{}
@@ -33,32 +35,7 @@ func UpsertUserAlerts(e *core.RequestEvent) error {
 
 	err = e.App.RunInTransaction(func(txApp core.App) error {
 		for _, systemId := range reqData.Systems {
-			// find existing matching alert
-			alertRecord, err := txApp.FindFirstRecordByFilter(alertsCollection,
-				"system={:system} && name={:name} && user={:user}",
-				dbx.Params{"system": systemId, "name": reqData.Name, "user": userID})
-
-			if err != nil && !errors.Is(err, sql.ErrNoRows) {
-				return err
-			}
-
-			// skip if alert already exists and overwrite is not set
-			if !reqData.Overwrite && alertRecord != nil {
-				continue
-			}
-
-			// create new alert if it doesn't exist
-			if alertRecord == nil {
-				alertRecord = core.NewRecord(alertsCollection)
-				alertRecord.Set("user", userID)
-				alertRecord.Set("system", systemId)
-				alertRecord.Set("name", reqData.Name)
-			}
-
-			alertRecord.Set("value", reqData.Value)
-			alertRecord.Set("min", reqData.Min)
-
-			if err := txApp.SaveNoValidate(alertRecord); err != nil {
+			if _, _, err := upsertAlert(txApp, alertsCollection, userID, systemId, reqData.Name, reqData.Value, reqData.Min, reqData.Target, reqData.Overwrite); err != nil {
 				return err
 			}
 		}
@@ -72,6 +49,43 @@ func UpsertUserAlerts(e *core.RequestEvent) error {
 	return e.JSON(http.StatusOK, map[string]any{"success": true})
 }
 
+// upsertAlert creates or updates an alert for a user and system. An existing
+// alert is only updated if overwrite is set. The target limits the alert to
+// part of the system, e.g. a Compose project for the Containers alert.
+func upsertAlert(txApp core.App, alertsCollection *core.Collection, userID, systemId, name string, value float64, min uint8, target string, overwrite bool) (created, saved bool, err error) {
+	// find existing matching alert
+	alertRecord, err := txApp.FindFirstRecordByFilter(alertsCollection,
+		"system={:system} && name={:name} && user={:user}",
+		dbx.Params{"system": systemId, "name": name, "user": userID})
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, false, err
+	}
+
+	// skip if alert already exists and overwrite is not set
+	if !overwrite && alertRecord != nil {
+		return false, false, nil
+	}
+
+	// create new alert if it doesn't exist
+	if alertRecord == nil {
+		created = true
+		alertRecord = core.NewRecord(alertsCollection)
+		alertRecord.Set("user", userID)
+		alertRecord.Set("system", systemId)
+		alertRecord.Set("name", name)
+	}
+
+	alertRecord.Set("value", value)
+	alertRecord.Set("min", min)
+	alertRecord.Set("target", strings.TrimSpace(target))
+
+	if err := txApp.SaveNoValidate(alertRecord); err != nil {
+		return false, false, err
+	}
+	return created, true, nil
+}
+
 // DeleteUserAlerts handles API request to delete alerts for a user across multiple systems
 // (DELETE /api/beszel/user-alerts)
 func DeleteUserAlerts(e *core.RequestEvent) error {
@@ -0,0 +1,70 @@
+package alerts
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// recordAlertCreate adds a created alert to the alert changes
+func recordAlertCreate(e *core.RecordEvent) error {
+	recordAlertChange(e.App, "create", nil, e.Record)
+	return e.Next()
+}
+
+// recordAlertUpdate adds an alert to the alert changes if its threshold or
+// duration changed. Updates of whether it's triggered are not recorded, nor
+// updates of records that weren't loaded from the database, which have no
+// original values.
+func recordAlertUpdate(e *core.RecordEvent) error {
+	original := e.Record.Original()
+	if original.Id == "" {
+		return e.Next()
+	}
+	if original.GetFloat("value") != e.Record.GetFloat("value") || original.GetInt("min") != e.Record.GetInt("min") {
+		recordAlertChange(e.App, "update", original, e.Record)
+	}
+	return e.Next()
+}
+
+// recordAlertDelete adds a deleted alert to the alert changes
+func recordAlertDelete(e *core.RecordEvent) error {
+	recordAlertChange(e.App, "delete", e.Record, nil)
+	return e.Next()
+}
+
+// recordAlertChange saves a change of an alert from old to new, which are nil
+// for created and deleted alerts. As only the user of an alert can change it,
+// the change is recorded with the user's email.
+func recordAlertChange(app core.App, action string, old, new *core.Record) {
+	alert := new
+	if alert == nil {
+		alert = old
+	}
+	// deleting the system deletes its alerts and alert changes
+	if _, err := app.FindRecordById("systems", alert.GetString("system")); err != nil {
+		return
+	}
+	collection, err := app.FindCachedCollectionByNameOrId("alert_changes")
+	if err != nil {
+		return
+	}
+	change := core.NewRecord(collection)
+	change.Set("system", alert.GetString("system"))
+	change.Set("alert", alert.Id)
+	change.Set("name", alert.GetString("name"))
+	change.Set("action", action)
+	if user, err := app.FindRecordById("users", alert.GetString("user")); err == nil {
+		change.Set("user", user.Id)
+		change.Set("email", user.Email())
+	}
+	if old != nil {
+		change.Set("old_value", old.GetFloat("value"))
+		change.Set("old_min", old.GetInt("min"))
+	}
+	if new != nil {
+		change.Set("value", new.GetFloat("value"))
+		change.Set("min", new.GetInt("min"))
+	}
+	if err := app.Save(change); err != nil {
+		app.Logger().Error("Failed to record alert change", "alert", alert.Id, "err", err)
+	}
+}
@@ -0,0 +1,87 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"testing"
+
+	beszelTests "beszel/internal/tests"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertChanges(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "audit@example.com", "password123")
+	require.NoError(t, err)
+	system, err := beszelTests.CreateRecord(hub, "systems", map[string]any{"name": "web", "host": "127.0.0.1", "users": []string{user.Id}})
+	require.NoError(t, err)
+
+	alert, err := beszelTests.CreateRecord(hub, "alerts", map[string]any{
+		"user":   user.Id,
+		"system": system.Id,
+		"name":   "CPU",
+		"value":  80,
+		"min":    5,
+	})
+	require.NoError(t, err)
+
+	update := func(field string, value any) {
+		record, err := hub.FindRecordById("alerts", alert.Id)
+		require.NoError(t, err)
+		record.Set(field, value)
+		require.NoError(t, hub.Save(record))
+	}
+	// changes of the triggered state aren't recorded
+	update("triggered", true)
+	update("value", 90)
+	update("min", 10)
+	alert, err = hub.FindRecordById("alerts", alert.Id)
+	require.NoError(t, err)
+	require.NoError(t, hub.Delete(alert))
+
+	var changes []*core.Record
+	err = hub.RecordQuery("alert_changes").AndWhere(dbx.HashExp{"system": system.Id}).OrderBy("rowid").All(&changes)
+	require.NoError(t, err)
+	require.Len(t, changes, 4)
+	type change struct {
+		action          string
+		oldValue, value float64
+		oldMin, min     int
+	}
+	got := make([]change, len(changes))
+	for i, record := range changes {
+		assert.Equal(t, user.Id, record.GetString("user"))
+		assert.Equal(t, "audit@example.com", record.GetString("email"))
+		assert.Equal(t, alert.Id, record.GetString("alert"))
+		assert.Equal(t, "CPU", record.GetString("name"))
+		got[i] = change{
+			action:   record.GetString("action"),
+			oldValue: record.GetFloat("old_value"),
+			value:    record.GetFloat("value"),
+			oldMin:   record.GetInt("old_min"),
+			min:      record.GetInt("min"),
+		}
+	}
+	assert.Equal(t, []change{
+		{action: "create", value: 80, min: 5},
+		{action: "update", oldValue: 80, value: 90, oldMin: 5, min: 5},
+		{action: "update", oldValue: 90, value: 90, oldMin: 5, min: 10},
+		{action: "delete", oldValue: 90, oldMin: 10},
+	}, got)
+
+	// deleting a system deletes its alerts without recording the changes
+	_, err = beszelTests.CreateRecord(hub, "alerts", map[string]any{"user": user.Id, "system": system.Id, "name": "Memory", "value": 80})
+	require.NoError(t, err)
+	require.NoError(t, hub.Delete(system))
+	count, err := hub.CountRecords("alert_changes")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,226 @@
+package alerts
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Version of the alert bundle format
+const alertBundleVersion = 1
+
+// AlertBundle is a portable set of alert rules and notification settings.
+// Systems are referenced by name so the bundle can be imported into another hub.
+type AlertBundle struct {
+	Version       int                       `json:"version"`
+	Rules         []AlertBundleRule         `json:"rules"`
+	Notifications *UserNotificationSettings `json:"notifications,omitempty"`
+}
+
+// AlertBundleRule is an alert applied to one or more systems
+type AlertBundleRule struct {
+	Name    string   `json:"name"`
+	Value   float64  `json:"value"`
+	Min     uint8    `json:"min"`
+	Target  string   `json:"target,omitempty"`  // Compose project of the Containers alert
+	Systems []string `json:"systems,omitempty"` // system names, or all target systems if empty
+}
+
+// ExportAlertBundle handles API request to export the user's alerts as a bundle
+// (GET /api/beszel/alert-bundle). Notification settings are included if the
+// "notifications" query param is true, as webhook URLs may contain secrets.
+func ExportAlertBundle(e *core.RequestEvent) error {
+	userID := e.Auth.Id
+
+	systemNames := make(map[string]string)
+	systems, err := findUserSystems(e.App, userID)
+	if err != nil {
+		return err
+	}
+	for _, system := range systems {
+		systemNames[system.Id] = system.GetString("name")
+	}
+
+	alertRecords, err := e.App.FindRecordsByFilter("alerts", "user={:user}", "name,value,min,target", -1, 0, dbx.Params{"user": userID})
+	if err != nil {
+		return err
+	}
+	bundle := AlertBundle{Version: alertBundleVersion, Rules: []AlertBundleRule{}}
+	// alerts with the same settings become one rule
+	for _, alertRecord := range alertRecords {
+		systemName, ok := systemNames[alertRecord.GetString("system")]
+		if !ok {
+			continue
+		}
+		rule := AlertBundleRule{
+			Name:   alertRecord.GetString("name"),
+			Value:  alertRecord.GetFloat("value"),
+			Min:    uint8(alertRecord.GetInt("min")),
+			Target: alertRecord.GetString("target"),
+		}
+		if n := len(bundle.Rules); n > 0 && bundle.Rules[n-1].Name == rule.Name &&
+			bundle.Rules[n-1].Value == rule.Value && bundle.Rules[n-1].Min == rule.Min && bundle.Rules[n-1].Target == rule.Target {
+			bundle.Rules[n-1].Systems = append(bundle.Rules[n-1].Systems, systemName)
+			continue
+		}
+		rule.Systems = []string{systemName}
+		bundle.Rules = append(bundle.Rules, rule)
+	}
+	for i := range bundle.Rules {
+		slices.Sort(bundle.Rules[i].Systems)
+	}
+
+	if e.Request.URL.Query().Get("notifications") == "true" {
+		settings := UserNotificationSettings{Emails: []string{}, Webhooks: []string{}}
+		if record, err := e.App.FindFirstRecordByFilter("user_settings", "user={:user}", dbx.Params{"user": userID}); err == nil {
+			record.UnmarshalJSONField("settings", &settings)
+		}
+		bundle.Notifications = &settings
+	}
+
+	return e.JSON(http.StatusOK, bundle)
+}
+
+// ImportAlertBundle handles API request to create the alerts of a bundle for the
+// user (POST /api/beszel/alert-bundle). Rule systems are matched to the user's
+// systems by name. Rules without systems apply to the systems in the request,
+// or to all of the user's systems. Notification settings are merged.
+func ImportAlertBundle(e *core.RequestEvent) error {
+	userID := e.Auth.Id
+
+	reqData := struct {
+		Bundle    AlertBundle `json:"bundle"`
+		Systems   []string    `json:"systems"`
+		Overwrite bool        `json:"overwrite"`
+	}{}
+	err := e.BindBody(&reqData)
+	if err != nil || userID == "" || reqData.Bundle.Version != alertBundleVersion {
+		return e.BadRequestError("Bad data", err)
+	}
+	for _, rule := range reqData.Bundle.Rules {
+		if rule.Name == "" {
+			return e.BadRequestError("Bad data", errors.New("rule without name"))
+		}
+	}
+
+	systems, err := findUserSystems(e.App, userID)
+	if err != nil {
+		return err
+	}
+	defaultSystems := make([]string, 0, len(systems))
+	idsByName := make(map[string][]string, len(systems))
+	for _, system := range systems {
+		defaultSystems = append(defaultSystems, system.Id)
+		idsByName[system.GetString("name")] = append(idsByName[system.GetString("name")], system.Id)
+	}
+	if len(reqData.Systems) > 0 {
+		for _, systemId := range reqData.Systems {
+			if !slices.Contains(defaultSystems, systemId) {
+				return e.BadRequestError("Invalid system: "+systemId, nil)
+			}
+		}
+		defaultSystems = reqData.Systems
+	}
+
+	alertsCollection, err := e.App.FindCachedCollectionByNameOrId("alerts")
+	if err != nil {
+		return err
+	}
+
+	var created, updated int
+	unmatched := []string{}
+	err = e.App.RunInTransaction(func(txApp core.App) error {
+		for _, rule := range reqData.Bundle.Rules {
+			targets := defaultSystems
+			if len(rule.Systems) > 0 {
+				targets = nil
+				for _, name := range rule.Systems {
+					ids, ok := idsByName[name]
+					if !ok && !slices.Contains(unmatched, name) {
+						unmatched = append(unmatched, name)
+					}
+					targets = append(targets, ids...)
+				}
+			}
+			for _, systemId := range targets {
+				isNew, saved, err := upsertAlert(txApp, alertsCollection, userID, systemId, rule.Name, rule.Value, rule.Min, rule.Target, reqData.Overwrite)
+				if err != nil {
+					return err
+				}
+				switch {
+				case isNew:
+					created++
+				case saved:
+					updated++
+				}
+			}
+		}
+		if reqData.Bundle.Notifications != nil {
+			return mergeNotificationSettings(txApp, userID, reqData.Bundle.Notifications)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slices.Sort(unmatched)
+	return e.JSON(http.StatusOK, map[string]any{"success": true, "created": created, "updated": updated, "unmatched": unmatched})
+}
+
+// findUserSystems returns the systems the user has access to, directly or through a team.
+func findUserSystems(app core.App, userID string) ([]*core.Record, error) {
+	return app.FindRecordsByFilter("systems", "users.id ?= {:user} || team.members.id ?= {:user}", "name", -1, 0, dbx.Params{"user": userID})
+}
+
+// mergeNotificationSettings adds emails, webhooks and routes to the user's settings,
+// creating the settings record if needed. Other settings are kept.
+func mergeNotificationSettings(txApp core.App, userID string, notifications *UserNotificationSettings) error {
+	record, err := txApp.FindFirstRecordByFilter("user_settings", "user={:user}", dbx.Params{"user": userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		collection, err := txApp.FindCachedCollectionByNameOrId("user_settings")
+		if err != nil {
+			return err
+		}
+		record = core.NewRecord(collection)
+		record.Set("user", userID)
+		// defaults are set by the create hook
+		if err := txApp.Save(record); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	settings := map[string]any{}
+	record.UnmarshalJSONField("settings", &settings)
+	current := UserNotificationSettings{Emails: []string{}, Webhooks: []string{}}
+	record.UnmarshalJSONField("settings", &current)
+	for _, email := range notifications.Emails {
+		if !slices.Contains(current.Emails, email) {
+			current.Emails = append(current.Emails, email)
+		}
+	}
+	for _, webhook := range notifications.Webhooks {
+		if !slices.Contains(current.Webhooks, webhook) {
+			current.Webhooks = append(current.Webhooks, webhook)
+		}
+	}
+	for _, route := range notifications.Routes {
+		if !slices.ContainsFunc(current.Routes, func(r NotificationRoute) bool { return reflect.DeepEqual(r, route) }) {
+			current.Routes = append(current.Routes, route)
+		}
+	}
+	settings["emails"] = current.Emails
+	settings["webhooks"] = current.Webhooks
+	if len(current.Routes) > 0 {
+		settings["routes"] = current.Routes
+	}
+	record.Set("settings", settings)
+	return txApp.Save(record)
+}
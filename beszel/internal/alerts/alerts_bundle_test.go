@@ -0,0 +1,174 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	beszelTests "beszel/internal/tests"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertBundleApi(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user1, _ := beszelTests.CreateUser(hub, "bundletest@example.com", "password")
+	user1Token, _ := user1.NewAuthToken()
+	user2, _ := beszelTests.CreateUser(hub, "bundletest2@example.com", "password")
+	user2Token, _ := user2.NewAuthToken()
+
+	createSystem := func(name string, users ...string) *core.Record {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":  name,
+			"users": users,
+			"host":  "127.0.0.1",
+		})
+		require.NoError(t, err)
+		return record
+	}
+	nas := createSystem("nas", user1.Id)
+	pi := createSystem("pi", user1.Id)
+	createSystem("other", user2.Id)
+	user2Nas := createSystem("nas", user2.Id)
+	user2Vps := createSystem("vps", user2.Id)
+
+	for _, alert := range []map[string]any{
+		{"name": "CPU", "system": nas.Id, "user": user1.Id, "value": 80, "min": 10},
+		{"name": "CPU", "system": pi.Id, "user": user1.Id, "value": 80, "min": 10},
+		{"name": "Temperature", "system": pi.Id, "user": user1.Id, "value": 70, "min": 5},
+		{"name": "Containers", "system": nas.Id, "user": user1.Id, "value": 3, "min": 1, "target": "blog"},
+	} {
+		_, err := beszelTests.CreateRecord(hub, "alerts", alert)
+		require.NoError(t, err)
+	}
+	settings, err := beszelTests.CreateRecord(hub, "user_settings", map[string]any{"user": user1.Id})
+	require.NoError(t, err)
+	settings.Set("settings", map[string]any{"chartTime": "1h", "emails": []string{"bundletest@example.com"}, "webhooks": []string{"ntfy://secret"}})
+	require.NoError(t, hub.Save(settings))
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	var exported json.RawMessage
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET no auth",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/alert-bundle",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:               "GET export without notifications",
+			Method:             http.MethodGet,
+			URL:                "/api/beszel/alert-bundle",
+			Headers:            map[string]string{"Authorization": user1Token},
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`{"name":"CPU","value":80,"min":10,"systems":["nas","pi"]}`, `{"name":"Temperature","value":70,"min":5,"systems":["pi"]}`, `{"name":"Containers","value":3,"min":1,"target":"blog","systems":["nas"]}`},
+			NotExpectedContent: []string{"ntfy://secret"},
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:            "GET export with notifications",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/alert-bundle?notifications=true",
+			Headers:         map[string]string{"Authorization": user1Token},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"version":1`, `"webhooks":["ntfy://secret"]`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				exported, _ = io.ReadAll(res.Body)
+			},
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+	require.NotEmpty(t, exported)
+
+	scenarios = []beszelTests.ApiScenario{
+		{
+			Name:            "POST import bad version",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-bundle",
+			Headers:         map[string]string{"Authorization": user2Token},
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Bad data"},
+			TestAppFactory:  testAppFactory,
+			Body:            jsonReader(map[string]any{"bundle": map[string]any{"version": 99}}),
+		},
+		{
+			Name:            "POST import with system of other user",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-bundle",
+			Headers:         map[string]string{"Authorization": user2Token},
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid system"},
+			TestAppFactory:  testAppFactory,
+			Body:            jsonReader(map[string]any{"bundle": map[string]any{"version": 1}, "systems": []string{pi.Id}}),
+		},
+		{
+			Name:            "POST import exported bundle into other user",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-bundle",
+			Headers:         map[string]string{"Authorization": user2Token},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"created":2`, `"unmatched":["pi"]`},
+			TestAppFactory:  testAppFactory,
+			Body:            jsonReader(map[string]any{"bundle": exported}),
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				count, _ := app.CountRecords("alerts", dbx.HashExp{"user": user2.Id, "system": user2Nas.Id, "name": "CPU", "value": 80, "min": 10})
+				assert.EqualValues(t, 1, count, "alert matched to system by name")
+				count, _ = app.CountRecords("alerts", dbx.HashExp{"user": user2.Id, "system": user2Nas.Id, "name": "Containers", "target": "blog"})
+				assert.EqualValues(t, 1, count, "alert target is imported")
+				record, err := app.FindFirstRecordByFilter("user_settings", "user={:user}", dbx.Params{"user": user2.Id})
+				require.NoError(t, err)
+				var settings map[string]any
+				require.NoError(t, record.UnmarshalJSONField("settings", &settings))
+				assert.Equal(t, []any{"bundletest2@example.com", "bundletest@example.com"}, settings["emails"], "emails are merged")
+				assert.Equal(t, []any{"ntfy://secret"}, settings["webhooks"])
+				assert.Equal(t, "1h", settings["chartTime"], "other settings are kept")
+			},
+		},
+		{
+			Name:            "POST import generic rules into selected systems",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-bundle",
+			Headers:         map[string]string{"Authorization": user2Token},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"created":1`, `"updated":1`},
+			TestAppFactory:  testAppFactory,
+			Body: jsonReader(map[string]any{
+				"bundle": map[string]any{
+					"version": 1,
+					"rules":   []map[string]any{{"name": "CPU", "value": 90, "min": 15}},
+				},
+				"systems":   []string{user2Nas.Id, user2Vps.Id},
+				"overwrite": true,
+			}),
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				count, _ := app.CountRecords("alerts", dbx.HashExp{"user": user2.Id, "name": "CPU", "value": 90, "min": 15})
+				assert.EqualValues(t, 2, count)
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,122 @@
+package alerts
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/hub/storage"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cast"
+)
+
+// handleContainerAlert triggers the Containers alert when a container is
+// unhealthy for the alert period, or restarts more times in the past hour than
+// the alert value. Unlike other system alerts it reads the container stats
+// records instead of averaging system stats. If the alert has a target, only
+// containers of that Compose project are checked.
+func (am *AlertManager) handleContainerAlert(systemRecord, alertRecord *core.Record, containers []*container.Stats, now time.Time) {
+	project := alertRecord.GetString("target")
+	if project != "" {
+		containers = projectContainers(containers, project)
+	}
+	if len(containers) == 0 {
+		return
+	}
+	min := max(1, cast.ToUint8(alertRecord.Get("min")))
+	records, err := am.hub.Storage().ContainerStats(storage.Query{
+		System: systemRecord.Id,
+		Type:   storage.Type1m,
+		Since:  now.Add(-time.Hour),
+	})
+	if err != nil {
+		return
+	}
+	threshold := alertRecord.GetFloat("value")
+	failing := failingContainers(containers, records, threshold, now.Add(-time.Duration(min)*time.Minute), min)
+
+	triggered := alertRecord.GetBool("triggered")
+	if triggered == (len(failing) > 0) {
+		return
+	}
+	descriptor := "Containers"
+	if project != "" {
+		descriptor = "Containers of " + project
+	}
+	if len(failing) > 0 {
+		descriptor = "Failing containers " + strings.Join(failing, ", ")
+		if project != "" {
+			descriptor = "Failing containers of " + project + " " + strings.Join(failing, ", ")
+		}
+	}
+	go am.sendSystemAlert(SystemAlertData{
+		systemRecord: systemRecord,
+		alertRecord:  alertRecord,
+		name:         "Containers",
+		unit:         " failing containers",
+		val:          float64(len(failing)),
+		threshold:    threshold,
+		triggered:    !triggered,
+		min:          min,
+		descriptor:   descriptor,
+	})
+}
+
+// projectContainers returns the containers of a Compose project
+func projectContainers(containers []*container.Stats, project string) []*container.Stats {
+	var matching []*container.Stats
+	for _, ctr := range containers {
+		if ctr.Project == project {
+			matching = append(matching, ctr)
+		}
+	}
+	return matching
+}
+
+// failingContainers names the containers that are unhealthy in all records
+// since the start of the alert period, or restarted more than maxRestarts times
+// since their first record of the past hour. Records are oldest first.
+func failingContainers(containers []*container.Stats, records []storage.ContainerStats, maxRestarts float64, since time.Time, min uint8) []string {
+	firstRestarts := make(map[string]uint32)
+	unhealthyRecords := make(map[string]int)
+	periodRecords := 0
+	for _, record := range records {
+		// subtract 10 seconds to give a small time buffer
+		inPeriod := !record.Created.Add(-time.Second * 10).Before(since)
+		if inPeriod {
+			periodRecords++
+		}
+		for _, stats := range record.Stats {
+			if _, ok := firstRestarts[stats.Name]; !ok {
+				firstRestarts[stats.Name] = stats.Restarts
+			}
+			if inPeriod && stats.Health == container.HealthUnhealthy {
+				unhealthyRecords[stats.Name]++
+			}
+		}
+	}
+
+	var failing []string
+	for _, ctr := range containers {
+		if ctr.Health == container.HealthUnhealthy &&
+			(min == 1 || (unhealthyRecords[ctr.Name] == periodRecords && float32(periodRecords) >= float32(min)/1.2)) {
+			failing = append(failing, ctr.Name+" (unhealthy)")
+			continue
+		}
+		// containers not in the records may have restarted before the past hour
+		first, ok := firstRestarts[ctr.Name]
+		if !ok {
+			continue
+		}
+		restarts := ctr.Restarts
+		// the count starts over when the container is recreated
+		if first <= ctr.Restarts {
+			restarts -= first
+		}
+		if float64(restarts) > maxRestarts {
+			failing = append(failing, fmt.Sprintf("%s (%d restarts)", ctr.Name, restarts))
+		}
+	}
+	return failing
+}
@@ -0,0 +1,229 @@
+package alerts
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// history disk usage is projected from, the retention of 120m records
+	diskForecastHistory = 7 * 24 * time.Hour
+	// 120m records needed to project disk usage, one day
+	diskForecastMinRecords = 12
+	// days of DiskFull alerts without a value
+	defaultDiskFullDays = 7
+)
+
+// DiskForecast is the projected time until a filesystem is full
+type DiskForecast struct {
+	Name  string  // "root" or the name of an extra filesystem
+	Used  float64 // GB
+	Total float64 // GB
+	Rate  float64 // GB per day, or growth per day for exponential trends
+	// Days until full, +Inf if usage isn't growing
+	Days        float64
+	Exponential bool // whether usage grows exponentially rather than linearly
+}
+
+// fitLine fits y = a + b*x by least squares and returns the slope b and the
+// coefficient of determination
+func fitLine(xs, ys []float64) (slope, r2 float64) {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var sxx, sxy, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0
+	}
+	slope = sxy / sxx
+	if syy == 0 {
+		return slope, 1
+	}
+	return slope, sxy * sxy / (sxx * syy)
+}
+
+// forecastDisk projects when a filesystem fills up from its usage over time,
+// in days, using a linear trend or an exponential one if it fits better
+func forecastDisk(name string, days, used []float64, total float64) DiskForecast {
+	latest := used[len(used)-1]
+	forecast := DiskForecast{Name: name, Used: latest, Total: total, Days: math.Inf(1)}
+	if latest >= total {
+		forecast.Days = 0
+		return forecast
+	}
+	slope, r2 := fitLine(days, used)
+	forecast.Rate = slope
+	if slope > 0 {
+		forecast.Days = (total - latest) / slope
+	}
+	// exponential trend, a line through the log of usage
+	logUsed := make([]float64, len(used))
+	for i, value := range used {
+		if value <= 0 {
+			return forecast
+		}
+		logUsed[i] = math.Log(value)
+	}
+	if growth, expR2 := fitLine(days, logUsed); growth > 0 && expR2 > r2 {
+		forecast.Exponential = true
+		forecast.Rate = math.Exp(growth) - 1
+		forecast.Days = math.Log(total/latest) / growth
+	}
+	return forecast
+}
+
+// forecastDisks projects when each filesystem of a system fills up from its
+// stats records, oldest first. Filesystems with fewer than a day of 120m
+// records aren't included.
+func forecastDisks(records []storage.SystemStats) []DiskForecast {
+	type usage struct {
+		days, used []float64
+		total      float64
+	}
+	filesystems := make(map[string]*usage)
+	var names []string
+	add := func(name string, day float64, fs *system.FsStats) {
+		if fs.DiskTotal <= 0 {
+			return
+		}
+		u, ok := filesystems[name]
+		if !ok {
+			u = &usage{}
+			filesystems[name] = u
+			names = append(names, name)
+		}
+		u.days = append(u.days, day)
+		u.used = append(u.used, fs.DiskUsed)
+		u.total = fs.DiskTotal
+	}
+	for i := range records {
+		stats := &records[i].Stats
+		day := float64(records[i].Created.Unix()) / 86400
+		add("root", day, &system.FsStats{DiskTotal: stats.DiskTotal, DiskUsed: stats.DiskUsed})
+		for name, fs := range stats.ExtraFs {
+			add(name, day, fs)
+		}
+	}
+	var forecasts []DiskForecast
+	for _, name := range names {
+		u := filesystems[name]
+		if len(u.used) < diskForecastMinRecords {
+			continue
+		}
+		forecasts = append(forecasts, forecastDisk(name, u.days, u.used, u.total))
+	}
+	return forecasts
+}
+
+// CheckDiskForecasts triggers DiskFull alerts of systems that are up when a
+// filesystem is projected to be full within the alert value in days, and
+// resolves them when none is. The target limits the alert to one filesystem.
+// Runs from a hub cron job, since usage trends change slowly.
+func (am *AlertManager) CheckDiskForecasts() error {
+	alertRecords, err := am.hub.FindAllRecords("alerts", dbx.HashExp{"name": "DiskFull"})
+	if err != nil || len(alertRecords) == 0 {
+		return err
+	}
+	now := am.clock.Now().UTC()
+	// forecasts by system, as systems may have alerts of several users
+	forecasts := make(map[string][]DiskForecast)
+	for _, alertRecord := range alertRecords {
+		systemId := alertRecord.GetString("system")
+		systemForecasts, ok := forecasts[systemId]
+		if !ok {
+			systemRecord, err := am.hub.FindRecordById("systems", systemId)
+			if err != nil || systemRecord.GetString("status") != "up" {
+				forecasts[systemId] = nil
+				continue
+			}
+			records, err := am.hub.Storage().SystemStats(storage.Query{
+				System: systemId,
+				Type:   storage.Type120m,
+				Since:  now.Add(-diskForecastHistory),
+			})
+			if err != nil {
+				return err
+			}
+			systemForecasts = forecastDisks(records)
+			forecasts[systemId] = systemForecasts
+		}
+		am.handleDiskFullAlert(alertRecord, systemForecasts)
+	}
+	return nil
+}
+
+// handleDiskFullAlert sends the DiskFull alert if its state changed with the
+// system's forecasts
+func (am *AlertManager) handleDiskFullAlert(alertRecord *core.Record, forecasts []DiskForecast) {
+	target := alertRecord.GetString("target")
+	var soonest *DiskForecast
+	for i := range forecasts {
+		if target != "" && forecasts[i].Name != target {
+			continue
+		}
+		if soonest == nil || forecasts[i].Days < soonest.Days {
+			soonest = &forecasts[i]
+		}
+	}
+	if soonest == nil {
+		return
+	}
+	horizon := alertRecord.GetFloat("value")
+	if horizon <= 0 {
+		horizon = defaultDiskFullDays
+	}
+	triggered := soonest.Days < horizon
+	if triggered == alertRecord.GetBool("triggered") {
+		return
+	}
+	systemRecord, err := am.hub.FindRecordById("systems", alertRecord.GetString("system"))
+	if err != nil {
+		return
+	}
+	am.sendSystemAlert(SystemAlertData{
+		systemRecord: systemRecord,
+		alertRecord:  alertRecord,
+		name:         "DiskFull",
+		unit:         " days",
+		// ten years if usage isn't growing, as JSON has no infinity
+		val:       min(soonest.Days, 3650),
+		threshold: horizon,
+		triggered: triggered,
+		target:    soonest.Name,
+		message:   diskForecastMessage(soonest, horizon, triggered),
+	})
+}
+
+// diskForecastMessage describes the forecast of a DiskFull alert
+func diskForecastMessage(forecast *DiskForecast, horizon float64, triggered bool) string {
+	usage := fmt.Sprintf("Usage of %s is %.2f of %.2f GB", forecast.Name, forecast.Used, forecast.Total)
+	var trend string
+	switch {
+	case forecast.Exponential:
+		trend = fmt.Sprintf("growing %.1f%% per day", forecast.Rate*100)
+	case forecast.Rate > 0:
+		trend = fmt.Sprintf("growing %.2f GB per day", forecast.Rate)
+	default:
+		trend = "not growing"
+	}
+	if triggered {
+		return fmt.Sprintf("%s and %s over the past week. At this rate it will be full in %.1f days.", usage, trend, forecast.Days)
+	}
+	return fmt.Sprintf("%s and %s over the past week. It's no longer projected to be full within %g days.", usage, trend, horizon)
+}
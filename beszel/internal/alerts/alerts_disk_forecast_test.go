@@ -0,0 +1,133 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"beszel/internal/alerts"
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	beszelTests "beszel/internal/tests"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diskRecords returns a week of 120m records with the root and data filesystem
+// usage of each record
+func diskRecords(end time.Time, rootUsed, dataUsed func(day float64) float64) []storage.SystemStats {
+	var records []storage.SystemStats
+	for i := 83; i >= 0; i-- {
+		created := end.Add(-time.Duration(i) * 2 * time.Hour)
+		day := float64(83-i) / 12
+		stats := system.Stats{DiskTotal: 500, DiskUsed: rootUsed(day)}
+		if dataUsed != nil {
+			stats.ExtraFs = map[string]*system.FsStats{"data": {DiskTotal: 1000, DiskUsed: dataUsed(day)}}
+		}
+		records = append(records, storage.SystemStats{Type: storage.Type120m, Created: created, Stats: stats})
+	}
+	return records
+}
+
+func TestForecastDisks(t *testing.T) {
+	end := time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)
+	linear := func(day float64) float64 { return 300 + 20*day }
+	doubling := func(day float64) float64 { return 10 * math.Pow(2, day/2) }
+
+	forecasts := alerts.ForecastDisks(diskRecords(end, linear, doubling))
+	require.Len(t, forecasts, 2)
+
+	root := forecasts[0]
+	assert.Equal(t, "root", root.Name)
+	assert.False(t, root.Exponential)
+	assert.InDelta(t, 20, root.Rate, 0.01)
+	assert.InDelta(t, linear(83.0/12), root.Used, 0.01)
+	// (500 - 438.33) / 20
+	assert.InDelta(t, 3.08, root.Days, 0.01)
+
+	data := forecasts[1]
+	assert.Equal(t, "data", data.Name)
+	assert.True(t, data.Exponential)
+	assert.InDelta(t, math.Sqrt2-1, data.Rate, 0.01)
+	// 2 * log2(1000 / 109.6)
+	assert.InDelta(t, 6.38, data.Days, 0.01)
+
+	flat := alerts.ForecastDisks(diskRecords(end, func(float64) float64 { return 100 }, nil))
+	require.Len(t, flat, 1)
+	assert.True(t, math.IsInf(flat[0].Days, 1), "usage isn't growing")
+
+	shrinking := alerts.ForecastDisks(diskRecords(end, func(day float64) float64 { return 400 - day }, nil))
+	assert.True(t, math.IsInf(shrinking[0].Days, 1), "usage is shrinking")
+
+	full := alerts.ForecastDisks(diskRecords(end, func(float64) float64 { return 500 }, nil))
+	assert.Zero(t, full[0].Days)
+
+	assert.Empty(t, alerts.ForecastDisks(diskRecords(end, linear, nil)[:11]), "less than a day of records")
+}
+
+func TestCheckDiskForecasts(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	// alerts are unique per user, system and name
+	var users []string
+	for _, email := range []string{"forecast1@example.com", "forecast2@example.com", "forecast3@example.com"} {
+		user, err := beszelTests.CreateUser(hub, email, "password")
+		require.NoError(t, err)
+		users = append(users, user.Id)
+	}
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "nas",
+		"users": users,
+		"host":  "127.0.0.1",
+	})
+	require.NoError(t, err)
+	systemRecord.Set("status", "up")
+	require.NoError(t, hub.SaveNoValidate(systemRecord))
+
+	// root fills up in about 3 days, data isn't growing
+	records := diskRecords(time.Now().UTC(),
+		func(day float64) float64 { return 300 + 20*day },
+		func(float64) float64 { return 100 })
+	for _, record := range records {
+		statsRecord, err := beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": systemRecord.Id,
+			"type":   record.Type,
+			"stats":  record.Stats,
+		})
+		require.NoError(t, err)
+		statsRecord.SetRaw("created", record.Created.Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(statsRecord))
+	}
+
+	createAlert := func(user string, days float64, target string) string {
+		record, err := beszelTests.CreateRecord(hub, "alerts", map[string]any{
+			"name":   "DiskFull",
+			"system": systemRecord.Id,
+			"user":   user,
+			"value":  days,
+			"target": target,
+		})
+		require.NoError(t, err)
+		return record.Id
+	}
+	weekAlert := createAlert(users[0], 7, "")
+	dayAlert := createAlert(users[1], 1, "")
+	dataAlert := createAlert(users[2], 7, "data")
+
+	require.NoError(t, hub.CheckDiskForecasts())
+
+	triggered := func(id string) bool {
+		record, err := hub.FindRecordById("alerts", id)
+		require.NoError(t, err)
+		return record.GetBool("triggered")
+	}
+	assert.True(t, triggered(weekAlert), "root is projected full within a week")
+	assert.False(t, triggered(dayAlert), "root isn't projected full within a day")
+	assert.False(t, triggered(dataAlert), "data isn't growing")
+}
@@ -0,0 +1,114 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// minutes of the window state changes of an alert are counted in
+	defaultFlapWindow = 30
+	// notifications of an alert sent within the window before it's flapping
+	defaultFlapThreshold = 4
+)
+
+// flapState tracks the state changes of an alert of a system to group the
+// notifications of a flapping alert, like a metric oscillating around its
+// threshold, into one notification per window.
+type flapState struct {
+	changes  []time.Time // times of state changes within the window
+	window   time.Duration
+	flapping bool
+	// notifications held back while flapping, and the latest of them
+	grouped   int
+	latest    AlertMessageData
+	summaryAt time.Time // when the grouped notifications are sent
+}
+
+// flapKey returns the key of an alert's flap state. Alerts without a system,
+// like test notifications, aren't tracked.
+func flapKey(data *AlertMessageData) string {
+	if data.SystemID == "" || data.Alert == "" {
+		return ""
+	}
+	return data.UserID + "/" + data.SystemID + "/" + data.Alert
+}
+
+// dampenFlapping records a state change of an alert and reports whether its
+// notification is held back because the alert is flapping. An alert is
+// flapping when it changes state more than the user's threshold within the
+// flap window, and stops when it hasn't changed for a whole window.
+func (am *AlertManager) dampenFlapping(data AlertMessageData, settings *UserNotificationSettings) bool {
+	key := flapKey(&data)
+	if key == "" || settings.FlapWindow <= 0 || settings.FlapThreshold <= 0 {
+		return false
+	}
+	now := am.clock.Now()
+	window := time.Duration(settings.FlapWindow) * time.Minute
+
+	am.flapsMu.Lock()
+	defer am.flapsMu.Unlock()
+	state, ok := am.flaps[key]
+	if !ok {
+		state = &flapState{}
+		am.flaps[key] = state
+	}
+	state.window = window
+	// forget changes before the window
+	for len(state.changes) > 0 && now.Sub(state.changes[0]) >= window {
+		state.changes = state.changes[1:]
+	}
+	state.changes = append(state.changes, now)
+	if !state.flapping && len(state.changes) > settings.FlapThreshold {
+		state.flapping = true
+		state.summaryAt = now.Add(window)
+	}
+	if !state.flapping {
+		return false
+	}
+	state.grouped++
+	state.latest = data
+	am.hub.Logger().Debug("Grouped notification of flapping alert", "system", data.System, "alert", data.Alert, "grouped", state.grouped)
+	return true
+}
+
+// sendFlappingSummaries sends the grouped notifications of flapping alerts
+// once per window, and once more when an alert stops flapping. Runs on each
+// tick of the alert worker.
+func (am *AlertManager) sendFlappingSummaries(now time.Time) {
+	var summaries []AlertMessageData
+	am.flapsMu.Lock()
+	for key, state := range am.flaps {
+		quiet := len(state.changes) == 0 || now.Sub(state.changes[len(state.changes)-1]) >= state.window
+		if state.grouped > 0 && (quiet || !now.Before(state.summaryAt)) {
+			summaries = append(summaries, flappingSummary(state))
+			state.grouped = 0
+			state.summaryAt = now.Add(state.window)
+		}
+		if quiet {
+			delete(am.flaps, key)
+		}
+	}
+	am.flapsMu.Unlock()
+
+	for _, data := range summaries {
+		settings, err := am.userNotificationSettings(data.UserID)
+		if err != nil {
+			continue
+		}
+		if err := am.deliverAlert(data, settings); err != nil {
+			am.hub.Logger().Error("Failed to send flapping alert", "err", err)
+		}
+	}
+}
+
+// flappingSummary returns the latest notification of a flapping alert with
+// the number of notifications grouped into it
+func flappingSummary(state *flapState) AlertMessageData {
+	data := state.latest
+	data.Occurrences = state.grouped
+	data.Title = fmt.Sprintf("%s (%d occurrences)", data.Title, state.grouped)
+	data.Message = fmt.Sprintf("This alert is flapping and changed state %d times in the past %d minutes. Its notifications were grouped, and this is its latest state.\n\n%s",
+		state.grouped, int(state.window.Minutes()), data.Message)
+	return data
+}
@@ -0,0 +1,73 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"testing"
+	"time"
+
+	"beszel/internal/alerts"
+	beszelTests "beszel/internal/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlappingAlerts(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	createUser := func(email string, settings map[string]any) string {
+		user, err := beszelTests.CreateUser(hub, email, "password123")
+		require.NoError(t, err)
+		record, err := beszelTests.CreateRecord(hub, "user_settings", map[string]any{"user": user.Id})
+		require.NoError(t, err)
+		settings["webhooks"] = []string{"pager://" + user.Id}
+		record.Set("settings", settings)
+		require.NoError(t, hub.Save(record))
+		return user.Id
+	}
+	// default window of 30 minutes and threshold of 4
+	defaults := createUser("flapping@example.com", map[string]any{})
+	disabled := createUser("notflapping@example.com", map[string]any{"flapWindow": 0})
+	strict := createUser("strictflapping@example.com", map[string]any{"flapWindow": 10, "flapThreshold": 2})
+
+	provider := &recordingProvider{}
+	hub.RegisterProvider(provider)
+	// flap sends alternating triggered and resolved alerts
+	flap := func(userId, alert string, times int) {
+		for i := range times {
+			title := "cpu above threshold"
+			if i%2 == 1 {
+				title = "cpu below threshold"
+			}
+			require.NoError(t, hub.SendAlert(alerts.AlertMessageData{UserID: userId, SystemID: "sys1", Alert: alert, Title: title}))
+		}
+	}
+
+	flap(defaults, "CPU", 9)
+	assert.Len(t, provider.sent, 4, "notifications after the threshold are grouped")
+	flap(defaults, "Memory", 2)
+	assert.Len(t, provider.sent, 6, "other alerts of the system aren't grouped")
+	flap(disabled, "CPU", 9)
+	assert.Len(t, provider.sent, 15, "grouping is disabled")
+	flap(strict, "CPU", 5)
+	assert.Len(t, provider.sent, 17)
+
+	// grouped notifications are sent once the alert stops flapping
+	provider.sent = nil
+	hub.SendFlappingSummaries(time.Now())
+	assert.Empty(t, provider.sent, "alerts are still flapping")
+	hub.SendFlappingSummaries(time.Now().Add(11 * time.Minute))
+	assert.Equal(t, []string{strict + ": cpu above threshold (3 occurrences)"}, provider.sent)
+	provider.sent = nil
+	hub.SendFlappingSummaries(time.Now().Add(31 * time.Minute))
+	assert.Equal(t, []string{defaults + ": cpu above threshold (5 occurrences)"}, provider.sent)
+
+	// the alert isn't flapping anymore
+	provider.sent = nil
+	flap(strict, "CPU", 1)
+	assert.Len(t, provider.sent, 1)
+}
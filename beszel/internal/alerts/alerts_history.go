@@ -40,7 +40,8 @@ func updateHistoryOnAlertUpdate(e *core.RecordEvent) error {
 	return e.Next()
 }
 
-// resolveAlertHistoryRecord sets the resolved field to the current time
+// resolveAlertHistoryRecord sets the resolved field to the current time and
+// the duration to the seconds since the alert was triggered
 func resolveAlertHistoryRecord(app core.App, alertRecordID string) error {
 	alertHistoryRecords, err := app.FindRecordsByFilter(
 		"alerts_history",
@@ -57,7 +58,9 @@ func resolveAlertHistoryRecord(app core.App, alertRecordID string) error {
 		return nil
 	}
 	alertHistoryRecord := alertHistoryRecords[0] // there should be only one record
-	alertHistoryRecord.Set("resolved", time.Now().UTC())
+	now := time.Now().UTC()
+	alertHistoryRecord.Set("resolved", now)
+	alertHistoryRecord.Set("duration", int(now.Sub(alertHistoryRecord.GetDateTime("created").Time()).Seconds()))
 	err = app.Save(alertHistoryRecord)
 	if err != nil {
 		app.Logger().Error("Failed to resolve alert history", "err", err)
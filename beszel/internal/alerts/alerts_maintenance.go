@@ -0,0 +1,145 @@
+package alerts
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/cron"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Maintenance window modes
+const (
+	// notifications aren't sent
+	MaintenanceSuppress = "suppress"
+	// notifications are sent with a maintenance note
+	MaintenanceMark = "mark"
+)
+
+// how long one-off maintenance windows are kept after they end
+const endedMaintenanceRetention = 7 * 24 * time.Hour
+
+// maintenanceWindow is a record of the maintenance_windows collection. It's
+// either a one-off window between start and end, or a recurring window that
+// starts at the times of a cron expression and lasts a number of minutes.
+type maintenanceWindow struct {
+	record   *core.Record
+	mode     string
+	start    time.Time
+	end      time.Time
+	schedule *cron.Schedule
+	duration int // minutes
+	location *time.Location
+	systems  []string
+	labels   map[string]string
+}
+
+// parseMaintenanceWindow reads a maintenance window from its record
+func parseMaintenanceWindow(record *core.Record) (*maintenanceWindow, error) {
+	window := &maintenanceWindow{
+		record:   record,
+		mode:     record.GetString("mode"),
+		start:    record.GetDateTime("start").Time(),
+		end:      record.GetDateTime("end").Time(),
+		duration: record.GetInt("duration"),
+		location: time.UTC,
+		systems:  record.GetStringSlice("systems"),
+	}
+	if expr := record.GetString("schedule"); expr != "" {
+		schedule, err := cron.NewSchedule(expr)
+		if err != nil {
+			return nil, err
+		}
+		window.schedule = schedule
+		if window.duration == 0 {
+			return nil, errors.New("recurring windows require a duration")
+		}
+	} else if window.start.IsZero() || !window.end.After(window.start) {
+		return nil, errors.New("windows require a schedule, or a start and an end after it")
+	}
+	if timezone := record.GetString("timezone"); timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, err
+		}
+		window.location = location
+	}
+	if err := record.UnmarshalJSONField("labels", &window.labels); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// active reports whether the window is active at a time
+func (w *maintenanceWindow) active(now time.Time) bool {
+	if w.schedule == nil {
+		return !now.Before(w.start) && now.Before(w.end)
+	}
+	// whether the window started within its duration
+	now = now.In(w.location).Truncate(time.Minute)
+	for i := range w.duration {
+		if w.schedule.IsDue(cron.NewMoment(now.Add(-time.Duration(i) * time.Minute))) {
+			return true
+		}
+	}
+	return false
+}
+
+// appliesTo reports whether the window applies to a system, which is one of
+// its systems or has all of its labels. A window without systems or labels
+// applies to all systems.
+func (w *maintenanceWindow) appliesTo(systemId string, labels map[string]string) bool {
+	if len(w.systems) == 0 && len(w.labels) == 0 {
+		return true
+	}
+	if slices.Contains(w.systems, systemId) {
+		return true
+	}
+	return len(w.labels) > 0 && matchLabels(w.labels, labels)
+}
+
+// validateMaintenanceWindow checks the schedule and time zone of a new or
+// updated maintenance window.
+func validateMaintenanceWindow(e *core.RecordRequestEvent) error {
+	if _, err := parseMaintenanceWindow(e.Record); err != nil {
+		return e.BadRequestError("Invalid maintenance window: "+err.Error(), nil)
+	}
+	return e.Next()
+}
+
+// maintenanceMode returns the mode of the user's active maintenance windows
+// that apply to a system, or an empty string if there are none. Suppressing
+// windows take precedence over marking ones.
+func (am *AlertManager) maintenanceMode(userId, systemId string, labels map[string]string) string {
+	records, err := am.hub.FindAllRecords("maintenance_windows", dbx.HashExp{"user": userId})
+	if err != nil {
+		return ""
+	}
+	now := am.clock.Now()
+	mode := ""
+	for _, record := range records {
+		window, err := parseMaintenanceWindow(record)
+		if err != nil || !window.active(now) || !window.appliesTo(systemId, labels) {
+			continue
+		}
+		if window.mode == MaintenanceSuppress {
+			return MaintenanceSuppress
+		}
+		mode = MaintenanceMark
+	}
+	return mode
+}
+
+// DeleteEndedMaintenanceWindows deletes one-off maintenance windows, like ad
+// hoc silences, that ended more than a week ago.
+func (am *AlertManager) DeleteEndedMaintenanceWindows() error {
+	ended := types.NowDateTime().Add(-endedMaintenanceRetention)
+	_, err := am.hub.DB().
+		NewQuery("DELETE FROM maintenance_windows WHERE schedule = '' AND [[end]] != '' AND [[end]] < {:ended}").
+		Bind(dbx.Params{"ended": ended.String()}).
+		Execute()
+	return err
+}
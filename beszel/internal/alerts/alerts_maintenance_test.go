@@ -0,0 +1,197 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"beszel/internal/alerts"
+	beszelTests "beszel/internal/tests"
+
+	"github.com/pocketbase/pocketbase/core"
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "maintenance@example.com", "password123")
+	require.NoError(t, err)
+	settings, err := beszelTests.CreateRecord(hub, "user_settings", map[string]any{"user": user.Id})
+	require.NoError(t, err)
+	settings.Set("settings", map[string]any{"webhooks": []string{"pager://oncall"}})
+	require.NoError(t, hub.Save(settings))
+
+	createSystem := func(name string) string {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":  name,
+			"users": []string{user.Id},
+			"host":  "127.0.0.1",
+		})
+		require.NoError(t, err)
+		return record.Id
+	}
+	silenced := createSystem("silenced")
+	rebooting := createSystem("rebooting")
+	nightly := createSystem("nightly")
+	zoned := createSystem("zoned")
+	ended := createSystem("ended")
+	short := createSystem("short")
+	other := createSystem("other")
+
+	now := time.Now().UTC()
+	// cron expression of a time ten minutes ago in a location
+	startedAt := func(location *time.Location) string {
+		started := now.Add(-10 * time.Minute).In(location)
+		return fmt.Sprintf("%d %d * * *", started.Minute(), started.Hour())
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	for _, window := range []map[string]any{
+		{"name": "silence", "mode": "suppress", "systems": []string{silenced}, "start": now.Add(-time.Hour), "end": now.Add(time.Hour)},
+		{"name": "reboots", "mode": "mark", "labels": map[string]string{"role": "web*"}, "start": now.Add(-time.Hour), "end": now.Add(time.Hour)},
+		{"name": "nightly", "mode": "suppress", "systems": []string{nightly}, "schedule": startedAt(time.UTC), "duration": 30},
+		{"name": "zoned", "mode": "suppress", "systems": []string{zoned}, "schedule": startedAt(newYork), "duration": 30, "timezone": "America/New_York"},
+		{"name": "ended", "mode": "suppress", "systems": []string{ended}, "start": now.Add(-2 * time.Hour), "end": now.Add(-time.Hour)},
+		{"name": "short", "mode": "suppress", "systems": []string{short}, "schedule": startedAt(time.UTC), "duration": 5},
+	} {
+		window["user"] = user.Id
+		_, err := beszelTests.CreateRecord(hub, "maintenance_windows", window)
+		require.NoError(t, err)
+	}
+
+	provider := &recordingProvider{}
+	hub.RegisterProvider(provider)
+	send := func(systemId string, labels map[string]string) []string {
+		provider.sent = nil
+		require.NoError(t, hub.SendAlert(alerts.AlertMessageData{UserID: user.Id, SystemID: systemId, Title: "down", Labels: labels}))
+		return provider.sent
+	}
+
+	assert.Empty(t, send(silenced, nil), "silenced system")
+	assert.Empty(t, send(nightly, nil), "recurring window")
+	assert.Empty(t, send(zoned, nil), "recurring window in a time zone")
+	assert.Empty(t, send(silenced, map[string]string{"role": "web-1"}), "suppressing windows take precedence")
+	assert.Equal(t, []string{"oncall: [Maintenance] down"}, send(rebooting, map[string]string{"role": "web-1"}), "window of the system's labels")
+	assert.Equal(t, []string{"oncall: down"}, send(ended, nil), "window ended")
+	assert.Equal(t, []string{"oncall: down"}, send(short, nil), "recurring window ended")
+	assert.Equal(t, []string{"oncall: down"}, send(other, map[string]string{"role": "db"}), "no window")
+	assert.Equal(t, []string{"oncall: down"}, send("", nil), "alerts without a system")
+
+	// ended one-off windows are deleted after a week
+	record, err := hub.FindFirstRecordByData("maintenance_windows", "name", "ended")
+	require.NoError(t, err)
+	record.Set("start", now.Add(-9*24*time.Hour))
+	record.Set("end", now.Add(-8*24*time.Hour))
+	require.NoError(t, hub.Save(record))
+	require.NoError(t, hub.DeleteEndedMaintenanceWindows())
+	count, err := hub.CountRecords("maintenance_windows")
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, count)
+	_, err = hub.FindFirstRecordByData("maintenance_windows", "name", "ended")
+	assert.Error(t, err)
+}
+
+func TestMaintenanceWindowsApi(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "maintenanceapi@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	readonly, err := beszelTests.CreateUser(hub, "maintenancereadonly@example.com", "password123")
+	require.NoError(t, err)
+	readonly.Set("role", "readonly")
+	require.NoError(t, hub.Save(readonly))
+	readonlyToken, err := readonly.NewAuthToken()
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+	window := func(user *core.Record, fields map[string]any) map[string]any {
+		fields["user"] = user.Id
+		fields["name"] = "window"
+		fields["mode"] = "suppress"
+		return fields
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "create with invalid schedule",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/maintenance_windows/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(window(user, map[string]any{"schedule": "every night", "duration": 30})),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid maintenance window"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "create recurring without duration",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/maintenance_windows/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(window(user, map[string]any{"schedule": "0 3 * * 0"})),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"require a duration"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "create with invalid time zone",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/maintenance_windows/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(window(user, map[string]any{"schedule": "0 3 * * 0", "duration": 30, "timezone": "Mars/Olympus"})),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid maintenance window"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "create with end before start",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/maintenance_windows/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(window(user, map[string]any{"start": "2025-01-02 00:00:00Z", "end": "2025-01-01 00:00:00Z"})),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"start and an end after it"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "create as readonly user",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/maintenance_windows/records",
+			Headers:         map[string]string{"Authorization": readonlyToken},
+			Body:            jsonReader(window(readonly, map[string]any{"start": "2025-01-01 00:00:00Z", "end": "2025-01-02 00:00:00Z"})),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Failed to create record"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "create recurring window",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/maintenance_windows/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(window(user, map[string]any{"schedule": "0 3 * * 0", "duration": 60, "timezone": "Europe/Berlin"})),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"schedule":"0 3 * * 0"`},
+			ExpectedEvents:  map[string]int{"OnRecordCreateRequest": 1},
+			TestAppFactory:  testAppFactory,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
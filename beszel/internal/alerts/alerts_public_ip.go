@@ -0,0 +1,29 @@
+package alerts
+
+import (
+	"beszel/internal/entities/system"
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// handlePublicIpAlert sends a notification when the public IP of the system
+// changed since the previous update. Unlike other system alerts it has no
+// threshold and is never triggered, so each change sends a single notification.
+func (am *AlertManager) handlePublicIpAlert(systemRecord, alertRecord *core.Record, data *system.CombinedData, prevIp string) {
+	if prevIp == "" || data.Info.PublicIp == "" {
+		return
+	}
+	systemName := systemRecord.GetString("name")
+	go am.SendAlert(AlertMessageData{
+		UserID:   alertRecord.GetString("user"),
+		Title:    fmt.Sprintf("%s public IP changed", systemName),
+		Message:  fmt.Sprintf("Public IP changed from %s to %s.", prevIp, data.Info.PublicIp),
+		Link:     am.hub.MakeLink("system", systemName),
+		LinkText: "View " + systemName,
+		SystemID: systemRecord.Id,
+		System:   systemName,
+		Alert:    "PublicIp",
+		Labels:   SystemLabels(systemRecord),
+	})
+}
@@ -0,0 +1,402 @@
+package alerts
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Max conditions of each group of an alert rule
+const maxRuleConditions = 10
+
+// RuleCondition compares a metric of a system with a value, e.g. cpu > 90.
+// Sensors are compared with the "sensor:<name>" metric.
+type RuleCondition struct {
+	Metric   string  `json:"metric"`
+	Operator string  `json:"op"`
+	Value    float64 `json:"value"`
+}
+
+// ruleMetric gets a metric from stats, and whether the stats have it
+type ruleMetric struct {
+	unit  string
+	value func(stats *system.Stats) (float64, bool)
+}
+
+// Metrics that alert rules can compare, by name
+var ruleMetrics = map[string]ruleMetric{
+	"cpu":    {"%", func(s *system.Stats) (float64, bool) { return s.Cpu, true }},
+	"memory": {"%", func(s *system.Stats) (float64, bool) { return s.MemPct, true }},
+	"disk":   {"%", func(s *system.Stats) (float64, bool) { return s.DiskPct, true }},
+	"swap": {"%", func(s *system.Stats) (float64, bool) {
+		return s.SwapUsed / s.Swap * 100, s.Swap > 0
+	}},
+	"bandwidth": {" MB/s", func(s *system.Stats) (float64, bool) { return s.NetworkSent + s.NetworkRecv, true }},
+	"diskread":  {" MB/s", func(s *system.Stats) (float64, bool) { return s.DiskReadPs, true }},
+	"diskwrite": {" MB/s", func(s *system.Stats) (float64, bool) { return s.DiskWritePs, true }},
+	"load1":     {"", func(s *system.Stats) (float64, bool) { return s.LoadAvg[0], true }},
+	"load5":     {"", func(s *system.Stats) (float64, bool) { return s.LoadAvg[1], true }},
+	"load15":    {"", func(s *system.Stats) (float64, bool) { return s.LoadAvg[2], true }},
+	"temperature": {"°C", func(s *system.Stats) (float64, bool) {
+		if len(s.Temperatures) == 0 {
+			return 0, false
+		}
+		return slices.Max(slices.Collect(maps.Values(s.Temperatures))), true
+	}},
+	"latency": {" ms", func(s *system.Stats) (float64, bool) { return s.Latency[0] + s.Latency[1], true }},
+}
+
+// metricValue returns a metric of rule conditions and anomaly alerts in stats,
+// and its unit
+func metricValue(metric string, stats *system.Stats) (value float64, unit string, ok bool) {
+	if sensor, isSensor := strings.CutPrefix(metric, "sensor:"); isSensor {
+		if temp, ok := stats.Temperatures[sensor]; ok {
+			return temp, "°C", true
+		}
+		if data, ok := stats.GenericSensors[sensor]; ok {
+			return data.Value, data.Unit, true
+		}
+		return 0, "", false
+	}
+	m, ok := ruleMetrics[metric]
+	if !ok {
+		return 0, "", false
+	}
+	value, ok = m.value(stats)
+	return value, m.unit, ok
+}
+
+// validMetric reports whether metric is a named metric or a sensor
+func validMetric(metric string) bool {
+	_, ok := ruleMetrics[metric]
+	return ok || strings.HasPrefix(metric, "sensor:")
+}
+
+// value returns the metric of the condition in stats, and its unit
+func (c *RuleCondition) value(stats *system.Stats) (value float64, unit string, ok bool) {
+	return metricValue(c.Metric, stats)
+}
+
+// holds reports whether the condition holds for stats. A condition on a metric
+// the stats don't have doesn't hold.
+func (c *RuleCondition) holds(stats *system.Stats) bool {
+	value, _, ok := c.value(stats)
+	if !ok {
+		return false
+	}
+	switch c.Operator {
+	case ">":
+		return value > c.Value
+	case ">=":
+		return value >= c.Value
+	case "<":
+		return value < c.Value
+	case "<=":
+		return value <= c.Value
+	}
+	return false
+}
+
+// validate checks the metric and operator of the condition
+func (c *RuleCondition) validate() error {
+	if !validMetric(c.Metric) {
+		return fmt.Errorf("invalid metric: %s", c.Metric)
+	}
+	if !slices.Contains([]string{">", ">=", "<", "<="}, c.Operator) {
+		return fmt.Errorf("invalid operator: %s", c.Operator)
+	}
+	return nil
+}
+
+// String formats the condition, e.g. "cpu > 90"
+func (c *RuleCondition) String() string {
+	return fmt.Sprintf("%s %s %g", c.Metric, c.Operator, c.Value)
+}
+
+// conditionGroup is the conditions of a rule joined with AND or OR
+type conditionGroup struct {
+	any        bool
+	conditions []RuleCondition
+}
+
+// holds reports whether all or any of the conditions hold for stats
+func (g *conditionGroup) holds(stats *system.Stats) bool {
+	for i := range g.conditions {
+		if g.conditions[i].holds(stats) == g.any {
+			return g.any
+		}
+	}
+	return !g.any
+}
+
+// String formats the conditions, e.g. "cpu > 90 AND temperature > 80"
+func (g *conditionGroup) String() string {
+	parts := make([]string, len(g.conditions))
+	for i := range g.conditions {
+		parts[i] = g.conditions[i].String()
+	}
+	join := " AND "
+	if g.any {
+		join = " OR "
+	}
+	return strings.Join(parts, join)
+}
+
+// alertRule is a record of the alert_rules collection
+type alertRule struct {
+	record          *core.Record
+	trigger         conditionGroup
+	resolve         *conditionGroup // nil to resolve when the trigger conditions don't hold
+	duration        int             // minutes the trigger conditions must hold
+	resolveDuration int             // minutes the resolve conditions must hold
+	systems         []string
+	labels          map[string]string
+}
+
+// parseAlertRule reads an alert rule from its record
+func parseAlertRule(record *core.Record) (*alertRule, error) {
+	rule := &alertRule{
+		record:          record,
+		trigger:         conditionGroup{any: record.GetString("match") == "any"},
+		duration:        max(1, record.GetInt("duration")),
+		resolveDuration: record.GetInt("resolve_duration"),
+		systems:         record.GetStringSlice("systems"),
+	}
+	if rule.resolveDuration == 0 {
+		rule.resolveDuration = rule.duration
+	}
+	if err := record.UnmarshalJSONField("conditions", &rule.trigger.conditions); err != nil {
+		return nil, err
+	}
+	var resolveConditions []RuleCondition
+	if err := record.UnmarshalJSONField("resolve_conditions", &resolveConditions); err != nil {
+		return nil, err
+	}
+	if len(resolveConditions) > 0 {
+		rule.resolve = &conditionGroup{any: record.GetString("resolve_match") == "any", conditions: resolveConditions}
+	}
+	if err := record.UnmarshalJSONField("labels", &rule.labels); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// validate checks the conditions of the rule
+func (r *alertRule) validate() error {
+	if len(r.trigger.conditions) == 0 {
+		return errors.New("conditions are required")
+	}
+	if err := validateConditions(r.trigger.conditions); err != nil {
+		return err
+	}
+	if r.resolve != nil {
+		return validateConditions(r.resolve.conditions)
+	}
+	return nil
+}
+
+// validateConditions checks the number of conditions of a group and each condition
+func validateConditions(conditions []RuleCondition) error {
+	if len(conditions) > maxRuleConditions {
+		return errors.New("too many conditions")
+	}
+	for i := range conditions {
+		if err := conditions[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliesTo reports whether the rule applies to a system, which is one of its
+// systems or has all of its labels. Label values can be glob patterns. A rule
+// without systems or labels applies to all systems.
+func (r *alertRule) appliesTo(systemRecord *core.Record) bool {
+	if len(r.systems) == 0 && len(r.labels) == 0 {
+		return true
+	}
+	if slices.Contains(r.systems, systemRecord.Id) {
+		return true
+	}
+	return len(r.labels) > 0 && matchLabels(r.labels, SystemLabels(systemRecord))
+}
+
+// heldFor reports whether holds is true for every record of the last minutes.
+// Like threshold alerts, a few missing records are tolerated.
+func heldFor(holds func(stats *system.Stats) bool, records []storage.SystemStats, now time.Time, minutes int) bool {
+	since := now.Add(-time.Duration(minutes) * time.Minute)
+	count := 0
+	for i := range records {
+		// subtract 10 seconds to give a small time buffer
+		if records[i].Created.Add(-10 * time.Second).Before(since) {
+			continue
+		}
+		if !holds(&records[i].Stats) {
+			return false
+		}
+		count++
+	}
+	return count > 0 && float64(count) >= float64(minutes)/1.2
+}
+
+// validateAlertRule checks the conditions of a new or updated alert rule.
+func validateAlertRule(e *core.RecordRequestEvent) error {
+	rule, err := parseAlertRule(e.Record)
+	if err != nil {
+		return e.BadRequestError("Invalid alert rule", err)
+	}
+	if err := rule.validate(); err != nil {
+		return e.BadRequestError("Invalid alert rule: "+err.Error(), nil)
+	}
+	return e.Next()
+}
+
+// handleAlertRules triggers and resolves the alert rules of the system's users
+// and team members that apply to the system.
+func (am *AlertManager) handleAlertRules(systemRecord *core.Record, data *system.CombinedData) error {
+	users := SystemUserIDs(am.hub, systemRecord)
+	if len(users) == 0 {
+		return nil
+	}
+	userIds := make([]any, len(users))
+	for i, user := range users {
+		userIds[i] = user
+	}
+	ruleRecords, err := am.hub.FindAllRecords("alert_rules", dbx.In("user", userIds...))
+	if err != nil || len(ruleRecords) == 0 {
+		return err
+	}
+
+	var rules []*alertRule
+	window := 0
+	for _, record := range ruleRecords {
+		rule, err := parseAlertRule(record)
+		if err != nil || !rule.appliesTo(systemRecord) {
+			continue
+		}
+		rules = append(rules, rule)
+		window = max(window, rule.duration, rule.resolveDuration)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	now := systemRecord.GetDateTime("updated").Time().UTC()
+	records, err := am.hub.Storage().SystemStats(storage.Query{
+		System: systemRecord.Id,
+		Type:   storage.Type1m,
+		// subtract some time to give us a bit of buffer
+		Since: now.Add(-time.Duration(window)*time.Minute - 90*time.Second),
+	})
+	if err != nil || len(records) == 0 {
+		return err
+	}
+
+	for _, rule := range rules {
+		var triggered []string
+		_ = rule.record.UnmarshalJSONField("triggered", &triggered)
+		wasTriggered := slices.Contains(triggered, systemRecord.Id)
+		if wasTriggered {
+			resolved := func(stats *system.Stats) bool { return !rule.trigger.holds(stats) }
+			if rule.resolve != nil {
+				resolved = rule.resolve.holds
+			}
+			if heldFor(resolved, records, now, rule.resolveDuration) && am.setAlertRuleState(rule, systemRecord, false) {
+				am.SendAlert(am.alertRuleMessage(rule, systemRecord, false, &records[len(records)-1].Stats))
+			}
+		} else if heldFor(rule.trigger.holds, records, now, rule.duration) && am.setAlertRuleState(rule, systemRecord, true) {
+			am.SendAlert(am.alertRuleMessage(rule, systemRecord, true, &records[len(records)-1].Stats))
+		}
+	}
+	return nil
+}
+
+// setAlertRuleState saves whether the rule is triggered for the system and
+// reports whether that changed. The record is read again, since systems are
+// updated concurrently.
+func (am *AlertManager) setAlertRuleState(rule *alertRule, systemRecord *core.Record, triggered bool) bool {
+	am.rulesMu.Lock()
+	defer am.rulesMu.Unlock()
+
+	record, err := am.hub.FindRecordById("alert_rules", rule.record.Id)
+	if err != nil {
+		return false
+	}
+	var systemIds []string
+	_ = record.UnmarshalJSONField("triggered", &systemIds)
+	if slices.Contains(systemIds, systemRecord.Id) == triggered {
+		return false
+	}
+	if triggered {
+		systemIds = append(systemIds, systemRecord.Id)
+	} else {
+		systemIds = slices.DeleteFunc(systemIds, func(id string) bool { return id == systemRecord.Id })
+	}
+	record.Set("triggered", systemIds)
+	if err := am.hub.SaveNoValidate(record); err != nil {
+		am.hub.Logger().Error("Failed to save alert rule", "rule", record.Id, "err", err)
+		return false
+	}
+	return true
+}
+
+// alertRuleMessage returns the notification of a rule triggering or resolving
+func (am *AlertManager) alertRuleMessage(rule *alertRule, systemRecord *core.Record, triggered bool, stats *system.Stats) AlertMessageData {
+	systemName := systemRecord.GetString("name")
+	ruleName := rule.record.GetString("name")
+	minutes := rule.duration
+	if !triggered {
+		minutes = rule.resolveDuration
+	}
+	minutesLabel := "minute"
+	if minutes > 1 {
+		minutesLabel += "s"
+	}
+	var title, message string
+	switch {
+	case triggered:
+		title = fmt.Sprintf("%s %s triggered", systemName, ruleName)
+		message = fmt.Sprintf("%s for %d %s.", rule.trigger.String(), minutes, minutesLabel)
+	case rule.resolve != nil:
+		title = fmt.Sprintf("%s %s resolved", systemName, ruleName)
+		message = fmt.Sprintf("%s for %d %s.", rule.resolve.String(), minutes, minutesLabel)
+	default:
+		title = fmt.Sprintf("%s %s resolved", systemName, ruleName)
+		message = fmt.Sprintf("Not %s for %d %s.", rule.trigger.String(), minutes, minutesLabel)
+	}
+	// latest value of each metric of the rule's conditions
+	var values []string
+	for _, condition := range rule.trigger.conditions {
+		if value, unit, ok := condition.value(stats); ok {
+			values = append(values, fmt.Sprintf("%s %.2f%s", condition.Metric, value, unit))
+		}
+	}
+	if len(values) > 0 {
+		message += " Latest values: " + strings.Join(values, ", ") + "."
+	}
+	first := rule.trigger.conditions[0]
+	value, unit, _ := first.value(stats)
+	return AlertMessageData{
+		UserID:    rule.record.GetString("user"),
+		Title:     title,
+		Message:   message,
+		Link:      am.hub.MakeLink("system", systemName),
+		LinkText:  "View " + systemName,
+		SystemID:  systemRecord.Id,
+		System:    systemName,
+		Alert:     ruleName,
+		Resolved:  !triggered,
+		Value:     value,
+		Threshold: first.Value,
+		Unit:      unit,
+		Labels:    SystemLabels(systemRecord),
+	}
+}
@@ -0,0 +1,186 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+
+	"github.com/pocketbase/pocketbase/core"
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertRules(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	user, _ := beszelTests.CreateUser(hub, "rulestest@example.com", "password")
+
+	now := time.Now().UTC()
+
+	// createSystem creates a system with one stats record per minute, newest first
+	createSystem := func(name string, labels map[string]string, stats ...system.Stats) *core.Record {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":   name,
+			"users":  []string{user.Id},
+			"host":   "127.0.0.1",
+			"labels": labels,
+		})
+		require.NoError(t, err)
+		for i := range stats {
+			statsRecord, err := beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+				"system": record.Id,
+				"type":   "1m",
+				"stats":  stats[i],
+			})
+			require.NoError(t, err)
+			statsRecord.SetRaw("created", now.Add(-time.Duration(i)*time.Minute).Format(types.DefaultDateLayout))
+			require.NoError(t, hub.SaveNoValidate(statsRecord))
+		}
+		record.Set("status", "up")
+		require.NoError(t, hub.SaveNoValidate(record))
+		return record
+	}
+	repeat := func(stats system.Stats, minutes int) []system.Stats {
+		result := make([]system.Stats, minutes)
+		for i := range result {
+			result[i] = stats
+		}
+		return result
+	}
+	hot := system.Stats{Cpu: 95, Temperatures: map[string]float64{"cpu": 85}}
+	prod := map[string]string{"env": "prod"}
+
+	triggering := createSystem("triggering", prod, repeat(hot, 6)...)
+	cool := createSystem("cool", prod, repeat(system.Stats{Cpu: 95, Temperatures: map[string]float64{"cpu": 60}}, 6)...)
+	brief := createSystem("brief", prod, repeat(hot, 2)...)
+	staging := createSystem("staging", map[string]string{"env": "staging"}, repeat(hot, 6)...)
+	resolving := createSystem("resolving", prod, repeat(system.Stats{Cpu: 60}, 11)...)
+	resolvingBrief := createSystem("resolving-brief", prod,
+		append(repeat(system.Stats{Cpu: 60}, 5), repeat(hot, 6)...)...)
+	between := createSystem("between", prod, repeat(system.Stats{Cpu: 75}, 11)...)
+	memory := createSystem("memory", nil, system.Stats{Cpu: 10, MemPct: 60})
+
+	prodRule, err := beszelTests.CreateRecord(hub, "alert_rules", map[string]any{
+		"user":  user.Id,
+		"name":  "Overheating",
+		"match": "all",
+		"conditions": []map[string]any{
+			{"metric": "cpu", "op": ">", "value": 90},
+			{"metric": "temperature", "op": ">", "value": 80},
+		},
+		"duration":           5,
+		"resolve_match":      "all",
+		"resolve_conditions": []map[string]any{{"metric": "cpu", "op": "<", "value": 70}},
+		"resolve_duration":   10,
+		"labels":             prod,
+		"triggered":          []string{resolving.Id, resolvingBrief.Id, between.Id},
+	})
+	require.NoError(t, err)
+	anyRule, err := beszelTests.CreateRecord(hub, "alert_rules", map[string]any{
+		"user":  user.Id,
+		"name":  "Busy",
+		"match": "any",
+		"conditions": []map[string]any{
+			{"metric": "cpu", "op": ">", "value": 50},
+			{"metric": "memory", "op": ">", "value": 50},
+		},
+		"duration": 1,
+		"systems":  []string{memory.Id},
+	})
+	require.NoError(t, err)
+
+	for _, record := range []*core.Record{triggering, cool, brief, staging, resolving, resolvingBrief, between, memory} {
+		require.NoError(t, hub.HandleSystemAlerts(record, &system.CombinedData{}))
+	}
+
+	triggered := func(rule *core.Record) []string {
+		rule, err := hub.FindRecordById("alert_rules", rule.Id)
+		require.NoError(t, err)
+		var ids []string
+		require.NoError(t, rule.UnmarshalJSONField("triggered", &ids))
+		return ids
+	}
+
+	prodTriggered := triggered(prodRule)
+	assert.Contains(t, prodTriggered, triggering.Id, "all conditions held for the duration")
+	assert.NotContains(t, prodTriggered, cool.Id, "only one of the conditions held")
+	assert.NotContains(t, prodTriggered, brief.Id, "the conditions didn't hold for the duration")
+	assert.NotContains(t, prodTriggered, staging.Id, "the rule doesn't apply to the system's labels")
+	assert.NotContains(t, prodTriggered, resolving.Id, "the resolve conditions held for the resolve duration")
+	assert.Contains(t, prodTriggered, resolvingBrief.Id, "the resolve conditions didn't hold for the resolve duration")
+	assert.Contains(t, prodTriggered, between.Id, "neither the trigger nor the resolve conditions held")
+
+	assert.Equal(t, []string{memory.Id}, triggered(anyRule), "any condition held on the rule's system")
+}
+
+func TestAlertRulesApi(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	user, _ := beszelTests.CreateUser(hub, "rulesapi@example.com", "password")
+	userToken, _ := user.NewAuthToken()
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	rule := func(conditions ...map[string]any) map[string]any {
+		return map[string]any{
+			"user":       user.Id,
+			"name":       "rule",
+			"match":      "all",
+			"conditions": conditions,
+			"duration":   5,
+		}
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "create with invalid metric",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/alert_rules/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(rule(map[string]any{"metric": "uptime", "op": ">", "value": 1})),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"invalid metric: uptime"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "create with invalid operator",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/alert_rules/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(rule(map[string]any{"metric": "cpu", "op": "==", "value": 1})),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"invalid operator: =="},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:    "create with sensor condition",
+			Method:  http.MethodPost,
+			URL:     "/api/collections/alert_rules/records",
+			Headers: map[string]string{"Authorization": userToken},
+			Body: jsonReader(rule(
+				map[string]any{"metric": "cpu", "op": ">", "value": 90},
+				map[string]any{"metric": "sensor:nvme", "op": ">=", "value": 70},
+			)),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"sensor:nvme"`},
+			ExpectedEvents:  map[string]int{"OnRecordCreateRequest": 1},
+			TestAppFactory:  testAppFactory,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,157 @@
+package alerts
+
+import (
+	"beszel/internal/hub/storage"
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"slices"
+	"time"
+)
+
+const (
+	// history of the alert metric drawn in sparklines
+	sparklineHistory = time.Hour
+	// size of sparkline images in pixels
+	sparklineWidth   = 480
+	sparklineHeight  = 120
+	sparklinePadding = 8
+)
+
+// metrics of alerts drawn in sparklines, by alert name
+var sparklineMetrics = map[string]string{
+	"CPU":         "cpu",
+	"Memory":      "memory",
+	"Disk":        "disk",
+	"Bandwidth":   "bandwidth",
+	"Temperature": "temperature",
+	"LoadAvg1":    "load1",
+	"LoadAvg5":    "load5",
+	"LoadAvg15":   "load15",
+	"Latency":     "latency",
+}
+
+var (
+	sparklineTriggered = color.RGBA{0xe7, 0x4c, 0x3c, 0xff}
+	sparklineResolved  = color.RGBA{0x2e, 0xcc, 0x71, 0xff}
+	sparklineThreshold = color.RGBA{0x95, 0xa5, 0xa6, 0xff}
+)
+
+// metricHistory returns the values of an alert's metric in the system's 1m
+// stats records over the past hour, oldest first, or nil if the alert has no
+// metric to draw. The target of Anomaly alerts is their metric.
+func (am *AlertManager) metricHistory(systemId, alertName, target string) []float64 {
+	metric, ok := sparklineMetrics[alertName]
+	if alertName == "Anomaly" {
+		metric, ok = target, true
+		if metric == "" {
+			metric = "cpu"
+		}
+	}
+	if !ok {
+		return nil
+	}
+	records, err := am.hub.Storage().SystemStats(storage.Query{
+		System: systemId,
+		Type:   storage.Type1m,
+		Since:  am.clock.Now().UTC().Add(-sparklineHistory),
+	})
+	if err != nil {
+		return nil
+	}
+	var values []float64
+	for i := range records {
+		if value, _, ok := metricValue(metric, &records[i].Stats); ok {
+			values = append(values, value)
+		}
+	}
+	if len(values) < 2 {
+		return nil
+	}
+	return values
+}
+
+// renderSparkline draws values as a PNG line chart, red while the alert is
+// triggered and green once it's resolved, with the threshold as a dashed line.
+// A threshold of zero isn't drawn.
+func renderSparkline(values []float64, threshold float64, resolved bool) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, sparklineWidth, sparklineHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	low, high := slices.Min(values), slices.Max(values)
+	if threshold > 0 {
+		low, high = min(low, threshold), max(high, threshold)
+	}
+	if high == low {
+		high = low + 1
+	}
+	plotWidth, plotHeight := sparklineWidth-2*sparklinePadding, sparklineHeight-2*sparklinePadding
+	x := func(i int) int {
+		return sparklinePadding + i*plotWidth/max(1, len(values)-1)
+	}
+	y := func(value float64) int {
+		return sparklinePadding + plotHeight - int((value-low)/(high-low)*float64(plotHeight))
+	}
+
+	if threshold > 0 {
+		thresholdY := y(threshold)
+		for px := sparklinePadding; px < sparklineWidth-sparklinePadding; px++ {
+			if px/6%2 == 0 {
+				img.Set(px, thresholdY, sparklineThreshold)
+			}
+		}
+	}
+	lineColor := sparklineTriggered
+	if resolved {
+		lineColor = sparklineResolved
+	}
+	for i := 1; i < len(values); i++ {
+		drawLine(img, x(i-1), y(values[i-1]), x(i), y(values[i]), lineColor)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLine draws a two pixel wide line with Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		img.Set(x0+1, y0, c)
+		img.Set(x0, y0+1, c)
+		img.Set(x0+1, y0+1, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,65 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"beszel/internal/alerts"
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderSparkline(t *testing.T) {
+	data, err := alerts.RenderSparkline([]float64{10, 40, 95, 90, 60}, 80, false)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 480, img.Bounds().Dx())
+	assert.Equal(t, 120, img.Bounds().Dy())
+	// the peak is drawn in red at the top of the chart
+	r, g, b, _ := img.At(8+2*464/4, 8).RGBA()
+	assert.Equal(t, []uint32{0xe7, 0x4c, 0x3c}, []uint32{r >> 8, g >> 8, b >> 8})
+
+	// flat values
+	_, err = alerts.RenderSparkline([]float64{5, 5}, 0, true)
+	assert.NoError(t, err)
+}
+
+func TestMetricHistory(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "sparkline@example.com", "password123")
+	require.NoError(t, err)
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{"name": "nas", "users": []string{user.Id}, "host": "127.0.0.1"})
+	require.NoError(t, err)
+	now := time.Now().UTC()
+	// 1m records over the past two hours, newest first
+	for i := range 120 {
+		record, err := beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": systemRecord.Id,
+			"type":   "1m",
+			"stats":  system.Stats{Cpu: float64(i), MemPct: 50},
+		})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-time.Duration(i)*time.Minute).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+
+	cpu := hub.MetricHistory(systemRecord.Id, "CPU", "")
+	require.Len(t, cpu, 60, "past hour")
+	assert.Equal(t, 59.0, cpu[0], "oldest first")
+	assert.Equal(t, 0.0, cpu[59])
+	assert.Len(t, hub.MetricHistory(systemRecord.Id, "Anomaly", "memory"), 60)
+	assert.Nil(t, hub.MetricHistory(systemRecord.Id, "Smart", ""), "alert without a metric")
+}
@@ -25,7 +25,8 @@ type alertInfo struct {
 // startWorker is a long-running goroutine that processes alert tasks
 // every x seconds. It must be running to process status alerts.
 func (am *AlertManager) startWorker() {
-	tick := time.Tick(15 * time.Second)
+	ticker := am.clock.NewTicker(15 * time.Second)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-am.stopChan:
@@ -36,14 +37,14 @@ func (am *AlertManager) startWorker() {
 				am.pendingAlerts.Store(task.alertRecord.Id, &alertInfo{
 					systemName:  task.systemName,
 					alertRecord: task.alertRecord,
-					expireTime:  time.Now().Add(task.delay),
+					expireTime:  am.clock.Now().Add(task.delay),
 				})
 			case "cancel":
 				am.pendingAlerts.Delete(task.alertRecord.Id)
 			}
-		case <-tick:
+		case <-ticker.C():
 			// Check for expired alerts every tick
-			now := time.Now()
+			now := am.clock.Now()
 			for key, value := range am.pendingAlerts.Range {
 				info := value.(*alertInfo)
 				if now.After(info.expireTime) {
@@ -52,6 +53,7 @@ func (am *AlertManager) startWorker() {
 					am.pendingAlerts.Delete(key)
 				}
 			}
+			am.sendFlappingSummaries(now)
 		}
 	}
 }
@@ -162,11 +164,22 @@ func (am *AlertManager) sendStatusAlert(alertStatus string, systemName string, a
 	// 	return nil
 	// }
 
+	var labels map[string]string
+	systemId := alertRecord.GetString("system")
+	if systemRecord, err := am.hub.FindRecordById("systems", systemId); err == nil {
+		labels = SystemLabels(systemRecord)
+	}
+
 	return am.SendAlert(AlertMessageData{
 		UserID:   alertRecord.GetString("user"),
 		Title:    title,
 		Message:  message,
 		Link:     am.hub.MakeLink("system", systemName),
 		LinkText: "View " + systemName,
+		SystemID: systemId,
+		System:   systemName,
+		Alert:    "Status",
+		Resolved: alertStatus == "up",
+		Labels:   labels,
 	})
 }
@@ -2,18 +2,27 @@ package alerts
 
 import (
 	"beszel/internal/entities/system"
-	"encoding/json"
+	"beszel/internal/hub/storage"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
-	"github.com/pocketbase/pocketbase/tools/types"
 	"github.com/spf13/cast"
 )
 
 func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *system.CombinedData) error {
+	// clear the public IP change so other updates of the record don't notify it again
+	prevPublicIp := data.PrevPublicIp
+	data.PrevPublicIp = ""
+
+	if err := am.handleAlertRules(systemRecord, data); err != nil {
+		am.hub.Logger().Error("Failed to handle alert rules", "system", systemRecord.Id, "err", err)
+	}
+
 	alertRecords, err := am.hub.FindAllRecords("alerts",
 		dbx.NewExp("system={:system} AND name!='Status'", dbx.Params{"system": systemRecord.Id}),
 	)
@@ -39,6 +48,14 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 		case "Bandwidth":
 			val = data.Info.Bandwidth
 			unit = " MB/s"
+			// alerts with a target are on a single network interface
+			if iface := alertRecord.GetString("target"); iface != "" {
+				bandwidth, ok := data.Stats.NetworkInterfaces[iface]
+				if !ok {
+					continue
+				}
+				val = interfaceMegabytes(bandwidth)
+			}
 		case "Disk":
 			maxUsedPct := data.Info.DiskPct
 			for _, fs := range data.Stats.ExtraFs {
@@ -63,6 +80,87 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 		case "LoadAvg15":
 			val = data.Info.LoadAvg[2]
 			unit = ""
+		case "SwapIo":
+			val = swapMegabytes(data.Stats.SwapIo)
+			unit = " MB/s"
+		case "LoadCores":
+			cpus := logicalCpus(data.Info)
+			if cpus == 0 {
+				continue
+			}
+			val = data.Info.LoadAvg[loadWindow(alertRecord.GetString("target"))] / cpus
+			unit = "× cores"
+		case "Latency":
+			val = data.Info.Latency
+			unit = " ms"
+		case "Raid":
+			if len(data.Info.Raid) == 0 {
+				continue
+			}
+			val = data.Stats.RaidMissing
+			unit = " missing members"
+		case "Smart":
+			if len(data.Info.Smart) == 0 {
+				continue
+			}
+			val = data.Stats.SmartFailing
+			unit = " failing disks"
+		case "Services":
+			if len(data.Info.Services) == 0 {
+				continue
+			}
+			val = data.Stats.ServicesFailed
+			unit = " failed units"
+		case "Checks":
+			if len(data.Info.Checks) == 0 {
+				continue
+			}
+			val = data.Stats.ChecksFailing
+			unit = " failing checks"
+		case "SensorState":
+			if !hasStateSensors(data.Stats.GenericSensors) {
+				continue
+			}
+			val = data.Stats.SensorsAlerting
+			unit = " sensors in alert state"
+		case "SensorStale":
+			if len(data.Stats.GenericSensors) == 0 && data.Stats.SensorsStale == 0 {
+				continue
+			}
+			val = data.Stats.SensorsStale
+			unit = " stale sensors"
+		case "Containers":
+			am.handleContainerAlert(systemRecord, alertRecord, data.Containers, now)
+			continue
+		case "PublicIp":
+			am.handlePublicIpAlert(systemRecord, alertRecord, data, prevPublicIp)
+			continue
+		case "Anomaly":
+			am.handleAnomalyAlert(systemRecord, alertRecord, now)
+			continue
+		case "DiskFull":
+			// checked by CheckDiskForecasts
+			continue
+		case "Uptime":
+			// checked by CheckUptimeAlerts
+			continue
+		case "BuildCache":
+			if data.Stats.DockerDisk == nil {
+				continue
+			}
+			val = data.Stats.DockerDisk.BuildCache
+			unit = " GB"
+		case "TemperatureRise":
+			if len(data.Stats.Temperatures) == 0 {
+				continue
+			}
+			val = data.Stats.TempRise
+			unit = "°C/min"
+		case "Pressure":
+			if data.Stats.Pressure == nil {
+				continue
+			}
+			val, _ = pressureValue(data.Stats.Pressure, alertRecord.GetString("target"))
 		}
 
 		triggered := alertRecord.GetBool("triggered")
@@ -87,6 +185,32 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 			threshold:    threshold,
 			triggered:    triggered,
 			min:          min,
+			target:       alertRecord.GetString("target"),
+		}
+		switch name {
+		case "Bandwidth":
+			if alert.target != "" {
+				alert.descriptor = "Bandwidth of " + alert.target
+			}
+		case "Raid":
+			alert.descriptor = raidDescriptor(data.Info.Raid)
+		case "Smart":
+			alert.descriptor = smartDescriptor(data.Info.Smart)
+		case "Services":
+			alert.descriptor = servicesDescriptor(data.Info.Services)
+		case "Checks":
+			alert.descriptor = checksDescriptor(data.Info.Checks)
+		case "SensorState":
+			alert.descriptor = sensorStateDescriptor(data.Stats.GenericSensors)
+		case "SensorStale":
+			alert.descriptor = "Stale sensors"
+		case "SwapIo":
+			alert.descriptor = "Swap in and out"
+		case "LoadCores":
+			alert.descriptor = loadWindowNames[loadWindow(alert.target)] + " load average per core"
+		case "Pressure":
+			_, resource := pressureValue(data.Stats.Pressure, alert.target)
+			alert.descriptor = resource + " pressure"
 		}
 
 		// send alert immediately if min is 1 - no need to sum up values.
@@ -104,30 +228,18 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 		validAlerts = append(validAlerts, alert)
 	}
 
-	systemStats := []struct {
-		Stats   []byte         `db:"stats"`
-		Created types.DateTime `db:"created"`
-	}{}
-
-	err = am.hub.DB().
-		Select("stats", "created").
-		From("system_stats").
-		Where(dbx.NewExp(
-			"system={:system} AND type='1m' AND created > {:created}",
-			dbx.Params{
-				"system": systemRecord.Id,
-				// subtract some time to give us a bit of buffer
-				"created": oldestTime.Add(-time.Second * 90),
-			},
-		)).
-		OrderBy("created").
-		All(&systemStats)
+	systemStats, err := am.hub.Storage().SystemStats(storage.Query{
+		System: systemRecord.Id,
+		Type:   storage.Type1m,
+		// subtract some time to give us a bit of buffer
+		Since: oldestTime.Add(-time.Second * 90),
+	})
 	if err != nil || len(systemStats) == 0 {
 		return err
 	}
 
 	// get oldest record creation time from first record in the slice
-	oldestRecordTime := systemStats[0].Created.Time()
+	oldestRecordTime := systemStats[0].Created
 	// log.Println("oldestRecordTime", oldestRecordTime.String())
 
 	// Filter validAlerts to keep only those with time newer than oldestRecord
@@ -144,16 +256,11 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 		return nil
 	}
 
-	var stats SystemAlertStats
-
 	// we can skip the latest systemStats record since it's the current value
 	for i := range systemStats {
-		stat := systemStats[i]
+		stats := &systemStats[i].Stats
 		// subtract 10 seconds to give a small time buffer
-		systemStatsCreation := stat.Created.Time().Add(-time.Second * 10)
-		if err := json.Unmarshal(stat.Stats, &stats); err != nil {
-			return err
-		}
+		systemStatsCreation := systemStats[i].Created.Add(-time.Second * 10)
 		// log.Println("stats", stats)
 		for j := range validAlerts {
 			alert := &validAlerts[j]
@@ -170,9 +277,13 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 			case "CPU":
 				alert.val += stats.Cpu
 			case "Memory":
-				alert.val += stats.Mem
+				alert.val += stats.MemPct
 			case "Bandwidth":
-				alert.val += stats.NetSent + stats.NetRecv
+				if alert.target != "" {
+					alert.val += interfaceMegabytes(stats.NetworkInterfaces[alert.target])
+				} else {
+					alert.val += stats.NetworkSent + stats.NetworkRecv
+				}
 			case "Disk":
 				if alert.mapSums == nil {
 					alert.mapSums = make(map[string]float32, len(data.Stats.ExtraFs)+1)
@@ -181,7 +292,7 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 				if _, ok := alert.mapSums["root"]; !ok {
 					alert.mapSums["root"] = 0.0
 				}
-				alert.mapSums["root"] += float32(stats.Disk)
+				alert.mapSums["root"] += float32(stats.DiskPct)
 				// add extra disks
 				for key, fs := range data.Stats.ExtraFs {
 					if _, ok := alert.mapSums[key]; !ok {
@@ -197,7 +308,7 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 					if _, ok := alert.mapSums[key]; !ok {
 						alert.mapSums[key] = float32(0)
 					}
-					alert.mapSums[key] += temp
+					alert.mapSums[key] += float32(temp)
 				}
 			case "LoadAvg1":
 				alert.val += stats.LoadAvg[0]
@@ -205,6 +316,49 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 				alert.val += stats.LoadAvg[1]
 			case "LoadAvg15":
 				alert.val += stats.LoadAvg[2]
+			case "SwapIo":
+				alert.val += swapMegabytes(stats.SwapIo)
+			case "LoadCores":
+				alert.val += stats.LoadAvg[loadWindow(alert.target)] / logicalCpus(data.Info)
+			case "Latency":
+				alert.val += stats.Latency[0] + stats.Latency[1]
+			case "Raid":
+				alert.val += stats.RaidMissing
+			case "Smart":
+				alert.val += stats.SmartFailing
+			case "Services":
+				alert.val += stats.ServicesFailed
+			case "Checks":
+				alert.val += stats.ChecksFailing
+			case "SensorState":
+				// lowest count, so sensors must stay in an alert state for the whole period
+				if alert.count == 0 {
+					alert.val = stats.SensorsAlerting
+				} else {
+					alert.val = min(alert.val, stats.SensorsAlerting)
+				}
+			case "SensorStale":
+				// lowest count, so sensors must stay stale for the whole period
+				if alert.count == 0 {
+					alert.val = stats.SensorsStale
+				} else {
+					alert.val = min(alert.val, stats.SensorsStale)
+				}
+			case "BuildCache":
+				// records without disk usage don't count toward the average
+				if stats.DockerDisk == nil {
+					continue
+				}
+				alert.val += stats.DockerDisk.BuildCache
+			case "TemperatureRise":
+				alert.val += stats.TempRise
+			case "Pressure":
+				// records without pressure don't count toward the average
+				if stats.Pressure == nil {
+					continue
+				}
+				value, _ := pressureValue(stats.Pressure, alert.target)
+				alert.val += value
 			default:
 				continue
 			}
@@ -234,6 +388,8 @@ func (am *AlertManager) HandleSystemAlerts(systemRecord *core.Record, data *syst
 				}
 			}
 			alert.val = float64(maxTemp)
+		case "SensorState", "SensorStale":
+			// already the lowest count
 		default:
 			alert.val = alert.val / float64(alert.count)
 		}
@@ -262,6 +418,12 @@ func (am *AlertManager) sendSystemAlert(alert SystemAlertData) {
 	if alert.name == "Disk" {
 		alert.name += " usage"
 	}
+	switch alert.name {
+	case "BuildCache":
+		alert.name = "Build cache"
+	case "TemperatureRise":
+		alert.name = "Temperature rise"
+	}
 	// format LoadAvg5 and LoadAvg15
 	if after, ok := strings.CutPrefix(alert.name, "LoadAvg"); ok {
 		alert.name = after + "m Load"
@@ -274,9 +436,27 @@ func (am *AlertManager) sendSystemAlert(alert SystemAlertData) {
 	}
 
 	var subject string
-	if alert.triggered {
+	healthSubjects, isHealthAlert := healthAlertSubjects[alert.name]
+	switch {
+	case alert.name == "Anomaly" && alert.triggered:
+		subject = fmt.Sprintf("%s unusual %s", systemName, lowerMetricName(anomalyName(alert.target)))
+	case alert.name == "Anomaly":
+		subject = fmt.Sprintf("%s %s back to normal", systemName, lowerMetricName(anomalyName(alert.target)))
+	case alert.name == "DiskFull" && alert.triggered:
+		subject = fmt.Sprintf("%s %s disk projected full in %.1f days", systemName, alert.target, alert.val)
+	case alert.name == "DiskFull":
+		subject = fmt.Sprintf("%s %s disk no longer projected full", systemName, alert.target)
+	case alert.name == "Uptime" && alert.triggered:
+		subject = fmt.Sprintf("%s %s uptime below SLA target", systemName, alert.target)
+	case alert.name == "Uptime":
+		subject = fmt.Sprintf("%s %s uptime back above SLA target", systemName, alert.target)
+	case isHealthAlert && alert.triggered:
+		subject = fmt.Sprintf("%s %s", systemName, healthSubjects[0])
+	case isHealthAlert:
+		subject = fmt.Sprintf("%s %s", systemName, healthSubjects[1])
+	case alert.triggered:
 		subject = fmt.Sprintf("%s %s above threshold", systemName, titleAlertName)
-	} else {
+	default:
 		subject = fmt.Sprintf("%s %s below threshold", systemName, titleAlertName)
 	}
 	minutesLabel := "minute"
@@ -287,6 +467,9 @@ func (am *AlertManager) sendSystemAlert(alert SystemAlertData) {
 		alert.descriptor = alert.name
 	}
 	body := fmt.Sprintf("%s averaged %.2f%s for the previous %v %s.", alert.descriptor, alert.val, alert.unit, alert.min, minutesLabel)
+	if alert.message != "" {
+		body = alert.message
+	}
 
 	alert.alertRecord.Set("triggered", alert.triggered)
 	if err := am.hub.Save(alert.alertRecord); err != nil {
@@ -294,10 +477,168 @@ func (am *AlertManager) sendSystemAlert(alert SystemAlertData) {
 		return
 	}
 	am.SendAlert(AlertMessageData{
-		UserID:   alert.alertRecord.GetString("user"),
-		Title:    subject,
-		Message:  body,
-		Link:     am.hub.MakeLink("system", systemName),
-		LinkText: "View " + systemName,
+		UserID:    alert.alertRecord.GetString("user"),
+		Title:     subject,
+		Message:   body,
+		Link:      am.hub.MakeLink("system", systemName),
+		LinkText:  "View " + systemName,
+		SystemID:  alert.systemRecord.Id,
+		System:    systemName,
+		Alert:     alert.alertRecord.GetString("name"),
+		Resolved:  !alert.triggered,
+		Value:     alert.val,
+		Threshold: alert.threshold,
+		Unit:      alert.unit,
+		Labels:    SystemLabels(alert.systemRecord),
+		History:   am.metricHistory(alert.systemRecord.Id, alert.alertRecord.GetString("name"), alert.target),
 	})
 }
+
+// healthAlertSubjects are the subjects of alerts on counts of unhealthy devices,
+// when triggered and resolved
+var healthAlertSubjects = map[string][2]string{
+	"Raid":        {"RAID array degraded", "RAID arrays recovered"},
+	"Smart":       {"disk failing SMART health check", "disks passing SMART health check"},
+	"Services":    {"systemd unit failed", "systemd units recovered"},
+	"Checks":      {"health check failing", "health checks passing"},
+	"SensorState": {"sensor in alert state", "sensors back to normal state"},
+	"Containers":  {"container unhealthy", "containers healthy"},
+}
+
+// raidDescriptor names the degraded arrays for the alert message
+func raidDescriptor(arrays []system.RaidArray) string {
+	var degraded []string
+	for i := range arrays {
+		if missing := arrays[i].Missing(); missing > 0 {
+			degraded = append(degraded, fmt.Sprintf("%s (%d of %d)", arrays[i].Name, arrays[i].Active, arrays[i].Devices))
+		}
+	}
+	if len(degraded) == 0 {
+		return "RAID arrays"
+	}
+	return "Degraded arrays " + strings.Join(degraded, ", ")
+}
+
+// smartDescriptor names the failing disks for the alert message
+func smartDescriptor(disks []system.SmartDisk) string {
+	var failing []string
+	for _, disk := range disks {
+		if !disk.Passed {
+			failing = append(failing, disk.Name)
+		}
+	}
+	if len(failing) == 0 {
+		return "Disks"
+	}
+	return "Failing disks " + strings.Join(failing, ", ")
+}
+
+// servicesDescriptor names the failed units for the alert message
+func servicesDescriptor(units []system.ServiceUnit) string {
+	var failed []string
+	for _, unit := range units {
+		if unit.Failed() {
+			failed = append(failed, unit.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return "Units"
+	}
+	return "Failed units " + strings.Join(failed, ", ")
+}
+
+// checksDescriptor names the failing checks for the alert message
+func checksDescriptor(checks []system.CheckResult) string {
+	var failing []string
+	for _, check := range checks {
+		if !check.Up {
+			failing = append(failing, check.Name)
+		}
+	}
+	if len(failing) == 0 {
+		return "Checks"
+	}
+	return "Failing checks " + strings.Join(failing, ", ")
+}
+
+// hasStateSensors returns true if any of the sensors reports a state
+func hasStateSensors(sensors map[string]system.SensorData) bool {
+	for _, sensor := range sensors {
+		if sensor.State != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// sensorStateDescriptor names the sensors in an alert state for the alert message
+func sensorStateDescriptor(sensors map[string]system.SensorData) string {
+	var alerting []string
+	for _, name := range slices.Sorted(maps.Keys(sensors)) {
+		if sensor := sensors[name]; sensor.Alert {
+			alerting = append(alerting, fmt.Sprintf("%s (%s)", name, sensor.State))
+		}
+	}
+	if len(alerting) == 0 {
+		return "Sensors"
+	}
+	return "Sensors in alert state " + strings.Join(alerting, ", ")
+}
+
+// swapMegabytes returns the MB/s swapped in and out
+func swapMegabytes(swapIo [2]uint64) float64 {
+	return float64(swapIo[0]+swapIo[1]) / 1024 / 1024
+}
+
+// interfaceMegabytes returns the MB/s sent and received by a network interface
+func interfaceMegabytes(bandwidth [2]uint64) float64 {
+	return float64(bandwidth[0]+bandwidth[1]) / 1024 / 1024
+}
+
+// pressureValue returns the "some" pressure of a resource (cpu, memory or io)
+// and its display name, or of the resource with the highest pressure if
+// resource is empty or unknown.
+func pressureValue(p *system.Pressure, resource string) (float64, string) {
+	values := []struct {
+		resource, name string
+		value          float64
+	}{
+		{"cpu", "CPU", p.Cpu[0]},
+		{"memory", "Memory", p.Memory[0]},
+		{"io", "I/O", p.Io[0]},
+	}
+	best := values[0]
+	for _, v := range values {
+		if v.resource == resource {
+			return v.value, v.name
+		}
+		if v.value > best.value {
+			best = v
+		}
+	}
+	return best.value, best.name
+}
+
+// Names of the load average windows, by index in the load average array
+var loadWindowNames = [3]string{"1 minute", "5 minute", "15 minute"}
+
+// loadWindow returns the index of the load average window of a LoadCores
+// alert target ("1", "5" or "15" minutes), 1 minute by default.
+func loadWindow(target string) int {
+	switch strings.TrimSuffix(strings.TrimSpace(target), "m") {
+	case "5":
+		return 1
+	case "15":
+		return 2
+	}
+	return 0
+}
+
+// logicalCpus returns the number of logical CPUs of a system, which is the
+// load average of a fully used system.
+func logicalCpus(info system.Info) float64 {
+	if info.Threads > 0 {
+		return float64(info.Threads)
+	}
+	return float64(info.Cores)
+}
@@ -0,0 +1,157 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"beszel/internal/alerts"
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	beszelTests "beszel/internal/tests"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPressureValue(t *testing.T) {
+	pressure := &system.Pressure{
+		Cpu:    [2]float64{12.5, 0},
+		Memory: [2]float64{3.2, 1.1},
+		Io:     [2]float64{40.1, 22.7},
+	}
+	value, name := alerts.PressureValue(pressure, "")
+	assert.Equal(t, 40.1, value)
+	assert.Equal(t, "I/O", name)
+
+	value, name = alerts.PressureValue(pressure, "memory")
+	assert.Equal(t, 3.2, value)
+	assert.Equal(t, "Memory", name)
+
+	// unknown resources use the highest pressure
+	value, name = alerts.PressureValue(pressure, "disk")
+	assert.Equal(t, 40.1, value)
+	assert.Equal(t, "I/O", name)
+}
+
+func TestLoadCoresHelpers(t *testing.T) {
+	assert.Equal(t, 0, alerts.LoadWindow(""))
+	assert.Equal(t, 0, alerts.LoadWindow("1"))
+	assert.Equal(t, 1, alerts.LoadWindow(" 5m"))
+	assert.Equal(t, 2, alerts.LoadWindow("15"))
+	assert.Equal(t, 0, alerts.LoadWindow("30"))
+
+	assert.Equal(t, 8.0, alerts.LogicalCpus(system.Info{Cores: 4, Threads: 8}))
+	assert.Equal(t, 4.0, alerts.LogicalCpus(system.Info{Cores: 4}))
+	assert.Zero(t, alerts.LogicalCpus(system.Info{}))
+}
+
+func TestAnomalyBaseline(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 120m records of two days, with a nightly backup writing 100 MB/s from 2:00 to 4:00
+	var records []storage.SystemStats
+	for i := 1; i <= 24; i++ {
+		created := start.Add(time.Duration(i) * 2 * time.Hour)
+		write := 10.0
+		if created.Hour() == 4 {
+			write = 100
+		}
+		records = append(records, storage.SystemStats{Type: storage.Type120m, Created: created, Stats: system.Stats{DiskWritePs: write}})
+	}
+
+	_, _, ok := alerts.AnomalyBaselineAt(records[:11], "diskwrite", start)
+	assert.False(t, ok, "less than a day of records")
+	_, _, ok = alerts.AnomalyBaselineAt(records, "sensor:nvme", start)
+	assert.False(t, ok, "records without the metric")
+
+	// slots with fewer than 3 records use the overall baseline
+	mean, stddev, ok := alerts.AnomalyBaselineAt(records, "diskwrite", start.Add(3*time.Hour))
+	assert.True(t, ok)
+	assert.InDelta(t, 17.5, mean, 0.01)
+	assert.InDelta(t, 24.87, stddev, 0.01)
+
+	// slots with enough records use their own baseline
+	records = append(records, records...)
+	mean, _, _ = alerts.AnomalyBaselineAt(records, "diskwrite", start.Add(3*time.Hour))
+	assert.InDelta(t, 100, mean, 0.01)
+	mean, stddev, _ = alerts.AnomalyBaselineAt(records, "diskwrite", start.Add(13*time.Hour))
+	assert.InDelta(t, 10, mean, 0.01)
+	// at least a tenth of the mean
+	assert.InDelta(t, 1, stddev, 0.01)
+}
+
+func TestSensorStaleAlert(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "stale@example.com", "password")
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	sensors := map[string]system.SensorData{"garage": {Value: 18.5, Unit: "°C"}}
+
+	// createAlert creates a system with one stats record per minute, newest
+	// first, and a SensorStale alert over 5 minutes
+	createAlert := func(name string, triggered bool, stale ...float64) (*core.Record, *core.Record) {
+		systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":   name,
+			"users":  []string{user.Id},
+			"host":   "127.0.0.1",
+			"status": "up",
+		})
+		require.NoError(t, err)
+		for i := range stale {
+			statsRecord, err := beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+				"system": systemRecord.Id,
+				"type":   "1m",
+				"stats":  system.Stats{GenericSensors: sensors, SensorsStale: stale[i]},
+			})
+			require.NoError(t, err)
+			statsRecord.SetRaw("created", now.Add(-time.Duration(i)*time.Minute).Format(types.DefaultDateLayout))
+			require.NoError(t, hub.SaveNoValidate(statsRecord))
+		}
+		alertRecord, err := beszelTests.CreateRecord(hub, "alerts", map[string]any{
+			"name":      "SensorStale",
+			"system":    systemRecord.Id,
+			"user":      user.Id,
+			"value":     0,
+			"min":       5,
+			"triggered": triggered,
+		})
+		require.NoError(t, err)
+		return systemRecord, alertRecord
+	}
+	handle := func(systemRecord *core.Record, stale float64) {
+		data := &system.CombinedData{Stats: system.Stats{GenericSensors: sensors, SensorsStale: stale}}
+		require.NoError(t, hub.HandleSystemAlerts(systemRecord, data))
+	}
+	// waitTriggered waits for the alert to be saved as triggered or resolved
+	waitTriggered := func(alertRecord *core.Record, triggered bool) {
+		require.Eventually(t, func() bool {
+			record, err := hub.FindRecordById("alerts", alertRecord.Id)
+			return err == nil && record.GetBool("triggered") == triggered
+		}, 2*time.Second, 10*time.Millisecond)
+	}
+	triggeredAfter := func(alertRecord *core.Record) bool {
+		time.Sleep(100 * time.Millisecond)
+		record, err := hub.FindRecordById("alerts", alertRecord.Id)
+		require.NoError(t, err)
+		return record.GetBool("triggered")
+	}
+
+	stale, staleAlert := createAlert("stale", false, 1, 1, 2, 1, 1, 1)
+	handle(stale, 1)
+	waitTriggered(staleAlert, true)
+
+	brief, briefAlert := createAlert("brief", false, 1, 1, 0, 1, 1, 1)
+	handle(brief, 1)
+	assert.False(t, triggeredAfter(briefAlert), "a sensor wasn't stale for the whole period")
+
+	fresh, freshAlert := createAlert("fresh", true, 0, 0, 0, 0, 0, 0)
+	handle(fresh, 0)
+	waitTriggered(freshAlert, false)
+}
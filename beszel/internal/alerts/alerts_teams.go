@@ -0,0 +1,79 @@
+package alerts
+
+import (
+	"slices"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// alerts of a team's system sent within this interval are sent to the team's
+// channels once, as each member with the alert sends it
+const teamAlertInterval = 5 * time.Minute
+
+// SystemUserIDs returns the ids of the users with access to a system: its
+// users and the members of its team.
+func SystemUserIDs(app core.App, systemRecord *core.Record) []string {
+	userIds := systemRecord.GetStringSlice("users")
+	teamId := systemRecord.GetString("team")
+	if teamId == "" {
+		return userIds
+	}
+	team, err := app.FindRecordById("teams", teamId)
+	if err != nil {
+		return userIds
+	}
+	for _, member := range team.GetStringSlice("members") {
+		if !slices.Contains(userIds, member) {
+			userIds = append(userIds, member)
+		}
+	}
+	return userIds
+}
+
+// deliverTeamAlert sends an alert of a system in a team to the team's emails
+// and webhooks, unless the team already got the same alert of the system from
+// another member.
+func (am *AlertManager) deliverTeamAlert(data AlertMessageData) {
+	if data.SystemID == "" || data.Alert == "" {
+		return
+	}
+	systemRecord, err := am.hub.FindRecordById("systems", data.SystemID)
+	if err != nil || systemRecord.GetString("team") == "" {
+		return
+	}
+	team, err := am.hub.FindRecordById("teams", systemRecord.GetString("team"))
+	if err != nil {
+		return
+	}
+	settings := UserNotificationSettings{Emails: []string{}, Webhooks: []string{}}
+	_ = team.UnmarshalJSONField("emails", &settings.Emails)
+	_ = team.UnmarshalJSONField("webhooks", &settings.Webhooks)
+	if len(settings.Emails) == 0 && len(settings.Webhooks) == 0 {
+		return
+	}
+
+	key := team.Id + "/" + data.SystemID + "/" + data.Alert
+	if data.Resolved {
+		key += "/resolved"
+	}
+	now := am.clock.Now()
+	am.teamAlertsMu.Lock()
+	for k, sentAt := range am.teamAlerts {
+		if now.Sub(sentAt) >= teamAlertInterval {
+			delete(am.teamAlerts, k)
+		}
+	}
+	_, sent := am.teamAlerts[key]
+	if !sent {
+		am.teamAlerts[key] = now
+	}
+	am.teamAlertsMu.Unlock()
+	if sent {
+		return
+	}
+
+	if err := am.deliverAlert(data, &settings); err != nil {
+		am.hub.Logger().Error("Failed to send team alert", "team", team.GetString("name"), "err", err)
+	}
+}
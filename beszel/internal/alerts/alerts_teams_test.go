@@ -0,0 +1,82 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"testing"
+
+	"beszel/internal/alerts"
+	beszelTests "beszel/internal/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeamAlerts(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	createUser := func(email string) string {
+		user, err := beszelTests.CreateUser(hub, email, "password123")
+		require.NoError(t, err)
+		_, err = beszelTests.CreateRecord(hub, "user_settings", map[string]any{
+			"user":     user.Id,
+			"settings": map[string]any{"webhooks": []string{"pager://" + user.Id}},
+		})
+		require.NoError(t, err)
+		return user.Id
+	}
+	owner := createUser("owner@example.com")
+	member := createUser("member@example.com")
+	team, err := beszelTests.CreateRecord(hub, "teams", map[string]any{
+		"name":     "Client A",
+		"members":  []string{member},
+		"webhooks": []string{"pager://team"},
+	})
+	require.NoError(t, err)
+	teamSystem, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "client-a-web",
+		"host":  "10.0.0.1",
+		"users": []string{owner},
+		"team":  team.Id,
+	})
+	require.NoError(t, err)
+	otherSystem, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "other",
+		"host":  "10.0.0.2",
+		"users": []string{owner},
+	})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{owner, member}, alerts.SystemUserIDs(hub, teamSystem))
+	assert.Equal(t, []string{owner}, alerts.SystemUserIDs(hub, otherSystem))
+
+	provider := &recordingProvider{}
+	hub.RegisterProvider(provider)
+	send := func(userId, systemId string, resolved bool) {
+		require.NoError(t, hub.SendAlert(alerts.AlertMessageData{
+			UserID:   userId,
+			SystemID: systemId,
+			Alert:    "CPU",
+			Title:    "cpu alert",
+			Resolved: resolved,
+		}))
+	}
+
+	// the team gets an alert of its system once, not once per member
+	send(owner, teamSystem.Id, false)
+	send(member, teamSystem.Id, false)
+	assert.Equal(t, []string{"team: cpu alert", owner + ": cpu alert", member + ": cpu alert"}, provider.sent)
+
+	// resolved alerts are sent to the team too
+	provider.sent = nil
+	send(owner, teamSystem.Id, true)
+	assert.Equal(t, []string{"team: cpu alert", owner + ": cpu alert"}, provider.sent)
+
+	// alerts of systems without a team only go to the user
+	provider.sent = nil
+	send(owner, otherSystem.Id, false)
+	assert.Equal(t, []string{owner + ": cpu alert"}, provider.sent)
+}
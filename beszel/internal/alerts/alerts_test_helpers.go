@@ -0,0 +1,73 @@
+//go:build testing
+// +build testing
+
+package alerts
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// TESTING ONLY: ParseRetryAfter parses a Retry-After header
+func ParseRetryAfter(value string, maxDelay time.Duration) time.Duration {
+	return parseRetryAfter(value, maxDelay)
+}
+
+// TESTING ONLY: PressureValue returns the pressure of a resource for alerts
+func PressureValue(p *system.Pressure, resource string) (float64, string) {
+	return pressureValue(p, resource)
+}
+
+// TESTING ONLY: LoadWindow returns the load average index of a LoadCores alert target
+func LoadWindow(target string) int {
+	return loadWindow(target)
+}
+
+// TESTING ONLY: LogicalCpus returns the number of logical CPUs of a system
+func LogicalCpus(info system.Info) float64 {
+	return logicalCpus(info)
+}
+
+// TESTING ONLY: AnomalyBaselineAt learns the baseline of a metric from 120m
+// records and returns its mean and standard deviation at a time
+func AnomalyBaselineAt(records []storage.SystemStats, metric string, at time.Time) (mean, stddev float64, ok bool) {
+	baseline := learnAnomalyBaseline(records, metric)
+	if baseline == nil {
+		return 0, 0, false
+	}
+	mean, stddev = baseline.at(at, metric)
+	return mean, stddev, true
+}
+
+// TESTING ONLY: ForecastDisks projects when each filesystem fills up from stats records
+func ForecastDisks(records []storage.SystemStats) []DiskForecast {
+	return forecastDisks(records)
+}
+
+// TESTING ONLY: SendFlappingSummaries sends the grouped notifications of flapping alerts due at a time
+func (am *AlertManager) SendFlappingSummaries(now time.Time) {
+	am.sendFlappingSummaries(now)
+}
+
+// TESTING ONLY: RenderSparkline draws values as a PNG line chart
+func RenderSparkline(values []float64, threshold float64, resolved bool) ([]byte, error) {
+	return renderSparkline(values, threshold, resolved)
+}
+
+// TESTING ONLY: MetricHistory returns the recent values of an alert's metric
+func (am *AlertManager) MetricHistory(systemId, alertName, target string) []float64 {
+	return am.metricHistory(systemId, alertName, target)
+}
+
+// TESTING ONLY: SendNotification sends a notification to a URL and returns its error
+func (am *AlertManager) SendNotification(notificationUrl string, data AlertMessageData) error {
+	return am.sendNotification(notificationUrl, data)
+}
+
+// TESTING ONLY: AlertAnalyticsFor summarizes the alerts of a user triggered between from and to
+func AlertAnalyticsFor(app core.App, userID string, from, to time.Time) (*AlertAnalytics, error) {
+	return alertAnalytics(app, userID, from, to)
+}
@@ -0,0 +1,95 @@
+package alerts
+
+import (
+	"beszel/internal/records"
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// SLA target of Uptime alerts without a value, in percent
+	defaultUptimeTarget = 99.9
+	// window of Uptime alerts without a target
+	defaultUptimeWindow = "30d"
+)
+
+// CheckUptimeAlerts triggers Uptime alerts when the uptime of their system
+// over the target window (24h, 7d, 30d or 90d) drops below the alert value,
+// and resolves them when it recovers. Runs from a hub cron job.
+func (am *AlertManager) CheckUptimeAlerts() error {
+	alertRecords, err := am.hub.FindAllRecords("alerts", dbx.HashExp{"name": "Uptime"})
+	if err != nil || len(alertRecords) == 0 {
+		return err
+	}
+	now := am.clock.Now().UTC()
+	// uptime by system, as systems may have alerts of several users
+	uptimes := make(map[string][]records.Uptime)
+	for _, alertRecord := range alertRecords {
+		systemId := alertRecord.GetString("system")
+		systemUptime, ok := uptimes[systemId]
+		if !ok {
+			systemUptime, err = records.SystemUptime(am.hub, systemId, now)
+			if err != nil {
+				return err
+			}
+			uptimes[systemId] = systemUptime
+		}
+		am.handleUptimeAlert(alertRecord, systemUptime)
+	}
+	return nil
+}
+
+// handleUptimeAlert sends the Uptime alert if its state changed with the
+// system's uptime
+func (am *AlertManager) handleUptimeAlert(alertRecord *core.Record, uptimes []records.Uptime) {
+	window := alertRecord.GetString("target")
+	if window == "" {
+		window = defaultUptimeWindow
+	}
+	var uptime *records.Uptime
+	for i := range uptimes {
+		if uptimes[i].Window == window {
+			uptime = &uptimes[i]
+		}
+	}
+	// no uptime for unknown windows or systems that were never up or down
+	if uptime == nil || uptime.Up+uptime.Down == 0 {
+		return
+	}
+	target := alertRecord.GetFloat("value")
+	if target <= 0 {
+		target = defaultUptimeTarget
+	}
+	triggered := uptime.Percent < target
+	if triggered == alertRecord.GetBool("triggered") {
+		return
+	}
+	systemRecord, err := am.hub.FindRecordById("systems", alertRecord.GetString("system"))
+	if err != nil {
+		return
+	}
+	am.sendSystemAlert(SystemAlertData{
+		systemRecord: systemRecord,
+		alertRecord:  alertRecord,
+		name:         "Uptime",
+		unit:         "%",
+		val:          uptime.Percent,
+		threshold:    target,
+		triggered:    triggered,
+		target:       window,
+		message:      uptimeMessage(uptime, target, triggered),
+	})
+}
+
+// uptimeMessage describes the uptime of an Uptime alert
+func uptimeMessage(uptime *records.Uptime, target float64, triggered bool) string {
+	downtime := (time.Duration(uptime.Down) * time.Second).Round(time.Minute)
+	summary := fmt.Sprintf("Uptime over the last %s is %.3f%%, with %s of downtime in %d incidents.", uptime.Window, uptime.Percent, downtime, uptime.Incidents)
+	if triggered {
+		return fmt.Sprintf("%s It's below the SLA target of %g%%.", summary, target)
+	}
+	return fmt.Sprintf("%s It's back above the SLA target of %g%%.", summary, target)
+}
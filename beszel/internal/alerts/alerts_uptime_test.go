@@ -0,0 +1,78 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"testing"
+	"time"
+
+	beszelTests "beszel/internal/tests"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUptimeAlerts(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	// alerts are unique per user, system and name
+	var users []string
+	for _, email := range []string{"uptime1@example.com", "uptime2@example.com", "uptime3@example.com", "uptime4@example.com"} {
+		user, err := beszelTests.CreateUser(hub, email, "password")
+		require.NoError(t, err)
+		users = append(users, user.Id)
+	}
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "web",
+		"users": users,
+		"host":  "127.0.0.1",
+	})
+	require.NoError(t, err)
+
+	// down for an hour yesterday, up for the rest of the last 30 days
+	now := time.Now().UTC()
+	for _, change := range []struct {
+		age    time.Duration
+		status string
+	}{{40 * 24 * time.Hour, "up"}, {20 * time.Hour, "down"}, {19 * time.Hour, "up"}} {
+		record, err := beszelTests.CreateRecord(hub, "status_changes", map[string]any{"system": systemRecord.Id, "status": change.status})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-change.age).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+
+	createAlert := func(user string, target float64, window string) string {
+		record, err := beszelTests.CreateRecord(hub, "alerts", map[string]any{
+			"name":   "Uptime",
+			"system": systemRecord.Id,
+			"user":   user,
+			"value":  target,
+			"target": window,
+		})
+		require.NoError(t, err)
+		return record.Id
+	}
+	dayAlert := createAlert(users[0], 99.9, "24h")
+	monthAlert := createAlert(users[1], 99.9, "")
+	looseAlert := createAlert(users[2], 99, "30d")
+	unknownAlert := createAlert(users[3], 99.9, "1y")
+
+	require.NoError(t, hub.CheckUptimeAlerts())
+
+	triggered := func(id string) bool {
+		record, err := hub.FindRecordById("alerts", id)
+		require.NoError(t, err)
+		return record.GetBool("triggered")
+	}
+	assert.True(t, triggered(dayAlert), "uptime of the last day is about 95.8%")
+	assert.True(t, triggered(monthAlert), "uptime of the last 30 days is about 99.86%")
+	assert.False(t, triggered(looseAlert), "uptime of the last 30 days is above 99%")
+	assert.False(t, triggered(unknownAlert), "uptime isn't computed for unknown windows")
+
+	history, err := hub.FindFirstRecordByFilter("alerts_history", "alert_id={:id}", map[string]any{"id": dayAlert})
+	require.NoError(t, err)
+	assert.Equal(t, "Uptime", history.GetString("name"))
+}
@@ -0,0 +1,144 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// API base URL of Discord
+const discordAPIURL = "https://discord.com/api"
+
+// Colors of Discord embeds
+const (
+	discordTriggered = 0xe74c3c
+	discordResolved  = 0x2ecc71
+	discordInfo      = 0x95a5a6
+)
+
+type discordMessage struct {
+	Username  string         `json:"username,omitempty"`
+	AvatarURL string         `json:"avatar_url,omitempty"`
+	Embeds    []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Color       int                 `json:"color"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Image       *discordEmbedImage  `json:"image,omitempty"`
+	Timestamp   time.Time           `json:"timestamp"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbedImage struct {
+	URL string `json:"url"`
+}
+
+// DiscordProvider sends alerts to a Discord webhook for URLs like
+// discord://<token>@<webhook id>, the URL format of Shoutrrr's Discord
+// service. Alerts are sent as embeds colored by their state, with their
+// system, value and threshold as fields and a sparkline of the metric if
+// there is one. The username and avatar query params override the webhook's.
+type DiscordProvider struct {
+	Client *http.Client
+	APIURL string // API base URL
+}
+
+// NewDiscordProvider returns a DiscordProvider with default settings
+func NewDiscordProvider() *DiscordProvider {
+	return &DiscordProvider{
+		Client: &http.Client{Timeout: 10 * time.Second},
+		APIURL: discordAPIURL,
+	}
+}
+
+// Schemes returns the URL scheme of Discord
+func (p *DiscordProvider) Schemes() []string {
+	return []string{"discord"}
+}
+
+// Send posts an alert to the webhook
+func (p *DiscordProvider) Send(notificationUrl *url.URL, data AlertMessageData) error {
+	token, webhookId := notificationUrl.User.Username(), notificationUrl.Host
+	if token == "" || webhookId == "" {
+		return errors.New("discord: URL requires a token and webhook id")
+	}
+	embed := discordEmbed{
+		// embed titles are limited to 256 characters and descriptions to 4096
+		Title:       truncate(data.Title, 256),
+		Description: truncate(data.Message, 4000),
+		URL:         data.Link,
+		Color:       discordInfo,
+		Timestamp:   time.Now().UTC(),
+	}
+	if data.Alert != "" {
+		embed.Color = discordTriggered
+		if data.Resolved {
+			embed.Color = discordResolved
+		}
+	}
+	for _, field := range alertFields(data) {
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: field.Name, Value: field.Value, Inline: true})
+	}
+	query := notificationUrl.Query()
+	message := discordMessage{
+		Username:  query.Get("username"),
+		AvatarURL: query.Get("avatar"),
+	}
+
+	endpoint := p.APIURL + "/webhooks/" + url.PathEscape(webhookId) + "/" + url.PathEscape(token)
+	sparkline := alertSparkline(data)
+	if sparkline == nil {
+		message.Embeds = []discordEmbed{embed}
+		body, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		return p.post(endpoint, bytes.NewReader(body), "application/json")
+	}
+	// the image is attached to the message and shown in the embed
+	embed.Image = &discordEmbedImage{URL: "attachment://sparkline.png"}
+	message.Embeds = []discordEmbed{embed}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	body, contentType, err := multipartForm([][2]string{{"payload_json", string(payload)}}, "files[0]", "sparkline.png", sparkline)
+	if err != nil {
+		return err
+	}
+	return p.post(endpoint, body, contentType)
+}
+
+// post sends a message to the webhook
+func (p *DiscordProvider) post(endpoint string, body io.Reader, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "Beszel")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		// don't log the URL, which contains the token
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("discord returned %s: %s", resp.Status, readErrorBody(resp.Body))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"beszel/internal/alerts"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscordProvider(t *testing.T) {
+	type embed struct {
+		Title  string `json:"title"`
+		Color  int    `json:"color"`
+		Fields []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"fields"`
+		Image *struct {
+			URL string `json:"url"`
+		} `json:"image"`
+	}
+	type message struct {
+		Username string  `json:"username"`
+		Embeds   []embed `json:"embeds"`
+	}
+	var paths []string
+	var messages []message
+	var files []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var msg message
+		if r.Header.Get("Content-Type") == "application/json" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		} else {
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			require.NoError(t, json.Unmarshal([]byte(r.FormValue("payload_json")), &msg))
+			_, header, err := r.FormFile("files[0]")
+			require.NoError(t, err)
+			files = append(files, header.Filename)
+		}
+		messages = append(messages, msg)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	provider := alerts.NewDiscordProvider()
+	provider.APIURL = server.URL
+	send := func(rawUrl string, data alerts.AlertMessageData) error {
+		u, err := url.Parse(rawUrl)
+		require.NoError(t, err)
+		return provider.Send(u, data)
+	}
+	data := alerts.AlertMessageData{
+		Title:     "nas memory above threshold",
+		Message:   "Memory averaged 92.50% for the previous 10 minutes.",
+		System:    "nas",
+		Alert:     "Memory",
+		Value:     92.5,
+		Threshold: 90,
+		Unit:      "%",
+	}
+
+	require.NoError(t, send("discord://token@12345?username=Beszel", data))
+	assert.Equal(t, "/webhooks/12345/token", paths[0])
+	require.Len(t, messages[0].Embeds, 1)
+	assert.Equal(t, "Beszel", messages[0].Username)
+	assert.Equal(t, "nas memory above threshold", messages[0].Embeds[0].Title)
+	assert.Equal(t, 0xe74c3c, messages[0].Embeds[0].Color)
+	require.Len(t, messages[0].Embeds[0].Fields, 4)
+	assert.Equal(t, "Value", messages[0].Embeds[0].Fields[2].Name)
+	assert.Equal(t, "92.5%", messages[0].Embeds[0].Fields[2].Value)
+	assert.Nil(t, messages[0].Embeds[0].Image)
+
+	// resolved alerts with history are green with the sparkline attached
+	data.Resolved = true
+	data.History = []float64{95, 92, 85, 70}
+	require.NoError(t, send("discord://token@12345", data))
+	assert.Equal(t, 0x2ecc71, messages[1].Embeds[0].Color)
+	require.NotNil(t, messages[1].Embeds[0].Image)
+	assert.Equal(t, "attachment://sparkline.png", messages[1].Embeds[0].Image.URL)
+	assert.Equal(t, []string{"sparkline.png"}, files)
+
+	assert.ErrorContains(t, send("discord://12345", data), "requires a token")
+}
@@ -0,0 +1,178 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// Delay before retrying when the service asks for a retry without Retry-After
+	externalRetryDelay = 30 * time.Second
+	// Max size of a response body read from the service
+	externalMaxResponseSize = 64 * 1024
+)
+
+// ExternalAlert is the JSON body posted to external notification services
+type ExternalAlert struct {
+	Title       string    `json:"title"`
+	Message     string    `json:"message"`
+	Link        string    `json:"link,omitempty"`
+	LinkText    string    `json:"linkText,omitempty"`
+	System      string    `json:"system,omitempty"`
+	Alert       string    `json:"alert,omitempty"`
+	Status      string    `json:"status,omitempty"` // "triggered" or "resolved" for alerts on a system
+	Value       float64   `json:"value,omitempty"`
+	Threshold   float64   `json:"threshold,omitempty"`
+	Unit        string    `json:"unit,omitempty"`
+	Maintenance bool      `json:"maintenance,omitempty"` // sent during a maintenance window
+	Occurrences int       `json:"occurrences,omitempty"` // notifications grouped while flapping
+	Time        time.Time `json:"time"`
+	Attempt     int       `json:"attempt"`
+}
+
+// externalResponse is the optional JSON response of an external service
+type externalResponse struct {
+	Delivered *bool  `json:"delivered"`
+	Error     string `json:"error"`
+}
+
+// ExternalProvider posts alerts as JSON to a service, for URLs like
+// external://alerts.example.com/hook (HTTPS) or external+http://host:8080/hook.
+// Credentials in the URL are sent with basic auth.
+//
+// The service can answer 429 or 503 with a Retry-After header to have the
+// alert sent again later, and {"delivered": false, "error": "..."} to report
+// that it couldn't deliver the alert.
+type ExternalProvider struct {
+	Client        *http.Client
+	MaxRetries    int           // retries when the service asks for one
+	MaxRetryAfter time.Duration // longest delay accepted from Retry-After
+	Logger        *slog.Logger  // logs the result of retries
+}
+
+// NewExternalProvider returns an ExternalProvider with default settings
+func NewExternalProvider() *ExternalProvider {
+	return &ExternalProvider{
+		Client:        &http.Client{Timeout: 10 * time.Second},
+		MaxRetries:    3,
+		MaxRetryAfter: 5 * time.Minute,
+		Logger:        slog.Default(),
+	}
+}
+
+// Schemes returns the URL schemes of external services
+func (p *ExternalProvider) Schemes() []string {
+	return []string{"external", "external+http"}
+}
+
+// Send posts an alert to the service. If the service asks for a retry, the
+// alert is sent again in the background and Send returns nil.
+func (p *ExternalProvider) Send(notificationUrl *url.URL, data AlertMessageData) error {
+	endpoint := *notificationUrl
+	endpoint.Scheme = "https"
+	if notificationUrl.Scheme == "external+http" {
+		endpoint.Scheme = "http"
+	}
+	endpoint.User = nil
+
+	alert := ExternalAlert{
+		Title:       data.Title,
+		Message:     data.Message,
+		Link:        data.Link,
+		LinkText:    data.LinkText,
+		System:      data.System,
+		Alert:       data.Alert,
+		Value:       data.Value,
+		Threshold:   data.Threshold,
+		Unit:        data.Unit,
+		Maintenance: data.Maintenance,
+		Occurrences: data.Occurrences,
+		Time:        time.Now().UTC(),
+	}
+	if data.Alert != "" {
+		alert.Status = "triggered"
+		if data.Resolved {
+			alert.Status = "resolved"
+		}
+	}
+	return p.send(endpoint.String(), notificationUrl.User, alert, 1)
+}
+
+// send posts an alert and schedules a retry if the service asks for one.
+func (p *ExternalProvider) send(endpoint string, user *url.Userinfo, alert ExternalAlert, attempt int) error {
+	alert.Attempt = attempt
+	retryAfter, err := p.post(endpoint, user, alert)
+	if retryAfter == 0 || attempt > p.MaxRetries {
+		return err
+	}
+	p.Logger.Info("External alert service asked for a retry", "title", alert.Title, "after", retryAfter)
+	time.AfterFunc(retryAfter, func() {
+		if err := p.send(endpoint, user, alert, attempt+1); err != nil {
+			p.Logger.Error("Error sending external alert", "title", alert.Title, "attempt", attempt+1, "err", err)
+		}
+	})
+	return nil
+}
+
+// post posts an alert and returns the delay before a retry if the service
+// asks for one.
+func (p *ExternalProvider) post(endpoint string, user *url.Userinfo, alert ExternalAlert) (time.Duration, error) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Beszel")
+	if user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		err := fmt.Errorf("external alert service returned %s", resp.Status)
+		return parseRetryAfter(resp.Header.Get("Retry-After"), p.MaxRetryAfter), err
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return 0, fmt.Errorf("external alert service returned %s", resp.Status)
+	}
+
+	// the response body is optional
+	var result externalResponse
+	_ = json.NewDecoder(io.LimitReader(resp.Body, externalMaxResponseSize)).Decode(&result)
+	if result.Delivered != nil && !*result.Delivered {
+		if result.Error == "" {
+			result.Error = "not delivered"
+		}
+		return 0, errors.New("external alert service: " + result.Error)
+	}
+	return 0, nil
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or as an HTTP date,
+// limited to between one second and maxDelay.
+func parseRetryAfter(value string, maxDelay time.Duration) time.Duration {
+	delay := externalRetryDelay
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		delay = time.Until(at)
+	}
+	return min(max(delay, time.Second), maxDelay)
+}
@@ -0,0 +1,193 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"beszel/internal/alerts"
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// externalURL returns the external+http URL of a test server
+func externalURL(t *testing.T, server *httptest.Server, path string) *url.URL {
+	u, err := url.Parse(strings.Replace(server.URL, "http://", "external+http://user:pass@", 1) + path)
+	require.NoError(t, err)
+	return u
+}
+
+func TestExternalProvider(t *testing.T) {
+	var mu sync.Mutex
+	var received []alerts.ExternalAlert
+	attempts := make(chan int, 4)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert alerts.ExternalAlert
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		mu.Lock()
+		received = append(received, alert)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/ok":
+			user, pass, _ := r.BasicAuth()
+			assert.Equal(t, "user:pass", user+":"+pass)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			w.Write([]byte(`{"delivered": true}`))
+		case "/undelivered":
+			w.Write([]byte(`{"delivered": false, "error": "no one on call"}`))
+		case "/busy":
+			attempts <- alert.Attempt
+			if alert.Attempt == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	provider := alerts.NewExternalProvider()
+	data := alerts.AlertMessageData{
+		Title:     "nas CPU above threshold",
+		Message:   "CPU averaged 91.00% for the previous 5 minutes.",
+		System:    "nas",
+		Alert:     "CPU",
+		Value:     91,
+		Threshold: 80,
+		Unit:      "%",
+	}
+
+	require.NoError(t, provider.Send(externalURL(t, server, "/ok"), data))
+	mu.Lock()
+	require.Len(t, received, 1)
+	assert.Equal(t, "nas", received[0].System)
+	assert.Equal(t, "CPU", received[0].Alert)
+	assert.Equal(t, "triggered", received[0].Status)
+	assert.Equal(t, 80.0, received[0].Threshold)
+	assert.Equal(t, 1, received[0].Attempt)
+	mu.Unlock()
+
+	err := provider.Send(externalURL(t, server, "/undelivered"), data)
+	assert.ErrorContains(t, err, "no one on call")
+
+	err = provider.Send(externalURL(t, server, "/error"), data)
+	assert.ErrorContains(t, err, "500")
+
+	// the alert is sent again after the Retry-After delay
+	require.NoError(t, provider.Send(externalURL(t, server, "/busy"), data))
+	assert.Equal(t, 1, <-attempts)
+	select {
+	case attempt := <-attempts:
+		assert.Equal(t, 2, attempt)
+	case <-time.After(3 * time.Second):
+		t.Fatal("alert was not retried")
+	}
+}
+
+// recordingProvider records the notifications it sends
+type recordingProvider struct {
+	sent []string
+}
+
+func (p *recordingProvider) Schemes() []string {
+	return []string{"pager"}
+}
+
+func (p *recordingProvider) Send(notificationUrl *url.URL, data alerts.AlertMessageData) error {
+	p.sent = append(p.sent, notificationUrl.Host+": "+data.Title)
+	return nil
+}
+
+func TestRegisterProvider(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "provider@example.com", "password123")
+	require.NoError(t, err)
+	settings, err := beszelTests.CreateRecord(hub, "user_settings", map[string]any{"user": user.Id})
+	require.NoError(t, err)
+	settings.Set("settings", map[string]any{"webhooks": []string{"pager://oncall"}})
+	require.NoError(t, hub.Save(settings))
+
+	provider := &recordingProvider{}
+	hub.RegisterProvider(provider)
+	require.NoError(t, hub.SendAlert(alerts.AlertMessageData{UserID: user.Id, Title: "nas is down"}))
+	assert.Equal(t, []string{"oncall: nas is down"}, provider.sent)
+}
+
+func TestNotificationRoutes(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "routes@example.com", "password123")
+	require.NoError(t, err)
+	settings, err := beszelTests.CreateRecord(hub, "user_settings", map[string]any{"user": user.Id})
+	require.NoError(t, err)
+	settings.Set("settings", map[string]any{
+		"webhooks": []string{"pager://default"},
+		"routes": []alerts.NotificationRoute{
+			{Labels: map[string]string{"env": "prod"}, Webhooks: []string{"pager://oncall"}},
+			{Labels: map[string]string{"env": "prod", "site": "ber*"}, Webhooks: []string{"pager://berlin", "pager://oncall"}},
+			{Webhooks: []string{"pager://never"}},
+		},
+	})
+	require.NoError(t, hub.Save(settings))
+
+	provider := &recordingProvider{}
+	hub.RegisterProvider(provider)
+	send := func(labels map[string]string) []string {
+		provider.sent = nil
+		require.NoError(t, hub.SendAlert(alerts.AlertMessageData{UserID: user.Id, Title: "down", Labels: labels}))
+		return provider.sent
+	}
+
+	assert.Equal(t, []string{"default: down"}, send(nil))
+	assert.Equal(t, []string{"default: down"}, send(map[string]string{"env": "dev"}))
+	assert.Equal(t, []string{"oncall: down"}, send(map[string]string{"env": "prod", "site": "paris"}))
+	assert.Equal(t, []string{"oncall: down", "berlin: down"}, send(map[string]string{"env": "prod", "site": "berlin"}))
+
+	// routes by alert type and severity
+	settings.Set("settings", map[string]any{
+		"webhooks": []string{"pager://default"},
+		"routes": []alerts.NotificationRoute{
+			{Labels: map[string]string{"env": "prod"}, Severities: []string{"critical", "error"}, Webhooks: []string{"pager://oncall"}},
+			{Labels: map[string]string{"env": "lab"}, Emails: []string{}},
+			{Alerts: []string{"Disk", "DiskFull"}, Webhooks: []string{"pager://storage"}},
+		},
+	})
+	require.NoError(t, hub.Save(settings))
+	sendAlert := func(alert string, labels map[string]string) []string {
+		provider.sent = nil
+		require.NoError(t, hub.SendAlert(alerts.AlertMessageData{UserID: user.Id, Title: alert, Alert: alert, Labels: labels}))
+		return provider.sent
+	}
+	prod := map[string]string{"env": "prod"}
+	assert.Equal(t, []string{"oncall: Status"}, sendAlert("Status", prod))
+	assert.Equal(t, []string{"oncall: Smart"}, sendAlert("Smart", prod))
+	assert.Equal(t, []string{"default: CPU"}, sendAlert("CPU", prod), "warnings of prod systems use the default destinations")
+	assert.Equal(t, []string{"oncall: DiskFull", "storage: DiskFull"}, sendAlert("DiskFull", prod))
+	assert.Equal(t, []string{"storage: Disk"}, sendAlert("Disk", nil))
+	assert.Empty(t, sendAlert("Status", map[string]string{"env": "lab"}), "a route without destinations silences alerts")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 10*time.Second, alerts.ParseRetryAfter("10", time.Minute))
+	assert.Equal(t, time.Minute, alerts.ParseRetryAfter("3600", time.Minute))
+	assert.Equal(t, time.Second, alerts.ParseRetryAfter("0", time.Minute))
+	assert.Equal(t, 30*time.Second, alerts.ParseRetryAfter("", time.Minute))
+	date := time.Now().Add(20 * time.Second).UTC().Format(http.TimeFormat)
+	assert.InDelta(t, 20, alerts.ParseRetryAfter(date, time.Minute).Seconds(), 1.5)
+}
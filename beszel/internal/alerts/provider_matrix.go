@@ -0,0 +1,194 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// matrixTxn makes the transaction ids of sent events unique
+var matrixTxn atomic.Int64
+
+// MatrixProvider sends alerts to Matrix rooms for URLs like
+// matrix://:<access token>@<homeserver>/?rooms=!<room id>,#<alias>, or
+// matrix://<user>:<password>@<homeserver>/?rooms=... to log in with a
+// password, the URL format of Shoutrrr's Matrix service. Alerts are sent as
+// HTML with their system, value and threshold, followed by a sparkline of the
+// metric if there is one. The disableTLS=yes query param connects over HTTP.
+type MatrixProvider struct {
+	Client *http.Client
+	// homeserver base URL replacing https://<host> of notification URLs, if set
+	APIURL string
+}
+
+// NewMatrixProvider returns a MatrixProvider with default settings
+func NewMatrixProvider() *MatrixProvider {
+	return &MatrixProvider{
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Schemes returns the URL scheme of Matrix
+func (p *MatrixProvider) Schemes() []string {
+	return []string{"matrix"}
+}
+
+// matrixClient makes requests to a homeserver as a user
+type matrixClient struct {
+	provider *MatrixProvider
+	baseURL  string
+	token    string
+}
+
+// Send sends an alert to each room of the URL
+func (p *MatrixProvider) Send(notificationUrl *url.URL, data AlertMessageData) error {
+	query := notificationUrl.Query()
+	rooms := query.Get("rooms")
+	if rooms == "" {
+		return errors.New("matrix: URL requires rooms")
+	}
+	client := &matrixClient{provider: p, baseURL: p.APIURL}
+	if client.baseURL == "" {
+		client.baseURL = "https://" + notificationUrl.Host
+		if query.Get("disableTLS") == "yes" {
+			client.baseURL = "http://" + notificationUrl.Host
+		}
+	}
+	user := notificationUrl.User.Username()
+	password, _ := notificationUrl.User.Password()
+	if user == "" {
+		client.token = password
+	} else if err := client.login(user, password); err != nil {
+		return fmt.Errorf("matrix login: %w", err)
+	}
+
+	message := map[string]any{
+		"msgtype":        "m.text",
+		"body":           data.Title + "\n" + data.Message,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": strings.ReplaceAll(alertHTML(data, 4000), "\n", "<br>"),
+	}
+	var image map[string]any
+	if sparkline := alertSparkline(data); sparkline != nil {
+		contentURI, err := client.upload(sparkline)
+		if err != nil {
+			return fmt.Errorf("matrix upload: %w", err)
+		}
+		image = map[string]any{
+			"msgtype": "m.image",
+			"body":    "sparkline.png",
+			"url":     contentURI,
+			"info":    map[string]any{"mimetype": "image/png", "w": sparklineWidth, "h": sparklineHeight},
+		}
+	}
+
+	var errs []error
+	for room := range strings.SplitSeq(rooms, ",") {
+		if room = strings.TrimSpace(room); room == "" {
+			continue
+		}
+		err := client.sendRoom(room, message, image)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("matrix room %s: %w", room, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendRoom sends the message and image to a room id or alias
+func (c *matrixClient) sendRoom(room string, message, image map[string]any) error {
+	roomId := room
+	if strings.HasPrefix(room, "#") {
+		var result struct {
+			RoomId string `json:"room_id"`
+		}
+		if err := c.do(http.MethodGet, "/_matrix/client/v3/directory/room/"+url.PathEscape(room), nil, "", &result); err != nil {
+			return err
+		}
+		roomId = result.RoomId
+	}
+	for _, content := range []map[string]any{message, image} {
+		if content == nil {
+			continue
+		}
+		path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/beszel-%d-%d",
+			url.PathEscape(roomId), time.Now().UnixMilli(), matrixTxn.Add(1))
+		if err := c.doJSON(http.MethodPut, path, content, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// login logs in with a password and keeps the access token
+func (c *matrixClient) login(user, password string) error {
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	err := c.doJSON(http.MethodPost, "/_matrix/client/v3/login", map[string]any{
+		"type":       "m.login.password",
+		"identifier": map[string]string{"type": "m.id.user", "user": user},
+		"password":   password,
+	}, &result)
+	c.token = result.AccessToken
+	return err
+}
+
+// upload uploads a PNG image to the media repository and returns its URI
+func (c *matrixClient) upload(image []byte) (string, error) {
+	var result struct {
+		ContentURI string `json:"content_uri"`
+	}
+	err := c.do(http.MethodPost, "/_matrix/media/v3/upload?filename=sparkline.png", bytes.NewReader(image), "image/png", &result)
+	return result.ContentURI, err
+}
+
+// doJSON makes a request with a JSON body
+func (c *matrixClient) doJSON(method, path string, payload, result any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return c.do(method, path, bytes.NewReader(body), "application/json", result)
+}
+
+// do makes a request to the homeserver and decodes the JSON response
+func (c *matrixClient) do(method, path string, body io.Reader, contentType string, result any) error {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("User-Agent", "Beszel")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.provider.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var matrixErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, externalMaxResponseSize)).Decode(&matrixErr)
+		if matrixErr.Error == "" {
+			return errors.New(resp.Status)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, matrixErr.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, externalMaxResponseSize)).Decode(result)
+}
@@ -0,0 +1,130 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Opsgenie priorities of alert severities
+var opsgeniePriorities = map[string]string{
+	SeverityCritical: "P1",
+	SeverityError:    "P2",
+	SeverityWarning:  "P3",
+	SeverityInfo:     "P5",
+}
+
+// opsgenieAlert is the body of an Opsgenie create alert request
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// OpsgenieProvider sends alerts to the Opsgenie Alert API for URLs like
+// opsgenie://api.opsgenie.com/<api key>, or api.eu.opsgenie.com for the EU
+// instance, the URL format of Shoutrrr's Opsgenie service. Triggered alerts
+// create an Opsgenie alert with the priority of the alert's severity, and
+// resolved alerts close it. Query params: priority (P1 to P5) sets the
+// priority of all alerts, and tags is a comma-separated list of tags.
+type OpsgenieProvider struct {
+	Client *http.Client
+	// API base URL replacing https://<host> of notification URLs, if set
+	APIURL string
+}
+
+// NewOpsgenieProvider returns an OpsgenieProvider with default settings
+func NewOpsgenieProvider() *OpsgenieProvider {
+	return &OpsgenieProvider{
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Schemes returns the URL scheme of Opsgenie
+func (p *OpsgenieProvider) Schemes() []string {
+	return []string{"opsgenie"}
+}
+
+// Send creates or closes the Opsgenie alert of an alert
+func (p *OpsgenieProvider) Send(notificationUrl *url.URL, data AlertMessageData) error {
+	apiKey := strings.Trim(notificationUrl.Path, "/")
+	if apiKey == "" {
+		return errors.New("opsgenie: missing API key")
+	}
+	apiURL := p.APIURL
+	if apiURL == "" {
+		apiURL = "https://" + notificationUrl.Host
+	}
+	alias := incidentKey(data)
+	if data.Resolved && alias != "" {
+		body := map[string]string{"source": "Beszel", "note": data.Title}
+		return p.post(apiURL+"/v2/alerts/"+url.PathEscape(alias)+"/close?identifierType=alias", apiKey, body)
+	}
+
+	query := notificationUrl.Query()
+	priority := opsgeniePriorities[alertSeverity(data)]
+	if override := query.Get("priority"); override != "" {
+		if !slices.Contains([]string{"P1", "P2", "P3", "P4", "P5"}, override) {
+			return fmt.Errorf("opsgenie: invalid priority %q", override)
+		}
+		priority = override
+	}
+	alert := opsgenieAlert{
+		Message:     truncate(data.Title, 130),
+		Alias:       alias,
+		Description: truncate(strings.TrimSpace(data.Message+"\n\n"+data.Link), 15000),
+		Entity:      data.System,
+		Source:      "Beszel",
+		Priority:    priority,
+		Details:     map[string]string{},
+	}
+	for tag := range strings.SplitSeq(query.Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			alert.Tags = append(alert.Tags, tag)
+		}
+	}
+	if data.Alert != "" {
+		alert.Details["alert"] = data.Alert
+		alert.Details["value"] = fmt.Sprintf("%g%s", data.Value, data.Unit)
+		alert.Details["threshold"] = fmt.Sprintf("%g%s", data.Threshold, data.Unit)
+	}
+	if data.Occurrences > 0 {
+		alert.Details["occurrences"] = fmt.Sprint(data.Occurrences)
+	}
+	return p.post(apiURL+"/v2/alerts", apiKey, alert)
+}
+
+// post sends a request to the Alert API
+func (p *OpsgenieProvider) post(endpoint, apiKey string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Beszel")
+	req.Header.Set("Authorization", "GenieKey "+apiKey)
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("opsgenie returned %s: %s", resp.Status, readErrorBody(resp.Body))
+	}
+	return nil
+}
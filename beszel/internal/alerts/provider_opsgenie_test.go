@@ -0,0 +1,69 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"beszel/internal/alerts"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpsgenieProvider(t *testing.T) {
+	type request struct {
+		path string
+		body map[string]any
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GenieKey apikey", r.Header.Get("Authorization"))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, request{r.URL.RequestURI(), body})
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	provider := alerts.NewOpsgenieProvider()
+	provider.APIURL = server.URL
+	send := func(rawUrl string, data alerts.AlertMessageData) error {
+		u, err := url.Parse(rawUrl)
+		require.NoError(t, err)
+		return provider.Send(u, data)
+	}
+	smart := alerts.AlertMessageData{
+		Title:    "nas SMART failure",
+		Message:  "Disk sda failed its SMART self-test.",
+		Link:     "http://localhost:8090/system/nas",
+		SystemID: "abc123",
+		System:   "nas",
+		Alert:    "Smart",
+	}
+
+	require.NoError(t, send("opsgenie://api.opsgenie.com/apikey?tags=storage,%20home", smart))
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/v2/alerts", requests[0].path)
+	body := requests[0].body
+	assert.Equal(t, "nas SMART failure", body["message"])
+	assert.Equal(t, "beszel/abc123/Smart", body["alias"])
+	assert.Equal(t, "P2", body["priority"])
+	assert.Equal(t, "nas", body["entity"])
+	assert.Equal(t, []any{"storage", "home"}, body["tags"])
+	assert.Equal(t, "Disk sda failed its SMART self-test.\n\nhttp://localhost:8090/system/nas", body["description"])
+
+	resolved := smart
+	resolved.Resolved = true
+	require.NoError(t, send("opsgenie://api.opsgenie.com/apikey", resolved))
+	assert.Equal(t, "/v2/alerts/beszel%2Fabc123%2FSmart/close?identifierType=alias", requests[1].path)
+
+	require.NoError(t, send("opsgenie://api.opsgenie.com/apikey?priority=P4", smart))
+	assert.Equal(t, "P4", requests[2].body["priority"])
+	assert.ErrorContains(t, send("opsgenie://api.opsgenie.com/apikey?priority=P9", smart), "invalid priority")
+	assert.ErrorContains(t, send("opsgenie://api.opsgenie.com/", smart), "missing API key")
+}
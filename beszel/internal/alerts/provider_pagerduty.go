@@ -0,0 +1,140 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Events API v2 endpoint of PagerDuty
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyEvent is an event of the PagerDuty Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"` // "trigger" or "resolve"
+	DedupKey    string            `json:"dedup_key,omitempty"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+	Client      string            `json:"client,omitempty"`
+	ClientURL   string            `json:"client_url,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Timestamp     time.Time      `json:"timestamp"`
+	Component     string         `json:"component,omitempty"`
+	Class         string         `json:"class,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text,omitempty"`
+}
+
+// PagerDutyProvider sends alerts to the PagerDuty Events API v2 for URLs like
+// pagerduty://<routing key>. Triggered alerts open an incident with the
+// severity of the alert, and resolved alerts resolve it. The severity query
+// param, e.g. ?severity=critical, sets the severity of all alerts.
+type PagerDutyProvider struct {
+	Client    *http.Client
+	EventsURL string // Events API endpoint
+}
+
+// NewPagerDutyProvider returns a PagerDutyProvider with default settings
+func NewPagerDutyProvider() *PagerDutyProvider {
+	return &PagerDutyProvider{
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		EventsURL: pagerDutyEventsURL,
+	}
+}
+
+// Schemes returns the URL scheme of PagerDuty
+func (p *PagerDutyProvider) Schemes() []string {
+	return []string{"pagerduty"}
+}
+
+// Send triggers or resolves the PagerDuty incident of an alert
+func (p *PagerDutyProvider) Send(notificationUrl *url.URL, data AlertMessageData) error {
+	routingKey := notificationUrl.Host
+	if routingKey == "" {
+		return errors.New("pagerduty: missing routing key")
+	}
+	event := pagerDutyEvent{
+		RoutingKey:  routingKey,
+		EventAction: "trigger",
+		DedupKey:    incidentKey(data),
+		Client:      "Beszel",
+		ClientURL:   data.Link,
+	}
+	if data.Resolved && event.DedupKey != "" {
+		event.EventAction = "resolve"
+		return p.post(event)
+	}
+
+	severity := alertSeverity(data)
+	if override := notificationUrl.Query().Get("severity"); override != "" {
+		if !slices.Contains([]string{SeverityCritical, SeverityError, SeverityWarning, SeverityInfo}, override) {
+			return fmt.Errorf("pagerduty: invalid severity %q", override)
+		}
+		severity = override
+	}
+	source := data.System
+	if source == "" {
+		source = "Beszel"
+	}
+	event.Payload = &pagerDutyPayload{
+		Summary:   truncate(data.Title, 1024),
+		Source:    source,
+		Severity:  severity,
+		Timestamp: time.Now().UTC(),
+		Component: data.System,
+		Class:     data.Alert,
+		CustomDetails: map[string]any{
+			"message": data.Message,
+		},
+	}
+	if data.Alert != "" {
+		event.Payload.CustomDetails["value"] = data.Value
+		event.Payload.CustomDetails["threshold"] = data.Threshold
+		event.Payload.CustomDetails["unit"] = data.Unit
+	}
+	if data.Occurrences > 0 {
+		event.Payload.CustomDetails["occurrences"] = data.Occurrences
+	}
+	if data.Link != "" {
+		event.Links = []pagerDutyLink{{Href: data.Link, Text: data.LinkText}}
+	}
+	return p.post(event)
+}
+
+// post sends an event to the Events API
+func (p *PagerDutyProvider) post(event pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.EventsURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Beszel")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("pagerduty returned %s: %s", resp.Status, readErrorBody(resp.Body))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"beszel/internal/alerts"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagerDutyProvider(t *testing.T) {
+	var events []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event)
+		if event["routing_key"] == "invalid" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"invalid event","message":"Event object is invalid"}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	provider := alerts.NewPagerDutyProvider()
+	provider.EventsURL = server.URL
+	send := func(rawUrl string, data alerts.AlertMessageData) error {
+		u, err := url.Parse(rawUrl)
+		require.NoError(t, err)
+		return provider.Send(u, data)
+	}
+	down := alerts.AlertMessageData{
+		Title:    "Connection to nas is down",
+		Message:  "Connection to nas is down",
+		Link:     "http://localhost:8090/system/nas",
+		LinkText: "View nas",
+		SystemID: "abc123",
+		System:   "nas",
+		Alert:    "Status",
+	}
+
+	require.NoError(t, send("pagerduty://routingkey", down))
+	require.Len(t, events, 1)
+	assert.Equal(t, "routingkey", events[0]["routing_key"])
+	assert.Equal(t, "trigger", events[0]["event_action"])
+	assert.Equal(t, "beszel/abc123/Status", events[0]["dedup_key"])
+	payload := events[0]["payload"].(map[string]any)
+	assert.Equal(t, "critical", payload["severity"])
+	assert.Equal(t, "nas", payload["source"])
+	assert.Equal(t, "Connection to nas is down", payload["summary"])
+
+	up := down
+	up.Title = "Connection to nas is up"
+	up.Resolved = true
+	require.NoError(t, send("pagerduty://routingkey", up))
+	assert.Equal(t, "resolve", events[1]["event_action"])
+	assert.Equal(t, "beszel/abc123/Status", events[1]["dedup_key"])
+	assert.Nil(t, events[1]["payload"])
+
+	// severity of threshold alerts, maintenance and the severity param
+	cpu := alerts.AlertMessageData{Title: "nas CPU above threshold", SystemID: "abc123", System: "nas", Alert: "CPU", Value: 91, Threshold: 80, Unit: "%"}
+	require.NoError(t, send("pagerduty://routingkey", cpu))
+	assert.Equal(t, "warning", events[2]["payload"].(map[string]any)["severity"])
+	require.NoError(t, send("pagerduty://routingkey?severity=error", cpu))
+	assert.Equal(t, "error", events[3]["payload"].(map[string]any)["severity"])
+	cpu.Maintenance = true
+	require.NoError(t, send("pagerduty://routingkey", cpu))
+	assert.Equal(t, "info", events[4]["payload"].(map[string]any)["severity"])
+
+	assert.ErrorContains(t, send("pagerduty://routingkey?severity=urgent", cpu), "invalid severity")
+	assert.ErrorContains(t, send("pagerduty://invalid", cpu), "Event object is invalid")
+	assert.ErrorContains(t, send("pagerduty:///", cpu), "missing routing key")
+}
@@ -0,0 +1,134 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Bot API base URL of Telegram
+const telegramAPIURL = "https://api.telegram.org"
+
+// telegramResponse is the response of the Telegram Bot API
+type telegramResponse struct {
+	Ok          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// TelegramProvider sends alerts with a Telegram bot for URLs like
+// telegram://<bot token>@telegram?chats=<chat id>,@<channel>, the URL format
+// of Shoutrrr's Telegram service. Alerts are formatted with their system,
+// value and threshold, with a sparkline of the metric attached if there is
+// one. The notification=no query param sends alerts silently.
+type TelegramProvider struct {
+	Client *http.Client
+	APIURL string // Bot API base URL
+}
+
+// NewTelegramProvider returns a TelegramProvider with default settings
+func NewTelegramProvider() *TelegramProvider {
+	return &TelegramProvider{
+		Client: &http.Client{Timeout: 10 * time.Second},
+		APIURL: telegramAPIURL,
+	}
+}
+
+// Schemes returns the URL scheme of Telegram
+func (p *TelegramProvider) Schemes() []string {
+	return []string{"telegram"}
+}
+
+// Send sends an alert to each chat of the URL
+func (p *TelegramProvider) Send(notificationUrl *url.URL, data AlertMessageData) error {
+	// the token is "<bot id>:<secret>", parsed as the URL's user and password
+	secret, _ := notificationUrl.User.Password()
+	token := notificationUrl.User.Username() + ":" + secret
+	query := notificationUrl.Query()
+	chats := query.Get("chats")
+	if chats == "" {
+		chats = query.Get("channels")
+	}
+	if secret == "" || chats == "" {
+		return errors.New("telegram: URL requires a bot token and chats")
+	}
+	silent := query.Get("notification") == "no"
+	sparkline := alertSparkline(data)
+
+	var errs []error
+	for chat := range strings.SplitSeq(chats, ",") {
+		if chat = strings.TrimSpace(chat); chat == "" {
+			continue
+		}
+		var err error
+		if sparkline != nil {
+			// captions are limited to 1024 characters
+			err = p.sendPhoto(token, chat, alertHTML(data, 512), sparkline, silent)
+		} else {
+			err = p.sendMessage(token, chat, alertHTML(data, 3000), silent)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("telegram chat %s: %w", chat, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendMessage sends an HTML message to a chat
+func (p *TelegramProvider) sendMessage(token, chat, text string, silent bool) error {
+	body, err := json.Marshal(map[string]any{
+		"chat_id":                  chat,
+		"text":                     text,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+		"disable_notification":     silent,
+	})
+	if err != nil {
+		return err
+	}
+	return p.post(token, "sendMessage", bytes.NewReader(body), "application/json")
+}
+
+// sendPhoto sends a PNG image with an HTML caption to a chat
+func (p *TelegramProvider) sendPhoto(token, chat, caption string, photo []byte, silent bool) error {
+	body, contentType, err := multipartForm([][2]string{
+		{"chat_id", chat},
+		{"caption", caption},
+		{"parse_mode", "HTML"},
+		{"disable_notification", fmt.Sprint(silent)},
+	}, "photo", "sparkline.png", photo)
+	if err != nil {
+		return err
+	}
+	return p.post(token, "sendPhoto", body, contentType)
+}
+
+// post calls a method of the Bot API
+func (p *TelegramProvider) post(token, method string, body io.Reader, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, p.APIURL+"/bot"+token+"/"+method, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "Beszel")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		// don't log the URL, which contains the token
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	var result telegramResponse
+	_ = json.NewDecoder(io.LimitReader(resp.Body, externalMaxResponseSize)).Decode(&result)
+	if !result.Ok {
+		if result.Description == "" {
+			result.Description = resp.Status
+		}
+		return errors.New(result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// WebhookTemplate is a webhook defined in the notification settings of a user,
+// which sends alerts to URLs like template://<name>. Its body is a Go template
+// executed with TemplateData, such as {"entity_id": {{json .System}}}.
+type WebhookTemplate struct {
+	Name        string            `json:"name"`
+	URL         string            `json:"url"`
+	Method      string            `json:"method,omitempty"` // POST if empty
+	Headers     map[string]string `json:"headers,omitempty"`
+	ContentType string            `json:"contentType,omitempty"` // application/json if empty
+	Body        string            `json:"body"`
+}
+
+// TemplateData is the data of webhook templates
+type TemplateData struct {
+	Title       string
+	Message     string
+	Link        string
+	LinkText    string
+	SystemID    string
+	System      string
+	Alert       string
+	Status      string // "triggered" or "resolved" for alerts on a system
+	Resolved    bool
+	Severity    string // "critical", "error", "warning" or "info"
+	Value       float64
+	Threshold   float64
+	Unit        string
+	Labels      map[string]string
+	Maintenance bool
+	Occurrences int
+	Time        time.Time
+}
+
+// names of webhook templates, which are the host of their URLs
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// functions of webhook templates
+var templateFuncs = template.FuncMap{
+	// json encodes a value, so strings are quoted and escaped in JSON bodies
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// TemplateProvider sends alerts to the webhook templates of users for URLs
+// like template://<name>. Bodies with a JSON content type must render to
+// valid JSON.
+type TemplateProvider struct {
+	Client *http.Client
+	// Templates returns the webhook template of a user by name
+	Templates func(userID, name string) (*WebhookTemplate, error)
+}
+
+// NewTemplateProvider returns a TemplateProvider that finds templates with a function
+func NewTemplateProvider(templates func(userID, name string) (*WebhookTemplate, error)) *TemplateProvider {
+	return &TemplateProvider{
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		Templates: templates,
+	}
+}
+
+// Schemes returns the URL scheme of webhook templates
+func (p *TemplateProvider) Schemes() []string {
+	return []string{"template"}
+}
+
+// Send renders the user's template and sends it to its URL
+func (p *TemplateProvider) Send(notificationUrl *url.URL, data AlertMessageData) error {
+	name := notificationUrl.Host
+	if name == "" {
+		name = notificationUrl.Opaque
+	}
+	webhook, err := p.Templates(data.UserID, name)
+	if err != nil {
+		return fmt.Errorf("webhook template %q: %w", name, err)
+	}
+	body, err := webhook.Render(data)
+	if err != nil {
+		return fmt.Errorf("webhook template %q: %w", name, err)
+	}
+	method := webhook.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", webhook.contentType())
+	req.Header.Set("User-Agent", "Beszel")
+	for key, value := range webhook.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		// don't log the URL, which can contain tokens
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook template %q returned %s: %s", name, resp.Status, readErrorBody(resp.Body))
+	}
+	return nil
+}
+
+// Validate checks the URL and body of a template
+func (w *WebhookTemplate) Validate() error {
+	if !templateNamePattern.MatchString(w.Name) {
+		return fmt.Errorf("name %q can only contain letters, digits and hyphens", w.Name)
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s: URL must be an http or https URL", w.Name)
+	}
+	// render example data to catch errors of field names and JSON syntax
+	_, err = w.Render(AlertMessageData{
+		Title:     "nas CPU above threshold",
+		Message:   "CPU averaged 91.00% for the previous 5 minutes.",
+		System:    "nas",
+		Alert:     "CPU",
+		Value:     91,
+		Threshold: 80,
+		Unit:      "%",
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", w.Name, err)
+	}
+	return nil
+}
+
+// Render executes the template's body with the data of an alert
+func (w *WebhookTemplate) Render(data AlertMessageData) ([]byte, error) {
+	tmpl, err := template.New(w.Name).Funcs(templateFuncs).Parse(w.Body)
+	if err != nil {
+		return nil, err
+	}
+	templateData := TemplateData{
+		Title:       data.Title,
+		Message:     data.Message,
+		Link:        data.Link,
+		LinkText:    data.LinkText,
+		SystemID:    data.SystemID,
+		System:      data.System,
+		Alert:       data.Alert,
+		Resolved:    data.Resolved,
+		Severity:    alertSeverity(data),
+		Value:       data.Value,
+		Threshold:   data.Threshold,
+		Unit:        data.Unit,
+		Labels:      data.Labels,
+		Maintenance: data.Maintenance,
+		Occurrences: data.Occurrences,
+		Time:        time.Now().UTC(),
+	}
+	if data.Alert != "" {
+		templateData.Status = "triggered"
+		if data.Resolved {
+			templateData.Status = "resolved"
+		}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, templateData); err != nil {
+		return nil, err
+	}
+	if strings.Contains(w.contentType(), "json") && !json.Valid(buf.Bytes()) {
+		return nil, errors.New("body is not valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// contentType returns the content type of the template's body
+func (w *WebhookTemplate) contentType() string {
+	if w.ContentType == "" {
+		return "application/json"
+	}
+	return w.ContentType
+}
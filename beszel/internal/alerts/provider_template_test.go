@@ -0,0 +1,175 @@
+//go:build testing
+// +build testing
+
+package alerts_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"beszel/internal/alerts"
+	beszelTests "beszel/internal/tests"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateProvider(t *testing.T) {
+	type request struct {
+		method      string
+		contentType string
+		token       string
+		body        string
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(body)})
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("unknown entity"))
+		}
+	}))
+	defer server.Close()
+
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "template@example.com", "password123")
+	require.NoError(t, err)
+	settings, err := beszelTests.CreateRecord(hub, "user_settings", map[string]any{"user": user.Id})
+	require.NoError(t, err)
+	templates := []alerts.WebhookTemplate{
+		{
+			Name:    "homeassistant",
+			URL:     server.URL + "/api/webhook/beszel",
+			Headers: map[string]string{"Authorization": "Bearer secret"},
+			Body:    `{"system": {{json .System}}, "alert": {{json .Alert}}, "state": "{{.Status}}", "severity": "{{.Severity}}", "value": {{.Value}}, "env": {{json (index .Labels "env")}}}`,
+		},
+		{
+			Name:        "n8n",
+			URL:         server.URL + "/webhook",
+			Method:      http.MethodPut,
+			ContentType: "text/plain",
+			Body:        `{{upper .System}} {{.Alert}}{{if .Resolved}} resolved{{end}}`,
+		},
+		{Name: "fail", URL: server.URL + "/fail", Body: `{}`},
+		{Name: "invalid", URL: server.URL, Body: `{"title": {{.Title}}}`},
+	}
+	settings.Set("settings", map[string]any{"webhooks": []string{"template://homeassistant"}, "webhookTemplates": templates})
+	require.NoError(t, hub.Save(settings))
+
+	data := alerts.AlertMessageData{
+		UserID: user.Id,
+		Title:  "nas is down",
+		System: `nas "1"`,
+		Alert:  "Status",
+		Value:  1,
+		Labels: map[string]string{"env": "prod"},
+	}
+	require.NoError(t, hub.SendAlert(data))
+	require.Len(t, requests, 1)
+	assert.Equal(t, request{
+		method:      http.MethodPost,
+		contentType: "application/json",
+		token:       "Bearer secret",
+		body:        `{"system": "nas \"1\"", "alert": "Status", "state": "triggered", "severity": "critical", "value": 1, "env": "prod"}`,
+	}, requests[0])
+
+	// plain text bodies and other methods
+	data.Resolved = true
+	settings.Set("settings", map[string]any{"webhooks": []string{"template://n8n"}, "webhookTemplates": templates})
+	require.NoError(t, hub.Save(settings))
+	require.NoError(t, hub.SendAlert(data))
+	require.Len(t, requests, 2)
+	assert.Equal(t, request{method: http.MethodPut, contentType: "text/plain", body: `NAS "1" Status resolved`}, requests[1])
+
+	// errors are returned by test notifications
+	send := func(name string) error {
+		return hub.SendNotification("template://"+name, alerts.AlertMessageData{UserID: user.Id, Title: "Test Alert"})
+	}
+	assert.ErrorContains(t, send("fail"), "400 Bad Request: unknown entity")
+	assert.ErrorContains(t, send("invalid"), "not valid JSON")
+	assert.ErrorContains(t, send("missing"), "template not found")
+}
+
+func TestWebhookTemplateValidate(t *testing.T) {
+	valid := alerts.WebhookTemplate{Name: "ha", URL: "https://ha.local/api/webhook/x", Body: `{"title": {{json .Title}}}`}
+	assert.NoError(t, valid.Validate())
+
+	tests := []struct {
+		template alerts.WebhookTemplate
+		err      string
+	}{
+		{alerts.WebhookTemplate{URL: valid.URL, Body: valid.Body}, "can only contain letters"},
+		{alerts.WebhookTemplate{Name: "home assistant", URL: valid.URL, Body: valid.Body}, "can only contain letters"},
+		{alerts.WebhookTemplate{Name: "ha", URL: "ftp://ha.local", Body: valid.Body}, "http or https URL"},
+		{alerts.WebhookTemplate{Name: "ha", URL: valid.URL, Body: `{{.Title`}, "unclosed action"},
+		{alerts.WebhookTemplate{Name: "ha", URL: valid.URL, Body: `{{.Host}}`}, "can't evaluate field Host"},
+		{alerts.WebhookTemplate{Name: "ha", URL: valid.URL, Body: `{"title": "{{.Title}}"`}, "not valid JSON"},
+	}
+	for _, test := range tests {
+		assert.ErrorContains(t, test.template.Validate(), test.err)
+	}
+}
+
+func TestWebhookTemplatesApi(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "templateapi@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	settings, err := beszelTests.CreateRecord(hub, "user_settings", map[string]any{"user": user.Id})
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+	webhookTemplates := func(templates ...alerts.WebhookTemplate) map[string]any {
+		return map[string]any{"settings": map[string]any{"webhookTemplates": templates}}
+	}
+	valid := alerts.WebhookTemplate{Name: "ha", URL: "https://ha.local/api/webhook/x", Body: `{"title": {{json .Title}}}`}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "save invalid template",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/user_settings/records/" + settings.Id,
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(webhookTemplates(alerts.WebhookTemplate{Name: "ha", URL: valid.URL, Body: `{{.Host}}`})),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid webhook template: ha"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "save duplicate templates",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/user_settings/records/" + settings.Id,
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(webhookTemplates(valid, valid)),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Duplicate webhook template ha"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "save valid template",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/user_settings/records/" + settings.Id,
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonReader(webhookTemplates(valid)),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"name":"ha"`},
+			TestAppFactory:  testAppFactory,
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,212 @@
+package alerts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// NotificationProvider delivers notifications to URLs with the provider's
+// schemes. Registered providers take precedence over Shoutrrr services, so a
+// hub can deliver alerts to services Shoutrrr doesn't support.
+type NotificationProvider interface {
+	// Schemes returns the URL schemes handled by the provider, e.g. "external"
+	Schemes() []string
+	// Send delivers a notification to a URL with one of the provider's schemes
+	Send(notificationUrl *url.URL, data AlertMessageData) error
+}
+
+// RegisterProvider adds a notification provider, replacing providers already
+// registered for its schemes.
+func (am *AlertManager) RegisterProvider(provider NotificationProvider) {
+	am.providersMu.Lock()
+	defer am.providersMu.Unlock()
+	for _, scheme := range provider.Schemes() {
+		am.providers[scheme] = provider
+	}
+}
+
+// getProvider returns the notification provider of a URL scheme, or nil
+func (am *AlertManager) getProvider(scheme string) NotificationProvider {
+	am.providersMu.RLock()
+	defer am.providersMu.RUnlock()
+	return am.providers[scheme]
+}
+
+// sendNotification sends a notification to a URL with the provider registered
+// for its scheme, or with Shoutrrr if there is none.
+func (am *AlertManager) sendNotification(notificationUrl string, data AlertMessageData) error {
+	parsedURL, err := url.Parse(notificationUrl)
+	if err != nil {
+		return fmt.Errorf("error parsing URL: %v", err)
+	}
+	provider := am.getProvider(parsedURL.Scheme)
+	if provider == nil {
+		return am.SendShoutrrrAlert(notificationUrl, data.Title, data.Message, data.Link, data.LinkText)
+	}
+	if err := provider.Send(parsedURL, data); err != nil {
+		am.hub.Logger().Error("Error sending alert", "scheme", parsedURL.Scheme, "err", err)
+		return err
+	}
+	am.hub.Logger().Info("Sent alert", "scheme", parsedURL.Scheme, "title", data.Title)
+	return nil
+}
+
+// Alert severities of incident management services
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// alertSeverity returns the severity of an alert for incident management
+// services and notification routes. Systems going down are critical, failures of disks, services and
+// checks are errors, and threshold alerts are warnings. Alerts sent during a
+// maintenance window and notifications without an alert are informational.
+func alertSeverity(data AlertMessageData) string {
+	if data.Alert == "" || data.Maintenance {
+		return SeverityInfo
+	}
+	switch data.Alert {
+	case "Status":
+		return SeverityCritical
+	case "Raid", "Smart", "DiskFull", "Services", "Checks", "SensorState", "Containers":
+		return SeverityError
+	}
+	return SeverityWarning
+}
+
+// incidentKey returns the key that identifies the incident of an alert of a
+// system, so the incident is resolved along with the alert. Notifications
+// without an alert have no key.
+func incidentKey(data AlertMessageData) string {
+	if data.Alert == "" {
+		return ""
+	}
+	return "beszel/" + data.SystemID + "/" + data.Alert
+}
+
+// readErrorBody returns the start of an error response body
+func readErrorBody(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, 512))
+	return string(bytes.TrimSpace(data))
+}
+
+// truncate shortens a string to a number of bytes, for services that limit
+// the length of fields
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// don't split a UTF-8 sequence
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// alertField is a labeled detail of an alert shown by rich notifications
+type alertField struct {
+	Name  string
+	Value string
+}
+
+// alertFields returns the details of an alert for rich notifications
+func alertFields(data AlertMessageData) []alertField {
+	var fields []alertField
+	if data.System != "" {
+		fields = append(fields, alertField{"System", data.System})
+	}
+	if data.Alert == "" {
+		return fields
+	}
+	fields = append(fields, alertField{"Alert", data.Alert})
+	if data.Value != 0 || data.Threshold != 0 {
+		fields = append(fields,
+			alertField{"Value", strconv.FormatFloat(data.Value, 'f', -1, 64) + data.Unit},
+			alertField{"Threshold", strconv.FormatFloat(data.Threshold, 'f', -1, 64) + data.Unit})
+	}
+	if data.Occurrences > 0 {
+		fields = append(fields, alertField{"Occurrences", strconv.Itoa(data.Occurrences)})
+	}
+	return fields
+}
+
+// alertHTML formats an alert as HTML for services that render a subset of it,
+// like Telegram and Matrix. The message is limited to maxMessage bytes.
+func alertHTML(data AlertMessageData, maxMessage int) string {
+	var sb strings.Builder
+	sb.WriteString("<b>" + html.EscapeString(data.Title) + "</b>")
+	if data.Message != "" && data.Message != data.Title {
+		sb.WriteString("\n" + html.EscapeString(truncate(data.Message, maxMessage)))
+	}
+	for _, field := range alertFields(data) {
+		sb.WriteString("\n<b>" + field.Name + ":</b> " + html.EscapeString(field.Value))
+	}
+	if data.Link != "" {
+		linkText := data.LinkText
+		if linkText == "" {
+			linkText = data.Link
+		}
+		sb.WriteString("\n<a href=\"" + html.EscapeString(data.Link) + "\">" + html.EscapeString(linkText) + "</a>")
+	}
+	return sb.String()
+}
+
+// alertSparkline renders the sparkline of an alert, or returns nil if it has
+// no history or the sparkline can't be rendered
+func alertSparkline(data AlertMessageData) []byte {
+	if len(data.History) < 2 {
+		return nil
+	}
+	image, err := renderSparkline(data.History, data.Threshold, data.Resolved)
+	if err != nil {
+		return nil
+	}
+	return image
+}
+
+// multipartForm encodes form fields and a PNG file as multipart/form-data and
+// returns the body and its content type
+func multipartForm(fields [][2]string, fileField, fileName string, file []byte) (*bytes.Buffer, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return nil, "", err
+		}
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, fileField, fileName))
+	header.Set("Content-Type", "image/png")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(file); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, writer.FormDataContentType(), nil
+}
+
+// withoutURL returns the error of a failed request without the request URL,
+// for services with secrets in their URLs
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
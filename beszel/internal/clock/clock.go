@@ -0,0 +1,48 @@
+// Package clock provides an injectable time source for tickers, timers and
+// elapsed time checks, so interval logic can be tested deterministically.
+package clock
+
+import "time"
+
+// Clock is the subset of the time package used by the agent and hub schedulers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	// Sleep pauses the current goroutine for at least the duration d.
+	Sleep(d time.Duration)
+	// NewTicker returns a new Ticker that sends the current time every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is an interface around time.Ticker.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+	// Reset stops the ticker and resets its period to d.
+	Reset(d time.Duration)
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// New returns a Clock backed by the time package.
+func New() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
@@ -0,0 +1,112 @@
+//go:build testing
+// +build testing
+
+package clock_test
+
+import (
+	"beszel/internal/clock"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestMockNowAndSince(t *testing.T) {
+	mock := clock.NewMock(start)
+	assert.Equal(t, start, mock.Now())
+
+	mock.Advance(90 * time.Second)
+	assert.Equal(t, start.Add(90*time.Second), mock.Now())
+	assert.Equal(t, 90*time.Second, mock.Since(start))
+}
+
+func TestMockAfter(t *testing.T) {
+	mock := clock.NewMock(start)
+	ch := mock.After(10 * time.Second)
+
+	mock.Advance(9 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	mock.Advance(time.Second)
+	select {
+	case fired := <-ch:
+		assert.Equal(t, start.Add(10*time.Second), fired)
+	default:
+		t.Fatal("timer did not fire")
+	}
+	assert.Equal(t, 0, mock.Waiters())
+}
+
+func TestMockTicker(t *testing.T) {
+	mock := clock.NewMock(start)
+	ticker := mock.NewTicker(time.Minute)
+
+	var ticks []time.Time
+	for range 3 {
+		mock.Advance(time.Minute)
+		ticks = append(ticks, <-ticker.C())
+	}
+	assert.Equal(t, []time.Time{start.Add(time.Minute), start.Add(2 * time.Minute), start.Add(3 * time.Minute)}, ticks)
+
+	// ticks are dropped if not received, like time.Ticker
+	mock.Advance(5 * time.Minute)
+	assert.Equal(t, start.Add(4*time.Minute), <-ticker.C())
+	select {
+	case <-ticker.C():
+		t.Fatal("expected dropped ticks")
+	default:
+	}
+
+	// reset changes the period from the current time
+	ticker.Reset(10 * time.Second)
+	mock.Advance(10 * time.Second)
+	assert.Equal(t, start.Add(8*time.Minute+10*time.Second), <-ticker.C())
+
+	ticker.Stop()
+	assert.Equal(t, 0, mock.Waiters())
+	mock.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+func TestMockSleep(t *testing.T) {
+	mock := clock.NewMock(start)
+	done := make(chan struct{})
+	go func() {
+		mock.Sleep(5 * time.Second)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return mock.Waiters() == 1 }, time.Second, time.Millisecond)
+	mock.Advance(5 * time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sleep did not return")
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	c := clock.New()
+	now := c.Now()
+	assert.WithinDuration(t, time.Now(), now, time.Second)
+	assert.GreaterOrEqual(t, c.Since(now), time.Duration(0))
+
+	ticker := c.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	select {
+	case <-ticker.C():
+	case <-time.After(time.Second):
+		t.Fatal("real ticker did not fire")
+	}
+}
@@ -0,0 +1,155 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Mock is a Clock whose time only moves when Advance or Set is called.
+// Tickers, timers and sleepers fire in order as the mock time passes their deadlines.
+type Mock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*mockWaiter
+}
+
+// mockWaiter is a pending timer, ticker or sleeper.
+type mockWaiter struct {
+	deadline time.Time
+	period   time.Duration // zero for one-shot timers
+	ch       chan time.Time
+}
+
+// NewMock returns a Mock clock set to the given time.
+func NewMock(now time.Time) *Mock {
+	return &Mock{now: now}
+}
+
+// Now returns the current mock time.
+func (m *Mock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// Since returns the mock time elapsed since t.
+func (m *Mock) Since(t time.Time) time.Duration {
+	return m.Now().Sub(t)
+}
+
+// After returns a channel that receives the mock time once d has elapsed.
+func (m *Mock) After(d time.Duration) <-chan time.Time {
+	return m.addWaiter(d, 0).ch
+}
+
+// Sleep blocks until the mock time has advanced by d.
+func (m *Mock) Sleep(d time.Duration) {
+	<-m.After(d)
+}
+
+// NewTicker returns a ticker that fires every d of mock time.
+func (m *Mock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return &mockTicker{mock: m, waiter: m.addWaiter(d, d)}
+}
+
+// Waiters returns the number of pending timers, tickers and sleepers.
+// Useful for waiting until a goroutine has registered before advancing.
+func (m *Mock) Waiters() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.waiters)
+}
+
+// Advance moves the mock time forward by d, firing everything due along the way.
+func (m *Mock) Advance(d time.Duration) {
+	m.Set(m.Now().Add(d))
+}
+
+// Set moves the mock time to t, firing everything due along the way.
+// Like time.Ticker, ticks are dropped if the receiver is not ready.
+func (m *Mock) Set(t time.Time) {
+	for {
+		m.mu.Lock()
+		sort.SliceStable(m.waiters, func(i, j int) bool {
+			return m.waiters[i].deadline.Before(m.waiters[j].deadline)
+		})
+		if len(m.waiters) == 0 || m.waiters[0].deadline.After(t) {
+			if t.After(m.now) {
+				m.now = t
+			}
+			m.mu.Unlock()
+			return
+		}
+		w := m.waiters[0]
+		if w.deadline.After(m.now) {
+			m.now = w.deadline
+		}
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+		} else {
+			m.waiters = m.waiters[1:]
+		}
+		now := m.now
+		m.mu.Unlock()
+
+		select {
+		case w.ch <- now:
+		default:
+		}
+	}
+}
+
+// addWaiter registers a waiter that fires after d.
+func (m *Mock) addWaiter(d, period time.Duration) *mockWaiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := &mockWaiter{
+		deadline: m.now.Add(d),
+		period:   period,
+		ch:       make(chan time.Time, 1),
+	}
+	m.waiters = append(m.waiters, w)
+	return w
+}
+
+// removeWaiter unregisters a waiter.
+func (m *Mock) removeWaiter(w *mockWaiter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.waiters {
+		if m.waiters[i] == w {
+			m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// mockTicker is a Ticker driven by a Mock clock.
+type mockTicker struct {
+	mock   *Mock
+	waiter *mockWaiter
+}
+
+func (t *mockTicker) C() <-chan time.Time {
+	return t.waiter.ch
+}
+
+func (t *mockTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.mock.removeWaiter(t.waiter)
+	t.mock.mu.Lock()
+	t.waiter.deadline = t.mock.now.Add(d)
+	t.waiter.period = d
+	t.mock.waiters = append(t.mock.waiters, t.waiter)
+	t.mock.mu.Unlock()
+}
+
+func (t *mockTicker) Stop() {
+	t.mock.removeWaiter(t.waiter)
+}
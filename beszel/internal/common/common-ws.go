@@ -10,6 +10,17 @@ const (
 	GetData WebSocketAction = iota
 	// Check the fingerprint of the agent
 	CheckFingerprint
+	// Request the remaining chunks of a payload sent in chunks
+	GetChunks
+)
+
+const (
+	// CBOR tag of chunk messages, to tell them apart from whole payloads
+	ChunkTag uint64 = 48201
+	// CBOR tag of system data pushed by the agent without a request
+	PushTag uint64 = 48202
+	// Smallest chunk size the agent will use
+	MinChunkSize = 16 * 1024
 )
 
 // HubRequest defines the structure for requests sent from hub to agent.
@@ -29,4 +40,34 @@ type FingerprintResponse struct {
 	// Optional system info for universal token system creation
 	Hostname string `cbor:"1,keyasint,omitempty,omitzero"`
 	Port     string `cbor:"2,keyasint,omitempty,omitzero"`
+	// Name and labels of the system, set by the agent's SYSTEM_NAME and LABELS
+	Name   string            `cbor:"3,keyasint,omitempty,omitzero"`
+	Labels map[string]string `cbor:"4,keyasint,omitempty,omitzero"`
+}
+
+// DataRequest is the optional data of a GetData request. Older hubs don't send it.
+type DataRequest struct {
+	// Max size of a message the hub accepts. Larger payloads are sent in chunks.
+	ChunkSize int `cbor:"0,keyasint,omitempty"`
+	// Whether the hub accepts system data pushed by the agent (PUSH_INTERVAL).
+	Push bool `cbor:"1,keyasint,omitempty"`
+}
+
+// ChunkRequest asks the agent to resend a payload from a chunk, to resume an
+// interrupted transfer.
+type ChunkRequest struct {
+	Id    uint32 `cbor:"0,keyasint"`
+	Index int    `cbor:"1,keyasint"`
+}
+
+// Chunk is a part of a payload too large for a single message. A chunk with
+// Total 0 means the agent no longer has the requested payload.
+type Chunk struct {
+	Id    uint32 `cbor:"0,keyasint"`
+	Index int    `cbor:"1,keyasint"`
+	Total int    `cbor:"2,keyasint"`
+	// size and CRC-32 checksum of the whole payload
+	Size int    `cbor:"3,keyasint"`
+	Sum  uint32 `cbor:"4,keyasint"`
+	Data []byte `cbor:"5,keyasint"`
 }
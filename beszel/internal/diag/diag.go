@@ -0,0 +1,243 @@
+// Package diag builds diagnostic bundles to attach to bug reports: a zip archive
+// with the config, recent logs, collector status and a sample payload.
+//
+// Secrets in the config are redacted, and hostnames, IP addresses and container
+// names are replaced with pseudonyms. Pseudonyms use a random key for each
+// bundle, so they are consistent within a bundle but can't be linked across bundles.
+package diag
+
+import (
+	"archive/zip"
+	"beszel/internal/hub/masking"
+	"bufio"
+	"cmp"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Redacted replaces secrets and text redacted during review
+const Redacted = "[redacted]"
+
+// secretKeyParts mark config keys whose values are redacted
+var secretKeyParts = []string{"KEY", "TOKEN", "SECRET", "PASS", "CREDENTIALS"}
+
+// Bundle is a diagnostic bundle being built. Files are stored as added and
+// sanitized when viewed or written.
+type Bundle struct {
+	files      []file
+	mask       *masking.Policy
+	hostnames  []string
+	containers []string
+	redact     []string
+}
+
+type file struct {
+	name string
+	data []byte
+}
+
+// New creates an empty bundle.
+func New() *Bundle {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	mask, _ := masking.Parse("all", key)
+	return &Bundle{mask: mask}
+}
+
+// Add adds a file to the bundle.
+func (b *Bundle) Add(name string, data []byte) {
+	b.files = append(b.files, file{name: name, data: data})
+}
+
+// AddJSON adds a file with the indented JSON encoding of v.
+func (b *Bundle) AddJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	b.Add(name, data)
+	return nil
+}
+
+// MaskHostnames replaces the names wherever they appear in the bundle.
+func (b *Bundle) MaskHostnames(names ...string) {
+	b.hostnames = appendNames(b.hostnames, names)
+}
+
+// MaskContainers replaces the container names wherever they appear in the bundle.
+func (b *Bundle) MaskContainers(names ...string) {
+	b.containers = appendNames(b.containers, names)
+}
+
+// Redact replaces the text wherever it appears in the bundle.
+func (b *Bundle) Redact(text ...string) {
+	b.redact = appendNames(b.redact, text)
+}
+
+// appendNames appends the non-empty names, longest first so that names
+// containing other names are replaced first.
+func appendNames(names []string, add []string) []string {
+	for _, name := range add {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.SortStableFunc(names, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	return names
+}
+
+// sanitize returns the data with redacted text and masked names replaced.
+func (b *Bundle) sanitize(data []byte) []byte {
+	text := string(data)
+	for _, term := range b.redact {
+		text = strings.ReplaceAll(text, term, Redacted)
+	}
+	for _, name := range b.hostnames {
+		text = wordRegexp(name).ReplaceAllLiteralString(text, b.mask.Hostname(name))
+	}
+	for _, name := range b.containers {
+		text = wordRegexp(name).ReplaceAllLiteralString(text, b.mask.Container(name))
+	}
+	return []byte(b.mask.Text(text))
+}
+
+// wordRegexp matches the name where it isn't part of a longer word, so short
+// names like "db" don't mask part of "mongodb".
+func wordRegexp(name string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(name)
+	if isWordChar(name[0]) {
+		pattern = `\b` + pattern
+	}
+	if isWordChar(name[len(name)-1]) {
+		pattern += `\b`
+	}
+	return regexp.MustCompile(pattern)
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// Write writes the sanitized files to a zip archive.
+func (b *Bundle) Write(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	for _, f := range b.files {
+		fw, err := archive.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(b.sanitize(f.data)); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Review lets the user view the sanitized files and redact more text before
+// the bundle is written. Returns false if the user cancels.
+func (b *Bundle) Review(in io.Reader, out io.Writer) (bool, error) {
+	scanner := bufio.NewScanner(in)
+	prompt := func(message string) (string, bool) {
+		fmt.Fprint(out, message)
+		if !scanner.Scan() {
+			return "", false
+		}
+		return strings.TrimSpace(scanner.Text()), true
+	}
+	fmt.Fprintln(out, "Secrets are redacted, and hostnames, IP addresses and container names are masked.")
+	for {
+		fmt.Fprintln(out, "\nFiles:")
+		for i, f := range b.files {
+			fmt.Fprintf(out, "  %d. %s (%d bytes)\n", i+1, f.name, len(b.sanitize(f.data)))
+		}
+		choice, ok := prompt("\n[number] view file, [r]edact text, [w]rite bundle, [q]uit: ")
+		if !ok {
+			return false, scanner.Err()
+		}
+		switch strings.ToLower(choice) {
+		case "w", "write":
+			return true, nil
+		case "q", "quit":
+			return false, nil
+		case "r", "redact":
+			text, ok := prompt("Text to redact: ")
+			if !ok {
+				return false, scanner.Err()
+			}
+			b.Redact(text)
+		default:
+			var i int
+			if _, err := fmt.Sscan(choice, &i); err != nil || i < 1 || i > len(b.files) {
+				fmt.Fprintln(out, "Invalid choice")
+				continue
+			}
+			fmt.Fprintf(out, "\n--- %s ---\n%s\n", b.files[i-1].name, b.sanitize(b.files[i-1].data))
+		}
+	}
+}
+
+// Save writes the bundle to path, after an interactive review if review is true.
+func (b *Bundle) Save(path string, review bool, in io.Reader, out io.Writer) error {
+	if review {
+		ok, err := b.Review(in, out)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(out, "Cancelled")
+			return nil
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Wrote", path)
+	return nil
+}
+
+// FileName returns the default bundle file name for the app, e.g. beszel-agent-diag-20250102-150405.zip
+func FileName(app string) string {
+	return fmt.Sprintf("%s-diag-%s.zip", app, time.Now().Format("20060102-150405"))
+}
+
+// Env returns the environment variables starting with one of the prefixes,
+// with secret values redacted.
+func Env(prefixes ...string) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				env[key] = RedactSecret(key, value)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// RedactSecret returns the value, or Redacted if the key looks like it holds a secret.
+func RedactSecret(key, value string) string {
+	upper := strings.ToUpper(key)
+	for _, part := range secretKeyParts {
+		if strings.Contains(upper, part) && value != "" {
+			return Redacted
+		}
+	}
+	return value
+}
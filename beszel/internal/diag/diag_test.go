@@ -0,0 +1,100 @@
+//go:build testing
+// +build testing
+
+package diag
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readZip returns the files of a zip archive by name
+func readZip(t *testing.T, data []byte) map[string]string {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestBundleSanitize(t *testing.T) {
+	b := New()
+	b.Add("logs.txt", []byte("db connected to 10.0.0.5 from web-1, mongodb on web-10 with token abc123"))
+	b.MaskHostnames("web-1", "web-10")
+	b.MaskContainers("db")
+	b.Redact("abc123")
+
+	var buf bytes.Buffer
+	require.NoError(t, b.Write(&buf))
+	logs := readZip(t, buf.Bytes())["logs.txt"]
+
+	assert.NotContains(t, logs, "10.0.0.5")
+	assert.NotContains(t, logs, "web-1")
+	assert.NotContains(t, logs, "abc123")
+	assert.Contains(t, logs, "ip-")
+	assert.Contains(t, logs, "host-")
+	assert.Contains(t, logs, "container-")
+	assert.Contains(t, logs, Redacted)
+	// names are only masked as whole words
+	assert.Contains(t, logs, "mongodb")
+	// web-10 isn't partly masked as web-1
+	assert.Contains(t, logs, "from "+b.mask.Hostname("web-1")+",")
+	assert.Contains(t, logs, "on "+b.mask.Hostname("web-10")+" ")
+}
+
+func TestBundleReview(t *testing.T) {
+	b := New()
+	b.Add("config.json", []byte(`{"NAME": "secret-name"}`))
+
+	// view the file, redact text, then write
+	var out bytes.Buffer
+	ok, err := b.Review(strings.NewReader("1\nr\nsecret-name\nw\n"), &out)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Contains(t, out.String(), "config.json")
+	assert.Contains(t, out.String(), "secret-name", "shown before it was redacted")
+	assert.Equal(t, []string{"secret-name"}, b.redact)
+
+	ok, err = b.Review(strings.NewReader("x\nq\n"), io.Discard)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// end of input cancels
+	ok, err = b.Review(strings.NewReader(""), io.Discard)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	path := filepath.Join(t.TempDir(), "diag.zip")
+	require.NoError(t, b.Save(path, true, strings.NewReader("q\n"), io.Discard))
+	assert.NoFileExists(t, path)
+	require.NoError(t, b.Save(path, false, nil, io.Discard))
+	assert.FileExists(t, path)
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("BESZEL_TEST_LISTEN", "45876")
+	t.Setenv("BESZEL_TEST_TOKEN", "abc")
+	t.Setenv("BESZEL_TEST_SMTP_PASSWORD", "def")
+	t.Setenv("BESZEL_TEST_EMPTY_KEY", "")
+	t.Setenv("OTHER_TOKEN", "ghi")
+
+	assert.Equal(t, map[string]string{
+		"BESZEL_TEST_LISTEN":        "45876",
+		"BESZEL_TEST_TOKEN":         Redacted,
+		"BESZEL_TEST_SMTP_PASSWORD": Redacted,
+		"BESZEL_TEST_EMPTY_KEY":     "",
+	}, Env("BESZEL_TEST_"))
+}
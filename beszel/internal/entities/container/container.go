@@ -1,6 +1,17 @@
 package container
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+// Health states of a container, from its health check or restart state
+const (
+	HealthStarting   = "starting"
+	HealthHealthy    = "healthy"
+	HealthUnhealthy  = "unhealthy"
+	HealthRestarting = "restarting"
+)
 
 // Docker container info from /containers/json
 type ApiInfo struct {
@@ -15,8 +26,8 @@ type ApiInfo struct {
 	// Ports      []Port
 	// SizeRw     int64 `json:",omitempty"`
 	// SizeRootFs int64 `json:",omitempty"`
-	// Labels     map[string]string
-	// State      string
+	Labels map[string]string
+	State  string
 	// HostConfig struct {
 	// 	NetworkMode string            `json:",omitempty"`
 	// 	Annotations map[string]string `json:",omitempty"`
@@ -25,6 +36,66 @@ type ApiInfo struct {
 	// Mounts          []MountPoint
 }
 
+// Labels of the Compose project of a container, set by Docker Compose and podman-compose
+var projectLabels = []string{"com.docker.compose.project", "io.podman.compose.project"}
+
+// Project returns the name of the Compose project the container belongs to, or
+// an empty string if it wasn't created by Compose.
+func (c *ApiInfo) Project() string {
+	for _, label := range projectLabels {
+		if project := c.Labels[label]; project != "" {
+			return project
+		}
+	}
+	return ""
+}
+
+// Health returns the health check status from the container status, e.g.
+// "Up 2 hours (healthy)", or HealthRestarting if the container is restarting.
+// Returns an empty string if the container has no health check.
+func (c *ApiInfo) Health() string {
+	switch {
+	case c.State == "restarting":
+		return HealthRestarting
+	case strings.HasSuffix(c.Status, "(unhealthy)"):
+		return HealthUnhealthy
+	case strings.HasSuffix(c.Status, "(healthy)"):
+		return HealthHealthy
+	case strings.HasSuffix(c.Status, "(health: starting)"):
+		return HealthStarting
+	}
+	return ""
+}
+
+// Docker container details from /containers/{id}/json
+type ApiInspect struct {
+	RestartCount uint32
+}
+
+// Docker disk usage from /system/df. Sizes are -1 if not calculated.
+type ApiDiskUsage struct {
+	LayersSize int64
+	Images     []struct {
+		Size       int64
+		SharedSize int64
+		Containers int64
+	}
+	Containers []struct {
+		SizeRw int64
+	}
+	Volumes []struct {
+		UsageData struct {
+			Size     int64
+			RefCount int64
+		}
+	}
+	BuildCache []struct {
+		Size   int64
+		InUse  bool
+		Shared bool
+	}
+}
+
 // Docker container resources from /containers/{id}/stats
 type ApiStats struct {
 	Read        time.Time `json:"read"`               // Time of stats generation
@@ -32,6 +103,9 @@ type ApiStats struct {
 	Networks    map[string]NetworkStats
 	CPUStats    CPUStats    `json:"cpu_stats"`
 	MemoryStats MemoryStats `json:"memory_stats"`
+	BlkioStats  BlkioStats  `json:"blkio_stats"`
+	// Disk reads and writes. Windows only.
+	StorageStats StorageStats `json:"storage_stats"`
 }
 
 func (s *ApiStats) CalculateCpuPercentLinux(prevCpuContainer uint64, prevCpuSystem uint64) float64 {
@@ -98,6 +172,40 @@ type NetworkStats struct {
 	TxBytes uint64 `json:"tx_bytes"`
 }
 
+type BlkioStats struct {
+	// Bytes transferred to and from block devices, by device and operation. Linux only.
+	IoServiceBytesRecursive []BlkioStatEntry `json:"io_service_bytes_recursive"`
+}
+
+type BlkioStatEntry struct {
+	// "read" or "write" with cgroup v2, "Read", "Write", "Sync", "Async" or "Total" with cgroup v1
+	Op    string `json:"op"`
+	Value uint64 `json:"value"`
+}
+
+type StorageStats struct {
+	ReadSizeBytes  uint64 `json:"read_size_bytes,omitempty"`
+	WriteSizeBytes uint64 `json:"write_size_bytes,omitempty"`
+}
+
+// DiskIO returns the total bytes read and written by the container
+func (s *ApiStats) DiskIO() (read, write uint64) {
+	for _, entry := range s.BlkioStats.IoServiceBytesRecursive {
+		switch entry.Op {
+		case "read", "Read":
+			read += entry.Value
+		case "write", "Write":
+			write += entry.Value
+		}
+	}
+	return read + s.StorageStats.ReadSizeBytes, write + s.StorageStats.WriteSizeBytes
+}
+
+type prevDiskStats struct {
+	Read  uint64
+	Write uint64
+}
+
 type prevNetStats struct {
 	Sent uint64
 	Recv uint64
@@ -110,9 +218,15 @@ type Stats struct {
 	Mem         float64 `json:"m" cbor:"2,keyasint"`
 	NetworkSent float64 `json:"ns" cbor:"3,keyasint"`
 	NetworkRecv float64 `json:"nr" cbor:"4,keyasint"`
+	DiskRead    float64 `json:"dr,omitempty" cbor:"5,keyasint,omitempty"`
+	DiskWrite   float64 `json:"dw,omitempty" cbor:"6,keyasint,omitempty"`
+	Health      string  `json:"h,omitempty" cbor:"7,keyasint,omitempty"`  // health check status or HealthRestarting
+	Restarts    uint32  `json:"rc,omitempty" cbor:"8,keyasint,omitempty"` // restarts since the container was created
+	Project     string  `json:"p,omitempty" cbor:"9,keyasint,omitempty"`  // Compose project
 	// PrevCpu     [2]uint64    `json:"-"`
-	CpuSystem    uint64       `json:"-"`
-	CpuContainer uint64       `json:"-"`
-	PrevNet      prevNetStats `json:"-"`
-	PrevReadTime time.Time    `json:"-"`
+	CpuSystem    uint64        `json:"-"`
+	CpuContainer uint64        `json:"-"`
+	PrevNet      prevNetStats  `json:"-"`
+	PrevDisk     prevDiskStats `json:"-"`
+	PrevReadTime time.Time     `json:"-"`
 }
@@ -0,0 +1,95 @@
+package system
+
+import (
+	"maps"
+	"slices"
+)
+
+const (
+	bytesInGigabyte = 1024 * 1024 * 1024
+	bytesInMegabyte = 1024 * 1024
+)
+
+// Metric is a gauge derived from Stats, used by the agent's Prometheus and OTLP
+// exporters and the hub's remote write and InfluxDB exporters.
+type Metric struct {
+	Name   string   // Name without the "beszel_" prefix
+	Help   string   // Description of the metric
+	Value  float64  // Value in base units (bytes, seconds)
+	Labels []string // Label name, value pairs
+}
+
+// Metrics returns the stats as gauges. Samples of the same metric are consecutive.
+func (s *Stats) Metrics() []Metric {
+	metrics := make([]Metric, 0, 32)
+	add := func(name, help string, value float64, labels ...string) {
+		metrics = append(metrics, Metric{Name: name, Help: help, Value: value, Labels: labels})
+	}
+
+	// cpu and load
+	add("cpu_usage_percent", "CPU usage", s.Cpu)
+	for i, period := range []string{"1m", "5m", "15m"} {
+		add("load_average", "System load average", s.LoadAvg[i], "period", period)
+	}
+
+	// memory
+	add("memory_total_bytes", "Total memory", s.Mem*bytesInGigabyte)
+	add("memory_used_bytes", "Used memory", s.MemUsed*bytesInGigabyte)
+	add("memory_buff_cache_bytes", "Memory used by buffers and cache", s.MemBuffCache*bytesInGigabyte)
+	add("memory_usage_percent", "Memory usage", s.MemPct)
+	if s.MemZfsArc > 0 {
+		add("memory_zfs_arc_bytes", "Memory used by the ZFS ARC", s.MemZfsArc*bytesInGigabyte)
+	}
+	add("swap_total_bytes", "Total swap", s.Swap*bytesInGigabyte)
+	add("swap_used_bytes", "Used swap", s.SwapUsed*bytesInGigabyte)
+
+	// filesystems, with the root filesystem as "root"
+	filesystems := []string{"root"}
+	fsStats := []FsStats{{DiskTotal: s.DiskTotal, DiskUsed: s.DiskUsed, DiskReadPs: s.DiskReadPs, DiskWritePs: s.DiskWritePs}}
+	for _, name := range slices.Sorted(maps.Keys(s.ExtraFs)) {
+		filesystems = append(filesystems, name)
+		fsStats = append(fsStats, *s.ExtraFs[name])
+	}
+	for i, fs := range fsStats {
+		add("filesystem_size_bytes", "Filesystem size", fs.DiskTotal*bytesInGigabyte, "filesystem", filesystems[i])
+	}
+	for i, fs := range fsStats {
+		add("filesystem_used_bytes", "Filesystem space used", fs.DiskUsed*bytesInGigabyte, "filesystem", filesystems[i])
+	}
+	for i, fs := range fsStats {
+		add("disk_read_bytes_per_second", "Disk read throughput", fs.DiskReadPs*bytesInMegabyte, "filesystem", filesystems[i])
+	}
+	for i, fs := range fsStats {
+		add("disk_write_bytes_per_second", "Disk write throughput", fs.DiskWritePs*bytesInMegabyte, "filesystem", filesystems[i])
+	}
+
+	// network
+	add("network_sent_bytes_per_second", "Network upload throughput", float64(s.Bandwidth[0]))
+	add("network_received_bytes_per_second", "Network download throughput", float64(s.Bandwidth[1]))
+
+	// sensors
+	for _, name := range slices.Sorted(maps.Keys(s.Temperatures)) {
+		add("temperature_celsius", "Temperature sensor reading", s.Temperatures[name], "sensor", name)
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.GenericSensors)) {
+		sensor := s.GenericSensors[name]
+		add("sensor_value", "Generic sensor reading", sensor.Value, "sensor", name, "unit", sensor.Unit)
+	}
+
+	// gpus
+	gpuIds := slices.Sorted(maps.Keys(s.GPUData))
+	for _, id := range gpuIds {
+		add("gpu_usage_percent", "GPU usage", s.GPUData[id].Usage, "gpu", id, "name", s.GPUData[id].Name)
+	}
+	for _, id := range gpuIds {
+		add("gpu_memory_used_bytes", "GPU memory used", s.GPUData[id].MemoryUsed*bytesInMegabyte, "gpu", id, "name", s.GPUData[id].Name)
+	}
+	for _, id := range gpuIds {
+		add("gpu_memory_total_bytes", "GPU memory total", s.GPUData[id].MemoryTotal*bytesInMegabyte, "gpu", id, "name", s.GPUData[id].Name)
+	}
+	for _, id := range gpuIds {
+		add("gpu_power_watts", "GPU power draw", s.GPUData[id].Power, "gpu", id, "name", s.GPUData[id].Name)
+	}
+
+	return metrics
+}
@@ -38,9 +38,38 @@ type Stats struct {
 	Bandwidth      [2]uint64           `json:"b,omitzero" cbor:"26,keyasint,omitzero"`  // [sent bytes, recv bytes]
 	MaxBandwidth   [2]uint64           `json:"bm,omitzero" cbor:"27,keyasint,omitzero"` // [sent bytes, recv bytes]
 	LoadAvg        [3]float64          `json:"la,omitempty" cbor:"28,keyasint"`
+	SensorCategories map[string]string `json:"sc,omitempty" cbor:"30,keyasint,omitempty"` // sensor name -> category
+	Latency        [2]float64          `json:"lat,omitzero" cbor:"31,keyasint,omitzero"` // ms [collection to hub, hub to record write], set by the hub
+	RaidMissing    float64             `json:"rm,omitempty" cbor:"32,keyasint,omitempty"` // members missing from software RAID arrays
+	SmartFailing   float64             `json:"sf,omitempty" cbor:"33,keyasint,omitempty"` // disks failing their SMART health check
+	ServicesFailed float64             `json:"svf,omitempty" cbor:"34,keyasint,omitempty"` // watched systemd units in failed state
+	ChecksFailing  float64             `json:"cf,omitempty" cbor:"35,keyasint,omitempty"` // local health checks that failed
+	SensorsAlerting float64            `json:"sa,omitempty" cbor:"36,keyasint,omitempty"` // state sensors in an alert state
+	DockerDisk     *DockerDiskUsage    `json:"ddu,omitempty" cbor:"37,keyasint,omitempty"` // disk space used by Docker
+	TempRise       float64             `json:"tr,omitempty" cbor:"38,keyasint,omitempty"` // fastest temperature rise in °C/min since the previous record, set by the hub
+	NetworkInterfaces map[string][2]uint64 `json:"ni,omitempty" cbor:"39,keyasint,omitempty"` // interface name -> [sent bytes, recv bytes] per second
+	CpuCores       []float64           `json:"cpc,omitempty" cbor:"40,keyasint,omitempty"` // usage percent of each core
+	CpuFreq        []float64           `json:"cpf,omitempty" cbor:"41,keyasint,omitempty"` // current frequency of each core in MHz
+	CpuThrottled   float64             `json:"cth,omitempty" cbor:"42,keyasint,omitempty"` // cores throttled below their max frequency
+	Pressure       *Pressure           `json:"psi,omitempty" cbor:"43,keyasint,omitempty"` // pressure stall information
+	SwapIo         [2]uint64           `json:"sio,omitzero" cbor:"44,keyasint,omitzero"` // [swap in bytes, swap out bytes] per second
+	MemCached      float64             `json:"mc,omitempty" cbor:"45,keyasint,omitempty"` // page cache, including reclaimable slab
+	MemBuffers     float64             `json:"mbf,omitempty" cbor:"46,keyasint,omitempty"`
+	MemAvailable   float64             `json:"ma,omitempty" cbor:"47,keyasint,omitempty"` // memory available without swapping, including ZFS ARC
+	MemHugePages     float64           `json:"mh,omitempty" cbor:"48,keyasint,omitempty"` // memory preallocated as hugepages
+	MemHugePagesUsed float64           `json:"mhu,omitempty" cbor:"49,keyasint,omitempty"`
+	SensorsStale   float64             `json:"ss,omitempty" cbor:"50,keyasint,omitempty"` // generic sensors not updated within their stale timeout
 	// TODO: remove other load fields in future release in favor of load avg array
 }
 
+// Share of time in percent that some or all tasks were stalled waiting for a
+// resource, averaged over 60 seconds (Linux PSI)
+type Pressure struct {
+	Cpu    [2]float64 `json:"c" cbor:"0,keyasint"` // [some, full]
+	Memory [2]float64 `json:"m" cbor:"1,keyasint"` // [some, full]
+	Io     [2]float64 `json:"i" cbor:"2,keyasint"` // [some, full]
+}
+
 type GPUData struct {
 	Name        string  `json:"n" cbor:"0,keyasint"`
 	Temperature float64 `json:"-"`
@@ -56,8 +85,18 @@ type SensorData struct {
 	Unit    string  `json:"u" cbor:"1,keyasint"`
 	Min     float64 `json:"min,omitempty" cbor:"2,keyasint,omitempty"`
 	Max     float64 `json:"max,omitempty" cbor:"3,keyasint,omitempty"`
+	State   string  `json:"s,omitempty" cbor:"4,keyasint,omitempty"` // label of the state of a state sensor, whose value is the state's code
+	Alert   bool    `json:"a,omitempty" cbor:"5,keyasint,omitempty"` // the state is one of the sensor's alert states
 }
 
+// Sensor categories used to group temperature and generic sensors on the hub
+const (
+	SensorCategoryCpu     = "cpu"
+	SensorCategoryChassis = "chassis"
+	SensorCategoryAmbient = "ambient"
+	SensorCategoryCustom  = "custom"
+)
+
 type FsStats struct {
 	Time           time.Time `json:"-"`
 	Root           bool      `json:"-"`
@@ -72,6 +111,15 @@ type FsStats struct {
 	MaxDiskWritePS float64   `json:"wm,omitempty" cbor:"5,keyasint,omitempty"`
 }
 
+// DockerDiskUsage is the disk space used by Docker in GB, as shown by docker system df
+type DockerDiskUsage struct {
+	Images      float64 `json:"i" cbor:"0,keyasint"`
+	Containers  float64 `json:"c" cbor:"1,keyasint"` // writable layers of containers
+	Volumes     float64 `json:"v" cbor:"2,keyasint"`
+	BuildCache  float64 `json:"b" cbor:"3,keyasint"`
+	Reclaimable float64 `json:"r" cbor:"4,keyasint"` // unused images, volumes and build cache
+}
+
 type NetIoStats struct {
 	BytesRecv uint64
 	BytesSent uint64
@@ -88,6 +136,25 @@ const (
 	Freebsd
 )
 
+// Kind of error reported by a stats collector
+type CollectorErrorKind = uint8
+
+const (
+	CollectorOk CollectorErrorKind = iota
+	CollectorError
+	CollectorUnavailable
+	CollectorPermissionDenied
+	CollectorTimeout
+	CollectorParseError
+	CollectorStale
+)
+
+// Result of the most recent run of a stats collector
+type CollectorStatus struct {
+	Kind  CollectorErrorKind `json:"k" cbor:"0,keyasint"`
+	Error string             `json:"e,omitempty" cbor:"1,keyasint,omitempty"`
+}
+
 type Info struct {
 	Hostname       string     `json:"h" cbor:"0,keyasint"`
 	KernelVersion  string     `json:"k,omitempty" cbor:"1,keyasint,omitempty"`
@@ -109,12 +176,85 @@ type Info struct {
 	LoadAvg15      float64    `json:"l15,omitempty" cbor:"17,keyasint,omitempty"`
 	BandwidthBytes uint64     `json:"bb" cbor:"18,keyasint"`
 	LoadAvg        [3]float64 `json:"la,omitempty" cbor:"19,keyasint"`
+	Collectors     map[string]CollectorStatus `json:"cs,omitempty" cbor:"20,keyasint,omitempty"` // collector name -> status
+	DockerVersion  string     `json:"dv,omitempty" cbor:"21,keyasint,omitempty"`
+	CollectedAt    int64      `json:"ca,omitempty" cbor:"22,keyasint,omitempty"` // unix ms when the agent collected the data
+	Latency        float64    `json:"lat,omitempty" cbor:"23,keyasint,omitempty"` // total pipeline latency in ms, set by the hub
+	Raid           []RaidArray `json:"raid,omitempty" cbor:"24,keyasint,omitempty"`
+	Smart          []SmartDisk `json:"smart,omitempty" cbor:"25,keyasint,omitempty"`
+	Services       []ServiceUnit `json:"svc,omitempty" cbor:"26,keyasint,omitempty"`
+	Checks         []CheckResult `json:"chk,omitempty" cbor:"27,keyasint,omitempty"`
+	ContainerRuntime string   `json:"rt,omitempty" cbor:"28,keyasint,omitempty"` // CRI runtime name, e.g. containerd
+	PublicIp       string     `json:"pip,omitempty" cbor:"29,keyasint,omitempty"`
+	Labels         map[string]string `json:"lb,omitempty" cbor:"30,keyasint,omitempty"` // declared by the agent, moved to the system's labels by the hub
 	// TODO: remove load fields in future release in favor of load avg array
 }
 
+// Status of a software RAID (md) array
+type RaidArray struct {
+	Name    string  `json:"n" cbor:"0,keyasint"`
+	Level   string  `json:"l,omitempty" cbor:"1,keyasint,omitempty"`
+	State   string  `json:"s" cbor:"2,keyasint"`                      // e.g. "active", or "clean, degraded" from mdadm
+	Devices int     `json:"d" cbor:"3,keyasint"`                      // members the array should have
+	Active  int     `json:"a" cbor:"4,keyasint"`                      // members in sync
+	Failed  int     `json:"f,omitempty" cbor:"5,keyasint,omitempty"`  // members marked faulty
+	Sync    string  `json:"sy,omitempty" cbor:"6,keyasint,omitempty"` // resync, recovery, reshape or check in progress
+	SyncPct float64 `json:"sp,omitempty" cbor:"7,keyasint,omitempty"` // progress of the sync
+}
+
+// Missing returns the number of members the array is missing.
+func (r *RaidArray) Missing() int {
+	return max(0, r.Devices-r.Active)
+}
+
+// S.M.A.R.T. health of a disk
+type SmartDisk struct {
+	Name           string  `json:"n" cbor:"0,keyasint"` // device name, e.g. sda or nvme0
+	Model          string  `json:"m,omitempty" cbor:"1,keyasint,omitempty"`
+	Passed         bool    `json:"p" cbor:"2,keyasint"`
+	Temperature    float64 `json:"t,omitempty" cbor:"3,keyasint,omitempty"`
+	PowerOnHours   uint64  `json:"h,omitempty" cbor:"4,keyasint,omitempty"`
+	PendingSectors uint64  `json:"ps,omitempty" cbor:"5,keyasint,omitempty"`
+	Reallocated    uint64  `json:"rs,omitempty" cbor:"6,keyasint,omitempty"` // reallocated sectors
+	MediaErrors    uint64  `json:"me,omitempty" cbor:"7,keyasint,omitempty"` // NVMe media and data integrity errors
+}
+
+// State of a watched systemd unit
+type ServiceUnit struct {
+	Name     string `json:"n" cbor:"0,keyasint"`                      // unit name, without .service
+	State    string `json:"s" cbor:"1,keyasint"`                      // active state, e.g. active or failed, or not-found
+	SubState string `json:"ss,omitempty" cbor:"2,keyasint,omitempty"` // e.g. running or exited
+	Restarts uint64 `json:"r,omitempty" cbor:"3,keyasint,omitempty"`  // automatic restarts since the unit was started
+}
+
+// Failed returns true if the unit is in the failed state
+func (u *ServiceUnit) Failed() bool {
+	return u.State == "failed"
+}
+
+// Result of a local health check run by the agent
+type CheckResult struct {
+	Name    string  `json:"n" cbor:"0,keyasint"`
+	Type    string  `json:"t" cbor:"1,keyasint"` // tcp, http, process or event
+	Up      bool    `json:"u" cbor:"2,keyasint"`
+	Latency float64 `json:"l,omitempty" cbor:"3,keyasint,omitempty"` // ms, for tcp and http checks
+	Error   string  `json:"e,omitempty" cbor:"4,keyasint,omitempty"`
+}
+
+// Discrete event reported to the agent's event API, such as a completed backup
+type Event struct {
+	Name    string   `json:"n" cbor:"0,keyasint"`
+	Message string   `json:"m,omitempty" cbor:"1,keyasint,omitempty"`
+	Value   *float64 `json:"v,omitempty" cbor:"2,keyasint,omitempty"` // optional numeric payload
+	Time    int64    `json:"t" cbor:"3,keyasint"`                     // unix milliseconds
+}
+
 // Final data structure to return to the hub
 type CombinedData struct {
 	Stats      Stats              `json:"stats" cbor:"0,keyasint"`
 	Info       Info               `json:"info" cbor:"1,keyasint"`
 	Containers []*container.Stats `json:"container" cbor:"2,keyasint"`
+	Events     []Event            `json:"events,omitempty" cbor:"3,keyasint,omitempty"` // events since the previous collection for the hub
+	// Previous public IP if it changed in this update, set by the hub
+	PrevPublicIp string `json:"-" cbor:"-"`
 }
@@ -3,10 +3,12 @@ package hub
 import (
 	"beszel/internal/common"
 	"beszel/internal/hub/expirymap"
+	"beszel/internal/hub/systems"
 	"beszel/internal/hub/ws"
 	"errors"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -26,8 +28,10 @@ type agentConnectRequest struct {
 	agentSemVer semver.Version
 	// isUniversalToken is true if the token is a universal token.
 	isUniversalToken bool
-	// userId is the user ID associated with the universal token.
+	// userId is the user ID associated with the universal or enrollment token.
 	userId string
+	// enrollment is the record of an unused enrollment token, nil if the token isn't one.
+	enrollment *core.Record
 }
 
 // universalTokenMap stores active universal tokens and their associated user IDs.
@@ -67,8 +71,16 @@ func (acr *agentConnectRequest) agentConnect() (err error) {
 
 	// Find matching fingerprint records for this token
 	fpRecords := getFingerprintRecordsByToken(acr.token, acr.hub)
+
+	// Check if token is an unused enrollment token. Once used, the agent is
+	// identified by the fingerprint record created for the token.
 	if len(fpRecords) == 0 && !acr.isUniversalToken {
-		// Invalid token - no records found and not a universal token
+		if acr.enrollment = findEnrollmentToken(acr.hub, acr.token); acr.enrollment != nil {
+			acr.userId = acr.enrollment.GetString("user")
+		}
+	}
+	if len(fpRecords) == 0 && !acr.isUniversalToken && acr.enrollment == nil {
+		// Invalid token - no records found and not a universal or enrollment token
 		return acr.sendResponseError(acr.res, http.StatusUnauthorized, "Invalid token")
 	}
 
@@ -93,6 +105,7 @@ func (acr *agentConnectRequest) agentConnect() (err error) {
 // SSH key signature, then adds the system to the system manager.
 func (acr *agentConnectRequest) verifyWsConn(conn *gws.Conn, fpRecords []ws.FingerprintRecord) (err error) {
 	wsConn := ws.NewWsConnection(conn)
+	wsConn.SetChunkSize(acr.hub.chunkSize)
 
 	// must set wsConn in connection store before the read loop
 	conn.Session().Store("wsConn", wsConn)
@@ -111,7 +124,7 @@ func (acr *agentConnectRequest) verifyWsConn(conn *gws.Conn, fpRecords []ws.Fing
 		return err
 	}
 
-	agentFingerprint, err := wsConn.GetFingerprint(acr.token, signer, acr.isUniversalToken)
+	agentFingerprint, err := wsConn.GetFingerprint(acr.token, signer, acr.isUniversalToken || acr.enrollment != nil)
 	if err != nil {
 		return err
 	}
@@ -125,6 +138,17 @@ func (acr *agentConnectRequest) verifyWsConn(conn *gws.Conn, fpRecords []ws.Fing
 	return acr.hub.sm.AddWebSocketSystem(fpRecord.SystemId, acr.agentSemVer, wsConn)
 }
 
+// getChunkSize returns the max WebSocket message size requested from agents,
+// set in KB by CHUNK_SIZE. Larger payloads are sent in chunks.
+func getChunkSize() int {
+	if value, _ := GetEnv("CHUNK_SIZE"); value != "" {
+		if kb, err := strconv.Atoi(value); err == nil && kb > 0 {
+			return max(kb*1024, common.MinChunkSize)
+		}
+	}
+	return ws.DefaultChunkSize
+}
+
 // validateAgentHeaders extracts and validates the token and agent version from HTTP headers.
 func (acr *agentConnectRequest) validateAgentHeaders(headers http.Header) (string, string, error) {
 	token := headers.Get("X-Token")
@@ -175,10 +199,14 @@ func (acr *agentConnectRequest) findOrCreateSystemForToken(fpRecords []ws.Finger
 }
 
 // handleNoRecords handles the case where no fingerprint records are found for a token.
-// A new system is created if the token is a valid universal token.
+// A new system is created if the token is a valid universal or enrollment token.
 func (acr *agentConnectRequest) handleNoRecords(agentFingerprint common.FingerprintResponse) (ws.FingerprintRecord, error) {
 	var fpRecord ws.FingerprintRecord
 
+	if acr.enrollment != nil {
+		return acr.enrollSystem(agentFingerprint)
+	}
+
 	if !acr.isUniversalToken || acr.userId == "" {
 		return fpRecord, errors.New("no matching fingerprints")
 	}
@@ -229,7 +257,7 @@ func (acr *agentConnectRequest) handleMultipleRecordsOrUniversalToken(fpRecords
 // createNewSystemForUniversalToken creates a new system and fingerprint record for a universal token.
 func (acr *agentConnectRequest) createNewSystemForUniversalToken(agentFingerprint common.FingerprintResponse) (ws.FingerprintRecord, error) {
 	var fpRecord ws.FingerprintRecord
-	if !acr.isUniversalToken || acr.userId == "" {
+	if (!acr.isUniversalToken && acr.enrollment == nil) || acr.userId == "" {
 		return fpRecord, errors.New("invalid token")
 	}
 
@@ -266,14 +294,30 @@ func (acr *agentConnectRequest) createSystem(agentFingerprint common.Fingerprint
 	if agentFingerprint.Port == "" {
 		agentFingerprint.Port = "45876"
 	}
+	name := agentFingerprint.Name
+	if name == "" {
+		name = agentFingerprint.Hostname
+	}
 	// create new record
 	systemRecord := core.NewRecord(systemsCollection)
-	systemRecord.Set("name", agentFingerprint.Hostname)
+	systemRecord.Set("name", name)
 	systemRecord.Set("host", remoteAddr)
 	systemRecord.Set("port", agentFingerprint.Port)
 	systemRecord.Set("users", []string{acr.userId})
+	if labels := systems.SanitizeLabels(agentFingerprint.Labels); len(labels) > 0 {
+		systemRecord.Set("labels", labels)
+	}
 
-	return systemRecord.Id, acr.hub.Save(systemRecord)
+	if err := acr.hub.Save(systemRecord); err != nil {
+		return "", err
+	}
+	details := map[string]any{"token": "universal"}
+	if acr.enrollment != nil {
+		details["token"] = "enrollment"
+	}
+	user, _ := acr.hub.FindRecordById("users", acr.userId)
+	saveAuditEntry(acr.hub, user, "systems.create", systemRecord, details, remoteAddr)
+	return systemRecord.Id, nil
 }
 
 // SetFingerprint creates or updates a fingerprint record in the database.
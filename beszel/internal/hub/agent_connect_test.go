@@ -414,6 +414,13 @@ func TestCreateSystemFromAgentData(t *testing.T) {
 			// Verify users array
 			users := systemRecord.Get("users")
 			assert.Equal(t, tc.expectedUsers, users)
+
+			// Verify the system is in the audit log
+			entry, err := testApp.FindFirstRecordByData("audit_log", "record", recordId)
+			require.NoError(t, err)
+			assert.Equal(t, "systems.create", entry.GetString("action"))
+			assert.Equal(t, userRecord.Id, entry.GetString("actor"))
+			assert.Equal(t, tc.expectedHost, entry.GetString("ip"))
 		})
 	}
 }
@@ -1698,3 +1705,78 @@ func TestGetRealIP(t *testing.T) {
 		})
 	}
 }
+
+// TestEnrollmentTokenFlow tests registering a system with a one-time enrollment token
+func TestEnrollmentTokenFlow(t *testing.T) {
+	hub, testApp, err := createTestHub(t)
+	require.NoError(t, err)
+	defer testApp.Cleanup()
+
+	userRecord, err := createTestUser(testApp)
+	require.NoError(t, err)
+
+	token := strings.Repeat("a", 40)
+	tokenRecord, err := createTestRecord(testApp, "enrollment_tokens", map[string]any{
+		"user":    userRecord.Id,
+		"token":   token,
+		"labels":  map[string]string{"env": "prod"},
+		"expires": time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = createTestRecord(testApp, "enrollment_tokens", map[string]any{
+		"user":    userRecord.Id,
+		"token":   strings.Repeat("b", 40),
+		"expires": time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	assert.Nil(t, findEnrollmentToken(hub, strings.Repeat("b", 40)), "expired token")
+	assert.Nil(t, findEnrollmentToken(hub, "unknown"))
+	enrollment := findEnrollmentToken(hub, token)
+	require.NotNil(t, enrollment)
+
+	acr := &agentConnectRequest{
+		hub:        hub,
+		token:      token,
+		userId:     enrollment.GetString("user"),
+		enrollment: enrollment,
+		req:        &http.Request{RemoteAddr: "192.168.0.10:40000"},
+	}
+	fingerprint := common.FingerprintResponse{
+		Fingerprint: "enrolled-fingerprint",
+		Hostname:    "host-1",
+		Name:        "web-1",
+		Labels:      map[string]string{"env": "dev", "role": "web"},
+	}
+	fpRecord, err := acr.findOrCreateSystemForToken(nil, fingerprint)
+	require.NoError(t, err)
+
+	systemRecord, err := testApp.FindRecordById("systems", fpRecord.SystemId)
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", systemRecord.GetString("name"))
+	assert.Equal(t, "192.168.0.10", systemRecord.GetString("host"))
+	var labels map[string]string
+	require.NoError(t, systemRecord.UnmarshalJSONField("labels", &labels))
+	assert.Equal(t, map[string]string{"env": "dev", "role": "web"}, labels, "agent labels take precedence")
+
+	// the token is used and linked to the system
+	tokenRecord, err = testApp.FindRecordById("enrollment_tokens", tokenRecord.Id)
+	require.NoError(t, err)
+	assert.False(t, tokenRecord.GetDateTime("used").IsZero())
+	assert.Equal(t, fpRecord.SystemId, tokenRecord.GetString("system"))
+	assert.Nil(t, findEnrollmentToken(hub, token))
+
+	// the token can't register another system
+	_, err = acr.findOrCreateSystemForToken(nil, common.FingerprintResponse{Fingerprint: "other"})
+	assert.ErrorIs(t, err, errTokenUsed)
+
+	// the agent reconnects with the fingerprint record of the token
+	fpRecords := getFingerprintRecordsByToken(token, hub)
+	require.Len(t, fpRecords, 1)
+	reconnect := &agentConnectRequest{hub: hub, token: token}
+	record, err := reconnect.findOrCreateSystemForToken(fpRecords, fingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, fpRecord.SystemId, record.SystemId)
+	_, err = reconnect.findOrCreateSystemForToken(fpRecords, common.FingerprintResponse{Fingerprint: "other"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,181 @@
+package hub
+
+import (
+	"beszel"
+	"beszel/internal/alerts"
+	"beszel/internal/hub/systems"
+	"cmp"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/blang/semver"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Agent version warnings
+const (
+	warningBelowMinimum = "below_minimum" // older than MIN_AGENT_VERSION
+	warningJsonEncoding = "json_encoding" // sends JSON instead of CBOR (deprecated)
+)
+
+// AgentVersionReport is the distribution of agent versions across systems
+type AgentVersionReport struct {
+	Hub      string               `json:"hub"`               // hub version
+	Minimum  string               `json:"minimum,omitempty"` // MIN_AGENT_VERSION
+	Versions []AgentVersionCount  `json:"versions"`          // newest first
+	Warnings []AgentVersionSystem `json:"warnings"`          // systems with warnings
+}
+
+// AgentVersionCount is the number of systems running an agent version
+type AgentVersionCount struct {
+	Version string `json:"version"`
+	Count   int    `json:"count"`
+}
+
+// AgentVersionSystem is a system with agent version warnings
+type AgentVersionSystem struct {
+	Id       string   `json:"id"`
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Warnings []string `json:"warnings"`
+}
+
+// agentVersionChecker warns about outdated agents once per system and version.
+type agentVersionChecker struct {
+	minimum *semver.Version
+	mu      sync.Mutex
+	warned  map[string]string // system id -> warned version
+}
+
+// newAgentVersionChecker reads the optional MIN_AGENT_VERSION.
+func newAgentVersionChecker() *agentVersionChecker {
+	checker := &agentVersionChecker{warned: make(map[string]string)}
+	if v, _ := GetEnv("MIN_AGENT_VERSION"); v != "" {
+		if minimum, err := semver.ParseTolerant(v); err == nil {
+			checker.minimum = &minimum
+		} else {
+			slog.Warn("Invalid MIN_AGENT_VERSION", "value", v, "err", err)
+		}
+	}
+	return checker
+}
+
+// warnings returns the warnings for an agent version.
+func (c *agentVersionChecker) warnings(version semver.Version) []string {
+	warnings := []string{}
+	if c.minimum != nil && version.LT(*c.minimum) {
+		warnings = append(warnings, warningBelowMinimum)
+	}
+	if version.LT(beszel.MinVersionCbor) {
+		warnings = append(warnings, warningJsonEncoding)
+	}
+	return warnings
+}
+
+// shouldWarn reports whether warnings for a system's version were not sent yet.
+func (c *agentVersionChecker) shouldWarn(systemId string, version semver.Version) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.warned[systemId] == version.String() {
+		return false
+	}
+	c.warned[systemId] = version.String()
+	return true
+}
+
+// HandleAgentHandshake logs a warning and notifies the system's users if a
+// connected agent is outdated. Each system and version is only reported once.
+func (h *Hub) HandleAgentHandshake(systemId string, handshake systems.AgentHandshake) {
+	warnings := h.versions.warnings(handshake.Version)
+	if len(warnings) == 0 || !h.versions.shouldWarn(systemId, handshake.Version) {
+		return
+	}
+	record, err := h.FindRecordById("systems", systemId)
+	if err != nil {
+		return
+	}
+	name := record.GetString("name")
+	h.Logger().Warn("Outdated agent", "system", name, "version", handshake.Version.String(), "warnings", warnings)
+
+	// only notify users if the admin set a minimum version
+	if !slices.Contains(warnings, warningBelowMinimum) {
+		return
+	}
+	title := fmt.Sprintf("%s agent is outdated", name)
+	message := fmt.Sprintf("%s is running agent %s, which is older than the minimum supported version %s. Please update the agent.",
+		name, handshake.Version, h.versions.minimum)
+	go func() {
+		for _, userId := range alerts.SystemUserIDs(h, record) {
+			if err := h.SendAlert(alerts.AlertMessageData{
+				UserID:   userId,
+				Title:    title,
+				Message:  message,
+				Link:     h.MakeLink("system", name),
+				LinkText: "View " + name,
+				Labels:   alerts.SystemLabels(record),
+			}); err != nil {
+				h.Logger().Error("Failed to send agent version alert", "err", err)
+			}
+		}
+	}()
+}
+
+// getAgentVersions handles GET /api/beszel/agent-versions.
+func (h *Hub) getAgentVersions(e *core.RequestEvent) error {
+	fleet, err := h.getFleetSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	return e.JSON(http.StatusOK, h.newAgentVersionReport(fleet, h.sm.GetAgentHandshakes()))
+}
+
+// newAgentVersionReport builds the version distribution of systems, preferring
+// the version from the latest handshake over the one in the system info.
+func (h *Hub) newAgentVersionReport(fleet []fleetSystem, handshakes map[string]systems.AgentHandshake) AgentVersionReport {
+	report := AgentVersionReport{
+		Hub:      beszel.Version,
+		Versions: []AgentVersionCount{},
+		Warnings: []AgentVersionSystem{},
+	}
+	if h.versions.minimum != nil {
+		report.Minimum = h.versions.minimum.String()
+	}
+
+	counts := make(map[string]int)
+	parsed := make(map[string]semver.Version)
+	for _, sys := range fleet {
+		handshake, connected := handshakes[sys.Id]
+		version := handshake.Version
+		if !connected {
+			var ok bool
+			if version, ok = parseAgentVersion(sys.Info.AgentVersion); !ok {
+				continue
+			}
+		}
+		v := version.String()
+		counts[v]++
+		parsed[v] = version
+		if warnings := h.versions.warnings(version); len(warnings) > 0 {
+			report.Warnings = append(report.Warnings, AgentVersionSystem{Id: sys.Id, Name: sys.Name, Version: v, Warnings: warnings})
+		}
+	}
+	for v, count := range counts {
+		report.Versions = append(report.Versions, AgentVersionCount{Version: v, Count: count})
+	}
+	slices.SortFunc(report.Versions, func(a, b AgentVersionCount) int { return parsed[b.Version].Compare(parsed[a.Version]) })
+	slices.SortFunc(report.Warnings, func(a, b AgentVersionSystem) int { return cmp.Compare(a.Name, b.Name) })
+	return report
+}
+
+// parseAgentVersion parses the version reported in system info.
+func parseAgentVersion(v string) (semver.Version, bool) {
+	if v = strings.TrimSpace(v); v == "" {
+		return semver.Version{}, false
+	}
+	version, err := semver.ParseTolerant(v)
+	return version, err == nil
+}
@@ -0,0 +1,110 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel"
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentVersions(t *testing.T) {
+	t.Setenv("BESZEL_HUB_MIN_AGENT_VERSION", "0.11.0")
+
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+
+	createSystem := func(name string, users []string, agentVersion string) string {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":  name,
+			"host":  "127.0.0.1",
+			"users": users,
+		})
+		require.NoError(t, err)
+		// info is reset on create
+		record.Set("info", system.Info{AgentVersion: agentVersion})
+		require.NoError(t, hub.SaveNoValidate(record))
+		return record.Id
+	}
+
+	createSystem("a", []string{user.Id}, "0.12.0")
+	createSystem("b", []string{user.Id}, "0.12.0")
+	jsonId := createSystem("c", []string{user.Id}, "0.11.1")
+	oldId := createSystem("d", []string{user.Id}, "0.10.2")
+	createSystem("e", []string{user.Id}, "")
+	createSystem("other", []string{otherUser.Id}, "0.9.0")
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /agent-versions - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/agent-versions",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /agent-versions - distribution and warnings",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/agent-versions",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"minimum":"0.11.0"`, `"hub":"` + beszel.Version + `"`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				body, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				var report struct {
+					Versions []struct {
+						Version string `json:"version"`
+						Count   int    `json:"count"`
+					} `json:"versions"`
+					Warnings []struct {
+						Id       string   `json:"id"`
+						Version  string   `json:"version"`
+						Warnings []string `json:"warnings"`
+					} `json:"warnings"`
+				}
+				require.NoError(t, json.Unmarshal(body, &report))
+
+				require.Len(t, report.Versions, 3, "systems of other users and without a version are skipped")
+				assert.Equal(t, "0.12.0", report.Versions[0].Version)
+				assert.Equal(t, 2, report.Versions[0].Count)
+				assert.Equal(t, "0.11.1", report.Versions[1].Version)
+				assert.Equal(t, "0.10.2", report.Versions[2].Version)
+
+				require.Len(t, report.Warnings, 2)
+				assert.Equal(t, jsonId, report.Warnings[0].Id)
+				assert.Equal(t, []string{"json_encoding"}, report.Warnings[0].Warnings)
+				assert.Equal(t, oldId, report.Warnings[1].Id)
+				assert.Equal(t, []string{"below_minimum", "json_encoding"}, report.Warnings[1].Warnings)
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,113 @@
+package hub
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// Scopes of API keys
+const (
+	scopeSystemsRead = "systems:read"
+	scopeStatsRead   = "stats:read"
+	scopeAlertsRead  = "alerts:read"
+	scopeAlertsWrite = "alerts:write"
+)
+
+var apiKeyScopes = []string{scopeSystemsRead, scopeStatsRead, scopeAlertsRead, scopeAlertsWrite}
+
+// request store key of the scopes of the request's API key
+const apiKeyScopesKey = "beszelApiKeyScopes"
+
+// validateAPIKey checks the scopes and expiration of a new API key. The token
+// is always generated by the hub, and readonly users can't create keys that
+// change alerts.
+func validateAPIKey(e *core.RecordRequestEvent) error {
+	var scopes []string
+	if err := e.Record.UnmarshalJSONField("scopes", &scopes); err != nil || len(scopes) == 0 {
+		return e.BadRequestError("API keys need at least one scope", err)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(apiKeyScopes, scope) {
+			return e.BadRequestError("Invalid scope: "+scope, nil)
+		}
+	}
+	if slices.Contains(scopes, scopeAlertsWrite) && e.Auth != nil && e.Auth.GetString("role") == "readonly" {
+		return e.ForbiddenError("Readonly users can't create keys with the alerts:write scope", nil)
+	}
+	if expires := e.Record.GetDateTime("expires"); !expires.IsZero() && !expires.Time().After(time.Now()) {
+		return e.BadRequestError("The expiration date must be in the future", nil)
+	}
+	e.Record.Set("token", "")
+	e.Record.Set("last_used", "")
+	return e.Next()
+}
+
+// apiKeyMiddleware authenticates requests to /api/v1 that have an API key in
+// the X-API-Key or Authorization header, or the key query param of WebSocket
+// requests, as the key's user, limited to the key's scopes. Runs after
+// PocketBase loads auth tokens, so requests with an auth token keep all scopes.
+func apiKeyMiddleware() *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Id:       "beszelApiKey",
+		Priority: apis.DefaultLoadAuthTokenMiddlewarePriority + 1,
+		Func: func(e *core.RequestEvent) error {
+			if e.Auth != nil || !strings.HasPrefix(e.Request.URL.Path, "/api/v1/") {
+				return e.Next()
+			}
+			token := e.Request.Header.Get("X-API-Key")
+			if token == "" {
+				token = strings.TrimPrefix(e.Request.Header.Get("Authorization"), "Bearer ")
+			}
+			// browsers can't set headers of WebSocket requests
+			if token == "" && strings.EqualFold(e.Request.Header.Get("Upgrade"), "websocket") {
+				token = e.Request.URL.Query().Get("key")
+			}
+			if token == "" {
+				return e.Next()
+			}
+			now := time.Now().UTC()
+			key, err := e.App.FindFirstRecordByFilter("api_keys", "token = {:token} && (expires = '' || expires > {:now})", dbx.Params{
+				"token": token,
+				"now":   now,
+			})
+			if err != nil {
+				return e.UnauthorizedError("Invalid or expired API key", nil)
+			}
+			user, err := e.App.FindRecordById("users", key.GetString("user"))
+			if err != nil {
+				return e.UnauthorizedError("Invalid or expired API key", nil)
+			}
+			var scopes []string
+			_ = key.UnmarshalJSONField("scopes", &scopes)
+			e.Auth = user
+			e.Set(apiKeyScopesKey, scopes)
+			if now.Sub(key.GetDateTime("last_used").Time()) >= time.Minute {
+				key.Set("last_used", now)
+				if err := e.App.SaveNoValidate(key); err != nil {
+					e.App.Logger().Warn("Failed to update API key", "id", key.Id, "err", err)
+				}
+			}
+			return e.Next()
+		},
+	}
+}
+
+// requireScope allows authenticated requests without an API key, or with an
+// API key that has the scope
+func requireScope(scope string) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		if e.Auth == nil {
+			return e.UnauthorizedError("The request requires a valid API key or auth token.", nil)
+		}
+		if scopes, ok := e.Get(apiKeyScopesKey).([]string); ok && !slices.Contains(scopes, scope) {
+			return e.ForbiddenError("The API key doesn't have the "+scope+" scope.", nil)
+		}
+		return e.Next()
+	}
+}
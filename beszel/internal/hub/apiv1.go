@@ -0,0 +1,351 @@
+package hub
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Versioned REST API at /api/v1, documented in supplemental/guides/api.md.
+// Requests are authenticated with an API key, limited to its scopes, or with
+// a user auth token. Responses of v1 only change in backward compatible ways.
+
+// Longest time range of a stats query
+const maxAPIStatsRange = 400 * 24 * time.Hour
+
+// APISystem is a system returned by the v1 API
+type APISystem struct {
+	Id      string            `json:"id"`
+	Name    string            `json:"name"`
+	Host    string            `json:"host"`
+	Status  string            `json:"status"`
+	Labels  map[string]string `json:"labels"`
+	Info    system.Info       `json:"info"`
+	Updated time.Time         `json:"updated"`
+}
+
+// APIStats is the response of the v1 stats and sensors endpoints
+type APIStats[T any] struct {
+	System     string    `json:"system"`
+	Resolution string    `json:"resolution"` // record type
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Records    []T       `json:"records"` // oldest first
+}
+
+// APISensors are the sensor readings of a stats record
+type APISensors struct {
+	Created      time.Time            `json:"created"`
+	Temperatures map[string]float64   `json:"temperatures"` // °C
+	Sensors      map[string]APISensor `json:"sensors"`
+}
+
+// APISensor is a reading of a generic sensor
+type APISensor struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+	State string  `json:"state,omitempty"`
+}
+
+// APIAlert is an alert of the user returned by the v1 API
+type APIAlert struct {
+	Id        string    `json:"id"`
+	System    string    `json:"system"`
+	Name      string    `json:"name"`
+	Value     float64   `json:"value"`
+	Min       int       `json:"min"`
+	Target    string    `json:"target"`
+	Triggered bool      `json:"triggered"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+func (h *Hub) registerApiV1Routes(se *core.ServeEvent) {
+	se.Router.Bind(apiKeyMiddleware())
+	api := se.Router.Group("/api/v1")
+	api.GET("/systems", h.v1ListSystems).BindFunc(requireScope(scopeSystemsRead))
+	api.GET("/systems/{id}", h.v1GetSystem).BindFunc(requireScope(scopeSystemsRead))
+	api.GET("/systems/{id}/stats", h.v1GetStats).BindFunc(requireScope(scopeStatsRead))
+	api.GET("/systems/{id}/sensors", h.v1GetSensors).BindFunc(requireScope(scopeStatsRead))
+	api.GET("/systems/{id}/export", h.v1ExportStats).BindFunc(requireScope(scopeStatsRead))
+	api.GET("/alerts", h.v1ListAlerts).BindFunc(requireScope(scopeAlertsRead))
+	api.POST("/alerts", h.v1UpsertAlert).BindFunc(requireScope(scopeAlertsWrite), auditRoute("alerts.upsert"))
+	api.DELETE("/alerts/{id}", h.v1DeleteAlert).BindFunc(requireScope(scopeAlertsWrite), auditRoute("alerts.delete"))
+	if h.live != nil {
+		api.GET("/live", h.live.handleConnect).BindFunc(requireScope(scopeStatsRead))
+	}
+}
+
+// newAPISystem returns the system of a record. Names and addresses are masked
+// for readonly users, as in systems records.
+func (h *Hub) newAPISystem(record *core.Record, readonly bool) APISystem {
+	apiSystem := APISystem{
+		Id:      record.Id,
+		Name:    record.GetString("name"),
+		Host:    record.GetString("host"),
+		Status:  record.GetString("status"),
+		Labels:  map[string]string{},
+		Updated: record.GetDateTime("updated").Time(),
+	}
+	_ = record.UnmarshalJSONField("labels", &apiSystem.Labels)
+	_ = record.UnmarshalJSONField("info", &apiSystem.Info)
+	if readonly {
+		apiSystem.Name = h.mask.Hostname(apiSystem.Name)
+		apiSystem.Host = h.mask.Hostname(apiSystem.Host)
+		apiSystem.Info.Hostname = h.mask.Hostname(apiSystem.Info.Hostname)
+		apiSystem.Info.PublicIp = h.mask.Text(apiSystem.Info.PublicIp)
+	}
+	return apiSystem
+}
+
+func isReadonly(e *core.RequestEvent) bool {
+	return e.Auth != nil && e.Auth.GetString("role") == "readonly"
+}
+
+// findAPISystem returns a system record if the user can view it
+func findAPISystem(e *core.RequestEvent, id string) (*core.Record, error) {
+	record, err := e.App.FindRecordById("systems", id)
+	if err != nil {
+		return nil, e.NotFoundError("System not found", err)
+	}
+	info, err := e.RequestInfo()
+	if err != nil {
+		return nil, e.InternalServerError("", err)
+	}
+	if canAccess, err := e.App.CanAccessRecord(record, info, record.Collection().ViewRule); !canAccess {
+		return nil, e.NotFoundError("System not found", err)
+	}
+	return record, nil
+}
+
+// v1ListSystems handles GET /api/v1/systems. Returns the systems visible to
+// the user, sorted by name.
+func (h *Hub) v1ListSystems(e *core.RequestEvent) error {
+	records, err := findVisibleSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	readonly := isReadonly(e)
+	systems := make([]APISystem, 0, len(records))
+	for _, record := range records {
+		systems = append(systems, h.newAPISystem(record, readonly))
+	}
+	slices.SortFunc(systems, func(a, b APISystem) int { return strings.Compare(a.Name, b.Name) })
+	return e.JSON(http.StatusOK, systems)
+}
+
+// v1GetSystem handles GET /api/v1/systems/{id}
+func (h *Hub) v1GetSystem(e *core.RequestEvent) error {
+	record, err := findAPISystem(e, e.Request.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	return e.JSON(http.StatusOK, h.newAPISystem(record, isReadonly(e)))
+}
+
+// querySystemStats returns the system in the id path param and its stats records
+// param for the from, to, resolution and points query params. The range is the
+// last hour by default, and the resolution is the shortest record type that
+// covers it with at most points records.
+func (h *Hub) querySystemStats(e *core.RequestEvent) (*core.Record, APIStats[storage.SystemStats], error) {
+	var result APIStats[storage.SystemStats]
+	record, err := findAPISystem(e, e.Request.PathValue("id"))
+	if err != nil {
+		return nil, result, err
+	}
+	query := e.Request.URL.Query()
+	now := time.Now().UTC()
+	result.System, result.To, result.From = record.Id, now, now.Add(-time.Hour)
+	if value := query.Get("to"); value != "" {
+		to, err := types.ParseDateTime(value)
+		if err != nil || to.IsZero() {
+			return nil, result, e.BadRequestError("Invalid to", err)
+		}
+		result.To, result.From = to.Time(), to.Time().Add(-time.Hour)
+	}
+	if value := query.Get("from"); value != "" {
+		from, err := types.ParseDateTime(value)
+		if err != nil || from.IsZero() {
+			return nil, result, e.BadRequestError("Invalid from", err)
+		}
+		result.From = from.Time()
+	}
+	if !result.From.Before(result.To) || result.To.Sub(result.From) > maxAPIStatsRange {
+		return nil, result, e.BadRequestError("Invalid range", nil)
+	}
+	points := defaultMaxDataPoints
+	if value := query.Get("points"); value != "" {
+		if points, err = strconv.Atoi(value); err != nil || points <= 0 {
+			return nil, result, e.BadRequestError("Invalid points", err)
+		}
+	}
+	switch result.Resolution = query.Get("resolution"); result.Resolution {
+	case "", "auto":
+		result.Resolution = h.recordTypeForRange(result.From, result.To, points, now)
+	default:
+		if !slices.Contains(statsTypes, result.Resolution) {
+			return nil, result, e.BadRequestError("Invalid resolution", nil)
+		}
+	}
+	result.Records, err = h.storage.SystemStats(storage.Query{
+		System: record.Id,
+		Type:   result.Resolution,
+		Since:  result.From,
+		Until:  result.To,
+	})
+	if err != nil {
+		return nil, result, e.InternalServerError("", err)
+	}
+	return record, result, nil
+}
+
+// v1GetStats handles GET /api/v1/systems/{id}/stats?from=&to=&resolution=&points=.
+// Returns the system's stats records in the range, with the same fields as
+// the stats that agents send.
+func (h *Hub) v1GetStats(e *core.RequestEvent) error {
+	_, result, err := h.querySystemStats(e)
+	if err != nil {
+		return err
+	}
+	records := make([]statsRecord, len(result.Records))
+	for i := range result.Records {
+		records[i] = statsRecord{Created: result.Records[i].Created, Stats: result.Records[i].Stats}
+	}
+	return e.JSON(http.StatusOK, APIStats[statsRecord]{
+		System:     result.System,
+		Resolution: result.Resolution,
+		From:       result.From,
+		To:         result.To,
+		Records:    records,
+	})
+}
+
+// v1GetSensors handles GET /api/v1/systems/{id}/sensors?from=&to=&resolution=&points=.
+// Returns the temperatures and generic sensor readings of the system's stats
+// records in the range.
+func (h *Hub) v1GetSensors(e *core.RequestEvent) error {
+	_, result, err := h.querySystemStats(e)
+	if err != nil {
+		return err
+	}
+	records := make([]APISensors, len(result.Records))
+	for i := range result.Records {
+		stats := &result.Records[i].Stats
+		records[i] = APISensors{
+			Created:      result.Records[i].Created,
+			Temperatures: stats.Temperatures,
+			Sensors:      make(map[string]APISensor, len(stats.GenericSensors)),
+		}
+		if records[i].Temperatures == nil {
+			records[i].Temperatures = map[string]float64{}
+		}
+		for name, sensor := range stats.GenericSensors {
+			records[i].Sensors[name] = APISensor{Value: sensor.Value, Unit: sensor.Unit, State: sensor.State}
+		}
+	}
+	return e.JSON(http.StatusOK, APIStats[APISensors]{
+		System:     result.System,
+		Resolution: result.Resolution,
+		From:       result.From,
+		To:         result.To,
+		Records:    records,
+	})
+}
+
+func newAPIAlert(record *core.Record) APIAlert {
+	return APIAlert{
+		Id:        record.Id,
+		System:    record.GetString("system"),
+		Name:      record.GetString("name"),
+		Value:     record.GetFloat("value"),
+		Min:       record.GetInt("min"),
+		Target:    record.GetString("target"),
+		Triggered: record.GetBool("triggered"),
+		Created:   record.GetDateTime("created").Time(),
+		Updated:   record.GetDateTime("updated").Time(),
+	}
+}
+
+// v1ListAlerts handles GET /api/v1/alerts?system=. Returns the user's alerts,
+// optionally of one system.
+func (h *Hub) v1ListAlerts(e *core.RequestEvent) error {
+	filter := dbx.HashExp{"user": e.Auth.Id}
+	if systemId := e.Request.URL.Query().Get("system"); systemId != "" {
+		filter["system"] = systemId
+	}
+	records, err := e.App.FindAllRecords("alerts", filter)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	alerts := make([]APIAlert, len(records))
+	for i, record := range records {
+		alerts[i] = newAPIAlert(record)
+	}
+	slices.SortFunc(alerts, func(a, b APIAlert) int {
+		return strings.Compare(a.System+a.Name, b.System+b.Name)
+	})
+	return e.JSON(http.StatusOK, alerts)
+}
+
+// v1UpsertAlert handles POST /api/v1/alerts. Creates the user's alert of a
+// system, or updates it if it exists.
+func (h *Hub) v1UpsertAlert(e *core.RequestEvent) error {
+	var data struct {
+		System string  `json:"system"`
+		Name   string  `json:"name"`
+		Value  float64 `json:"value"`
+		Min    int     `json:"min"`
+		Target string  `json:"target"`
+	}
+	if err := e.BindBody(&data); err != nil || data.System == "" || data.Name == "" {
+		return e.BadRequestError("System and name are required", err)
+	}
+	if _, err := findAPISystem(e, data.System); err != nil {
+		return err
+	}
+	record, err := e.App.FindFirstRecordByFilter("alerts", "user = {:user} && system = {:system} && name = {:name}",
+		dbx.Params{"user": e.Auth.Id, "system": data.System, "name": data.Name})
+	status := http.StatusOK
+	if errors.Is(err, sql.ErrNoRows) {
+		collection, err := e.App.FindCachedCollectionByNameOrId("alerts")
+		if err != nil {
+			return e.InternalServerError("", err)
+		}
+		record = core.NewRecord(collection)
+		record.Set("user", e.Auth.Id)
+		record.Set("system", data.System)
+		record.Set("name", data.Name)
+		status = http.StatusCreated
+	} else if err != nil {
+		return e.InternalServerError("", err)
+	}
+	record.Set("value", data.Value)
+	record.Set("min", data.Min)
+	record.Set("target", strings.TrimSpace(data.Target))
+	if err := e.App.Save(record); err != nil {
+		return e.BadRequestError("Invalid alert", err)
+	}
+	return e.JSON(status, newAPIAlert(record))
+}
+
+// v1DeleteAlert handles DELETE /api/v1/alerts/{id}
+func (h *Hub) v1DeleteAlert(e *core.RequestEvent) error {
+	record, err := e.App.FindRecordById("alerts", e.Request.PathValue("id"))
+	if err != nil || record.GetString("user") != e.Auth.Id {
+		return e.NotFoundError("Alert not found", err)
+	}
+	if err := e.App.Delete(record); err != nil {
+		return e.InternalServerError("", err)
+	}
+	return e.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,308 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiV1(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "api@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+
+	createKey := func(token string, scopes []string, expires time.Time) *core.Record {
+		record, err := beszelTests.CreateRecord(hub, "api_keys", map[string]any{
+			"user":    user.Id,
+			"name":    "test",
+			"token":   token,
+			"scopes":  scopes,
+			"expires": expires,
+		})
+		require.NoError(t, err)
+		return record
+	}
+	fullKey := createKey(strings.Repeat("a", 40), []string{"systems:read", "stats:read", "alerts:read", "alerts:write"}, time.Time{})
+	readKey := createKey(strings.Repeat("b", 40), []string{"systems:read", "alerts:read"}, time.Now().Add(time.Hour))
+	expiredKey := createKey(strings.Repeat("c", 40), []string{"systems:read"}, time.Now().Add(-time.Hour))
+
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":   "web1",
+		"host":   "10.0.0.1",
+		"users":  []string{user.Id},
+		"labels": map[string]string{"env": "prod"},
+		"info":   map[string]any{"h": "web1.lan", "c": 4},
+	})
+	require.NoError(t, err)
+	hiddenRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "hidden",
+		"host":  "10.0.0.2",
+		"users": []string{otherUser.Id},
+	})
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	for i := range 3 {
+		record, err := beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": systemRecord.Id,
+			"type":   "1m",
+			"stats":  `{"cpu": 25, "t": {"cpu": 51.5}, "gs": {"humidity": {"v": 45, "u": "%"}}}`,
+		})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-time.Duration(i*10+5)*time.Minute).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+	cpuAlert, err := beszelTests.CreateRecord(hub, "alerts", map[string]any{
+		"user":   user.Id,
+		"system": systemRecord.Id,
+		"name":   "CPU",
+		"value":  80,
+	})
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *tests.TestApp {
+		return hub.TestApp
+	}
+	keyHeader := func(key *core.Record) map[string]string {
+		return map[string]string{"X-API-Key": key.GetString("token")}
+	}
+	statsRange := "from=" + url.QueryEscape(now.Add(-time.Hour).Format(time.RFC3339)) + "&to=" + url.QueryEscape(now.Format(time.RFC3339))
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /v1/systems - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/systems",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires a valid API key"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /v1/systems - unknown key should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/systems",
+			Headers:         map[string]string{"X-API-Key": strings.Repeat("x", 40)},
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"Invalid or expired API key"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /v1/systems - expired key should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/systems",
+			Headers:         keyHeader(expiredKey),
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"Invalid or expired API key"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:    "GET /v1/systems - lists the key user's systems",
+			Method:  http.MethodGet,
+			URL:     "/api/v1/systems",
+			Headers: keyHeader(readKey),
+			ExpectedContent: []string{
+				`"id":"` + systemRecord.Id + `"`, `"name":"web1"`, `"host":"10.0.0.1"`, `"labels":{"env":"prod"}`, `"h":"web1.lan"`,
+			},
+			NotExpectedContent: []string{"hidden"},
+			ExpectedStatus:     200,
+			TestAppFactory:     testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				key, err := app.FindRecordById("api_keys", readKey.Id)
+				require.NoError(t, err)
+				assert.False(t, key.GetDateTime("last_used").IsZero())
+			},
+		},
+		{
+			Name:            "GET /v1/systems - bearer key",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/systems/" + systemRecord.Id,
+			Headers:         map[string]string{"Authorization": "Bearer " + fullKey.GetString("token")},
+			ExpectedContent: []string{`"name":"web1"`},
+			ExpectedStatus:  200,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /v1/systems/{id} - other user's system",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/systems/" + hiddenRecord.Id,
+			Headers:         keyHeader(fullKey),
+			ExpectedContent: []string{"System not found"},
+			ExpectedStatus:  404,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /v1/systems/{id}/stats - key without scope",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/systems/" + systemRecord.Id + "/stats",
+			Headers:         keyHeader(readKey),
+			ExpectedContent: []string{"stats:read scope"},
+			ExpectedStatus:  403,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:    "GET /v1/systems/{id}/stats - records in the range",
+			Method:  http.MethodGet,
+			URL:     "/api/v1/systems/" + systemRecord.Id + "/stats?" + statsRange,
+			Headers: keyHeader(fullKey),
+			ExpectedContent: []string{
+				`"resolution":"1m"`, `"records":[{"created":`, `"cpu":25`, `"t":{"cpu":51.5}`,
+			},
+			ExpectedStatus: 200,
+			TestAppFactory: testAppFactory,
+		},
+		{
+			Name:            "GET /v1/systems/{id}/stats - auth tokens have all scopes",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/systems/" + systemRecord.Id + "/stats?resolution=10m",
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedContent: []string{`"resolution":"10m"`, `"records":[]`},
+			ExpectedStatus:  200,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /v1/systems/{id}/stats - few points use longer records",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/systems/" + systemRecord.Id + "/stats?points=5&" + statsRange,
+			Headers:         keyHeader(fullKey),
+			ExpectedContent: []string{`"resolution":"20m"`},
+			ExpectedStatus:  200,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /v1/systems/{id}/stats - invalid resolution",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/systems/" + systemRecord.Id + "/stats?resolution=5m",
+			Headers:         keyHeader(fullKey),
+			ExpectedContent: []string{"Invalid resolution"},
+			ExpectedStatus:  400,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:    "GET /v1/systems/{id}/sensors - temperatures and generic sensors",
+			Method:  http.MethodGet,
+			URL:     "/api/v1/systems/" + systemRecord.Id + "/sensors?" + statsRange,
+			Headers: keyHeader(fullKey),
+			ExpectedContent: []string{
+				`"temperatures":{"cpu":51.5}`, `"sensors":{"humidity":{"value":45,"unit":"%"}}`,
+			},
+			ExpectedStatus: 200,
+			TestAppFactory: testAppFactory,
+		},
+		{
+			Name:            "GET /v1/alerts - lists the user's alerts",
+			Method:          http.MethodGet,
+			URL:             "/api/v1/alerts?system=" + systemRecord.Id,
+			Headers:         keyHeader(readKey),
+			ExpectedContent: []string{`"id":"` + cpuAlert.Id + `"`, `"name":"CPU"`, `"value":80`},
+			ExpectedStatus:  200,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "POST /v1/alerts - key without scope",
+			Method:          http.MethodPost,
+			URL:             "/api/v1/alerts",
+			Headers:         keyHeader(readKey),
+			Body:            strings.NewReader(`{"system": "` + systemRecord.Id + `", "name": "Memory", "value": 90}`),
+			ExpectedContent: []string{"alerts:write scope"},
+			ExpectedStatus:  403,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "POST /v1/alerts - creates an alert",
+			Method:          http.MethodPost,
+			URL:             "/api/v1/alerts",
+			Headers:         keyHeader(fullKey),
+			Body:            strings.NewReader(`{"system": "` + systemRecord.Id + `", "name": "Memory", "value": 90, "min": 5}`),
+			ExpectedContent: []string{`"name":"Memory"`, `"value":90`, `"min":5`},
+			ExpectedStatus:  201,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "POST /v1/alerts - updates an existing alert",
+			Method:          http.MethodPost,
+			URL:             "/api/v1/alerts",
+			Headers:         keyHeader(fullKey),
+			Body:            strings.NewReader(`{"system": "` + systemRecord.Id + `", "name": "CPU", "value": 95}`),
+			ExpectedContent: []string{`"id":"` + cpuAlert.Id + `"`, `"value":95`},
+			ExpectedStatus:  200,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "POST /v1/alerts - invalid name",
+			Method:          http.MethodPost,
+			URL:             "/api/v1/alerts",
+			Headers:         keyHeader(fullKey),
+			Body:            strings.NewReader(`{"system": "` + systemRecord.Id + `", "name": "Nope", "value": 1}`),
+			ExpectedContent: []string{"Invalid alert"},
+			ExpectedStatus:  400,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "POST /v1/alerts - other user's system",
+			Method:          http.MethodPost,
+			URL:             "/api/v1/alerts",
+			Headers:         keyHeader(fullKey),
+			Body:            strings.NewReader(`{"system": "` + hiddenRecord.Id + `", "name": "CPU", "value": 1}`),
+			ExpectedContent: []string{"System not found"},
+			ExpectedStatus:  404,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:           "DELETE /v1/alerts/{id} - deletes the alert",
+			Method:         http.MethodDelete,
+			URL:            "/api/v1/alerts/" + cpuAlert.Id,
+			Headers:        keyHeader(fullKey),
+			ExpectedStatus: 204,
+			TestAppFactory: testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				_, err := app.FindRecordById("alerts", cpuAlert.Id)
+				assert.Error(t, err)
+			},
+		},
+		{
+			Name:            "POST api_keys - invalid scope",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/api_keys/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            strings.NewReader(`{"user": "` + user.Id + `", "scopes": ["systems:write"]}`),
+			ExpectedContent: []string{"Invalid scope: systems:write"},
+			ExpectedStatus:  400,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:               "POST api_keys - generates the token",
+			Method:             http.MethodPost,
+			URL:                "/api/collections/api_keys/records",
+			Headers:            map[string]string{"Authorization": userToken},
+			Body:               strings.NewReader(`{"user": "` + user.Id + `", "scopes": ["stats:read"], "token": "` + strings.Repeat("d", 40) + `"}`),
+			ExpectedContent:    []string{`"scopes":["stats:read"]`, `"token":"`},
+			NotExpectedContent: []string{strings.Repeat("d", 40)},
+			ExpectedStatus:     200,
+			TestAppFactory:     testAppFactory,
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
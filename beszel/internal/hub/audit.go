@@ -0,0 +1,290 @@
+package hub
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	// days audit log entries are kept if AUDIT_LOG_DAYS is not set
+	defaultAuditLogDays = 365
+	// most entries returned by the audit log API
+	maxAuditLogEntries = 10000
+)
+
+// AuditEntry is an entry of the audit log
+type AuditEntry struct {
+	Id         string         `db:"id" json:"id"`
+	Actor      string         `db:"actor" json:"actor"`
+	Email      string         `db:"email" json:"email"`
+	Action     string         `db:"action" json:"action"`
+	Collection string         `db:"collection" json:"collection"`
+	Record     string         `db:"record" json:"record"`
+	Name       string         `db:"name" json:"name"`
+	Details    types.JSONRaw  `db:"details" json:"details"`
+	IP         string         `db:"ip" json:"ip"`
+	Created    types.DateTime `db:"created" json:"created"`
+}
+
+// audit adds an entry to the audit log. The actor is the user or superuser
+// who made the request, and is nil for anonymous requests.
+func audit(e *core.RequestEvent, actor *core.Record, action string, record *core.Record, details map[string]any) {
+	saveAuditEntry(e.App, actor, action, record, details, e.RealIP())
+}
+
+// saveAuditEntry adds an entry to the audit log for changes made outside of
+// API requests, like systems added by agents
+func saveAuditEntry(app core.App, actor *core.Record, action string, record *core.Record, details map[string]any, ip string) {
+	collection, err := app.FindCachedCollectionByNameOrId("audit_log")
+	if err != nil {
+		return
+	}
+	entry := core.NewRecord(collection)
+	entry.Set("action", action)
+	if actor != nil {
+		entry.Set("actor", actor.Id)
+		entry.Set("email", actor.Email())
+	}
+	if record != nil {
+		entry.Set("collection", record.Collection().Name)
+		entry.Set("record", record.Id)
+		entry.Set("name", auditName(record))
+	}
+	if len(details) > 0 {
+		entry.Set("details", details)
+	}
+	entry.Set("ip", ip)
+	if err := app.Save(entry); err != nil {
+		app.Logger().Error("Failed to save audit log entry", "action", action, "err", err)
+	}
+}
+
+// auditName returns a name of a record to show in the audit log
+func auditName(record *core.Record) string {
+	for _, field := range []string{"name", "email", "slug"} {
+		if record.Collection().Fields.GetByName(field) != nil {
+			if name := record.GetString(field); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// auditRecordRequest returns a hook that adds successful create, update and
+// delete requests of the collections API to the audit log
+func auditRecordRequest(action string) func(e *core.RecordRequestEvent) error {
+	return func(e *core.RecordRequestEvent) error {
+		if e.Collection.Name == "audit_log" {
+			return e.Next()
+		}
+		var details map[string]any
+		if action == "update" {
+			if changes := auditChanges(e.Record); len(changes) > 0 {
+				details = map[string]any{"changes": changes}
+			}
+		}
+		if err := e.Next(); err != nil {
+			return err
+		}
+		audit(e.RequestEvent, e.Auth, e.Collection.Name+"."+action, e.Record, details)
+		return nil
+	}
+}
+
+// auditChanges returns the old and new values of the changed fields of a
+// record. Values of secrets, like tokens and passwords, and of large fields
+// are left out.
+func auditChanges(record *core.Record) map[string]any {
+	original := record.Original()
+	changes := map[string]any{}
+	for _, field := range record.Collection().Fields {
+		name := field.GetName()
+		oldValue, newValue := original.Get(name), record.Get(name)
+		switch field.(type) {
+		case *core.AutodateField:
+			continue
+		}
+		if oldJSON, newJSON := auditJSON(oldValue), auditJSON(newValue); oldJSON == newJSON {
+			continue
+		}
+		if field.GetHidden() || isSecretField(name) {
+			changes[name] = nil
+			continue
+		}
+		switch field.(type) {
+		case *core.JSONField, *core.FileField, *core.EditorField:
+			changes[name] = nil
+		default:
+			changes[name] = [2]any{oldValue, newValue}
+		}
+	}
+	return changes
+}
+
+// auditJSON returns the JSON of a value to compare old and new values
+func auditJSON(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// isSecretField returns whether a field holds a secret, like a token
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range []string{"token", "secret", "password", "key", "fingerprint"} {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditLogin adds logins to the audit log. Auth refreshes aren't logged.
+func auditLogin(e *core.RecordAuthRequestEvent) error {
+	if err := e.Next(); err != nil {
+		return err
+	}
+	if e.AuthMethod != "" {
+		audit(e.RequestEvent, e.Record, e.Collection.Name+".login", e.Record, map[string]any{"method": e.AuthMethod})
+	}
+	return nil
+}
+
+// auditFailedLogin adds failed password logins to the audit log
+func auditFailedLogin(e *core.RecordAuthWithPasswordRequestEvent) error {
+	err := e.Next()
+	if err != nil {
+		audit(e.RequestEvent, nil, e.Collection.Name+".login_failed", nil, map[string]any{"identity": e.Identity})
+	}
+	return err
+}
+
+// auditRoute returns a middleware that adds successful requests of a route
+// to the audit log
+func auditRoute(action string) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		if e.Status() < http.StatusBadRequest {
+			audit(e, e.Auth, action, nil, map[string]any{"method": e.Request.Method, "path": e.Request.URL.Path})
+		}
+		return nil
+	}
+}
+
+// getAuditLog handles GET /api/beszel/audit-log, which returns the audit log
+// entries between from and to, newest first, as JSON or as a CSV file with
+// format=csv. Entries can be filtered by actor id or email and by action,
+// e.g. ?action=systems.create. Only admins can read the audit log.
+func getAuditLog(e *core.RequestEvent) error {
+	if !e.HasSuperuserAuth() && e.Auth.GetString("role") != "admin" {
+		return e.ForbiddenError("Only admins can read the audit log", nil)
+	}
+	query := e.Request.URL.Query()
+	to := time.Now().UTC()
+	if value := query.Get("to"); value != "" {
+		parsed, err := types.ParseDateTime(value)
+		if err != nil {
+			return e.BadRequestError("Invalid to", err)
+		}
+		to = parsed.Time()
+	}
+	from := to.Add(-30 * 24 * time.Hour)
+	if value := query.Get("from"); value != "" {
+		parsed, err := types.ParseDateTime(value)
+		if err != nil {
+			return e.BadRequestError("Invalid from", err)
+		}
+		from = parsed.Time()
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		return e.BadRequestError("Invalid format, expected json or csv", nil)
+	}
+
+	q := e.App.DB().Select("id", "actor", "email", "action", "collection", "record", "name", "details", "ip", "created").
+		From("audit_log").
+		Where(dbx.Between("created", from.Format(types.DefaultDateLayout), to.Format(types.DefaultDateLayout))).
+		OrderBy("created DESC", "rowid DESC").
+		Limit(maxAuditLogEntries)
+	if actor := query.Get("actor"); actor != "" {
+		q.AndWhere(dbx.Or(dbx.HashExp{"actor": actor}, dbx.HashExp{"email": actor}))
+	}
+	if action := query.Get("action"); action != "" {
+		q.AndWhere(dbx.HashExp{"action": action})
+	}
+	entries := []AuditEntry{}
+	if err := q.All(&entries); err != nil {
+		return e.InternalServerError("", err)
+	}
+
+	if format == "json" {
+		return e.JSON(http.StatusOK, entries)
+	}
+	data, err := auditCSV(entries)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	e.Response.Header().Set("Content-Disposition", `attachment; filename="audit_log_`+to.Format("20060102-1504")+`.csv"`)
+	return e.Blob(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+// auditCSV returns a CSV table with a row per audit log entry
+func auditCSV(entries []AuditEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{"time", "actor", "email", "action", "collection", "record", "name", "details", "ip"}); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		details := string(entry.Details)
+		if details == "null" {
+			details = ""
+		}
+		row := []string{
+			entry.Created.Time().Format(time.RFC3339),
+			entry.Actor, entry.Email, entry.Action, entry.Collection, entry.Record, entry.Name, details, entry.IP,
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// deleteOldAuditLog deletes audit log entries older than AUDIT_LOG_DAYS,
+// 365 days by default
+func (h *Hub) deleteOldAuditLog() error {
+	days := defaultAuditLogDays
+	if value, _ := GetEnv("AUDIT_LOG_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+	before := time.Now().UTC().AddDate(0, 0, -days)
+	_, err := h.DB().Delete("audit_log", dbx.NewExp("created < {:before}", dbx.Params{"before": before.Format(types.DefaultDateLayout)})).Execute()
+	return err
+}
+
+// bindAuditLog adds changes of records, logins and failed logins to the audit log
+func (h *Hub) bindAuditLog() {
+	h.App.OnRecordCreateRequest().BindFunc(auditRecordRequest("create"))
+	h.App.OnRecordUpdateRequest().BindFunc(auditRecordRequest("update"))
+	h.App.OnRecordDeleteRequest().BindFunc(auditRecordRequest("delete"))
+	h.App.OnRecordAuthRequest().BindFunc(auditLogin)
+	h.App.OnRecordAuthWithPasswordRequest().BindFunc(auditFailedLogin)
+}
@@ -0,0 +1,270 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	createUser := func(email, role string) (*core.Record, string) {
+		user, err := beszelTests.CreateUser(hub, email, "password123")
+		require.NoError(t, err)
+		user.Set("role", role)
+		user.SetVerified(true)
+		require.NoError(t, hub.Save(user))
+		token, err := user.NewAuthToken()
+		require.NoError(t, err)
+		return user, token
+	}
+	admin, adminToken := createUser("admin@example.com", "admin")
+	user, userToken := createUser("user@example.com", "user")
+
+	superusers, err := hub.FindCollectionByNameOrId(core.CollectionNameSuperusers)
+	require.NoError(t, err)
+	superuser := core.NewRecord(superusers)
+	superuser.SetEmail("superuser@example.com")
+	superuser.SetPassword("password123")
+	require.NoError(t, hub.Save(superuser))
+	superuserToken, err := superuser.NewAuthToken()
+	require.NoError(t, err)
+
+	system, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "web",
+		"host":  "10.0.0.1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+	apiKey, err := beszelTests.CreateRecord(hub, "api_keys", map[string]any{
+		"user":   user.Id,
+		"name":   "grafana",
+		"token":  strings.Repeat("a", 40),
+		"scopes": []string{"systems:read"},
+	})
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *tests.TestApp {
+		return hub.TestApp
+	}
+	// lastEntry returns the newest audit log entry of an action
+	lastEntry := func(t testing.TB, app core.App, action string) *core.Record {
+		entry := &core.Record{}
+		err := app.RecordQuery("audit_log").AndWhere(dbx.HashExp{"action": action}).OrderBy("rowid DESC").Limit(1).One(entry)
+		require.NoError(t, err, "should have a %s entry", action)
+		return entry
+	}
+	countEntries := func(t testing.TB, app core.App, action string) int64 {
+		count, err := app.CountRecords("audit_log", dbx.HashExp{"action": action})
+		require.NoError(t, err)
+		return count
+	}
+	jsonBody := func(data any) *strings.Reader {
+		body, _ := json.Marshal(data)
+		return strings.NewReader(string(body))
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "adds created records",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/systems/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonBody(map[string]any{"name": "db", "host": "10.0.0.2", "users": []string{user.Id}}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"name":"db"`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				entry := lastEntry(t, app, "systems.create")
+				assert.Equal(t, user.Id, entry.GetString("actor"))
+				assert.Equal(t, "user@example.com", entry.GetString("email"))
+				assert.Equal(t, "systems", entry.GetString("collection"))
+				assert.Equal(t, "db", entry.GetString("name"))
+				assert.NotEmpty(t, entry.GetString("record"))
+			},
+		},
+		{
+			Name:            "adds the changes of updated records",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/systems/records/" + system.Id,
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonBody(map[string]any{"name": "web-1"}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"name":"web-1"`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				entry := lastEntry(t, app, "systems.update")
+				assert.Equal(t, system.Id, entry.GetString("record"))
+				assert.JSONEq(t, `{"changes":{"name":["web","web-1"]}}`, entry.GetString("details"))
+			},
+		},
+		{
+			Name:            "leaves out the values of secrets",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/api_keys/records/" + apiKey.Id,
+			Headers:         map[string]string{"Authorization": superuserToken},
+			Body:            jsonBody(map[string]any{"name": "prometheus", "token": strings.Repeat("b", 40)}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"name":"prometheus"`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				entry := lastEntry(t, app, "api_keys.update")
+				assert.Equal(t, superuser.Id, entry.GetString("actor"))
+				assert.JSONEq(t, `{"changes":{"name":["grafana","prometheus"],"token":null}}`, entry.GetString("details"))
+				assert.NotContains(t, entry.GetString("details"), strings.Repeat("b", 40))
+			},
+		},
+		{
+			Name:            "adds logins",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/users/auth-with-password",
+			Body:            jsonBody(map[string]any{"identity": "user@example.com", "password": "password123"}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"token":`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				entry := lastEntry(t, app, "users.login")
+				assert.Equal(t, user.Id, entry.GetString("actor"))
+				assert.JSONEq(t, `{"method":"password"}`, entry.GetString("details"))
+			},
+		},
+		{
+			Name:            "doesn't add auth refreshes",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/users/auth-refresh",
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"token":`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				assert.EqualValues(t, 1, countEntries(t, app, "users.login"))
+			},
+		},
+		{
+			Name:            "adds failed logins",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/users/auth-with-password",
+			Body:            jsonBody(map[string]any{"identity": "user@example.com", "password": "wrong"}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{`"message":`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				entry := lastEntry(t, app, "users.login_failed")
+				assert.Empty(t, entry.GetString("actor"))
+				assert.JSONEq(t, `{"identity":"user@example.com"}`, entry.GetString("details"))
+			},
+		},
+		{
+			Name:            "adds successful requests of audited routes",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-history/ack",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonBody(map[string]any{"ids": []string{"missing"}}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"acknowledged":0`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				entry := lastEntry(t, app, "alerts.acknowledge")
+				assert.Equal(t, user.Id, entry.GetString("actor"))
+				assert.JSONEq(t, `{"method":"POST","path":"/api/beszel/alert-history/ack"}`, entry.GetString("details"))
+			},
+		},
+		{
+			Name:            "doesn't add failed requests of audited routes",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-history/ack",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            jsonBody(map[string]any{"ids": []string{}}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{`"message":"Bad data."`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				assert.EqualValues(t, 1, countEntries(t, app, "alerts.acknowledge"))
+			},
+		},
+		{
+			Name:            "adds enabled universal tokens",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/universal-token?enable=1",
+			Headers:         map[string]string{"Authorization": adminToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"active":true`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				assert.Equal(t, admin.Id, lastEntry(t, app, "universal_token.enable").GetString("actor"))
+			},
+		},
+		{
+			Name:            "users can't read the audit log",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/audit-log",
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedStatus:  403,
+			ExpectedContent: []string{`"message":"Only admins can read the audit log."`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "users can't list the audit log collection",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/audit_log/records",
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":0`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "admins read the audit log filtered by actor and action",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/audit-log?actor=user@example.com&action=systems.update",
+			Headers:         map[string]string{"Authorization": adminToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"action":"systems.update"`, `"email":"user@example.com"`, `"name":"web-1"`},
+			NotExpectedContent: []string{
+				`"action":"systems.create"`,
+				`"action":"users.login"`,
+			},
+			TestAppFactory: testAppFactory,
+		},
+		{
+			Name:            "admins export the audit log as CSV",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/audit-log?format=csv&action=users.login_failed",
+			Headers:         map[string]string{"Authorization": adminToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{"time,actor,email,action,collection,record,name,details,ip\n", `,,,users.login_failed,,,,"{""identity"":""user@example.com""}",`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				assert.Equal(t, "text/csv; charset=utf-8", res.Header.Get("Content-Type"))
+				assert.Contains(t, res.Header.Get("Content-Disposition"), `filename="audit_log_`)
+			},
+		},
+		{
+			Name:            "rejects invalid formats",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/audit-log?format=xml",
+			Headers:         map[string]string{"Authorization": adminToken},
+			ExpectedStatus:  400,
+			ExpectedContent: []string{`"message":"Invalid format, expected json or csv."`},
+			TestAppFactory:  testAppFactory,
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -1,21 +1,27 @@
-// Package config provides functions for syncing systems with the config.yml file
+// Package config provides functions for syncing systems, users and alerts with
+// the config.yml file
 package config
 
 import (
 	"beszel/internal/entities/system"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/google/uuid"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
 	"github.com/spf13/cast"
 	"gopkg.in/yaml.v3"
 )
 
 type config struct {
+	Users   []userConfig   `yaml:"users,omitempty"`
 	Systems []systemConfig `yaml:"systems"`
 }
 
@@ -25,9 +31,38 @@ type systemConfig struct {
 	Port  uint16   `yaml:"port,omitempty"`
 	Token string   `yaml:"token,omitempty"`
 	Users []string `yaml:"users"`
+	// Alerts of the system's users. The system's alerts are managed by the
+	// config only if the key is set, so `alerts: []` deletes them.
+	Alerts []alertConfig `yaml:"alerts,omitempty"`
 }
 
-// Syncs systems with the config.yml file
+// userConfig is a user that is created if it doesn't exist. Users not in the
+// config are kept.
+type userConfig struct {
+	Email string `yaml:"email"`
+	Role  string `yaml:"role,omitempty"` // user, admin or readonly
+	// Password of new users, with environment variables expanded. Users without
+	// one get a random password and can sign in after resetting it.
+	Password string `yaml:"password,omitempty"`
+	// Notification channels that replace the user's emails and webhooks, if set
+	Notifications *notificationsConfig `yaml:"notifications,omitempty"`
+}
+
+type notificationsConfig struct {
+	Emails   []string `yaml:"emails"`
+	Webhooks []string `yaml:"webhooks"`
+}
+
+type alertConfig struct {
+	Name   string  `yaml:"name"`
+	Value  float64 `yaml:"value"`
+	Min    uint8   `yaml:"min,omitempty"` // minutes, up to 60
+	Target string  `yaml:"target,omitempty"`
+}
+
+var userRoles = []string{"user", "admin", "readonly"}
+
+// Syncs users, systems and alerts with the config.yml file
 func SyncSystems(e *core.ServeEvent) error {
 	h := e.App
 	configPath := filepath.Join(h.DataDir(), "config.yml")
@@ -42,6 +77,12 @@ func SyncSystems(e *core.ServeEvent) error {
 		return fmt.Errorf("failed to parse config.yml: %v", err)
 	}
 
+	if len(config.Users) > 0 {
+		if err := syncUsers(h, config.Users); err != nil {
+			return err
+		}
+	}
+
 	if len(config.Systems) == 0 {
 		log.Println("No systems defined in config.yml.")
 		return nil
@@ -109,6 +150,11 @@ func SyncSystems(e *core.ServeEvent) error {
 			if err := h.Save(existingSystem); err != nil {
 				return err
 			}
+			if sysConfig.Alerts != nil {
+				if err := syncAlerts(h, existingSystem, sysConfig.Alerts); err != nil {
+					return err
+				}
+			}
 
 			// Only update token if one is specified in config, otherwise preserve existing token
 			if sysConfig.Token != "" {
@@ -145,6 +191,11 @@ func SyncSystems(e *core.ServeEvent) error {
 			if err := createFingerprintRecord(h, newSystem.Id, token); err != nil {
 				return err
 			}
+			if sysConfig.Alerts != nil {
+				if err := syncAlerts(h, newSystem, sysConfig.Alerts); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
@@ -159,6 +210,132 @@ func SyncSystems(e *core.ServeEvent) error {
 	return nil
 }
 
+// syncUsers creates the users of the config that don't exist, and updates the
+// role and notification channels of users that do
+func syncUsers(app core.App, users []userConfig) error {
+	usersCollection, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		return fmt.Errorf("failed to find users collection: %v", err)
+	}
+	for _, userConfig := range users {
+		if userConfig.Email == "" {
+			return errors.New("user in config.yml without email")
+		}
+		if userConfig.Role != "" && !slices.Contains(userRoles, userConfig.Role) {
+			return fmt.Errorf("invalid role %q of user %s in config.yml", userConfig.Role, userConfig.Email)
+		}
+		user, err := app.FindAuthRecordByEmail(usersCollection, userConfig.Email)
+		if errors.Is(err, sql.ErrNoRows) {
+			user = core.NewRecord(usersCollection)
+			user.Set("email", userConfig.Email)
+			user.Set("verified", true)
+			password := os.ExpandEnv(userConfig.Password)
+			if password == "" {
+				password = security.RandomString(32)
+			}
+			user.Set("password", password)
+		} else if err != nil {
+			return err
+		}
+		if userConfig.Role != "" {
+			user.Set("role", userConfig.Role)
+		}
+		if err := app.Save(user); err != nil {
+			return fmt.Errorf("failed to save user %s: %v", userConfig.Email, err)
+		}
+		if userConfig.Notifications != nil {
+			if err := setNotifications(app, user.Id, userConfig.Notifications); err != nil {
+				return err
+			}
+		}
+	}
+	log.Println("Users synced with config.yml")
+	return nil
+}
+
+// setNotifications replaces the emails and webhooks in the user's settings,
+// creating the settings record if needed. Other settings are kept.
+func setNotifications(app core.App, userID string, notifications *notificationsConfig) error {
+	record, err := app.FindFirstRecordByFilter("user_settings", "user={:user}", dbx.Params{"user": userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		collection, err := app.FindCollectionByNameOrId("user_settings")
+		if err != nil {
+			return err
+		}
+		record = core.NewRecord(collection)
+		record.Set("user", userID)
+	} else if err != nil {
+		return err
+	}
+	settings := map[string]any{}
+	record.UnmarshalJSONField("settings", &settings)
+	settings["emails"] = nonNil(notifications.Emails)
+	settings["webhooks"] = nonNil(notifications.Webhooks)
+	record.Set("settings", settings)
+	return app.Save(record)
+}
+
+// syncAlerts makes the alerts of the system match the config. Each user of
+// the system gets every alert, and other alerts of the system are deleted.
+func syncAlerts(app core.App, systemRecord *core.Record, alerts []alertConfig) error {
+	alertsCollection, err := app.FindCollectionByNameOrId("alerts")
+	if err != nil {
+		return fmt.Errorf("failed to find alerts collection: %v", err)
+	}
+	existing, err := app.FindAllRecords(alertsCollection, dbx.HashExp{"system": systemRecord.Id})
+	if err != nil {
+		return err
+	}
+	// existing alerts by user and name
+	existingMap := make(map[string]*core.Record, len(existing))
+	for _, alert := range existing {
+		existingMap[alert.GetString("user")+alert.GetString("name")] = alert
+	}
+	systemName := systemRecord.GetString("name")
+	for _, alertConfig := range alerts {
+		if alertConfig.Name == "" {
+			return fmt.Errorf("alert of system %s in config.yml without name", systemName)
+		}
+		if alertConfig.Min > 60 {
+			return fmt.Errorf("min of alert %s of system %s in config.yml is over 60", alertConfig.Name, systemName)
+		}
+	}
+	for _, userID := range systemRecord.GetStringSlice("users") {
+		for _, alertConfig := range alerts {
+			key := userID + alertConfig.Name
+			alert, ok := existingMap[key]
+			if ok {
+				delete(existingMap, key)
+			} else {
+				alert = core.NewRecord(alertsCollection)
+				alert.Set("user", userID)
+				alert.Set("system", systemRecord.Id)
+				alert.Set("name", alertConfig.Name)
+			}
+			alert.Set("value", alertConfig.Value)
+			alert.Set("min", alertConfig.Min)
+			alert.Set("target", alertConfig.Target)
+			if err := app.Save(alert); err != nil {
+				return fmt.Errorf("invalid alert %s of system %s in config.yml: %w", alertConfig.Name, systemName, err)
+			}
+		}
+	}
+	for _, alert := range existingMap {
+		if err := app.Delete(alert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nonNil returns an empty slice instead of nil, so it's saved as []
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
 // Generates content for the config.yml file as a YAML string
 func generateYAML(h core.App) (string, error) {
 	// Fetch all systems from the database
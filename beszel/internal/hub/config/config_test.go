@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -243,3 +244,114 @@ func TestConfigMigrationScenario(t *testing.T) {
 	assert.Equal(t, "migrated-server", updatedSystem.GetString("name"))
 	assert.Equal(t, "migrated.example.com", updatedSystem.GetString("host"))
 }
+
+// TestConfigSyncUsersAndAlerts tests that users and alerts are reconciled with the config
+func TestConfigSyncUsersAndAlerts(t *testing.T) {
+	testHub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer testHub.Cleanup()
+
+	existingUser, err := tests.CreateUser(testHub.App, "existing@example.com", "testtesttest")
+	require.NoError(t, err)
+	keptUser, err := tests.CreateUser(testHub.App, "kept@example.com", "testtesttest")
+	require.NoError(t, err)
+	system, err := tests.CreateRecord(testHub.App, "systems", map[string]any{
+		"name":  "web1",
+		"host":  "web1.example.com",
+		"port":  45876,
+		"users": []string{existingUser.Id},
+	})
+	require.NoError(t, err)
+	staleAlert, err := tests.CreateRecord(testHub.App, "alerts", map[string]any{
+		"user":   existingUser.Id,
+		"system": system.Id,
+		"name":   "Memory",
+		"value":  90,
+	})
+	require.NoError(t, err)
+
+	t.Setenv("NEW_USER_PASSWORD", "newpassword123")
+	configYAML := `users:
+  - email: existing@example.com
+    role: readonly
+    notifications:
+      emails: [ops@example.com]
+      webhooks: ["ntfy://ntfy.sh/beszel"]
+  - email: new@example.com
+    role: admin
+    password: ${NEW_USER_PASSWORD}
+systems:
+  - name: web1
+    host: web1.example.com
+    users: [existing@example.com, new@example.com]
+    alerts:
+      - name: CPU
+        value: 80
+        min: 5
+  - name: web2
+    host: web2.example.com
+    users: [new@example.com]`
+
+	configPath := filepath.Join(testHub.DataDir(), "config.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+	require.NoError(t, config.SyncSystems(&core.ServeEvent{App: testHub.App}))
+
+	// existing users are updated, new users are created, others are kept
+	existingUser, err = testHub.FindRecordById("users", existingUser.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "readonly", existingUser.GetString("role"))
+	assert.True(t, existingUser.ValidatePassword("testtesttest"), "password of existing users should be kept")
+	newUser, err := testHub.FindAuthRecordByEmail("users", "new@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", newUser.GetString("role"))
+	assert.True(t, newUser.ValidatePassword("newpassword123"))
+	_, err = testHub.FindRecordById("users", keptUser.Id)
+	assert.NoError(t, err)
+
+	// notification channels are replaced
+	settings, err := testHub.FindFirstRecordByFilter("user_settings", "user={:user}", map[string]any{"user": existingUser.Id})
+	require.NoError(t, err)
+	var notifications struct {
+		Emails   []string `json:"emails"`
+		Webhooks []string `json:"webhooks"`
+	}
+	require.NoError(t, settings.UnmarshalJSONField("settings", &notifications))
+	assert.Equal(t, []string{"ops@example.com"}, notifications.Emails)
+	assert.Equal(t, []string{"ntfy://ntfy.sh/beszel"}, notifications.Webhooks)
+
+	// each user of web1 gets the alerts of the config, and other alerts are deleted
+	alerts, err := testHub.FindRecordsByFilter("alerts", "system={:system}", "", -1, 0, map[string]any{"system": system.Id})
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	for _, alert := range alerts {
+		assert.Equal(t, "CPU", alert.GetString("name"))
+		assert.Equal(t, 80.0, alert.GetFloat("value"))
+		assert.Equal(t, 5, alert.GetInt("min"))
+	}
+	_, err = testHub.FindRecordById("alerts", staleAlert.Id)
+	assert.Error(t, err)
+
+	// alerts of systems without an alerts key aren't managed
+	web2, err := testHub.FindFirstRecordByFilter("systems", "name='web2'")
+	require.NoError(t, err)
+	_, err = tests.CreateRecord(testHub.App, "alerts", map[string]any{
+		"user":   newUser.Id,
+		"system": web2.Id,
+		"name":   "Status",
+	})
+	require.NoError(t, err)
+	require.NoError(t, config.SyncSystems(&core.ServeEvent{App: testHub.App}))
+	count, err := testHub.CountRecords("alerts", dbx.HashExp{"system": web2.Id})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	// invalid roles fail
+	require.NoError(t, os.WriteFile(configPath, []byte("users:\n  - email: existing@example.com\n    role: owner\n"), 0644))
+	assert.ErrorContains(t, config.SyncSystems(&core.ServeEvent{App: testHub.App}), "invalid role")
+
+	// invalid alerts fail, also for systems without users
+	require.NoError(t, os.WriteFile(configPath, []byte("systems:\n  - name: web1\n    host: web1.example.com\n    users: [existing@example.com]\n    alerts:\n      - name: Swap\n        value: 1\n"), 0644))
+	assert.ErrorContains(t, config.SyncSystems(&core.ServeEvent{App: testHub.App}), "invalid alert Swap of system web1")
+	require.NoError(t, os.WriteFile(configPath, []byte("systems:\n  - name: web3\n    host: web3.example.com\n    alerts:\n      - name: CPU\n        min: 61\n"), 0644))
+	assert.ErrorContains(t, config.SyncSystems(&core.ServeEvent{App: testHub.App}), "min of alert CPU of system web3")
+}
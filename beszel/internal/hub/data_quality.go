@@ -0,0 +1,162 @@
+package hub
+
+import (
+	"beszel/internal/alerts"
+	"beszel/internal/records"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Period of records checked by each data quality check. Matches the retention of 1m records.
+const dataQualityWindow = time.Hour
+
+// dataQuality holds the result of the latest data quality check
+type dataQuality struct {
+	mu       sync.Mutex
+	checked  time.Time
+	reports  []records.DataQualityReport
+	notified map[string]string // system id -> problems its users were last notified of
+}
+
+// DataQualityResponse is the response of the data quality API
+type DataQualityResponse struct {
+	Checked time.Time                   `json:"checked"`
+	Systems []records.DataQualityReport `json:"systems"`
+}
+
+// scanDataQuality checks the last hour of records and keeps the result for the API.
+func (h *Hub) scanDataQuality() ([]records.DataQualityReport, error) {
+	now := time.Now().UTC()
+	reports, err := records.CheckDataQuality(h, h.storage, now.Add(-dataQualityWindow), now)
+	if err != nil {
+		return nil, err
+	}
+	h.dataQuality.mu.Lock()
+	h.dataQuality.checked, h.dataQuality.reports = now, reports
+	h.dataQuality.mu.Unlock()
+	return reports, nil
+}
+
+// checkDataQuality runs the hourly data quality check and notifies the users
+// of systems with problems if DATA_QUALITY_ALERTS is true. Users are notified
+// once per kind of problem until the system's records are fine again.
+func (h *Hub) checkDataQuality() error {
+	reports, err := h.scanDataQuality()
+	if err != nil {
+		return err
+	}
+	if notify, _ := GetEnv("DATA_QUALITY_ALERTS"); notify != "true" {
+		return nil
+	}
+	for _, report := range reports {
+		if !h.shouldNotifyDataQuality(&report) {
+			continue
+		}
+		systemRecord, err := h.FindRecordById("systems", report.System)
+		if err != nil {
+			continue
+		}
+		message := describeDataQuality(&report)
+		for _, userId := range alerts.SystemUserIDs(h, systemRecord) {
+			if err := h.SendAlert(alerts.AlertMessageData{
+				UserID:   userId,
+				Title:    fmt.Sprintf("Data quality problems on %s", report.Name),
+				Message:  message,
+				Link:     h.MakeLink("system", report.Name),
+				LinkText: "View " + report.Name,
+				Labels:   alerts.SystemLabels(systemRecord),
+			}); err != nil {
+				h.Logger().Error("Failed to send data quality alert", "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// shouldNotifyDataQuality reports whether the problems of a system differ from
+// the ones its users were last notified of, and remembers them.
+func (h *Hub) shouldNotifyDataQuality(report *records.DataQualityReport) bool {
+	var problems []string
+	if len(report.Gaps) > 0 {
+		problems = append(problems, "gaps")
+	}
+	if report.Duplicates > 0 {
+		problems = append(problems, "duplicates")
+	}
+	if len(report.Invalid) > 0 {
+		problems = append(problems, "invalid")
+	}
+	key := strings.Join(problems, ",")
+
+	h.dataQuality.mu.Lock()
+	defer h.dataQuality.mu.Unlock()
+	if key == "" {
+		delete(h.dataQuality.notified, report.System)
+		return false
+	}
+	if h.dataQuality.notified[report.System] == key {
+		return false
+	}
+	if h.dataQuality.notified == nil {
+		h.dataQuality.notified = make(map[string]string)
+	}
+	h.dataQuality.notified[report.System] = key
+	return true
+}
+
+// describeDataQuality summarizes the problems in a report.
+func describeDataQuality(report *records.DataQualityReport) string {
+	var problems []string
+	if len(report.Gaps) > 0 {
+		missing := 0
+		for _, gap := range report.Gaps {
+			missing += gap.Missing
+		}
+		problems = append(problems, fmt.Sprintf("%d missing records in %d gaps", missing, len(report.Gaps)))
+	}
+	if report.Duplicates > 0 {
+		problems = append(problems, fmt.Sprintf("%d duplicate records", report.Duplicates))
+	}
+	if len(report.Invalid) > 0 {
+		problems = append(problems, fmt.Sprintf("%d impossible values", len(report.Invalid)))
+	}
+	return fmt.Sprintf("Found %s in the last hour of %s stats.", strings.Join(problems, ", "), report.Name)
+}
+
+// getDataQuality handles GET /api/beszel/data-quality. Returns the latest check,
+// running a new one first if there is none or an admin sets the "refresh" query
+// param to true. Only the hourly check sends notifications.
+func (h *Hub) getDataQuality(e *core.RequestEvent) error {
+	refresh := e.Request.URL.Query().Get("refresh") == "true"
+	if refresh && !e.HasSuperuserAuth() && e.Auth.GetString("role") != "admin" {
+		return e.ForbiddenError("Only admins can refresh the data quality check", nil)
+	}
+	h.dataQuality.mu.Lock()
+	stale := h.dataQuality.checked.IsZero()
+	h.dataQuality.mu.Unlock()
+	if stale || refresh {
+		if _, err := h.scanDataQuality(); err != nil {
+			return e.InternalServerError("", err)
+		}
+	}
+
+	systems, err := h.getFleetSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	h.dataQuality.mu.Lock()
+	defer h.dataQuality.mu.Unlock()
+	response := DataQualityResponse{Checked: h.dataQuality.checked, Systems: []records.DataQualityReport{}}
+	for _, report := range h.dataQuality.reports {
+		if slices.ContainsFunc(systems, func(sys fleetSystem) bool { return sys.Id == report.System }) {
+			response.Systems = append(response.Systems, report)
+		}
+	}
+	return e.JSON(http.StatusOK, response)
+}
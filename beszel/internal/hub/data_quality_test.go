@@ -0,0 +1,125 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataQualityApi(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	t.Setenv("DATA_QUALITY_ALERTS", "true")
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	_, err = beszelTests.CreateRecord(hub, "user_settings", map[string]any{
+		"user":     user.Id,
+		"settings": map[string]any{"emails": []string{"testuser@example.com"}},
+	})
+	require.NoError(t, err)
+	admin, err := beszelTests.CreateUser(hub, "admin@example.com", "password123")
+	require.NoError(t, err)
+	admin.Set("role", "admin")
+	require.NoError(t, hub.Save(admin))
+	adminToken, err := admin.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+
+	createSystem := func(name string, users []string) string {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":  name,
+			"host":  "127.0.0.1",
+			"users": users,
+		})
+		require.NoError(t, err)
+		// status is reset on create
+		record.Set("status", "up")
+		require.NoError(t, hub.SaveNoValidate(record))
+		return record.Id
+	}
+	systemId := createSystem("gappy", []string{user.Id})
+	createSystem("hidden", []string{otherUser.Id})
+
+	now := time.Now().UTC()
+	for _, age := range []time.Duration{20, 10, 9} {
+		record, err := beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": systemId,
+			"type":   "1m",
+			"stats":  `{"cpu": 10}`,
+		})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-age*time.Minute).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+
+	testAppFactory := func(t testing.TB) *tests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /data-quality - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/data-quality",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:               "GET /data-quality - reports user's systems",
+			Method:             http.MethodGet,
+			URL:                "/api/beszel/data-quality",
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"name":"gappy"`, `"records":3`, `"missing":9`, `"duplicates":0`},
+			NotExpectedContent: []string{"hidden"},
+			TestAppFactory:     testAppFactory,
+			Headers:            map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:            "GET /data-quality?refresh=true - users can't refresh",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/data-quality?refresh=true",
+			ExpectedStatus:  403,
+			ExpectedContent: []string{"Only admins can refresh"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:            "GET /data-quality?refresh=true - admins refresh without notifying",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/data-quality?refresh=true",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"systems":[`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": adminToken},
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				assert.Zero(t, app.TestMailer.TotalSend())
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+
+	// the hourly check notifies once for the same problems
+	require.NoError(t, hub.CheckDataQuality())
+	assert.Equal(t, 1, hub.TestMailer.TotalSend())
+	require.NoError(t, hub.CheckDataQuality())
+	assert.Equal(t, 1, hub.TestMailer.TotalSend())
+}
@@ -0,0 +1,105 @@
+package hub
+
+import (
+	"beszel"
+	"beszel/internal/diag"
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Number of recent log entries included in diagnostic bundles
+const diagLogLimit = 500
+
+// Period searched for the latest stats record. Matches the retention of 1m records.
+const diagSampleAge = time.Hour
+
+// diagSystem is the status of a system in a diagnostic bundle
+type diagSystem struct {
+	Name       string                            `json:"name"`
+	Host       string                            `json:"host"`
+	Status     string                            `json:"status"`
+	Version    string                            `json:"version"`
+	Latency    float64                           `json:"latency,omitempty"`
+	Collectors map[string]system.CollectorStatus `json:"collectors,omitempty"`
+}
+
+// Diag writes a diagnostic bundle for bug reports to path, with the config,
+// recent logs, the status and collectors of each system and the latest stats
+// record. If review is true, the user reviews the bundle before it's written.
+// The app must be bootstrapped.
+func (h *Hub) Diag(path string, review bool, in io.Reader, out io.Writer) error {
+	bundle := diag.New()
+	bundle.Add("version.txt", []byte(beszel.AppName+" "+beszel.Version+" "+runtime.GOOS+"/"+runtime.GOARCH+"\n"))
+	if err := bundle.AddJSON("config.json", diag.Env("BESZEL_HUB_")); err != nil {
+		return err
+	}
+
+	systemRecords, err := h.FindAllRecords("systems")
+	if err != nil {
+		return err
+	}
+	systemNames := make(map[string]string, len(systemRecords))
+	systems := make([]diagSystem, 0, len(systemRecords))
+	for _, record := range systemRecords {
+		var info system.Info
+		_ = record.UnmarshalJSONField("info", &info)
+		systems = append(systems, diagSystem{
+			Name:       record.GetString("name"),
+			Host:       record.GetString("host"),
+			Status:     record.GetString("status"),
+			Version:    info.AgentVersion,
+			Latency:    info.Latency,
+			Collectors: info.Collectors,
+		})
+		systemNames[record.Id] = record.GetString("name")
+		bundle.MaskHostnames(record.GetString("name"), record.GetString("host"), info.Hostname)
+	}
+	if err := bundle.AddJSON("systems.json", systems); err != nil {
+		return err
+	}
+
+	// latest stats record, as stored from the agent payload
+	records, err := h.storage.SystemStats(storage.Query{Type: storage.Type1m, Since: time.Now().Add(-diagSampleAge)})
+	if err != nil {
+		return err
+	}
+	if len(records) > 0 {
+		latest := records[0]
+		for _, record := range records[1:] {
+			if record.Created.After(latest.Created) {
+				latest = record
+			}
+		}
+		if err := bundle.AddJSON("sample.json", map[string]any{
+			"system":  systemNames[latest.System],
+			"created": latest.Created,
+			"stats":   latest.Stats,
+		}); err != nil {
+			return err
+		}
+	}
+
+	var logs []*core.Log
+	if err := h.LogQuery().OrderBy("created DESC").Limit(diagLogLimit).All(&logs); err != nil {
+		return err
+	}
+	if err := bundle.AddJSON("logs.json", logs); err != nil {
+		return err
+	}
+
+	// user emails may appear in logs
+	users, err := h.FindAllRecords("users")
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		bundle.Redact(user.Email())
+	}
+
+	return bundle.Save(path, review, in, out)
+}
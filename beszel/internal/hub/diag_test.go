@@ -0,0 +1,65 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"archive/zip"
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiag(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := beszelTests.CreateUser(hub, "diag-user@example.com", "password123")
+	require.NoError(t, err)
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "prod-db-1",
+		"host":  "192.168.1.20",
+		"users": []string{user.Id},
+		"info":  system.Info{AgentVersion: "0.12.0"},
+	})
+	require.NoError(t, err)
+	_, err = beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+		"system": systemRecord.Id,
+		"type":   "1m",
+		"stats":  system.Stats{Cpu: 42},
+	})
+	require.NoError(t, err)
+	hub.Logger().Info("Login", "email", "diag-user@example.com")
+
+	path := filepath.Join(t.TempDir(), "diag.zip")
+	require.NoError(t, hub.Diag(path, false, nil, io.Discard))
+
+	archive, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer archive.Close()
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		files[f.Name] = string(content)
+	}
+
+	require.Contains(t, files, "systems.json")
+	assert.Contains(t, files["systems.json"], `"version": "0.12.0"`)
+	assert.Contains(t, files["sample.json"], `"cpu": 42`)
+	assert.Contains(t, files, "logs.json")
+	assert.Contains(t, files, "config.json")
+	for name, content := range files {
+		assert.NotContains(t, content, "prod-db-1", name)
+		assert.NotContains(t, content, "192.168.1.20", name)
+		assert.NotContains(t, content, "diag-user@example.com", name)
+	}
+}
@@ -0,0 +1,288 @@
+package hub
+
+import (
+	"beszel/internal/hub/storage"
+	"bytes"
+	"fmt"
+	"html/template"
+	"maps"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Periods of digest reports
+const (
+	digestDaily  = "daily"
+	digestWeekly = "weekly"
+)
+
+// DigestReport summarizes the systems of a user over the last day or week
+type DigestReport struct {
+	Period  string         `json:"period"` // "daily" or "weekly"
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"`
+	Systems []DigestSystem `json:"systems"`
+}
+
+// DigestSystem is the summary of a system in a digest report
+type DigestSystem struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// percent of the period the hub received stats from the system
+	Uptime   float64 `json:"uptime"`
+	Cpu      float64 `json:"cpu"`
+	PeakCpu  float64 `json:"peakCpu"`
+	Temp     float64 `json:"temp,omitempty"`     // average of the hottest sensor
+	PeakTemp float64 `json:"peakTemp,omitempty"` // peak of the hottest sensor
+	// change in used disk space of the root filesystem, in GB
+	DiskGrowth float64 `json:"diskGrowth"`
+	Alerts     int     `json:"alerts"` // alerts triggered in the period
+}
+
+// digestSettings are the user settings of digest reports
+type digestSettings struct {
+	Digest string   `json:"digest"` // "daily", "weekly", or empty to disable
+	Emails []string `json:"emails"`
+}
+
+// digestRecordType returns the record type and interval used for a period.
+// Daily reports use 20 minute records and weekly reports use 120 minute
+// records, which are both kept for at least the period.
+func digestRecordType(period string) (string, time.Duration) {
+	if period == digestWeekly {
+		return storage.Type120m, 120 * time.Minute
+	}
+	return storage.Type20m, 20 * time.Minute
+}
+
+// digestLength returns the length of a period
+func digestLength(period string) time.Duration {
+	if period == digestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// sendDigests emails the daily digest reports, and the weekly ones on Mondays
+func (h *Hub) sendDigests(now time.Time) error {
+	records, err := h.FindAllRecords("user_settings")
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		var settings digestSettings
+		if err := record.UnmarshalJSONField("settings", &settings); err != nil {
+			continue
+		}
+		if settings.Digest != digestDaily && (settings.Digest != digestWeekly || now.Weekday() != time.Monday) {
+			continue
+		}
+		userID := record.GetString("user")
+		report, err := newDigestReport(h, h.storage, userID, settings.Digest, now)
+		if err != nil {
+			h.Logger().Error("Failed to create digest report", "user", userID, "err", err)
+			continue
+		}
+		if err := h.sendDigest(userID, settings.Emails, report); err != nil {
+			h.Logger().Error("Failed to send digest report", "user", userID, "err", err)
+		}
+	}
+	return nil
+}
+
+// sendDigest emails a report to the user's notification emails, or to the
+// user's own address if there are none
+func (h *Hub) sendDigest(userID string, emails []string, report *DigestReport) error {
+	if len(emails) == 0 {
+		user, err := h.FindRecordById("users", userID)
+		if err != nil {
+			return err
+		}
+		emails = []string{user.Email()}
+	}
+	message, err := h.newDigestMessage(report)
+	if err != nil {
+		return err
+	}
+	for _, email := range emails {
+		message.To = append(message.To, mail.Address{Address: email})
+	}
+	if err := h.NewMailClient().Send(message); err != nil {
+		return err
+	}
+	h.Logger().Info("Sent digest report", "to", message.To, "subj", message.Subject)
+	return nil
+}
+
+// newDigestReport summarizes the systems of a user over the period ending at to.
+// Uptime is the share of the period's records the hub has, counted from the
+// system's creation if it was added during the period.
+func newDigestReport(app core.App, driver storage.Driver, userID, period string, to time.Time) (*DigestReport, error) {
+	to = to.UTC()
+	report := &DigestReport{Period: period, From: to.Add(-digestLength(period)), To: to, Systems: []DigestSystem{}}
+
+	systems, err := app.FindRecordsByFilter("systems", "users.id ?= {:user} || team.members.id ?= {:user}", "name", -1, 0, dbx.Params{"user": userID})
+	if err != nil {
+		return nil, err
+	}
+	var alertCounts []struct {
+		System string `db:"system"`
+		Count  int    `db:"count"`
+	}
+	err = app.DB().NewQuery("SELECT system, COUNT(*) AS count FROM alerts_history " +
+		"WHERE user = {:user} AND created >= {:from} AND created < {:to} GROUP BY system").
+		Bind(dbx.Params{
+			"user": userID,
+			"from": report.From.Format(types.DefaultDateLayout),
+			"to":   report.To.Format(types.DefaultDateLayout),
+		}).All(&alertCounts)
+	if err != nil {
+		return nil, err
+	}
+	alertsBySystem := make(map[string]int, len(alertCounts))
+	for _, c := range alertCounts {
+		alertsBySystem[c.System] = c.Count
+	}
+
+	recordType, interval := digestRecordType(period)
+	for _, systemRecord := range systems {
+		since := report.From
+		if created := systemRecord.GetDateTime("created").Time(); created.After(since) {
+			since = created
+		}
+		if !since.Before(to) {
+			continue
+		}
+		stats, err := driver.SystemStats(storage.Query{System: systemRecord.Id, Type: recordType, Since: since, Until: to})
+		if err != nil {
+			return nil, err
+		}
+		summary := summarizeDigestStats(stats)
+		summary.Id = systemRecord.Id
+		summary.Name = systemRecord.GetString("name")
+		summary.Alerts = alertsBySystem[systemRecord.Id]
+		if expected := float64(to.Sub(since)) / float64(interval); expected >= 1 {
+			summary.Uptime = min(100, float64(len(stats))/expected*100)
+		} else if len(stats) > 0 {
+			summary.Uptime = 100
+		}
+		report.Systems = append(report.Systems, summary)
+	}
+	return report, nil
+}
+
+// summarizeDigestStats returns the CPU, temperature and disk growth of a
+// system's records
+func summarizeDigestStats(records []storage.SystemStats) DigestSystem {
+	var summary DigestSystem
+	if len(records) == 0 {
+		return summary
+	}
+	var cpuSum, tempSum float64
+	var tempCount int
+	for i := range records {
+		stats := &records[i].Stats
+		cpuSum += stats.Cpu
+		summary.PeakCpu = max(summary.PeakCpu, stats.Cpu, stats.MaxCpu)
+		if len(stats.Temperatures) > 0 {
+			temp := slices.Max(slices.Collect(maps.Values(stats.Temperatures)))
+			tempSum += temp
+			tempCount++
+			summary.PeakTemp = max(summary.PeakTemp, temp)
+		}
+	}
+	summary.Cpu = cpuSum / float64(len(records))
+	if tempCount > 0 {
+		summary.Temp = tempSum / float64(tempCount)
+	}
+	summary.DiskGrowth = records[len(records)-1].Stats.DiskUsed - records[0].Stats.DiskUsed
+	return summary
+}
+
+// digestTemplate is the HTML body of digest emails
+var digestTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"round": func(v float64) string { return fmt.Sprintf("%.1f", v) },
+}).Parse(`<h2>{{.Title}}</h2>
+<p>{{.Report.From.Format "Jan 2 15:04"}} – {{.Report.To.Format "Jan 2 15:04"}} UTC</p>
+<table cellpadding="6" style="border-collapse: collapse">
+<tr style="text-align: left"><th>System</th><th>Uptime</th><th>CPU avg / peak</th><th>Temp avg / peak</th><th>Disk growth</th><th>Alerts</th></tr>
+{{range .Report.Systems}}<tr style="border-top: 1px solid #ddd">
+<td>{{.Name}}</td><td>{{round .Uptime}}%</td><td>{{round .Cpu}}% / {{round .PeakCpu}}%</td>
+<td>{{if .PeakTemp}}{{round .Temp}} °C / {{round .PeakTemp}} °C{{else}}–{{end}}</td>
+<td>{{if ge .DiskGrowth 0.0}}+{{end}}{{round .DiskGrowth}} GB</td><td>{{.Alerts}}</td>
+</tr>
+{{else}}<tr><td colspan="6">No systems</td></tr>
+{{end}}</table>
+{{if .Link}}<p><a href="{{.Link}}">Open Beszel</a></p>{{end}}`))
+
+// newDigestMessage renders a report as an email with HTML and text bodies
+func (h *Hub) newDigestMessage(report *DigestReport) (*mailer.Message, error) {
+	title := fmt.Sprintf("Beszel %s report for %s", report.Period, report.To.Format("Jan 2, 2006"))
+	var html bytes.Buffer
+	err := digestTemplate.Execute(&html, map[string]any{"Title": title, "Report": report, "Link": h.MakeLink()})
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	for _, s := range report.Systems {
+		fmt.Fprintf(&text, "%s: uptime %.1f%%, CPU %.1f%% (peak %.1f%%)", s.Name, s.Uptime, s.Cpu, s.PeakCpu)
+		if s.PeakTemp > 0 {
+			fmt.Fprintf(&text, ", temperature %.1f °C (peak %.1f °C)", s.Temp, s.PeakTemp)
+		}
+		fmt.Fprintf(&text, ", disk growth %+.1f GB, %d alerts\n", s.DiskGrowth, s.Alerts)
+	}
+	return &mailer.Message{
+		From: mail.Address{
+			Address: h.Settings().Meta.SenderAddress,
+			Name:    h.Settings().Meta.SenderName,
+		},
+		Subject: title,
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+// getDigest handles GET /api/beszel/digest?period=weekly. Returns the user's
+// digest report of the last day, or of the last week.
+func (h *Hub) getDigest(e *core.RequestEvent) error {
+	period := e.Request.URL.Query().Get("period")
+	if period == "" {
+		period = digestDaily
+	}
+	if period != digestDaily && period != digestWeekly {
+		return e.BadRequestError("Invalid period", nil)
+	}
+	report, err := newDigestReport(e.App, h.storage, e.Auth.Id, period, time.Now())
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	return e.JSON(http.StatusOK, report)
+}
+
+// sendTestDigest handles POST /api/beszel/test-digest, which emails the
+// user's digest report of the period in the request body now
+func (h *Hub) sendTestDigest(e *core.RequestEvent) error {
+	var data struct {
+		Period string   `json:"period"`
+		Emails []string `json:"emails"`
+	}
+	if err := e.BindBody(&data); err != nil || (data.Period != digestDaily && data.Period != digestWeekly) {
+		return e.BadRequestError("Invalid period", err)
+	}
+	report, err := newDigestReport(e.App, h.storage, e.Auth.Id, data.Period, time.Now())
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	if err := h.sendDigest(e.Auth.Id, data.Emails, report); err != nil {
+		return e.JSON(http.StatusOK, map[string]string{"err": err.Error()})
+	}
+	return e.JSON(http.StatusOK, map[string]bool{"err": false})
+}
@@ -0,0 +1,165 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestReports(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "digest@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	createSystem := func(name, userId string) string {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":  name,
+			"host":  "127.0.0.1",
+			"users": []string{userId},
+		})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-10*24*time.Hour).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+		return record.Id
+	}
+	systemId := createSystem("nas", user.Id)
+	createSystem("hidden", otherUser.Id)
+
+	// stats for half of the last day, with growing disk usage
+	for i := range 36 {
+		record, err := beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": systemId,
+			"type":   "20m",
+			"stats":  fmt.Sprintf(`{"cpu": %d, "cpum": 90, "du": %d, "t": {"cpu": 50, "nvme": 60}}`, 10+i%2*10, 200-i),
+		})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-time.Duration(i*20+10)*time.Minute).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+	for _, age := range []time.Duration{time.Hour, 2 * time.Hour, 48 * time.Hour} {
+		record, err := beszelTests.CreateRecord(hub, "alerts_history", map[string]any{"user": user.Id, "system": systemId, "name": "CPU"})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-age).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+
+	testAppFactory := func(t testing.TB) *tests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /digest - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/digest",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /digest - invalid period",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/digest?period=monthly",
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid period"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:    "GET /digest - summarizes the user's systems",
+			Method:  http.MethodGet,
+			URL:     "/api/beszel/digest",
+			Headers: map[string]string{"Authorization": userToken},
+			ExpectedContent: []string{
+				`"period":"daily"`, `"name":"nas"`, `"uptime":50`, `"cpu":15`, `"peakCpu":90`,
+				`"temp":60`, `"peakTemp":60`, `"diskGrowth":35`, `"alerts":2`,
+			},
+			NotExpectedContent: []string{"hidden"},
+			ExpectedStatus:     200,
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:            "GET /digest - weekly reports use longer records",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/digest?period=weekly",
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedContent: []string{`"period":"weekly"`, `"uptime":0`, `"alerts":3`},
+			ExpectedStatus:  200,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "POST /test-digest - emails the report",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/test-digest",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            strings.NewReader(`{"period": "daily", "emails": ["ops@example.com"]}`),
+			ExpectedContent: []string{`"err":false`},
+			ExpectedStatus:  200,
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				require.Equal(t, 1, app.TestMailer.TotalSend())
+				message := app.TestMailer.LastMessage()
+				assert.Equal(t, "ops@example.com", message.To[0].Address)
+				assert.Contains(t, message.Subject, "Beszel daily report")
+				assert.Contains(t, message.HTML, "<td>nas</td><td>50.0%</td>")
+				assert.Contains(t, message.Text, "nas: uptime 50.0%, CPU 15.0% (peak 90.0%)")
+			},
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	for _, digest := range []string{"daily", "weekly", ""} {
+		user, err := beszelTests.CreateUser(hub, digest+"digest@example.com", "password123")
+		require.NoError(t, err)
+		_, err = beszelTests.CreateRecord(hub, "user_settings", map[string]any{
+			"user":     user.Id,
+			"settings": map[string]any{"digest": digest},
+		})
+		require.NoError(t, err)
+	}
+
+	recipients := func() (to []string) {
+		for _, message := range hub.TestMailer.Messages() {
+			to = append(to, message.To[0].Address)
+		}
+		hub.TestMailer.Reset()
+		return to
+	}
+
+	// weekly reports are sent on Mondays, to the user's address if there are no emails
+	monday := time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)
+	require.NoError(t, hub.SendDigests(monday))
+	assert.ElementsMatch(t, []string{"dailydigest@example.com", "weeklydigest@example.com"}, recipients())
+
+	require.NoError(t, hub.SendDigests(monday.AddDate(0, 0, 1)))
+	assert.Equal(t, []string{"dailydigest@example.com"}, recipients())
+}
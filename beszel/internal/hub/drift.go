@@ -0,0 +1,141 @@
+package hub
+
+import (
+	"beszel/internal/entities/system"
+	"cmp"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// driftFact is a fact compared across systems. An empty value means the fact
+// is unknown for the system, which is not drift.
+type driftFact struct {
+	name  string
+	value func(sys *fleetSystem) string
+}
+
+// driftFacts are the facts that can be compared, in report order
+var driftFacts = []driftFact{
+	{"agent", func(sys *fleetSystem) string { return sys.Info.AgentVersion }},
+	{"kernel", func(sys *fleetSystem) string { return sys.Info.KernelVersion }},
+	{"os", func(sys *fleetSystem) string { return osName(sys.Info.Os) }},
+	{"cpu", func(sys *fleetSystem) string { return sys.Info.CpuModel }},
+	{"docker", func(sys *fleetSystem) string { return sys.Info.DockerVersion }},
+	{"sensors", func(sys *fleetSystem) string {
+		if sys.Stats == nil {
+			return ""
+		}
+		sensors := slices.Collect(maps.Keys(sys.Stats.Temperatures))
+		sensors = slices.AppendSeq(sensors, maps.Keys(sys.Stats.GenericSensors))
+		slices.Sort(sensors)
+		return strings.Join(sensors, ",")
+	}},
+}
+
+// Facts compared if the request doesn't select any
+var defaultDriftFacts = []string{"agent", "kernel", "docker", "sensors"}
+
+// DriftReport compares facts across a group of systems
+type DriftReport struct {
+	Systems int         `json:"systems"` // number of systems compared
+	Facts   []FactDrift `json:"facts"`
+}
+
+// FactDrift lists the values of a fact and the systems that differ from the most common one
+type FactDrift struct {
+	Fact     string         `json:"fact"`
+	Baseline string         `json:"baseline"`
+	Values   map[string]int `json:"values"` // value -> number of systems
+	Outliers []DriftOutlier `json:"outliers"`
+}
+
+// DriftOutlier is a system whose value differs from the baseline
+type DriftOutlier struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// getDriftReport handles GET /api/beszel/drift.
+// Optional query params: "group", a name pattern like "web-*", and "facts", a
+// comma separated list of agent, kernel, os, cpu, docker and sensors.
+func (h *Hub) getDriftReport(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	group := query.Get("group")
+	if _, err := path.Match(group, ""); err != nil {
+		return e.BadRequestError("Invalid group pattern", err)
+	}
+	facts := defaultDriftFacts
+	if v := query.Get("facts"); v != "" {
+		facts = strings.Split(v, ",")
+		for _, fact := range facts {
+			if !slices.ContainsFunc(driftFacts, func(f driftFact) bool { return f.name == fact }) {
+				return e.BadRequestError("Invalid fact: "+fact, nil)
+			}
+		}
+	}
+
+	systems, err := h.getFleetSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	return e.JSON(http.StatusOK, newDriftReport(systems, group, facts))
+}
+
+// newDriftReport compares the facts of systems matching the group pattern.
+// Systems that have never reported info are skipped.
+func newDriftReport(systems []fleetSystem, group string, facts []string) DriftReport {
+	systems = slices.DeleteFunc(slices.Clone(systems), func(sys fleetSystem) bool {
+		matched, _ := path.Match(cmp.Or(group, "*"), sys.Name)
+		return !matched || sys.Info.AgentVersion == ""
+	})
+	slices.SortFunc(systems, func(a, b fleetSystem) int { return cmp.Compare(a.Name, b.Name) })
+
+	report := DriftReport{Systems: len(systems), Facts: []FactDrift{}}
+	for _, fact := range driftFacts {
+		if !slices.Contains(facts, fact.name) {
+			continue
+		}
+		drift := FactDrift{Fact: fact.name, Values: make(map[string]int), Outliers: []DriftOutlier{}}
+		values := make([]string, len(systems))
+		for i := range systems {
+			values[i] = fact.value(&systems[i])
+			if values[i] != "" {
+				drift.Values[values[i]]++
+			}
+		}
+		// the most common value is the baseline, preferring the greatest on ties
+		for value, count := range drift.Values {
+			if count > drift.Values[drift.Baseline] || (count == drift.Values[drift.Baseline] && value > drift.Baseline) {
+				drift.Baseline = value
+			}
+		}
+		for i, sys := range systems {
+			if values[i] != "" && values[i] != drift.Baseline {
+				drift.Outliers = append(drift.Outliers, DriftOutlier{Id: sys.Id, Name: sys.Name, Value: values[i]})
+			}
+		}
+		report.Facts = append(report.Facts, drift)
+	}
+	return report
+}
+
+// osName returns the name of an operating system
+func osName(os system.Os) string {
+	switch os {
+	case system.Linux:
+		return "linux"
+	case system.Darwin:
+		return "darwin"
+	case system.Windows:
+		return "windows"
+	case system.Freebsd:
+		return "freebsd"
+	}
+	return ""
+}
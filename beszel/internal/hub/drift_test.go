@@ -0,0 +1,173 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriftReport(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+
+	createSystem := func(name string, users []string, info system.Info, stats *system.Stats) string {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":  name,
+			"host":  "127.0.0.1",
+			"users": users,
+		})
+		require.NoError(t, err)
+		// info is reset on create
+		record.Set("status", "up")
+		record.Set("info", info)
+		require.NoError(t, hub.SaveNoValidate(record))
+		if stats != nil {
+			_, err = beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+				"system": record.Id,
+				"type":   "1m",
+				"stats":  stats,
+			})
+			require.NoError(t, err)
+		}
+		return record.Id
+	}
+
+	sensors := &system.Stats{
+		Temperatures:   map[string]float64{"cpu": 50},
+		GenericSensors: map[string]system.SensorData{"pressure": {Value: 1000, Unit: "Pa"}},
+	}
+	createSystem("web-1", []string{user.Id}, system.Info{AgentVersion: "0.12.0", KernelVersion: "6.1.0", DockerVersion: "27.0.1"}, sensors)
+	createSystem("web-2", []string{user.Id}, system.Info{AgentVersion: "0.12.0", KernelVersion: "6.1.0", DockerVersion: "27.0.1"}, sensors)
+	oldId := createSystem("web-3", []string{user.Id}, system.Info{AgentVersion: "0.11.1", KernelVersion: "5.15.0"},
+		&system.Stats{Temperatures: map[string]float64{"cpu": 50}})
+	createSystem("db-1", []string{user.Id}, system.Info{AgentVersion: "0.10.0", KernelVersion: "4.19.0"}, nil)
+	createSystem("web-new", []string{user.Id}, system.Info{}, nil)
+	createSystem("web-other", []string{otherUser.Id}, system.Info{AgentVersion: "0.9.0"}, nil)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	type driftReport struct {
+		Systems int `json:"systems"`
+		Facts   []struct {
+			Fact     string         `json:"fact"`
+			Baseline string         `json:"baseline"`
+			Values   map[string]int `json:"values"`
+			Outliers []struct {
+				Id    string `json:"id"`
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"outliers"`
+		} `json:"facts"`
+	}
+	readReport := func(t testing.TB, res *http.Response) driftReport {
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		var report driftReport
+		require.NoError(t, json.Unmarshal(body, &report))
+		return report
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /drift - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/drift",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /drift - invalid fact",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/drift?facts=agent,color",
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid fact: color"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:            "GET /drift - invalid group",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/drift?group=%5B",
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid group pattern"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:            "GET /drift - group with default facts",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/drift?group=web-*",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"systems":3`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				report := readReport(t, res)
+				require.Len(t, report.Facts, 4)
+
+				agent := report.Facts[0]
+				assert.Equal(t, "agent", agent.Fact)
+				assert.Equal(t, "0.12.0", agent.Baseline)
+				assert.Equal(t, map[string]int{"0.12.0": 2, "0.11.1": 1}, agent.Values)
+				require.Len(t, agent.Outliers, 1)
+				assert.Equal(t, oldId, agent.Outliers[0].Id)
+				assert.Equal(t, "0.11.1", agent.Outliers[0].Value)
+
+				assert.Equal(t, "kernel", report.Facts[1].Fact)
+				require.Len(t, report.Facts[1].Outliers, 1)
+
+				docker := report.Facts[2]
+				assert.Equal(t, "docker", docker.Fact)
+				assert.Empty(t, docker.Outliers, "systems without docker are not outliers")
+
+				sensors := report.Facts[3]
+				assert.Equal(t, "cpu,pressure", sensors.Baseline)
+				require.Len(t, sensors.Outliers, 1)
+				assert.Equal(t, "web-3", sensors.Outliers[0].Name)
+				assert.Equal(t, "cpu", sensors.Outliers[0].Value)
+			},
+		},
+		{
+			Name:            "GET /drift - all systems, selected facts",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/drift?facts=kernel",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"systems":4`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				report := readReport(t, res)
+				require.Len(t, report.Facts, 1)
+				assert.Equal(t, "6.1.0", report.Facts[0].Baseline)
+				require.Len(t, report.Facts[0].Outliers, 2)
+				assert.Equal(t, "db-1", report.Facts[0].Outliers[0].Name, "outliers are sorted by name")
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
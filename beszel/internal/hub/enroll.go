@@ -0,0 +1,97 @@
+package hub
+
+import (
+	"beszel/internal/common"
+	"beszel/internal/hub/systems"
+	"beszel/internal/hub/ws"
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Longest time an enrollment token can be valid
+const maxEnrollmentTokenDuration = 30 * 24 * time.Hour
+
+var errTokenUsed = errors.New("enrollment token already used")
+
+// validateEnrollmentToken checks the labels and expiration of a new enrollment
+// token. The token is always generated by the hub.
+func validateEnrollmentToken(e *core.RecordRequestEvent) error {
+	var labels map[string]string
+	if err := e.Record.UnmarshalJSONField("labels", &labels); err != nil {
+		return e.BadRequestError("Labels must be an object of strings", err)
+	}
+	if len(systems.SanitizeLabels(labels)) != len(labels) {
+		return e.BadRequestError("Invalid labels", nil)
+	}
+	expires := e.Record.GetDateTime("expires").Time()
+	if !expires.After(time.Now()) || time.Until(expires) > maxEnrollmentTokenDuration {
+		return e.BadRequestError("Enrollment tokens must expire within 30 days", nil)
+	}
+	e.Record.Set("token", "")
+	e.Record.Set("used", "")
+	e.Record.Set("system", "")
+	return e.Next()
+}
+
+// findEnrollmentToken returns the record of an unused and unexpired enrollment
+// token, or nil if there isn't one.
+func findEnrollmentToken(h *Hub, token string) *core.Record {
+	record, err := h.FindFirstRecordByFilter("enrollment_tokens", "token = {:token} && used = '' && expires > {:now}", dbx.Params{
+		"token": token,
+		"now":   time.Now().UTC(),
+	})
+	if err != nil {
+		return nil
+	}
+	return record
+}
+
+// claimEnrollmentToken marks the token as used, failing if another agent
+// claimed it first.
+func claimEnrollmentToken(h *Hub, record *core.Record) error {
+	// conditional update so two agents can't register with the same token
+	result, err := h.DB().NewQuery("UPDATE enrollment_tokens SET used = {:now} WHERE id = {:id} AND used = ''").
+		Bind(dbx.Params{"id": record.Id, "now": types.NowDateTime().String()}).
+		Execute()
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errTokenUsed
+	}
+	return nil
+}
+
+// enrollSystem claims the enrollment token and creates a system with the name
+// and labels sent by the agent, added to the token's labels.
+func (acr *agentConnectRequest) enrollSystem(agentFingerprint common.FingerprintResponse) (ws.FingerprintRecord, error) {
+	var fpRecord ws.FingerprintRecord
+	if err := claimEnrollmentToken(acr.hub, acr.enrollment); err != nil {
+		return fpRecord, err
+	}
+	// the agent's labels replace the token's labels with the same keys, as
+	// they do when the agent sends them with its system info
+	var labels map[string]string
+	_ = acr.enrollment.UnmarshalJSONField("labels", &labels)
+	if len(labels) > 0 {
+		maps.Copy(labels, agentFingerprint.Labels)
+		agentFingerprint.Labels = labels
+	}
+	fpRecord, err := acr.createNewSystemForUniversalToken(agentFingerprint)
+	if err != nil {
+		return fpRecord, err
+	}
+	// link the token to the system, reloading it to keep the used date
+	if record, err := acr.hub.FindRecordById("enrollment_tokens", acr.enrollment.Id); err == nil {
+		record.Set("system", fpRecord.SystemId)
+		if err := acr.hub.SaveNoValidate(record); err != nil {
+			acr.hub.Logger().Error("Error saving enrollment token", "err", err)
+		}
+	}
+	return fpRecord, nil
+}
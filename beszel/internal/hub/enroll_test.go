@@ -0,0 +1,128 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"testing"
+	"time"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrollmentTokens(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	readonlyUser, err := beszelTests.CreateUser(hub, "readonly@example.com", "password123")
+	require.NoError(t, err)
+	readonlyUser.Set("role", "readonly")
+	require.NoError(t, hub.Save(readonlyUser))
+	readonlyToken, err := readonlyUser.NewAuthToken()
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+	expires := func(d time.Duration) string {
+		date, _ := types.ParseDateTime(time.Now().Add(d))
+		return date.String()
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:   "POST enrollment_tokens - token is generated",
+			Method: http.MethodPost,
+			URL:    "/api/collections/enrollment_tokens/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"token":   "chosen-by-the-client-chosen-by-the-client",
+				"used":    expires(0),
+				"labels":  map[string]string{"env": "prod"},
+				"expires": expires(24 * time.Hour),
+			}),
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"labels":{"env":"prod"}`, `"used":""`},
+			NotExpectedContent: []string{"chosen-by-the-client"},
+			TestAppFactory:     testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:   "POST enrollment_tokens - invalid labels",
+			Method: http.MethodPost,
+			URL:    "/api/collections/enrollment_tokens/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"labels":  []string{"prod"},
+				"expires": expires(24 * time.Hour),
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Labels must be an object of strings"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:   "POST enrollment_tokens - expiration too far",
+			Method: http.MethodPost,
+			URL:    "/api/collections/enrollment_tokens/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"expires": expires(31 * 24 * time.Hour),
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"expire within 30 days"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:   "POST enrollment_tokens - readonly user can't create",
+			Method: http.MethodPost,
+			URL:    "/api/collections/enrollment_tokens/records",
+			Body: jsonReader(map[string]any{
+				"user":    readonlyUser.Id,
+				"expires": expires(24 * time.Hour),
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Failed to create record"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": readonlyToken,
+			},
+		},
+		{
+			Name:   "POST enrollment_tokens - can't create for another user",
+			Method: http.MethodPost,
+			URL:    "/api/collections/enrollment_tokens/records",
+			Body: jsonReader(map[string]any{
+				"user":    readonlyUser.Id,
+				"expires": expires(24 * time.Hour),
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Failed to create record"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
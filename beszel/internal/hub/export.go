@@ -0,0 +1,188 @@
+package hub
+
+import (
+	"beszel/internal/entities/system"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// unsafeFilenameChars matches characters that are replaced in export file names
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// ExportSeries is a metric series of an export
+type ExportSeries struct {
+	Metric string            `json:"metric"` // name without the "beszel_" prefix
+	Labels map[string]string `json:"labels,omitempty"`
+	Points [][2]float64      `json:"points"` // unix time in ms, value
+}
+
+// ExportData is the JSON body of a stats export
+type ExportData struct {
+	System     string         `json:"system"`
+	Name       string         `json:"name"`
+	Resolution string         `json:"resolution"`
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Series     []ExportSeries `json:"series"`
+}
+
+// exportSeries is a series of an export with its column name
+type exportSeries struct {
+	column string
+	ExportSeries
+}
+
+// v1ExportStats handles GET /api/v1/systems/{id}/export, which downloads the
+// metrics of a system as CSV or JSON. It takes the query params of
+// v1GetStats, plus "format" (csv or json) and "metrics", comma separated
+// metric names with an optional "beszel_" prefix. All metrics are exported by
+// default.
+func (h *Hub) v1ExportStats(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		return e.BadRequestError("Invalid format, expected csv or json", nil)
+	}
+	var metricNames []string
+	for name := range strings.SplitSeq(query.Get("metrics"), ",") {
+		if name = strings.TrimPrefix(strings.TrimSpace(name), grafanaMetricPrefix); name != "" {
+			metricNames = append(metricNames, name)
+		}
+	}
+
+	record, result, err := h.querySystemStats(e)
+	if err != nil {
+		return err
+	}
+	name := h.newAPISystem(record, isReadonly(e)).Name
+
+	// series by column name, in order of appearance
+	var series []*exportSeries
+	seriesByColumn := make(map[string]*exportSeries)
+	for i := range result.Records {
+		timestamp := float64(result.Records[i].Created.UnixMilli())
+		for _, metric := range result.Records[i].Stats.Metrics() {
+			if len(metricNames) > 0 && !slices.Contains(metricNames, metric.Name) {
+				continue
+			}
+			column := exportColumnName(&metric)
+			s, ok := seriesByColumn[column]
+			if !ok {
+				s = &exportSeries{column: column, ExportSeries: ExportSeries{Metric: metric.Name}}
+				if len(metric.Labels) > 1 {
+					s.Labels = make(map[string]string, len(metric.Labels)/2)
+					for j := 0; j+1 < len(metric.Labels); j += 2 {
+						s.Labels[metric.Labels[j]] = metric.Labels[j+1]
+					}
+				}
+				seriesByColumn[column] = s
+				series = append(series, s)
+			}
+			s.Points = append(s.Points, [2]float64{timestamp, metric.Value})
+		}
+	}
+
+	filename := fmt.Sprintf("%s_%s_%s.%s",
+		strings.Trim(unsafeFilenameChars.ReplaceAllString(name, "_"), "_"),
+		result.Resolution,
+		result.From.UTC().Format("20060102-1504"),
+		format)
+	e.Response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "json" {
+		data := ExportData{
+			System:     result.System,
+			Name:       name,
+			Resolution: result.Resolution,
+			From:       result.From,
+			To:         result.To,
+			Series:     make([]ExportSeries, len(series)),
+		}
+		for i, s := range series {
+			data.Series[i] = s.ExportSeries
+		}
+		return e.JSON(http.StatusOK, data)
+	}
+
+	body, err := exportCSV(series)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	return e.Blob(http.StatusOK, "text/csv; charset=utf-8", body)
+}
+
+// exportCSV returns a CSV table with a row per record and a column per series.
+// Values missing from a record are empty.
+func exportCSV(series []*exportSeries) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := make([]string, len(series)+1)
+	header[0] = "time"
+	for i, s := range series {
+		header[i+1] = s.column
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	// points of each series are in time order, so rows advance a cursor per series
+	cursors := make([]int, len(series))
+	times := make(map[float64]struct{})
+	var rowTimes []float64
+	for _, s := range series {
+		for _, point := range s.Points {
+			if _, ok := times[point[0]]; !ok {
+				times[point[0]] = struct{}{}
+				rowTimes = append(rowTimes, point[0])
+			}
+		}
+	}
+	slices.Sort(rowTimes)
+	row := make([]string, len(series)+1)
+	for _, timestamp := range rowTimes {
+		row[0] = time.UnixMilli(int64(timestamp)).UTC().Format(time.RFC3339)
+		for i, s := range series {
+			row[i+1] = ""
+			if c := cursors[i]; c < len(s.Points) && s.Points[c][0] == timestamp {
+				row[i+1] = strconv.FormatFloat(s.Points[c][1], 'f', -1, 64)
+				cursors[i]++
+			}
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// exportColumnName returns the CSV column of a metric, e.g. temperature_celsius{sensor=cpu}.
+// Label values aren't quoted, so spreadsheets show the names as they are.
+func exportColumnName(metric *system.Metric) string {
+	if len(metric.Labels) < 2 {
+		return metric.Name
+	}
+	var sb strings.Builder
+	sb.WriteString(metric.Name)
+	for i := 0; i+1 < len(metric.Labels); i += 2 {
+		if i == 0 {
+			sb.WriteString("{")
+		} else {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, "%s=%s", metric.Labels[i], metric.Labels[i+1])
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
@@ -0,0 +1,137 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportStats(t *testing.T) {
+	testHub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer testHub.Cleanup()
+
+	testHub.StartHub()
+
+	user, err := beszelTests.CreateUser(testHub, "export@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	key, err := beszelTests.CreateRecord(testHub, "api_keys", map[string]any{
+		"user":   user.Id,
+		"token":  strings.Repeat("a", 40),
+		"scopes": []string{"systems:read"},
+	})
+	require.NoError(t, err)
+	systemRecord, err := beszelTests.CreateRecord(testHub, "systems", map[string]any{
+		"name":  "web 1",
+		"host":  "10.0.0.1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, stats := range []string{
+		`{"cpu": 25, "t": {"cpu": 51.5}}`,
+		`{"cpu": 30}`,
+	} {
+		record, err := beszelTests.CreateRecord(testHub, "system_stats", map[string]any{
+			"system": systemRecord.Id,
+			"type":   "1m",
+			"stats":  stats,
+		})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-time.Duration(10-i*5)*time.Minute).Format(types.DefaultDateLayout))
+		require.NoError(t, testHub.SaveNoValidate(record))
+	}
+
+	testAppFactory := func(t testing.TB) *tests.TestApp {
+		return testHub.TestApp
+	}
+	auth := map[string]string{"Authorization": userToken}
+	exportURL := "/api/v1/systems/" + systemRecord.Id + "/export?from=" + url.QueryEscape(now.Add(-time.Hour).Format(time.RFC3339)) +
+		"&to=" + url.QueryEscape(now.Format(time.RFC3339))
+	first := now.Add(-10 * time.Minute).Format(time.RFC3339)
+	second := now.Add(-5 * time.Minute).Format(time.RFC3339)
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "export - key without stats:read should fail",
+			Method:          http.MethodGet,
+			URL:             exportURL,
+			Headers:         map[string]string{"X-API-Key": key.GetString("token")},
+			ExpectedStatus:  403,
+			ExpectedContent: []string{"stats:read scope"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "export - invalid format should fail",
+			Method:          http.MethodGet,
+			URL:             exportURL + "&format=xml",
+			Headers:         auth,
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid format"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:           "export - CSV has a row per record and a column per series",
+			Method:         http.MethodGet,
+			URL:            exportURL,
+			Headers:        auth,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"time,cpu_usage_percent,load_average{period=1m},",
+				",temperature_celsius{sensor=cpu}\n",
+				first + ",25,0,",
+				",51.5\n",
+				second + ",30,0,",
+			},
+			TestAppFactory: testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				assert.Equal(t, "text/csv; charset=utf-8", res.Header.Get("Content-Type"))
+				assert.Equal(t, `attachment; filename="web_1_1m_`+now.Add(-time.Hour).Format("20060102-1504")+`.csv"`, res.Header.Get("Content-Disposition"))
+			},
+		},
+		{
+			Name:            "export - metrics limits the columns",
+			Method:          http.MethodGet,
+			URL:             exportURL + "&metrics=beszel_cpu_usage_percent,temperature_celsius",
+			Headers:         auth,
+			ExpectedStatus:  200,
+			ExpectedContent: []string{"time,cpu_usage_percent,temperature_celsius{sensor=cpu}\n" + first + ",25,51.5\n" + second + ",30,\n"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:           "export - JSON has the points of each series",
+			Method:         http.MethodGet,
+			URL:            exportURL + "&format=json&metrics=temperature_celsius",
+			Headers:        auth,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"web 1","resolution":"1m"`,
+				`"series":[{"metric":"temperature_celsius","labels":{"sensor":"cpu"},"points":[[` +
+					strconv.FormatInt(now.Add(-10*time.Minute).UnixMilli(), 10) + `,51.5]]}]`,
+			},
+			TestAppFactory: testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				assert.Contains(t, res.Header.Get("Content-Disposition"), ".json")
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
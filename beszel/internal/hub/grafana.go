@@ -0,0 +1,462 @@
+package hub
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// The Grafana JSON data source (and the older SimpleJSON data source) query
+// the hub at /api/beszel/grafana. Metrics have the names of remote write
+// metrics, and targets select series with Prometheus style label matchers,
+// e.g. beszel_temperature_celsius{system="nas",sensor=~"cpu.*"}. Series are
+// labeled with the system name, system id, system labels and metric labels.
+
+const (
+	grafanaMetricPrefix = "beszel_"
+	// max alerts returned as annotations
+	maxGrafanaAnnotations = 1000
+)
+
+var (
+	// metric name with optional label matchers
+	grafanaSelectorPattern = regexp.MustCompile(`^\s*([a-zA-Z_:][a-zA-Z0-9_:]*)\s*(?:\{(.*)\})?\s*$`)
+	// label matcher at the start of the matchers, followed by a comma or the end
+	grafanaMatcherPattern = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*"((?:[^"\\]|\\.)*)"\s*(?:,|$)`)
+)
+
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// grafanaFilter is an ad hoc filter set in a Grafana dashboard
+type grafanaFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+type grafanaQueryRequest struct {
+	Range         grafanaRange    `json:"range"`
+	MaxDataPoints int             `json:"maxDataPoints"`
+	Targets       []grafanaTarget `json:"targets"`
+	AdhocFilters  []grafanaFilter `json:"adhocFilters"`
+}
+
+type grafanaTarget struct {
+	Target string `json:"target"`
+	Hide   bool   `json:"hide"`
+}
+
+// grafanaSeries is a time series with [value, unix ms] data points
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type grafanaAnnotation struct {
+	Time    int64    `json:"time"`
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Title   string   `json:"title"`
+	Text    string   `json:"text"`
+	Tags    []string `json:"tags"`
+}
+
+// grafanaSystem is a system visible to the user with the labels of its series
+type grafanaSystem struct {
+	id     string
+	labels map[string]string
+}
+
+// labelMatcher matches a label value like a Prometheus label matcher.
+// Missing labels have an empty value.
+type labelMatcher struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func newLabelMatcher(name, op, value string) (labelMatcher, error) {
+	matcher := labelMatcher{name: name, op: op, value: value}
+	switch op {
+	case "=", "!=":
+	case "=~", "!~":
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return matcher, fmt.Errorf("invalid regex for %s: %w", name, err)
+		}
+		matcher.re = re
+	default:
+		return matcher, fmt.Errorf("unsupported operator %q", op)
+	}
+	return matcher, nil
+}
+
+func (m *labelMatcher) matches(labels map[string]string) bool {
+	value := labels[m.name]
+	switch m.op {
+	case "=":
+		return value == m.value
+	case "!=":
+		return value != m.value
+	case "=~":
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+// parseGrafanaTarget parses a target like metric{label="value",label=~"regex"}.
+// Returns the metric name without the beszel_ prefix.
+func parseGrafanaTarget(target string) (string, []labelMatcher, error) {
+	match := grafanaSelectorPattern.FindStringSubmatch(target)
+	if match == nil {
+		return "", nil, fmt.Errorf("invalid target %q", target)
+	}
+	var matchers []labelMatcher
+	for rest := match[2]; strings.TrimSpace(rest) != ""; {
+		m := grafanaMatcherPattern.FindStringSubmatch(rest)
+		if m == nil {
+			return "", nil, fmt.Errorf("invalid label matchers in %q", target)
+		}
+		value, err := strconv.Unquote(`"` + m[3] + `"`)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid label value %q", m[3])
+		}
+		matcher, err := newLabelMatcher(m[1], m[2], value)
+		if err != nil {
+			return "", nil, err
+		}
+		matchers = append(matchers, matcher)
+		rest = rest[len(m[0]):]
+	}
+	return strings.TrimPrefix(match[1], grafanaMetricPrefix), matchers, nil
+}
+
+// grafanaSeriesName returns the name of a series, e.g. beszel_temperature_celsius{system="nas",sensor="cpu"}
+func grafanaSeriesName(metric *system.Metric, systemName string) string {
+	var sb strings.Builder
+	sb.WriteString(grafanaMetricPrefix + metric.Name)
+	fmt.Fprintf(&sb, "{system=%q", systemName)
+	for i := 0; i+1 < len(metric.Labels); i += 2 {
+		fmt.Fprintf(&sb, ",%s=%q", metric.Labels[i], metric.Labels[i+1])
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// grafanaSystems returns the systems visible to the request's user. System
+// names are masked for readonly users.
+func (h *Hub) grafanaSystems(e *core.RequestEvent) ([]grafanaSystem, error) {
+	records, err := findVisibleSystems(e)
+	if err != nil {
+		return nil, err
+	}
+	readonly := e.Auth != nil && e.Auth.GetString("role") == "readonly"
+	systems := make([]grafanaSystem, 0, len(records))
+	for _, record := range records {
+		labels := make(map[string]string)
+		_ = record.UnmarshalJSONField("labels", &labels)
+		name := record.GetString("name")
+		if readonly {
+			name = h.mask.Hostname(name)
+		}
+		labels["system"] = name
+		labels["system_id"] = record.Id
+		systems = append(systems, grafanaSystem{id: record.Id, labels: labels})
+	}
+	return systems, nil
+}
+
+// grafanaCatalog returns the metric names, and the values of each label, of
+// the latest stats of the systems
+func (h *Hub) grafanaCatalog(systems []grafanaSystem) ([]string, map[string][]string, error) {
+	latestStats, err := getLatestSystemStats(h.storage)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make(map[string]struct{})
+	labelValues := make(map[string]map[string]struct{})
+	addLabel := func(name, value string) {
+		if labelValues[name] == nil {
+			labelValues[name] = make(map[string]struct{})
+		}
+		labelValues[name][value] = struct{}{}
+	}
+	// metrics of empty stats are listed even if no system is up
+	for _, metric := range (&system.Stats{}).Metrics() {
+		names[grafanaMetricPrefix+metric.Name] = struct{}{}
+	}
+	for _, sys := range systems {
+		for name, value := range sys.labels {
+			addLabel(name, value)
+		}
+		stats, ok := latestStats[sys.id]
+		if !ok {
+			continue
+		}
+		for _, metric := range stats.Metrics() {
+			names[grafanaMetricPrefix+metric.Name] = struct{}{}
+			for i := 0; i+1 < len(metric.Labels); i += 2 {
+				addLabel(metric.Labels[i], metric.Labels[i+1])
+			}
+		}
+	}
+	values := make(map[string][]string, len(labelValues))
+	for name, set := range labelValues {
+		values[name] = slices.Sorted(maps.Keys(set))
+	}
+	return slices.Sorted(maps.Keys(names)), values, nil
+}
+
+// grafanaTestConnection handles GET /api/beszel/grafana, which Grafana
+// requests to test the data source
+func (h *Hub) grafanaTestConnection(e *core.RequestEvent) error {
+	return e.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// grafanaSearch handles POST /api/beszel/grafana/search. Returns the metric
+// names containing the target in the request body.
+func (h *Hub) grafanaSearch(e *core.RequestEvent) error {
+	var data struct {
+		Target string `json:"target"`
+	}
+	// the body is optional
+	_ = e.BindBody(&data)
+	systems, err := h.grafanaSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	names, _, err := h.grafanaCatalog(systems)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return !strings.Contains(name, data.Target)
+	})
+	return e.JSON(http.StatusOK, names)
+}
+
+// grafanaMetrics handles POST /api/beszel/grafana/metrics, the metric list
+// of the JSON data source query editor
+func (h *Hub) grafanaMetrics(e *core.RequestEvent) error {
+	systems, err := h.grafanaSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	names, _, err := h.grafanaCatalog(systems)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	metrics := make([]map[string]string, len(names))
+	for i, name := range names {
+		metrics[i] = map[string]string{"label": name, "value": name}
+	}
+	return e.JSON(http.StatusOK, metrics)
+}
+
+// grafanaTagKeys handles POST /api/beszel/grafana/tag-keys, the label names
+// available to ad hoc filters
+func (h *Hub) grafanaTagKeys(e *core.RequestEvent) error {
+	systems, err := h.grafanaSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	_, labelValues, err := h.grafanaCatalog(systems)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	keys := make([]map[string]string, 0, len(labelValues))
+	for _, name := range slices.Sorted(maps.Keys(labelValues)) {
+		keys = append(keys, map[string]string{"type": "string", "text": name})
+	}
+	return e.JSON(http.StatusOK, keys)
+}
+
+// grafanaTagValues handles POST /api/beszel/grafana/tag-values, the values
+// of the label in the request body
+func (h *Hub) grafanaTagValues(e *core.RequestEvent) error {
+	var data struct {
+		Key string `json:"key"`
+	}
+	if err := e.BindBody(&data); err != nil || data.Key == "" {
+		return e.BadRequestError("Invalid key", err)
+	}
+	systems, err := h.grafanaSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	_, labelValues, err := h.grafanaCatalog(systems)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	values := make([]map[string]string, 0, len(labelValues[data.Key]))
+	for _, value := range labelValues[data.Key] {
+		values = append(values, map[string]string{"text": value})
+	}
+	return e.JSON(http.StatusOK, values)
+}
+
+// grafanaQuery handles POST /api/beszel/grafana/query. Returns a series for
+// each metric sample of the visible systems matching a target and the ad hoc
+// filters, from records of the type that fits the range and max data points.
+func (h *Hub) grafanaQuery(e *core.RequestEvent) error {
+	var data grafanaQueryRequest
+	if err := e.BindBody(&data); err != nil {
+		return e.BadRequestError("Invalid query", err)
+	}
+	if !data.Range.From.Before(data.Range.To) {
+		return e.BadRequestError("Invalid range", nil)
+	}
+	var filters []labelMatcher
+	for _, filter := range data.AdhocFilters {
+		matcher, err := newLabelMatcher(filter.Key, filter.Operator, filter.Value)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		filters = append(filters, matcher)
+	}
+	systems, err := h.grafanaSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	recordType := h.recordTypeForRange(data.Range.From, data.Range.To, data.MaxDataPoints, time.Now().UTC())
+
+	// stats of each system are loaded once for all targets
+	systemStats := make(map[string][]storage.SystemStats, len(systems))
+	series := []*grafanaSeries{}
+	for _, target := range data.Targets {
+		if target.Hide || strings.TrimSpace(target.Target) == "" {
+			continue
+		}
+		name, matchers, err := parseGrafanaTarget(target.Target)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		matchers = append(matchers, filters...)
+		for _, sys := range systems {
+			records, ok := systemStats[sys.id]
+			if !ok {
+				records, err = h.storage.SystemStats(storage.Query{
+					System: sys.id,
+					Type:   recordType,
+					Since:  data.Range.From,
+					Until:  data.Range.To,
+				})
+				if err != nil {
+					return e.InternalServerError("", err)
+				}
+				systemStats[sys.id] = records
+			}
+			// series of the system by name, in order of appearance
+			systemSeries := make(map[string]*grafanaSeries)
+			for i := range records {
+				timestamp := float64(records[i].Created.UnixMilli())
+				for _, metric := range records[i].Stats.Metrics() {
+					if metric.Name != name || !matchesLabels(matchers, sys.labels, metric.Labels) {
+						continue
+					}
+					seriesName := grafanaSeriesName(&metric, sys.labels["system"])
+					s, ok := systemSeries[seriesName]
+					if !ok {
+						s = &grafanaSeries{Target: seriesName}
+						systemSeries[seriesName] = s
+						series = append(series, s)
+					}
+					s.Datapoints = append(s.Datapoints, [2]float64{metric.Value, timestamp})
+				}
+			}
+		}
+	}
+	return e.JSON(http.StatusOK, series)
+}
+
+// matchesLabels reports whether the system and metric labels match all matchers
+func matchesLabels(matchers []labelMatcher, systemLabels map[string]string, metricLabels []string) bool {
+	if len(matchers) == 0 {
+		return true
+	}
+	labels := systemLabels
+	if len(metricLabels) > 0 {
+		labels = maps.Clone(systemLabels)
+		for i := 0; i+1 < len(metricLabels); i += 2 {
+			labels[metricLabels[i]] = metricLabels[i+1]
+		}
+	}
+	for i := range matchers {
+		if !matchers[i].matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// grafanaAnnotations handles POST /api/beszel/grafana/annotations. Returns
+// the user's alerts in the range, ending when they were resolved. A query in
+// the annotation limits them to a system or alert name.
+func (h *Hub) grafanaAnnotations(e *core.RequestEvent) error {
+	var data struct {
+		Range      grafanaRange `json:"range"`
+		Annotation struct {
+			Query string `json:"query"`
+		} `json:"annotation"`
+	}
+	if err := e.BindBody(&data); err != nil || !data.Range.From.Before(data.Range.To) {
+		return e.BadRequestError("Invalid range", err)
+	}
+	systems, err := h.grafanaSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	systemNames := make(map[string]string, len(systems))
+	for _, sys := range systems {
+		systemNames[sys.id] = sys.labels["system"]
+	}
+	records, err := e.App.FindRecordsByFilter("alerts_history",
+		"user = {:user} && created >= {:from} && created <= {:to}", "-created", maxGrafanaAnnotations, 0,
+		dbx.Params{
+			"user": e.Auth.Id,
+			"from": data.Range.From.UTC().Format(types.DefaultDateLayout),
+			"to":   data.Range.To.UTC().Format(types.DefaultDateLayout),
+		})
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	query := strings.TrimSpace(data.Annotation.Query)
+	annotations := []grafanaAnnotation{}
+	for _, record := range records {
+		systemName, ok := systemNames[record.GetString("system")]
+		if !ok {
+			continue
+		}
+		alertName := record.GetString("name")
+		if query != "" && query != systemName && query != alertName {
+			continue
+		}
+		annotation := grafanaAnnotation{
+			Time:  record.GetDateTime("created").Time().UnixMilli(),
+			Title: fmt.Sprintf("%s alert on %s", alertName, systemName),
+			Text:  "Active",
+			Tags:  []string{systemName, alertName},
+		}
+		if resolved := record.GetDateTime("resolved"); !resolved.IsZero() {
+			annotation.TimeEnd = resolved.Time().UnixMilli()
+			annotation.Text = fmt.Sprintf("Resolved after %s", resolved.Time().Sub(record.GetDateTime("created").Time()).Round(time.Second))
+		}
+		annotations = append(annotations, annotation)
+	}
+	return e.JSON(http.StatusOK, annotations)
+}
@@ -0,0 +1,209 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrafanaDataSource(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "grafana@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	createSystem := func(name, env, userId string) string {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":   name,
+			"host":   "127.0.0.1",
+			"users":  []string{userId},
+			"labels": map[string]string{"env": env},
+		})
+		require.NoError(t, err)
+		return record.Id
+	}
+	createStats := func(systemId string, age time.Duration, stats string) {
+		record, err := beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": systemId,
+			"type":   "1m",
+			"stats":  stats,
+		})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-age).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+	webId := createSystem("web1", "prod", user.Id)
+	dbId := createSystem("db1", "dev", user.Id)
+	hiddenId := createSystem("hidden", "prod", otherUser.Id)
+	for i := range 3 {
+		createStats(webId, time.Duration(i+1)*time.Minute, fmt.Sprintf(`{"cpu": %d, "t": {"cpu": 50, "nvme": 40}}`, 10+i))
+		createStats(dbId, time.Duration(i+1)*time.Minute, `{"cpu": 70, "t": {"cpu": 60}}`)
+		createStats(hiddenId, time.Duration(i+1)*time.Minute, `{"cpu": 99, "t": {"hidden_sensor": 60}}`)
+	}
+	alert, err := beszelTests.CreateRecord(hub, "alerts_history", map[string]any{"user": user.Id, "system": webId, "name": "CPU"})
+	require.NoError(t, err)
+	alert.SetRaw("created", now.Add(-10*time.Minute).Format(types.DefaultDateLayout))
+	alert.SetRaw("resolved", now.Add(-5*time.Minute).Format(types.DefaultDateLayout))
+	require.NoError(t, hub.SaveNoValidate(alert))
+
+	testAppFactory := func(t testing.TB) *tests.TestApp {
+		return hub.TestApp
+	}
+	queryBody := func(targets string, filters string) *strings.Reader {
+		return strings.NewReader(fmt.Sprintf(`{"range": {"from": %q, "to": %q}, "maxDataPoints": 500, "targets": [%s], "adhocFilters": [%s]}`,
+			now.Add(-30*time.Minute).Format(time.RFC3339), now.Add(time.Minute).Format(time.RFC3339), targets, filters))
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /grafana - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/grafana",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /grafana - connection test",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/grafana",
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"status":"ok"`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:               "POST /grafana/search - lists matching metric names",
+			Method:             http.MethodPost,
+			URL:                "/api/beszel/grafana/search",
+			Headers:            map[string]string{"Authorization": userToken},
+			Body:               strings.NewReader(`{"target": "cpu"}`),
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"beszel_cpu_usage_percent"`},
+			NotExpectedContent: []string{"memory"},
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:            "POST /grafana/metrics - lists metrics for the query editor",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/grafana/metrics",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            strings.NewReader(`{}`),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`{"label":"beszel_temperature_celsius","value":"beszel_temperature_celsius"}`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:               "POST /grafana/tag-keys - lists label names",
+			Method:             http.MethodPost,
+			URL:                "/api/beszel/grafana/tag-keys",
+			Headers:            map[string]string{"Authorization": userToken},
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"text":"env"`, `"text":"sensor"`, `"text":"system"`},
+			NotExpectedContent: []string{"hidden"},
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:               "POST /grafana/tag-values - lists values of visible systems",
+			Method:             http.MethodPost,
+			URL:                "/api/beszel/grafana/tag-values",
+			Headers:            map[string]string{"Authorization": userToken},
+			Body:               strings.NewReader(`{"key": "sensor"}`),
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`[{"text":"cpu"},{"text":"nvme"}]`},
+			NotExpectedContent: []string{"hidden_sensor"},
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:    "POST /grafana/query - series of each system",
+			Method:  http.MethodPost,
+			URL:     "/api/beszel/grafana/query",
+			Headers: map[string]string{"Authorization": userToken},
+			Body:    queryBody(`{"target": "beszel_cpu_usage_percent", "refId": "A"}`, ""),
+			ExpectedContent: []string{
+				`"target":"beszel_cpu_usage_percent{system=\"web1\"}","datapoints":[[12,`,
+				`"target":"beszel_cpu_usage_percent{system=\"db1\"}","datapoints":[[70,`,
+			},
+			NotExpectedContent: []string{"hidden"},
+			ExpectedStatus:     200,
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:    "POST /grafana/query - label matchers select series",
+			Method:  http.MethodPost,
+			URL:     "/api/beszel/grafana/query",
+			Headers: map[string]string{"Authorization": userToken},
+			Body:    queryBody(`{"target": "temperature_celsius{sensor=\"cpu\", system=~\"web.*\"}"}`, ""),
+			ExpectedContent: []string{
+				`"target":"beszel_temperature_celsius{system=\"web1\",sensor=\"cpu\"}","datapoints":[[50,`,
+			},
+			NotExpectedContent: []string{"nvme", "db1"},
+			ExpectedStatus:     200,
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:               "POST /grafana/query - ad hoc filters match system labels",
+			Method:             http.MethodPost,
+			URL:                "/api/beszel/grafana/query",
+			Headers:            map[string]string{"Authorization": userToken},
+			Body:               queryBody(`{"target": "beszel_cpu_usage_percent"}, {"target": "beszel_cpu_usage_percent", "hide": true}`, `{"key": "env", "operator": "!=", "value": "prod"}`),
+			ExpectedContent:    []string{`[{"target":"beszel_cpu_usage_percent{system=\"db1\"}"`},
+			NotExpectedContent: []string{"web1"},
+			ExpectedStatus:     200,
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:            "POST /grafana/query - invalid target",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/grafana/query",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            queryBody(`{"target": "beszel_cpu_usage_percent{system=web1}"}`, ""),
+			ExpectedContent: []string{"Invalid label matchers"},
+			ExpectedStatus:  400,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "POST /grafana/query - invalid range",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/grafana/query",
+			Headers:         map[string]string{"Authorization": userToken},
+			Body:            strings.NewReader(`{"targets": [{"target": "beszel_cpu_usage_percent"}]}`),
+			ExpectedContent: []string{"Invalid range"},
+			ExpectedStatus:  400,
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:    "POST /grafana/annotations - alerts in the range",
+			Method:  http.MethodPost,
+			URL:     "/api/beszel/grafana/annotations",
+			Headers: map[string]string{"Authorization": userToken},
+			Body: strings.NewReader(fmt.Sprintf(`{"range": {"from": %q, "to": %q}, "annotation": {"query": "web1"}}`,
+				now.Add(-time.Hour).Format(time.RFC3339), now.Format(time.RFC3339))),
+			ExpectedContent: []string{`"title":"CPU alert on web1"`, `"text":"Resolved after 5m0s"`, `"tags":["web1","CPU"]`, `"timeEnd":`},
+			ExpectedStatus:  200,
+			TestAppFactory:  testAppFactory,
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -4,7 +4,13 @@ package hub
 import (
 	"beszel"
 	"beszel/internal/alerts"
+	"beszel/internal/entities/system"
 	"beszel/internal/hub/config"
+	"beszel/internal/hub/influxdb"
+	"beszel/internal/hub/masking"
+	"beszel/internal/hub/pki"
+	"beszel/internal/hub/remotewrite"
+	"beszel/internal/hub/storage"
 	"beszel/internal/hub/systems"
 	"beszel/internal/records"
 	"beszel/internal/users"
@@ -31,12 +37,24 @@ import (
 type Hub struct {
 	core.App
 	*alerts.AlertManager
-	um     *users.UserManager
-	rm     *records.RecordManager
-	sm     *systems.SystemManager
-	pubKey string
-	signer ssh.Signer
-	appURL string
+	um          *users.UserManager
+	rm          *records.RecordManager
+	sm          *systems.SystemManager
+	storage     storage.Driver
+	remoteWrite *remotewrite.Exporter
+	influx      *influxdb.Exporter
+	versions    *agentVersionChecker
+	dataQuality dataQuality
+	pubKey      string
+	signer      ssh.Signer
+	appURL      string
+	replication *replicationMode // nil unless REPLICATION_MODE is enabled
+	quota       *apiQuota        // API request counters and limits of each user
+	mask        *masking.Policy  // masks names shared outside the hub, nil if MASK is not set
+	oidc        *oidcConfig      // OIDC single sign-on, nil if OIDC_ISSUER is not set
+	chunkSize   int              // max WebSocket message size requested from agents
+	mtls        *pki.CA          // CA of mTLS connections to agents, nil if MTLS is not enabled
+	live        *liveStream      // WebSocket stream of system updates
 }
 
 // NewHub creates a new Hub instance with default configuration
@@ -47,11 +65,38 @@ func NewHub(app core.App) *Hub {
 	hub.AlertManager = alerts.NewAlertManager(hub)
 	hub.um = users.NewUserManager(hub)
 	hub.rm = records.NewRecordManager(hub)
+	hub.storage = hub.rm
 	hub.sm = systems.NewSystemManager(hub)
+	hub.versions = newAgentVersionChecker()
 	hub.appURL, _ = GetEnv("APP_URL")
+	hub.replication = newReplicationMode(hub)
+	hub.chunkSize = getChunkSize()
 	return hub
 }
 
+// Storage returns the driver that stores stats records
+func (h *Hub) Storage() storage.Driver {
+	return h.storage
+}
+
+// SetStorage replaces the default PocketBase storage driver. Must be called before StartHub.
+func (h *Hub) SetStorage(driver storage.Driver) {
+	h.storage = driver
+}
+
+// ExportStats queues the one minute stats of a system for the InfluxDB and
+// remote write exporters, if enabled. It's called after the stats are saved,
+// so stats are exported with any storage driver.
+func (h *Hub) ExportStats(systemRecord *core.Record, stats *system.Stats, created time.Time) {
+	name := systemRecord.GetString("name")
+	if h.remoteWrite != nil {
+		h.remoteWrite.AddStats(systemRecord.Id, name, stats, created)
+	}
+	if h.influx != nil {
+		h.influx.AddStats(systemRecord.Id, name, stats, created)
+	}
+}
+
 // GetEnv retrieves an environment variable with a "BESZEL_HUB_" prefix, or falls back to the unprefixed key.
 func GetEnv(key string) (value string, exists bool) {
 	if value, exists = os.LookupEnv("BESZEL_HUB_" + key); exists {
@@ -62,6 +107,16 @@ func GetEnv(key string) (value string, exists bool) {
 }
 
 func (h *Hub) StartHub() error {
+	// mask names in exports, the kiosk feed and for readonly users if MASK is set
+	var err error
+	if h.mask, err = h.newMaskingPolicy(); err != nil {
+		return err
+	}
+	// sign in with OIDC if OIDC_ISSUER is set
+	if h.oidc, err = newOIDCConfig(); err != nil {
+		return err
+	}
+
 	h.App.OnServe().BindFunc(func(e *core.ServeEvent) error {
 		// initialize settings / collections
 		if err := h.initialize(e); err != nil {
@@ -94,6 +149,47 @@ func (h *Hub) StartHub() error {
 	// handle default values for user / user_settings creation
 	h.App.OnRecordCreate("users").BindFunc(h.um.InitializeUserRole)
 	h.App.OnRecordCreate("user_settings").BindFunc(h.um.InitializeUserSettings)
+	// map OIDC groups to roles and teams
+	h.App.OnRecordAuthWithOAuth2Request("users").BindFunc(h.onOAuth2Login)
+	// record changes and logins in the audit log
+	h.bindAuditLog()
+
+	// validate new share links and generate their token
+	h.App.OnRecordCreateRequest("share_links").BindFunc(validateShareLink)
+	// check the systems and sensors of new and updated status pages
+	h.App.OnRecordCreateRequest("status_pages").BindFunc(validateStatusPage)
+	h.App.OnRecordUpdateRequest("status_pages").BindFunc(validateStatusPage)
+	// validate new API keys and generate their token
+	h.App.OnRecordCreateRequest("api_keys").BindFunc(validateAPIKey)
+	// validate new enrollment tokens and generate their token
+	h.App.OnRecordCreateRequest("enrollment_tokens").BindFunc(validateEnrollmentToken)
+	// teams: admins are members, and users can only assign systems to their teams
+	h.App.OnRecordCreate("teams").BindFunc(includeTeamAdmins)
+	h.App.OnRecordUpdate("teams").BindFunc(includeTeamAdmins)
+	h.App.OnRecordCreateRequest("teams").BindFunc(validateTeam)
+	h.App.OnRecordUpdateRequest("teams").BindFunc(validateTeam)
+	h.App.OnRecordCreateRequest("systems").BindFunc(validateSystemTeam)
+	h.App.OnRecordUpdateRequest("systems").BindFunc(validateSystemTeam)
+	// record status changes of systems for uptime
+	h.App.OnRecordUpdate("systems").BindFunc(records.TrackStatusChanges)
+
+	// don't checkpoint the database for backups during replication snapshots
+	if h.replication != nil {
+		h.App.OnBackupCreate().BindFunc(h.replication.blockPausedBackups)
+	}
+
+	// mask names sent to readonly users
+	if h.mask != nil {
+		h.App.OnRecordEnrich("systems", "container_stats").BindFunc(h.maskReadonlyRecord)
+	}
+
+	// stream system updates to WebSocket clients
+	h.live = newLiveStream(h)
+	h.live.Start()
+
+	// forward stats to a Prometheus remote write endpoint and InfluxDB
+	h.startRemoteWrite()
+	h.startInfluxExport()
 
 	if pb, ok := h.App.(*pocketbase.PocketBase); ok {
 		// log.Println("Starting pocketbase")
@@ -119,6 +215,19 @@ func (h *Hub) initialize(e *core.ServeEvent) error {
 	if err := e.App.Save(settings); err != nil {
 		return err
 	}
+	// keep stats longer than displayed in the UI if STATS_RETENTION env is set
+	var retention map[string]time.Duration
+	if value, exists := GetEnv("STATS_RETENTION"); exists {
+		var err error
+		if retention, err = records.ParseRetention(value); err != nil {
+			return err
+		}
+		h.rm.SetRetention(retention)
+	}
+	// store stats in PostgreSQL if STATS_DB env is set
+	if err := h.initStatsDB(retention); err != nil {
+		return err
+	}
 	// set auth settings
 	usersCollection, err := e.App.FindCollectionByNameOrId("users")
 	if err != nil {
@@ -132,8 +241,11 @@ func (h *Hub) initialize(e *core.ServeEvent) error {
 	if usersCollection.OAuth2.Enabled {
 		usersCollection.OAuth2.Enabled = len(usersCollection.OAuth2.Providers) > 0
 	}
-	// allow oauth user creation if USER_CREATION is set
-	if userCreation, _ := GetEnv("USER_CREATION"); userCreation == "true" {
+	// add the OIDC provider if OIDC_ISSUER is set
+	h.initOIDC(usersCollection)
+	// allow oauth user creation if USER_CREATION or OIDC_ISSUER is set. Only
+	// OIDC users are created if USER_CREATION is not set.
+	if userCreation, _ := GetEnv("USER_CREATION"); userCreation == "true" || h.oidc != nil {
 		cr := "@request.context = 'oauth2'"
 		usersCollection.CreateRule = &cr
 	} else {
@@ -149,11 +261,14 @@ func (h *Hub) initialize(e *core.ServeEvent) error {
 	}
 	shareAllSystems, _ := GetEnv("SHARE_ALL_SYSTEMS")
 	systemsReadRule := "@request.auth.id != \"\""
+	updateDeleteRule := systemsReadRule
 	if shareAllSystems != "true" {
-		// default is to only show systems that the user id is assigned to
-		systemsReadRule += " && users.id ?= @request.auth.id"
+		// default is to only show systems that the user or the user's team is assigned to.
+		// Operators of the team can view the system, but not change it.
+		systemsReadRule += " && (users.id ?= @request.auth.id || team.members.id ?= @request.auth.id || team.operators.id ?= @request.auth.id)"
+		updateDeleteRule += " && (users.id ?= @request.auth.id || team.members.id ?= @request.auth.id)"
 	}
-	updateDeleteRule := systemsReadRule + " && @request.auth.role != \"readonly\""
+	updateDeleteRule += " && @request.auth.role != \"readonly\""
 	systemsCollection.ListRule = &systemsReadRule
 	systemsCollection.ViewRule = &systemsReadRule
 	systemsCollection.UpdateRule = &updateDeleteRule
@@ -161,7 +276,8 @@ func (h *Hub) initialize(e *core.ServeEvent) error {
 	if err := e.App.Save(systemsCollection); err != nil {
 		return err
 	}
-	return nil
+	// connect to agents over mTLS if MTLS is set
+	return h.initMTLS()
 }
 
 // startServer sets up the server for Beszel
@@ -203,6 +319,12 @@ func (h *Hub) startServer(se *core.ServeEvent) error {
 					return serveStatic(e)
 				}
 			}
+			// the root of a status page's custom domain redirects to the page
+			if path := e.Request.URL.Path; path == "/" || path == basePath {
+				if slug, ok := statusPageForHost(e.App, e.Request); ok {
+					return e.Redirect(http.StatusFound, basePath+"status/"+slug)
+				}
+			}
 			if cspExists {
 				e.Response.Header().Del("X-Frame-Options")
 				e.Response.Header().Set("Content-Security-Policy", csp)
@@ -215,10 +337,63 @@ func (h *Hub) startServer(se *core.ServeEvent) error {
 
 // registerCronJobs sets up scheduled tasks
 func (h *Hub) registerCronJobs(_ *core.ServeEvent) error {
-	// delete old system_stats and alerts_history records once every hour
-	h.Cron().MustAdd("delete old records", "8 * * * *", h.rm.DeleteOldRecords)
+	// delete old stats, alerts_history, system_events, status_changes, sensor_rollups, maintenance_windows and audit_log records once every hour
+	h.Cron().MustAdd("delete old records", "8 * * * *", func() {
+		if err := h.storage.DeleteOld(); err != nil {
+			h.Logger().Error("Failed to delete old stats", "err", err)
+		}
+		if err := h.rm.DeleteOldAlertsHistory(); err != nil {
+			h.Logger().Error("Failed to delete old alerts history", "err", err)
+		}
+		if err := h.rm.DeleteOldSystemEvents(); err != nil {
+			h.Logger().Error("Failed to delete old system events", "err", err)
+		}
+		if err := h.rm.DeleteOldStatusChanges(); err != nil {
+			h.Logger().Error("Failed to delete old status changes", "err", err)
+		}
+		if err := h.rm.DeleteOldSensorRollups(); err != nil {
+			h.Logger().Error("Failed to delete old sensor rollups", "err", err)
+		}
+		if err := h.DeleteEndedMaintenanceWindows(); err != nil {
+			h.Logger().Error("Failed to delete ended maintenance windows", "err", err)
+		}
+		if err := h.deleteOldAuditLog(); err != nil {
+			h.Logger().Error("Failed to delete old audit log entries", "err", err)
+		}
+	})
 	// create longer records every 10 minutes
-	h.Cron().MustAdd("create longer records", "*/10 * * * *", h.rm.CreateLongerRecords)
+	h.Cron().MustAdd("create longer records", "*/10 * * * *", func() {
+		if err := h.storage.Downsample(); err != nil {
+			h.Logger().Error("Failed to create longer records", "err", err)
+		}
+	})
+	// check the last hour of records for gaps and impossible values
+	h.Cron().MustAdd("check data quality", "23 * * * *", func() {
+		if err := h.checkDataQuality(); err != nil {
+			h.Logger().Error("Data quality check failed", "err", err)
+		}
+	})
+	// project disk usage for DiskFull alerts every hour
+	h.Cron().MustAdd("forecast disk usage", "41 * * * *", func() {
+		if err := h.CheckDiskForecasts(); err != nil {
+			h.Logger().Error("Failed to forecast disk usage", "err", err)
+		}
+	})
+	// check uptime of systems against the SLA targets of Uptime alerts
+	h.Cron().MustAdd("check uptime", "*/10 * * * *", func() {
+		if err := h.CheckUptimeAlerts(); err != nil {
+			h.Logger().Error("Failed to check uptime alerts", "err", err)
+		}
+	})
+	// email daily digest reports, and weekly ones on Mondays
+	h.Cron().MustAdd("send digest reports", "0 7 * * *", func() {
+		if err := h.sendDigests(time.Now().UTC()); err != nil {
+			h.Logger().Error("Failed to send digest reports", "err", err)
+		}
+	})
+	if h.replication != nil {
+		h.replication.registerCronJobs()
+	}
 	return nil
 }
 
@@ -229,10 +404,19 @@ func (h *Hub) registerApiRoutes(se *core.ServeEvent) error {
 	apiAuth.Bind(apis.RequireAuth())
 	// auth optional routes
 	apiNoAuth := se.Router.Group("/api/beszel")
+	// count and limit API requests of each user
+	if h.quota == nil {
+		var err error
+		if h.quota, err = newAPIQuota(); err != nil {
+			return err
+		}
+	}
+	se.Router.Bind(h.quota.middleware())
+	apiAuth.GET("/api-usage", h.quota.handleUsage)
 
 	// create first user endpoint only needed if no users exist
 	if totalUsers, _ := se.App.CountRecords("users"); totalUsers == 0 {
-		apiNoAuth.POST("/create-user", h.um.CreateFirstUser)
+		apiNoAuth.POST("/create-user", h.um.CreateFirstUser).BindFunc(auditRoute("users.create_first"))
 	}
 	// check if first time setup on login page
 	apiNoAuth.GET("/first-run", func(e *core.RequestEvent) error {
@@ -245,6 +429,7 @@ func (h *Hub) registerApiRoutes(se *core.ServeEvent) error {
 	})
 	// send test notification
 	apiAuth.POST("/test-notification", h.SendTestNotification)
+	apiAuth.POST("/test-route", h.SendTestRoute)
 	// get config.yml content
 	apiAuth.GET("/config-yaml", config.GetYamlConfig)
 	// handle agent websocket connection
@@ -252,8 +437,69 @@ func (h *Hub) registerApiRoutes(se *core.ServeEvent) error {
 	// get or create universal tokens
 	apiAuth.GET("/universal-token", h.getUniversalToken)
 	// update / delete user alerts
-	apiAuth.POST("/user-alerts", alerts.UpsertUserAlerts)
-	apiAuth.DELETE("/user-alerts", alerts.DeleteUserAlerts)
+	apiAuth.POST("/user-alerts", alerts.UpsertUserAlerts).BindFunc(auditRoute("alerts.upsert"))
+	apiAuth.DELETE("/user-alerts", alerts.DeleteUserAlerts).BindFunc(auditRoute("alerts.delete_many"))
+	// export / import alert rules as a bundle
+	apiAuth.GET("/alert-bundle", alerts.ExportAlertBundle)
+	apiAuth.POST("/alert-bundle", alerts.ImportAlertBundle).BindFunc(auditRoute("alerts.import"))
+	// acknowledge alerts and get analytics of the alert history
+	apiAuth.POST("/alert-history/ack", alerts.AcknowledgeAlerts).BindFunc(auditRoute("alerts.acknowledge"))
+	apiAuth.GET("/alert-analytics", alerts.GetAlertAnalytics)
+	// get or email a daily or weekly digest report of the user's systems
+	apiAuth.GET("/digest", h.getDigest)
+	apiAuth.POST("/test-digest", h.sendTestDigest)
+	// get aggregated stats for all systems
+	apiAuth.GET("/fleet-overview", h.getFleetOverview)
+	// compare inventory facts across systems
+	apiAuth.GET("/drift", h.getDriftReport)
+	// get agent version distribution and outdated agents
+	apiAuth.GET("/agent-versions", h.getAgentVersions)
+	// get data quality report of the last hour of records
+	apiAuth.GET("/data-quality", h.getDataQuality)
+	// get stats records of a system for charts
+	apiAuth.GET("/stats", h.getStats)
+	// get uptime of a system over the last 24 hours to 90 days
+	apiAuth.GET("/uptime", h.getUptime)
+	// get daily min, max and rate of change of a system's sensors
+	apiAuth.GET("/sensor-summary", h.getSensorSummary)
+	// search systems, containers and sensors by name
+	apiAuth.GET("/search", h.getSearch)
+	// Grafana JSON data source
+	apiAuth.GET("/grafana", h.grafanaTestConnection)
+	apiAuth.POST("/grafana/search", h.grafanaSearch)
+	apiAuth.POST("/grafana/metrics", h.grafanaMetrics)
+	apiAuth.POST("/grafana/query", h.grafanaQuery)
+	apiAuth.POST("/grafana/tag-keys", h.grafanaTagKeys)
+	apiAuth.POST("/grafana/tag-values", h.grafanaTagValues)
+	apiAuth.POST("/grafana/annotations", h.grafanaAnnotations)
+	// members of teams by email
+	apiAuth.GET("/teams/{id}/members", listTeamMembers)
+	apiAuth.POST("/teams/{id}/members", addTeamMember).BindFunc(auditRoute("teams.add_member"))
+	apiAuth.DELETE("/teams/{id}/members/{user}", removeTeamMember).BindFunc(auditRoute("teams.remove_member"))
+	// query and export the audit log
+	apiAuth.GET("/audit-log", getAuditLog)
+	// stats from devices that can't run the agent, authenticated by system token
+	apiNoAuth.POST("/ingest", h.postIngest)
+	// read-only wallboard feed, enabled with KIOSK_TOKEN
+	if kiosk := newKioskConfig(h.storage, h.mask); kiosk != nil {
+		apiNoAuth.GET("/kiosk", kiosk.handleKiosk)
+	}
+	// public status page of selected systems
+	apiNoAuth.GET("/status/{slug}", h.getStatusPage)
+	// read-only view of a system shared with a share link
+	apiNoAuth.GET("/share/{token}", h.getSharedSystem)
+	// versioned REST API, authenticated with API keys or auth tokens
+	h.registerApiV1Routes(se)
+	// mTLS CA certificate, revocation list, and agent certificates
+	h.registerMTLSRoutes(se)
+	// pause and resume checkpoints for replication snapshots
+	if h.replication != nil {
+		h.replication.registerApiRoutes(se)
+	}
+	// database snapshots for standby hubs, enabled with STANDBY_TOKEN
+	if token, _ := GetEnv("STANDBY_TOKEN"); token != "" {
+		apiNoAuth.GET("/standby/snapshot", h.getStandbySnapshot)
+	}
 
 	return nil
 }
@@ -278,8 +524,10 @@ func (h *Hub) getUniversalToken(e *core.RequestEvent) error {
 	switch query.Get("enable") {
 	case "1":
 		tokenMap.Set(token, userID, time.Hour)
+		audit(e, e.Auth, "universal_token.enable", nil, nil)
 	case "0":
 		tokenMap.RemovebyValue(userID)
+		audit(e, e.Auth, "universal_token.disable", nil, nil)
 	}
 	_, response["active"] = tokenMap.GetOk(token)
 	return e.JSON(http.StatusOK, response)
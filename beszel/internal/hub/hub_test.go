@@ -331,6 +331,48 @@ func TestApiRoutesAuthentication(t *testing.T) {
 			ExpectedStatus:  200,
 			ExpectedContent: []string{"sending message"},
 		},
+		{
+			Name:            "POST /test-route - no auth should fail",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/test-route",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+			Body: jsonReader(map[string]any{
+				"webhooks": []string{"generic://127.0.0.1"},
+			}),
+		},
+		{
+			Name:           "POST /test-route - with auth should send to the route",
+			Method:         http.MethodPost,
+			URL:            "/api/beszel/test-route",
+			TestAppFactory: testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+			Body: jsonReader(map[string]any{
+				"labels":     map[string]string{"env": "prod"},
+				"severities": []string{"critical"},
+				"webhooks":   []string{"generic://127.0.0.1"},
+			}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{"generic: ", "sending message"},
+		},
+		{
+			Name:           "POST /test-route - invalid severity should fail",
+			Method:         http.MethodPost,
+			URL:            "/api/beszel/test-route",
+			TestAppFactory: testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+			Body: jsonReader(map[string]any{
+				"severities": []string{"urgent"},
+				"webhooks":   []string{"generic://127.0.0.1"},
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid severity: urgent"},
+		},
 		{
 			Name:            "GET /config-yaml - no auth should fail",
 			Method:          http.MethodGet,
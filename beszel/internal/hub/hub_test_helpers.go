@@ -3,7 +3,12 @@
 
 package hub
 
-import "beszel/internal/hub/systems"
+import (
+	"beszel/internal/hub/influxdb"
+	"beszel/internal/hub/remotewrite"
+	"beszel/internal/hub/systems"
+	"time"
+)
 
 // TESTING ONLY: GetSystemManager returns the system manager
 func (h *Hub) GetSystemManager() *systems.SystemManager {
@@ -19,3 +24,28 @@ func (h *Hub) GetPubkey() string {
 func (h *Hub) SetPubkey(pubkey string) {
 	h.pubKey = pubkey
 }
+
+// TESTING ONLY: GetRemoteWrite returns the remote write exporter
+func (h *Hub) GetRemoteWrite() *remotewrite.Exporter {
+	return h.remoteWrite
+}
+
+// TESTING ONLY: GetInfluxExporter returns the InfluxDB exporter
+func (h *Hub) GetInfluxExporter() *influxdb.Exporter {
+	return h.influx
+}
+
+// TESTING ONLY: FuzzyScore scores how well a name matches a lowercase query
+func FuzzyScore(q, name string) int {
+	return fuzzyScore(q, name)
+}
+
+// TESTING ONLY: SendDigests sends the digest reports due at a time
+func (h *Hub) SendDigests(now time.Time) error {
+	return h.sendDigests(now)
+}
+
+// TESTING ONLY: CheckDataQuality runs the hourly data quality check
+func (h *Hub) CheckDataQuality() error {
+	return h.checkDataQuality()
+}
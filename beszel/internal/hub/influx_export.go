@@ -0,0 +1,32 @@
+package hub
+
+import "beszel/internal/hub/influxdb"
+
+// newInfluxConfig returns the InfluxDB config, or nil if INFLUXDB_URL is not set.
+func newInfluxConfig() *influxdb.Config {
+	url, _ := GetEnv("INFLUXDB_URL")
+	if url == "" {
+		return nil
+	}
+	config := &influxdb.Config{URL: url}
+	config.Org, _ = GetEnv("INFLUXDB_ORG")
+	config.Bucket, _ = GetEnv("INFLUXDB_BUCKET")
+	config.Token, _ = GetEnv("INFLUXDB_TOKEN")
+	return config
+}
+
+// startInfluxExport writes system stats to INFLUXDB_URL, if set.
+func (h *Hub) startInfluxExport() {
+	config := newInfluxConfig()
+	if config == nil {
+		return
+	}
+	if config.Bucket == "" {
+		h.Logger().Error("INFLUXDB_BUCKET is required to export stats to InfluxDB")
+		return
+	}
+	h.Logger().Info("Exporting stats to InfluxDB", "url", config.URL, "bucket", config.Bucket)
+	config.Mask = h.mask
+	h.influx = influxdb.New(h, *config)
+	h.influx.Start()
+}
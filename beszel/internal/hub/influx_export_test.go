@@ -0,0 +1,61 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfluxExport(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body += string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	t.Setenv("BESZEL_HUB_INFLUXDB_URL", server.URL)
+	t.Setenv("BESZEL_HUB_INFLUXDB_ORG", "org")
+	t.Setenv("BESZEL_HUB_INFLUXDB_BUCKET", "bucket")
+
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+	hub.StartHub()
+	exporter := hub.GetInfluxExporter()
+	require.NotNil(t, exporter)
+
+	user, err := beszelTests.CreateUser(hub, "test@example.com", "password123")
+	require.NoError(t, err)
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "web-server",
+		"host":  "127.0.0.1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+	// stats are exported when they are added, with any storage driver
+	hub.ExportStats(systemRecord, &system.Stats{Cpu: 42}, time.Now())
+
+	require.NoError(t, exporter.Flush())
+	assert.Contains(t, body, "beszel,system=web-server,system_id="+systemRecord.Id+" cpu_usage_percent=42,")
+	assert.Equal(t, 1, countOccurrences([]byte(body), "cpu_usage_percent"))
+}
+
+func TestInfluxExportRequiresBucket(t *testing.T) {
+	t.Setenv("BESZEL_HUB_INFLUXDB_URL", "http://localhost:8086")
+
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+	hub.StartHub()
+	assert.Nil(t, hub.GetInfluxExporter())
+}
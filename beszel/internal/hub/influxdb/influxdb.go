@@ -0,0 +1,211 @@
+// Package influxdb writes system stats to InfluxDB v2 using the line protocol.
+package influxdb
+
+import (
+	"beszel"
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/masking"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// measurement is the InfluxDB measurement for all stats
+	measurement = "beszel"
+	// flushInterval is how often queued lines are written
+	flushInterval = 10 * time.Second
+	// maxBatchLines is the max number of lines in one write request
+	maxBatchLines = 5_000
+	// maxPending is the max number of lines kept while InfluxDB is unavailable
+	maxPending = 100_000
+)
+
+// Config holds the InfluxDB settings.
+type Config struct {
+	URL    string          // Server URL, e.g. http://localhost:8086
+	Org    string          // Organization name or id
+	Bucket string          // Bucket name or id
+	Token  string          // API token with write access to the bucket
+	Mask   *masking.Policy // Masks system names, nil to write them as is
+}
+
+// Exporter writes system stats to InfluxDB in batches.
+type Exporter struct {
+	app    core.App
+	config Config
+	client *http.Client
+	mu     sync.Mutex
+	queue  []string
+	done   chan struct{}
+}
+
+// New creates an exporter. Call Start to begin writing stats.
+func New(app core.App, config Config) *Exporter {
+	return &Exporter{
+		app:    app,
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+		done:   make(chan struct{}),
+	}
+}
+
+// Start writes stats added with AddStats until the app terminates.
+func (e *Exporter) Start() {
+	e.app.OnTerminate().BindFunc(func(te *core.TerminateEvent) error {
+		close(e.done)
+		return te.Next()
+	})
+	go e.run()
+}
+
+// run writes queued lines every flushInterval, and once more on shutdown.
+func (e *Exporter) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.done:
+			_ = e.Flush()
+			return
+		}
+		if err := e.Flush(); err != nil {
+			e.app.Logger().Error("InfluxDB write failed", "err", err)
+		}
+	}
+}
+
+// AddStats queues the one minute stats of a system, saved at t.
+func (e *Exporter) AddStats(systemId, systemName string, stats *system.Stats, t time.Time) {
+	e.Enqueue(Lines(systemId, e.config.Mask.Hostname(systemName), stats, t))
+}
+
+// Lines converts stats to line protocol. Metrics with the same labels are
+// written as fields of one line, tagged with the labels and the system.
+func Lines(systemId, systemName string, stats *system.Stats, t time.Time) []string {
+	type point struct {
+		tags   string
+		fields []string
+	}
+	var points []*point
+	byTags := make(map[string]*point)
+	for _, metric := range stats.Metrics() {
+		tags := append([]string{"system", systemName, "system_id", systemId}, metric.Labels...)
+		key := formatTags(tags)
+		p, ok := byTags[key]
+		if !ok {
+			p = &point{tags: key}
+			byTags[key] = p
+			points = append(points, p)
+		}
+		p.fields = append(p.fields, escape(metric.Name, ",= ")+"="+strconv.FormatFloat(metric.Value, 'f', -1, 64))
+	}
+	timestamp := strconv.FormatInt(t.UnixNano(), 10)
+	lines := make([]string, 0, len(points))
+	for _, p := range points {
+		lines = append(lines, measurement+p.tags+" "+strings.Join(p.fields, ",")+" "+timestamp)
+	}
+	return lines
+}
+
+// formatTags formats name, value pairs as sorted line protocol tags, skipping empty values.
+func formatTags(pairs []string) string {
+	tags := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			tags = append(tags, escape(pairs[i], ",= ")+"="+escape(pairs[i+1], ",= "))
+		}
+	}
+	sort.Strings(tags)
+	if len(tags) == 0 {
+		return ""
+	}
+	return "," + strings.Join(tags, ",")
+}
+
+// escape escapes backslashes and the given special characters.
+func escape(s, chars string) string {
+	if !strings.ContainsAny(s, chars+`\`) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\\' || strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Enqueue adds lines to be written on the next flush, dropping the oldest
+// lines if InfluxDB has been unavailable for too long.
+func (e *Exporter) Enqueue(lines []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.queue = append(e.queue, lines...)
+	if over := len(e.queue) - maxPending; over > 0 {
+		e.queue = slices.Delete(e.queue, 0, over)
+	}
+}
+
+// Flush writes all queued lines in batches. Unwritten lines are kept for the
+// next flush if a request fails with a network or server error.
+func (e *Exporter) Flush() error {
+	e.mu.Lock()
+	lines := e.queue
+	e.queue = nil
+	e.mu.Unlock()
+
+	for len(lines) > 0 {
+		batch := lines[:min(len(lines), maxBatchLines)]
+		retry, err := e.write(batch)
+		if err != nil {
+			if retry {
+				e.mu.Lock()
+				e.queue = append(lines, e.queue...)
+				e.mu.Unlock()
+			}
+			return err
+		}
+		lines = lines[len(batch):]
+	}
+	return nil
+}
+
+// write sends lines to the write API and reports whether a failure can be retried.
+func (e *Exporter) write(lines []string) (retry bool, err error) {
+	query := url.Values{"org": {e.config.Org}, "bucket": {e.config.Bucket}, "precision": {"ns"}}
+	writeURL := strings.TrimSuffix(e.config.URL, "/") + "/api/v2/write?" + query.Encode()
+	req, err := http.NewRequest(http.MethodPost, writeURL, strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", beszel.AppName+"/"+beszel.Version)
+	if e.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+e.config.Token)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests,
+			fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return false, nil
+}
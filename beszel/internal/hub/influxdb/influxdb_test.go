@@ -0,0 +1,96 @@
+//go:build testing
+// +build testing
+
+package influxdb
+
+import (
+	"beszel/internal/entities/system"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLines(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	lines := Lines("abc123", "web server", &system.Stats{
+		Cpu:          12.5,
+		MemPct:       50,
+		Temperatures: map[string]float64{"cpu,die": 55},
+	}, now)
+
+	require.NotEmpty(t, lines)
+	assert.True(t, strings.HasPrefix(lines[0], `beszel,system=web\ server,system_id=abc123 cpu_usage_percent=12.5,`), lines[0])
+	assert.Contains(t, lines[0], ",memory_usage_percent=50,")
+	assert.True(t, strings.HasSuffix(lines[0], " 1700000000000000000"))
+	assert.Contains(t, lines, `beszel,period=1m,system=web\ server,system_id=abc123 load_average=0 1700000000000000000`)
+	assert.Contains(t, lines, `beszel,filesystem=root,system=web\ server,system_id=abc123 filesystem_size_bytes=0,filesystem_used_bytes=0,disk_read_bytes_per_second=0,disk_write_bytes_per_second=0 1700000000000000000`,
+		"metrics with the same labels share a line")
+	assert.Contains(t, lines, `beszel,sensor=cpu\,die,system=web\ server,system_id=abc123 temperature_celsius=55 1700000000000000000`)
+}
+
+func TestFormatTags(t *testing.T) {
+	assert.Equal(t, "", formatTags(nil))
+	assert.Equal(t, ",a=1,b=x\\=y", formatTags([]string{"b", "x=y", "a", "1", "empty", ""}))
+}
+
+func TestFlush(t *testing.T) {
+	var received []string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/write", r.URL.Path)
+		assert.Equal(t, "my-org", r.URL.Query().Get("org"))
+		assert.Equal(t, "stats", r.URL.Query().Get("bucket"))
+		assert.Equal(t, "ns", r.URL.Query().Get("precision"))
+		assert.Equal(t, "Token secret", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		if status/100 == 2 {
+			received = append(received, strings.Split(string(body), "\n")...)
+		}
+		w.WriteHeader(status)
+		if status == http.StatusBadRequest {
+			w.Write([]byte(`{"code":"invalid","message":"bad line"}`))
+		}
+	}))
+	defer server.Close()
+
+	e := New(nil, Config{URL: server.URL + "/", Org: "my-org", Bucket: "stats", Token: "secret"})
+	assert.NoError(t, e.Flush(), "nothing to write")
+
+	// server errors are retried on the next flush
+	status = http.StatusServiceUnavailable
+	e.Enqueue([]string{"a", "b"})
+	assert.Error(t, e.Flush())
+	assert.Len(t, e.queue, 2)
+
+	status = http.StatusNoContent
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, []string{"a", "b"}, received)
+	assert.Empty(t, e.queue)
+
+	// client errors are dropped
+	status = http.StatusBadRequest
+	e.Enqueue([]string{"c"})
+	assert.ErrorContains(t, e.Flush(), "bad line")
+	assert.Empty(t, e.queue)
+
+	// large queues are written in batches
+	status = http.StatusNoContent
+	received = nil
+	e.Enqueue(make([]string, maxBatchLines+1))
+	assert.NoError(t, e.Flush())
+	assert.Len(t, received, maxBatchLines+1)
+}
+
+func TestEnqueueLimit(t *testing.T) {
+	e := New(nil, Config{})
+	e.Enqueue(make([]string, maxPending))
+	e.Enqueue([]string{"newest"})
+	require.Len(t, e.queue, maxPending)
+	assert.Equal(t, "newest", e.queue[maxPending-1], "oldest lines are dropped")
+}
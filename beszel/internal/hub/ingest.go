@@ -0,0 +1,66 @@
+package hub
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/ws"
+	"cmp"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ingestEntry is a payload posted to the ingest API, with the same schema as
+// the data sent by agents, and the token of the system it belongs to.
+type ingestEntry struct {
+	Token string `json:"token"`
+	system.CombinedData
+}
+
+// ingestResult is the result of an entry posted to the ingest API
+type ingestResult struct {
+	System string `json:"system,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// postIngest handles POST /api/beszel/ingest, which accepts a JSON array of
+// stats payloads from devices that can't run the agent. Each entry is saved
+// for the system with its token, or the X-Token header if it has none, at the
+// system's next update instead of polling the agent. If there are several
+// entries for a system, the last is saved.
+//
+// The response has a result for each entry, in order, with the system ID or
+// an error.
+func (h *Hub) postIngest(e *core.RequestEvent) error {
+	var entries []ingestEntry
+	if err := json.NewDecoder(e.Request.Body).Decode(&entries); err != nil {
+		return e.BadRequestError("Invalid payload", err)
+	}
+	headerToken := e.Request.Header.Get("X-Token")
+	results := make([]ingestResult, len(entries))
+	for i := range entries {
+		results[i] = h.ingest(cmp.Or(entries[i].Token, headerToken), &entries[i].CombinedData)
+	}
+	return e.JSON(http.StatusOK, map[string]any{"results": results})
+}
+
+// ingest passes data to the updater of the system with the token
+func (h *Hub) ingest(token string, data *system.CombinedData) ingestResult {
+	var fpRecords []ws.FingerprintRecord
+	if token != "" && len(token) <= 64 {
+		fpRecords = getFingerprintRecordsByToken(token, h)
+	}
+	// systems added with a universal token share it, so it can't identify one
+	if len(fpRecords) != 1 {
+		return ingestResult{Error: "Invalid token"}
+	}
+	systemId := fpRecords[0].SystemId
+	// paused systems stay in the manager until their next update
+	if systemRecord, err := h.FindRecordById("systems", systemId); err != nil || systemRecord.GetString("status") == "paused" {
+		return ingestResult{System: systemId, Error: "System is paused"}
+	}
+	if err := h.sm.Ingest(systemId, data); err != nil {
+		return ingestResult{System: systemId, Error: err.Error()}
+	}
+	return ingestResult{System: systemId}
+}
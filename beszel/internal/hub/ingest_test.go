@@ -0,0 +1,102 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"strings"
+	"testing"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngestApi(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+	// systems are added to the manager when created
+	require.NoError(t, hub.GetSystemManager().Initialize())
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+
+	createSystem := func(name, status, token string) string {
+		systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":  name,
+			"host":  name + ".local",
+			"users": []string{user.Id},
+		})
+		require.NoError(t, err)
+		// new systems are pending
+		systemRecord.Set("status", status)
+		require.NoError(t, hub.Save(systemRecord))
+		_, err = beszelTests.CreateRecord(hub, "fingerprints", map[string]any{
+			"system": systemRecord.Id,
+			"token":  token,
+		})
+		require.NoError(t, err)
+		return systemRecord.Id
+	}
+	routerId := createSystem("router", "pending", "router-token")
+	sensorId := createSystem("sensor", "pending", "sensor-token")
+	pausedId := createSystem("paused", "paused", "paused-token")
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "POST /ingest - invalid payload",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/ingest",
+			Body:            strings.NewReader(`{"stats": {}}`),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid payload"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:   "POST /ingest - batch of systems",
+			Method: http.MethodPost,
+			URL:    "/api/beszel/ingest",
+			Body: strings.NewReader(`[
+				{"token": "router-token", "info": {"h": "router"}, "stats": {"cpu": 4.5}},
+				{"token": "sensor-token", "stats": {"t": {"probe": 21.5}}},
+				{"token": "wrong-token", "stats": {}},
+				{"token": "paused-token", "stats": {}}
+			]`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`{"results":[{"system":"` + routerId + `"},{"system":"` + sensorId + `"},{"error":"Invalid token"},{"system":"` + pausedId + `","error":"System is paused"}]}`,
+			},
+			TestAppFactory: testAppFactory,
+		},
+		{
+			Name:            "POST /ingest - token from header",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/ingest",
+			Body:            strings.NewReader(`[{"stats": {"t": {"probe": 22}}}]`),
+			Headers:         map[string]string{"X-Token": "sensor-token"},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`{"results":[{"system":"` + sensorId + `"}]}`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "POST /ingest - no token",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/ingest",
+			Body:            strings.NewReader(`[{"stats": {}}]`),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`{"results":[{"error":"Invalid token"}]}`},
+			TestAppFactory:  testAppFactory,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
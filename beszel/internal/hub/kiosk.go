@@ -0,0 +1,283 @@
+package hub
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/masking"
+	"beszel/internal/hub/storage"
+	"cmp"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// Default seconds between kiosk payload refreshes
+	defaultKioskRefresh = 30
+	// Default seconds each group is shown before rotating to the next
+	defaultKioskRotate = 15
+	// Group of systems not matched by KIOSK_GROUPS
+	kioskOtherGroup = "Other"
+)
+
+// kioskConfig is the read-only wallboard feed configuration, set with env vars:
+//
+//	KIOSK_TOKEN=secret                     # required, enables the feed
+//	KIOSK_GROUPS=Web=web-*,Database=db-*   # group name=system name pattern
+//	KIOSK_SENSORS=cpu_temp,ups_load        # sensors included in each tile
+//	KIOSK_REFRESH=30                       # seconds between refreshes
+//	KIOSK_ROTATE=15                        # seconds per group
+type kioskConfig struct {
+	token   string
+	groups  []kioskGroupPattern
+	sensors []string
+	refresh int
+	rotate  int
+	storage storage.Driver  // source of the latest stats
+	mask    *masking.Policy // masks system names, set with MASK
+}
+
+type kioskGroupPattern struct {
+	name    string
+	pattern string
+}
+
+// KioskFeed is the payload returned by GET /api/beszel/kiosk
+type KioskFeed struct {
+	Refresh int          `json:"refresh"` // seconds until the client should refresh
+	Rotate  int          `json:"rotate"`  // seconds each group should be shown
+	Updated time.Time    `json:"updated"`
+	Groups  []KioskGroup `json:"groups"`
+	Alerts  []KioskAlert `json:"alerts"`
+}
+
+// KioskGroup is a status tile for a group of systems
+type KioskGroup struct {
+	Name    string        `json:"name"`
+	Up      int           `json:"up"`
+	Down    int           `json:"down"`
+	Paused  int           `json:"paused"`
+	Pending int           `json:"pending"`
+	Systems []KioskSystem `json:"systems"`
+}
+
+// KioskSystem is a system in a kiosk group
+type KioskSystem struct {
+	Name    string                       `json:"name"`
+	Status  string                       `json:"status"`
+	Cpu     float64                      `json:"cpu"`
+	MemPct  float64                      `json:"mp"`
+	DiskPct float64                      `json:"dp"`
+	Temp    float64                      `json:"dt,omitempty"`
+	Sensors map[string]system.SensorData `json:"sensors,omitempty"`
+}
+
+// KioskAlert is an active alert
+type KioskAlert struct {
+	System string  `json:"system"`
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+}
+
+// newKioskConfig returns the kiosk config, or nil if KIOSK_TOKEN is not set.
+func newKioskConfig(driver storage.Driver, mask *masking.Policy) *kioskConfig {
+	token, _ := GetEnv("KIOSK_TOKEN")
+	if token == "" {
+		return nil
+	}
+	config := &kioskConfig{
+		token:   token,
+		refresh: defaultKioskRefresh,
+		rotate:  defaultKioskRotate,
+		storage: driver,
+		mask:    mask,
+	}
+	groups, _ := GetEnv("KIOSK_GROUPS")
+	for entry := range strings.SplitSeq(groups, ",") {
+		name, pattern, ok := strings.Cut(entry, "=")
+		name, pattern = strings.TrimSpace(name), strings.TrimSpace(pattern)
+		if !ok || name == "" || pattern == "" {
+			if entry != "" {
+				slog.Warn("Invalid KIOSK_GROUPS entry", "entry", entry)
+			}
+			continue
+		}
+		config.groups = append(config.groups, kioskGroupPattern{name: name, pattern: pattern})
+	}
+	sensors, _ := GetEnv("KIOSK_SENSORS")
+	for sensor := range strings.SplitSeq(sensors, ",") {
+		if sensor = strings.TrimSpace(sensor); sensor != "" {
+			config.sensors = append(config.sensors, sensor)
+		}
+	}
+	if v, _ := GetEnv("KIOSK_REFRESH"); v != "" {
+		if refresh, err := strconv.Atoi(v); err == nil && refresh > 0 {
+			config.refresh = refresh
+		}
+	}
+	if v, _ := GetEnv("KIOSK_ROTATE"); v != "" {
+		if rotate, err := strconv.Atoi(v); err == nil && rotate > 0 {
+			config.rotate = rotate
+		}
+	}
+	return config
+}
+
+// handleKiosk handles GET /api/beszel/kiosk. The token is passed with the "token"
+// query param or the X-Kiosk-Token header, so it works from a TV browser URL.
+func (kc *kioskConfig) handleKiosk(e *core.RequestEvent) error {
+	token := e.Request.Header.Get("X-Kiosk-Token")
+	if token == "" {
+		token = e.Request.URL.Query().Get("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(kc.token)) != 1 {
+		return e.UnauthorizedError("Invalid kiosk token", nil)
+	}
+
+	records, err := e.App.FindAllRecords("systems")
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	systems, err := newFleetSystems(kc.storage, records)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	alerts, err := getKioskAlerts(e.App, records, kc.mask)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+
+	feed := kc.newFeed(systems)
+	feed.Alerts = alerts
+	e.Response.Header().Set("Cache-Control", "no-store")
+	return e.JSON(http.StatusOK, feed)
+}
+
+// newFeed groups the systems into the kiosk feed.
+func (kc *kioskConfig) newFeed(systems []fleetSystem) KioskFeed {
+	feed := KioskFeed{
+		Refresh: kc.refresh,
+		Rotate:  kc.rotate,
+		Updated: time.Now().UTC(),
+		Groups:  []KioskGroup{},
+		Alerts:  []KioskAlert{},
+	}
+	groupIndex := make(map[string]int)
+	addToGroup := func(name string, sys KioskSystem) {
+		i, ok := groupIndex[name]
+		if !ok {
+			i = len(feed.Groups)
+			groupIndex[name] = i
+			feed.Groups = append(feed.Groups, KioskGroup{Name: name})
+		}
+		group := &feed.Groups[i]
+		switch sys.Status {
+		case "up":
+			group.Up++
+		case "down":
+			group.Down++
+		case "paused":
+			group.Paused++
+		default:
+			group.Pending++
+		}
+		group.Systems = append(group.Systems, sys)
+	}
+	// create configured groups first so they keep their order
+	for _, group := range kc.groups {
+		if _, ok := groupIndex[group.name]; !ok {
+			groupIndex[group.name] = len(feed.Groups)
+			feed.Groups = append(feed.Groups, KioskGroup{Name: group.name, Systems: []KioskSystem{}})
+		}
+	}
+
+	slices.SortFunc(systems, func(a, b fleetSystem) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for _, sys := range systems {
+		addToGroup(kc.getGroup(sys.Name), kc.newSystem(sys))
+	}
+	return feed
+}
+
+// getGroup returns the first group with a pattern matching the system name.
+func (kc *kioskConfig) getGroup(systemName string) string {
+	for _, group := range kc.groups {
+		if matched, _ := path.Match(group.pattern, systemName); matched {
+			return group.name
+		}
+	}
+	return kioskOtherGroup
+}
+
+// newSystem creates the kiosk tile of a system with its selected sensors.
+func (kc *kioskConfig) newSystem(sys fleetSystem) KioskSystem {
+	kioskSystem := KioskSystem{
+		Name:   kc.mask.Hostname(sys.Name),
+		Status: sys.Status,
+	}
+	if sys.Status != "up" {
+		return kioskSystem
+	}
+	kioskSystem.Cpu = sys.Info.Cpu
+	kioskSystem.MemPct = sys.Info.MemPct
+	kioskSystem.DiskPct = sys.Info.DiskPct
+	kioskSystem.Temp = sys.Info.DashboardTemp
+	if sys.Stats == nil {
+		return kioskSystem
+	}
+	for _, sensor := range kc.sensors {
+		var data system.SensorData
+		if temp, ok := sys.Stats.Temperatures[sensor]; ok {
+			data = system.SensorData{Value: temp, Unit: "°C"}
+		} else if data, ok = sys.Stats.GenericSensors[sensor]; !ok {
+			continue
+		}
+		if kioskSystem.Sensors == nil {
+			kioskSystem.Sensors = make(map[string]system.SensorData)
+		}
+		kioskSystem.Sensors[sensor] = data
+	}
+	return kioskSystem
+}
+
+// getKioskAlerts returns the triggered alerts of the systems, once per system and alert name.
+func getKioskAlerts(app core.App, systems []*core.Record, mask *masking.Policy) ([]KioskAlert, error) {
+	systemNames := make(map[string]string, len(systems))
+	for _, record := range systems {
+		systemNames[record.Id] = mask.Hostname(record.GetString("name"))
+	}
+	alertRecords, err := app.FindAllRecords("alerts", dbx.HashExp{"triggered": true})
+	if err != nil {
+		return nil, err
+	}
+	alerts := make([]KioskAlert, 0, len(alertRecords))
+	seen := make(map[string]struct{}, len(alertRecords))
+	for _, record := range alertRecords {
+		systemName, ok := systemNames[record.GetString("system")]
+		if !ok {
+			continue
+		}
+		key := systemName + "/" + record.GetString("name")
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		alerts = append(alerts, KioskAlert{
+			System: systemName,
+			Name:   record.GetString("name"),
+			Value:  record.GetFloat("value"),
+		})
+	}
+	slices.SortFunc(alerts, func(a, b KioskAlert) int {
+		return cmp.Or(strings.Compare(a.System, b.System), strings.Compare(a.Name, b.Name))
+	})
+	return alerts, nil
+}
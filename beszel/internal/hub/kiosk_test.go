@@ -0,0 +1,228 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKioskFeed(t *testing.T) {
+	t.Setenv("BESZEL_HUB_KIOSK_TOKEN", "kiosk-secret")
+	t.Setenv("BESZEL_HUB_KIOSK_GROUPS", "Web=web-*,Database=db-*")
+	t.Setenv("BESZEL_HUB_KIOSK_SENSORS", "cpu_temp,ups_load")
+	t.Setenv("BESZEL_HUB_KIOSK_ROTATE", "20")
+
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+
+	createSystem := func(name, status string, info system.Info) *core.Record {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":  name,
+			"host":  "127.0.0.1",
+			"users": []string{user.Id},
+			"info":  info,
+		})
+		require.NoError(t, err)
+		record.Set("status", status)
+		require.NoError(t, hub.SaveNoValidate(record))
+		return record
+	}
+
+	web1 := createSystem("web-1", "up", system.Info{Cpu: 12.5, MemPct: 40})
+	createSystem("web-2", "down", system.Info{Cpu: 99})
+	createSystem("backup", "up", system.Info{DiskPct: 91})
+
+	_, err = beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+		"system": web1.Id,
+		"type":   "1m",
+		"stats": system.Stats{
+			Temperatures:   map[string]float64{"cpu_temp": 55, "nvme": 40},
+			GenericSensors: map[string]system.SensorData{"ups_load": {Value: 30, Unit: "%"}},
+		},
+	})
+	require.NoError(t, err)
+
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+	for _, userId := range []string{user.Id, otherUser.Id} {
+		_, err = beszelTests.CreateRecord(hub, "alerts", map[string]any{
+			"name":      "CPU",
+			"system":    web1.Id,
+			"user":      userId,
+			"value":     80,
+			"triggered": true,
+		})
+		require.NoError(t, err)
+	}
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /kiosk - no token should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/kiosk",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"Invalid kiosk token"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /kiosk - wrong token should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/kiosk?token=wrong",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"Invalid kiosk token"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /kiosk - token header",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/kiosk",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"rotate":20`, `"refresh":30`},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"X-Kiosk-Token": "kiosk-secret",
+			},
+		},
+		{
+			Name:            "GET /kiosk - grouped systems",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/kiosk?token=kiosk-secret",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"groups"`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				body, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				var feed struct {
+					Groups []struct {
+						Name    string
+						Up      int
+						Down    int
+						Systems []struct {
+							Name    string
+							Cpu     float64
+							Sensors map[string]system.SensorData
+						}
+					}
+					Alerts []struct {
+						System string
+						Name   string
+					}
+				}
+				require.NoError(t, json.Unmarshal(body, &feed))
+				require.Len(t, feed.Groups, 3)
+
+				assert.Equal(t, "Web", feed.Groups[0].Name)
+				assert.Equal(t, 1, feed.Groups[0].Up)
+				assert.Equal(t, 1, feed.Groups[0].Down)
+				require.Len(t, feed.Groups[0].Systems, 2)
+				assert.Equal(t, 12.5, feed.Groups[0].Systems[0].Cpu)
+				assert.Equal(t, map[string]system.SensorData{
+					"cpu_temp": {Value: 55, Unit: "°C"},
+					"ups_load": {Value: 30, Unit: "%"},
+				}, feed.Groups[0].Systems[0].Sensors)
+				// down systems don't report stale usage
+				assert.Zero(t, feed.Groups[0].Systems[1].Cpu)
+
+				assert.Equal(t, "Database", feed.Groups[1].Name)
+				assert.Empty(t, feed.Groups[1].Systems)
+				assert.Equal(t, "Other", feed.Groups[2].Name)
+				assert.Equal(t, "backup", feed.Groups[2].Systems[0].Name)
+
+				// alerts are deduplicated across users
+				require.Len(t, feed.Alerts, 1)
+				assert.Equal(t, "web-1", feed.Alerts[0].System)
+				assert.Equal(t, "CPU", feed.Alerts[0].Name)
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestKioskFeedMasking(t *testing.T) {
+	t.Setenv("BESZEL_HUB_KIOSK_TOKEN", "kiosk-secret")
+	t.Setenv("BESZEL_HUB_MASK", "hostnames")
+	t.Setenv("BESZEL_HUB_MASK_KEY", "test-key")
+
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	require.NoError(t, hub.StartHub())
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	user.Set("role", "readonly")
+	require.NoError(t, hub.Save(user))
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+
+	system, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "db-internal-1",
+		"host":  "10.0.0.5",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+	_, err = beszelTests.CreateRecord(hub, "alerts", map[string]any{
+		"name":      "CPU",
+		"system":    system.Id,
+		"user":      user.Id,
+		"value":     80,
+		"triggered": true,
+	})
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:               "GET /kiosk - system names are masked",
+			Method:             http.MethodGet,
+			URL:                "/api/beszel/kiosk?token=kiosk-secret",
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"name":"host-`, `"system":"host-`},
+			NotExpectedContent: []string{"db-internal-1"},
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:               "GET systems - readonly users get masked names",
+			Method:             http.MethodGet,
+			URL:                "/api/collections/systems/records",
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"name":"host-`, `"host":"host-`},
+			NotExpectedContent: []string{"db-internal-1", "10.0.0.5"},
+			TestAppFactory:     testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,277 @@
+package hub
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxzan/gws"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// interval of pings to live stream clients
+	livePingInterval = 30 * time.Second
+	// clients are disconnected if they don't answer pings or send messages
+	liveDeadline = 70 * time.Second
+	// max size of messages from clients
+	liveMaxMessageSize = 64 * 1024
+	// session key of the liveClient of a connection
+	liveClientKey = "liveClient"
+)
+
+// LiveMessage is a message sent to live stream clients
+type LiveMessage struct {
+	Type    string        `json:"type"`              // "stats", "subscribed" or "error"
+	System  *APISystem    `json:"system,omitempty"`  // system of stats messages
+	Stats   *system.Stats `json:"stats,omitempty"`   // latest stats, if the system is up
+	Systems []string      `json:"systems,omitempty"` // subscribed system ids
+	Message string        `json:"message,omitempty"` // error message
+}
+
+// liveRequest is a message from a live stream client that changes its subscriptions
+type liveRequest struct {
+	Subscribe   []string `json:"subscribe"` // system ids, or "*" for all visible systems
+	Unsubscribe []string `json:"unsubscribe"`
+}
+
+// liveStream sends the stats of systems to subscribed WebSocket clients when
+// the hub updates the systems, about once a minute
+type liveStream struct {
+	gws.BuiltinEventHandler
+	hub      *Hub
+	upgrader *gws.Upgrader
+	mu       sync.RWMutex
+	clients  map[*liveClient]struct{}
+	done     chan struct{}
+}
+
+// liveClient is a connection to the live stream
+type liveClient struct {
+	conn     *gws.Conn
+	info     *core.RequestInfo // auth of the connection, to check access to systems
+	readonly bool
+	mu       sync.Mutex
+	systems  map[string]struct{} // subscribed system ids
+}
+
+func newLiveStream(h *Hub) *liveStream {
+	ls := &liveStream{hub: h, clients: make(map[*liveClient]struct{}), done: make(chan struct{})}
+	ls.upgrader = gws.NewUpgrader(ls, &gws.ServerOption{ReadMaxPayloadSize: liveMaxMessageSize})
+	return ls
+}
+
+// Start sends stats to clients on system updates, and pings clients until the
+// app terminates
+func (ls *liveStream) Start() {
+	ls.hub.OnRecordAfterUpdateSuccess("systems").BindFunc(ls.onSystemUpdate)
+	ls.hub.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		close(ls.done)
+		return e.Next()
+	})
+	go ls.run()
+}
+
+func (ls *liveStream) run() {
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ls.done:
+			for _, client := range ls.allClients() {
+				_ = client.conn.WriteClose(1001, nil)
+			}
+			return
+		}
+		for _, client := range ls.allClients() {
+			_ = client.conn.WritePing(nil)
+		}
+	}
+}
+
+func (ls *liveStream) allClients() []*liveClient {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	clients := make([]*liveClient, 0, len(ls.clients))
+	for client := range ls.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// subscribers returns the clients subscribed to a system
+func (ls *liveStream) subscribers(systemId string) []*liveClient {
+	var clients []*liveClient
+	for _, client := range ls.allClients() {
+		client.mu.Lock()
+		if _, ok := client.systems[systemId]; ok {
+			clients = append(clients, client)
+		}
+		client.mu.Unlock()
+	}
+	return clients
+}
+
+// handleConnect handles GET /api/v1/live, which upgrades the connection to a
+// WebSocket. The optional "systems" query param subscribes to comma separated
+// system ids, or "*" for all systems visible to the user.
+func (ls *liveStream) handleConnect(e *core.RequestEvent) error {
+	client := &liveClient{
+		info:     &core.RequestInfo{Auth: e.Auth},
+		readonly: isReadonly(e),
+		systems:  make(map[string]struct{}),
+	}
+	conn, err := ls.upgrader.Upgrade(e.Response, e.Request)
+	if err != nil {
+		// the upgrader already sent the error response
+		return nil
+	}
+	client.conn = conn
+	conn.Session().Store(liveClientKey, client)
+	ls.mu.Lock()
+	ls.clients[client] = struct{}{}
+	ls.mu.Unlock()
+	if ids := e.Request.URL.Query().Get("systems"); ids != "" {
+		ls.subscribe(client, strings.Split(ids, ","))
+	}
+	go conn.ReadLoop()
+	return nil
+}
+
+func (ls *liveStream) OnOpen(conn *gws.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(liveDeadline))
+}
+
+func (ls *liveStream) OnClose(conn *gws.Conn, err error) {
+	if client, ok := conn.Session().Load(liveClientKey); ok {
+		ls.mu.Lock()
+		delete(ls.clients, client.(*liveClient))
+		ls.mu.Unlock()
+	}
+}
+
+func (ls *liveStream) OnPong(conn *gws.Conn, payload []byte) {
+	_ = conn.SetDeadline(time.Now().Add(liveDeadline))
+}
+
+func (ls *liveStream) OnPing(conn *gws.Conn, payload []byte) {
+	_ = conn.SetDeadline(time.Now().Add(liveDeadline))
+	_ = conn.WritePong(payload)
+}
+
+// OnMessage changes the subscriptions of a client
+func (ls *liveStream) OnMessage(conn *gws.Conn, message *gws.Message) {
+	defer message.Close()
+	_ = conn.SetDeadline(time.Now().Add(liveDeadline))
+	value, ok := conn.Session().Load(liveClientKey)
+	if !ok {
+		return
+	}
+	client := value.(*liveClient)
+	var request liveRequest
+	if err := json.Unmarshal(message.Bytes(), &request); err != nil {
+		client.send(LiveMessage{Type: "error", Message: "Invalid message"})
+		return
+	}
+	if len(request.Unsubscribe) > 0 {
+		client.mu.Lock()
+		for _, id := range request.Unsubscribe {
+			delete(client.systems, id)
+		}
+		client.mu.Unlock()
+	}
+	ls.subscribe(client, request.Subscribe)
+}
+
+// subscribe adds the systems the client can view to its subscriptions, and
+// sends their latest stats
+func (ls *liveStream) subscribe(client *liveClient, ids []string) {
+	var records []*core.Record
+	if slices.Contains(ids, "*") {
+		var err error
+		if records, err = findSystemsVisibleTo(ls.hub, client.info); err != nil {
+			client.send(LiveMessage{Type: "error", Message: "Failed to find systems"})
+			return
+		}
+	} else {
+		for _, id := range ids {
+			if id = strings.TrimSpace(id); id == "" {
+				continue
+			}
+			record, err := ls.hub.FindRecordById("systems", id)
+			if err == nil {
+				if canAccess, _ := ls.hub.CanAccessRecord(record, client.info, record.Collection().ViewRule); canAccess {
+					records = append(records, record)
+					continue
+				}
+			}
+			client.send(LiveMessage{Type: "error", Message: "System not found: " + id})
+		}
+	}
+	client.mu.Lock()
+	for _, record := range records {
+		client.systems[record.Id] = struct{}{}
+	}
+	subscribed := make([]string, 0, len(client.systems))
+	for id := range client.systems {
+		subscribed = append(subscribed, id)
+	}
+	client.mu.Unlock()
+	slices.Sort(subscribed)
+	client.send(LiveMessage{Type: "subscribed", Systems: subscribed})
+
+	if len(records) == 0 {
+		return
+	}
+	latestStats, err := getLatestSystemStats(ls.hub.storage)
+	if err != nil {
+		ls.hub.Logger().Error("Failed to get latest stats", "err", err)
+		return
+	}
+	for _, record := range records {
+		client.send(ls.newStatsMessage(record, latestStats[record.Id], client.readonly))
+	}
+}
+
+// onSystemUpdate sends the system and its latest stats to subscribed clients
+func (ls *liveStream) onSystemUpdate(e *core.RecordEvent) error {
+	clients := ls.subscribers(e.Record.Id)
+	if len(clients) == 0 {
+		return e.Next()
+	}
+	var stats *system.Stats
+	if e.Record.GetString("status") == "up" {
+		records, err := ls.hub.storage.SystemStats(storage.Query{System: e.Record.Id, Since: time.Now().UTC().Add(-2 * time.Minute)})
+		if err != nil {
+			ls.hub.Logger().Error("Failed to get latest stats", "system", e.Record.Id, "err", err)
+		} else if len(records) > 0 {
+			stats = &records[len(records)-1].Stats
+		}
+	}
+	for _, client := range clients {
+		client.send(ls.newStatsMessage(e.Record, stats, client.readonly))
+	}
+	return e.Next()
+}
+
+func (ls *liveStream) newStatsMessage(record *core.Record, stats *system.Stats, readonly bool) LiveMessage {
+	apiSystem := ls.hub.newAPISystem(record, readonly)
+	if record.GetString("status") != "up" {
+		stats = nil
+	}
+	return LiveMessage{Type: "stats", System: &apiSystem, Stats: stats}
+}
+
+// send queues a message to the client
+func (c *liveClient) send(message LiveMessage) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	c.conn.WriteAsync(gws.OpcodeText, payload, nil)
+}
@@ -0,0 +1,148 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/hub"
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lxzan/gws"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// liveTestClient collects the messages of a live stream connection
+type liveTestClient struct {
+	gws.BuiltinEventHandler
+	messages chan hub.LiveMessage
+}
+
+func (c *liveTestClient) OnMessage(conn *gws.Conn, message *gws.Message) {
+	defer message.Close()
+	var msg hub.LiveMessage
+	if err := json.Unmarshal(message.Bytes(), &msg); err == nil {
+		c.messages <- msg
+	}
+}
+
+func (c *liveTestClient) next(t *testing.T) hub.LiveMessage {
+	t.Helper()
+	select {
+	case msg := <-c.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for live message")
+		return hub.LiveMessage{}
+	}
+}
+
+func TestLiveStream(t *testing.T) {
+	testHub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer testHub.Cleanup()
+
+	testHub.StartHub()
+
+	user, err := beszelTests.CreateUser(testHub, "live@example.com", "password123")
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(testHub, "other@example.com", "password123")
+	require.NoError(t, err)
+	key, err := beszelTests.CreateRecord(testHub, "api_keys", map[string]any{
+		"user":   user.Id,
+		"token":  strings.Repeat("a", 40),
+		"scopes": []string{"stats:read"},
+	})
+	require.NoError(t, err)
+	systemRecord, err := beszelTests.CreateRecord(testHub, "systems", map[string]any{
+		"name":   "web1",
+		"host":   "10.0.0.1",
+		"status": "up",
+		"users":  []string{user.Id},
+	})
+	require.NoError(t, err)
+	hiddenRecord, err := beszelTests.CreateRecord(testHub, "systems", map[string]any{
+		"name":  "hidden",
+		"host":  "10.0.0.2",
+		"users": []string{otherUser.Id},
+	})
+	require.NoError(t, err)
+	_, err = beszelTests.CreateRecord(testHub, "system_stats", map[string]any{
+		"system": systemRecord.Id,
+		"type":   "1m",
+		"stats":  `{"cpu": 25}`,
+	})
+	require.NoError(t, err)
+
+	// serve the hub's routes over HTTP for WebSocket connections
+	router, err := apis.NewRouter(testHub)
+	require.NoError(t, err)
+	var server *httptest.Server
+	err = testHub.OnServe().Trigger(&core.ServeEvent{App: testHub, Router: router}, func(e *core.ServeEvent) error {
+		mux, err := e.Router.BuildMux()
+		if err != nil {
+			return err
+		}
+		server = httptest.NewServer(mux)
+		return nil
+	})
+	require.NoError(t, err)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/live"
+
+	// connections need a key or auth token
+	_, _, err = gws.NewClient(&liveTestClient{}, &gws.ClientOption{Addr: wsURL})
+	assert.Error(t, err)
+
+	client := &liveTestClient{messages: make(chan hub.LiveMessage, 10)}
+	conn, _, err := gws.NewClient(client, &gws.ClientOption{Addr: wsURL + "?systems=" + systemRecord.Id + "&key=" + key.GetString("token")})
+	require.NoError(t, err)
+	defer conn.NetConn().Close()
+	go conn.ReadLoop()
+
+	// subscribing sends the latest stats
+	msg := client.next(t)
+	assert.Equal(t, "subscribed", msg.Type)
+	assert.Equal(t, []string{systemRecord.Id}, msg.Systems)
+	msg = client.next(t)
+	assert.Equal(t, "stats", msg.Type)
+	require.NotNil(t, msg.System)
+	assert.Equal(t, "web1", msg.System.Name)
+	require.NotNil(t, msg.Stats)
+	assert.Equal(t, 25.0, msg.Stats.Cpu)
+
+	// updates of the system are sent as they happen
+	record, err := testHub.FindRecordById("systems", systemRecord.Id)
+	require.NoError(t, err)
+	record.Set("status", "down")
+	require.NoError(t, testHub.SaveNoValidate(record))
+	msg = client.next(t)
+	assert.Equal(t, "stats", msg.Type)
+	assert.Equal(t, "down", msg.System.Status)
+	assert.Nil(t, msg.Stats)
+
+	// systems of other users can't be subscribed to
+	require.NoError(t, conn.WriteString(`{"subscribe": ["`+hiddenRecord.Id+`"], "unsubscribe": ["`+systemRecord.Id+`"]}`))
+	msg = client.next(t)
+	assert.Equal(t, "error", msg.Type)
+	assert.Contains(t, msg.Message, "System not found")
+	msg = client.next(t)
+	assert.Equal(t, "subscribed", msg.Type)
+	assert.Empty(t, msg.Systems)
+
+	// updates of unsubscribed systems aren't sent
+	record.Set("status", "up")
+	require.NoError(t, testHub.SaveNoValidate(record))
+	select {
+	case msg := <-client.messages:
+		t.Fatalf("unexpected message %+v", msg)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
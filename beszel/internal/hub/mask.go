@@ -0,0 +1,79 @@
+package hub
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/hub/masking"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newMaskingPolicy returns the masking policy set with env vars, or nil if MASK is not set:
+//
+//	MASK      what to mask in exports, the kiosk feed and for readonly users:
+//	          hostnames, ips, containers or all, e.g. MASK=hostnames,ips
+//	MASK_KEY  key for the pseudonyms, defaults to a random key saved in the data dir
+func (h *Hub) newMaskingPolicy() (*masking.Policy, error) {
+	value, _ := GetEnv("MASK")
+	if value == "" {
+		return nil, nil
+	}
+	key, err := h.maskingKey()
+	if err != nil {
+		return nil, err
+	}
+	return masking.Parse(value, key)
+}
+
+// maskingKey returns MASK_KEY, or the key saved in the data dir, creating it if needed.
+// The key is persisted so pseudonyms don't change when the hub restarts.
+func (h *Hub) maskingKey() ([]byte, error) {
+	if key, _ := GetEnv("MASK_KEY"); key != "" {
+		return []byte(key), nil
+	}
+	path := filepath.Join(h.DataDir(), "mask_key")
+	if key, err := os.ReadFile(path); err == nil && len(key) > 0 {
+		return key, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(h.DataDir(), 0755); err != nil {
+		return nil, err
+	}
+	return key, os.WriteFile(path, key, 0600)
+}
+
+// maskReadonlyRecord masks names in systems and container_stats records sent to readonly users.
+func (h *Hub) maskReadonlyRecord(e *core.RecordEnrichEvent) error {
+	if e.RequestInfo == nil || e.RequestInfo.Auth == nil || e.RequestInfo.Auth.GetString("role") != "readonly" {
+		return e.Next()
+	}
+	switch e.Record.Collection().Name {
+	case "systems":
+		e.Record.Set("name", h.mask.Hostname(e.Record.GetString("name")))
+		e.Record.Set("host", h.mask.Hostname(e.Record.GetString("host")))
+		var info map[string]any
+		if err := e.Record.UnmarshalJSONField("info", &info); err == nil {
+			if hostname, ok := info["h"].(string); ok {
+				info["h"] = h.mask.Hostname(hostname)
+			}
+			if publicIp, ok := info["pip"].(string); ok {
+				info["pip"] = h.mask.Text(publicIp)
+			}
+			e.Record.Set("info", info)
+		}
+	case "container_stats":
+		var stats []container.Stats
+		if err := e.Record.UnmarshalJSONField("stats", &stats); err == nil {
+			for i := range stats {
+				stats[i].Name = h.mask.Container(stats[i].Name)
+			}
+			e.Record.Set("stats", stats)
+		}
+	}
+	return e.Next()
+}
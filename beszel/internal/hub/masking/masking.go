@@ -0,0 +1,90 @@
+// Package masking replaces identifying names in data shared outside the hub,
+// like exports and the kiosk feed, with stable pseudonyms.
+//
+// Pseudonyms are keyed hashes, so the same name always gets the same pseudonym
+// (keeping time series and dashboards consistent) but can't be reversed by
+// hashing guesses without the key.
+package masking
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Policy is what to mask. A nil policy masks nothing.
+type Policy struct {
+	Hostnames  bool // system names and hostnames
+	IPs        bool // IPv4 and IPv6 addresses
+	Containers bool // container names
+	key        []byte
+}
+
+// ipRe matches IPv4 addresses, and IPv6 addresses with all eight groups or
+// compressed with "::" (so times like 12:30:45 don't match)
+var ipRe = regexp.MustCompile(`(?i)\b(?:\d{1,3}\.){3}\d{1,3}\b|\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b|(?:\b[0-9a-f]{1,4}(?::[0-9a-f]{1,4})*)?::(?:[0-9a-f]{1,4}(?::[0-9a-f]{1,4})*\b)?`)
+
+// Parse parses a comma separated list of what to mask: hostnames, ips,
+// containers or all. Returns nil if value is empty.
+func Parse(value string, key []byte) (*Policy, error) {
+	policy := &Policy{key: key}
+	for item := range strings.SplitSeq(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(item)) {
+		case "":
+		case "hostnames":
+			policy.Hostnames = true
+		case "ips":
+			policy.IPs = true
+		case "containers":
+			policy.Containers = true
+		case "all":
+			policy.Hostnames, policy.IPs, policy.Containers = true, true, true
+		default:
+			return nil, fmt.Errorf("invalid mask %q", item)
+		}
+	}
+	if !policy.Hostnames && !policy.IPs && !policy.Containers {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// Hostname masks a system name or hostname. Without Hostnames, only the IP
+// addresses in it are masked, since systems are often named by address.
+func (p *Policy) Hostname(name string) string {
+	if p == nil || name == "" {
+		return name
+	}
+	if p.Hostnames {
+		return p.pseudonym("host", name)
+	}
+	return p.Text(name)
+}
+
+// Container masks a container name.
+func (p *Policy) Container(name string) string {
+	if p == nil || !p.Containers || name == "" {
+		return name
+	}
+	return p.pseudonym("container", name)
+}
+
+// Text masks the IP addresses in text.
+func (p *Policy) Text(text string) string {
+	if p == nil || !p.IPs {
+		return text
+	}
+	return ipRe.ReplaceAllStringFunc(text, func(ip string) string {
+		return p.pseudonym("ip", ip)
+	})
+}
+
+// pseudonym returns the kind followed by a keyed hash of the value, e.g. host-3f2a9c1b.
+func (p *Policy) pseudonym(kind, value string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(kind + ":" + value))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil)[:4])
+}
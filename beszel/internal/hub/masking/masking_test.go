@@ -0,0 +1,58 @@
+//go:build testing
+// +build testing
+
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	policy, err := Parse("", nil)
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+
+	policy, err = Parse(" Hostnames, ips ", nil)
+	require.NoError(t, err)
+	assert.True(t, policy.Hostnames)
+	assert.True(t, policy.IPs)
+	assert.False(t, policy.Containers)
+
+	policy, err = Parse("all", nil)
+	require.NoError(t, err)
+	assert.True(t, policy.Hostnames && policy.IPs && policy.Containers)
+
+	_, err = Parse("hostnames,macs", nil)
+	assert.EqualError(t, err, `invalid mask "macs"`)
+}
+
+func TestPolicy(t *testing.T) {
+	var none *Policy
+	assert.Equal(t, "web-1", none.Hostname("web-1"))
+	assert.Equal(t, "nginx", none.Container("nginx"))
+	assert.Equal(t, "10.0.0.1", none.Text("10.0.0.1"))
+
+	policy := &Policy{Hostnames: true, IPs: true, Containers: true, key: []byte("key")}
+	host := policy.Hostname("web-1")
+	assert.Regexp(t, `^host-[0-9a-f]{8}$`, host)
+	assert.Equal(t, host, policy.Hostname("web-1"), "stable")
+	assert.NotEqual(t, host, policy.Hostname("web-2"))
+	assert.NotEqual(t, host, (&Policy{Hostnames: true, key: []byte("other")}).Hostname("web-1"), "keyed")
+	assert.Regexp(t, `^container-[0-9a-f]{8}$`, policy.Container("nginx"))
+	assert.Empty(t, policy.Hostname(""))
+
+	text := policy.Text("failed to reach 192.168.1.20:45876 and fe80::1ff:fe23:4567:890a")
+	assert.Regexp(t, `^failed to reach ip-[0-9a-f]{8}:45876 and ip-[0-9a-f]{8}$`, text)
+	assert.Equal(t, "at 12:30:45", policy.Text("at 12:30:45"), "times aren't addresses")
+	assert.Regexp(t, `^ip-[0-9a-f]{8}$`, policy.Text("2001:db8:0:0:0:0:2:1"))
+	assert.Regexp(t, `^ip-[0-9a-f]{8}$`, policy.Text("::1"))
+
+	// systems named by address are masked without Hostnames
+	ipsOnly := &Policy{IPs: true, key: []byte("key")}
+	assert.Equal(t, "web-1", ipsOnly.Hostname("web-1"))
+	assert.Regexp(t, `^ip-[0-9a-f]{8}$`, ipsOnly.Hostname("10.0.0.5"))
+	assert.Equal(t, "nginx", ipsOnly.Container("nginx"))
+}
@@ -0,0 +1,107 @@
+package hub
+
+import (
+	"beszel/internal/hub/pki"
+	"crypto/tls"
+	"net/http"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// mTLSEnabled reports whether the hub connects to agents over mTLS instead of
+// SSH, set with MTLS=true or with the CA of existing PKI in MTLS_CA_CERT and
+// MTLS_CA_KEY.
+func mTLSEnabled() bool {
+	enabled, _ := GetEnv("MTLS")
+	caCert, _ := GetEnv("MTLS_CA_CERT")
+	return enabled == "true" || caCert != ""
+}
+
+// initMTLS loads or creates the CA of mTLS connections, if enabled.
+func (h *Hub) initMTLS() error {
+	if !mTLSEnabled() {
+		return nil
+	}
+	caCert, _ := GetEnv("MTLS_CA_CERT")
+	caKey, _ := GetEnv("MTLS_CA_KEY")
+	ca, err := pki.Load(h.DataDir(), caCert, caKey)
+	if err != nil {
+		return err
+	}
+	// issue the first client certificate now so errors show up on startup
+	if _, err := ca.ClientCertificate(); err != nil {
+		return err
+	}
+	h.mtls = ca
+	h.Logger().Info("Connecting to agents over mTLS")
+	return nil
+}
+
+// MTLSConfig returns the TLS config of a connection to an agent at host, or
+// nil if the hub connects to agents over SSH.
+func (h *Hub) MTLSConfig(host string) *tls.Config {
+	if h.mtls == nil {
+		return nil
+	}
+	return h.mtls.ClientConfig(host)
+}
+
+// registerMTLSRoutes adds the routes to get the CA certificate and revocation
+// list, and the superuser routes to issue and revoke agent certificates.
+func (h *Hub) registerMTLSRoutes(se *core.ServeEvent) {
+	if h.mtls == nil {
+		return
+	}
+	// agents may fetch the CA certificate and revocation list without auth
+	public := se.Router.Group("/api/beszel/mtls")
+	public.GET("/ca", func(e *core.RequestEvent) error {
+		return e.Blob(http.StatusOK, "application/x-pem-file", h.mtls.CertPEM())
+	})
+	public.GET("/crl", func(e *core.RequestEvent) error {
+		crl, err := h.mtls.CRLPEM()
+		if err != nil {
+			return e.InternalServerError("", err)
+		}
+		return e.Blob(http.StatusOK, "application/x-pem-file", crl)
+	})
+
+	group := se.Router.Group("/api/beszel/mtls")
+	group.Bind(apis.RequireSuperuserAuth())
+	// issue an agent certificate, e.g. {"name": "web-1", "hosts": ["web-1.lan", "10.0.0.5"]}
+	group.POST("/certificates", func(e *core.RequestEvent) error {
+		var body struct {
+			Name  string   `json:"name"`
+			Hosts []string `json:"hosts"`
+		}
+		if err := e.BindBody(&body); err != nil {
+			return e.BadRequestError("Invalid body", err)
+		}
+		if strings.TrimSpace(body.Name) == "" {
+			return e.BadRequestError("Name is required", nil)
+		}
+		cert, err := h.mtls.IssueAgent(body.Name, body.Hosts)
+		if err != nil {
+			return e.BadRequestError(err.Error(), err)
+		}
+		return e.JSON(http.StatusOK, cert)
+	}).BindFunc(auditRoute("mtls.issue_certificate"))
+	// revoke a certificate by its serial number, e.g. {"serial": "3f2a..."}
+	group.POST("/revoke", func(e *core.RequestEvent) error {
+		var body struct {
+			Serial string `json:"serial"`
+		}
+		if err := e.BindBody(&body); err != nil {
+			return e.BadRequestError("Invalid body", err)
+		}
+		serial, err := pki.ParseSerial(body.Serial)
+		if err != nil {
+			return e.BadRequestError(err.Error(), err)
+		}
+		if err := h.mtls.Revoke(serial); err != nil {
+			return e.InternalServerError("", err)
+		}
+		return e.JSON(http.StatusOK, map[string]string{"serial": serial.Text(16)})
+	}).BindFunc(auditRoute("mtls.revoke_certificate"))
+}
@@ -0,0 +1,329 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/auth"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// roles of users, from the most to the least privileged
+var oidcRoles = []string{"admin", "user", "readonly"}
+
+// oidcTeam is the team and team role of the members of an IdP group
+type oidcTeam struct {
+	name string
+	role string // "member", "admin" or "operator"
+}
+
+// oidcConfig is the OIDC single sign-on set with env vars:
+//
+//	OIDC_ISSUER         issuer URL of the identity provider, e.g. https://id.example.com/realms/main
+//	OIDC_CLIENT_ID      client id of the hub
+//	OIDC_CLIENT_SECRET  client secret of the hub
+//	OIDC_NAME           name of the login button, "SSO" by default
+//	OIDC_GROUPS_CLAIM   claim with the user's groups, "groups" by default. Nested claims
+//	                    are separated by dots, e.g. realm_access.roles
+//	OIDC_ROLES          roles of groups, e.g. beszel-admins=admin;auditors=readonly
+//	OIDC_TEAMS          teams of groups, e.g. client-a=Client A;client-a-noc=Client A:operator
+//
+// Mappings are separated by semicolons, and groups are split from their role
+// or team at the last "=", so groups can be LDAP DNs.
+type oidcConfig struct {
+	issuer       string
+	clientId     string
+	clientSecret string
+	name         string
+	groupsClaim  string
+	roles        map[string]string
+	teams        map[string][]oidcTeam
+}
+
+// newOIDCConfig returns the OIDC config set with env vars, or nil if
+// OIDC_ISSUER is not set
+func newOIDCConfig() (*oidcConfig, error) {
+	issuer, _ := GetEnv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	c := &oidcConfig{
+		issuer:      strings.TrimSuffix(issuer, "/"),
+		name:        "SSO",
+		groupsClaim: "groups",
+		roles:       map[string]string{},
+		teams:       map[string][]oidcTeam{},
+	}
+	c.clientId, _ = GetEnv("OIDC_CLIENT_ID")
+	c.clientSecret, _ = GetEnv("OIDC_CLIENT_SECRET")
+	if c.clientId == "" || c.clientSecret == "" {
+		return nil, errors.New("OIDC_CLIENT_ID and OIDC_CLIENT_SECRET are required with OIDC_ISSUER")
+	}
+	if name, _ := GetEnv("OIDC_NAME"); name != "" {
+		c.name = name
+	}
+	if claim, _ := GetEnv("OIDC_GROUPS_CLAIM"); claim != "" {
+		c.groupsClaim = claim
+	}
+	roles, _ := GetEnv("OIDC_ROLES")
+	err := parseGroupMappings(roles, func(group, role string) error {
+		if !slices.Contains(oidcRoles, role) {
+			return fmt.Errorf("invalid role %q of group %q in OIDC_ROLES", role, group)
+		}
+		c.roles[group] = role
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	teams, _ := GetEnv("OIDC_TEAMS")
+	err = parseGroupMappings(teams, func(group, value string) error {
+		team := oidcTeam{name: value, role: "member"}
+		if name, role, found := strings.Cut(value, ":"); found {
+			if role != "member" && role != "admin" && role != "operator" {
+				return fmt.Errorf("invalid team role %q of group %q in OIDC_TEAMS", role, group)
+			}
+			team = oidcTeam{name: name, role: role}
+		}
+		c.teams[group] = append(c.teams[group], team)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseGroupMappings calls add with the group and value of each
+// "group=value" mapping of a semicolon separated list
+func parseGroupMappings(value string, add func(group, value string) error) error {
+	for mapping := range strings.SplitSeq(value, ";") {
+		if mapping = strings.TrimSpace(mapping); mapping == "" {
+			continue
+		}
+		i := strings.LastIndex(mapping, "=")
+		if i <= 0 || i == len(mapping)-1 {
+			return fmt.Errorf("invalid group mapping %q, expected group=value", mapping)
+		}
+		if err := add(strings.TrimSpace(mapping[:i]), strings.TrimSpace(mapping[i+1:])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initOIDC adds the OIDC provider to the users collection, with the endpoints
+// of the issuer's discovery document. The hub still starts if the issuer
+// can't be reached, so users can sign in with passwords.
+func (h *Hub) initOIDC(usersCollection *core.Collection) {
+	if h.oidc == nil {
+		return
+	}
+	provider, err := h.oidc.providerConfig()
+	if err != nil {
+		h.Logger().Error("Failed to configure OIDC", "issuer", h.oidc.issuer, "err", err)
+		return
+	}
+	providers := slices.DeleteFunc(usersCollection.OAuth2.Providers, func(p core.OAuth2ProviderConfig) bool {
+		return p.Name == auth.NameOIDC
+	})
+	usersCollection.OAuth2.Providers = append(providers, provider)
+	usersCollection.OAuth2.Enabled = true
+}
+
+// providerConfig returns the config of the OIDC provider, with the endpoints
+// of the issuer's discovery document
+func (c *oidcConfig) providerConfig() (core.OAuth2ProviderConfig, error) {
+	var discovery struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+		JwksURI               string `json:"jwks_uri"`
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get(c.issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return core.OAuth2ProviderConfig{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return core.OAuth2ProviderConfig{}, fmt.Errorf("discovery document returned %s", res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(&discovery); err != nil {
+		return core.OAuth2ProviderConfig{}, err
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" {
+		return core.OAuth2ProviderConfig{}, errors.New("discovery document has no authorization or token endpoint")
+	}
+	return core.OAuth2ProviderConfig{
+		Name:         auth.NameOIDC,
+		ClientId:     c.clientId,
+		ClientSecret: c.clientSecret,
+		DisplayName:  c.name,
+		AuthURL:      discovery.AuthorizationEndpoint,
+		TokenURL:     discovery.TokenEndpoint,
+		// the user is read from the verified id_token if there is no userinfo endpoint
+		UserInfoURL: discovery.UserinfoEndpoint,
+		Extra: map[string]any{
+			"jwksURL": discovery.JwksURI,
+			"issuers": []string{c.issuer},
+		},
+	}, nil
+}
+
+// groups returns the groups in the groups claim of an OIDC user
+func (c *oidcConfig) groups(rawUser map[string]any) []string {
+	var value any = rawUser
+	for key := range strings.SplitSeq(c.groupsClaim, ".") {
+		claims, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = claims[key]
+	}
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		groups := make([]string, 0, len(value))
+		for _, group := range value {
+			if group, ok := group.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+// role returns the most privileged role of the groups, or "user" if none of
+// the groups has a role. It returns "" if OIDC_ROLES is not set, so roles are
+// managed in the hub.
+func (c *oidcConfig) role(groups []string) string {
+	if len(c.roles) == 0 {
+		return ""
+	}
+	best := -1
+	for _, group := range groups {
+		if i := slices.Index(oidcRoles, c.roles[group]); i >= 0 && (best < 0 || i < best) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "user"
+	}
+	return oidcRoles[best]
+}
+
+// onOAuth2Login handles OAuth2 logins. Users of the OIDC provider get the role
+// and teams of their groups, and new users are created even if USER_CREATION
+// is not set.
+func (h *Hub) onOAuth2Login(e *core.RecordAuthWithOAuth2RequestEvent) error {
+	// roles are given by admins or groups, not chosen when signing up
+	delete(e.CreateData, "role")
+	if h.oidc == nil || e.ProviderName != auth.NameOIDC {
+		if userCreation, _ := GetEnv("USER_CREATION"); e.IsNewRecord && userCreation != "true" {
+			return e.ForbiddenError("Sign up is disabled", nil)
+		}
+		return e.Next()
+	}
+
+	groups := h.oidc.groups(e.OAuth2User.RawUser)
+	if role := h.oidc.role(groups); role != "" {
+		if e.IsNewRecord {
+			if e.CreateData == nil {
+				e.CreateData = map[string]any{}
+			}
+			e.CreateData["role"] = role
+		} else if e.Record.GetString("role") != role {
+			e.Record.Set("role", role)
+			if err := e.App.Save(e.Record); err != nil {
+				return e.InternalServerError("Failed to update the role", err)
+			}
+		}
+	}
+	if err := e.Next(); err != nil {
+		return err
+	}
+	if err := h.oidc.syncTeams(e.App, e.Record.Id, groups); err != nil {
+		e.App.Logger().Error("Failed to sync OIDC teams", "user", e.Record.Email(), "err", err)
+	}
+	return nil
+}
+
+// syncTeams adds a user to the teams of their groups, creating the teams if
+// needed, and removes them from the other teams in OIDC_TEAMS
+func (c *oidcConfig) syncTeams(app core.App, userId string, groups []string) error {
+	roles := map[string]string{}
+	for group, teams := range c.teams {
+		for _, team := range teams {
+			if _, ok := roles[team.name]; !ok {
+				roles[team.name] = ""
+			}
+			if slices.Contains(groups, group) && teamRoleRank(team.role) > teamRoleRank(roles[team.name]) {
+				roles[team.name] = team.role
+			}
+		}
+	}
+	return app.RunInTransaction(func(txApp core.App) error {
+		for name, role := range roles {
+			team, err := txApp.FindFirstRecordByData("teams", "name", name)
+			if err != nil {
+				if role == "" {
+					continue
+				}
+				collection, err := txApp.FindCachedCollectionByNameOrId("teams")
+				if err != nil {
+					return err
+				}
+				team = core.NewRecord(collection)
+				team.Set("name", name)
+				team.Set("emails", types.JSONArray[string]{})
+				team.Set("webhooks", types.JSONArray[string]{})
+			}
+			if teamRole(team, userId) == role {
+				continue
+			}
+			team.Set("members-", userId)
+			team.Set("admins-", userId)
+			team.Set("operators-", userId)
+			switch role {
+			case "admin":
+				team.Set("admins+", userId)
+			case "operator":
+				team.Set("operators+", userId)
+			case "member":
+				team.Set("members+", userId)
+			}
+			if err := txApp.Save(team); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// teamRole returns the role of a user in a team, or "" if the user isn't in it
+func teamRole(team *core.Record, userId string) string {
+	switch {
+	case slices.Contains(team.GetStringSlice("admins"), userId):
+		return "admin"
+	case slices.Contains(team.GetStringSlice("members"), userId):
+		return "member"
+	case slices.Contains(team.GetStringSlice("operators"), userId):
+		return "operator"
+	}
+	return ""
+}
+
+// teamRoleRank orders team roles by privilege, so users in several groups of
+// a team get the most privileged role
+func teamRoleRank(role string) int {
+	return slices.Index([]string{"", "operator", "member", "admin"}, role)
+}
@@ -0,0 +1,174 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestIdP returns an OIDC provider whose access tokens are the codes of the
+// auth requests, and whose users are the users of the codes
+func newTestIdP(t *testing.T, users map[string]map[string]any) *httptest.Server {
+	var idp *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"userinfo_endpoint":      idp.URL + "/userinfo",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"access_token": r.Form.Get("code"), "token_type": "Bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := users[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(user)
+	})
+	idp = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newTestIdP(t, map[string]map[string]any{
+		"alice-admin": {
+			"sub": "alice", "email": "alice@example.com", "email_verified": true,
+			"groups": []string{"beszel-admins", "client-a"},
+		},
+		"alice-auditor": {
+			"sub": "alice", "email": "alice@example.com", "email_verified": true,
+			"groups": []string{"auditors", "client-a-noc"},
+		},
+		"bob": {"sub": "bob", "email": "bob@example.com", "email_verified": true},
+	})
+	t.Setenv("BESZEL_HUB_OIDC_ISSUER", idp.URL)
+	t.Setenv("BESZEL_HUB_OIDC_CLIENT_ID", "beszel")
+	t.Setenv("BESZEL_HUB_OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("BESZEL_HUB_OIDC_ROLES", "beszel-admins=admin; auditors=readonly")
+	t.Setenv("BESZEL_HUB_OIDC_TEAMS", "client-a=Client A;client-a-noc=Client A:operator")
+
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+	require.NoError(t, hub.StartHub())
+
+	testAppFactory := func(t testing.TB) *tests.TestApp {
+		return hub.TestApp
+	}
+	login := func(code string, createData map[string]any) *strings.Reader {
+		body, _ := json.Marshal(map[string]any{
+			"provider":     "oidc",
+			"code":         code,
+			"codeVerifier": "verifier",
+			"redirectURL":  "http://localhost:8090/api/oauth2-redirect",
+			"createData":   createData,
+		})
+		return strings.NewReader(string(body))
+	}
+	findUser := func(t testing.TB, app core.App, email string) *core.Record {
+		user, err := app.FindAuthRecordByEmail("users", email)
+		require.NoError(t, err)
+		return user
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "lists the OIDC provider",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/users/auth-methods",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"name":"oidc"`, `"displayName":"SSO"`, idp.URL + "/authorize"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "creates users with the role and teams of their groups",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/users/auth-with-oauth2",
+			Body:            login("alice-admin", nil),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"email":"alice@example.com"`, `"role":"admin"`, `"isNew":true`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				user := findUser(t, app, "alice@example.com")
+				team, err := app.FindFirstRecordByData("teams", "name", "Client A")
+				require.NoError(t, err)
+				assert.Equal(t, []string{user.Id}, team.GetStringSlice("members"))
+				assert.Empty(t, team.GetStringSlice("operators"))
+			},
+		},
+		{
+			Name:            "updates the role and teams of existing users",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/users/auth-with-oauth2",
+			Body:            login("alice-auditor", nil),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"email":"alice@example.com"`, `"role":"readonly"`, `"isNew":false`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				user := findUser(t, app, "alice@example.com")
+				team, err := app.FindFirstRecordByData("teams", "name", "Client A")
+				require.NoError(t, err)
+				assert.Empty(t, team.GetStringSlice("members"))
+				assert.Equal(t, []string{user.Id}, team.GetStringSlice("operators"))
+			},
+		},
+		{
+			Name:            "users without mapped groups can't choose their role",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/users/auth-with-oauth2",
+			Body:            login("bob", map[string]any{"role": "admin"}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"email":"bob@example.com"`, `"role":"user"`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				team, err := app.FindFirstRecordByData("teams", "name", "Client A")
+				require.NoError(t, err)
+				assert.NotContains(t, team.GetStringSlice("members"), findUser(t, app, "bob@example.com").Id)
+			},
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestOIDCConfig(t *testing.T) {
+	t.Setenv("BESZEL_HUB_OIDC_ISSUER", "https://id.example.com")
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	assert.ErrorContains(t, hub.StartHub(), "OIDC_CLIENT_ID and OIDC_CLIENT_SECRET are required")
+
+	t.Setenv("BESZEL_HUB_OIDC_CLIENT_ID", "beszel")
+	t.Setenv("BESZEL_HUB_OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("BESZEL_HUB_OIDC_ROLES", "cn=admins,ou=groups=superuser")
+	assert.ErrorContains(t, hub.StartHub(), `invalid role "superuser" of group "cn=admins,ou=groups"`)
+
+	t.Setenv("BESZEL_HUB_OIDC_ROLES", "cn=admins,ou=groups=admin")
+	t.Setenv("BESZEL_HUB_OIDC_TEAMS", "ops")
+	assert.ErrorContains(t, hub.StartHub(), `invalid group mapping "ops"`)
+
+	t.Setenv("BESZEL_HUB_OIDC_TEAMS", "ops=Ops:owner")
+	assert.ErrorContains(t, hub.StartHub(), `invalid team role "owner"`)
+}
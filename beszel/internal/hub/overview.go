@@ -0,0 +1,236 @@
+package hub
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+const (
+	// Number of systems returned in FleetOverview.Hottest
+	hottestSystemsLimit = 5
+	// Default usage threshold in percent (matches the default critical meter color)
+	defaultUsageThreshold = 90.0
+	// Default temperature threshold in celsius
+	defaultTempThreshold = 80.0
+)
+
+// FleetOverview holds aggregates across all systems the user can access
+type FleetOverview struct {
+	Total         int               `json:"total"`
+	Up            int               `json:"up"`
+	Down          int               `json:"down"`
+	Paused        int               `json:"paused"`
+	Pending       int               `json:"pending"`
+	Cores         int               `json:"cores"`
+	Threads       int               `json:"threads"`
+	Power         float64           `json:"power"` // watts, from GPUs and sensors with unit W
+	Hottest       []FleetSystemInfo `json:"hottest"`
+	OverThreshold []FleetSystemInfo `json:"overThreshold"`
+}
+
+// FleetSystemInfo is a summary of a system in the fleet overview
+type FleetSystemInfo struct {
+	Id      string  `json:"id"`
+	Name    string  `json:"name"`
+	Cpu     float64 `json:"cpu"`
+	MemPct  float64 `json:"mp"`
+	DiskPct float64 `json:"dp"`
+	Temp    float64 `json:"dt,omitempty"`
+}
+
+// fleetSystem is a system record and its latest stats used to build the overview
+type fleetSystem struct {
+	Id     string
+	Name   string
+	Status string
+	Info   system.Info
+	Stats  *system.Stats // nil if no recent stats
+}
+
+// fleetThresholds are the limits for FleetOverview.OverThreshold
+type fleetThresholds struct {
+	usage float64 // cpu, memory and disk percent
+	temp  float64 // dashboard temperature in celsius
+}
+
+// getFleetOverview handles GET /api/beszel/fleet-overview.
+// Optional query params "threshold" (usage percent) and "temp" (celsius) set the limits for overThreshold.
+func (h *Hub) getFleetOverview(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	thresholds := fleetThresholds{usage: defaultUsageThreshold, temp: defaultTempThreshold}
+	if v, err := strconv.ParseFloat(query.Get("threshold"), 64); err == nil {
+		thresholds.usage = v
+	}
+	if v, err := strconv.ParseFloat(query.Get("temp"), 64); err == nil {
+		thresholds.temp = v
+	}
+
+	systems, err := h.getFleetSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	return e.JSON(http.StatusOK, newFleetOverview(systems, thresholds))
+}
+
+// getFleetSystems returns the systems visible to the request's user with their latest stats.
+func (h *Hub) getFleetSystems(e *core.RequestEvent) ([]fleetSystem, error) {
+	records, err := findVisibleSystems(e)
+	if err != nil {
+		return nil, err
+	}
+	return newFleetSystems(h.storage, records)
+}
+
+// findVisibleSystems returns the system records visible to the request's user.
+func findVisibleSystems(e *core.RequestEvent) ([]*core.Record, error) {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return nil, err
+	}
+	return findSystemsVisibleTo(e.App, info)
+}
+
+// findSystemsVisibleTo returns the system records visible to the auth record
+// of the request info. Without a list rule, only superusers see systems.
+func findSystemsVisibleTo(app core.App, info *core.RequestInfo) ([]*core.Record, error) {
+	collection, err := app.FindCachedCollectionByNameOrId("systems")
+	if err != nil {
+		return nil, err
+	}
+	if !info.HasSuperuserAuth() && collection.ListRule == nil {
+		return nil, nil
+	}
+
+	// apply the collection's list rule so users only see their own systems
+	query := app.RecordQuery(collection)
+	if !info.HasSuperuserAuth() && *collection.ListRule != "" {
+		resolver := core.NewRecordFieldResolver(app, collection, info, true)
+		expr, err := search.FilterData(*collection.ListRule).BuildExpr(resolver)
+		if err != nil {
+			return nil, err
+		}
+		query.AndWhere(expr)
+		if err := resolver.UpdateQuery(query); err != nil {
+			return nil, err
+		}
+	}
+	var records []*core.Record
+	if err := query.All(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// newFleetSystems loads the latest stats of the system records.
+func newFleetSystems(driver storage.Driver, records []*core.Record) ([]fleetSystem, error) {
+	latestStats, err := getLatestSystemStats(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	systems := make([]fleetSystem, 0, len(records))
+	for _, record := range records {
+		sys := fleetSystem{
+			Id:     record.Id,
+			Name:   record.GetString("name"),
+			Status: record.GetString("status"),
+		}
+		_ = record.UnmarshalJSONField("info", &sys.Info)
+		sys.Stats = latestStats[record.Id]
+		systems = append(systems, sys)
+	}
+	return systems, nil
+}
+
+// getLatestSystemStats returns the latest 1m stats of each system from the last two update intervals.
+func getLatestSystemStats(driver storage.Driver) (map[string]*system.Stats, error) {
+	records, err := driver.SystemStats(storage.Query{Since: time.Now().UTC().Add(-2 * time.Minute)})
+	if err != nil {
+		return nil, err
+	}
+	latestStats := make(map[string]*system.Stats, len(records))
+	for i := range records {
+		latestStats[records[i].System] = &records[i].Stats
+	}
+	return latestStats, nil
+}
+
+// newFleetOverview aggregates the systems into a FleetOverview.
+// Cores, power, hottest and overThreshold only include systems that are up.
+func newFleetOverview(systems []fleetSystem, thresholds fleetThresholds) FleetOverview {
+	overview := FleetOverview{
+		Total:         len(systems),
+		Hottest:       []FleetSystemInfo{},
+		OverThreshold: []FleetSystemInfo{},
+	}
+	for _, sys := range systems {
+		switch sys.Status {
+		case "up":
+			overview.Up++
+		case "down":
+			overview.Down++
+			continue
+		case "paused":
+			overview.Paused++
+			continue
+		default:
+			overview.Pending++
+			continue
+		}
+
+		overview.Cores += sys.Info.Cores
+		overview.Threads += cmp.Or(sys.Info.Threads, sys.Info.Cores)
+		overview.Power += getSystemPower(sys.Stats)
+
+		summary := FleetSystemInfo{
+			Id:      sys.Id,
+			Name:    sys.Name,
+			Cpu:     sys.Info.Cpu,
+			MemPct:  sys.Info.MemPct,
+			DiskPct: sys.Info.DiskPct,
+			Temp:    sys.Info.DashboardTemp,
+		}
+		if summary.Temp > 0 {
+			overview.Hottest = append(overview.Hottest, summary)
+		}
+		if max(summary.Cpu, summary.MemPct, summary.DiskPct) >= thresholds.usage || summary.Temp >= thresholds.temp {
+			overview.OverThreshold = append(overview.OverThreshold, summary)
+		}
+	}
+
+	slices.SortStableFunc(overview.Hottest, func(a, b FleetSystemInfo) int {
+		return cmp.Compare(b.Temp, a.Temp)
+	})
+	if len(overview.Hottest) > hottestSystemsLimit {
+		overview.Hottest = overview.Hottest[:hottestSystemsLimit]
+	}
+	slices.SortStableFunc(overview.OverThreshold, func(a, b FleetSystemInfo) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return overview
+}
+
+// getSystemPower returns the total power draw in watts reported in the stats.
+func getSystemPower(stats *system.Stats) (power float64) {
+	if stats == nil {
+		return 0
+	}
+	for _, gpu := range stats.GPUData {
+		power += gpu.Power
+	}
+	for _, sensor := range stats.GenericSensors {
+		if strings.EqualFold(sensor.Unit, "W") {
+			power += sensor.Value
+		}
+	}
+	return power
+}
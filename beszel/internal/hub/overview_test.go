@@ -0,0 +1,148 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFleetOverview(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+
+	createSystem := func(name, status string, users []string, info system.Info) string {
+		record, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+			"name":   name,
+			"host":   "127.0.0.1",
+			"status": status,
+			"users":  users,
+			"info":   info,
+		})
+		require.NoError(t, err)
+		// the status is set to pending on create
+		record.Set("status", status)
+		require.NoError(t, hub.SaveNoValidate(record))
+		return record.Id
+	}
+
+	hotId := createSystem("hot", "up", []string{user.Id}, system.Info{Cores: 8, Threads: 16, Cpu: 95, DashboardTemp: 85})
+	createSystem("warm", "up", []string{user.Id}, system.Info{Cores: 4, Cpu: 10, DiskPct: 50, DashboardTemp: 60})
+	createSystem("cool", "up", []string{user.Id}, system.Info{Cores: 2, DashboardTemp: 40})
+	createSystem("offline", "down", []string{user.Id}, system.Info{Cores: 16, DashboardTemp: 99})
+	createSystem("stopped", "paused", []string{user.Id}, system.Info{Cores: 16})
+	createSystem("not-mine", "up", []string{otherUser.Id}, system.Info{Cores: 64, Cpu: 100, DashboardTemp: 95})
+
+	_, err = beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+		"system": hotId,
+		"type":   "1m",
+		"stats": system.Stats{
+			GPUData:        map[string]system.GPUData{"0": {Name: "GPU", Power: 120.5}},
+			GenericSensors: map[string]system.SensorData{"ups_realpower": {Value: 300, Unit: "W"}, "pressure": {Value: 1000, Unit: "Pa"}},
+		},
+	})
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /fleet-overview - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/fleet-overview",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /fleet-overview - aggregates user's systems",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/fleet-overview",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"total":5`},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				body, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				var overview struct {
+					Up            int     `json:"up"`
+					Down          int     `json:"down"`
+					Paused        int     `json:"paused"`
+					Cores         int     `json:"cores"`
+					Threads       int     `json:"threads"`
+					Power         float64 `json:"power"`
+					Hottest       []struct{ Name string }
+					OverThreshold []struct{ Name string } `json:"overThreshold"`
+				}
+				require.NoError(t, json.Unmarshal(body, &overview))
+				assert.Equal(t, 3, overview.Up)
+				assert.Equal(t, 1, overview.Down)
+				assert.Equal(t, 1, overview.Paused)
+				assert.Equal(t, 14, overview.Cores)
+				assert.Equal(t, 22, overview.Threads)
+				assert.Equal(t, 420.5, overview.Power)
+				require.Len(t, overview.Hottest, 3)
+				assert.Equal(t, "hot", overview.Hottest[0].Name)
+				assert.Equal(t, "cool", overview.Hottest[2].Name)
+				require.Len(t, overview.OverThreshold, 1)
+				assert.Equal(t, "hot", overview.OverThreshold[0].Name)
+			},
+		},
+		{
+			Name:            "GET /fleet-overview - custom thresholds",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/fleet-overview?threshold=50&temp=100",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"overThreshold":[{"id":"` + hotId + `","name":"hot"`, `"name":"warm","cpu":10,"mp":0,"dp":50`},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:            "GET /fleet-overview - no systems without a list rule",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/fleet-overview",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"total":0`},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+			BeforeTestFunc: func(t testing.TB, app *pbTests.TestApp, e *core.ServeEvent) {
+				collection, err := app.FindCollectionByNameOrId("systems")
+				require.NoError(t, err)
+				collection.ListRule = nil
+				require.NoError(t, app.Save(collection))
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
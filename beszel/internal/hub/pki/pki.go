@@ -0,0 +1,348 @@
+// Package pki is the certificate authority of mTLS connections from the hub to
+// agents. It issues server certificates for agents and the hub's own client
+// certificate, which is rotated before it expires, and keeps a revocation list
+// of agent certificates.
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Files of the CA created in the data directory
+	caCertFile = "mtls_ca.crt"
+	caKeyFile  = "mtls_ca.key"
+	// File of the revocation list, which is kept with either CA
+	crlFile = "mtls_crl.pem"
+
+	caValidity = 10 * 365 * 24 * time.Hour
+	// AgentCertValidity is how long agent server certificates are valid
+	AgentCertValidity = 365 * 24 * time.Hour
+	// ClientCertValidity is how long each hub client certificate is valid. A
+	// new one is issued when a third of its validity is left.
+	ClientCertValidity = 7 * 24 * time.Hour
+	// The revocation list is signed again when half of its validity is left
+	crlValidity = 30 * 24 * time.Hour
+)
+
+var errRevoked = errors.New("certificate is revoked")
+
+// CA issues and verifies the certificates of mTLS connections. It's safe for
+// concurrent use.
+type CA struct {
+	mu         sync.Mutex
+	cert       *x509.Certificate
+	key        crypto.Signer
+	pool       *x509.CertPool
+	crlPath    string
+	revoked    []x509.RevocationListEntry
+	crl        *x509.RevocationList
+	crlDER     []byte
+	client     *tls.Certificate
+	clientLeaf *x509.Certificate
+	now        func() time.Time
+}
+
+// Load loads the CA from certFile and keyFile, such as an intermediate CA of
+// existing PKI. If both are empty, the CA in dataDir is used, and created on
+// first use. The revocation list is kept in dataDir.
+func Load(dataDir, certFile, keyFile string) (*CA, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("both the CA certificate and key are required")
+	}
+	if certFile == "" {
+		certFile = filepath.Join(dataDir, caCertFile)
+		keyFile = filepath.Join(dataDir, caKeyFile)
+		if _, err := os.Stat(certFile); errors.Is(err, os.ErrNotExist) {
+			if err := createCA(certFile, keyFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok || pair.Leaf == nil || !pair.Leaf.IsCA {
+		return nil, fmt.Errorf("%s is not a CA certificate", certFile)
+	}
+	ca := &CA{
+		cert:    pair.Leaf,
+		key:     key,
+		pool:    x509.NewCertPool(),
+		crlPath: filepath.Join(dataDir, crlFile),
+		now:     time.Now,
+	}
+	ca.pool.AddCert(ca.cert)
+	if err := ca.loadCRL(); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// createCA creates a self-signed CA and writes it to certFile and keyFile.
+func createCA(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Beszel hub CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+// newSerial returns a random 128 bit serial number.
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// CertPEM returns the CA certificate, which agents use to verify the hub.
+func (ca *CA) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// issue signs a certificate from the template with a new key.
+func (ca *CA) issue(template *x509.Certificate, validity time.Duration) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if template.SerialNumber, err = newSerial(); err != nil {
+		return nil, nil, err
+	}
+	now := ca.now()
+	template.NotBefore = now.Add(-time.Hour)
+	template.NotAfter = now.Add(validity)
+	if template.NotAfter.After(ca.cert.NotAfter) {
+		template.NotAfter = ca.cert.NotAfter
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+// AgentCert is a server certificate issued for an agent
+type AgentCert struct {
+	Serial   string    `json:"serial"` // hexadecimal, used to revoke the certificate
+	NotAfter time.Time `json:"notAfter"`
+	Cert     string    `json:"cert"` // PEM for TLS_CERT
+	Key      string    `json:"key"`  // PEM for TLS_KEY
+}
+
+// IssueAgent issues a server certificate for an agent, valid for its host
+// names and IP addresses.
+func (ca *CA) IssueAgent(name string, hosts []string) (*AgentCert, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("at least one host is required")
+	}
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	cert, key, err := ca.issue(template, AgentCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentCert{
+		Serial:   cert.SerialNumber.Text(16),
+		NotAfter: cert.NotAfter,
+		Cert:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		Key:      string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+	}, nil
+}
+
+// ParseSerial parses a hexadecimal serial number, as in AgentCert.
+func ParseSerial(value string) (*big.Int, error) {
+	serial, ok := new(big.Int).SetString(strings.ReplaceAll(value, ":", ""), 16)
+	if !ok || serial.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid serial number %q", value)
+	}
+	return serial, nil
+}
+
+// ClientCertificate returns the hub's client certificate, issuing a new one if
+// a third or less of its validity is left.
+func (ca *CA) ClientCertificate() (*tls.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if ca.client != nil && ca.clientLeaf.NotAfter.Sub(ca.now()) > ClientCertValidity/3 {
+		return ca.client, nil
+	}
+	cert, key, err := ca.issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "beszel-hub"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ClientCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	ca.client = &tls.Certificate{
+		Certificate: [][]byte{cert.Raw, ca.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+	ca.clientLeaf = cert
+	return ca.client, nil
+}
+
+// ClientConfig returns the TLS config of a connection to an agent at host.
+// The agent's certificate must be issued by the CA and not revoked.
+func (ca *CA) ClientConfig(host string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		RootCAs:    ca.pool,
+		ServerName: host,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return ca.ClientCertificate()
+		},
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) > 0 && ca.IsRevoked(state.PeerCertificates[0].SerialNumber) {
+				return errRevoked
+			}
+			return nil
+		},
+	}
+}
+
+// IsRevoked reports whether a certificate is in the revocation list.
+func (ca *CA) IsRevoked(serial *big.Int) bool {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	return slices.ContainsFunc(ca.revoked, func(entry x509.RevocationListEntry) bool {
+		return entry.SerialNumber.Cmp(serial) == 0
+	})
+}
+
+// Revoke adds a certificate to the revocation list.
+func (ca *CA) Revoke(serial *big.Int) error {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	for _, entry := range ca.revoked {
+		if entry.SerialNumber.Cmp(serial) == 0 {
+			return nil
+		}
+	}
+	ca.revoked = append(ca.revoked, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: ca.now()})
+	return ca.signCRL()
+}
+
+// CRLPEM returns the revocation list signed by the CA, for agents that check
+// the hub's certificate against it. It's signed again before it expires.
+func (ca *CA) CRLPEM() ([]byte, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if ca.crl == nil || ca.crl.NextUpdate.Sub(ca.now()) < crlValidity/2 {
+		if err := ca.signCRL(); err != nil {
+			return nil, err
+		}
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: ca.crlDER}), nil
+}
+
+// signCRL signs the revocation list and writes it to the CRL file. The CRL
+// number must increase with each list.
+func (ca *CA) signCRL() error {
+	number := big.NewInt(1)
+	if ca.crl != nil {
+		number.Add(ca.crl.Number, number)
+	}
+	now := ca.now()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    number,
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(crlValidity),
+		RevokedCertificateEntries: ca.revoked,
+	}, ca.cert, ca.key)
+	if err != nil {
+		return err
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(ca.crlPath, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	ca.crl, ca.crlDER = crl, der
+	return nil
+}
+
+// loadCRL loads the revocation list from the CRL file, if it exists.
+func (ca *CA) loadCRL() error {
+	data, err := os.ReadFile(ca.crlPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("invalid CRL in %s", ca.crlPath)
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		return err
+	}
+	// a list of another CA, e.g. after replacing the CA, is not kept
+	if crl.CheckSignatureFrom(ca.cert) != nil {
+		return nil
+	}
+	ca.crl, ca.crlDER, ca.revoked = crl, block.Bytes, crl.RevokedCertificateEntries
+	return nil
+}
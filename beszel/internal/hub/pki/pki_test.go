@@ -0,0 +1,110 @@
+//go:build testing
+// +build testing
+
+package pki
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// handshake connects a client with the CA's config to a server with the
+// agent certificate, which requires a client certificate from the CA.
+func handshake(t *testing.T, ca *CA, agent *AgentCert) error {
+	pair, err := tls.X509KeyPair([]byte(agent.Cert), []byte(agent.Key))
+	require.NoError(t, err)
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	server := tls.Server(serverConn, &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.pool,
+	})
+	go func() {
+		_ = server.Handshake()
+		server.Close()
+	}()
+	return tls.Client(clientConn, ca.ClientConfig("agent.lan")).Handshake()
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := Load(dir, "", "")
+	require.NoError(t, err)
+	assert.True(t, ca.cert.IsCA)
+
+	// the CA is created once
+	again, err := Load(dir, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, ca.CertPEM(), again.CertPEM())
+
+	_, err = Load(dir, dir+"/mtls_ca.crt", "")
+	assert.Error(t, err)
+	_, err = Load(t.TempDir(), dir+"/mtls_ca.crt", dir+"/mtls_ca.key")
+	assert.NoError(t, err, "existing CA files can be used")
+}
+
+func TestIssueAndRevoke(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := Load(dir, "", "")
+	require.NoError(t, err)
+
+	agent, err := ca.IssueAgent("agent", []string{"agent.lan", "10.0.0.5"})
+	require.NoError(t, err)
+	block, _ := pem.Decode([]byte(agent.Cert))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"agent.lan"}, cert.DNSNames)
+	assert.Len(t, cert.IPAddresses, 1)
+	assert.NoError(t, handshake(t, ca, agent))
+
+	_, err = ca.IssueAgent("agent", nil)
+	assert.Error(t, err)
+
+	// revoked certificates are rejected, also after loading the CA again
+	serial, err := ParseSerial(agent.Serial)
+	require.NoError(t, err)
+	require.NoError(t, ca.Revoke(serial))
+	assert.ErrorContains(t, handshake(t, ca, agent), "revoked")
+
+	ca, err = Load(dir, "", "")
+	require.NoError(t, err)
+	assert.True(t, ca.IsRevoked(serial))
+	crlPEM, err := ca.CRLPEM()
+	require.NoError(t, err)
+	block, _ = pem.Decode(crlPEM)
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	require.NoError(t, err)
+	require.Len(t, crl.RevokedCertificateEntries, 1)
+	assert.Equal(t, 0, crl.RevokedCertificateEntries[0].SerialNumber.Cmp(serial))
+
+	_, err = ParseSerial("not hex")
+	assert.Error(t, err)
+}
+
+func TestClientCertificateRotation(t *testing.T) {
+	ca, err := Load(t.TempDir(), "", "")
+	require.NoError(t, err)
+	now := time.Now()
+	ca.now = func() time.Time { return now }
+
+	first, err := ca.ClientCertificate()
+	require.NoError(t, err)
+	same, err := ca.ClientCertificate()
+	require.NoError(t, err)
+	assert.Same(t, first, same)
+
+	// a new certificate is issued when a third of the validity is left
+	now = now.Add(ClientCertValidity * 3 / 4)
+	rotated, err := ca.ClientCertificate()
+	require.NoError(t, err)
+	assert.NotSame(t, first, rotated)
+	assert.True(t, rotated.Leaf.NotAfter.After(first.Leaf.NotAfter))
+}
@@ -0,0 +1,175 @@
+package hub
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// apiQuota counts API requests of each user and limits them if API_QUOTA is set,
+// e.g. API_QUOTA=120/m. Requests of a user refill evenly over the period, up to
+// the full quota, so short bursts are allowed. Superusers are not limited.
+type apiQuota struct {
+	limit  int           // requests per period, 0 if not limited
+	period time.Duration // period of the limit
+	mu     sync.Mutex
+	users  map[string]*apiUsage
+}
+
+// apiUsage is the request counters and remaining quota of a user
+type apiUsage struct {
+	requests    uint64
+	limited     uint64
+	lastRequest time.Time
+	tokens      float64 // requests left, refilled over the period
+	refilled    time.Time
+}
+
+// APIUsage is the usage of a user returned by GET /api/beszel/api-usage
+type APIUsage struct {
+	User        string    `json:"user"`
+	Requests    uint64    `json:"requests"`
+	Limited     uint64    `json:"limited"`
+	Remaining   int       `json:"remaining,omitempty"`
+	LastRequest time.Time `json:"lastRequest"`
+}
+
+// APIUsageResponse is the payload returned by GET /api/beszel/api-usage
+type APIUsageResponse struct {
+	Limit  int        `json:"limit"`            // requests per period, 0 if not limited
+	Period float64    `json:"period,omitempty"` // seconds
+	Users  []APIUsage `json:"users"`
+}
+
+// newAPIQuota returns the quota set with the API_QUOTA env var. Without it,
+// requests are counted but not limited.
+func newAPIQuota() (*apiQuota, error) {
+	quota := &apiQuota{users: make(map[string]*apiUsage)}
+	if value, _ := GetEnv("API_QUOTA"); value != "" {
+		var err error
+		if quota.limit, quota.period, err = parseAPIQuota(value); err != nil {
+			return nil, err
+		}
+	}
+	return quota, nil
+}
+
+// parseAPIQuota parses a quota of "<requests>/<period>", where the period is s,
+// m, h or a duration like 30s. The period defaults to a minute.
+func parseAPIQuota(value string) (int, time.Duration, error) {
+	countStr, periodStr, _ := strings.Cut(strings.TrimSpace(value), "/")
+	count, err := strconv.Atoi(strings.TrimSpace(countStr))
+	if err != nil || count < 1 {
+		return 0, 0, fmt.Errorf("invalid API_QUOTA %q", value)
+	}
+	period := time.Minute
+	switch periodStr = strings.TrimSpace(periodStr); periodStr {
+	case "", "m":
+	case "s":
+		period = time.Second
+	case "h":
+		period = time.Hour
+	default:
+		if period, err = time.ParseDuration(periodStr); err != nil || period <= 0 {
+			return 0, 0, fmt.Errorf("invalid API_QUOTA %q", value)
+		}
+	}
+	return count, period, nil
+}
+
+// middleware counts and limits API requests of authenticated users. Runs after
+// the auth token is loaded.
+func (q *apiQuota) middleware() *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Id:       "beszelApiQuota",
+		Priority: apis.DefaultRateLimitMiddlewarePriority + 1,
+		Func: func(e *core.RequestEvent) error {
+			if e.Auth == nil || e.HasSuperuserAuth() || !strings.HasPrefix(e.Request.URL.Path, "/api/") {
+				return e.Next()
+			}
+			remaining, retryAfter, ok := q.take(e.Auth.Id, time.Now())
+			if q.limit > 0 {
+				header := e.Response.Header()
+				header.Set("X-RateLimit-Limit", strconv.Itoa(q.limit))
+				header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+				if !ok {
+					header.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+					return e.TooManyRequestsError("API quota exceeded", nil)
+				}
+			}
+			return e.Next()
+		},
+	}
+}
+
+// take counts a request of a user and takes one from their quota. Returns the
+// requests left, and if the quota is used up, the time until the next request is allowed.
+func (q *apiQuota) take(userId string, now time.Time) (remaining int, retryAfter time.Duration, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	usage, exists := q.users[userId]
+	if !exists {
+		usage = &apiUsage{tokens: float64(q.limit), refilled: now}
+		q.users[userId] = usage
+	}
+	usage.requests++
+	usage.lastRequest = now
+	if q.limit == 0 {
+		return 0, 0, true
+	}
+	q.refill(usage, now)
+	if usage.tokens < 1 {
+		usage.limited++
+		return 0, time.Duration((1 - usage.tokens) * float64(q.period) / float64(q.limit)), false
+	}
+	usage.tokens--
+	return int(usage.tokens), 0, true
+}
+
+// refill adds the requests earned since the last refill
+func (q *apiQuota) refill(usage *apiUsage, now time.Time) {
+	elapsed := now.Sub(usage.refilled)
+	usage.tokens = min(float64(q.limit), usage.tokens+elapsed.Seconds()*float64(q.limit)/q.period.Seconds())
+	usage.refilled = now
+}
+
+// usage returns the usage of a user, or of all users if userId is empty, busiest first.
+func (q *apiQuota) usage(userId string, now time.Time) APIUsageResponse {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	response := APIUsageResponse{Limit: q.limit, Period: q.period.Seconds(), Users: []APIUsage{}}
+	for id, usage := range q.users {
+		if userId != "" && id != userId {
+			continue
+		}
+		item := APIUsage{User: id, Requests: usage.requests, Limited: usage.limited, LastRequest: usage.lastRequest.UTC()}
+		if q.limit > 0 {
+			q.refill(usage, now)
+			item.Remaining = int(usage.tokens)
+		}
+		response.Users = append(response.Users, item)
+	}
+	slices.SortFunc(response.Users, func(a, b APIUsage) int {
+		return cmp.Or(cmp.Compare(b.Requests, a.Requests), strings.Compare(a.User, b.User))
+	})
+	return response
+}
+
+// handleUsage returns the API usage of the user, or of all users for admins
+func (q *apiQuota) handleUsage(e *core.RequestEvent) error {
+	userId := e.Auth.Id
+	if e.HasSuperuserAuth() || e.Auth.GetString("role") == "admin" {
+		userId = e.Request.URL.Query().Get("user")
+	}
+	return e.JSON(http.StatusOK, q.usage(userId, time.Now()))
+}
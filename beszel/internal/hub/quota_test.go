@@ -0,0 +1,145 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIQuota(t *testing.T) {
+	t.Setenv("BESZEL_HUB_API_QUOTA", "2/h")
+
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+
+	admin, err := beszelTests.CreateUser(hub, "admin@example.com", "password123")
+	require.NoError(t, err)
+	admin.Set("role", "admin")
+	require.NoError(t, hub.Save(admin))
+	adminToken, err := admin.NewAuthToken()
+	require.NoError(t, err)
+
+	superusers, err := hub.FindCollectionByNameOrId(core.CollectionNameSuperusers)
+	require.NoError(t, err)
+	superuser := core.NewRecord(superusers)
+	superuser.SetEmail("superuser@example.com")
+	superuser.SetPassword("password123")
+	require.NoError(t, hub.Save(superuser))
+	superuserToken, err := superuser.NewAuthToken()
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+	assertRemaining := func(remaining string) func(testing.TB, *pbTests.TestApp, *http.Response) {
+		return func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+			assert.Equal(t, "2", res.Header.Get("X-RateLimit-Limit"))
+			assert.Equal(t, remaining, res.Header.Get("X-RateLimit-Remaining"))
+		}
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /getkey - first request",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/getkey",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"key"`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+			AfterTestFunc:   assertRemaining("1"),
+		},
+		{
+			Name:            "GET /getkey - second request",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/getkey",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"key"`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+			AfterTestFunc:   assertRemaining("0"),
+		},
+		{
+			Name:            "GET /getkey - quota exceeded",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/getkey",
+			ExpectedStatus:  429,
+			ExpectedContent: []string{"API quota exceeded"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				assert.Equal(t, "1800", res.Header.Get("Retry-After"))
+			},
+		},
+		{
+			Name:            "GET /getkey - other users have their own quota",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/getkey",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"key"`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": adminToken},
+			AfterTestFunc:   assertRemaining("1"),
+		},
+		{
+			Name:            "GET /api-usage - admin sees all users",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/api-usage",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"limit":2`, `"period":3600`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": adminToken},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				body, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				var usage struct {
+					Users []struct {
+						User     string
+						Requests int
+						Limited  int
+					}
+				}
+				require.NoError(t, json.Unmarshal(body, &usage))
+				require.Len(t, usage.Users, 2)
+				assert.Equal(t, user.Id, usage.Users[0].User)
+				assert.Equal(t, 3, usage.Users[0].Requests)
+				assert.Equal(t, 1, usage.Users[0].Limited)
+				assert.Equal(t, admin.Id, usage.Users[1].User)
+				assert.Equal(t, 2, usage.Users[1].Requests)
+			},
+		},
+		{
+			Name:            "GET /collections - superusers are not limited",
+			Method:          http.MethodGet,
+			URL:             "/api/collections",
+			ExpectedStatus:  200,
+			TestAppFactory:  testAppFactory,
+			ExpectedContent: []string{`"items"`},
+			Headers:         map[string]string{"Authorization": superuserToken},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				assert.Empty(t, res.Header.Get("X-RateLimit-Limit"))
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,44 @@
+package hub
+
+import (
+	"beszel/internal/hub/remotewrite"
+	"log/slog"
+	"strings"
+)
+
+// newRemoteWriteConfig returns the remote write config, or nil if REMOTE_WRITE_URL is not set.
+func newRemoteWriteConfig() *remotewrite.Config {
+	url, _ := GetEnv("REMOTE_WRITE_URL")
+	if url == "" {
+		return nil
+	}
+	config := &remotewrite.Config{URL: url, Labels: make(map[string]string)}
+	config.Username, _ = GetEnv("REMOTE_WRITE_USERNAME")
+	config.Password, _ = GetEnv("REMOTE_WRITE_PASSWORD")
+	config.Token, _ = GetEnv("REMOTE_WRITE_TOKEN")
+	labels, _ := GetEnv("REMOTE_WRITE_LABELS")
+	for entry := range strings.SplitSeq(labels, ",") {
+		name, value, ok := strings.Cut(entry, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			if entry != "" {
+				slog.Warn("Invalid REMOTE_WRITE_LABELS entry", "entry", entry)
+			}
+			continue
+		}
+		config.Labels[name] = value
+	}
+	return config
+}
+
+// startRemoteWrite forwards system stats to REMOTE_WRITE_URL, if set.
+func (h *Hub) startRemoteWrite() {
+	config := newRemoteWriteConfig()
+	if config == nil {
+		return
+	}
+	h.Logger().Info("Forwarding stats to remote write endpoint", "url", config.URL)
+	config.Mask = h.mask
+	h.remoteWrite = remotewrite.New(h, *config)
+	h.remoteWrite.Start()
+}
@@ -0,0 +1,70 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteWrite(t *testing.T) {
+	var requests int
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		data, _ := io.ReadAll(r.Body)
+		body, _ = snappy.Decode(nil, data)
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "pass", pass)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	t.Setenv("BESZEL_HUB_REMOTE_WRITE_URL", server.URL)
+	t.Setenv("BESZEL_HUB_REMOTE_WRITE_USERNAME", "user")
+	t.Setenv("BESZEL_HUB_REMOTE_WRITE_PASSWORD", "pass")
+	t.Setenv("BESZEL_HUB_REMOTE_WRITE_LABELS", "hub=prod")
+
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+	hub.StartHub()
+	user, err := beszelTests.CreateUser(hub, "test@example.com", "password123")
+	require.NoError(t, err)
+	exporter := hub.GetRemoteWrite()
+	require.NotNil(t, exporter)
+
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "web-server",
+		"host":  "127.0.0.1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+	// stats are exported when they are added, with any storage driver
+	hub.ExportStats(systemRecord, &system.Stats{Cpu: 42}, time.Now())
+
+	require.NoError(t, exporter.Flush())
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, 1, countOccurrences(body, "beszel_cpu_usage_percent"))
+	assert.Contains(t, string(body), "web-server", "series are labeled with the system name")
+	assert.Contains(t, string(body), "prod")
+}
+
+func countOccurrences(b []byte, s string) (n int) {
+	for i := 0; i+len(s) <= len(b); i++ {
+		if string(b[i:i+len(s)]) == s {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,213 @@
+// Package remotewrite forwards system stats to a Prometheus remote write endpoint.
+package remotewrite
+
+import (
+	"beszel"
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/masking"
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/pocketbase/pocketbase/core"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// flushInterval is how often queued samples are sent
+	flushInterval = 10 * time.Second
+	// maxPending is the max number of series kept while the endpoint is unavailable
+	maxPending = 50_000
+)
+
+// Config holds the remote write settings.
+type Config struct {
+	URL      string            // Remote write endpoint, e.g. http://localhost:8428/api/v1/write
+	Username string            // Username for basic auth
+	Password string            // Password for basic auth
+	Token    string            // Bearer token, used instead of basic auth if set
+	Labels   map[string]string // Labels added to every series, e.g. hub=prod
+	Mask     *masking.Policy   // Masks system names, nil to send them as is
+}
+
+// Label is a Prometheus label.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// TimeSeries is a single sample with its labels, sorted by name.
+type TimeSeries struct {
+	Labels    []Label
+	Value     float64
+	Timestamp int64 // milliseconds since epoch
+}
+
+// Exporter sends system stats to a remote write endpoint in batches.
+type Exporter struct {
+	app    core.App
+	config Config
+	client *http.Client
+	mu     sync.Mutex
+	queue  []TimeSeries
+	done   chan struct{}
+}
+
+// New creates an exporter. Call Start to begin forwarding stats.
+func New(app core.App, config Config) *Exporter {
+	return &Exporter{
+		app:    app,
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+		done:   make(chan struct{}),
+	}
+}
+
+// Start forwards stats added with AddStats until the app terminates.
+func (e *Exporter) Start() {
+	e.app.OnTerminate().BindFunc(func(te *core.TerminateEvent) error {
+		close(e.done)
+		return te.Next()
+	})
+	go e.run()
+}
+
+// run sends queued series every flushInterval, and once more on shutdown.
+func (e *Exporter) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.done:
+			_ = e.Flush()
+			return
+		}
+		if err := e.Flush(); err != nil {
+			e.app.Logger().Error("Remote write failed", "err", err)
+		}
+	}
+}
+
+// AddStats queues the one minute stats of a system, saved at t.
+func (e *Exporter) AddStats(systemId, systemName string, stats *system.Stats, t time.Time) {
+	e.Enqueue(e.Series(systemId, e.config.Mask.Hostname(systemName), stats, t))
+}
+
+// Series converts stats to time series labeled with the system and configured labels.
+func (e *Exporter) Series(systemId, systemName string, stats *system.Stats, t time.Time) []TimeSeries {
+	metrics := stats.Metrics()
+	series := make([]TimeSeries, 0, len(metrics))
+	for _, metric := range metrics {
+		labels := make([]Label, 0, len(metric.Labels)/2+len(e.config.Labels)+3)
+		labels = append(labels,
+			Label{"__name__", "beszel_" + metric.Name},
+			Label{"system", systemName},
+			Label{"system_id", systemId},
+		)
+		for i := 0; i+1 < len(metric.Labels); i += 2 {
+			labels = append(labels, Label{metric.Labels[i], metric.Labels[i+1]})
+		}
+		for _, name := range slices.Sorted(maps.Keys(e.config.Labels)) {
+			labels = append(labels, Label{name, e.config.Labels[name]})
+		}
+		sort.SliceStable(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		series = append(series, TimeSeries{Labels: labels, Value: metric.Value, Timestamp: t.UnixMilli()})
+	}
+	return series
+}
+
+// Enqueue adds series to be sent on the next flush, dropping the oldest
+// series if the endpoint has been unavailable for too long.
+func (e *Exporter) Enqueue(series []TimeSeries) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.queue = append(e.queue, series...)
+	if over := len(e.queue) - maxPending; over > 0 {
+		e.queue = slices.Delete(e.queue, 0, over)
+	}
+}
+
+// Flush sends all queued series. Series are kept for the next flush if the
+// request fails with a network or server error.
+func (e *Exporter) Flush() error {
+	e.mu.Lock()
+	series := e.queue
+	e.queue = nil
+	e.mu.Unlock()
+	if len(series) == 0 {
+		return nil
+	}
+	retry, err := e.send(series)
+	if err != nil && retry {
+		e.mu.Lock()
+		e.queue = append(series, e.queue...)
+		e.mu.Unlock()
+	}
+	return err
+}
+
+// send posts series to the endpoint and reports whether a failure can be retried.
+func (e *Exporter) send(series []TimeSeries) (retry bool, err error) {
+	body := snappy.Encode(nil, EncodeWriteRequest(series))
+	req, err := http.NewRequest(http.MethodPost, e.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", beszel.AppName+"/"+beszel.Version)
+	if e.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.config.Token)
+	} else if e.config.Username != "" {
+		req.SetBasicAuth(e.config.Username, e.config.Password)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests,
+			fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return false, nil
+}
+
+// EncodeWriteRequest encodes series as a Prometheus WriteRequest protobuf message.
+func EncodeWriteRequest(series []TimeSeries) []byte {
+	var b []byte
+	for _, ts := range series {
+		var tsb []byte
+		for _, label := range ts.Labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, label.Name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, label.Value)
+			tsb = protowire.AppendTag(tsb, 1, protowire.BytesType)
+			tsb = protowire.AppendBytes(tsb, lb)
+		}
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(ts.Value))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(ts.Timestamp))
+		tsb = protowire.AppendTag(tsb, 2, protowire.BytesType)
+		tsb = protowire.AppendBytes(tsb, sb)
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, tsb)
+	}
+	return b
+}
@@ -0,0 +1,171 @@
+//go:build testing
+// +build testing
+
+package remotewrite
+
+import (
+	"beszel/internal/entities/system"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest decodes a WriteRequest protobuf message for testing.
+func decodeWriteRequest(t *testing.T, b []byte) []TimeSeries {
+	t.Helper()
+	// fields returns the bytes and fixed64 / varint values of each field number
+	fields := func(b []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, n uint64)) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			require.GreaterOrEqual(t, n, 0)
+			b = b[n:]
+			switch typ {
+			case protowire.BytesType:
+				v, n := protowire.ConsumeBytes(b)
+				fn(num, typ, v, 0)
+				b = b[n:]
+			case protowire.Fixed64Type:
+				v, n := protowire.ConsumeFixed64(b)
+				fn(num, typ, nil, v)
+				b = b[n:]
+			case protowire.VarintType:
+				v, n := protowire.ConsumeVarint(b)
+				fn(num, typ, nil, v)
+				b = b[n:]
+			default:
+				t.Fatalf("unexpected wire type %d", typ)
+			}
+		}
+	}
+	var series []TimeSeries
+	fields(b, func(_ protowire.Number, _ protowire.Type, tsb []byte, _ uint64) {
+		var ts TimeSeries
+		fields(tsb, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+			switch num {
+			case 1:
+				var label Label
+				fields(v, func(num protowire.Number, _ protowire.Type, s []byte, _ uint64) {
+					if num == 1 {
+						label.Name = string(s)
+					} else {
+						label.Value = string(s)
+					}
+				})
+				ts.Labels = append(ts.Labels, label)
+			case 2:
+				fields(v, func(num protowire.Number, _ protowire.Type, _ []byte, n uint64) {
+					if num == 1 {
+						ts.Value = math.Float64frombits(n)
+					} else {
+						ts.Timestamp = int64(n)
+					}
+				})
+			}
+		})
+		series = append(series, ts)
+	})
+	return series
+}
+
+func TestSeries(t *testing.T) {
+	e := New(nil, Config{Labels: map[string]string{"hub": "prod", "a": "b"}})
+	now := time.Unix(1700000000, 0)
+	series := e.Series("abc123", "web", &system.Stats{
+		Cpu:          12.5,
+		Temperatures: map[string]float64{"cpu": 55},
+	}, now)
+
+	require.NotEmpty(t, series)
+	assert.Equal(t, TimeSeries{
+		Labels: []Label{
+			{"__name__", "beszel_cpu_usage_percent"},
+			{"a", "b"},
+			{"hub", "prod"},
+			{"system", "web"},
+			{"system_id", "abc123"},
+		},
+		Value:     12.5,
+		Timestamp: now.UnixMilli(),
+	}, series[0])
+
+	var temp *TimeSeries
+	for i := range series {
+		if series[i].Labels[0].Value == "beszel_temperature_celsius" {
+			temp = &series[i]
+		}
+	}
+	require.NotNil(t, temp)
+	assert.Equal(t, 55.0, temp.Value)
+	assert.Contains(t, temp.Labels, Label{"sensor", "cpu"})
+	for _, ts := range series {
+		for i := 1; i < len(ts.Labels); i++ {
+			assert.Less(t, ts.Labels[i-1].Name, ts.Labels[i].Name, "labels are sorted by name")
+		}
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	series := []TimeSeries{
+		{Labels: []Label{{"__name__", "a"}, {"x", "y"}}, Value: 1.5, Timestamp: 1000},
+		{Labels: []Label{{"__name__", "b"}}, Value: -2, Timestamp: 2000},
+	}
+	assert.Equal(t, series, decodeWriteRequest(t, EncodeWriteRequest(series)))
+}
+
+func TestFlush(t *testing.T) {
+	var received []TimeSeries
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "0.1.0", r.Header.Get("X-Prometheus-Remote-Write-Version"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		data, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		if status/100 == 2 {
+			received = append(received, decodeWriteRequest(t, data)...)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	e := New(nil, Config{URL: server.URL, Token: "secret"})
+	assert.NoError(t, e.Flush(), "nothing to send")
+
+	series := []TimeSeries{{Labels: []Label{{"__name__", "a"}}, Value: 1, Timestamp: 1000}}
+
+	// server errors are retried on the next flush
+	status = http.StatusServiceUnavailable
+	e.Enqueue(series)
+	assert.Error(t, e.Flush())
+	assert.Len(t, e.queue, 1)
+
+	status = http.StatusNoContent
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, series, received)
+	assert.Empty(t, e.queue)
+
+	// client errors are dropped
+	status = http.StatusBadRequest
+	e.Enqueue(series)
+	assert.ErrorContains(t, e.Flush(), "400")
+	assert.Empty(t, e.queue)
+}
+
+func TestEnqueueLimit(t *testing.T) {
+	e := New(nil, Config{})
+	e.Enqueue(make([]TimeSeries, maxPending))
+	e.Enqueue([]TimeSeries{{Value: 1}})
+	require.Len(t, e.queue, maxPending)
+	assert.Equal(t, 1.0, e.queue[maxPending-1].Value, "oldest series are dropped")
+}
@@ -0,0 +1,151 @@
+package hub
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// Default time checkpoints stay paused if the snapshot never resumes them
+	defaultReplicationPause = 10 * time.Minute
+	// Longest time checkpoints can be paused
+	maxReplicationPause = time.Hour
+	// Same as the default PocketBase pragmas, with autocheckpoints disabled
+	replicationPragmas = "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=journal_size_limit(200000000)&_pragma=synchronous(NORMAL)&_pragma=wal_autocheckpoint(0)&_pragma=foreign_keys(ON)&_pragma=temp_store(MEMORY)&_pragma=cache_size(-16000)"
+)
+
+var errReplicationPaused = errors.New("checkpoints are paused for a replication snapshot")
+
+// ReplicationEnabled reports whether the hub runs in replication mode, set with
+// REPLICATION_MODE=true. The mode tunes SQLite for continuous replication tools
+// like Litestream and LiteFS, which copy WAL frames before they are checkpointed:
+//
+//   - SQLite autocheckpoints are disabled (see ReplicationDBConnect)
+//   - PocketBase's daily TRUNCATE checkpoint, which resets the WAL under the
+//     replicator, is replaced by a PASSIVE checkpoint every five minutes
+//   - superusers can pause checkpoints and backups while taking a snapshot
+func ReplicationEnabled() bool {
+	enabled, _ := GetEnv("REPLICATION_MODE")
+	return enabled == "true"
+}
+
+// ReplicationDBConnect opens a SQLite database with autocheckpoints disabled.
+// Used as the PocketBase DBConnect function in replication mode.
+func ReplicationDBConnect(dbPath string) (*dbx.DB, error) {
+	return dbx.Open("sqlite", dbPath+replicationPragmas)
+}
+
+// replicationMode runs checkpoints unless they are paused for a snapshot
+type replicationMode struct {
+	app         core.App
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// ReplicationStatus is the payload returned by the replication API routes
+type ReplicationStatus struct {
+	Paused      bool      `json:"paused"`
+	PausedUntil time.Time `json:"pausedUntil,omitzero"`
+}
+
+// newReplicationMode returns nil unless REPLICATION_MODE is enabled
+func newReplicationMode(app core.App) *replicationMode {
+	if !ReplicationEnabled() {
+		return nil
+	}
+	return &replicationMode{app: app}
+}
+
+// status returns whether checkpoints are paused
+func (r *replicationMode) status() ReplicationStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Now().Before(r.pausedUntil) {
+		return ReplicationStatus{Paused: true, PausedUntil: r.pausedUntil.UTC()}
+	}
+	return ReplicationStatus{}
+}
+
+// pause pauses checkpoints for up to d, so a crashed snapshot script can't
+// leave the WAL growing forever.
+func (r *replicationMode) pause(d time.Duration) ReplicationStatus {
+	r.mu.Lock()
+	r.pausedUntil = time.Now().Add(min(d, maxReplicationPause))
+	r.mu.Unlock()
+	return r.status()
+}
+
+// resume resumes checkpoints
+func (r *replicationMode) resume() ReplicationStatus {
+	r.mu.Lock()
+	r.pausedUntil = time.Time{}
+	r.mu.Unlock()
+	return r.status()
+}
+
+// checkpoint moves WAL frames into the main and auxiliary databases without
+// resetting the WAL, unless checkpoints are paused.
+func (r *replicationMode) checkpoint() error {
+	if r.status().Paused {
+		return errReplicationPaused
+	}
+	for _, db := range []dbx.Builder{r.app.NonconcurrentDB(), r.app.AuxNonconcurrentDB()} {
+		if _, err := db.NewQuery("PRAGMA wal_checkpoint(PASSIVE)").Execute(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerCronJobs replaces the PocketBase checkpoint job with PASSIVE checkpoints
+func (r *replicationMode) registerCronJobs() {
+	r.app.Cron().Remove("__pbDBOptimize__")
+	r.app.Cron().MustAdd("replication checkpoint", "*/5 * * * *", func() {
+		if err := r.checkpoint(); err != nil && !errors.Is(err, errReplicationPaused) {
+			r.app.Logger().Error("Failed to checkpoint database", "err", err)
+		}
+	})
+	r.app.Cron().MustAdd("optimize database", "0 0 * * *", func() {
+		if _, err := r.app.NonconcurrentDB().NewQuery("PRAGMA optimize").Execute(); err != nil {
+			r.app.Logger().Warn("Failed to optimize database", "err", err)
+		}
+	})
+}
+
+// registerApiRoutes adds the superuser routes to pause and resume checkpoints
+func (r *replicationMode) registerApiRoutes(se *core.ServeEvent) {
+	group := se.Router.Group("/api/beszel/replication")
+	group.Bind(apis.RequireSuperuserAuth())
+	group.GET("", func(e *core.RequestEvent) error {
+		return e.JSON(http.StatusOK, r.status())
+	})
+	// pause checkpoints, e.g. POST /api/beszel/replication/pause?duration=15m
+	group.POST("/pause", func(e *core.RequestEvent) error {
+		d := defaultReplicationPause
+		if value := e.Request.URL.Query().Get("duration"); value != "" {
+			var err error
+			if d, err = time.ParseDuration(value); err != nil || d <= 0 {
+				return e.BadRequestError("Invalid duration", err)
+			}
+		}
+		return e.JSON(http.StatusOK, r.pause(d))
+	}).BindFunc(auditRoute("replication.pause"))
+	group.POST("/resume", func(e *core.RequestEvent) error {
+		return e.JSON(http.StatusOK, r.resume())
+	}).BindFunc(auditRoute("replication.resume"))
+}
+
+// blockPausedBackups fails backups while checkpoints are paused, since they
+// run a TRUNCATE checkpoint
+func (r *replicationMode) blockPausedBackups(e *core.BackupEvent) error {
+	if r.status().Paused {
+		return errReplicationPaused
+	}
+	return e.Next()
+}
@@ -0,0 +1,115 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicationMode(t *testing.T) {
+	t.Setenv("BESZEL_HUB_REPLICATION_MODE", "true")
+
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+
+	superusers, err := hub.FindCollectionByNameOrId(core.CollectionNameSuperusers)
+	require.NoError(t, err)
+	superuser := core.NewRecord(superusers)
+	superuser.SetEmail("superuser@example.com")
+	superuser.SetPassword("password123")
+	require.NoError(t, hub.Save(superuser))
+	superuserToken, err := superuser.NewAuthToken()
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /replication - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/replication",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "POST /replication/pause - user should fail",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/replication/pause",
+			ExpectedStatus:  403,
+			ExpectedContent: []string{"The authorized record is not allowed"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:            "POST /replication/pause - invalid duration",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/replication/pause?duration=soon",
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid duration"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": superuserToken},
+		},
+		{
+			Name:            "POST /replication/pause - superuser",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/replication/pause?duration=5m",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"paused":true`, `"pausedUntil"`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": superuserToken},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				var jobs []string
+				for _, job := range app.Cron().Jobs() {
+					jobs = append(jobs, job.Id())
+				}
+				assert.NotContains(t, jobs, "__pbDBOptimize__", "TRUNCATE checkpoint job is removed")
+				assert.Contains(t, jobs, "replication checkpoint")
+				assert.Error(t, app.CreateBackup(context.Background(), "paused.zip"), "backups are blocked while paused")
+			},
+		},
+		{
+			Name:            "GET /replication - paused",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/replication",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"paused":true`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": superuserToken},
+		},
+		{
+			Name:            "POST /replication/resume - superuser",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/replication/resume",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`{"paused":false}`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": superuserToken},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				assert.NoError(t, app.CreateBackup(context.Background(), "resumed.zip"))
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,195 @@
+package hub
+
+import (
+	"beszel/internal/hub/masking"
+	"beszel/internal/hub/storage"
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// Default and max number of search results
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// Max length of a search query
+	maxSearchQueryLength = 128
+)
+
+// Kinds of search results
+const (
+	searchKindSystem    = "system"
+	searchKindContainer = "container"
+	searchKindSensor    = "sensor"
+)
+
+// SearchResult is a system, container or sensor matching a search query
+type SearchResult struct {
+	Kind     string `json:"kind"`            // "system", "container" or "sensor"
+	Name     string `json:"name"`            // matched name
+	System   string `json:"system"`          // name of the system
+	SystemId string `json:"systemId"`        // id of the system
+	Chart    string `json:"chart,omitempty"` // id of the chart showing the result on the system page
+	Score    int    `json:"score"`
+}
+
+// getSearch handles GET /api/beszel/search?q=<query>. Returns the systems,
+// containers and sensors visible to the user with names matching the query,
+// best matches first. The optional "limit" query param sets the max number of
+// results, 20 by default.
+func (h *Hub) getSearch(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" || utf8.RuneCountInString(q) > maxSearchQueryLength {
+		return e.BadRequestError("Invalid query", nil)
+	}
+	limit := defaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxSearchLimit {
+			return e.BadRequestError("Invalid limit", err)
+		}
+	}
+
+	systems, err := h.getFleetSystems(e)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	containers, err := getLatestContainerNames(h.storage)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+
+	// readonly users only see masked names, so they can't search for the real ones
+	var mask *masking.Policy
+	if e.Auth != nil && e.Auth.GetString("role") == "readonly" {
+		mask = h.mask
+	}
+	results := searchFleet(q, systems, containers, mask)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return e.JSON(http.StatusOK, results)
+}
+
+// getLatestContainerNames returns the names of the containers of each system
+// from the last two update intervals.
+func getLatestContainerNames(driver storage.Driver) (map[string][]string, error) {
+	records, err := driver.ContainerStats(storage.Query{Since: time.Now().UTC().Add(-2 * time.Minute)})
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string][]string, len(records))
+	for _, record := range records {
+		systemNames := make([]string, 0, len(record.Stats))
+		for _, stats := range record.Stats {
+			systemNames = append(systemNames, stats.Name)
+		}
+		// records are oldest first, so the latest record wins
+		names[record.System] = systemNames
+	}
+	return names, nil
+}
+
+// searchFleet returns the systems, containers and sensors matching the query,
+// sorted by score.
+func searchFleet(q string, systems []fleetSystem, containers map[string][]string, mask *masking.Policy) []SearchResult {
+	q = strings.ToLower(q)
+	results := []SearchResult{}
+	add := func(kind, name, chart string, sys fleetSystem, systemName string) {
+		if score := fuzzyScore(q, name); score > 0 {
+			results = append(results, SearchResult{
+				Kind:     kind,
+				Name:     name,
+				System:   systemName,
+				SystemId: sys.Id,
+				Chart:    chart,
+				Score:    score,
+			})
+		}
+	}
+	for _, sys := range systems {
+		systemName := mask.Hostname(sys.Name)
+		// match the system by name or hostname, but only list it once
+		score := max(fuzzyScore(q, systemName), fuzzyScore(q, mask.Hostname(sys.Info.Hostname)))
+		if score > 0 {
+			results = append(results, SearchResult{
+				Kind:     searchKindSystem,
+				Name:     systemName,
+				System:   systemName,
+				SystemId: sys.Id,
+				Score:    score,
+			})
+		}
+		for _, name := range containers[sys.Id] {
+			add(searchKindContainer, mask.Container(name), "containers", sys, systemName)
+		}
+		if sys.Stats == nil {
+			continue
+		}
+		for name := range sys.Stats.Temperatures {
+			add(searchKindSensor, name, "temperature", sys, systemName)
+		}
+		for name := range sys.Stats.GenericSensors {
+			add(searchKindSensor, name, "sensor-"+name, sys, systemName)
+		}
+	}
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(a.System, b.System),
+		)
+	})
+	return results
+}
+
+// fuzzyScore scores how well a name matches a lowercase query, from 0 (no
+// match) to 100 (same name). Names containing the query score higher than
+// names with the query's characters scattered in order, and matches at the
+// start of the name or of a word score higher than matches inside a word.
+func fuzzyScore(q, name string) int {
+	if q == "" || name == "" {
+		return 0
+	}
+	name = strings.ToLower(name)
+	switch i := strings.Index(name, q); {
+	case name == q:
+		return 100
+	case i == 0:
+		return 90
+	case i > 0 && isWordStart(name, i):
+		return 80
+	case i > 0:
+		return 70
+	}
+
+	// characters of the query in order, with a penalty for each gap
+	gaps, pos := 0, 0
+	for _, r := range q {
+		i := strings.IndexRune(name[pos:], r)
+		if i < 0 {
+			return 0
+		}
+		if i > 0 {
+			gaps++
+		}
+		pos += i + utf8.RuneLen(r)
+	}
+	return max(60-gaps*5, 1)
+}
+
+// isWordStart reports whether the byte at i starts a word of the name.
+func isWordStart(name string, i int) bool {
+	switch name[i-1] {
+	case '-', '_', '.', ' ', '/', ':':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"beszel/internal/hub"
+	beszelTests "beszel/internal/tests"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+
+	nas, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "nas",
+		"host":  "127.0.0.1",
+		"users": []string{user.Id},
+		"info":  system.Info{Hostname: "vault-host"},
+	})
+	require.NoError(t, err)
+	other, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "other-vault",
+		"host":  "127.0.0.2",
+		"users": []string{otherUser.Id},
+	})
+	require.NoError(t, err)
+	for _, systemId := range []string{nas.Id, other.Id} {
+		_, err = beszelTests.CreateRecord(hub, "container_stats", map[string]any{
+			"system": systemId,
+			"type":   "1m",
+			"stats":  []container.Stats{{Name: "vaultwarden"}, {Name: "nginx"}},
+		})
+		require.NoError(t, err)
+	}
+	_, err = beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+		"system": nas.Id,
+		"type":   "1m",
+		"stats": system.Stats{
+			Temperatures:   map[string]float64{"nvme_composite": 40},
+			GenericSensors: map[string]system.SensorData{"ups_load": {Value: 30, Unit: "%"}},
+		},
+	})
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /search - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/search?q=vault",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /search - missing query",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/search?q=+",
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid query"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:            "GET /search - containers and systems of the user",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/search?q=vault",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{"vaultwarden"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				body, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				var results []map[string]any
+				require.NoError(t, json.Unmarshal(body, &results))
+				require.Len(t, results, 2)
+				// the system matches by hostname
+				assert.Equal(t, "system", results[0]["kind"])
+				assert.Equal(t, "nas", results[0]["name"])
+				assert.Equal(t, "container", results[1]["kind"])
+				assert.Equal(t, "vaultwarden", results[1]["name"])
+				assert.Equal(t, "containers", results[1]["chart"])
+				assert.Equal(t, nas.Id, results[1]["systemId"])
+			},
+		},
+		{
+			Name:            "GET /search - sensors",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/search?q=nvme",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"kind":"sensor","name":"nvme_composite","system":"nas"`, `"chart":"temperature"`},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:            "GET /search - limit",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/search?q=n&limit=1",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`[{"kind":"system","name":"nas"`},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		q, name string
+		want    int
+	}{
+		{"nginx", "NGINX", 100},
+		{"vault", "vaultwarden", 90},
+		{"warden", "vault-warden", 80},
+		{"warden", "vaultwarden", 70},
+		{"vw", "vaultwarden", 55},
+		{"vwn", "vaultwarden", 50},
+		{"xyz", "vaultwarden", 0},
+		{"", "vaultwarden", 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, hub.FuzzyScore(tt.q, tt.name), "%s in %s", tt.q, tt.name)
+	}
+}
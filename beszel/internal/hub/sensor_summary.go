@@ -0,0 +1,43 @@
+package hub
+
+import (
+	"beszel/internal/records"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// getSensorSummary handles GET /api/beszel/sensor-summary?system=<id>. Returns
+// the min, max, average and fastest rise of each sensor of the system, from
+// the daily sensor rollups. The optional "days" query param sets the number of
+// days including today, 1 by default, up to the retention of the rollups.
+func (h *Hub) getSensorSummary(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	systemRecord, err := e.App.FindRecordById("systems", query.Get("system"))
+	if err != nil {
+		return e.NotFoundError("System not found", err)
+	}
+	info, err := e.RequestInfo()
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	if canAccess, err := e.App.CanAccessRecord(systemRecord, info, systemRecord.Collection().ViewRule); !canAccess {
+		return e.NotFoundError("System not found", err)
+	}
+
+	days := 1
+	maxDays := int(h.rm.SensorRollupRetention() / (24 * time.Hour))
+	if value := query.Get("days"); value != "" {
+		if days, err = strconv.Atoi(value); err != nil || days < 1 || days > maxDays {
+			return e.BadRequestError("Invalid days", err)
+		}
+	}
+	since := time.Now().UTC().AddDate(0, 0, 1-days)
+	summaries, err := records.SummarizeSensors(e.App, systemRecord.Id, since)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	return e.JSON(http.StatusOK, summaries)
+}
@@ -0,0 +1,113 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/records"
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"testing"
+	"time"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSensorSummary(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+	otherToken, err := otherUser.NewAuthToken()
+	require.NoError(t, err)
+
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "nas",
+		"host":  "127.0.0.1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	// yesterday's peak and today's readings
+	today := time.Now().UTC().Truncate(24 * time.Hour).Add(time.Hour)
+	for i, temp := range []float64{72, 50, 52, 51} {
+		at := today.Add(time.Duration(i) * time.Minute)
+		if i == 0 {
+			at = today.AddDate(0, 0, -1)
+		}
+		stats := &system.Stats{Temperatures: map[string]float64{"nvme": temp}}
+		require.NoError(t, records.UpdateSensorRollups(hub, systemRecord.Id, stats, at))
+	}
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /sensor-summary - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/sensor-summary?system=" + systemRecord.Id,
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /sensor-summary - other user's system is not found",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/sensor-summary?system=" + systemRecord.Id,
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"System not found"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": otherToken,
+			},
+		},
+		{
+			Name:            "GET /sensor-summary - today",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/sensor-summary?system=" + systemRecord.Id,
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"type":"temperature","sensor":"nvme","unit":"°C","min":50`, `"max":52`, `"avg":51`, `"maxRate":2`},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:            "GET /sensor-summary - two days",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/sensor-summary?system=" + systemRecord.Id + "&days=2",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"min":50`, `"max":72`, `"avg":56.25`},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:            "GET /sensor-summary - invalid days",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/sensor-summary?system=" + systemRecord.Id + "&days=400",
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid days"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
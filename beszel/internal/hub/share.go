@@ -0,0 +1,225 @@
+package hub
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Longest time a share link can be valid
+const maxShareLinkDuration = 90 * 24 * time.Hour
+
+// Metric groups a share link can be limited to
+var shareMetrics = []string{"cpu", "memory", "disk", "network", "sensors", "containers"}
+
+// Time range of the records of each type, matching the chart times of the UI
+var shareChartRanges = map[string]time.Duration{
+	storage.Type1m:   time.Hour,
+	storage.Type10m:  12 * time.Hour,
+	storage.Type20m:  24 * time.Hour,
+	storage.Type120m: 7 * 24 * time.Hour,
+	storage.Type480m: 30 * 24 * time.Hour,
+}
+
+// SharedSystem is the payload returned by GET /api/beszel/share/{token}
+type SharedSystem struct {
+	Name       string                 `json:"name"`
+	Status     string                 `json:"status"`
+	Expires    time.Time              `json:"expires"`
+	Metrics    []string               `json:"metrics"`
+	Info       system.Info            `json:"info"`
+	Stats      []SharedStats          `json:"stats"`
+	Containers []SharedContainerStats `json:"containers,omitempty"`
+}
+
+// SharedStats is a stats record of a shared system, with only the shared metrics
+type SharedStats struct {
+	Created time.Time    `json:"created"`
+	Stats   system.Stats `json:"stats"`
+}
+
+// SharedContainerStats is a container stats record of a shared system
+type SharedContainerStats struct {
+	Created time.Time         `json:"created"`
+	Stats   []container.Stats `json:"stats"`
+}
+
+// validateShareLink checks the metrics and expiration of a new share link. The
+// token is always generated by the hub.
+func validateShareLink(e *core.RecordRequestEvent) error {
+	var metrics []string
+	if err := e.Record.UnmarshalJSONField("metrics", &metrics); err != nil {
+		return e.BadRequestError("Invalid metrics", err)
+	}
+	for _, metric := range metrics {
+		if !slices.Contains(shareMetrics, metric) {
+			return e.BadRequestError("Invalid metric: "+metric, nil)
+		}
+	}
+	expires := e.Record.GetDateTime("expires").Time()
+	if !expires.After(time.Now()) || time.Until(expires) > maxShareLinkDuration {
+		return e.BadRequestError("Share links must expire within 90 days", nil)
+	}
+	e.Record.Set("token", "")
+	return e.Next()
+}
+
+// getSharedSystem handles GET /api/beszel/share/{token}. Returns the stats of
+// the link's system for its shared metrics, without authentication. The
+// optional "type" query param sets the record type, 1m by default.
+func (h *Hub) getSharedSystem(e *core.RequestEvent) error {
+	link, err := e.App.FindFirstRecordByFilter("share_links", "token = {:token} && expires > {:now}", dbx.Params{
+		"token": e.Request.PathValue("token"),
+		"now":   time.Now().UTC(),
+	})
+	if err != nil {
+		return e.NotFoundError("Share link not found or expired", err)
+	}
+	systemRecord, err := e.App.FindRecordById("systems", link.GetString("system"))
+	if err != nil {
+		return e.NotFoundError("Share link not found or expired", err)
+	}
+
+	recordType := e.Request.URL.Query().Get("type")
+	if recordType == "" {
+		recordType = storage.Type1m
+	}
+	chartRange, ok := shareChartRanges[recordType]
+	if !ok {
+		return e.BadRequestError("Invalid type", nil)
+	}
+
+	var metrics []string
+	_ = link.UnmarshalJSONField("metrics", &metrics)
+	if len(metrics) == 0 {
+		metrics = shareMetrics
+	}
+	var info system.Info
+	_ = systemRecord.UnmarshalJSONField("info", &info)
+	shared := SharedSystem{
+		Name:    h.mask.Hostname(systemRecord.GetString("name")),
+		Status:  systemRecord.GetString("status"),
+		Expires: link.GetDateTime("expires").Time(),
+		Metrics: metrics,
+		Info:    sharedInfo(info, metrics),
+		Stats:   []SharedStats{},
+	}
+
+	query := storage.Query{System: systemRecord.Id, Type: recordType, Since: time.Now().UTC().Add(-chartRange)}
+	records, err := h.storage.SystemStats(query)
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	for i := range records {
+		shared.Stats = append(shared.Stats, SharedStats{
+			Created: records[i].Created,
+			Stats:   sharedStats(&records[i].Stats, metrics),
+		})
+	}
+	if slices.Contains(metrics, "containers") {
+		containerRecords, err := h.storage.ContainerStats(query)
+		if err != nil {
+			return e.InternalServerError("", err)
+		}
+		shared.Containers = make([]SharedContainerStats, 0, len(containerRecords))
+		for _, record := range containerRecords {
+			for i := range record.Stats {
+				record.Stats[i].Name = h.mask.Container(record.Stats[i].Name)
+			}
+			shared.Containers = append(shared.Containers, SharedContainerStats{Created: record.Created, Stats: record.Stats})
+		}
+	}
+
+	e.Response.Header().Set("Cache-Control", "no-store")
+	return e.JSON(http.StatusOK, shared)
+}
+
+// sharedInfo returns the system info without the hostname, versions and
+// health details, and with only the shared metrics.
+func sharedInfo(info system.Info, metrics []string) system.Info {
+	shared := system.Info{
+		Cores:    info.Cores,
+		Threads:  info.Threads,
+		CpuModel: info.CpuModel,
+		Uptime:   info.Uptime,
+		Os:       info.Os,
+	}
+	if slices.Contains(metrics, "cpu") {
+		shared.Cpu = info.Cpu
+		shared.LoadAvg = info.LoadAvg
+		shared.GpuPct = info.GpuPct
+	}
+	if slices.Contains(metrics, "memory") {
+		shared.MemPct = info.MemPct
+	}
+	if slices.Contains(metrics, "disk") {
+		shared.DiskPct = info.DiskPct
+	}
+	if slices.Contains(metrics, "network") {
+		shared.Bandwidth = info.Bandwidth
+		shared.BandwidthBytes = info.BandwidthBytes
+	}
+	if slices.Contains(metrics, "sensors") {
+		shared.DashboardTemp = info.DashboardTemp
+	}
+	return shared
+}
+
+// sharedStats returns a copy of the stats with only the shared metrics.
+func sharedStats(stats *system.Stats, metrics []string) system.Stats {
+	var shared system.Stats
+	if slices.Contains(metrics, "cpu") {
+		shared.Cpu = stats.Cpu
+		shared.MaxCpu = stats.MaxCpu
+		shared.LoadAvg = stats.LoadAvg
+		shared.GPUData = stats.GPUData
+		shared.CpuCores = stats.CpuCores
+		shared.CpuFreq = stats.CpuFreq
+		shared.CpuThrottled = stats.CpuThrottled
+	}
+	if slices.Contains(metrics, "memory") {
+		shared.Mem = stats.Mem
+		shared.MemUsed = stats.MemUsed
+		shared.MemPct = stats.MemPct
+		shared.MemBuffCache = stats.MemBuffCache
+		shared.MemZfsArc = stats.MemZfsArc
+		shared.MemCached = stats.MemCached
+		shared.MemBuffers = stats.MemBuffers
+		shared.MemAvailable = stats.MemAvailable
+		shared.MemHugePages = stats.MemHugePages
+		shared.MemHugePagesUsed = stats.MemHugePagesUsed
+		shared.Swap = stats.Swap
+		shared.SwapUsed = stats.SwapUsed
+	}
+	if slices.Contains(metrics, "disk") {
+		shared.DiskTotal = stats.DiskTotal
+		shared.DiskUsed = stats.DiskUsed
+		shared.DiskPct = stats.DiskPct
+		shared.DiskReadPs = stats.DiskReadPs
+		shared.DiskWritePs = stats.DiskWritePs
+		shared.MaxDiskReadPs = stats.MaxDiskReadPs
+		shared.MaxDiskWritePs = stats.MaxDiskWritePs
+		shared.ExtraFs = stats.ExtraFs
+	}
+	if slices.Contains(metrics, "network") {
+		shared.NetworkSent = stats.NetworkSent
+		shared.NetworkRecv = stats.NetworkRecv
+		shared.MaxNetworkSent = stats.MaxNetworkSent
+		shared.MaxNetworkRecv = stats.MaxNetworkRecv
+		shared.Bandwidth = stats.Bandwidth
+		shared.MaxBandwidth = stats.MaxBandwidth
+		shared.NetworkInterfaces = stats.NetworkInterfaces
+	}
+	if slices.Contains(metrics, "sensors") {
+		shared.Temperatures = stats.Temperatures
+		shared.GenericSensors = stats.GenericSensors
+		shared.SensorCategories = stats.SensorCategories
+	}
+	return shared
+}
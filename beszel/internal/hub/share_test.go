@@ -0,0 +1,201 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"testing"
+	"time"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareLinks(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	readonlyUser, err := beszelTests.CreateUser(hub, "readonly@example.com", "password123")
+	require.NoError(t, err)
+	readonlyUser.Set("role", "readonly")
+	require.NoError(t, hub.Save(readonlyUser))
+	readonlyToken, err := readonlyUser.NewAuthToken()
+	require.NoError(t, err)
+
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "friend-box",
+		"host":  "127.0.0.1",
+		"users": []string{user.Id, readonlyUser.Id},
+		"info":  system.Info{Hostname: "secret-host", Cpu: 12.5, MemPct: 41.5, AgentVersion: "0.12.0"},
+	})
+	require.NoError(t, err)
+	_, err = beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+		"system": systemRecord.Id,
+		"type":   "1m",
+		"stats":  system.Stats{Cpu: 12.5, MemUsed: 7.77, Temperatures: map[string]float64{"nvme": 40}},
+	})
+	require.NoError(t, err)
+
+	newLink := func(metrics []string, expires time.Time) string {
+		record, err := beszelTests.CreateRecord(hub, "share_links", map[string]any{
+			"user":    user.Id,
+			"system":  systemRecord.Id,
+			"token":   "",
+			"metrics": metrics,
+			"expires": expires,
+		})
+		require.NoError(t, err)
+		return record.GetString("token")
+	}
+	cpuLink := newLink([]string{"cpu"}, time.Now().Add(time.Hour))
+	allLink := newLink(nil, time.Now().Add(time.Hour))
+	expiredLink := newLink(nil, time.Now().Add(-time.Minute))
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+	expires := func(d time.Duration) string {
+		date, _ := types.ParseDateTime(time.Now().Add(d))
+		return date.String()
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:               "GET /share - metric subset",
+			Method:             http.MethodGet,
+			URL:                "/api/beszel/share/" + cpuLink,
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"name":"friend-box"`, `"metrics":["cpu"]`, `"cpu":12.5`},
+			NotExpectedContent: []string{"7.77", "nvme", "41.5", "secret-host", "0.12.0"},
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:               "GET /share - all metrics",
+			Method:             http.MethodGet,
+			URL:                "/api/beszel/share/" + allLink + "?type=1m",
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"mu":7.77`, `"nvme":40`, `"mp":41.5`},
+			NotExpectedContent: []string{"secret-host"},
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:            "GET /share - invalid type",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/share/" + allLink + "?type=5m",
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid type"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /share - expired link",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/share/" + expiredLink,
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"Share link not found or expired"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /share - unknown token",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/share/unknown",
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"Share link not found or expired"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:   "POST share_links - token is generated",
+			Method: http.MethodPost,
+			URL:    "/api/collections/share_links/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"system":  systemRecord.Id,
+				"token":   "chosen-by-the-client-chosen-by-the-client",
+				"metrics": []string{"cpu", "network"},
+				"expires": expires(24 * time.Hour),
+			}),
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"metrics":["cpu","network"]`},
+			NotExpectedContent: []string{"chosen-by-the-client"},
+			TestAppFactory:     testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:   "POST share_links - invalid metric",
+			Method: http.MethodPost,
+			URL:    "/api/collections/share_links/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"system":  systemRecord.Id,
+				"metrics": []string{"alerts"},
+				"expires": expires(24 * time.Hour),
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid metric: alerts"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:   "POST share_links - expiration too far",
+			Method: http.MethodPost,
+			URL:    "/api/collections/share_links/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"system":  systemRecord.Id,
+				"expires": expires(100 * 24 * time.Hour),
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"expire within 90 days"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": userToken,
+			},
+		},
+		{
+			Name:   "POST share_links - readonly user can't share",
+			Method: http.MethodPost,
+			URL:    "/api/collections/share_links/records",
+			Body: jsonReader(map[string]any{
+				"user":    readonlyUser.Id,
+				"system":  systemRecord.Id,
+				"expires": expires(24 * time.Hour),
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Failed to create record"},
+			TestAppFactory:  testAppFactory,
+			Headers: map[string]string{
+				"Authorization": readonlyToken,
+			},
+		},
+		{
+			Name:   "POST share_links - no auth",
+			Method: http.MethodPost,
+			URL:    "/api/collections/share_links/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"system":  systemRecord.Id,
+				"expires": expires(24 * time.Hour),
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Failed to create record"},
+			TestAppFactory:  testAppFactory,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,312 @@
+package hub
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// Default time between snapshots downloaded by a standby hub
+	defaultStandbyInterval = time.Minute
+	// Longest time between health checks of the primary hub
+	standbyHealthInterval = 10 * time.Second
+	// Max time to download a snapshot
+	standbySnapshotTimeout = 10 * time.Minute
+	// Database file in the data dir
+	standbyDBFile = "data.db"
+)
+
+// Files sent with the database if they exist in the primary's data dir, so
+// agents and mTLS certificates keep working after a standby is promoted
+var standbyFiles = []string{"id_ed25519", "mask_key", "mtls_ca.crt", "mtls_ca.key", "config.yml"}
+
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// StandbyOptions configures a standby hub
+type StandbyOptions struct {
+	PrimaryURL   string        // base URL of the primary hub
+	Token        string        // STANDBY_TOKEN of the primary hub
+	Interval     time.Duration // time between snapshots
+	PromoteAfter time.Duration // time the primary must be down before promotion, 0 to never promote
+}
+
+// getStandbySnapshot handles GET /api/beszel/standby/snapshot, enabled with
+// STANDBY_TOKEN. It returns a gzipped tar of a copy of the database and the
+// hub's keys. The token is passed with the X-Standby-Token header.
+func (h *Hub) getStandbySnapshot(e *core.RequestEvent) error {
+	token, _ := GetEnv("STANDBY_TOKEN")
+	if subtle.ConstantTimeCompare([]byte(e.Request.Header.Get("X-Standby-Token")), []byte(token)) != 1 {
+		return e.UnauthorizedError("Invalid standby token", nil)
+	}
+	dir, err := os.MkdirTemp(h.DataDir(), ".snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// VACUUM INTO writes a consistent copy without blocking writes
+	dbPath := filepath.Join(dir, standbyDBFile)
+	if _, err := h.DB().NewQuery("VACUUM INTO {:path}").Bind(dbx.Params{"path": dbPath}).Execute(); err != nil {
+		return e.InternalServerError("Failed to copy database", err)
+	}
+
+	// the archive is written to a file first, so errors are returned before
+	// any of the response is sent
+	archivePath := filepath.Join(dir, "snapshot.tar.gz")
+	if err := h.writeStandbyArchive(archivePath, dbPath); err != nil {
+		return e.InternalServerError("Failed to create snapshot", err)
+	}
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	info, err := archive.Stat()
+	if err != nil {
+		return err
+	}
+	e.Response.Header().Set("Content-Type", "application/gzip")
+	e.Response.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	e.Response.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(e.Response, archive); err != nil {
+		// the status is already sent, so the standby sees a truncated archive
+		h.Logger().Warn("Failed to send standby snapshot", "err", err)
+	}
+	return nil
+}
+
+// writeStandbyArchive writes a gzipped tar of the database copy at dbPath and
+// the standbyFiles in the data dir to path.
+func (h *Hub) writeStandbyArchive(path, dbPath string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	if err := addTarFile(tw, standbyDBFile, dbPath); err != nil {
+		return err
+	}
+	for _, name := range standbyFiles {
+		if err := addTarFile(tw, name, filepath.Join(h.DataDir(), name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// addTarFile writes the file at path to the archive as name
+func addTarFile(tw *tar.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// Standby keeps the data dir in sync with the primary hub until the primary has
+// been down for opts.PromoteAfter, then bootstraps the app again so it can serve
+// as the new primary. A standby only promotes after a successful sync since it
+// started, and doesn't serve requests before that.
+func (h *Hub) Standby(ctx context.Context, opts StandbyOptions) error {
+	if opts.PrimaryURL == "" || opts.Token == "" {
+		return errors.New("standby requires the primary URL and STANDBY_TOKEN")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultStandbyInterval
+	}
+	s := &standbySync{
+		primaryURL: strings.TrimSuffix(opts.PrimaryURL, "/"),
+		token:      opts.Token,
+		dataDir:    h.DataDir(),
+		client:     &http.Client{Timeout: standbySnapshotTimeout},
+	}
+	// release the database so it can be replaced. The app logger writes to the
+	// closed database until promotion, so slog is used instead.
+	if err := h.ResetBootstrapState(); err != nil {
+		return err
+	}
+	slog.Info("Running as standby", "primary", s.primaryURL, "interval", opts.Interval, "promoteAfter", opts.PromoteAfter)
+
+	ticker := time.NewTicker(min(opts.Interval, standbyHealthInterval))
+	defer ticker.Stop()
+	var lastSync, lastHealthy time.Time
+	for {
+		now := time.Now()
+		if err := s.checkHealth(ctx); err != nil {
+			slog.Warn("Primary hub is down", "err", err)
+		} else {
+			lastHealthy = now
+			if now.Sub(lastSync) >= opts.Interval {
+				if err := s.sync(ctx); err != nil {
+					slog.Error("Failed to sync with primary hub", "err", err)
+				} else {
+					lastSync = now
+					slog.Debug("Synced with primary hub")
+				}
+			}
+		}
+		if opts.PromoteAfter > 0 && !lastSync.IsZero() && now.Sub(lastHealthy) >= opts.PromoteAfter {
+			slog.Warn("Promoting standby hub", "lastSync", lastSync)
+			return h.Bootstrap()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// standbySync downloads snapshots of the primary hub into the data dir
+type standbySync struct {
+	primaryURL string
+	token      string
+	dataDir    string
+	client     *http.Client
+}
+
+// checkHealth checks the health endpoint of the primary hub
+func (s *standbySync) checkHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.primaryURL+"/api/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sync downloads a snapshot and replaces the database and keys in the data dir.
+// Files are extracted to a temporary dir first, so a failed download doesn't
+// leave a partial database.
+func (s *standbySync) sync(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.primaryURL+"/api/beszel/standby/snapshot", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Standby-Token", s.token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("snapshot returned status %d", resp.StatusCode)
+	}
+
+	dir, err := os.MkdirTemp(s.dataDir, ".standby-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	names, err := extractSnapshot(resp.Body, dir)
+	if err != nil {
+		return err
+	}
+	if err := checkSQLiteFile(filepath.Join(dir, standbyDBFile)); err != nil {
+		return err
+	}
+	// the WAL of the old database would be applied to the new one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(filepath.Join(s.dataDir, standbyDBFile+suffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, name := range names {
+		if err := os.Rename(filepath.Join(dir, name), filepath.Join(s.dataDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractSnapshot extracts the files of a snapshot into dir, returning their
+// names. Only the database and standbyFiles are extracted.
+func extractSnapshot(r io.Reader, dir string) ([]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name != standbyDBFile && !slices.Contains(standbyFiles, header.Name) {
+			continue
+		}
+		file, err := os.OpenFile(filepath.Join(dir, header.Name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(file, tr)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, header.Name)
+	}
+}
+
+// checkSQLiteFile checks that the file at path starts with the SQLite header
+func checkSQLiteFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	header := make([]byte, len(sqliteHeader))
+	if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header, sqliteHeader) {
+		return errors.New("snapshot is not a SQLite database")
+	}
+	return nil
+}
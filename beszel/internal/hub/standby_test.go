@@ -0,0 +1,138 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"archive/tar"
+	"beszel/internal/hub"
+	beszelTests "beszel/internal/tests"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandby(t *testing.T) {
+	t.Setenv("BESZEL_HUB_STANDBY_TOKEN", "standby-secret")
+
+	primary, _ := beszelTests.NewTestHub(t.TempDir())
+	defer primary.Cleanup()
+
+	primary.StartHub()
+
+	_, err := beszelTests.CreateUser(primary, "replicated@example.com", "password123")
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return primary.TestApp
+	}
+
+	var snapshot []byte
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /standby/snapshot - no token should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/standby/snapshot",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"Invalid standby token"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /standby/snapshot - wrong token should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/standby/snapshot",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"Invalid standby token"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"X-Standby-Token": "wrong"},
+		},
+		{
+			Name:            "GET /standby/snapshot - valid token",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/standby/snapshot",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{"\x1f\x8b"}, // gzip header
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"X-Standby-Token": "standby-secret"},
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				var err error
+				snapshot, err = io.ReadAll(res.Body)
+				require.NoError(t, err)
+				// the archive is complete before it's sent
+				assert.Equal(t, strconv.Itoa(len(snapshot)), res.Header.Get("Content-Length"))
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+
+	// the snapshot is a gzipped tar that starts with the database
+	gz, err := gzip.NewReader(bytes.NewReader(snapshot))
+	require.NoError(t, err)
+	header, err := tar.NewReader(gz).Next()
+	require.NoError(t, err)
+	assert.Equal(t, "data.db", header.Name)
+
+	// fake primary that goes down after the first snapshot
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case down.Load():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/api/health":
+			w.WriteHeader(http.StatusOK)
+		case r.Header.Get("X-Standby-Token") != "standby-secret":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			_, _ = w.Write(snapshot)
+			down.Store(true)
+		}
+	}))
+	defer server.Close()
+
+	standby, _ := beszelTests.NewTestHub(t.TempDir())
+	defer standby.Cleanup()
+
+	_, err = standby.FindAuthRecordByEmail("users", "replicated@example.com")
+	require.Error(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = standby.Standby(ctx, hub.StandbyOptions{
+		PrimaryURL:   server.URL,
+		Token:        "standby-secret",
+		Interval:     20 * time.Millisecond,
+		PromoteAfter: 100 * time.Millisecond,
+	})
+	require.NoError(t, err, "standby is promoted when the primary goes down")
+
+	// the promoted hub has the primary's data
+	_, err = standby.FindAuthRecordByEmail("users", "replicated@example.com")
+	assert.NoError(t, err)
+
+	// a standby that never synced doesn't promote
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err = standby.Standby(ctx, hub.StandbyOptions{
+		PrimaryURL:   server.URL,
+		Token:        "standby-secret",
+		Interval:     20 * time.Millisecond,
+		PromoteAfter: 50 * time.Millisecond,
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.Error(t, standby.Standby(context.Background(), hub.StandbyOptions{PrimaryURL: server.URL}), "token is required")
+}
@@ -0,0 +1,133 @@
+package hub
+
+import (
+	"beszel/internal/hub/storage"
+	"beszel/internal/hub/storage/postgres"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Record types that can be requested from /api/beszel/stats
+var statsTypes = []string{storage.Type1m, storage.Type10m, storage.Type20m, storage.Type120m, storage.Type480m}
+
+// max records per system of stats queries by time range that don't set a limit
+const defaultMaxDataPoints = 1000
+
+// statsRecord is a record returned by /api/beszel/stats
+type statsRecord struct {
+	Created time.Time `json:"created"`
+	Stats   any       `json:"stats"`
+}
+
+// initStatsDB stores stats records in the PostgreSQL or TimescaleDB database
+// at STATS_DB instead of the hub's database. Sensor rollups, events and other
+// records stay in the hub's database.
+func (h *Hub) initStatsDB(retention map[string]time.Duration) error {
+	dsn, _ := GetEnv("STATS_DB")
+	if dsn == "" {
+		return nil
+	}
+	if _, ok := h.storage.(*postgres.Driver); ok {
+		return nil
+	}
+	driver, err := postgres.Open(dsn, retention)
+	if err != nil {
+		return fmt.Errorf("failed to open STATS_DB: %w", err)
+	}
+	h.storage = driver
+	h.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		driver.Close()
+		return e.Next()
+	})
+	h.Logger().Info("Storing stats in PostgreSQL", "timescaledb", driver.TimescaleDB())
+	return nil
+}
+
+// getStats handles GET /api/beszel/stats?system=<id>&collection=<system_stats|container_stats>.
+// Returns the system's stats records for charts from the storage driver, oldest
+// first. The optional "type" query param sets the record type, 1m by default,
+// and "since" returns only records created after a date.
+func (h *Hub) getStats(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	systemRecord, err := e.App.FindRecordById("systems", query.Get("system"))
+	if err != nil {
+		return e.NotFoundError("System not found", err)
+	}
+	info, err := e.RequestInfo()
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	if canAccess, err := e.App.CanAccessRecord(systemRecord, info, systemRecord.Collection().ViewRule); !canAccess {
+		return e.NotFoundError("System not found", err)
+	}
+
+	statsQuery := storage.Query{System: systemRecord.Id, Type: storage.Type1m}
+	if value := query.Get("type"); value != "" {
+		if !slices.Contains(statsTypes, value) {
+			return e.BadRequestError("Invalid type", nil)
+		}
+		statsQuery.Type = value
+	}
+	if value := query.Get("since"); value != "" {
+		since, err := types.ParseDateTime(value)
+		if err != nil || since.IsZero() {
+			return e.BadRequestError("Invalid since", err)
+		}
+		statsQuery.Since = since.Time()
+	}
+
+	result := []statsRecord{}
+	switch query.Get("collection") {
+	case "system_stats":
+		records, err := h.storage.SystemStats(statsQuery)
+		if err != nil {
+			return e.InternalServerError("", err)
+		}
+		for _, record := range records {
+			result = append(result, statsRecord{Created: record.Created, Stats: record.Stats})
+		}
+	case "container_stats":
+		records, err := h.storage.ContainerStats(statsQuery)
+		if err != nil {
+			return e.InternalServerError("", err)
+		}
+		// mask container names for readonly users, as for container_stats records
+		readonly := info.Auth != nil && info.Auth.GetString("role") == "readonly"
+		for _, record := range records {
+			if readonly {
+				for i := range record.Stats {
+					record.Stats[i].Name = h.mask.Container(record.Stats[i].Name)
+				}
+			}
+			result = append(result, statsRecord{Created: record.Created, Stats: record.Stats})
+		}
+	default:
+		return e.BadRequestError("Invalid collection", nil)
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+// recordTypeForRange returns the shortest record type that is kept for the
+// whole range and doesn't return more than maxDataPoints per series. Ranges
+// may start up to one interval before the retention, as in "the last hour".
+func (h *Hub) recordTypeForRange(from, to time.Time, maxDataPoints int, now time.Time) string {
+	if maxDataPoints <= 0 {
+		maxDataPoints = defaultMaxDataPoints
+	}
+	minInterval := to.Sub(from) / time.Duration(maxDataPoints)
+	for _, recordType := range statsTypes {
+		interval, err := time.ParseDuration(recordType)
+		if err != nil || interval < minInterval {
+			continue
+		}
+		if from.After(now.Add(-h.rm.StatsRetention(recordType) - interval)) {
+			return recordType
+		}
+	}
+	return statsTypes[len(statsTypes)-1]
+}
@@ -0,0 +1,140 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"testing"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsApi(t *testing.T) {
+	t.Setenv("BESZEL_HUB_MASK", "containers")
+
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	readonlyUser, err := beszelTests.CreateUser(hub, "readonly@example.com", "password123")
+	require.NoError(t, err)
+	readonlyUser.Set("role", "readonly")
+	require.NoError(t, hub.Save(readonlyUser))
+	readonlyToken, err := readonlyUser.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+	otherToken, err := otherUser.NewAuthToken()
+	require.NoError(t, err)
+
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "web",
+		"host":  "127.0.0.1",
+		"users": []string{user.Id, readonlyUser.Id},
+	})
+	require.NoError(t, err)
+	for _, collection := range []string{"system_stats", "container_stats"} {
+		stats := `{"cpu": 12.5}`
+		if collection == "container_stats" {
+			stats = `[{"n": "postgres", "c": 3}]`
+		}
+		_, err := beszelTests.CreateRecord(hub, collection, map[string]any{
+			"system": systemRecord.Id,
+			"type":   "1m",
+			"stats":  stats,
+		})
+		require.NoError(t, err)
+	}
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+	url := "/api/beszel/stats?system=" + systemRecord.Id
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /stats - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             url + "&collection=system_stats",
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /stats - other user's system is not found",
+			Method:          http.MethodGet,
+			URL:             url + "&collection=system_stats",
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"System not found"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": otherToken},
+		},
+		{
+			Name:            "GET /stats - invalid collection",
+			Method:          http.MethodGet,
+			URL:             url + "&collection=users",
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid collection"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:            "GET /stats - invalid type",
+			Method:          http.MethodGet,
+			URL:             url + "&collection=system_stats&type=5m",
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid type"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:            "GET /stats - system stats",
+			Method:          http.MethodGet,
+			URL:             url + "&collection=system_stats&since=2020-01-01%2000:00:00",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"stats":{"cpu":12.5`, `"created":"`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:            "GET /stats - no records since the date",
+			Method:          http.MethodGet,
+			URL:             url + "&collection=system_stats&since=2100-01-01%2000:00:00",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{"[]"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:            "GET /stats - container stats",
+			Method:          http.MethodGet,
+			URL:             url + "&collection=container_stats",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"n":"postgres"`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:               "GET /stats - container names are masked for readonly users",
+			Method:             http.MethodGet,
+			URL:                url + "&collection=container_stats",
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"c":3`},
+			NotExpectedContent: []string{"postgres"},
+			TestAppFactory:     testAppFactory,
+			Headers:            map[string]string{"Authorization": readonlyToken},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,145 @@
+package hub
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Max sensors shown for each system of a status page
+const maxStatusPageSensors = 20
+
+// Sensor values older than this aren't shown on status pages
+const statusPageSensorAge = 5 * time.Minute
+
+// StatusPage is the payload returned by GET /api/beszel/status/{slug}
+type StatusPage struct {
+	Name    string             `json:"name"`
+	Systems []StatusPageSystem `json:"systems"`
+}
+
+// StatusPageSystem is a system of a status page. Metrics are only set if the
+// system is up.
+type StatusPageSystem struct {
+	Name    string             `json:"name"`
+	Status  string             `json:"status"`
+	Cpu     float64            `json:"cpu"`
+	MemPct  float64            `json:"mp"`
+	Sensors []StatusPageSensor `json:"sensors,omitempty"`
+}
+
+// StatusPageSensor is the latest value of a sensor shown on a status page
+type StatusPageSensor struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// validateStatusPage checks that the user can view the systems of a new or
+// updated status page, and the number of sensors.
+func validateStatusPage(e *core.RecordRequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	for _, systemId := range e.Record.GetStringSlice("systems") {
+		systemRecord, err := e.App.FindRecordById("systems", systemId)
+		if err != nil {
+			return e.BadRequestError("System not found", err)
+		}
+		if canAccess, _ := e.App.CanAccessRecord(systemRecord, info, systemRecord.Collection().ViewRule); !canAccess {
+			return e.BadRequestError("System not found", nil)
+		}
+	}
+	var sensors []string
+	if err := e.Record.UnmarshalJSONField("sensors", &sensors); err != nil {
+		return e.BadRequestError("Invalid sensors", err)
+	}
+	if len(sensors) > maxStatusPageSensors {
+		return e.BadRequestError("Too many sensors", nil)
+	}
+	e.Record.Set("domain", strings.ToLower(e.Record.GetString("domain")))
+	return e.Next()
+}
+
+// getStatusPage handles GET /api/beszel/status/{slug}. Returns the status and
+// key metrics of the page's systems, without authentication.
+func (h *Hub) getStatusPage(e *core.RequestEvent) error {
+	page, err := e.App.FindFirstRecordByData("status_pages", "slug", e.Request.PathValue("slug"))
+	if err != nil {
+		return e.NotFoundError("Status page not found", err)
+	}
+	var sensors []string
+	_ = page.UnmarshalJSONField("sensors", &sensors)
+
+	status := StatusPage{Name: page.GetString("name"), Systems: []StatusPageSystem{}}
+	for _, systemId := range page.GetStringSlice("systems") {
+		systemRecord, err := e.App.FindRecordById("systems", systemId)
+		if err != nil {
+			continue
+		}
+		pageSystem := StatusPageSystem{
+			Name:   h.mask.Hostname(systemRecord.GetString("name")),
+			Status: systemRecord.GetString("status"),
+		}
+		if pageSystem.Status == "up" {
+			var info system.Info
+			_ = systemRecord.UnmarshalJSONField("info", &info)
+			pageSystem.Cpu, pageSystem.MemPct = info.Cpu, info.MemPct
+			if len(sensors) > 0 {
+				pageSystem.Sensors, err = h.statusPageSensors(systemRecord.Id, sensors)
+				if err != nil {
+					return e.InternalServerError("", err)
+				}
+			}
+		}
+		status.Systems = append(status.Systems, pageSystem)
+	}
+
+	e.Response.Header().Set("Cache-Control", "no-store")
+	return e.JSON(http.StatusOK, status)
+}
+
+// statusPageSensors returns the latest values of the named sensors of a system,
+// in the order of the names. Sensors the system doesn't have are skipped.
+func (h *Hub) statusPageSensors(systemId string, names []string) ([]StatusPageSensor, error) {
+	records, err := h.storage.SystemStats(storage.Query{
+		System: systemId,
+		Type:   storage.Type1m,
+		Since:  time.Now().UTC().Add(-statusPageSensorAge),
+	})
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	stats := records[len(records)-1].Stats
+	sensors := make([]StatusPageSensor, 0, len(names))
+	for _, name := range names {
+		if value, ok := stats.Temperatures[name]; ok {
+			sensors = append(sensors, StatusPageSensor{Name: name, Value: value, Unit: "°C"})
+		} else if data, ok := stats.GenericSensors[name]; ok {
+			sensors = append(sensors, StatusPageSensor{Name: name, Value: data.Value, Unit: data.Unit})
+		}
+	}
+	return sensors, nil
+}
+
+// statusPageForHost returns the slug of the status page with the request's
+// host as its custom domain, if there is one.
+func statusPageForHost(app core.App, r *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	var slug string
+	err = app.DB().Select("slug").From("status_pages").
+		Where(dbx.HashExp{"domain": strings.ToLower(host)}).
+		AndWhere(dbx.NewExp("domain != ''")).
+		Row(&slug)
+	return slug, err == nil && slug != ""
+}
@@ -0,0 +1,203 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	"beszel/internal/entities/system"
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"testing"
+
+	pbTests "github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusPages(t *testing.T) {
+	hub, _ := beszelTests.NewTestHub(t.TempDir())
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "testuser@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	readonlyUser, err := beszelTests.CreateUser(hub, "readonly@example.com", "password123")
+	require.NoError(t, err)
+	readonlyUser.Set("role", "readonly")
+	require.NoError(t, hub.Save(readonlyUser))
+	readonlyToken, err := readonlyUser.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+
+	webRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":   "web",
+		"host":   "127.0.0.1",
+		"status": "up",
+		"users":  []string{user.Id, readonlyUser.Id},
+		"info":   system.Info{Hostname: "secret-host", Cpu: 12.5, MemPct: 41.5},
+	})
+	require.NoError(t, err)
+	_, err = beszelTests.CreateRecord(hub, "system_stats", map[string]any{
+		"system": webRecord.Id,
+		"type":   "1m",
+		"stats": system.Stats{
+			Temperatures:   map[string]float64{"cpu_thermal": 48, "nvme": 40},
+			GenericSensors: map[string]system.SensorData{"humidity": {Value: 55, Unit: "%"}},
+		},
+	})
+	require.NoError(t, err)
+	dbRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":   "db",
+		"host":   "127.0.0.2",
+		"status": "down",
+		"users":  []string{user.Id},
+		"info":   system.Info{Cpu: 99},
+	})
+	require.NoError(t, err)
+	otherRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "other",
+		"host":  "127.0.0.3",
+		"users": []string{otherUser.Id},
+	})
+	require.NoError(t, err)
+
+	_, err = beszelTests.CreateRecord(hub, "status_pages", map[string]any{
+		"user":    user.Id,
+		"name":    "Acme status",
+		"slug":    "acme",
+		"domain":  "status.example.com",
+		"systems": []string{webRecord.Id, dbRecord.Id},
+		"sensors": []string{"humidity", "cpu_thermal", "missing"},
+	})
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *pbTests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:           "GET /status - page without auth",
+			Method:         http.MethodGet,
+			URL:            "/api/beszel/status/acme",
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"Acme status"`,
+				`{"name":"web","status":"up","cpu":12.5,"mp":41.5,"sensors":[{"name":"humidity","value":55,"unit":"%"},{"name":"cpu_thermal","value":48,"unit":"°C"}]}`,
+				`{"name":"db","status":"down","cpu":0,"mp":0}`,
+			},
+			NotExpectedContent: []string{"secret-host", "nvme", "missing", "99"},
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:            "GET /status - unknown page",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/status/unknown",
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"Status page not found"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:           "GET / - custom domain redirects to the page",
+			Method:         http.MethodGet,
+			URL:            "http://status.example.com/",
+			ExpectedStatus: 302,
+			TestAppFactory: testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *pbTests.TestApp, res *http.Response) {
+				require.Equal(t, "/status/acme", res.Header.Get("Location"))
+			},
+		},
+		{
+			Name:            "GET / - other hosts aren't redirected",
+			Method:          http.MethodGet,
+			URL:             "http://hub.example.com/",
+			ExpectedStatus:  200,
+			ExpectedContent: []string{"<html"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:   "POST status_pages - create page",
+			Method: http.MethodPost,
+			URL:    "/api/collections/status_pages/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"name":    "Web",
+				"slug":    "web-status",
+				"domain":  "Web.Example.com",
+				"systems": []string{webRecord.Id},
+			}),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"slug":"web-status"`, `"domain":"web.example.com"`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:   "POST status_pages - slug is taken",
+			Method: http.MethodPost,
+			URL:    "/api/collections/status_pages/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"name":    "Acme",
+				"slug":    "acme",
+				"systems": []string{webRecord.Id},
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{`"slug"`},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:   "POST status_pages - system of another user",
+			Method: http.MethodPost,
+			URL:    "/api/collections/status_pages/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"name":    "Other",
+				"slug":    "other",
+				"systems": []string{otherRecord.Id},
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"System not found"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:   "POST status_pages - too many sensors",
+			Method: http.MethodPost,
+			URL:    "/api/collections/status_pages/records",
+			Body: jsonReader(map[string]any{
+				"user":    user.Id,
+				"name":    "Sensors",
+				"slug":    "sensors",
+				"systems": []string{webRecord.Id},
+				"sensors": make([]string, 21),
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Too many sensors"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": userToken},
+		},
+		{
+			Name:   "POST status_pages - readonly user can't create pages",
+			Method: http.MethodPost,
+			URL:    "/api/collections/status_pages/records",
+			Body: jsonReader(map[string]any{
+				"user":    readonlyUser.Id,
+				"name":    "Readonly",
+				"slug":    "readonly",
+				"systems": []string{webRecord.Id},
+			}),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Failed to create record"},
+			TestAppFactory:  testAppFactory,
+			Headers:         map[string]string{"Authorization": readonlyToken},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build testing
+// +build testing
+
+package postgres
+
+// TESTING ONLY: Truncate deletes all records
+func (d *Driver) Truncate() error {
+	for _, table := range tables {
+		if _, err := d.db.NewQuery("TRUNCATE " + table).Execute(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Package storage defines the backend that stores system and container stats records.
+//
+// The hub writes one minute records through a Driver as they are received from
+// agents, and periodically asks it to downsample and delete old records. The
+// default driver stores records in the PocketBase system_stats and
+// container_stats collections (see records.RecordManager). Setting the
+// STATS_RETENTION env var (e.g. "1m=7d,120m=1y") makes it keep records longer
+// than the UI displays them, packed into columnar packed_stats records.
+//
+// Setting STATS_DB to a PostgreSQL connection string stores the records in
+// PostgreSQL instead (see the postgres package). The web UI and the terminal
+// client read records through /api/beszel/stats, and the InfluxDB and remote
+// write exporters get stats from the hub after they are added.
+package storage
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"time"
+)
+
+// Record types from shortest to longest interval. Longer records are
+// averages of shorter records.
+const (
+	Type1m   = "1m"
+	Type10m  = "10m"
+	Type20m  = "20m"
+	Type120m = "120m"
+	Type480m = "480m"
+)
+
+// SystemStats is a stats record of a system
+type SystemStats struct {
+	System  string
+	Type    string
+	Created time.Time
+	Stats   system.Stats
+}
+
+// ContainerStats is a record of the stats of a system's containers
+type ContainerStats struct {
+	System  string
+	Type    string
+	Created time.Time
+	Stats   []container.Stats
+}
+
+// Query selects records of one type
+type Query struct {
+	System string    // all systems if empty
+	Type   string    // Type1m if empty
+	Since  time.Time // created after, if not zero
+	Until  time.Time // created at or before, if not zero
+}
+
+// Driver stores stats records. Implementations must be safe for concurrent use.
+type Driver interface {
+	// AddStats saves one minute records of a system. Containers may be empty.
+	AddStats(systemId string, stats *system.Stats, containers []*container.Stats) error
+	// SystemStats returns the system stats records matching the query, oldest first.
+	SystemStats(query Query) ([]SystemStats, error)
+	// ContainerStats returns the container stats records matching the query, oldest first.
+	ContainerStats(query Query) ([]ContainerStats, error)
+	// Downsample creates longer records by averaging shorter records of systems that are up.
+	Downsample() error
+	// DeleteOld deletes records older than the retention of their type.
+	DeleteOld() error
+}
@@ -0,0 +1,31 @@
+package systems
+
+import (
+	"beszel/internal/entities/system"
+	"errors"
+)
+
+// ErrSystemInactive is returned by Ingest if the system isn't being updated,
+// e.g. because it's paused.
+var ErrSystemInactive = errors.New("system is not active")
+
+// Ingest passes data posted to the ingest API to the system's updater, which
+// saves it at the next update instead of polling the agent. Data that hasn't
+// been saved yet is replaced, like data pushed by an agent.
+func (sm *SystemManager) Ingest(systemId string, data *system.CombinedData) error {
+	sys, ok := sm.systems.GetOk(systemId)
+	if !ok || sys.ingestChan == nil {
+		return ErrSystemInactive
+	}
+	for {
+		select {
+		case sys.ingestChan <- data:
+			return nil
+		default:
+		}
+		select {
+		case <-sys.ingestChan:
+		default:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package systems
+
+import (
+	"beszel/internal/entities/system"
+	"maps"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Limits of the labels of a system
+const (
+	MaxLabels      = 32
+	MaxLabelLength = 128
+)
+
+// SanitizeLabels returns the labels without empty keys and values that are too
+// long, up to the max number of labels.
+func SanitizeLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	result := make(map[string]string, min(len(labels), MaxLabels))
+	for key, value := range labels {
+		if len(result) == MaxLabels {
+			break
+		}
+		if key == "" || len(key) > MaxLabelLength || len(value) > MaxLabelLength {
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// setLabels adds the labels declared by the agent to the system's labels,
+// replacing the values of the same keys. Other labels, set by an enrollment
+// token or the API, are kept. The labels are removed from the info.
+func setLabels(systemRecord *core.Record, info *system.Info) {
+	declared := SanitizeLabels(info.Labels)
+	info.Labels = nil
+	if len(declared) == 0 {
+		return
+	}
+	var labels map[string]string
+	_ = systemRecord.UnmarshalJSONField("labels", &labels)
+	merged := make(map[string]string, len(labels)+len(declared))
+	maps.Copy(merged, labels)
+	maps.Copy(merged, declared)
+	if maps.Equal(merged, labels) {
+		return
+	}
+	systemRecord.Set("labels", SanitizeLabels(merged))
+}
@@ -2,42 +2,62 @@ package systems
 
 import (
 	"beszel"
+	"beszel/internal/clock"
 	"beszel/internal/entities/system"
 	"beszel/internal/hub/ws"
+	"beszel/internal/records"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
 	"net"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/blang/semver"
 	"github.com/fxamacker/cbor/v2"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 	"golang.org/x/crypto/ssh"
 )
 
 type System struct {
-	Id           string               `db:"id"`
-	Host         string               `db:"host"`
-	Port         string               `db:"port"`
-	Status       string               `db:"status"`
-	manager      *SystemManager       // Manager that this system belongs to
-	client       *ssh.Client          // SSH client for fetching data
-	data         *system.CombinedData // system data from agent
-	ctx          context.Context      // Context for stopping the updater
-	cancel       context.CancelFunc   // Stops and removes system from updater
-	WsConn       *ws.WsConn           // Handler for agent WebSocket connection
-	agentVersion semver.Version       // Agent version
-	updateTicker *time.Ticker         // Ticker for updating the system
+	Id           string                    `db:"id"`
+	Host         string                    `db:"host"`
+	Port         string                    `db:"port"`
+	Status       string                    `db:"status"`
+	Ingest       bool                      `db:"ingest"` // only receives stats from the ingest API and is never polled
+	manager      *SystemManager            // Manager that this system belongs to
+	client       *ssh.Client               // SSH client for fetching data
+	data         *system.CombinedData      // system data from agent
+	ctx          context.Context           // Context for stopping the updater
+	cancel       context.CancelFunc        // Stops and removes system from updater
+	WsConn       *ws.WsConn                // Handler for agent WebSocket connection
+	agentVersion semver.Version            // Agent version
+	updateTicker clock.Ticker              // Ticker for updating the system
+	pushed       *system.CombinedData      // latest data pushed by the agent, used instead of polling
+	pushedAt     time.Time                 // time the pushed data was received
+	ingestChan   chan *system.CombinedData // latest data posted to the ingest API
+	lastIngest   time.Time                 // time of the latest post to the ingest API, or when the system was added
+
+	handshake atomic.Pointer[AgentHandshake] // Latest agent handshake, read by the hub API
+}
+
+// AgentHandshake is the agent version and connection type from the latest connection
+type AgentHandshake struct {
+	Version   semver.Version
+	WebSocket bool
 }
 
 func (sm *SystemManager) NewSystem(systemId string) *System {
 	system := &System{
-		Id:   systemId,
-		data: &system.CombinedData{},
+		Id:         systemId,
+		data:       &system.CombinedData{},
+		ingestChan: make(chan *system.CombinedData, 1),
 	}
 	system.ctx, system.cancel = system.getContext()
 	return system
@@ -50,19 +70,27 @@ func (sys *System) StartUpdater() {
 	// Channel that can be used to set the system down. Currently only used to
 	// allow a short delay for reconnection after websocket connection is closed.
 	var downChan chan struct{}
+	// Channel of data pushed by the agent, if it pushes over WebSocket
+	var pushChan chan *system.CombinedData
 
 	// Add random jitter to first WebSocket connection to prevent
 	// clustering if all agents are started at the same time.
 	// SSH connections during hub startup are already staggered.
+	clk := sys.manager.clock
 	var jitter <-chan time.Time
 	if sys.WsConn != nil {
-		jitter = getJitter()
+		jitter = getJitter(clk)
 		// use the websocket connection's down channel to set the system down
 		downChan = sys.WsConn.DownChan
+		pushChan = sys.WsConn.PushChan
 	} else {
 		// if the system does not have a websocket connection, wait before updating
 		// to allow the agent to connect via websocket (makes sure fingerprint is set).
-		time.Sleep(11 * time.Second)
+		select {
+		case <-sys.ctx.Done():
+			return
+		case <-clk.After(11 * time.Second):
+		}
 	}
 
 	// update immediately if system is not paused (only for ws connections)
@@ -73,7 +101,7 @@ func (sys *System) StartUpdater() {
 		}
 	}
 
-	sys.updateTicker = time.NewTicker(time.Duration(interval) * time.Millisecond)
+	sys.updateTicker = clk.NewTicker(time.Duration(interval) * time.Millisecond)
 	// Go 1.23+ will automatically stop the ticker when the system is garbage collected, however we seem to need this or testing/synctest will block even if calling runtime.GC()
 	defer sys.updateTicker.Stop()
 
@@ -81,13 +109,19 @@ func (sys *System) StartUpdater() {
 		select {
 		case <-sys.ctx.Done():
 			return
-		case <-sys.updateTicker.C:
+		case <-sys.updateTicker.C():
 			if err := sys.update(); err != nil {
 				_ = sys.setDown(err)
 			}
+		case data := <-pushChan:
+			sys.pushed, sys.pushedAt = data, clk.Now()
+		case data := <-sys.ingestChan:
+			sys.pushed, sys.pushedAt = data, clk.Now()
+			sys.lastIngest = sys.pushedAt
 		case <-downChan:
 			sys.WsConn = nil
 			downChan = nil
+			pushChan = nil
 			_ = sys.setDown(nil)
 		case <-jitter:
 			sys.updateTicker.Reset(time.Duration(interval) * time.Millisecond)
@@ -104,13 +138,41 @@ func (sys *System) update() error {
 		sys.handlePaused()
 		return nil
 	}
+	if data, received := sys.takePushed(); data != nil {
+		_, err := sys.createRecords(data, received)
+		return err
+	}
+	if sys.Ingest {
+		return sys.checkIngest()
+	}
 	data, err := sys.fetchDataFromAgent()
 	if err == nil {
-		_, err = sys.createRecords(data)
+		_, err = sys.createRecords(data, sys.manager.clock.Now())
 	}
 	return err
 }
 
+// takePushed returns the latest data pushed by the agent and when it was
+// received, if it's newer than the update interval, so the agent isn't polled.
+func (sys *System) takePushed() (*system.CombinedData, time.Time) {
+	data, received := sys.pushed, sys.pushedAt
+	sys.pushed = nil
+	if data == nil || sys.manager.clock.Since(received) >= time.Duration(interval)*time.Millisecond {
+		return nil, time.Time{}
+	}
+	return data, received
+}
+
+// checkIngest returns an error if an ingest-only system hasn't posted stats
+// within ingestGracePeriod, so it's set down. Until then a missed post keeps
+// the current status.
+func (sys *System) checkIngest() error {
+	if since := sys.manager.clock.Since(sys.lastIngest); since >= ingestGracePeriod {
+		return fmt.Errorf("no stats posted for %s", since.Truncate(time.Second))
+	}
+	return nil
+}
+
 func (sys *System) handlePaused() {
 	if sys.WsConn == nil {
 		// if the system is paused and there's no websocket connection, remove the system
@@ -125,42 +187,31 @@ func (sys *System) handlePaused() {
 }
 
 // createRecords updates the system record and adds system_stats and container_stats records
-func (sys *System) createRecords(data *system.CombinedData) (*core.Record, error) {
+func (sys *System) createRecords(data *system.CombinedData, received time.Time) (*core.Record, error) {
 	systemRecord, err := sys.getRecord()
 	if err != nil {
 		return nil, err
 	}
 	hub := sys.manager.hub
-	// add system_stats and container_stats records
-	systemStatsCollection, err := hub.FindCachedCollectionByNameOrId("system_stats")
-	if err != nil {
-		return nil, err
+	// add system and container stats records
+	now := sys.manager.clock.Now()
+	setLatency(data, received, now)
+	// update before adding stats since it sets the temperature rise
+	if err := records.UpdateSensorRollups(hub, systemRecord.Id, &data.Stats, now); err != nil {
+		hub.Logger().Error("Failed to update sensor rollups", "system", systemRecord.Id, "err", err)
 	}
-
-	systemStatsRecord := core.NewRecord(systemStatsCollection)
-	systemStatsRecord.Set("system", systemRecord.Id)
-	systemStatsRecord.Set("stats", data.Stats)
-	systemStatsRecord.Set("type", "1m")
-	if err := hub.SaveNoValidate(systemStatsRecord); err != nil {
+	if err := hub.Storage().AddStats(systemRecord.Id, &data.Stats, data.Containers); err != nil {
 		return nil, err
 	}
-	// add new container_stats record
-	if len(data.Containers) > 0 {
-		containerStatsCollection, err := hub.FindCachedCollectionByNameOrId("container_stats")
-		if err != nil {
-			return nil, err
-		}
-		containerStatsRecord := core.NewRecord(containerStatsCollection)
-		containerStatsRecord.Set("system", systemRecord.Id)
-		containerStatsRecord.Set("stats", data.Containers)
-		containerStatsRecord.Set("type", "1m")
-		if err := hub.SaveNoValidate(containerStatsRecord); err != nil {
-			return nil, err
-		}
+	hub.ExportStats(systemRecord, &data.Stats, now)
+	setPrevPublicIp(systemRecord, data, now)
+	if err := saveEvents(hub, systemRecord.Id, data.Events); err != nil {
+		hub.Logger().Error("Failed to save events", "system", systemRecord.Id, "err", err)
 	}
 	// update system record (do this last because it triggers alerts and we need above records to be inserted first)
 	systemRecord.Set("status", up)
 
+	setLabels(systemRecord, &data.Info)
 	systemRecord.Set("info", data.Info)
 	if err := hub.SaveNoValidate(systemRecord); err != nil {
 		return nil, err
@@ -168,6 +219,74 @@ func (sys *System) createRecords(data *system.CombinedData) (*core.Record, error
 	return systemRecord, nil
 }
 
+// saveEvents adds system_events records for events reported to the agent's
+// event API. Events that were already saved are skipped, since the agent may
+// send its cached data again to a new session.
+func saveEvents(app core.App, systemId string, events []system.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	collection, err := app.FindCachedCollectionByNameOrId("system_events")
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		eventTime, _ := types.ParseDateTime(time.UnixMilli(event.Time))
+		_, err := app.FindFirstRecordByFilter(collection, "system={:system} && name={:name} && time={:time}",
+			dbx.Params{"system": systemId, "name": event.Name, "time": eventTime.String()})
+		if err == nil {
+			continue
+		}
+		record := core.NewRecord(collection)
+		record.Set("system", systemId)
+		record.Set("name", event.Name)
+		record.Set("message", event.Message)
+		if event.Value != nil {
+			record.Set("value", *event.Value)
+		}
+		record.Set("time", eventTime)
+		if err := app.SaveNoValidate(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPrevPublicIp sets the previous public IP of the data if it changed since
+// the last update, and adds an event for the change.
+func setPrevPublicIp(systemRecord *core.Record, data *system.CombinedData, now time.Time) {
+	data.PrevPublicIp = ""
+	var prevInfo system.Info
+	_ = systemRecord.UnmarshalJSONField("info", &prevInfo)
+	if prevInfo.PublicIp == "" || data.Info.PublicIp == "" || prevInfo.PublicIp == data.Info.PublicIp {
+		return
+	}
+	data.PrevPublicIp = prevInfo.PublicIp
+	data.Events = append(data.Events, system.Event{
+		Name:    "public_ip",
+		Message: fmt.Sprintf("Public IP changed from %s to %s", prevInfo.PublicIp, data.Info.PublicIp),
+		Time:    now.UnixMilli(),
+	})
+}
+
+// setLatency sets the pipeline latency of the data, from the agent's collection
+// to when the hub received it, and from then until the record is written. Old
+// agents don't report a collection time. The first part relies on the agent and
+// hub clocks being in sync, so negative values are clamped to zero.
+func setLatency(data *system.CombinedData, received, now time.Time) {
+	if data.Info.CollectedAt == 0 {
+		data.Stats.Latency, data.Info.Latency = [2]float64{}, 0
+		return
+	}
+	toHub := max(received.Sub(time.UnixMilli(data.Info.CollectedAt)), 0)
+	toRecord := max(now.Sub(received), 0)
+	data.Stats.Latency = [2]float64{
+		float64(toHub.Microseconds()) / 1000,
+		float64(toRecord.Microseconds()) / 1000,
+	}
+	data.Info.Latency = data.Stats.Latency[0] + data.Stats.Latency[1]
+}
+
 // getRecord retrieves the system record from the database.
 // If the record is not found, it removes the system from the manager.
 func (sys *System) getRecord() (*core.Record, error) {
@@ -220,6 +339,15 @@ func (sys *System) fetchDataFromAgent() (*system.CombinedData, error) {
 		sys.closeWebSocketConnection()
 	}
 
+	// agents serve stats over mTLS instead of SSH if the hub has MTLS enabled
+	serverName := sys.Host
+	if strings.HasPrefix(serverName, "/") {
+		serverName = "localhost"
+	}
+	if config := sys.manager.hub.MTLSConfig(serverName); config != nil {
+		return sys.fetchDataViaTLS(config)
+	}
+
 	sshData, err := sys.fetchDataViaSSH()
 	if err != nil {
 		return nil, err
@@ -238,6 +366,33 @@ func (sys *System) fetchDataViaWebSocket() (*system.CombinedData, error) {
 	return sys.data, nil
 }
 
+// fetchDataViaTLS fetches data from an agent serving stats over mTLS. The agent
+// sends the data as CBOR after the handshake and closes the connection.
+func (sys *System) fetchDataViaTLS(config *tls.Config) (*system.CombinedData, error) {
+	network, addr := "tcp", net.JoinHostPort(sys.Host, sys.Port)
+	if strings.HasPrefix(sys.Host, "/") {
+		network, addr = "unix", sys.Host
+	}
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: sessionTimeout}, Config: config}
+	conn, err := dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	*sys.data = system.CombinedData{}
+	if err := cbor.NewDecoder(conn).Decode(sys.data); err != nil {
+		return nil, err
+	}
+	// the agent version is only known from the data
+	if version, err := semver.Parse(sys.data.Info.AgentVersion); err == nil && !version.EQ(sys.agentVersion) {
+		sys.agentVersion = version
+		sys.setHandshake(AgentHandshake{Version: version})
+	}
+	return sys.data, nil
+}
+
 // fetchDataViaSSH handles fetching data using SSH.
 // This function encapsulates the original SSH logic.
 // It updates sys.data directly upon successful fetch.
@@ -318,6 +473,7 @@ func (s *System) createSSHClient() error {
 		return err
 	}
 	s.agentVersion, _ = extractAgentVersion(string(s.client.Conn.ServerVersion()))
+	s.setHandshake(AgentHandshake{Version: s.agentVersion})
 	return nil
 }
 
@@ -369,6 +525,12 @@ func (sys *System) closeWebSocketConnection() {
 	}
 }
 
+// setHandshake records the agent's handshake and lets the hub check the version.
+func (sys *System) setHandshake(handshake AgentHandshake) {
+	sys.handshake.Store(&handshake)
+	sys.manager.hub.HandleAgentHandshake(sys.Id, handshake)
+}
+
 // extractAgentVersion extracts the beszel version from SSH server version string
 func extractAgentVersion(versionString string) (semver.Version, error) {
 	_, after, _ := strings.Cut(versionString, "_")
@@ -378,10 +540,10 @@ func extractAgentVersion(versionString string) (semver.Version, error) {
 // getJitter returns a channel that will be triggered after a random delay
 // between 40% and 90% of the interval.
 // This is used to stagger the initial WebSocket connections to prevent clustering.
-func getJitter() <-chan time.Time {
+func getJitter(clk clock.Clock) <-chan time.Time {
 	minPercent := 40
 	maxPercent := 90
 	jitterRange := maxPercent - minPercent
 	msDelay := (interval * minPercent / 100) + rand.Intn(interval*jitterRange/100)
-	return time.After(time.Duration(msDelay) * time.Millisecond)
+	return clk.After(time.Duration(msDelay) * time.Millisecond)
 }
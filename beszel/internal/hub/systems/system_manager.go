@@ -2,11 +2,15 @@ package systems
 
 import (
 	"beszel"
+	"beszel/internal/clock"
 	"beszel/internal/common"
 	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
 	"beszel/internal/hub/ws"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/blang/semver"
@@ -28,6 +32,10 @@ const (
 
 	// sessionTimeout is the maximum time to wait for SSH connections
 	sessionTimeout = 4 * time.Second
+
+	// ingestGracePeriod is how long an ingest-only system can go without
+	// posting stats before it's set down
+	ingestGracePeriod = 3 * time.Minute
 )
 
 var (
@@ -41,6 +49,10 @@ type SystemManager struct {
 	hub       hubLike                       // Hub interface for database and alert operations
 	systems   *store.Store[string, *System] // Thread-safe store of active systems
 	sshConfig *ssh.ClientConfig             // SSH client configuration for system connections
+	clock     clock.Clock                   // Time source for update tickers and delays
+	mu        sync.Mutex                    // Guards stopped and adding updaters
+	stopped   bool                          // Whether the hub is shutting down
+	updaters  sync.WaitGroup                // Running update goroutines
 }
 
 // hubLike defines the interface requirements for the hub dependency.
@@ -50,15 +62,24 @@ type hubLike interface {
 	GetSSHKey(dataDir string) (ssh.Signer, error)
 	HandleSystemAlerts(systemRecord *core.Record, data *system.CombinedData) error
 	HandleStatusAlerts(status string, systemRecord *core.Record) error
+	HandleAgentHandshake(systemId string, handshake AgentHandshake)
+	MTLSConfig(host string) *tls.Config
+	Storage() storage.Driver
+	ExportStats(systemRecord *core.Record, stats *system.Stats, created time.Time)
 }
 
 // NewSystemManager creates a new SystemManager instance with the provided hub.
 // The hub must implement the hubLike interface to provide database and alert functionality.
+// Systems are stopped when the hub terminates, including systems of agents
+// that connect before Initialize.
 func NewSystemManager(hub hubLike) *SystemManager {
-	return &SystemManager{
+	sm := &SystemManager{
 		systems: store.New(map[string]*System{}),
 		hub:     hub,
+		clock:   clock.New(),
 	}
+	hub.OnTerminate().BindFunc(sm.onTerminate)
+	return sm
 }
 
 // Initialize sets up the system manager by binding event hooks and starting existing systems.
@@ -75,7 +96,7 @@ func (sm *SystemManager) Initialize() error {
 
 	// Load existing systems from database (excluding paused ones)
 	var systems []*System
-	err = sm.hub.DB().NewQuery("SELECT id, host, port, status FROM systems WHERE status != 'paused'").All(&systems)
+	err = sm.hub.DB().NewQuery("SELECT id, host, port, status, ingest FROM systems WHERE status != 'paused'").All(&systems)
 	if err != nil || len(systems) == 0 {
 		return err
 	}
@@ -88,7 +109,7 @@ func (sm *SystemManager) Initialize() error {
 		sleepTime := time.Duration(delta) * time.Millisecond
 
 		for _, system := range systems {
-			time.Sleep(sleepTime)
+			sm.clock.Sleep(sleepTime)
 			_ = sm.AddSystem(system)
 		}
 	}()
@@ -106,6 +127,21 @@ func (sm *SystemManager) bindEventHooks() {
 	sm.hub.OnRecordAfterUpdateSuccess("fingerprints").BindFunc(sm.onTokenRotated)
 }
 
+// onTerminate stops monitoring all systems when the hub shuts down, so their
+// update goroutines don't outlive the app. Systems still being started in the
+// background aren't added. Waits for updates in progress, which would
+// otherwise write to the closed database.
+func (sm *SystemManager) onTerminate(e *core.TerminateEvent) error {
+	sm.mu.Lock()
+	sm.stopped = true
+	sm.mu.Unlock()
+	for systemID := range sm.systems.GetAll() {
+		_ = sm.RemoveSystem(systemID)
+	}
+	sm.updaters.Wait()
+	return e.Next()
+}
+
 // onTokenRotated handles fingerprint token rotation events.
 // When a system's authentication token is rotated, any existing WebSocket connection
 // must be closed to force re-authentication with the new token.
@@ -164,6 +200,7 @@ func (sm *SystemManager) onRecordAfterUpdateSuccess(e *core.RecordEvent) error {
 	if ok {
 		prevStatus = system.Status
 		system.Status = newStatus
+		system.Ingest = e.Record.GetBool("ingest")
 	}
 
 	switch newStatus {
@@ -220,6 +257,11 @@ func (sm *SystemManager) onRecordAfterDeleteSuccess(e *core.RecordEvent) error {
 // It validates required fields, initializes the system context, and starts the update goroutine.
 // Returns error if a system with the same ID already exists.
 func (sm *SystemManager) AddSystem(sys *System) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.stopped {
+		return errors.New("system manager stopped")
+	}
 	if sm.systems.Has(sys.Id) {
 		return errSystemExists
 	}
@@ -231,10 +273,15 @@ func (sm *SystemManager) AddSystem(sys *System) error {
 	sys.manager = sm
 	sys.ctx, sys.cancel = sys.getContext()
 	sys.data = &system.CombinedData{}
+	sys.lastIngest = sm.clock.Now()
 	sm.systems.Set(sys.Id, sys)
 
 	// Start monitoring in background
-	go sys.StartUpdater()
+	sm.updaters.Add(1)
+	go func() {
+		defer sm.updaters.Done()
+		sys.StartUpdater()
+	}()
 	return nil
 }
 
@@ -278,6 +325,7 @@ func (sm *SystemManager) AddRecord(record *core.Record, system *System) (err err
 	system.Status = record.GetString("status")
 	system.Host = record.GetString("host")
 	system.Port = record.GetString("port")
+	system.Ingest = record.GetBool("ingest")
 
 	return sm.AddSystem(system)
 }
@@ -291,6 +339,11 @@ func (sm *SystemManager) AddWebSocketSystem(systemId string, agentVersion semver
 		return err
 	}
 
+	// continue a chunked transfer interrupted by the previous connection
+	if prev, ok := sm.systems.GetOk(systemId); ok {
+		wsConn.ResumeTransfer(prev.WsConn)
+	}
+
 	system := sm.NewSystem(systemId)
 	system.WsConn = wsConn
 	system.agentVersion = agentVersion
@@ -298,9 +351,21 @@ func (sm *SystemManager) AddWebSocketSystem(systemId string, agentVersion semver
 	if err := sm.AddRecord(systemRecord, system); err != nil {
 		return err
 	}
+	system.setHandshake(AgentHandshake{Version: agentVersion, WebSocket: true})
 	return nil
 }
 
+// GetAgentHandshakes returns the latest handshake of each connected system by system ID.
+func (sm *SystemManager) GetAgentHandshakes() map[string]AgentHandshake {
+	handshakes := make(map[string]AgentHandshake)
+	for id, sys := range sm.systems.GetAll() {
+		if handshake := sys.handshake.Load(); handshake != nil {
+			handshakes[id] = *handshake
+		}
+	}
+	return handshakes
+}
+
 // createSSHClientConfig initializes the SSH client configuration for connecting to an agent's server
 func (sm *SystemManager) createSSHClientConfig() error {
 	privateKey, err := sm.hub.GetSSHKey("")
@@ -4,16 +4,22 @@
 package systems_test
 
 import (
+	"beszel/internal/clock"
 	"beszel/internal/entities/container"
 	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
 	"beszel/internal/hub/systems"
 	"beszel/internal/tests"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"testing/synctest"
 	"time"
 
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -128,6 +134,102 @@ func TestSystemManagerNew(t *testing.T) {
 	})
 }
 
+func TestSystemManagerMockClock(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	mock := clock.NewMock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	sm := hub.GetSystemManager()
+	sm.SetClock(mock)
+	require.NoError(t, sm.Initialize())
+
+	user, err := tests.CreateUser(hub, "test@test.com", "testtesttest")
+	require.NoError(t, err)
+	record, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "mock-clock",
+		"host":  "127.0.0.1",
+		"port":  "1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	// updater waits for a websocket connection before the first ssh update
+	require.Eventually(t, func() bool { return mock.Waiters() == 1 }, 5*time.Second, 5*time.Millisecond)
+	mock.Advance(10 * time.Second)
+	assert.Equal(t, "pending", sm.GetSystemStatusFromStore(record.Id))
+
+	// first update fails and the ticker is registered
+	mock.Advance(time.Second)
+	require.Eventually(t, func() bool { return sm.GetSystemStatusFromStore(record.Id) == "down" }, 5*time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return mock.Waiters() == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	require.NoError(t, sm.RemoveSystem(record.Id))
+	require.Eventually(t, func() bool { return mock.Waiters() == 0 }, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestSystemManagerPipelineLatency(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sm := hub.GetSystemManager()
+	sm.SetClock(clock.NewMock(now))
+	require.NoError(t, sm.Initialize())
+
+	user, err := tests.CreateUser(hub, "test@test.com", "testtesttest")
+	require.NoError(t, err)
+	record, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "latency",
+		"host":  "127.0.0.1",
+		"port":  "1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	latestStats := func() system.Stats {
+		statsRecord, err := hub.FindFirstRecordByFilter("system_stats", "system={:system}", map[string]any{"system": record.Id})
+		require.NoError(t, err)
+		var stats system.Stats
+		require.NoError(t, statsRecord.UnmarshalJSONField("stats", &stats))
+		require.NoError(t, hub.Delete(statsRecord))
+		return stats
+	}
+	latestInfo := func() system.Info {
+		systemRecord, err := hub.FindRecordById("systems", record.Id)
+		require.NoError(t, err)
+		var info system.Info
+		require.NoError(t, systemRecord.UnmarshalJSONField("info", &info))
+		return info
+	}
+
+	data := &system.CombinedData{Info: system.Info{CollectedAt: now.Add(-1500 * time.Millisecond).UnixMilli()}}
+	require.NoError(t, sm.CreateRecords(record.Id, data, now.Add(-200*time.Millisecond)))
+	assert.Equal(t, [2]float64{1300, 200}, latestStats().Latency)
+	assert.Equal(t, 1500.0, latestInfo().Latency)
+
+	// agent clock ahead of the hub
+	data = &system.CombinedData{Info: system.Info{CollectedAt: now.Add(time.Second).UnixMilli()}}
+	require.NoError(t, sm.CreateRecords(record.Id, data, now.Add(-100*time.Millisecond)))
+	assert.Equal(t, [2]float64{0, 100}, latestStats().Latency)
+
+	// agent without a collection time
+	data = &system.CombinedData{}
+	require.NoError(t, sm.CreateRecords(record.Id, data, now))
+	assert.Equal(t, [2]float64{}, latestStats().Latency)
+	assert.Zero(t, latestInfo().Latency)
+
+	_, err = tests.CreateRecord(hub, "alerts", map[string]any{
+		"name":   "Latency",
+		"system": record.Id,
+		"user":   user.Id,
+		"value":  5000,
+		"min":    5,
+	})
+	assert.NoError(t, err, "latency alerts are allowed")
+}
+
 func testOld(t *testing.T, hub *tests.TestHub) {
 	user, err := tests.CreateUser(hub, "test@testy.com", "testtesttest")
 	require.NoError(t, err)
@@ -417,3 +519,317 @@ func testOld(t *testing.T, hub *tests.TestHub) {
 		assert.NoError(t, err)
 	})
 }
+
+// memoryStorage is a storage driver that keeps records in memory
+type memoryStorage struct {
+	mu      sync.Mutex
+	records []storage.SystemStats
+}
+
+func (s *memoryStorage) AddStats(systemId string, stats *system.Stats, _ []*container.Stats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, storage.SystemStats{System: systemId, Type: storage.Type1m, Created: time.Now(), Stats: *stats})
+	return nil
+}
+
+func (s *memoryStorage) SystemStats(query storage.Query) ([]storage.SystemStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.records), nil
+}
+
+func (s *memoryStorage) ContainerStats(storage.Query) ([]storage.ContainerStats, error) {
+	return nil, nil
+}
+
+func (s *memoryStorage) Downsample() error { return nil }
+
+func (s *memoryStorage) DeleteOld() error { return nil }
+
+func TestSystemManagerPushedData(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sm := hub.GetSystemManager()
+	sm.SetClock(clock.NewMock(now))
+	require.NoError(t, sm.Initialize())
+
+	user, err := tests.CreateUser(hub, "test@test.com", "testtesttest")
+	require.NoError(t, err)
+	record, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "push",
+		"host":  "127.0.0.1",
+		"port":  "1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	// recent pushed data is recorded without polling the agent
+	data := &system.CombinedData{Info: system.Info{CollectedAt: now.Add(-1500 * time.Millisecond).UnixMilli()}}
+	require.NoError(t, sm.UpdateWithPushed(record.Id, data, now.Add(-time.Second)))
+	statsRecord, err := hub.FindFirstRecordByFilter("system_stats", "system={:system}", map[string]any{"system": record.Id})
+	require.NoError(t, err)
+	var stats system.Stats
+	require.NoError(t, statsRecord.UnmarshalJSONField("stats", &stats))
+	assert.Equal(t, [2]float64{500, 1000}, stats.Latency)
+
+	// older data is ignored and the agent is polled
+	err = sm.UpdateWithPushed(record.Id, data, now.Add(-time.Minute))
+	assert.Error(t, err)
+}
+
+func TestSystemManagerIngest(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sm := hub.GetSystemManager()
+	sm.SetClock(clock.NewMock(now))
+	require.NoError(t, sm.Initialize())
+
+	user, err := tests.CreateUser(hub, "test@test.com", "testtesttest")
+	require.NoError(t, err)
+	record, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "esp32",
+		"host":  "esp32.local",
+		"port":  "1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	// the latest ingested data replaces data that wasn't saved yet
+	require.NoError(t, sm.Ingest(record.Id, &system.CombinedData{Stats: system.Stats{Temperatures: map[string]float64{"probe": 20}}}))
+	require.NoError(t, sm.Ingest(record.Id, &system.CombinedData{Stats: system.Stats{Temperatures: map[string]float64{"probe": 21.5}}}))
+	require.NoError(t, sm.UpdateWithIngested(record.Id))
+	assert.Error(t, sm.UpdateWithIngested(record.Id), "data is only saved once")
+
+	statsRecords, err := hub.FindAllRecords("system_stats", dbx.HashExp{"system": record.Id})
+	require.NoError(t, err)
+	require.Len(t, statsRecords, 1)
+	var stats system.Stats
+	require.NoError(t, statsRecords[0].UnmarshalJSONField("stats", &stats))
+	assert.Equal(t, map[string]float64{"probe": 21.5}, stats.Temperatures)
+
+	record, err = hub.FindRecordById("systems", record.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "up", record.GetString("status"))
+
+	assert.ErrorIs(t, sm.Ingest("missing", &system.CombinedData{}), systems.ErrSystemInactive)
+}
+
+func TestSystemManagerIngestOnly(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sm := hub.GetSystemManager()
+	sm.SetClock(clock.NewMock(now))
+	require.NoError(t, sm.Initialize())
+
+	user, err := tests.CreateUser(hub, "test@test.com", "testtesttest")
+	require.NoError(t, err)
+	record, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":   "esp32",
+		"host":   "esp32.local",
+		"port":   "1",
+		"users":  []string{user.Id},
+		"ingest": true,
+	})
+	require.NoError(t, err)
+	status := func() string {
+		record, err := hub.FindRecordById("systems", record.Id)
+		require.NoError(t, err)
+		return record.GetString("status")
+	}
+
+	// ingest-only systems aren't polled, and a missed post keeps the status
+	require.NoError(t, sm.UpdateSystem(record.Id))
+	assert.Equal(t, "pending", status())
+	require.NoError(t, sm.Ingest(record.Id, &system.CombinedData{Stats: system.Stats{Cpu: 10}}))
+	require.NoError(t, sm.UpdateWithIngested(record.Id))
+	assert.Equal(t, "up", status())
+	require.NoError(t, sm.UpdateSystem(record.Id))
+	assert.Equal(t, "up", status())
+
+	// they are set down after the grace period without posts
+	require.NoError(t, sm.SetLastIngest(record.Id, now.Add(-5*time.Minute)))
+	assert.ErrorContains(t, sm.UpdateSystem(record.Id), "no stats posted for 5m0s")
+}
+
+func TestSystemManagerLabels(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sm := hub.GetSystemManager()
+	sm.SetClock(clock.NewMock(now))
+	require.NoError(t, sm.Initialize())
+
+	user, err := tests.CreateUser(hub, "test@test.com", "testtesttest")
+	require.NoError(t, err)
+	record, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":   "labels",
+		"host":   "127.0.0.1",
+		"port":   "1",
+		"users":  []string{user.Id},
+		"labels": map[string]string{"env": "dev", "site": "berlin"},
+	})
+	require.NoError(t, err)
+
+	// labels declared by the agent replace the same keys and others are kept
+	data := &system.CombinedData{Info: system.Info{Labels: map[string]string{"env": "prod", "rack": "3", "": "empty"}}}
+	require.NoError(t, sm.UpdateWithPushed(record.Id, data, now))
+	record, err = hub.FindRecordById("systems", record.Id)
+	require.NoError(t, err)
+	var labels map[string]string
+	require.NoError(t, record.UnmarshalJSONField("labels", &labels))
+	assert.Equal(t, map[string]string{"env": "prod", "rack": "3", "site": "berlin"}, labels)
+	var info system.Info
+	require.NoError(t, record.UnmarshalJSONField("info", &info))
+	assert.Nil(t, info.Labels, "labels aren't stored in the info")
+}
+
+func TestSanitizeLabels(t *testing.T) {
+	assert.Nil(t, systems.SanitizeLabels(nil))
+	assert.Equal(t, map[string]string{"env": "prod"}, systems.SanitizeLabels(map[string]string{
+		"env":  "prod",
+		"":     "empty",
+		"long": strings.Repeat("x", systems.MaxLabelLength+1),
+	}))
+	many := make(map[string]string)
+	for i := range systems.MaxLabels + 5 {
+		many[fmt.Sprint(i)] = "x"
+	}
+	assert.Len(t, systems.SanitizeLabels(many), systems.MaxLabels)
+}
+
+func TestSystemManagerStorageDriver(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	driver := &memoryStorage{}
+	hub.SetStorage(driver)
+	sm := hub.GetSystemManager()
+	sm.SetClock(clock.NewMock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	require.NoError(t, sm.Initialize())
+
+	user, err := tests.CreateUser(hub, "test@test.com", "testtesttest")
+	require.NoError(t, err)
+	record, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "memory",
+		"host":  "127.0.0.1",
+		"port":  "1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	data := &system.CombinedData{Stats: system.Stats{Cpu: 42}, Info: system.Info{Cpu: 42}}
+	require.NoError(t, sm.CreateRecords(record.Id, data, time.Now()))
+
+	records, err := driver.SystemStats(storage.Query{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, record.Id, records[0].System)
+	assert.Equal(t, 42.0, records[0].Stats.Cpu)
+
+	count, err := hub.CountRecords("system_stats")
+	require.NoError(t, err)
+	assert.Zero(t, count, "default driver not used")
+
+	systemRecord, err := hub.FindRecordById("systems", record.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "up", systemRecord.GetString("status"))
+}
+
+func TestSystemManagerEvents(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	sm := hub.GetSystemManager()
+	require.NoError(t, sm.Initialize())
+
+	user, err := tests.CreateUser(hub, "test@test.com", "testtesttest")
+	require.NoError(t, err)
+	record, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "events",
+		"host":  "127.0.0.1",
+		"port":  "1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	eventTime := time.Date(2025, 1, 2, 15, 4, 5, 123e6, time.UTC)
+	value := 1284.0
+	data := &system.CombinedData{Events: []system.Event{
+		{Name: "backup", Message: "Backup completed", Value: &value, Time: eventTime.UnixMilli()},
+		{Name: "deploy", Time: eventTime.UnixMilli()},
+	}}
+	require.NoError(t, sm.CreateRecords(record.Id, data, time.Now()))
+	// events sent again with cached data are not saved twice
+	require.NoError(t, sm.CreateRecords(record.Id, data, time.Now()))
+
+	events, err := hub.FindAllRecords("system_events")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	byName := map[string]*core.Record{}
+	for _, event := range events {
+		byName[event.GetString("name")] = event
+	}
+	assert.Equal(t, record.Id, byName["backup"].GetString("system"))
+	assert.Equal(t, "Backup completed", byName["backup"].GetString("message"))
+	assert.Equal(t, "1284", byName["backup"].GetString("value"))
+	assert.Equal(t, eventTime, byName["backup"].GetDateTime("time").Time())
+	assert.Equal(t, "null", byName["deploy"].GetString("value"))
+}
+
+func TestSystemManagerPublicIpChange(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	sm := hub.GetSystemManager()
+	require.NoError(t, sm.Initialize())
+
+	user, err := tests.CreateUser(hub, "test@test.com", "testtesttest")
+	require.NoError(t, err)
+	record, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "wan",
+		"host":  "127.0.0.1",
+		"port":  "1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	update := func(ip string) {
+		data := &system.CombinedData{Info: system.Info{PublicIp: ip}}
+		require.NoError(t, sm.CreateRecords(record.Id, data, time.Now()))
+	}
+	// no event for the first IP, a missing IP, or the same IP
+	update("203.0.113.7")
+	update("")
+	update("203.0.113.7")
+	events, err := hub.FindAllRecords("system_events")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	update("198.51.100.1")
+	events, err = hub.FindAllRecords("system_events")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "public_ip", events[0].GetString("name"))
+	assert.Equal(t, "Public IP changed from 203.0.113.7 to 198.51.100.1", events[0].GetString("message"))
+
+	record, err = hub.FindRecordById("systems", record.Id)
+	require.NoError(t, err)
+	var info system.Info
+	require.NoError(t, record.UnmarshalJSONField("info", &info))
+	assert.Equal(t, "198.51.100.1", info.PublicIp)
+}
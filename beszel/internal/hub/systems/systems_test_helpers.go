@@ -4,11 +4,45 @@
 package systems
 
 import (
+	"beszel/internal/clock"
 	entities "beszel/internal/entities/system"
 	"context"
 	"fmt"
+	"time"
 )
 
+// TESTING ONLY: SetClock sets the time source used for update tickers and delays
+// Must be called before Initialize or adding any systems
+func (sm *SystemManager) SetClock(c clock.Clock) {
+	sm.clock = c
+}
+
+// TESTING ONLY: UpdateWithPushed updates a system as if its agent pushed the data at the given time
+func (sm *SystemManager) UpdateWithPushed(systemID string, data *entities.CombinedData, received time.Time) error {
+	sys, ok := sm.systems.GetOk(systemID)
+	if !ok {
+		return fmt.Errorf("no system")
+	}
+	sys.pushed, sys.pushedAt = data, received
+	return sys.update()
+}
+
+// TESTING ONLY: UpdateWithIngested updates a system with the data passed to Ingest, as the updater does
+func (sm *SystemManager) UpdateWithIngested(systemID string) error {
+	sys, ok := sm.systems.GetOk(systemID)
+	if !ok {
+		return fmt.Errorf("no system")
+	}
+	select {
+	case data := <-sys.ingestChan:
+		sys.pushed, sys.pushedAt = data, sm.clock.Now()
+		sys.lastIngest = sys.pushedAt
+	default:
+		return fmt.Errorf("no ingested data")
+	}
+	return sys.update()
+}
+
 // TESTING ONLY: GetSystemCount returns the number of systems in the store
 func (sm *SystemManager) GetSystemCount() int {
 	return sm.systems.Length()
@@ -68,6 +102,16 @@ func (sm *SystemManager) GetSystemData(systemID string) *entities.CombinedData {
 	return sys.data
 }
 
+// TESTING ONLY: CreateRecords saves data for a system as if it was received from the agent at the given time
+func (sm *SystemManager) CreateRecords(systemID string, data *entities.CombinedData, received time.Time) error {
+	sys, ok := sm.systems.GetOk(systemID)
+	if !ok {
+		return fmt.Errorf("no system")
+	}
+	_, err := sys.createRecords(data, received)
+	return err
+}
+
 // TESTING ONLY: GetSystemHostPort returns the host and port for a system with the given ID
 // Returns empty strings if the system doesn't exist
 func (sm *SystemManager) GetSystemHostPort(systemID string) (string, string) {
@@ -100,3 +144,22 @@ func (sm *SystemManager) SetSystemStatusInDB(systemID string, status string) boo
 
 	return true
 }
+
+// TESTING ONLY: UpdateSystem runs an update of a system as the updater does
+func (sm *SystemManager) UpdateSystem(systemID string) error {
+	sys, ok := sm.systems.GetOk(systemID)
+	if !ok {
+		return fmt.Errorf("no system")
+	}
+	return sys.update()
+}
+
+// TESTING ONLY: SetLastIngest sets the time of the latest post to the ingest API
+func (sm *SystemManager) SetLastIngest(systemID string, t time.Time) error {
+	sys, ok := sm.systems.GetOk(systemID)
+	if !ok {
+		return fmt.Errorf("no system")
+	}
+	sys.lastIngest = t
+	return nil
+}
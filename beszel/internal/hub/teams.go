@@ -0,0 +1,167 @@
+package hub
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// includeTeamAdmins adds the admins of a team to its members, so they can
+// access the team's systems
+func includeTeamAdmins(e *core.RecordEvent) error {
+	members := e.Record.GetStringSlice("members")
+	for _, admin := range e.Record.GetStringSlice("admins") {
+		if !slices.Contains(members, admin) {
+			members = append(members, admin)
+		}
+	}
+	e.Record.Set("members", members)
+	return e.Next()
+}
+
+// validateTeam checks the notification channels of a new or updated team
+func validateTeam(e *core.RecordRequestEvent) error {
+	for _, field := range []string{"emails", "webhooks"} {
+		var values []string
+		if raw := e.Record.GetString(field); raw != "" && raw != "null" {
+			if err := e.Record.UnmarshalJSONField(field, &values); err != nil {
+				return e.BadRequestError("Invalid "+field+", expected a list of strings", nil)
+			}
+		}
+		if values == nil {
+			e.Record.Set(field, []string{})
+		}
+	}
+	return e.Next()
+}
+
+// validateSystemTeam checks that users only assign systems to their own teams.
+// Admins can assign systems to any team.
+func validateSystemTeam(e *core.RecordRequestEvent) error {
+	teamId := e.Record.GetString("team")
+	if teamId == "" || teamId == e.Record.Original().GetString("team") || e.HasSuperuserAuth() {
+		return e.Next()
+	}
+	if e.Auth == nil || e.Auth.GetString("role") != "admin" {
+		team, err := e.App.FindRecordById("teams", teamId)
+		if err != nil || e.Auth == nil || !slices.Contains(team.GetStringSlice("members"), e.Auth.Id) {
+			return e.BadRequestError("You aren't a member of the team", nil)
+		}
+	}
+	return e.Next()
+}
+
+// TeamMember is a member or operator of a team
+type TeamMember struct {
+	Id       string `json:"id"`
+	Email    string `json:"email"`
+	Admin    bool   `json:"admin"`
+	Operator bool   `json:"operator"`
+}
+
+// findTeam returns the team in the id path param if the user can view it, or
+// change it if update is true. Users can't view other users, so team admins
+// manage members by email with the team members endpoints.
+func findTeam(e *core.RequestEvent, update bool) (*core.Record, error) {
+	team, err := e.App.FindRecordById("teams", e.Request.PathValue("id"))
+	if err != nil {
+		return nil, e.NotFoundError("Team not found", err)
+	}
+	info, err := e.RequestInfo()
+	if err != nil {
+		return nil, e.InternalServerError("", err)
+	}
+	rule := team.Collection().ViewRule
+	if update {
+		rule = team.Collection().UpdateRule
+	}
+	if canAccess, err := e.App.CanAccessRecord(team, info, rule); !canAccess {
+		return nil, e.NotFoundError("Team not found", err)
+	}
+	return team, nil
+}
+
+// listTeamMembers handles GET /api/beszel/teams/{id}/members, which returns
+// the members and operators of a team sorted by email
+func listTeamMembers(e *core.RequestEvent) error {
+	team, err := findTeam(e, false)
+	if err != nil {
+		return err
+	}
+	operators := team.GetStringSlice("operators")
+	users, err := e.App.FindRecordsByIds("users", append(team.GetStringSlice("members"), operators...))
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	admins := team.GetStringSlice("admins")
+	members := make([]TeamMember, 0, len(users))
+	for _, user := range users {
+		members = append(members, TeamMember{
+			Id:       user.Id,
+			Email:    user.Email(),
+			Admin:    slices.Contains(admins, user.Id),
+			Operator: slices.Contains(operators, user.Id),
+		})
+	}
+	slices.SortFunc(members, func(a, b TeamMember) int { return strings.Compare(a.Email, b.Email) })
+	return e.JSON(http.StatusOK, members)
+}
+
+// addTeamMember handles POST /api/beszel/teams/{id}/members, which adds the
+// user with an email to a team, or changes whether the member is an admin.
+// Operators can view the team's systems and acknowledge their alerts, but
+// aren't members, so they can't change the systems.
+func addTeamMember(e *core.RequestEvent) error {
+	team, err := findTeam(e, true)
+	if err != nil {
+		return err
+	}
+	var data struct {
+		Email    string `json:"email"`
+		Admin    bool   `json:"admin"`
+		Operator bool   `json:"operator"`
+	}
+	if err := e.BindBody(&data); err != nil || data.Email == "" {
+		return e.BadRequestError("Email is required", err)
+	}
+	user, err := e.App.FindAuthRecordByEmail("users", data.Email)
+	if err != nil {
+		return e.NotFoundError("User not found", err)
+	}
+	switch {
+	case data.Operator:
+		team.Set("operators+", user.Id)
+		team.Set("members-", user.Id)
+		team.Set("admins-", user.Id)
+	case data.Admin:
+		team.Set("members+", user.Id)
+		team.Set("admins+", user.Id)
+		team.Set("operators-", user.Id)
+	default:
+		team.Set("members+", user.Id)
+		team.Set("admins-", user.Id)
+		team.Set("operators-", user.Id)
+	}
+	if err := e.App.Save(team); err != nil {
+		return e.BadRequestError("Failed to add member", err)
+	}
+	return e.JSON(http.StatusOK, TeamMember{Id: user.Id, Email: user.Email(), Admin: data.Admin && !data.Operator, Operator: data.Operator})
+}
+
+// removeTeamMember handles DELETE /api/beszel/teams/{id}/members/{user}
+func removeTeamMember(e *core.RequestEvent) error {
+	team, err := findTeam(e, true)
+	if err != nil {
+		return err
+	}
+	userId := e.Request.PathValue("user")
+	team.Set("members-", userId)
+	team.Set("admins-", userId)
+	team.Set("operators-", userId)
+	if err := e.App.Save(team); err != nil {
+		return e.BadRequestError("Failed to remove member", err)
+	}
+	return e.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,379 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeams(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	createUser := func(email, role string) (*core.Record, string) {
+		user, err := beszelTests.CreateUser(hub, email, "password123")
+		require.NoError(t, err)
+		user.Set("role", role)
+		require.NoError(t, hub.Save(user))
+		token, err := user.NewAuthToken()
+		require.NoError(t, err)
+		return user, token
+	}
+	_, adminToken := createUser("admin@example.com", "admin")
+	teamAdmin, teamAdminToken := createUser("teamadmin@example.com", "user")
+	member, memberToken := createUser("member@example.com", "user")
+	outsider, outsiderToken := createUser("outsider@example.com", "user")
+	operator, operatorToken := createUser("operator@example.com", "user")
+
+	team, err := beszelTests.CreateRecord(hub, "teams", map[string]any{
+		"name":      "Client A",
+		"admins":    []string{teamAdmin.Id},
+		"members":   []string{member.Id},
+		"operators": []string{operator.Id},
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{teamAdmin.Id, member.Id}, team.GetStringSlice("members"), "admins should be members")
+
+	teamSystem, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "client-a-web",
+		"host":  "10.0.0.1",
+		"users": []string{teamAdmin.Id},
+		"team":  team.Id,
+	})
+	require.NoError(t, err)
+	_, err = beszelTests.CreateRecord(hub, "status_changes", map[string]any{
+		"system": teamSystem.Id,
+		"status": "up",
+	})
+	require.NoError(t, err)
+	alertHistory, err := beszelTests.CreateRecord(hub, "alerts_history", map[string]any{
+		"user":   teamAdmin.Id,
+		"system": teamSystem.Id,
+		"name":   "CPU",
+	})
+	require.NoError(t, err)
+	_, err = beszelTests.CreateRecord(hub, "alerts", map[string]any{
+		"user":   teamAdmin.Id,
+		"system": teamSystem.Id,
+		"name":   "Memory",
+		"value":  80,
+		"min":    5,
+	})
+	require.NoError(t, err)
+	outsiderSystem, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "outsider-web",
+		"host":  "10.0.0.2",
+		"users": []string{outsider.Id},
+	})
+	require.NoError(t, err)
+
+	testAppFactory := func(t testing.TB) *tests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "members see the systems of their team",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/systems/records",
+			Headers:         map[string]string{"Authorization": memberToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":1`, `"name":"client-a-web"`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:               "other users don't see the systems of the team",
+			Method:             http.MethodGet,
+			URL:                "/api/collections/systems/records",
+			Headers:            map[string]string{"Authorization": outsiderToken},
+			ExpectedStatus:     200,
+			ExpectedContent:    []string{`"totalItems":1`, `"name":"outsider-web"`},
+			NotExpectedContent: []string{"client-a-web"},
+			TestAppFactory:     testAppFactory,
+		},
+		{
+			Name:            "members see the records of team systems in other collections",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/status_changes/records",
+			Headers:         map[string]string{"Authorization": memberToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":1`, teamSystem.Id},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "other users don't see the records of team systems",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/status_changes/records",
+			Headers:         map[string]string{"Authorization": outsiderToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":0`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "operators see the systems of their team",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/systems/records",
+			Headers:         map[string]string{"Authorization": operatorToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":1`, `"name":"client-a-web"`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "operators see the records of team systems in other collections",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/status_changes/records",
+			Headers:         map[string]string{"Authorization": operatorToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":1`, teamSystem.Id},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "operators can't update the systems of their team",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/systems/records/" + teamSystem.Id,
+			Headers:         map[string]string{"Authorization": operatorToken},
+			Body:            strings.NewReader(`{"name": "renamed"}`),
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"wasn't found"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "operators see the alert history of team systems",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/alerts_history/records",
+			Headers:         map[string]string{"Authorization": operatorToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":1`, alertHistory.Id},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "members don't see the alert history of other members",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/alerts_history/records",
+			Headers:         map[string]string{"Authorization": memberToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":0`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "other users can't acknowledge alerts of team systems",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-history/ack",
+			Headers:         map[string]string{"Authorization": outsiderToken},
+			Body:            strings.NewReader(`{"ids": ["` + alertHistory.Id + `"]}`),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"acknowledged":0`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "operators acknowledge alerts of team systems",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/alert-history/ack",
+			Headers:         map[string]string{"Authorization": operatorToken},
+			Body:            strings.NewReader(`{"ids": ["` + alertHistory.Id + `"]}`),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"acknowledged":1`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				record, err := app.FindRecordById("alerts_history", alertHistory.Id)
+				require.NoError(t, err)
+				assert.False(t, record.GetDateTime("acknowledged").IsZero())
+				assert.Equal(t, operator.Id, record.GetString("acknowledged_by"))
+			},
+		},
+		{
+			Name:            "members see the alert changes of team systems",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/alert_changes/records",
+			Headers:         map[string]string{"Authorization": memberToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":1`, `"email":"teamadmin@example.com"`, `"name":"Memory"`, `"action":"create"`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "other users don't see the alert changes of team systems",
+			Method:          http.MethodGet,
+			URL:             "/api/collections/alert_changes/records",
+			Headers:         map[string]string{"Authorization": outsiderToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"totalItems":0`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "users can't create teams",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/teams/records",
+			Headers:         map[string]string{"Authorization": teamAdminToken},
+			Body:            strings.NewReader(`{"name": "Client B"}`),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Failed to create record"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "admins can create teams",
+			Method:          http.MethodPost,
+			URL:             "/api/collections/teams/records",
+			Headers:         map[string]string{"Authorization": adminToken},
+			Body:            strings.NewReader(`{"name": "Client B", "emails": ["ops@example.com"]}`),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"name":"Client B"`, `"webhooks":[]`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "channels must be lists of strings",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/teams/records/" + team.Id,
+			Headers:         map[string]string{"Authorization": teamAdminToken},
+			Body:            strings.NewReader(`{"webhooks": {"url": "ntfy://ntfy.sh/a"}}`),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"Invalid webhooks"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "members can't change their team",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/teams/records/" + team.Id,
+			Headers:         map[string]string{"Authorization": memberToken},
+			Body:            strings.NewReader(`{"name": "Renamed"}`),
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"wasn't found"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "team admins can change their team",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/teams/records/" + team.Id,
+			Headers:         map[string]string{"Authorization": teamAdminToken},
+			Body:            strings.NewReader(`{"webhooks": ["ntfy://ntfy.sh/client-a"]}`),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"webhooks":["ntfy://ntfy.sh/client-a"]`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "users can't assign systems to other teams",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/systems/records/" + outsiderSystem.Id,
+			Headers:         map[string]string{"Authorization": outsiderToken},
+			Body:            strings.NewReader(`{"team": "` + team.Id + `"}`),
+			ExpectedStatus:  400,
+			ExpectedContent: []string{"You aren't a member of the team"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "members can update the systems of their team",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/systems/records/" + teamSystem.Id,
+			Headers:         map[string]string{"Authorization": memberToken},
+			Body:            strings.NewReader(`{"name": "client-a-www"}`),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"name":"client-a-www"`, `"team":"` + team.Id + `"`},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:           "members list the members of their team",
+			Method:         http.MethodGet,
+			URL:            "/api/beszel/teams/" + team.Id + "/members",
+			Headers:        map[string]string{"Authorization": memberToken},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`{"id":"` + member.Id + `","email":"member@example.com","admin":false,"operator":false}`,
+				`"email":"teamadmin@example.com","admin":true`,
+				`"email":"operator@example.com","admin":false,"operator":true`,
+			},
+			TestAppFactory: testAppFactory,
+		},
+		{
+			Name:            "other users can't list the members",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/teams/" + team.Id + "/members",
+			Headers:         map[string]string{"Authorization": outsiderToken},
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"Team not found"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "members can't add members",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/teams/" + team.Id + "/members",
+			Headers:         map[string]string{"Authorization": memberToken},
+			Body:            strings.NewReader(`{"email": "outsider@example.com"}`),
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"Team not found"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "unknown emails can't be added",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/teams/" + team.Id + "/members",
+			Headers:         map[string]string{"Authorization": teamAdminToken},
+			Body:            strings.NewReader(`{"email": "nobody@example.com"}`),
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"User not found"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "team admins add members by email",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/teams/" + team.Id + "/members",
+			Headers:         map[string]string{"Authorization": teamAdminToken},
+			Body:            strings.NewReader(`{"email": "outsider@example.com", "admin": true}`),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"email":"outsider@example.com","admin":true`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				team, err := app.FindRecordById("teams", team.Id)
+				require.NoError(t, err)
+				assert.Contains(t, team.GetStringSlice("members"), outsider.Id)
+				assert.Contains(t, team.GetStringSlice("admins"), outsider.Id)
+			},
+		},
+		{
+			Name:            "team admins make members operators",
+			Method:          http.MethodPost,
+			URL:             "/api/beszel/teams/" + team.Id + "/members",
+			Headers:         map[string]string{"Authorization": teamAdminToken},
+			Body:            strings.NewReader(`{"email": "outsider@example.com", "operator": true}`),
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"email":"outsider@example.com","admin":false,"operator":true`},
+			TestAppFactory:  testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				team, err := app.FindRecordById("teams", team.Id)
+				require.NoError(t, err)
+				assert.NotContains(t, team.GetStringSlice("members"), outsider.Id)
+				assert.NotContains(t, team.GetStringSlice("admins"), outsider.Id)
+				assert.Contains(t, team.GetStringSlice("operators"), outsider.Id)
+			},
+		},
+		{
+			Name:           "team admins remove members",
+			Method:         http.MethodDelete,
+			URL:            "/api/beszel/teams/" + team.Id + "/members/" + outsider.Id,
+			Headers:        map[string]string{"Authorization": teamAdminToken},
+			ExpectedStatus: 204,
+			TestAppFactory: testAppFactory,
+			AfterTestFunc: func(t testing.TB, app *tests.TestApp, res *http.Response) {
+				team, err := app.FindRecordById("teams", team.Id)
+				require.NoError(t, err)
+				assert.NotContains(t, team.GetStringSlice("members"), outsider.Id)
+				assert.NotContains(t, team.GetStringSlice("admins"), outsider.Id)
+				assert.NotContains(t, team.GetStringSlice("operators"), outsider.Id)
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,36 @@
+package hub
+
+import (
+	"beszel/internal/records"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// SystemUptime is the response of the uptime API
+type SystemUptime struct {
+	Status string           `json:"status"`
+	Uptime []records.Uptime `json:"uptime"` // over the last 24 hours, 7, 30 and 90 days
+}
+
+// getUptime handles GET /api/beszel/uptime?system=<id>. Returns the share of
+// time the system was up over each uptime window.
+func (h *Hub) getUptime(e *core.RequestEvent) error {
+	systemRecord, err := e.App.FindRecordById("systems", e.Request.URL.Query().Get("system"))
+	if err != nil {
+		return e.NotFoundError("System not found", err)
+	}
+	info, err := e.RequestInfo()
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	if canAccess, err := e.App.CanAccessRecord(systemRecord, info, systemRecord.Collection().ViewRule); !canAccess {
+		return e.NotFoundError("System not found", err)
+	}
+	uptime, err := records.SystemUptime(e.App, systemRecord.Id, time.Now())
+	if err != nil {
+		return e.InternalServerError("", err)
+	}
+	return e.JSON(http.StatusOK, SystemUptime{Status: systemRecord.GetString("status"), Uptime: uptime})
+}
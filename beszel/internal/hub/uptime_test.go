@@ -0,0 +1,100 @@
+//go:build testing
+// +build testing
+
+package hub_test
+
+import (
+	beszelTests "beszel/internal/tests"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUptimeApi(t *testing.T) {
+	hub, err := beszelTests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	hub.StartHub()
+
+	user, err := beszelTests.CreateUser(hub, "uptime@example.com", "password123")
+	require.NoError(t, err)
+	userToken, err := user.NewAuthToken()
+	require.NoError(t, err)
+	otherUser, err := beszelTests.CreateUser(hub, "other@example.com", "password123")
+	require.NoError(t, err)
+	otherToken, err := otherUser.NewAuthToken()
+	require.NoError(t, err)
+
+	systemRecord, err := beszelTests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "web",
+		"host":  "127.0.0.1",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	// status changes of system records are tracked
+	setStatus := func(status string) {
+		record, err := hub.FindRecordById("systems", systemRecord.Id)
+		require.NoError(t, err)
+		record.Set("status", status)
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+	setStatus("up")
+	setStatus("up")
+	setStatus("down")
+	changes, err := hub.FindAllRecords("status_changes", dbx.HashExp{"system": systemRecord.Id})
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "up", changes[0].GetString("status"))
+	assert.Equal(t, "down", changes[1].GetString("status"))
+
+	// up for three hours and down for one
+	now := time.Now().UTC()
+	changes[0].SetRaw("created", now.Add(-4*time.Hour).Format(types.DefaultDateLayout))
+	require.NoError(t, hub.SaveNoValidate(changes[0]))
+	changes[1].SetRaw("created", now.Add(-time.Hour).Format(types.DefaultDateLayout))
+	require.NoError(t, hub.SaveNoValidate(changes[1]))
+
+	testAppFactory := func(t testing.TB) *tests.TestApp {
+		return hub.TestApp
+	}
+
+	scenarios := []beszelTests.ApiScenario{
+		{
+			Name:            "GET /uptime - no auth should fail",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/uptime?system=" + systemRecord.Id,
+			ExpectedStatus:  401,
+			ExpectedContent: []string{"requires valid"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /uptime - other user's system",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/uptime?system=" + systemRecord.Id,
+			Headers:         map[string]string{"Authorization": otherToken},
+			ExpectedStatus:  404,
+			ExpectedContent: []string{"System not found"},
+			TestAppFactory:  testAppFactory,
+		},
+		{
+			Name:            "GET /uptime - uptime over each window",
+			Method:          http.MethodGet,
+			URL:             "/api/beszel/uptime?system=" + systemRecord.Id,
+			Headers:         map[string]string{"Authorization": userToken},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{`"status":"down"`, `"window":"24h","percent":74.99`, `"window":"90d","percent":74.99`, `"incidents":1`},
+			TestAppFactory:  testAppFactory,
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
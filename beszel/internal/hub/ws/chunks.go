@@ -0,0 +1,138 @@
+package ws
+
+import (
+	"beszel/internal/common"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	// DefaultChunkSize is the max message size requested from agents. Larger
+	// payloads are sent in chunks.
+	DefaultChunkSize = 1024 * 1024
+	// Largest payload reassembled from chunks
+	maxChunkedPayloadSize = 128 * 1024 * 1024
+	// How long an interrupted transfer can be resumed
+	chunkTransferTTL = 2 * time.Minute
+	// How long a chunk waits for the reader before the connection is closed
+	chunkReadWait = 5 * time.Second
+)
+
+// errTransferExpired is returned when the agent no longer has the payload of a transfer
+var errTransferExpired = errors.New("chunked transfer expired")
+
+// chunkTransfer is a payload being received in chunks
+type chunkTransfer struct {
+	id      uint32
+	total   int
+	size    int
+	sum     uint32
+	buf     []byte
+	next    int // index of the next chunk
+	started time.Time
+}
+
+// newChunkTransfer starts a transfer from its first chunk
+func newChunkTransfer(chunk common.Chunk) (*chunkTransfer, error) {
+	if chunk.Total == 0 {
+		return nil, errTransferExpired
+	}
+	if chunk.Total < 0 || chunk.Size < 0 || chunk.Size > maxChunkedPayloadSize {
+		return nil, fmt.Errorf("invalid chunked payload size %d", chunk.Size)
+	}
+	return &chunkTransfer{
+		id:      chunk.Id,
+		total:   chunk.Total,
+		size:    chunk.Size,
+		sum:     chunk.Sum,
+		buf:     make([]byte, 0, chunk.Size),
+		started: time.Now(),
+	}, nil
+}
+
+// add adds a chunk to the transfer and returns the payload once all chunks
+// are received. Chunks received before are ignored.
+func (t *chunkTransfer) add(chunk common.Chunk) ([]byte, error) {
+	if chunk.Total == 0 {
+		return nil, errTransferExpired
+	}
+	if chunk.Id != t.id || chunk.Total != t.total || chunk.Size != t.size || chunk.Sum != t.sum {
+		return nil, errors.New("chunk does not match transfer")
+	}
+	if chunk.Index < t.next {
+		return nil, nil
+	}
+	if chunk.Index > t.next {
+		return nil, fmt.Errorf("missing chunk %d of %d", t.next, t.total)
+	}
+	if len(t.buf)+len(chunk.Data) > t.size {
+		return nil, errors.New("chunk exceeds payload size")
+	}
+	t.buf = append(t.buf, chunk.Data...)
+	t.next++
+	if t.next < t.total {
+		return nil, nil
+	}
+	if len(t.buf) != t.size || crc32.ChecksumIEEE(t.buf) != t.sum {
+		return nil, errors.New("chunked payload checksum mismatch")
+	}
+	return t.buf, nil
+}
+
+// isChunk reports whether a message is a CBOR tag, which whole payloads never are
+func isChunk(data []byte) bool {
+	const cborMajorTag = 6
+	return len(data) > 0 && data[0]>>5 == cborMajorTag
+}
+
+// decodeChunk decodes a chunk message
+func decodeChunk(data []byte) (chunk common.Chunk, err error) {
+	var tag cbor.RawTag
+	if err = cbor.Unmarshal(data, &tag); err != nil {
+		return chunk, err
+	}
+	if tag.Number != common.ChunkTag {
+		return chunk, fmt.Errorf("unexpected CBOR tag %d", tag.Number)
+	}
+	err = cbor.Unmarshal(tag.Content, &chunk)
+	return chunk, err
+}
+
+// addChunk adds a chunk to the current transfer, starting a new one if the
+// chunk belongs to another payload, and returns the payload once complete.
+func (ws *WsConn) addChunk(chunk common.Chunk) (payload []byte, err error) {
+	transfer := ws.transfer.Load()
+	if transfer == nil || transfer.id != chunk.Id {
+		if transfer, err = newChunkTransfer(chunk); err != nil {
+			return nil, err
+		}
+		ws.transfer.Store(transfer)
+	}
+	return transfer.add(chunk)
+}
+
+// resumableTransfer returns the interrupted transfer if it can still be resumed
+func (ws *WsConn) resumableTransfer() *chunkTransfer {
+	transfer := ws.transfer.Load()
+	if transfer == nil || time.Since(transfer.started) > chunkTransferTTL {
+		return nil
+	}
+	return transfer
+}
+
+// ResumeTransfer takes over an interrupted chunked transfer of a previous
+// connection from the same agent, so it continues where it stopped.
+func (ws *WsConn) ResumeTransfer(prev *WsConn) {
+	if prev != nil && prev != ws {
+		ws.transfer.Store(prev.transfer.Swap(nil))
+	}
+}
+
+// SetChunkSize sets the max message size requested from the agent
+func (ws *WsConn) SetChunkSize(size int) {
+	ws.chunkSize = size
+}
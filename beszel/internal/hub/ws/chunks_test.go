@@ -0,0 +1,120 @@
+//go:build testing
+// +build testing
+
+package ws
+
+import (
+	"beszel/internal/common"
+	"bytes"
+	"hash/crc32"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeChunks splits a payload into n chunks like the agent does
+func makeChunks(id uint32, payload []byte, n int) []common.Chunk {
+	size := (len(payload) + n - 1) / n
+	chunks := make([]common.Chunk, n)
+	for i := range chunks {
+		chunks[i] = common.Chunk{
+			Id:    id,
+			Index: i,
+			Total: n,
+			Size:  len(payload),
+			Sum:   crc32.ChecksumIEEE(payload),
+			Data:  payload[i*size : min((i+1)*size, len(payload))],
+		}
+	}
+	return chunks
+}
+
+func TestChunkTransfer(t *testing.T) {
+	payload := bytes.Repeat([]byte("chunked payload "), 1000)
+
+	t.Run("reassembles in order", func(t *testing.T) {
+		ws := NewWsConnection(nil)
+		var result []byte
+		for _, chunk := range makeChunks(1, payload, 4) {
+			out, err := ws.addChunk(chunk)
+			require.NoError(t, err)
+			if chunk.Index < 3 {
+				assert.Nil(t, out)
+			}
+			result = out
+		}
+		assert.Equal(t, payload, result)
+	})
+
+	t.Run("resumes on a new connection", func(t *testing.T) {
+		chunks := makeChunks(2, payload, 4)
+		prev := NewWsConnection(nil)
+		for _, chunk := range chunks[:2] {
+			_, err := prev.addChunk(chunk)
+			require.NoError(t, err)
+		}
+
+		ws := NewWsConnection(nil)
+		ws.ResumeTransfer(prev)
+		assert.Nil(t, prev.transfer.Load())
+		transfer := ws.resumableTransfer()
+		require.NotNil(t, transfer)
+		assert.Equal(t, uint32(2), transfer.id)
+		assert.Equal(t, 2, transfer.next)
+
+		// chunks received before are ignored
+		out, err := ws.addChunk(chunks[1])
+		require.NoError(t, err)
+		assert.Nil(t, out)
+		_, err = ws.addChunk(chunks[2])
+		require.NoError(t, err)
+		out, err = ws.addChunk(chunks[3])
+		require.NoError(t, err)
+		assert.Equal(t, payload, out)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		chunks := makeChunks(3, payload, 4)
+
+		ws := NewWsConnection(nil)
+		_, err := ws.addChunk(chunks[0])
+		require.NoError(t, err)
+		_, err = ws.addChunk(chunks[2])
+		assert.ErrorContains(t, err, "missing chunk 1")
+
+		_, err = ws.addChunk(common.Chunk{Id: 3})
+		assert.ErrorIs(t, err, errTransferExpired)
+		_, err = NewWsConnection(nil).addChunk(common.Chunk{Id: 4})
+		assert.ErrorIs(t, err, errTransferExpired)
+
+		_, err = NewWsConnection(nil).addChunk(common.Chunk{Id: 5, Total: 1, Size: maxChunkedPayloadSize + 1})
+		assert.Error(t, err)
+
+		corrupt := makeChunks(6, payload, 1)[0]
+		corrupt.Data = bytes.Repeat([]byte("x"), len(payload))
+		_, err = NewWsConnection(nil).addChunk(corrupt)
+		assert.ErrorContains(t, err, "checksum mismatch")
+	})
+}
+
+func TestDecodeChunk(t *testing.T) {
+	chunk := common.Chunk{Id: 9, Index: 1, Total: 2, Size: 3, Sum: 4, Data: []byte("abc")}
+	data, err := cbor.Marshal(cbor.Tag{Number: common.ChunkTag, Content: chunk})
+	require.NoError(t, err)
+	assert.True(t, isChunk(data))
+	decoded, err := decodeChunk(data)
+	require.NoError(t, err)
+	assert.Equal(t, chunk, decoded)
+
+	// whole payloads are CBOR maps
+	data, err = cbor.Marshal(map[string]int{"a": 1})
+	require.NoError(t, err)
+	assert.False(t, isChunk(data))
+
+	data, err = cbor.Marshal(cbor.Tag{Number: 1, Content: 0})
+	require.NoError(t, err)
+	_, err = decodeChunk(data)
+	assert.Error(t, err)
+}
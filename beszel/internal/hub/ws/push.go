@@ -0,0 +1,49 @@
+package ws
+
+import (
+	"beszel/internal/common"
+	"beszel/internal/entities/system"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// isPush reports whether a message is system data pushed by the agent. The
+// tag number fits in two bytes, so its header is 0xd9 followed by the number.
+func isPush(data []byte) bool {
+	return len(data) > 3 && data[0] == 0xd9 && uint64(data[1])<<8|uint64(data[2]) == common.PushTag
+}
+
+// decodePush decodes the system data of a push message
+func decodePush(data []byte) (*system.CombinedData, error) {
+	var tag cbor.RawTag
+	if err := cbor.Unmarshal(data, &tag); err != nil {
+		return nil, err
+	}
+	if tag.Number != common.PushTag {
+		return nil, fmt.Errorf("unexpected CBOR tag %d", tag.Number)
+	}
+	pushed := &system.CombinedData{}
+	err := cbor.Unmarshal(tag.Content, pushed)
+	return pushed, err
+}
+
+// receivePush passes pushed data to the system updater, replacing older data
+// that hasn't been read yet. Invalid data is dropped.
+func (ws *WsConn) receivePush(message []byte) {
+	data, err := decodePush(message)
+	if err != nil {
+		return
+	}
+	for {
+		select {
+		case ws.PushChan <- data:
+			return
+		default:
+		}
+		select {
+		case <-ws.PushChan:
+		default:
+		}
+	}
+}
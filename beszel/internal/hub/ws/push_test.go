@@ -0,0 +1,50 @@
+//go:build testing
+// +build testing
+
+package ws
+
+import (
+	"beszel/internal/common"
+	"beszel/internal/entities/system"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePush(t *testing.T) {
+	data := &system.CombinedData{Info: system.Info{Hostname: "host"}}
+	message, err := cbor.Marshal(cbor.Tag{Number: common.PushTag, Content: data})
+	require.NoError(t, err)
+	assert.True(t, isPush(message))
+	decoded, err := decodePush(message)
+	require.NoError(t, err)
+	assert.Equal(t, "host", decoded.Info.Hostname)
+
+	// chunks and whole payloads are not pushes
+	chunk, err := cbor.Marshal(cbor.Tag{Number: common.ChunkTag, Content: common.Chunk{}})
+	require.NoError(t, err)
+	assert.False(t, isPush(chunk))
+	payload, err := cbor.Marshal(data)
+	require.NoError(t, err)
+	assert.False(t, isPush(payload))
+}
+
+func TestWsConnReceivePush(t *testing.T) {
+	ws := NewWsConnection(nil)
+	push := func(hostname string) {
+		message, err := cbor.Marshal(cbor.Tag{Number: common.PushTag, Content: system.CombinedData{Info: system.Info{Hostname: hostname}}})
+		require.NoError(t, err)
+		ws.receivePush(message)
+	}
+
+	// data that hasn't been read is replaced by newer data
+	push("old")
+	push("new")
+	assert.Equal(t, "new", (<-ws.PushChan).Info.Hostname)
+
+	// invalid data is dropped
+	ws.receivePush([]byte{0xd9, 0xbc, 0x4a, 0xff})
+	assert.Empty(t, ws.PushChan)
+}
@@ -4,6 +4,7 @@ import (
 	"beszel/internal/common"
 	"beszel/internal/entities/system"
 	"errors"
+	"sync/atomic"
 	"time"
 	"weak"
 
@@ -26,6 +27,9 @@ type WsConn struct {
 	conn         *gws.Conn
 	responseChan chan *gws.Message
 	DownChan     chan struct{}
+	PushChan     chan *system.CombinedData     // latest data pushed by the agent
+	chunkSize    int                           // max message size requested from the agent
+	transfer     atomic.Pointer[chunkTransfer] // payload being received in chunks
 }
 
 // FingerprintRecord is fingerprints collection record data in the hub
@@ -54,6 +58,8 @@ func NewWsConnection(conn *gws.Conn) *WsConn {
 		conn:         conn,
 		responseChan: make(chan *gws.Message, 1),
 		DownChan:     make(chan struct{}, 1),
+		PushChan:     make(chan *system.CombinedData, 1),
+		chunkSize:    DefaultChunkSize,
 	}
 }
 
@@ -73,12 +79,27 @@ func (h *Handler) OnMessage(conn *gws.Conn, message *gws.Message) {
 		_ = conn.WriteClose(1000, nil)
 		return
 	}
+	if isPush(message.Data.Bytes()) {
+		wsConn.(*WsConn).receivePush(message.Data.Bytes())
+		message.Close()
+		return
+	}
+	responseChan := wsConn.(*WsConn).responseChan
 	select {
-	case wsConn.(*WsConn).responseChan <- message:
+	case responseChan <- message:
+		return
 	default:
-		// close if the connection is not expecting a response
-		wsConn.(*WsConn).Close(nil)
 	}
+	// chunks can arrive faster than they are read, so give the reader time to catch up
+	if isChunk(message.Data.Bytes()) {
+		select {
+		case responseChan <- message:
+			return
+		case <-time.After(chunkReadWait):
+		}
+	}
+	// close if the connection is not expecting a response
+	wsConn.(*WsConn).Close(nil)
 }
 
 // OnClose handles WebSocket connection closures and triggers system down status after delay.
@@ -125,20 +146,59 @@ func (ws *WsConn) sendMessage(data common.HubRequest[any]) error {
 }
 
 // RequestSystemData requests system metrics from the agent and unmarshals the response.
+// Payloads larger than the chunk size are received in chunks. An interrupted
+// chunked transfer is resumed from the last chunk received.
 func (ws *WsConn) RequestSystemData(data *system.CombinedData) error {
-	var message *gws.Message
+	if transfer := ws.resumableTransfer(); transfer != nil {
+		ws.sendMessage(common.HubRequest[any]{
+			Action: common.GetChunks,
+			Data:   common.ChunkRequest{Id: transfer.id, Index: transfer.next},
+		})
+	} else {
+		ws.requestData()
+	}
+
+	for {
+		var message *gws.Message
+		select {
+		case <-time.After(10 * time.Second):
+			ws.Close(nil)
+			return gws.ErrConnClosed
+		case message = <-ws.responseChan:
+		}
+		if !isChunk(message.Data.Bytes()) {
+			ws.transfer.Store(nil)
+			defer message.Close()
+			return cbor.Unmarshal(message.Data.Bytes(), data)
+		}
+		chunk, err := decodeChunk(message.Data.Bytes())
+		message.Close()
+		if err != nil {
+			ws.transfer.Store(nil)
+			return err
+		}
+		payload, err := ws.addChunk(chunk)
+		switch {
+		case errors.Is(err, errTransferExpired):
+			// the agent no longer has the payload, so request new data
+			ws.transfer.Store(nil)
+			ws.requestData()
+		case err != nil:
+			ws.transfer.Store(nil)
+			return err
+		case payload != nil:
+			ws.transfer.Store(nil)
+			return cbor.Unmarshal(payload, data)
+		}
+	}
+}
 
+// requestData sends a GetData request with the max message size.
+func (ws *WsConn) requestData() {
 	ws.sendMessage(common.HubRequest[any]{
 		Action: common.GetData,
+		Data:   common.DataRequest{ChunkSize: ws.chunkSize, Push: true},
 	})
-	select {
-	case <-time.After(10 * time.Second):
-		ws.Close(nil)
-		return gws.ErrConnClosed
-	case message = <-ws.responseChan:
-	}
-	defer message.Close()
-	return cbor.Unmarshal(message.Data.Bytes(), data)
 }
 
 // GetFingerprint authenticates with the agent using SSH signature and returns the agent's fingerprint.
@@ -0,0 +1,163 @@
+package records
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// packedStats is a bucket of stats samples stored in one row, with a column of
+// values for each JSON leaf of the samples. Values repeat across samples far
+// less than keys do, so this is much smaller than a row per sample.
+type packedStats struct {
+	Times   []int64        `json:"t"` // unix ms of each sample
+	Columns []packedColumn `json:"c"`
+}
+
+// packedColumn holds the values of one JSON leaf for each sample
+type packedColumn struct {
+	Path   []any `json:"k"` // object keys (string) and array indexes (number)
+	Values []any `json:"v"` // value for each sample, nil if missing
+}
+
+// packSamples encodes JSON trees (as decoded into any) into columns.
+func packSamples(times []time.Time, samples []any) packedStats {
+	packed := packedStats{Times: make([]int64, len(times))}
+	columns := make(map[string]*packedColumn)
+	for i, sample := range samples {
+		packed.Times[i] = times[i].UnixMilli()
+		walkLeaves(sample, nil, func(path []any, value any) {
+			key := pathKey(path)
+			column, ok := columns[key]
+			if !ok {
+				column = &packedColumn{Path: slices.Clone(path), Values: make([]any, len(samples))}
+				columns[key] = column
+			}
+			column.Values[i] = value
+		})
+	}
+	keys := make([]string, 0, len(columns))
+	for key := range columns {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		packed.Columns = append(packed.Columns, *columns[key])
+	}
+	return packed
+}
+
+// samples decodes the packed columns into a JSON tree for each sample.
+func (p *packedStats) samples() ([]time.Time, []any) {
+	times := make([]time.Time, len(p.Times))
+	samples := make([]any, len(p.Times))
+	for i, ms := range p.Times {
+		times[i] = time.UnixMilli(ms).UTC()
+		sample := any(map[string]any{})
+		for _, column := range p.Columns {
+			if i < len(column.Values) && column.Values[i] != nil {
+				sample = setPath(sample, column.Path, column.Values[i])
+			}
+		}
+		samples[i] = sample
+	}
+	return times, samples
+}
+
+// toTree converts a value to its JSON tree.
+func toTree(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var tree any
+	err = json.Unmarshal(data, &tree)
+	return tree, err
+}
+
+// fromTree converts a JSON tree to a value.
+func fromTree(tree any, value any) error {
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// walkLeaves calls fn with the path and value of each leaf of a JSON tree.
+func walkLeaves(node any, path []any, fn func(path []any, value any)) {
+	switch node := node.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(node))
+		for key := range node {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			walkLeaves(node[key], append(path, key), fn)
+		}
+	case []any:
+		for i, value := range node {
+			walkLeaves(value, append(path, i), fn)
+		}
+	case nil:
+	default:
+		fn(path, node)
+	}
+}
+
+// setPath sets a leaf of a JSON tree, creating objects and arrays as needed.
+func setPath(node any, path []any, value any) any {
+	if len(path) == 0 {
+		return value
+	}
+	if i, ok := pathIndex(path[0]); ok {
+		array, _ := node.([]any)
+		for len(array) <= i {
+			array = append(array, nil)
+		}
+		array[i] = setPath(array[i], path[1:], value)
+		return array
+	}
+	object, _ := node.(map[string]any)
+	if object == nil {
+		object = make(map[string]any)
+	}
+	key, _ := path[0].(string)
+	object[key] = setPath(object[key], path[1:], value)
+	return object
+}
+
+// pathIndex returns the array index of a path element. Indexes are ints when
+// packing and float64 after decoding.
+func pathIndex(element any) (int, bool) {
+	switch i := element.(type) {
+	case int:
+		return i, true
+	case float64:
+		return int(i), true
+	}
+	return 0, false
+}
+
+// pathKey returns a unique string for a path.
+func pathKey(path []any) string {
+	var key []byte
+	for _, element := range path {
+		if i, ok := pathIndex(element); ok {
+			key = strconv.AppendInt(append(key, '#'), int64(i), 10)
+		} else {
+			key = strconv.AppendQuote(append(key, '.'), element.(string))
+		}
+	}
+	return string(key)
+}
+
+// sortByTime sorts samples by time, keeping the order of equal times.
+func sortByTime[T any](samples []T, created func(T) time.Time) {
+	slices.SortStableFunc(samples, func(a, b T) int {
+		return cmp.Compare(created(a).UnixNano(), created(b).UnixNano())
+	})
+}
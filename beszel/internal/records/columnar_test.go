@@ -0,0 +1,42 @@
+//go:build testing
+// +build testing
+
+package records
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackSamples(t *testing.T) {
+	times := []time.Time{
+		time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 1, 12, 1, 0, 0, time.UTC),
+	}
+	var samples []any
+	for _, sample := range []string{
+		`{"cpu": 1.5, "dio": [1, 2], "t": {"cpu": 40}}`,
+		`{"cpu": 2, "dio": [3], "t": {"gpu": 50}, "name": "x"}`,
+	} {
+		var tree any
+		require.NoError(t, json.Unmarshal([]byte(sample), &tree))
+		samples = append(samples, tree)
+	}
+
+	packed := packSamples(times, samples)
+	assert.Len(t, packed.Columns, 6, "one column per leaf")
+
+	// round trip through JSON, as stored in the record
+	data, err := json.Marshal(packed)
+	require.NoError(t, err)
+	var decoded packedStats
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	gotTimes, gotSamples := decoded.samples()
+	assert.Equal(t, times, gotTimes)
+	assert.Equal(t, samples, gotSamples)
+}
@@ -0,0 +1,310 @@
+package records
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/hub/storage"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Records of a type kept longer than its UI window are packed into packed_stats
+// records once they leave the window. Each packed record holds the samples of
+// one system, collection and type for a period as long as the window, so an
+// hour of 1m system_stats takes one row instead of sixty.
+
+// SensorsRetention is the retention key of daily sensor_rollups records
+const SensorsRetention = "sensors"
+
+// ParseRetention parses how long records of each type are kept, e.g.
+// "1m=7d,10m=30d,120m=1y,sensors=2y". Durations accept d, w and y for days,
+// weeks and years in addition to the units of time.ParseDuration.
+func ParseRetention(value string) (map[string]time.Duration, error) {
+	retention := make(map[string]time.Duration)
+	for entry := range strings.SplitSeq(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		recordType, durationStr, ok := strings.Cut(entry, "=")
+		recordType = strings.TrimSpace(recordType)
+		if !ok || (statsWindow(recordType) == 0 && recordType != SensorsRetention) {
+			return nil, fmt.Errorf("invalid stats retention %q", entry)
+		}
+		duration, err := parseDays(strings.TrimSpace(durationStr))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid stats retention %q", entry)
+		}
+		retention[recordType] = duration
+	}
+	return retention, nil
+}
+
+// Days of each unit accepted by parseDays
+var dayUnits = []struct {
+	suffix string
+	days   int
+}{{"d", 1}, {"w", 7}, {"y", 365}}
+
+// parseDays parses a duration in days ("30d"), weeks ("4w"), years ("1y") or a
+// time.Duration string.
+func parseDays(value string) (time.Duration, error) {
+	for _, unit := range dayUnits {
+		if n, ok := strings.CutSuffix(value, unit.suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil {
+				return 0, errors.New("invalid days")
+			}
+			return time.Duration(count*unit.days) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// statsWindow returns the UI window of a record type, or 0 if the type is unknown
+func statsWindow(recordType string) time.Duration {
+	for _, sw := range statsWindows {
+		if sw.recordType == recordType {
+			return sw.window
+		}
+	}
+	return 0
+}
+
+// SetRetention sets how long records of each type are kept. Types without a
+// retention, or with one shorter than their UI window, are kept for the window.
+// Sensor rollups are kept for the SensorsRetention key, or the longest window.
+func (rm *RecordManager) SetRetention(retention map[string]time.Duration) {
+	rm.retention = maps.Clone(retention)
+}
+
+// StatsRetention returns how long stats records of a type are kept
+func (rm *RecordManager) StatsRetention(recordType string) time.Duration {
+	return Retention(rm.retention, recordType)
+}
+
+// SensorRollupRetention returns how long daily sensor rollups are kept
+func (rm *RecordManager) SensorRollupRetention() time.Duration {
+	return max(rm.retention[SensorsRetention], statsWindows[len(statsWindows)-1].window)
+}
+
+// packOldStats moves records leaving their UI window into packed_stats if their
+// type is kept longer than the window. Must run before deleteOldSystemStats
+// with the same time, which then deletes the packed records.
+func (rm *RecordManager) packOldStats(txApp core.App, now time.Time) error {
+	for _, sw := range statsWindows {
+		if rm.retention[sw.recordType] <= sw.window {
+			continue
+		}
+		for _, collection := range []string{"system_stats", "container_stats"} {
+			var rows []statsRow
+			// same condition as deleteOldSystemStats
+			err := txApp.DB().
+				Select("system", "type", "created", "stats").
+				From(collection).
+				Where(dbx.NewExp("type = {:type} AND created < {:created}", dbx.Params{"type": sw.recordType, "created": now.Add(-sw.window)})).
+				OrderBy("system", "created").
+				All(&rows)
+			if err != nil {
+				return err
+			}
+			for len(rows) > 0 {
+				// rows of the same system and bucket
+				start := rows[0].Created.Time().Truncate(sw.window)
+				n := 1
+				for n < len(rows) && rows[n].System == rows[0].System && rows[n].Created.Time().Truncate(sw.window).Equal(start) {
+					n++
+				}
+				if err := rm.addPackedStats(txApp, collection, start, sw.window, rows[:n]); err != nil {
+					return err
+				}
+				rows = rows[n:]
+			}
+		}
+	}
+	return nil
+}
+
+// addPackedStats adds rows of one system to the packed_stats bucket starting at start.
+func (rm *RecordManager) addPackedStats(txApp core.App, collection string, start time.Time, window time.Duration, rows []statsRow) error {
+	var samples []packedSample
+	record, err := txApp.FindFirstRecordByFilter("packed_stats",
+		"system = {:system} && collection = {:collection} && type = {:type} && start_time = {:start}",
+		dbx.Params{"system": rows[0].System, "collection": collection, "type": rows[0].Type, "start": start.UTC().Format(types.DefaultDateLayout)})
+	if err == nil {
+		var packed packedStats
+		if err := record.UnmarshalJSONField("data", &packed); err == nil {
+			samples = packed.unpack(rows[0].System)
+		}
+	} else {
+		packedCollection, err := txApp.FindCachedCollectionByNameOrId("packed_stats")
+		if err != nil {
+			return err
+		}
+		record = core.NewRecord(packedCollection)
+		record.Set("system", rows[0].System)
+		record.Set("collection", collection)
+		record.Set("type", rows[0].Type)
+		record.Set("start_time", start)
+		record.Set("end_time", start.Add(window))
+	}
+	for _, row := range rows {
+		if tree, err := packableStats(collection, row.Stats); err == nil {
+			samples = append(samples, packedSample{created: row.Created.Time(), tree: tree})
+		}
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	sortByTime(samples, func(s packedSample) time.Time { return s.created })
+	times := make([]time.Time, len(samples))
+	trees := make([]any, len(samples))
+	for i, sample := range samples {
+		times[i], trees[i] = sample.created, sample.tree
+	}
+	record.Set("count", len(samples))
+	record.Set("data", packSamples(times, trees))
+	return txApp.SaveNoValidate(record)
+}
+
+// packedSample is a sample of a packed_stats record
+type packedSample struct {
+	system  string
+	created time.Time
+	tree    any
+}
+
+// unpack returns the samples of a packed_stats record of a system.
+func (p *packedStats) unpack(system string) []packedSample {
+	times, trees := p.samples()
+	samples := make([]packedSample, len(times))
+	for i := range times {
+		samples[i] = packedSample{system: system, created: times[i], tree: trees[i]}
+	}
+	return samples
+}
+
+// packableStats returns the JSON tree of stats to pack. Containers are keyed by
+// name so each container metric gets its own column.
+func packableStats(collection string, data []byte) (any, error) {
+	if collection != "container_stats" {
+		var tree any
+		err := json.Unmarshal(data, &tree)
+		return tree, err
+	}
+	var containers []container.Stats
+	if err := json.Unmarshal(data, &containers); err != nil {
+		return nil, err
+	}
+	byName := make(map[string]container.Stats, len(containers))
+	for _, c := range containers {
+		name := c.Name
+		c.Name = ""
+		byName[name] = c
+	}
+	return toTree(byName)
+}
+
+// deleteOldPackedStats deletes packed_stats records past their retention.
+func (rm *RecordManager) deleteOldPackedStats(txApp core.App, now time.Time) error {
+	for _, sw := range statsWindows {
+		cutoff := now.Add(-max(rm.retention[sw.recordType], sw.window))
+		_, err := txApp.DB().
+			Delete("packed_stats", dbx.NewExp("type = {:type} AND end_time < {:cutoff}",
+				dbx.Params{"type": sw.recordType, "cutoff": cutoff.Format(types.DefaultDateLayout)})).
+			Execute()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packedSamples returns the packed samples of a collection matching the query.
+func (rm *RecordManager) packedSamples(collection string, query storage.Query) ([]packedSample, error) {
+	q := rm.app.DB().
+		Select("system", "data").
+		From("packed_stats").
+		Where(dbx.HashExp{"collection": collection, "type": cmp.Or(query.Type, storage.Type1m)}).
+		OrderBy("start_time")
+	if query.System != "" {
+		q.AndWhere(dbx.HashExp{"system": query.System})
+	}
+	if !query.Since.IsZero() {
+		q.AndWhere(dbx.NewExp("end_time > {:since}", dbx.Params{"since": query.Since.UTC().Format(types.DefaultDateLayout)}))
+	}
+	if !query.Until.IsZero() {
+		q.AndWhere(dbx.NewExp("start_time <= {:until}", dbx.Params{"until": query.Until.UTC().Format(types.DefaultDateLayout)}))
+	}
+	var rows []struct {
+		System string `db:"system"`
+		Data   []byte `db:"data"`
+	}
+	if err := q.All(&rows); err != nil {
+		return nil, err
+	}
+	// rows have millisecond precision, so compare packed samples the same way
+	since, until := query.Since.Truncate(time.Millisecond), query.Until.Truncate(time.Millisecond)
+	var samples []packedSample
+	for _, row := range rows {
+		var packed packedStats
+		if err := json.Unmarshal(row.Data, &packed); err != nil {
+			continue
+		}
+		for _, sample := range packed.unpack(row.System) {
+			if (!query.Since.IsZero() && !sample.created.After(since)) || (!query.Until.IsZero() && sample.created.After(until)) {
+				continue
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// packedSystemStats returns the packed system_stats matching the query.
+func (rm *RecordManager) packedSystemStats(query storage.Query) ([]storage.SystemStats, error) {
+	samples, err := rm.packedSamples("system_stats", query)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]storage.SystemStats, 0, len(samples))
+	for _, sample := range samples {
+		record := storage.SystemStats{System: sample.system, Type: cmp.Or(query.Type, storage.Type1m), Created: sample.created}
+		if err := fromTree(sample.tree, &record.Stats); err == nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// packedContainerStats returns the packed container_stats matching the query.
+func (rm *RecordManager) packedContainerStats(query storage.Query) ([]storage.ContainerStats, error) {
+	samples, err := rm.packedSamples("container_stats", query)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]storage.ContainerStats, 0, len(samples))
+	for _, sample := range samples {
+		var byName map[string]container.Stats
+		if err := fromTree(sample.tree, &byName); err != nil {
+			continue
+		}
+		record := storage.ContainerStats{System: sample.system, Type: cmp.Or(query.Type, storage.Type1m), Created: sample.created}
+		for _, name := range slices.Sorted(maps.Keys(byName)) {
+			stats := byName[name]
+			stats.Name = name
+			record.Stats = append(record.Stats, stats)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
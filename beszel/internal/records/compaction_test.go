@@ -0,0 +1,127 @@
+//go:build testing
+// +build testing
+
+package records_test
+
+import (
+	"beszel/internal/hub/storage"
+	"beszel/internal/records"
+	"beszel/internal/tests"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetention(t *testing.T) {
+	retention, err := records.ParseRetention(" 1m=7d, 10m = 2160h ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"1m": 7 * 24 * time.Hour, "10m": 90 * 24 * time.Hour}, retention)
+
+	retention, err = records.ParseRetention("10m=4w,120m=1y,sensors=2y")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"10m":                    28 * 24 * time.Hour,
+		"120m":                   365 * 24 * time.Hour,
+		records.SensorsRetention: 730 * 24 * time.Hour,
+	}, retention)
+
+	for _, value := range []string{"5m=7d", "1m", "1m=abc", "1m=-1d", "1m=0", "1m=1.5y", "sensor=1y"} {
+		_, err := records.ParseRetention(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestRecordManagerPackedStats(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := tests.CreateUser(hub, "test@example.com", "testtesttest")
+	require.NoError(t, err)
+	system, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "test-system",
+		"host":  "localhost",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	addStats := func(age time.Duration, cpu int) {
+		for _, collection := range []string{"system_stats", "container_stats"} {
+			stats := fmt.Sprintf(`{"cpu": %d, "m": 8, "lat": [1, 2]}`, cpu)
+			if collection == "container_stats" {
+				stats = fmt.Sprintf(`[{"n": "web", "c": %d}, {"n": "db", "c": 1}]`, cpu)
+			}
+			record, err := tests.CreateRecord(hub, collection, map[string]any{
+				"system": system.Id,
+				"type":   "1m",
+				"stats":  stats,
+			})
+			require.NoError(t, err)
+			record.SetRaw("created", now.Add(-age).Format(types.DefaultDateLayout))
+			require.NoError(t, hub.SaveNoValidate(record))
+		}
+	}
+	addStats(10*24*time.Hour, 1) // past retention
+	addStats(3*time.Hour, 2)
+	addStats(150*time.Minute, 3)
+	addStats(2*time.Hour, 4)
+	addStats(10*time.Minute, 5) // within the UI window
+
+	rm := records.NewRecordManager(hub)
+	rm.SetRetention(map[string]time.Duration{"1m": 7 * 24 * time.Hour})
+	require.NoError(t, rm.DeleteOld())
+
+	count, err := hub.CountRecords("system_stats")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count, "packed records are deleted")
+
+	systemStats, err := rm.SystemStats(storage.Query{System: system.Id})
+	require.NoError(t, err)
+	require.Len(t, systemStats, 4)
+	for i, cpu := range []float64{2, 3, 4, 5} {
+		assert.Equal(t, cpu, systemStats[i].Stats.Cpu)
+		assert.Equal(t, system.Id, systemStats[i].System)
+	}
+	assert.Equal(t, 8.0, systemStats[0].Stats.Mem)
+	assert.Equal(t, [2]float64{1, 2}, systemStats[0].Stats.Latency)
+	assert.Equal(t, now.Add(-3*time.Hour), systemStats[0].Created)
+
+	containerStats, err := rm.ContainerStats(storage.Query{System: system.Id, Since: now.Add(-160 * time.Minute), Until: now.Add(-2 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, containerStats, 2)
+	require.Len(t, containerStats[0].Stats, 2)
+	assert.Equal(t, "db", containerStats[0].Stats[0].Name)
+	assert.Equal(t, "web", containerStats[0].Stats[1].Name)
+	assert.Equal(t, 3.0, containerStats[0].Stats[1].Cpu)
+	assert.Equal(t, 4.0, containerStats[1].Stats[1].Cpu)
+
+	// records packed later are merged into existing buckets
+	addStats(3*time.Hour-time.Minute, 6)
+	require.NoError(t, rm.DeleteOld())
+	systemStats, err = rm.SystemStats(storage.Query{System: system.Id})
+	require.NoError(t, err)
+	require.Len(t, systemStats, 5)
+	assert.Equal(t, 6.0, systemStats[1].Stats.Cpu)
+	buckets, err := hub.FindAllRecords("packed_stats")
+	require.NoError(t, err)
+	for _, bucket := range buckets {
+		assert.Less(t, bucket.GetDateTime("end_time").Time().Sub(bucket.GetDateTime("start_time").Time()), time.Hour+time.Second)
+	}
+	total := 0
+	for _, bucket := range buckets {
+		total += bucket.GetInt("count")
+	}
+	assert.Equal(t, 8, total, "4 samples of each collection")
+
+	// without extended retention, packed records are deleted with the window
+	rm.SetRetention(nil)
+	require.NoError(t, rm.DeleteOld())
+	count, err = hub.CountRecords("packed_stats")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
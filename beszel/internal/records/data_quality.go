@@ -0,0 +1,116 @@
+package records
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Expected time between 1m system_stats records
+const statsInterval = time.Minute
+
+// DataQualityReport lists collection problems found in a system's 1m records
+type DataQualityReport struct {
+	System     string         `json:"system"`
+	Name       string         `json:"name"`
+	Records    int            `json:"records"`
+	Gaps       []DataGap      `json:"gaps"`
+	Duplicates int            `json:"duplicates"` // records less than half an interval after the previous one
+	Invalid    []InvalidValue `json:"invalid"`
+}
+
+// DataGap is a period without records
+type DataGap struct {
+	Start   time.Time `json:"start"` // time of the last record before the gap
+	End     time.Time `json:"end"`   // time of the first record after the gap, or the end of the check
+	Missing int       `json:"missing"`
+}
+
+// InvalidValue is an impossible value in a record
+type InvalidValue struct {
+	Time  time.Time `json:"time"`
+	Field string    `json:"field"`
+	Value float64   `json:"value"`
+}
+
+// OK reports whether no problems were found
+func (r *DataQualityReport) OK() bool {
+	return len(r.Gaps) == 0 && r.Duplicates == 0 && len(r.Invalid) == 0
+}
+
+// CheckDataQuality scans the 1m stats records created between since and
+// until for gaps, duplicate timestamps and impossible values. Systems that are
+// not up are skipped, as gaps are expected while a system is down.
+func CheckDataQuality(app core.App, driver storage.Driver, since, until time.Time) ([]DataQualityReport, error) {
+	var systems []struct {
+		Id   string `db:"id"`
+		Name string `db:"name"`
+	}
+	if err := app.DB().NewQuery("SELECT id, name FROM systems WHERE status='up' ORDER BY name").All(&systems); err != nil {
+		return nil, err
+	}
+
+	reports := make([]DataQualityReport, 0, len(systems))
+	for _, system := range systems {
+		records, err := driver.SystemStats(storage.Query{System: system.Id, Type: storage.Type1m, Since: since, Until: until})
+		if err != nil {
+			return nil, err
+		}
+
+		report := DataQualityReport{
+			System:  system.Id,
+			Name:    system.Name,
+			Records: len(records),
+			Gaps:    []DataGap{},
+			Invalid: []InvalidValue{},
+		}
+		for i := range records {
+			created := records[i].Created
+			if i > 0 {
+				if elapsed := created.Sub(records[i-1].Created); elapsed < statsInterval/2 {
+					report.Duplicates++
+				} else if gap, ok := newDataGap(records[i-1].Created, created); ok {
+					report.Gaps = append(report.Gaps, gap)
+				}
+			}
+			report.Invalid = append(report.Invalid, findInvalidValues(created, &records[i].Stats)...)
+		}
+		// records stopped while the system is up, allowing for the next record to be late
+		if len(records) > 0 {
+			last := records[len(records)-1].Created
+			if gap, ok := newDataGap(last, until.Add(-statsInterval)); ok {
+				gap.End = until
+				report.Gaps = append(report.Gaps, gap)
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// newDataGap returns the gap between two record times, if at least one record is missing.
+func newDataGap(start, end time.Time) (DataGap, bool) {
+	missing := int((end.Sub(start)+statsInterval/2)/statsInterval) - 1
+	return DataGap{Start: start, End: end, Missing: missing}, missing > 0
+}
+
+// findInvalidValues returns usage values that are negative or above 100 percent.
+func findInvalidValues(created time.Time, stats *system.Stats) []InvalidValue {
+	var invalid []InvalidValue
+	check := func(field string, value, maximum float64) {
+		if value < 0 || (maximum > 0 && value > maximum) {
+			invalid = append(invalid, InvalidValue{Time: created, Field: field, Value: value})
+		}
+	}
+	check("cpu", stats.Cpu, 100)
+	check("mp", stats.MemPct, 100)
+	check("dp", stats.DiskPct, 100)
+	check("mu", stats.MemUsed, 0)
+	check("du", stats.DiskUsed, 0)
+	check("su", stats.SwapUsed, 0)
+	check("ns", stats.NetworkSent, 0)
+	check("nr", stats.NetworkRecv, 0)
+	return invalid
+}
@@ -16,7 +16,8 @@ import (
 )
 
 type RecordManager struct {
-	app core.App
+	app       core.App
+	retention map[string]time.Duration // by record type, if longer than the UI window
 }
 
 type LongerRecordData struct {
@@ -31,7 +32,7 @@ type RecordIds []struct {
 }
 
 func NewRecordManager(app core.App) *RecordManager {
-	return &RecordManager{app}
+	return &RecordManager{app: app}
 }
 
 type StatsRecord struct {
@@ -46,38 +47,64 @@ var tempStats system.Stats
 var queryParams = make(dbx.Params, 1)
 var containerSums = make(map[string]*container.Stats)
 
-// Create longer records by averaging shorter records
-func (rm *RecordManager) CreateLongerRecords() {
-	// start := time.Now()
-	longerRecordData := []LongerRecordData{
-		{
-			shorterType: "1m",
-			// change to 9 from 10 to allow edge case timing or short pauses
-			minShorterRecords:  9,
-			longerType:         "10m",
-			longerTimeDuration: -10 * time.Minute,
-		},
-		{
-			shorterType:        "10m",
-			minShorterRecords:  2,
-			longerType:         "20m",
-			longerTimeDuration: -20 * time.Minute,
-		},
-		{
-			shorterType:        "20m",
-			minShorterRecords:  6,
-			longerType:         "120m",
-			longerTimeDuration: -120 * time.Minute,
-		},
-		{
-			shorterType:        "120m",
-			minShorterRecords:  4,
-			longerType:         "480m",
-			longerTimeDuration: -480 * time.Minute,
-		},
+// longerRecordData are the longer record types, in the order they're created
+var longerRecordData = []LongerRecordData{
+	{
+		shorterType: "1m",
+		// change to 9 from 10 to allow edge case timing or short pauses
+		minShorterRecords:  9,
+		longerType:         "10m",
+		longerTimeDuration: -10 * time.Minute,
+	},
+	{
+		shorterType:        "10m",
+		minShorterRecords:  2,
+		longerType:         "20m",
+		longerTimeDuration: -20 * time.Minute,
+	},
+	{
+		shorterType:        "20m",
+		minShorterRecords:  6,
+		longerType:         "120m",
+		longerTimeDuration: -120 * time.Minute,
+	},
+	{
+		shorterType:        "120m",
+		minShorterRecords:  4,
+		longerType:         "480m",
+		longerTimeDuration: -480 * time.Minute,
+	},
+}
+
+// DownsampleStep is a longer record type and the shorter records averaged to create it
+type DownsampleStep struct {
+	ShorterType string
+	LongerType  string
+	Interval    time.Duration // interval of the longer records
+	MinRecords  int           // min number of shorter records in the interval
+}
+
+// DownsampleSteps returns the longer record types in the order they're created,
+// for storage drivers that keep records outside PocketBase.
+func DownsampleSteps() []DownsampleStep {
+	steps := make([]DownsampleStep, len(longerRecordData))
+	for i, data := range longerRecordData {
+		steps[i] = DownsampleStep{
+			ShorterType: data.shorterType,
+			LongerType:  data.longerType,
+			Interval:    -data.longerTimeDuration,
+			MinRecords:  data.minShorterRecords,
+		}
 	}
+	return steps
+}
+
+// Downsample creates longer records by averaging shorter records
+func (rm *RecordManager) Downsample() error {
+	// start := time.Now()
+
 	// wrap the operations in a transaction
-	rm.app.RunInTransaction(func(txApp core.App) error {
+	err := rm.app.RunInTransaction(func(txApp core.App) error {
 		var err error
 		collections := [2]*core.Collection{}
 		collections[0], err = txApp.FindCachedCollectionByNameOrId("system_stats")
@@ -163,28 +190,45 @@ func (rm *RecordManager) CreateLongerRecords() {
 	statsRecord.Stats = statsRecord.Stats[:0]
 
 	// log.Println("finished creating longer records", "time (ms)", time.Since(start).Milliseconds())
+	return err
 }
 
 // Calculate the average stats of a list of system_stats records without reflect
 func (rm *RecordManager) AverageSystemStats(db dbx.Builder, records RecordIds) *system.Stats {
+	return averageSystemStats(len(records), func(i int) []byte {
+		statsRecord.Stats = statsRecord.Stats[:0]
+		queryParams["id"] = records[i].Id
+		db.NewQuery("SELECT stats FROM system_stats WHERE id = {:id}").Bind(queryParams).One(&statsRecord)
+		return statsRecord.Stats
+	})
+}
+
+// AverageSystemStatsJSON returns the average of system stats encoded as JSON,
+// for storage drivers that keep records outside PocketBase.
+func AverageSystemStatsJSON(records [][]byte) *system.Stats {
+	return averageSystemStats(len(records), func(i int) []byte { return records[i] })
+}
+
+// averageSystemStats averages n system stats, loading the JSON of each with load
+func averageSystemStats(n int, load func(i int) []byte) *system.Stats {
 	// Clear/reset global structs for reuse
 	sumStats = system.Stats{}
 	tempStats = system.Stats{}
 	sum := &sumStats
 	stats := &tempStats
 
-	count := float64(len(records))
+	count := float64(n)
 	tempCount := float64(0)
+	dockerDiskCount := float64(0)
+	cpuCoresCount := float64(0)
+	cpuFreqCount := float64(0)
+	pressureCount := float64(0)
 
 	// Accumulate totals
-	for _, record := range records {
-		id := record.Id
-		// clear global statsRecord for reuse
-		statsRecord.Stats = statsRecord.Stats[:0]
-
-		queryParams["id"] = id
-		db.NewQuery("SELECT stats FROM system_stats WHERE id = {:id}").Bind(queryParams).One(&statsRecord)
-		if err := json.Unmarshal(statsRecord.Stats, stats); err != nil {
+	for i := range n {
+		// clear global stats for reuse (json doesn't reset omitted fields)
+		*stats = system.Stats{}
+		if err := json.Unmarshal(load(i), stats); err != nil {
 			continue
 		}
 
@@ -194,6 +238,11 @@ func (rm *RecordManager) AverageSystemStats(db dbx.Builder, records RecordIds) *
 		sum.MemPct += stats.MemPct
 		sum.MemBuffCache += stats.MemBuffCache
 		sum.MemZfsArc += stats.MemZfsArc
+		sum.MemCached += stats.MemCached
+		sum.MemBuffers += stats.MemBuffers
+		sum.MemAvailable += stats.MemAvailable
+		sum.MemHugePages += stats.MemHugePages
+		sum.MemHugePagesUsed += stats.MemHugePagesUsed
 		sum.Swap += stats.Swap
 		sum.SwapUsed += stats.SwapUsed
 		sum.DiskTotal += stats.DiskTotal
@@ -208,6 +257,18 @@ func (rm *RecordManager) AverageSystemStats(db dbx.Builder, records RecordIds) *
 		sum.LoadAvg[2] += stats.LoadAvg[2]
 		sum.Bandwidth[0] += stats.Bandwidth[0]
 		sum.Bandwidth[1] += stats.Bandwidth[1]
+		sum.SwapIo[0] += stats.SwapIo[0]
+		sum.SwapIo[1] += stats.SwapIo[1]
+		sum.Latency[0] += stats.Latency[0]
+		sum.Latency[1] += stats.Latency[1]
+		sum.RaidMissing += stats.RaidMissing
+		sum.SmartFailing += stats.SmartFailing
+		sum.ServicesFailed += stats.ServicesFailed
+		sum.ChecksFailing += stats.ChecksFailing
+		sum.SensorsAlerting += stats.SensorsAlerting
+		sum.SensorsStale += stats.SensorsStale
+		sum.TempRise += stats.TempRise
+		sum.CpuThrottled += stats.CpuThrottled
 		// Set peak values
 		sum.MaxCpu = max(sum.MaxCpu, stats.MaxCpu, stats.Cpu)
 		sum.MaxNetworkSent = max(sum.MaxNetworkSent, stats.MaxNetworkSent, stats.NetworkSent)
@@ -228,6 +289,59 @@ func (rm *RecordManager) AverageSystemStats(db dbx.Builder, records RecordIds) *
 			}
 		}
 
+		// Accumulate network interface bandwidth
+		for name, bandwidth := range stats.NetworkInterfaces {
+			if sum.NetworkInterfaces == nil {
+				sum.NetworkInterfaces = make(map[string][2]uint64, len(stats.NetworkInterfaces))
+			}
+			total := sum.NetworkInterfaces[name]
+			sum.NetworkInterfaces[name] = [2]uint64{total[0] + bandwidth[0], total[1] + bandwidth[1]}
+		}
+
+		// Accumulate per-core usage and frequency
+		if len(stats.CpuCores) > 0 {
+			cpuCoresCount++
+			sum.CpuCores = addSlices(sum.CpuCores, stats.CpuCores)
+		}
+		if len(stats.CpuFreq) > 0 {
+			cpuFreqCount++
+			sum.CpuFreq = addSlices(sum.CpuFreq, stats.CpuFreq)
+		}
+
+		// Accumulate pressure stall information
+		if p := stats.Pressure; p != nil {
+			if sum.Pressure == nil {
+				sum.Pressure = &system.Pressure{}
+			}
+			pressureCount++
+			for i := range 2 {
+				sum.Pressure.Cpu[i] += p.Cpu[i]
+				sum.Pressure.Memory[i] += p.Memory[i]
+				sum.Pressure.Io[i] += p.Io[i]
+			}
+		}
+
+		// Accumulate Docker disk usage
+		if disk := stats.DockerDisk; disk != nil {
+			if sum.DockerDisk == nil {
+				sum.DockerDisk = &system.DockerDiskUsage{}
+			}
+			dockerDiskCount++
+			sum.DockerDisk.Images += disk.Images
+			sum.DockerDisk.Containers += disk.Containers
+			sum.DockerDisk.Volumes += disk.Volumes
+			sum.DockerDisk.BuildCache += disk.BuildCache
+			sum.DockerDisk.Reclaimable += disk.Reclaimable
+		}
+
+		// Keep the most recent sensor categories
+		for key, category := range stats.SensorCategories {
+			if sum.SensorCategories == nil {
+				sum.SensorCategories = make(map[string]string, len(stats.SensorCategories))
+			}
+			sum.SensorCategories[key] = category
+		}
+
 		// Accumulate extra filesystem stats
 		if stats.ExtraFs != nil {
 			if sum.ExtraFs == nil {
@@ -276,6 +390,11 @@ func (rm *RecordManager) AverageSystemStats(db dbx.Builder, records RecordIds) *
 		sum.MemPct = twoDecimals(sum.MemPct / count)
 		sum.MemBuffCache = twoDecimals(sum.MemBuffCache / count)
 		sum.MemZfsArc = twoDecimals(sum.MemZfsArc / count)
+		sum.MemCached = twoDecimals(sum.MemCached / count)
+		sum.MemBuffers = twoDecimals(sum.MemBuffers / count)
+		sum.MemAvailable = twoDecimals(sum.MemAvailable / count)
+		sum.MemHugePages = twoDecimals(sum.MemHugePages / count)
+		sum.MemHugePagesUsed = twoDecimals(sum.MemHugePagesUsed / count)
 		sum.Swap = twoDecimals(sum.Swap / count)
 		sum.SwapUsed = twoDecimals(sum.SwapUsed / count)
 		sum.DiskTotal = twoDecimals(sum.DiskTotal / count)
@@ -290,6 +409,18 @@ func (rm *RecordManager) AverageSystemStats(db dbx.Builder, records RecordIds) *
 		sum.LoadAvg[2] = twoDecimals(sum.LoadAvg[2] / count)
 		sum.Bandwidth[0] = sum.Bandwidth[0] / uint64(count)
 		sum.Bandwidth[1] = sum.Bandwidth[1] / uint64(count)
+		sum.SwapIo[0] = sum.SwapIo[0] / uint64(count)
+		sum.SwapIo[1] = sum.SwapIo[1] / uint64(count)
+		sum.Latency[0] = twoDecimals(sum.Latency[0] / count)
+		sum.Latency[1] = twoDecimals(sum.Latency[1] / count)
+		sum.RaidMissing = twoDecimals(sum.RaidMissing / count)
+		sum.SmartFailing = twoDecimals(sum.SmartFailing / count)
+		sum.ServicesFailed = twoDecimals(sum.ServicesFailed / count)
+		sum.ChecksFailing = twoDecimals(sum.ChecksFailing / count)
+		sum.SensorsAlerting = twoDecimals(sum.SensorsAlerting / count)
+		sum.SensorsStale = twoDecimals(sum.SensorsStale / count)
+		sum.TempRise = twoDecimals(sum.TempRise / count)
+		sum.CpuThrottled = twoDecimals(sum.CpuThrottled / count)
 		// Average temperatures
 		if sum.Temperatures != nil && tempCount > 0 {
 			for key := range sum.Temperatures {
@@ -297,6 +428,37 @@ func (rm *RecordManager) AverageSystemStats(db dbx.Builder, records RecordIds) *
 			}
 		}
 
+		// Average network interface bandwidth, counting records without an interface as idle
+		for name, total := range sum.NetworkInterfaces {
+			sum.NetworkInterfaces[name] = [2]uint64{total[0] / uint64(count), total[1] / uint64(count)}
+		}
+
+		// Average per-core usage and frequency
+		for i := range sum.CpuCores {
+			sum.CpuCores[i] = twoDecimals(sum.CpuCores[i] / cpuCoresCount)
+		}
+		for i := range sum.CpuFreq {
+			sum.CpuFreq[i] = twoDecimals(sum.CpuFreq[i] / cpuFreqCount)
+		}
+
+		// Average pressure stall information
+		if p := sum.Pressure; p != nil && pressureCount > 0 {
+			for i := range 2 {
+				p.Cpu[i] = twoDecimals(p.Cpu[i] / pressureCount)
+				p.Memory[i] = twoDecimals(p.Memory[i] / pressureCount)
+				p.Io[i] = twoDecimals(p.Io[i] / pressureCount)
+			}
+		}
+
+		// Average Docker disk usage
+		if disk := sum.DockerDisk; disk != nil && dockerDiskCount > 0 {
+			disk.Images = twoDecimals(disk.Images / dockerDiskCount)
+			disk.Containers = twoDecimals(disk.Containers / dockerDiskCount)
+			disk.Volumes = twoDecimals(disk.Volumes / dockerDiskCount)
+			disk.BuildCache = twoDecimals(disk.BuildCache / dockerDiskCount)
+			disk.Reclaimable = twoDecimals(disk.Reclaimable / dockerDiskCount)
+		}
+
 		// Average extra filesystem stats
 		if sum.ExtraFs != nil {
 			for key := range sum.ExtraFs {
@@ -328,23 +490,35 @@ func (rm *RecordManager) AverageSystemStats(db dbx.Builder, records RecordIds) *
 
 // Calculate the average stats of a list of container_stats records
 func (rm *RecordManager) AverageContainerStats(db dbx.Builder, records RecordIds) []container.Stats {
+	return averageContainerStats(len(records), func(i int) []byte {
+		statsRecord.Stats = statsRecord.Stats[:0]
+		queryParams["id"] = records[i].Id
+		db.NewQuery("SELECT stats FROM container_stats WHERE id = {:id}").Bind(queryParams).One(&statsRecord)
+		return statsRecord.Stats
+	})
+}
+
+// AverageContainerStatsJSON returns the average of container stats encoded as
+// JSON, for storage drivers that keep records outside PocketBase.
+func AverageContainerStatsJSON(records [][]byte) []container.Stats {
+	return averageContainerStats(len(records), func(i int) []byte { return records[i] })
+}
+
+// averageContainerStats averages n container stats lists, loading the JSON of each with load
+func averageContainerStats(n int, load func(i int) []byte) []container.Stats {
 	// Clear global map for reuse
 	for k := range containerSums {
 		delete(containerSums, k)
 	}
 	sums := containerSums
-	count := float64(len(records))
+	count := float64(n)
 
-	for i := range records {
-		id := records[i].Id
-		// clear global statsRecord and containerStats for reuse
-		statsRecord.Stats = statsRecord.Stats[:0]
+	for i := range n {
+		// clear global containerStats for reuse (json doesn't reset omitted fields)
+		clear(containerStats[:cap(containerStats)])
 		containerStats = containerStats[:0]
 
-		queryParams["id"] = id
-		db.NewQuery("SELECT stats FROM container_stats WHERE id = {:id}").Bind(queryParams).One(&statsRecord)
-
-		if err := json.Unmarshal(statsRecord.Stats, &containerStats); err != nil {
+		if err := json.Unmarshal(load(i), &containerStats); err != nil {
 			return []container.Stats{}
 		}
 		for i := range containerStats {
@@ -356,6 +530,12 @@ func (rm *RecordManager) AverageContainerStats(db dbx.Builder, records RecordIds
 			sums[stat.Name].Mem += stat.Mem
 			sums[stat.Name].NetworkSent += stat.NetworkSent
 			sums[stat.Name].NetworkRecv += stat.NetworkRecv
+			sums[stat.Name].DiskRead += stat.DiskRead
+			sums[stat.Name].DiskWrite += stat.DiskWrite
+			sums[stat.Name].Restarts = max(sums[stat.Name].Restarts, stat.Restarts)
+			if stat.Project != "" {
+				sums[stat.Name].Project = stat.Project
+			}
 		}
 	}
 
@@ -367,15 +547,47 @@ func (rm *RecordManager) AverageContainerStats(db dbx.Builder, records RecordIds
 			Mem:         twoDecimals(value.Mem / count),
 			NetworkSent: twoDecimals(value.NetworkSent / count),
 			NetworkRecv: twoDecimals(value.NetworkRecv / count),
+			DiskRead:    twoDecimals(value.DiskRead / count),
+			DiskWrite:   twoDecimals(value.DiskWrite / count),
+			Restarts:    value.Restarts,
+			Project:     value.Project,
 		})
 	}
 	return result
 }
 
+// DeleteOld deletes system_stats and container_stats records past their retention
+func (rm *RecordManager) DeleteOld() error {
+	return rm.app.RunInTransaction(rm.deleteOldStats)
+}
+
+// deleteOldStats packs records that are no longer displayed in the UI if they
+// are kept longer, then deletes them and packed records past their retention.
+func (rm *RecordManager) deleteOldStats(txApp core.App) error {
+	now := time.Now().UTC()
+	if err := rm.packOldStats(txApp, now); err != nil {
+		return err
+	}
+	if err := deleteOldSystemStats(txApp, now); err != nil {
+		return err
+	}
+	return rm.deleteOldPackedStats(txApp, now)
+}
+
+// DeleteOldAlertsHistory deletes the oldest alerts_history records of users with too many
+func (rm *RecordManager) DeleteOldAlertsHistory() error {
+	return deleteOldAlertsHistory(rm.app, 200, 250)
+}
+
+// DeleteOldSystemEvents deletes system_events records older than the longest chart window
+func (rm *RecordManager) DeleteOldSystemEvents() error {
+	return deleteOldSystemEvents(rm.app, time.Now().UTC())
+}
+
 // Delete old records
 func (rm *RecordManager) DeleteOldRecords() {
 	rm.app.RunInTransaction(func(txApp core.App) error {
-		err := deleteOldSystemStats(txApp)
+		err := rm.deleteOldStats(txApp)
 		if err != nil {
 			return err
 		}
@@ -383,10 +595,23 @@ func (rm *RecordManager) DeleteOldRecords() {
 		if err != nil {
 			return err
 		}
-		return nil
+		if err := deleteOldSystemEvents(txApp, time.Now().UTC()); err != nil {
+			return err
+		}
+		if err := deleteOldStatusChanges(txApp, time.Now().UTC()); err != nil {
+			return err
+		}
+		return deleteOldSensorRollups(txApp, time.Now().UTC(), rm.SensorRollupRetention())
 	})
 }
 
+// Delete system events older than the longest chart window
+func deleteOldSystemEvents(app core.App, now time.Time) error {
+	window := statsWindows[len(statsWindows)-1].window
+	_, err := app.DB().NewQuery("DELETE FROM system_events WHERE time < {:time}").Bind(dbx.Params{"time": now.Add(-window)}).Execute()
+	return err
+}
+
 // Delete old alerts history records
 func deleteOldAlertsHistory(app core.App, countToKeep, countBeforeDeletion int) error {
 	db := app.DB()
@@ -406,36 +631,39 @@ func deleteOldAlertsHistory(app core.App, countToKeep, countBeforeDeletion int)
 	return nil
 }
 
+// statsWindows is how long records of each type are displayed in the UI, and
+// kept as individual records
+var statsWindows = []struct {
+	recordType string
+	window     time.Duration
+}{
+	{recordType: "1m", window: time.Hour},             // 1 hour
+	{recordType: "10m", window: 12 * time.Hour},       // 12 hours
+	{recordType: "20m", window: 24 * time.Hour},       // 1 day
+	{recordType: "120m", window: 7 * 24 * time.Hour},  // 7 days
+	{recordType: "480m", window: 30 * 24 * time.Hour}, // 30 days
+}
+
+// Retention returns how long records of a type are kept, which is at least as
+// long as they are displayed in the UI.
+func Retention(retention map[string]time.Duration, recordType string) time.Duration {
+	return max(retention[recordType], statsWindow(recordType))
+}
+
 // Deletes system_stats records older than what is displayed in the UI
-func deleteOldSystemStats(app core.App) error {
+func deleteOldSystemStats(app core.App, now time.Time) error {
 	// Collections to process
 	collections := [2]string{"system_stats", "container_stats"}
 
-	// Record types and their retention periods
-	type RecordDeletionData struct {
-		recordType string
-		retention  time.Duration
-	}
-	recordData := []RecordDeletionData{
-		{recordType: "1m", retention: time.Hour},             // 1 hour
-		{recordType: "10m", retention: 12 * time.Hour},       // 12 hours
-		{recordType: "20m", retention: 24 * time.Hour},       // 1 day
-		{recordType: "120m", retention: 7 * 24 * time.Hour},  // 7 days
-		{recordType: "480m", retention: 30 * 24 * time.Hour}, // 30 days
-	}
-
-	now := time.Now().UTC()
-
 	for _, collection := range collections {
 		// Build the WHERE clause
 		var conditionParts []string
 		var params dbx.Params = make(map[string]any)
-		for i := range recordData {
-			rd := recordData[i]
+		for i, sw := range statsWindows {
 			// Create parameterized condition for this record type
 			dateParam := fmt.Sprintf("date%d", i)
-			conditionParts = append(conditionParts, fmt.Sprintf("(type = '%s' AND created < {:%s})", rd.recordType, dateParam))
-			params[dateParam] = now.Add(-rd.retention)
+			conditionParts = append(conditionParts, fmt.Sprintf("(type = '%s' AND created < {:%s})", sw.recordType, dateParam))
+			params[dateParam] = now.Add(-sw.window)
 		}
 		// Combine conditions with OR
 		conditionStr := strings.Join(conditionParts, " OR ")
@@ -452,3 +680,14 @@ func deleteOldSystemStats(app core.App) error {
 func twoDecimals(value float64) float64 {
 	return math.Round(value*100) / 100
 }
+
+// addSlices adds the values of b to a, growing a if b is longer.
+func addSlices(a, b []float64) []float64 {
+	if len(b) > len(a) {
+		a = append(a, make([]float64, len(b)-len(a))...)
+	}
+	for i, value := range b {
+		a[i] += value
+	}
+	return a
+}
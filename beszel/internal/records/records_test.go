@@ -79,6 +79,16 @@ func TestDeleteOldRecords(t *testing.T) {
 		require.NoError(t, err)
 	}
 
+	// Create an event older than the longest chart window and a recent one
+	for _, age := range []time.Duration{40 * 24 * time.Hour, time.Hour} {
+		_, err = tests.CreateRecord(hub, "system_events", map[string]any{
+			"system": system.Id,
+			"name":   "backup",
+			"time":   now.Add(-age),
+		})
+		require.NoError(t, err)
+	}
+
 	// Count records before deletion
 	systemStatsCountBefore, err := hub.CountRecords("system_stats")
 	require.NoError(t, err)
@@ -100,6 +110,12 @@ func TestDeleteOldRecords(t *testing.T) {
 	// Verify alerts history was trimmed
 	assert.Less(t, alertsCountAfter, alertsCountBefore, "Excessive alerts history should be deleted")
 	assert.Equal(t, alertsCountAfter, int64(200), "Alerts count should be equal to countToKeep (200)")
+
+	// Verify old events were deleted
+	events, err := hub.FindAllRecords("system_events")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.WithinDuration(t, now.Add(-time.Hour), events[0].GetDateTime("time").Time(), time.Second)
 }
 
 // TestDeleteOldSystemStats tests the deleteOldSystemStats function
@@ -360,7 +376,198 @@ func TestRecordManagerCreation(t *testing.T) {
 	assert.NotNil(t, rm, "RecordManager should not be nil")
 }
 
+func TestAverageContainerStats(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	rm := records.NewRecordManager(hub)
+	user, err := tests.CreateUser(hub, "test@example.com", "testtesttest")
+	require.NoError(t, err)
+	system, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "test-system",
+		"host":  "localhost",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	var ids records.RecordIds
+	for _, stats := range []string{
+		`[{"n": "web", "c": 10, "m": 100, "ns": 1, "nr": 2, "dr": 4, "dw": 8, "p": "blog"}]`,
+		`[{"n": "web", "c": 20, "m": 200, "ns": 3, "nr": 4, "p": "blog"}]`,
+	} {
+		record, err := tests.CreateRecord(hub, "container_stats", map[string]any{
+			"system": system.Id,
+			"type":   "1m",
+			"stats":  stats,
+		})
+		require.NoError(t, err)
+		ids = append(ids, struct {
+			Id string `db:"id"`
+		}{Id: record.Id})
+	}
+
+	result := rm.AverageContainerStats(hub.DB(), ids)
+	require.Len(t, result, 1)
+	assert.Equal(t, "web", result[0].Name)
+	assert.Equal(t, "blog", result[0].Project)
+	assert.Equal(t, 15.0, result[0].Cpu)
+	assert.Equal(t, 150.0, result[0].Mem)
+	assert.Equal(t, 2.0, result[0].NetworkSent)
+	assert.Equal(t, 3.0, result[0].NetworkRecv)
+	// agents without block i/o count as zero
+	assert.Equal(t, 2.0, result[0].DiskRead)
+	assert.Equal(t, 4.0, result[0].DiskWrite)
+}
+
+func TestAverageSystemStatsDockerDisk(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	rm := records.NewRecordManager(hub)
+	user, err := tests.CreateUser(hub, "test@example.com", "testtesttest")
+	require.NoError(t, err)
+	system, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "test-system",
+		"host":  "localhost",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	var ids records.RecordIds
+	for _, stats := range []string{
+		`{"cpu": 10, "ddu": {"i": 6, "c": 1, "v": 4, "b": 8, "r": 9}}`,
+		`{"cpu": 20, "ddu": {"i": 6, "c": 2, "v": 4, "b": 12, "r": 13}}`,
+		// disk usage isn't reported until the first request completes
+		`{"cpu": 30}`,
+	} {
+		record, err := tests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": system.Id,
+			"type":   "1m",
+			"stats":  stats,
+		})
+		require.NoError(t, err)
+		ids = append(ids, struct {
+			Id string `db:"id"`
+		}{Id: record.Id})
+	}
+
+	result := rm.AverageSystemStats(hub.DB(), ids)
+	assert.Equal(t, 20.0, result.Cpu)
+	require.NotNil(t, result.DockerDisk)
+	assert.Equal(t, 6.0, result.DockerDisk.Images)
+	assert.Equal(t, 1.5, result.DockerDisk.Containers)
+	assert.Equal(t, 4.0, result.DockerDisk.Volumes)
+	assert.Equal(t, 10.0, result.DockerDisk.BuildCache)
+	assert.Equal(t, 11.0, result.DockerDisk.Reclaimable)
+}
+
+func TestAverageSystemStatsNetworkInterfaces(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	rm := records.NewRecordManager(hub)
+	user, err := tests.CreateUser(hub, "test@example.com", "testtesttest")
+	require.NoError(t, err)
+	system, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "test-system",
+		"host":  "localhost",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	var ids records.RecordIds
+	for _, stats := range []string{
+		`{"ni": {"eth0": [300, 900], "wg0": [30, 60]}}`,
+		`{"ni": {"eth0": [600, 1200]}}`,
+		`{"ni": {"eth0": [0, 300], "wg0": [60, 30]}}`,
+	} {
+		record, err := tests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": system.Id,
+			"type":   "1m",
+			"stats":  stats,
+		})
+		require.NoError(t, err)
+		ids = append(ids, struct {
+			Id string `db:"id"`
+		}{Id: record.Id})
+	}
+
+	result := rm.AverageSystemStats(hub.DB(), ids)
+	assert.Equal(t, map[string][2]uint64{
+		"eth0": {300, 800},
+		// a missing interface counts as idle
+		"wg0": {30, 30},
+	}, result.NetworkInterfaces)
+}
+
+func TestAverageSystemStatsCpuCores(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	rm := records.NewRecordManager(hub)
+	user, err := tests.CreateUser(hub, "test@example.com", "testtesttest")
+	require.NoError(t, err)
+	system, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "test-system",
+		"host":  "localhost",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	var ids records.RecordIds
+	for _, stats := range []string{
+		`{"cpc": [10, 50], "cpf": [1500, 600], "cth": 1}`,
+		`{"cpc": [20, 70, 30], "cpf": [1500, 1800]}`,
+		// records from agents without per-core stats don't count
+		`{"cpu": 5}`,
+	} {
+		record, err := tests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": system.Id,
+			"type":   "1m",
+			"stats":  stats,
+		})
+		require.NoError(t, err)
+		ids = append(ids, struct {
+			Id string `db:"id"`
+		}{Id: record.Id})
+	}
+
+	result := rm.AverageSystemStats(hub.DB(), ids)
+	assert.Equal(t, []float64{15, 60, 15}, result.CpuCores)
+	assert.Equal(t, []float64{1500, 1200}, result.CpuFreq)
+	assert.Equal(t, 0.33, result.CpuThrottled)
+}
+
 // TestTwoDecimals tests the twoDecimals helper function
+func TestAverageStatsJSON(t *testing.T) {
+	stats := records.AverageSystemStatsJSON([][]byte{[]byte(`{"cpu": 10, "m": 8}`), []byte(`{"cpu": 21, "m": 8}`), []byte(`invalid`)})
+	// invalid records count toward the average, as with records that fail to load
+	assert.Equal(t, 10.33, stats.Cpu)
+	assert.Equal(t, 5.33, stats.Mem)
+
+	containers := records.AverageContainerStatsJSON([][]byte{[]byte(`[{"n": "web", "c": 10}]`), []byte(`[{"n": "web", "c": 20}, {"n": "db", "c": 4}]`)})
+	require.Len(t, containers, 2)
+	byName := map[string]float64{}
+	for _, c := range containers {
+		byName[c.Name] = c.Cpu
+	}
+	assert.Equal(t, map[string]float64{"web": 15, "db": 2}, byName)
+}
+
+func TestDownsampleSteps(t *testing.T) {
+	steps := records.DownsampleSteps()
+	require.Len(t, steps, 4)
+	assert.Equal(t, records.DownsampleStep{ShorterType: "1m", LongerType: "10m", Interval: 10 * time.Minute, MinRecords: 9}, steps[0])
+	// each step averages the records of the previous step
+	for i := 1; i < len(steps); i++ {
+		assert.Equal(t, steps[i-1].LongerType, steps[i].ShorterType)
+	}
+}
+
 func TestTwoDecimals(t *testing.T) {
 	testCases := []struct {
 		input    float64
@@ -379,3 +586,73 @@ func TestTwoDecimals(t *testing.T) {
 		assert.InDelta(t, tc.expected, result, 0.02, "twoDecimals(%f) should equal %f", tc.input, tc.expected)
 	}
 }
+
+func TestCheckDataQuality(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := tests.CreateUser(hub, "test@example.com", "testtesttest")
+	require.NoError(t, err)
+
+	createSystem := func(name, status string) *core.Record {
+		record, err := tests.CreateRecord(hub, "systems", map[string]any{
+			"name":   name,
+			"host":   "localhost",
+			"status": status,
+			"users":  []string{user.Id},
+		})
+		require.NoError(t, err)
+		return record
+	}
+	good := createSystem("good", "up")
+	bad := createSystem("bad", "up")
+	down := createSystem("down", "down")
+
+	until := time.Now().UTC().Truncate(time.Second)
+	since := until.Add(-time.Hour)
+	createStats := func(system *core.Record, age time.Duration, stats string) {
+		record, err := tests.CreateRecord(hub, "system_stats", map[string]any{
+			"system": system.Id,
+			"type":   "1m",
+			"stats":  stats,
+		})
+		require.NoError(t, err)
+		record.SetRaw("created", until.Add(-age).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+	for i := 1; i <= 10; i++ {
+		createStats(good, time.Duration(i)*time.Minute, `{"cpu": 10, "mp": 50, "dp": 20}`)
+		createStats(down, time.Duration(i)*time.Minute*3, `{"cpu": 10}`)
+	}
+	// 4 missing records between 10m and 5m ago, and none in the last 3 minutes
+	for _, age := range []time.Duration{10, 5, 4} {
+		createStats(bad, age*time.Minute, `{"cpu": 10, "mp": 50}`)
+	}
+	createStats(bad, 4*time.Minute+10*time.Second, `{"cpu": 101, "mp": -1}`)
+	// outside of the checked period
+	createStats(bad, 2*time.Hour, `{"cpu": 200}`)
+
+	rm := records.NewRecordManager(hub)
+	reports, err := records.CheckDataQuality(hub, rm, since, until)
+	require.NoError(t, err)
+	require.Len(t, reports, 2, "systems that are down are skipped")
+
+	assert.Equal(t, "bad", reports[0].Name)
+	assert.False(t, reports[0].OK())
+	assert.Equal(t, 4, reports[0].Records)
+	require.Len(t, reports[0].Gaps, 2)
+	assert.Equal(t, until.Add(-10*time.Minute), reports[0].Gaps[0].Start.UTC())
+	assert.Equal(t, until.Add(-5*time.Minute), reports[0].Gaps[0].End.UTC())
+	assert.Equal(t, 4, reports[0].Gaps[0].Missing)
+	assert.Equal(t, until, reports[0].Gaps[1].End.UTC(), "records stopped")
+	assert.Equal(t, 1, reports[0].Duplicates)
+	require.Len(t, reports[0].Invalid, 2)
+	assert.Equal(t, "cpu", reports[0].Invalid[0].Field)
+	assert.Equal(t, 101.0, reports[0].Invalid[0].Value)
+	assert.Equal(t, "mp", reports[0].Invalid[1].Field)
+
+	assert.Equal(t, good.Id, reports[1].System)
+	assert.Equal(t, 10, reports[1].Records)
+	assert.True(t, reports[1].OK())
+}
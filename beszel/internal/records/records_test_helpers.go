@@ -4,12 +4,14 @@
 package records
 
 import (
+	"time"
+
 	"github.com/pocketbase/pocketbase/core"
 )
 
 // TestDeleteOldSystemStats exposes deleteOldSystemStats for testing
 func TestDeleteOldSystemStats(app core.App) error {
-	return deleteOldSystemStats(app)
+	return deleteOldSystemStats(app, time.Now().UTC())
 }
 
 // TestDeleteOldAlertsHistory exposes deleteOldAlertsHistory for testing
@@ -21,3 +23,8 @@ func TestDeleteOldAlertsHistory(app core.App, countToKeep, countBeforeDeletion i
 func TestTwoDecimals(value float64) float64 {
 	return twoDecimals(value)
 }
+
+// TestDeleteOldStatusChanges exposes deleteOldStatusChanges for testing
+func TestDeleteOldStatusChanges(app core.App, now time.Time) error {
+	return deleteOldStatusChanges(app, now)
+}
@@ -0,0 +1,230 @@
+package records
+
+import (
+	"beszel/internal/entities/system"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Types of sensors in sensor_rollups records
+const (
+	SensorTypeTemperature = "temperature"
+	SensorTypeGeneric     = "generic"
+)
+
+// Readings further apart than this aren't used for the rate of change, since
+// the system was down or paused in between.
+const maxRateInterval = 5 * time.Minute
+
+// sensorRollupDay is the format of the day of sensor_rollups records, in UTC
+const sensorRollupDay = time.DateOnly
+
+// sensorReading is a numeric sensor value in a stats record
+type sensorReading struct {
+	sensorType string
+	sensor     string
+	unit       string
+	value      float64
+}
+
+// UpdateSensorRollups adds the temperatures and numeric generic sensors of a
+// stats record to the system's daily sensor_rollups records, which keep the
+// min, max and average of each sensor and its fastest rate of change per
+// minute. Sets the stats' TempRise to the fastest temperature rise since the
+// previous record.
+func UpdateSensorRollups(app core.App, systemId string, stats *system.Stats, now time.Time) error {
+	readings := sensorReadings(stats)
+	stats.TempRise = 0
+	if len(readings) == 0 {
+		return nil
+	}
+	collection, err := app.FindCachedCollectionByNameOrId("sensor_rollups")
+	if err != nil {
+		return err
+	}
+	now = now.UTC()
+	day := now.Format(sensorRollupDay)
+	rollups, err := findSensorRollups(app, systemId, day)
+	if err != nil {
+		return err
+	}
+	// the latest reading of sensors without a record for today is in yesterday's
+	var previous map[string]*core.Record
+
+	return app.RunInTransaction(func(txApp core.App) error {
+		for _, reading := range readings {
+			key := reading.sensorType + "/" + reading.sensor
+			record, ok := rollups[key]
+			if !ok {
+				if previous == nil {
+					if previous, err = findSensorRollups(txApp, systemId, now.AddDate(0, 0, -1).Format(sensorRollupDay)); err != nil {
+						return err
+					}
+				}
+				record = core.NewRecord(collection)
+				record.Set("system", systemId)
+				record.Set("type", reading.sensorType)
+				record.Set("sensor", reading.sensor)
+				record.Set("day", day)
+				if prev, ok := previous[key]; ok {
+					record.Set("last", prev.GetFloat("last"))
+					record.Set("last_at", prev.GetDateTime("last_at"))
+				}
+			}
+			rate := addSensorReading(record, reading, now)
+			if reading.sensorType == SensorTypeTemperature && rate > stats.TempRise {
+				stats.TempRise = twoDecimals(rate)
+			}
+			if err := txApp.SaveNoValidate(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sensorReadings returns the temperatures and generic sensors with numeric
+// values of a stats record. State sensors are skipped.
+func sensorReadings(stats *system.Stats) []sensorReading {
+	readings := make([]sensorReading, 0, len(stats.Temperatures)+len(stats.GenericSensors))
+	for name, value := range stats.Temperatures {
+		readings = append(readings, sensorReading{SensorTypeTemperature, name, "°C", value})
+	}
+	for name, sensor := range stats.GenericSensors {
+		if sensor.State != "" {
+			continue
+		}
+		readings = append(readings, sensorReading{SensorTypeGeneric, name, sensor.Unit, sensor.Value})
+	}
+	return readings
+}
+
+// findSensorRollups returns the sensor_rollups records of a system for a day,
+// by type and sensor.
+func findSensorRollups(app core.App, systemId, day string) (map[string]*core.Record, error) {
+	records, err := app.FindAllRecords("sensor_rollups", dbx.HashExp{"system": systemId, "day": day})
+	if err != nil {
+		return nil, err
+	}
+	rollups := make(map[string]*core.Record, len(records))
+	for _, record := range records {
+		rollups[record.GetString("type")+"/"+record.GetString("sensor")] = record
+	}
+	return rollups, nil
+}
+
+// addSensorReading updates a rollup record with a reading and returns the rate
+// of change per minute since the previous reading, or 0 if there is none.
+func addSensorReading(record *core.Record, reading sensorReading, now time.Time) float64 {
+	at, _ := types.ParseDateTime(now)
+	count := record.GetInt("count")
+	if count == 0 || reading.value < record.GetFloat("min") {
+		record.Set("min", reading.value)
+		record.Set("min_at", at)
+	}
+	if count == 0 || reading.value > record.GetFloat("max") {
+		record.Set("max", reading.value)
+		record.Set("max_at", at)
+	}
+	// running average
+	avg := record.GetFloat("avg")
+	record.Set("avg", avg+(reading.value-avg)/float64(count+1))
+	record.Set("count", count+1)
+	record.Set("unit", reading.unit)
+
+	var rate float64
+	lastAt := record.GetDateTime("last_at").Time()
+	if elapsed := now.Sub(lastAt); !lastAt.IsZero() && elapsed > 0 && elapsed <= maxRateInterval {
+		rate = (reading.value - record.GetFloat("last")) / elapsed.Minutes()
+		if rate > record.GetFloat("max_rate") || record.GetDateTime("max_rate_at").IsZero() {
+			record.Set("max_rate", twoDecimals(rate))
+			record.Set("max_rate_at", at)
+		}
+	}
+	record.Set("last", reading.value)
+	record.Set("last_at", at)
+	return rate
+}
+
+// DeleteOldSensorRollups deletes sensor_rollups records past their retention
+func (rm *RecordManager) DeleteOldSensorRollups() error {
+	return deleteOldSensorRollups(rm.app, time.Now().UTC(), rm.SensorRollupRetention())
+}
+
+// Delete sensor rollups older than the retention
+func deleteOldSensorRollups(app core.App, now time.Time, retention time.Duration) error {
+	day := now.Add(-retention).Format(sensorRollupDay)
+	_, err := app.DB().NewQuery("DELETE FROM sensor_rollups WHERE day < {:day}").Bind(dbx.Params{"day": day}).Execute()
+	return err
+}
+
+// SensorSummary is the min, max and average of a sensor over one or more days,
+// with the times of the extremes, from its daily sensor_rollups records
+type SensorSummary struct {
+	Type      string    `json:"type"`
+	Sensor    string    `json:"sensor"`
+	Unit      string    `json:"unit,omitempty"`
+	Min       float64   `json:"min"`
+	MinAt     time.Time `json:"minAt"`
+	Max       float64   `json:"max"`
+	MaxAt     time.Time `json:"maxAt"`
+	Avg       float64   `json:"avg"`
+	MaxRate   float64   `json:"maxRate"` // fastest rise per minute
+	MaxRateAt time.Time `json:"maxRateAt,omitzero"`
+	count     int
+}
+
+// SummarizeSensors combines the sensor_rollups records of a system since a
+// day into a summary of each sensor, sorted by type and sensor.
+func SummarizeSensors(app core.App, systemId string, since time.Time) ([]SensorSummary, error) {
+	var rollups []*core.Record
+	err := app.RecordQuery("sensor_rollups").
+		AndWhere(dbx.HashExp{"system": systemId}).
+		AndWhere(dbx.NewExp("day >= {:day}", dbx.Params{"day": since.UTC().Format(sensorRollupDay)})).
+		OrderBy("type", "sensor", "day").
+		All(&rollups)
+	if err != nil {
+		return nil, err
+	}
+	summaries := []SensorSummary{}
+	for _, record := range rollups {
+		count := record.GetInt("count")
+		if count == 0 {
+			continue
+		}
+		last := len(summaries) - 1
+		if last < 0 || summaries[last].Type != record.GetString("type") || summaries[last].Sensor != record.GetString("sensor") {
+			summaries = append(summaries, SensorSummary{
+				Type:   record.GetString("type"),
+				Sensor: record.GetString("sensor"),
+				Min:    record.GetFloat("min"),
+				MinAt:  record.GetDateTime("min_at").Time(),
+				Max:    record.GetFloat("max"),
+				MaxAt:  record.GetDateTime("max_at").Time(),
+			})
+			last++
+		}
+		summary := &summaries[last]
+		// days are in order, so the unit is the latest
+		summary.Unit = record.GetString("unit")
+		if value := record.GetFloat("min"); value < summary.Min {
+			summary.Min, summary.MinAt = value, record.GetDateTime("min_at").Time()
+		}
+		if value := record.GetFloat("max"); value > summary.Max {
+			summary.Max, summary.MaxAt = value, record.GetDateTime("max_at").Time()
+		}
+		if rateAt := record.GetDateTime("max_rate_at"); !rateAt.IsZero() && (summary.MaxRateAt.IsZero() || record.GetFloat("max_rate") > summary.MaxRate) {
+			summary.MaxRate, summary.MaxRateAt = record.GetFloat("max_rate"), rateAt.Time()
+		}
+		// average weighted by the number of readings of each day
+		summary.Avg += (record.GetFloat("avg") - summary.Avg) * float64(count) / float64(summary.count+count)
+		summary.count += count
+	}
+	for i := range summaries {
+		summaries[i].Avg = twoDecimals(summaries[i].Avg)
+	}
+	return summaries, nil
+}
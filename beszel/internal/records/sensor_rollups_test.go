@@ -0,0 +1,99 @@
+//go:build testing
+// +build testing
+
+package records_test
+
+import (
+	"beszel/internal/entities/system"
+	"beszel/internal/records"
+	"beszel/internal/tests"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateSensorRollups(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := tests.CreateUser(hub, "test@example.com", "testtesttest")
+	require.NoError(t, err)
+	sys, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "test-system",
+		"host":  "localhost",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	update := func(now time.Time, nvme, cpu float64) *system.Stats {
+		stats := &system.Stats{
+			Temperatures: map[string]float64{"nvme": nvme, "cpu": cpu},
+			GenericSensors: map[string]system.SensorData{
+				"power": {Value: nvme * 10, Unit: "W"},
+				"door":  {Value: 1, Max: 1, State: "open"},
+			},
+		}
+		require.NoError(t, records.UpdateSensorRollups(hub, sys.Id, stats, now))
+		return stats
+	}
+
+	// readings before and after midnight
+	start := time.Date(2025, 1, 2, 23, 58, 0, 0, time.UTC)
+	assert.Zero(t, update(start, 50, 40).TempRise)
+	assert.Equal(t, 22.0, update(start.Add(time.Minute), 72, 41).TempRise)
+	assert.Equal(t, 3.0, update(start.Add(2*time.Minute), 75, 40).TempRise)
+	// no rate of change after a gap
+	assert.Zero(t, update(start.Add(20*time.Minute), 90, 40).TempRise)
+
+	rollups, err := hub.FindAllRecords("sensor_rollups", dbx.HashExp{"system": sys.Id})
+	require.NoError(t, err)
+	// state sensors are skipped
+	assert.Len(t, rollups, 6)
+
+	find := func(sensorType, sensor, day string) map[string]any {
+		record, err := hub.FindFirstRecordByFilter("sensor_rollups", "type={:type} && sensor={:sensor} && day={:day}",
+			dbx.Params{"type": sensorType, "sensor": sensor, "day": day})
+		require.NoError(t, err)
+		return map[string]any{
+			"min":         record.GetFloat("min"),
+			"max":         record.GetFloat("max"),
+			"max_at":      record.GetDateTime("max_at").Time().UTC(),
+			"avg":         record.GetFloat("avg"),
+			"count":       record.GetInt("count"),
+			"max_rate":    record.GetFloat("max_rate"),
+			"max_rate_at": record.GetDateTime("max_rate_at").Time().UTC(),
+			"unit":        record.GetString("unit"),
+		}
+	}
+	assert.Equal(t, map[string]any{
+		"min": 50.0, "max": 72.0, "max_at": start.Add(time.Minute), "avg": 61.0, "count": 2,
+		"max_rate": 22.0, "max_rate_at": start.Add(time.Minute), "unit": "°C",
+	}, find(records.SensorTypeTemperature, "nvme", "2025-01-02"))
+	// the rate of change of the first reading of the day is from yesterday's last reading
+	assert.Equal(t, map[string]any{
+		"min": 75.0, "max": 90.0, "max_at": start.Add(20 * time.Minute), "avg": 82.5, "count": 2,
+		"max_rate": 3.0, "max_rate_at": start.Add(2 * time.Minute), "unit": "°C",
+	}, find(records.SensorTypeTemperature, "nvme", "2025-01-03"))
+	assert.Equal(t, "W", find(records.SensorTypeGeneric, "power", "2025-01-02")["unit"])
+	assert.Equal(t, 720.0, find(records.SensorTypeGeneric, "power", "2025-01-02")["max"])
+
+	// old rollups are kept for the sensors retention
+	rm := records.NewRecordManager(hub)
+	rm.SetRetention(map[string]time.Duration{records.SensorsRetention: 10 * 365 * 24 * time.Hour})
+	require.NoError(t, rm.DeleteOldSensorRollups())
+	count, err := hub.CountRecords("sensor_rollups")
+	require.NoError(t, err)
+	assert.NotZero(t, count)
+
+	// and for the longest chart window by default
+	rm.SetRetention(nil)
+	assert.Equal(t, 30*24*time.Hour, rm.SensorRollupRetention())
+	require.NoError(t, rm.DeleteOldSensorRollups())
+	count, err = hub.CountRecords("sensor_rollups")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,125 @@
+package records
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"cmp"
+	"encoding/json"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// RecordManager is the default storage driver, using the PocketBase collections
+var _ storage.Driver = (*RecordManager)(nil)
+
+// AddStats saves one minute system_stats and container_stats records.
+func (rm *RecordManager) AddStats(systemId string, stats *system.Stats, containers []*container.Stats) error {
+	systemStatsCollection, err := rm.app.FindCachedCollectionByNameOrId("system_stats")
+	if err != nil {
+		return err
+	}
+	systemStatsRecord := core.NewRecord(systemStatsCollection)
+	systemStatsRecord.Set("system", systemId)
+	systemStatsRecord.Set("stats", stats)
+	systemStatsRecord.Set("type", storage.Type1m)
+	if err := rm.app.SaveNoValidate(systemStatsRecord); err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return nil
+	}
+	containerStatsCollection, err := rm.app.FindCachedCollectionByNameOrId("container_stats")
+	if err != nil {
+		return err
+	}
+	containerStatsRecord := core.NewRecord(containerStatsCollection)
+	containerStatsRecord.Set("system", systemId)
+	containerStatsRecord.Set("stats", containers)
+	containerStatsRecord.Set("type", storage.Type1m)
+	return rm.app.SaveNoValidate(containerStatsRecord)
+}
+
+// SystemStats returns system_stats records matching the query, oldest first,
+// including records packed into packed_stats. Records with invalid stats are skipped.
+func (rm *RecordManager) SystemStats(query storage.Query) ([]storage.SystemStats, error) {
+	rows, err := rm.queryStats("system_stats", query)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]storage.SystemStats, 0, len(rows))
+	for _, row := range rows {
+		record := storage.SystemStats{System: row.System, Type: row.Type, Created: row.Created.Time()}
+		if err := json.Unmarshal(row.Stats, &record.Stats); err == nil {
+			records = append(records, record)
+		}
+	}
+	if len(rm.retention) == 0 {
+		return records, nil
+	}
+	packed, err := rm.packedSystemStats(query)
+	if err != nil || len(packed) == 0 {
+		return records, err
+	}
+	records = append(packed, records...)
+	sortByTime(records, func(r storage.SystemStats) time.Time { return r.Created })
+	return records, nil
+}
+
+// ContainerStats returns container_stats records matching the query, oldest first,
+// including records packed into packed_stats. Records with invalid stats are skipped.
+func (rm *RecordManager) ContainerStats(query storage.Query) ([]storage.ContainerStats, error) {
+	rows, err := rm.queryStats("container_stats", query)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]storage.ContainerStats, 0, len(rows))
+	for _, row := range rows {
+		record := storage.ContainerStats{System: row.System, Type: row.Type, Created: row.Created.Time()}
+		if err := json.Unmarshal(row.Stats, &record.Stats); err == nil {
+			records = append(records, record)
+		}
+	}
+	if len(rm.retention) == 0 {
+		return records, nil
+	}
+	packed, err := rm.packedContainerStats(query)
+	if err != nil || len(packed) == 0 {
+		return records, err
+	}
+	records = append(packed, records...)
+	sortByTime(records, func(r storage.ContainerStats) time.Time { return r.Created })
+	return records, nil
+}
+
+// statsRow is a row of the system_stats or container_stats table
+type statsRow struct {
+	System  string         `db:"system"`
+	Type    string         `db:"type"`
+	Created types.DateTime `db:"created"`
+	Stats   []byte         `db:"stats"`
+}
+
+// queryStats returns the rows of a stats collection matching the query, oldest first.
+func (rm *RecordManager) queryStats(table string, query storage.Query) ([]statsRow, error) {
+	q := rm.app.DB().
+		Select("system", "type", "created", "stats").
+		From(table).
+		Where(dbx.HashExp{"type": cmp.Or(query.Type, storage.Type1m)}).
+		OrderBy("created")
+	if query.System != "" {
+		q.AndWhere(dbx.HashExp{"system": query.System})
+	}
+	if !query.Since.IsZero() {
+		q.AndWhere(dbx.NewExp("created > {:since}", dbx.Params{"since": query.Since.UTC().Format(types.DefaultDateLayout)}))
+	}
+	if !query.Until.IsZero() {
+		q.AndWhere(dbx.NewExp("created <= {:until}", dbx.Params{"until": query.Until.UTC().Format(types.DefaultDateLayout)}))
+	}
+	var rows []statsRow
+	err := q.All(&rows)
+	return rows, err
+}
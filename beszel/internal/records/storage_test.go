@@ -0,0 +1,94 @@
+//go:build testing
+// +build testing
+
+package records_test
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"beszel/internal/hub/storage"
+	"beszel/internal/records"
+	"beszel/internal/tests"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordManagerStorage(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := tests.CreateUser(hub, "test@example.com", "testtesttest")
+	require.NoError(t, err)
+	createSystem := func(name string) string {
+		record, err := tests.CreateRecord(hub, "systems", map[string]any{
+			"name":  name,
+			"host":  "localhost",
+			"users": []string{user.Id},
+		})
+		require.NoError(t, err)
+		record.Set("status", "up")
+		require.NoError(t, hub.SaveNoValidate(record))
+		return record.Id
+	}
+	system1 := createSystem("one")
+	system2 := createSystem("two")
+
+	rm := records.NewRecordManager(hub)
+	for i := range 10 {
+		containers := []*container.Stats{{Name: "web", Cpu: float64(i)}}
+		require.NoError(t, rm.AddStats(system1, &system.Stats{Cpu: float64(i)}, containers))
+	}
+	require.NoError(t, rm.AddStats(system2, &system.Stats{Cpu: 50}, nil))
+
+	all, err := rm.SystemStats(storage.Query{})
+	require.NoError(t, err)
+	assert.Len(t, all, 11)
+
+	systemStats, err := rm.SystemStats(storage.Query{System: system1})
+	require.NoError(t, err)
+	require.Len(t, systemStats, 10)
+	assert.Equal(t, system1, systemStats[0].System)
+	assert.Equal(t, storage.Type1m, systemStats[0].Type)
+	assert.Equal(t, 9.0, systemStats[9].Stats.Cpu, "oldest first")
+
+	containerStats, err := rm.ContainerStats(storage.Query{System: system2})
+	require.NoError(t, err)
+	assert.Empty(t, containerStats, "no record without containers")
+	containerStats, err = rm.ContainerStats(storage.Query{System: system1})
+	require.NoError(t, err)
+	require.Len(t, containerStats, 10)
+	assert.Equal(t, []container.Stats{{Name: "web", Cpu: 0}}, containerStats[0].Stats)
+
+	// time range
+	old := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	_, err = hub.DB().Update("system_stats", dbx.Params{"created": old.Format(types.DefaultDateLayout)}, dbx.HashExp{"system": system2}).Execute()
+	require.NoError(t, err)
+	ranged, err := rm.SystemStats(storage.Query{Since: old.Add(-time.Minute), Until: old})
+	require.NoError(t, err)
+	require.Len(t, ranged, 1)
+	assert.Equal(t, system2, ranged[0].System)
+	assert.True(t, ranged[0].Created.Equal(old))
+
+	// longer records are averages of shorter records
+	require.NoError(t, rm.Downsample())
+	longer, err := rm.SystemStats(storage.Query{Type: storage.Type10m})
+	require.NoError(t, err)
+	require.Len(t, longer, 1, "system two has too few recent records")
+	assert.Equal(t, system1, longer[0].System)
+	assert.Equal(t, 4.5, longer[0].Stats.Cpu)
+	longerContainers, err := rm.ContainerStats(storage.Query{Type: storage.Type10m})
+	require.NoError(t, err)
+	require.Len(t, longerContainers, 1)
+	assert.Equal(t, 4.5, longerContainers[0].Stats[0].Cpu)
+
+	require.NoError(t, rm.DeleteOld())
+	remaining, err := rm.SystemStats(storage.Query{})
+	require.NoError(t, err)
+	assert.Len(t, remaining, 10, "old 1m record deleted")
+}
@@ -0,0 +1,140 @@
+package records
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// UptimeWindows are the windows system uptime is computed over, from shortest
+// to longest. Status changes are kept for the longest window.
+var UptimeWindows = []struct {
+	Name   string
+	Length time.Duration
+}{
+	{Name: "24h", Length: 24 * time.Hour},
+	{Name: "7d", Length: 7 * 24 * time.Hour},
+	{Name: "30d", Length: 30 * 24 * time.Hour},
+	{Name: "90d", Length: 90 * 24 * time.Hour},
+}
+
+// Uptime is the share of a window a system was up. Time the system was paused
+// or pending, or before its first status change, isn't counted.
+type Uptime struct {
+	Window    string  `json:"window"`
+	Percent   float64 `json:"percent"` // 100 if no time was counted
+	Up        float64 `json:"up"`      // seconds
+	Down      float64 `json:"down"`    // seconds
+	Incidents int     `json:"incidents"`
+}
+
+// StatusChange is a change of the status of a system
+type StatusChange struct {
+	Status  string         `db:"status" json:"status"`
+	Created types.DateTime `db:"created" json:"created"`
+}
+
+// TrackStatusChanges adds a status_changes record when the status of a system
+// record changes. Bound to updates of systems records, which are loaded from
+// the database before their status is set.
+func TrackStatusChanges(e *core.RecordEvent) error {
+	prevStatus := e.Record.Original().GetString("status")
+	if err := e.Next(); err != nil {
+		return err
+	}
+	status := e.Record.GetString("status")
+	if status == prevStatus {
+		return nil
+	}
+	collection, err := e.App.FindCachedCollectionByNameOrId("status_changes")
+	if err != nil {
+		return err
+	}
+	record := core.NewRecord(collection)
+	record.Set("system", e.Record.Id)
+	record.Set("status", status)
+	if err := e.App.SaveNoValidate(record); err != nil {
+		e.App.Logger().Error("Failed to save status change", "system", e.Record.Id, "err", err)
+	}
+	return nil
+}
+
+// SystemUptime returns the uptime of a system over each of the UptimeWindows
+// ending at now
+func SystemUptime(app core.App, systemId string, now time.Time) ([]Uptime, error) {
+	now = now.UTC()
+	longest := UptimeWindows[len(UptimeWindows)-1].Length
+	changes, err := statusChanges(app, systemId, now.Add(-longest), now)
+	if err != nil {
+		return nil, err
+	}
+	uptimes := make([]Uptime, len(UptimeWindows))
+	for i, window := range UptimeWindows {
+		uptimes[i] = uptimeBetween(changes, window.Name, now.Add(-window.Length), now)
+	}
+	return uptimes, nil
+}
+
+// statusChanges returns the status changes of a system between from and to,
+// oldest first, starting with the status the system had at from
+func statusChanges(app core.App, systemId string, from, to time.Time) ([]StatusChange, error) {
+	var changes []StatusChange
+	err := app.DB().NewQuery("SELECT status, created FROM status_changes " +
+		"WHERE system = {:system} AND created < {:to} AND created >= COALESCE(" +
+		"(SELECT MAX(created) FROM status_changes WHERE system = {:system} AND created <= {:from}), '') " +
+		"ORDER BY created").
+		Bind(dbx.Params{
+			"system": systemId,
+			"from":   from.Format(types.DefaultDateLayout),
+			"to":     to.Format(types.DefaultDateLayout),
+		}).All(&changes)
+	return changes, err
+}
+
+// uptimeBetween adds up the time a system was up and down between from and to
+// from its status changes, oldest first
+func uptimeBetween(changes []StatusChange, window string, from, to time.Time) Uptime {
+	uptime := Uptime{Window: window, Percent: 100}
+	for i, change := range changes {
+		start, end := change.Created.Time(), to
+		if i+1 < len(changes) {
+			end = changes[i+1].Created.Time()
+		}
+		if !end.After(from) {
+			continue
+		}
+		if start.Before(from) {
+			start = from
+		} else if change.Status == "down" {
+			uptime.Incidents++
+		}
+		switch change.Status {
+		case "up":
+			uptime.Up += end.Sub(start).Seconds()
+		case "down":
+			uptime.Down += end.Sub(start).Seconds()
+		}
+	}
+	if total := uptime.Up + uptime.Down; total > 0 {
+		uptime.Percent = uptime.Up / total * 100
+	}
+	return uptime
+}
+
+// DeleteOldStatusChanges deletes status_changes records older than the longest
+// uptime window, except the latest of each system, which is its status at the
+// start of the window
+func (rm *RecordManager) DeleteOldStatusChanges() error {
+	return deleteOldStatusChanges(rm.app, time.Now().UTC())
+}
+
+// Delete status changes older than the longest uptime window
+func deleteOldStatusChanges(app core.App, now time.Time) error {
+	cutoff := now.Add(-UptimeWindows[len(UptimeWindows)-1].Length).Format(types.DefaultDateLayout)
+	_, err := app.DB().NewQuery("DELETE FROM status_changes WHERE created < {:time} AND created < " +
+		"(SELECT MAX(c.created) FROM status_changes c WHERE c.system = status_changes.system AND c.created < {:time})").
+		Bind(dbx.Params{"time": cutoff}).Execute()
+	return err
+}
@@ -0,0 +1,94 @@
+//go:build testing
+// +build testing
+
+package records_test
+
+import (
+	"beszel/internal/records"
+	"beszel/internal/tests"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemUptime(t *testing.T) {
+	hub, err := tests.NewTestHub(t.TempDir())
+	require.NoError(t, err)
+	defer hub.Cleanup()
+
+	user, err := tests.CreateUser(hub, "test@example.com", "testtesttest")
+	require.NoError(t, err)
+	sys, err := tests.CreateRecord(hub, "systems", map[string]any{
+		"name":  "test-system",
+		"host":  "localhost",
+		"users": []string{user.Id},
+	})
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	day := 24 * time.Hour
+	changes := []struct {
+		age    time.Duration
+		status string
+	}{
+		{100 * day, "up"},
+		{95 * day, "down"},
+		{91 * day, "up"},
+		// one hour down ten days ago
+		{10 * day, "down"},
+		{10*day - time.Hour, "up"},
+		// one hour down and half an hour paused today
+		{2 * time.Hour, "down"},
+		{time.Hour, "paused"},
+		{30 * time.Minute, "up"},
+	}
+	for _, change := range changes {
+		record, err := tests.CreateRecord(hub, "status_changes", map[string]any{"system": sys.Id, "status": change.status})
+		require.NoError(t, err)
+		record.SetRaw("created", now.Add(-change.age).Format(types.DefaultDateLayout))
+		require.NoError(t, hub.SaveNoValidate(record))
+	}
+
+	uptime, err := records.SystemUptime(hub, sys.Id, now)
+	require.NoError(t, err)
+	require.Len(t, uptime, 4)
+
+	hours := func(u records.Uptime) [2]float64 { return [2]float64{u.Up / 3600, u.Down / 3600} }
+	assert.Equal(t, "24h", uptime[0].Window)
+	assert.Equal(t, [2]float64{22.5, 1}, hours(uptime[0]))
+	assert.InDelta(t, 22.5/23.5*100, uptime[0].Percent, 0.001)
+	assert.Equal(t, 1, uptime[0].Incidents)
+
+	assert.Equal(t, "7d", uptime[1].Window)
+	assert.Equal(t, [2]float64{7*24 - 1.5, 1}, hours(uptime[1]))
+	assert.Equal(t, 1, uptime[1].Incidents)
+
+	// the status at the start of the window comes from the latest earlier change
+	assert.Equal(t, "30d", uptime[2].Window)
+	assert.Equal(t, [2]float64{30*24 - 2.5, 2}, hours(uptime[2]))
+	assert.Equal(t, 2, uptime[2].Incidents)
+
+	assert.Equal(t, "90d", uptime[3].Window)
+	assert.Equal(t, [2]float64{90*24 - 2.5, 2}, hours(uptime[3]))
+	assert.Equal(t, 2, uptime[3].Incidents)
+
+	// changes older than 90 days are deleted except the status at the start of the window
+	require.NoError(t, records.TestDeleteOldStatusChanges(hub, now))
+	count, err := hub.CountRecords("status_changes", dbx.HashExp{"system": sys.Id})
+	require.NoError(t, err)
+	assert.EqualValues(t, 6, count)
+	uptimeAfter, err := records.SystemUptime(hub, sys.Id, now)
+	require.NoError(t, err)
+	assert.Equal(t, uptime, uptimeAfter)
+
+	// systems without status changes are up all the time that is counted
+	other, err := tests.CreateRecord(hub, "systems", map[string]any{"name": "other", "host": "localhost", "users": []string{user.Id}})
+	require.NoError(t, err)
+	uptime, err = records.SystemUptime(hub, other.Id, now)
+	require.NoError(t, err)
+	assert.Equal(t, records.Uptime{Window: "24h", Percent: 100}, uptime[0])
+}
@@ -0,0 +1,152 @@
+// Package tui implements a terminal client for the hub.
+package tui
+
+import (
+	"beszel/internal/entities/system"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client is a minimal client for the hub's PocketBase API
+type Client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+// System is a system record from the hub
+type System struct {
+	Id     string      `json:"id"`
+	Name   string      `json:"name"`
+	Status string      `json:"status"`
+	Info   system.Info `json:"info"`
+}
+
+// Alert is a triggered alert from the hub
+type Alert struct {
+	System string  `json:"system"`
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+}
+
+// History holds recent stats of a system, oldest first
+type History struct {
+	Cpu    []float64
+	MemPct []float64
+}
+
+// NewClient creates a client for the hub at hubURL.
+func NewClient(hubURL string) *Client {
+	return &Client{
+		url:  strings.TrimSuffix(hubURL, "/"),
+		http: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// URL returns the hub URL.
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Login authenticates with a user's email and password.
+func (c *Client) Login(email, password string) error {
+	body, err := json.Marshal(map[string]string{"identity": email, "password": password})
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Token string `json:"token"`
+	}
+	if err := c.do(http.MethodPost, "/api/collections/users/auth-with-password", bytes.NewReader(body), &res); err != nil {
+		return err
+	}
+	c.token = res.Token
+	return nil
+}
+
+// Systems returns the user's systems sorted by name.
+func (c *Client) Systems() ([]System, error) {
+	var res struct {
+		Items []System `json:"items"`
+	}
+	query := url.Values{
+		"perPage": {"500"},
+		"sort":    {"name"},
+		"fields":  {"id,name,status,info"},
+	}
+	err := c.do(http.MethodGet, "/api/collections/systems/records?"+query.Encode(), nil, &res)
+	return res.Items, err
+}
+
+// History returns up to limit of the latest one minute stats of a system. Stats
+// are read through the hub's stats API, which works with any storage driver.
+func (c *Client) History(systemId string, limit int) (History, error) {
+	var records []struct {
+		Stats system.Stats `json:"stats"`
+	}
+	query := url.Values{
+		"system":     {systemId},
+		"collection": {"system_stats"},
+		"since":      {time.Now().UTC().Add(-time.Duration(limit) * time.Minute).Format(time.RFC3339)},
+	}
+	var history History
+	if err := c.do(http.MethodGet, "/api/beszel/stats?"+query.Encode(), nil, &records); err != nil {
+		return history, err
+	}
+	// oldest first
+	for _, record := range records[max(0, len(records)-limit):] {
+		history.Cpu = append(history.Cpu, record.Stats.Cpu)
+		history.MemPct = append(history.MemPct, record.Stats.MemPct)
+	}
+	return history, nil
+}
+
+// Alerts returns the user's triggered alerts.
+func (c *Client) Alerts() ([]Alert, error) {
+	var res struct {
+		Items []Alert `json:"items"`
+	}
+	query := url.Values{
+		"filter":  {"triggered=true"},
+		"perPage": {"500"},
+		"fields":  {"system,name,value"},
+	}
+	err := c.do(http.MethodGet, "/api/collections/alerts/records?"+query.Encode(), nil, &res)
+	return res.Items, err
+}
+
+// do sends a request to the hub and decodes the JSON response into v.
+func (c *Client) do(method, path string, body *bytes.Reader, v any) error {
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequest(method, c.url+path, body)
+	} else {
+		req, err = http.NewRequest(method, c.url+path, nil)
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", c.token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&apiErr)
+		return fmt.Errorf("%s %s: %d %s", method, strings.SplitN(path, "?", 2)[0], res.StatusCode, apiErr.Message)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
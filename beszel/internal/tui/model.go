@@ -0,0 +1,267 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Number of one minute records shown in sparklines
+const historyLength = 30
+
+// Msg is an event handled by Model.Update
+type Msg any
+
+// Cmd runs asynchronously and returns a Msg to send to Model.Update
+type Cmd func() Msg
+
+// KeyMsg is a key press, e.g. "q", "up" or "ctrl+c"
+type KeyMsg string
+
+// TickMsg is sent on each refresh interval
+type TickMsg time.Time
+
+// ResizeMsg is sent when the terminal size changes
+type ResizeMsg struct {
+	Width int
+}
+
+// DataMsg holds the result of fetching data from the hub
+type DataMsg struct {
+	Systems []System
+	History map[string]History // keyed by system id
+	Alerts  []Alert
+	Err     error
+	Time    time.Time
+}
+
+type quitMsg struct{}
+
+// Quit is a Cmd that exits the program
+func Quit() Msg {
+	return quitMsg{}
+}
+
+// Model is the state of the terminal UI. Update and View follow The Elm
+// Architecture, so the model is easy to test without a terminal.
+type Model struct {
+	client   *Client
+	systems  []System
+	history  map[string]History
+	alerts   []Alert
+	selected int
+	err      error
+	updated  time.Time
+	loading  bool
+	width    int
+	color    bool
+}
+
+// NewModel creates a Model that fetches data with the client.
+func NewModel(client *Client, color bool) Model {
+	return Model{client: client, width: 100, color: color}
+}
+
+// Init returns the initial Cmd, which fetches data from the hub.
+func (m Model) Init() Cmd {
+	return m.fetch
+}
+
+// Update handles a Msg and returns the new model and an optional Cmd.
+func (m Model) Update(msg Msg) (Model, Cmd) {
+	switch msg := msg.(type) {
+	case KeyMsg:
+		switch msg {
+		case "q", "ctrl+c", "esc":
+			return m, Quit
+		case "up", "k":
+			m.selected = max(0, m.selected-1)
+		case "down", "j":
+			m.selected = min(len(m.systems)-1, m.selected+1)
+		case "r":
+			return m.refresh()
+		}
+	case TickMsg:
+		return m.refresh()
+	case ResizeMsg:
+		m.width = msg.Width
+	case DataMsg:
+		m.loading = false
+		m.err = msg.Err
+		if msg.Err == nil {
+			m.systems, m.history, m.alerts, m.updated = msg.Systems, msg.History, msg.Alerts, msg.Time
+			m.selected = max(0, min(m.selected, len(m.systems)-1))
+		}
+	}
+	return m, nil
+}
+
+// refresh fetches new data unless a fetch is already running.
+func (m Model) refresh() (Model, Cmd) {
+	if m.loading {
+		return m, nil
+	}
+	m.loading = true
+	return m, m.fetch
+}
+
+// fetch loads systems, their history and active alerts from the hub.
+func (m Model) fetch() Msg {
+	msg := DataMsg{Time: time.Now(), History: make(map[string]History)}
+	if msg.Systems, msg.Err = m.client.Systems(); msg.Err != nil {
+		return msg
+	}
+	for _, sys := range msg.Systems {
+		if sys.Status != "up" {
+			continue
+		}
+		if msg.History[sys.Id], msg.Err = m.client.History(sys.Id, historyLength); msg.Err != nil {
+			return msg
+		}
+	}
+	msg.Alerts, msg.Err = m.client.Alerts()
+	return msg
+}
+
+// View renders the model.
+func (m Model) View() string {
+	var b strings.Builder
+
+	// header
+	var up, down, paused int
+	for _, sys := range m.systems {
+		switch sys.Status {
+		case "up":
+			up++
+		case "down":
+			down++
+		case "paused":
+			paused++
+		}
+	}
+	b.WriteString(m.style("Beszel", bold) + "  " + m.client.URL() + "\n")
+	fmt.Fprintf(&b, "%d up · %d down · %d paused", up, down, paused)
+	if !m.updated.IsZero() {
+		b.WriteString(m.style("  updated "+m.updated.Format(time.TimeOnly), faint))
+	}
+	b.WriteString("\n\n")
+
+	if m.err != nil {
+		b.WriteString(m.style("Error: "+m.err.Error(), red) + "\n\n")
+	}
+
+	// systems table
+	sparkWidth := max(10, min(historyLength, m.width-80))
+	fmt.Fprintf(&b, "  %-20s %-8s %6s %6s %6s %6s  %s\n", "NAME", "STATUS", "CPU", "MEM", "DISK", "TEMP", "CPU HISTORY")
+	for i, sys := range m.systems {
+		cursor := "  "
+		if i == m.selected {
+			cursor = m.style("> ", bold)
+		}
+		status := fmt.Sprintf("%-8s", sys.Status)
+		status = m.style(status, statusColor(sys.Status))
+		row := fmt.Sprintf("%-20s %s", truncate(sys.Name, 20), status)
+		if sys.Status == "up" {
+			temp := ""
+			if sys.Info.DashboardTemp > 0 {
+				temp = fmt.Sprintf("%.0f°C", sys.Info.DashboardTemp)
+			}
+			row += fmt.Sprintf(" %5.1f%% %5.1f%% %5.1f%% %6s  %s", sys.Info.Cpu, sys.Info.MemPct, sys.Info.DiskPct, temp,
+				Sparkline(tail(m.history[sys.Id].Cpu, sparkWidth), 100))
+		}
+		b.WriteString(cursor + row + "\n")
+	}
+	if len(m.systems) == 0 && m.err == nil {
+		b.WriteString(m.style("  Loading...", faint) + "\n")
+	}
+
+	// selected system details
+	if m.selected < len(m.systems) {
+		sys := m.systems[m.selected]
+		b.WriteString("\n" + m.style(sys.Name, bold) + "\n")
+		if sys.Status == "up" {
+			info := sys.Info
+			fmt.Fprintf(&b, "  %s · %s · %d cores · up %s · load %.2f %.2f %.2f · agent %s\n",
+				info.Hostname, info.CpuModel, info.Cores, formatUptime(info.Uptime),
+				info.LoadAvg[0], info.LoadAvg[1], info.LoadAvg[2], info.AgentVersion)
+			history := m.history[sys.Id]
+			detailWidth := max(10, min(historyLength, m.width-12))
+			fmt.Fprintf(&b, "  CPU %s\n", Sparkline(tail(history.Cpu, detailWidth), 100))
+			fmt.Fprintf(&b, "  MEM %s\n", Sparkline(tail(history.MemPct, detailWidth), 100))
+		} else {
+			b.WriteString(m.style("  "+sys.Status, statusColor(sys.Status)) + "\n")
+		}
+	}
+
+	// active alerts
+	if len(m.alerts) > 0 {
+		names := make(map[string]string, len(m.systems))
+		for _, sys := range m.systems {
+			names[sys.Id] = sys.Name
+		}
+		b.WriteString("\n" + m.style(fmt.Sprintf("Active alerts (%d)", len(m.alerts)), bold) + "\n")
+		for _, alert := range m.alerts {
+			line := fmt.Sprintf("  %s %s", names[alert.System], alert.Name)
+			if alert.Name != "Status" {
+				line += fmt.Sprintf(" above %g", alert.Value)
+			}
+			b.WriteString(m.style(line, red) + "\n")
+		}
+	}
+
+	b.WriteString("\n" + m.style("↑/↓ select · r refresh · q quit", faint) + "\n")
+	return b.String()
+}
+
+// ANSI styles
+const (
+	bold   = "1"
+	faint  = "2"
+	red    = "31"
+	green  = "32"
+	yellow = "33"
+)
+
+// style wraps s in an ANSI style if colors are enabled.
+func (m Model) style(s, code string) string {
+	if !m.color || code == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+func statusColor(status string) string {
+	switch status {
+	case "up":
+		return green
+	case "down":
+		return red
+	default:
+		return yellow
+	}
+}
+
+// truncate shortens s to n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// tail returns the last n values.
+func tail(values []float64, n int) []float64 {
+	if len(values) > n {
+		return values[len(values)-n:]
+	}
+	return values
+}
+
+// formatUptime formats seconds as days or hours.
+func formatUptime(seconds uint64) string {
+	if seconds >= 86400 {
+		return fmt.Sprintf("%dd", seconds/86400)
+	}
+	return fmt.Sprintf("%dh", seconds/3600)
+}
@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Run starts the terminal UI, refreshing data every interval until the user quits.
+func Run(model Model, interval time.Duration, in *os.File, out *os.File) error {
+	if fd := int(in.Fd()); term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, state)
+	}
+	// use the alternate screen and hide the cursor
+	io.WriteString(out, "\x1b[?1049h\x1b[?25l")
+	defer io.WriteString(out, "\x1b[?25h\x1b[?1049l")
+
+	msgs := make(chan Msg)
+	go readKeys(in, msgs)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	run := func(cmd Cmd) {
+		if cmd != nil {
+			go func() { msgs <- cmd() }()
+		}
+	}
+	run(model.Init())
+	width := 0
+	for {
+		if w, _, err := term.GetSize(int(out.Fd())); err == nil && w != width {
+			width = w
+			model, _ = model.Update(ResizeMsg{Width: w})
+		}
+		render(out, model.View())
+
+		var msg Msg
+		select {
+		case msg = <-msgs:
+		case t := <-ticker.C:
+			msg = TickMsg(t)
+		}
+		if _, ok := msg.(quitMsg); ok {
+			return nil
+		}
+		var cmd Cmd
+		model, cmd = model.Update(msg)
+		run(cmd)
+	}
+}
+
+// render clears the screen and writes the view. Raw mode needs explicit carriage returns.
+func render(out io.Writer, view string) {
+	io.WriteString(out, "\x1b[H\x1b[2J"+strings.ReplaceAll(view, "\n", "\r\n"))
+}
+
+// readKeys sends key presses from in as KeyMsg.
+func readKeys(in io.Reader, msgs chan<- Msg) {
+	reader := bufio.NewReader(in)
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			msgs <- quitMsg{}
+			return
+		}
+		switch r {
+		case 3:
+			msgs <- KeyMsg("ctrl+c")
+		case '\r', '\n':
+			msgs <- KeyMsg("enter")
+		case 27:
+			// arrow keys are sent as ESC [ A-D
+			if reader.Buffered() >= 2 {
+				seq := make([]byte, 2)
+				_, _ = io.ReadFull(reader, seq)
+				switch string(seq) {
+				case "[A":
+					msgs <- KeyMsg("up")
+				case "[B":
+					msgs <- KeyMsg("down")
+				}
+				continue
+			}
+			msgs <- KeyMsg("esc")
+		default:
+			msgs <- KeyMsg(string(r))
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package tui
+
+import "strings"
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders values from zero to maximum as a line of block characters.
+func Sparkline(values []float64, maximum float64) string {
+	if maximum <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := int(v / maximum * float64(len(sparkBlocks)-1))
+		i = max(0, min(i, len(sparkBlocks)-1))
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
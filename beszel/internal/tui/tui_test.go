@@ -0,0 +1,155 @@
+//go:build testing
+// +build testing
+
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▄█", Sparkline([]float64{0, 50, 100}, 100))
+	assert.Equal(t, "▁█", Sparkline([]float64{-5, 150}, 100), "values are clamped")
+	assert.Equal(t, "", Sparkline(nil, 100))
+	assert.Equal(t, "", Sparkline([]float64{1}, 0))
+}
+
+func newTestHub(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/collections/users/auth-with-password" {
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["identity"] != "test@example.com" || body["password"] != "password" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"message":"Failed to authenticate."}`))
+				return
+			}
+			w.Write([]byte(`{"token":"test-token"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"Only superusers can perform this action."}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/collections/systems/records":
+			w.Write([]byte(`{"items":[
+				{"id":"sys1","name":"web","status":"up","info":{"cpu":12.5,"mp":40,"dp":51,"h":"web.local","c":4}},
+				{"id":"sys2","name":"db","status":"down","info":{}}
+			]}`))
+		case "/api/beszel/stats":
+			assert.Equal(t, "sys1", r.URL.Query().Get("system"))
+			assert.Equal(t, "system_stats", r.URL.Query().Get("collection"))
+			since, err := time.Parse(time.RFC3339, r.URL.Query().Get("since"))
+			assert.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(-30*time.Minute), since, time.Minute)
+			// oldest first
+			w.Write([]byte(`[{"created":"2025-06-02T07:00:00Z","stats":{"cpu":0,"mp":20}},{"created":"2025-06-02T07:01:00Z","stats":{"cpu":100,"mp":30}}]`))
+		case "/api/collections/alerts/records":
+			assert.Equal(t, "triggered=true", r.URL.Query().Get("filter"))
+			w.Write([]byte(`{"items":[{"system":"sys1","name":"CPU","value":80}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient(t *testing.T) {
+	server := newTestHub(t)
+	client := NewClient(server.URL + "/")
+
+	_, err := client.Systems()
+	assert.ErrorContains(t, err, "403 Only superusers")
+
+	assert.ErrorContains(t, client.Login("test@example.com", "wrong"), "Failed to authenticate")
+	require.NoError(t, client.Login("test@example.com", "password"))
+
+	systems, err := client.Systems()
+	require.NoError(t, err)
+	require.Len(t, systems, 2)
+	assert.Equal(t, "web", systems[0].Name)
+	assert.Equal(t, 12.5, systems[0].Info.Cpu)
+	assert.Equal(t, "web.local", systems[0].Info.Hostname)
+
+	history, err := client.History("sys1", 30)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 100}, history.Cpu, "history is oldest first")
+	assert.Equal(t, []float64{20, 30}, history.MemPct)
+
+	alerts, err := client.Alerts()
+	require.NoError(t, err)
+	assert.Equal(t, []Alert{{System: "sys1", Name: "CPU", Value: 80}}, alerts)
+}
+
+func TestModel(t *testing.T) {
+	server := newTestHub(t)
+	client := NewClient(server.URL)
+	require.NoError(t, client.Login("test@example.com", "password"))
+
+	model := NewModel(client, false)
+	assert.Contains(t, model.View(), "Loading...")
+
+	msg := model.Init()()
+	data, ok := msg.(DataMsg)
+	require.True(t, ok)
+	require.NoError(t, data.Err)
+	assert.NotContains(t, data.History, "sys2", "history is only loaded for systems that are up")
+
+	model, cmd := model.Update(msg)
+	assert.Nil(t, cmd)
+	view := model.View()
+	assert.Contains(t, view, "1 up · 1 down · 0 paused")
+	assert.Contains(t, view, "> web")
+	assert.Contains(t, view, " 12.5%")
+	assert.Contains(t, view, "▁█")
+	assert.Contains(t, view, "Active alerts (1)")
+	assert.Contains(t, view, "web CPU above 80")
+	assert.NotContains(t, view, "\x1b[", "no colors when disabled")
+
+	// selection stays within bounds
+	model, _ = model.Update(KeyMsg("down"))
+	model, _ = model.Update(KeyMsg("j"))
+	assert.Contains(t, model.View(), "> db")
+	model, _ = model.Update(KeyMsg("up"))
+	model, _ = model.Update(KeyMsg("k"))
+	assert.Contains(t, model.View(), "> web")
+
+	// only one fetch runs at a time
+	model, cmd = model.Update(KeyMsg("r"))
+	assert.NotNil(t, cmd)
+	model, cmd = model.Update(TickMsg{})
+	assert.Nil(t, cmd)
+
+	// errors keep the last data
+	model, _ = model.Update(DataMsg{Err: errors.New("connection refused")})
+	view = model.View()
+	assert.Contains(t, view, "Error: connection refused")
+	assert.Contains(t, view, "web")
+
+	_, cmd = model.Update(KeyMsg("q"))
+	require.NotNil(t, cmd)
+	assert.Equal(t, quitMsg{}, cmd())
+}
+
+func TestReadKeys(t *testing.T) {
+	msgs := make(chan Msg, 10)
+	readKeys(strings.NewReader("j\x1b[A\x1b[Bq\x03"), msgs)
+	var keys []Msg
+	for len(msgs) > 0 {
+		keys = append(keys, <-msgs)
+	}
+	assert.Equal(t, []Msg{KeyMsg("j"), KeyMsg("up"), KeyMsg("down"), KeyMsg("q"), KeyMsg("ctrl+c"), quitMsg{}}, keys)
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// adds the Latency alert for pipeline latency of system stats
+func init() {
+	m.Register(func(app core.App) error {
+		collection, field, err := findAlertNameField(app)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(field.Values, "Latency") {
+			field.Values = append(field.Values, "Latency")
+		}
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, field, err := findAlertNameField(app)
+		if err != nil {
+			return err
+		}
+		field.Values = slices.DeleteFunc(field.Values, func(v string) bool { return v == "Latency" })
+		return app.Save(collection)
+	})
+}
+
+// findAlertNameField returns the alerts collection and its name select field
+func findAlertNameField(app core.App) (*core.Collection, *core.SelectField, error) {
+	collection, err := app.FindCollectionByNameOrId("alerts")
+	if err != nil {
+		return nil, nil, err
+	}
+	field, ok := collection.Fields.GetByName("name").(*core.SelectField)
+	if !ok {
+		return nil, nil, errors.New("alerts collection has no name select field")
+	}
+	return collection, field, nil
+}
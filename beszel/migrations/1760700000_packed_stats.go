@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// adds the packed_stats collection for stats kept longer than the UI window
+func init() {
+	m.Register(func(app core.App) error {
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("packed_stats")
+		collection.Fields.Add(
+			&core.RelationField{Name: "system", CollectionId: systems.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.SelectField{Name: "collection", Values: []string{"system_stats", "container_stats"}, MaxSelect: 1, Required: true},
+			&core.SelectField{Name: "type", Values: []string{"1m", "10m", "20m", "120m", "480m"}, MaxSelect: 1, Required: true},
+			&core.DateField{Name: "start_time", Required: true},
+			&core.DateField{Name: "end_time", Required: true},
+			&core.NumberField{Name: "count", OnlyInt: true},
+			&core.JSONField{Name: "data", MaxSize: 50 << 20},
+		)
+		collection.AddIndex("idx_packed_stats_bucket", true, "`system`, `collection`, `type`, `start_time`", "")
+		collection.AddIndex("idx_packed_stats_end_time", false, "`type`, `end_time`", "")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("packed_stats")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// adds the Raid alert for software RAID arrays missing members
+func init() {
+	m.Register(func(app core.App) error {
+		collection, field, err := findAlertNameField(app)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(field.Values, "Raid") {
+			field.Values = append(field.Values, "Raid")
+		}
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, field, err := findAlertNameField(app)
+		if err != nil {
+			return err
+		}
+		field.Values = slices.DeleteFunc(field.Values, func(v string) bool { return v == "Raid" })
+		return app.Save(collection)
+	})
+}
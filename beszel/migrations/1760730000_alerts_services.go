@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// adds the Services alert for watched systemd units in failed state
+func init() {
+	m.Register(func(app core.App) error {
+		collection, field, err := findAlertNameField(app)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(field.Values, "Services") {
+			field.Values = append(field.Values, "Services")
+		}
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, field, err := findAlertNameField(app)
+		if err != nil {
+			return err
+		}
+		field.Values = slices.DeleteFunc(field.Values, func(v string) bool { return v == "Services" })
+		return app.Save(collection)
+	})
+}
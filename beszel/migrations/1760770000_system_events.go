@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the system_events collection for events reported to the agent's event API
+func init() {
+	m.Register(func(app core.App) error {
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("system_events")
+		collection.ListRule = types.Pointer(`@request.auth.id != "" && system.users.id ?= @request.auth.id`)
+		collection.Fields.Add(
+			&core.RelationField{Name: "system", CollectionId: systems.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.TextField{Name: "name", Max: 64, Required: true},
+			&core.TextField{Name: "message", Max: 256},
+			// null if the event has no value
+			&core.JSONField{Name: "value"},
+			&core.DateField{Name: "time", Required: true},
+		)
+		collection.AddIndex("idx_system_events_time", false, "`system`, `time`", "")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("system_events")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the sensor_rollups collection of daily sensor min, max, average and rate of change
+func init() {
+	m.Register(func(app core.App) error {
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("sensor_rollups")
+		collection.ListRule = types.Pointer(`@request.auth.id != "" && system.users.id ?= @request.auth.id`)
+		collection.Fields.Add(
+			&core.RelationField{Name: "system", CollectionId: systems.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			// temperature or generic
+			&core.TextField{Name: "type", Required: true},
+			&core.TextField{Name: "sensor", Required: true},
+			&core.TextField{Name: "unit"},
+			// UTC date, e.g. 2025-01-02
+			&core.TextField{Name: "day", Required: true},
+			&core.NumberField{Name: "min"},
+			&core.DateField{Name: "min_at"},
+			&core.NumberField{Name: "max"},
+			&core.DateField{Name: "max_at"},
+			&core.NumberField{Name: "avg"},
+			&core.NumberField{Name: "count", OnlyInt: true},
+			// fastest rise per minute between consecutive readings
+			&core.NumberField{Name: "max_rate"},
+			&core.DateField{Name: "max_rate_at"},
+			// latest reading, for the rate of change
+			&core.NumberField{Name: "last"},
+			&core.DateField{Name: "last_at"},
+		)
+		collection.AddIndex("idx_sensor_rollups_day", true, "`system`, `day`, `type`, `sensor`", "")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sensor_rollups")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// adds the target field of alerts, which limits the Containers alert to a Compose project
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("alerts")
+		if err != nil {
+			return err
+		}
+		collection.Fields.Add(&core.TextField{Name: "target", Max: 128})
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("alerts")
+		if err != nil {
+			return err
+		}
+		collection.Fields.RemoveByName("target")
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the share_links collection for read-only guest links to a system
+func init() {
+	m.Register(func(app core.App) error {
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("share_links")
+		ownerRule := `@request.auth.id != "" && user = @request.auth.id`
+		collection.ListRule = types.Pointer(ownerRule)
+		collection.ViewRule = types.Pointer(ownerRule)
+		collection.CreateRule = types.Pointer(ownerRule + ` && system.users.id ?= @request.auth.id && @request.auth.role != "readonly"`)
+		collection.DeleteRule = types.Pointer(ownerRule)
+		collection.Fields.Add(
+			&core.RelationField{Name: "user", CollectionId: users.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.RelationField{Name: "system", CollectionId: systems.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.TextField{Name: "token", Min: 40, Max: 40, Pattern: `^[a-zA-Z0-9]+$`, AutogeneratePattern: `[a-zA-Z0-9]{40}`, Required: true},
+			&core.TextField{Name: "name", Max: 64},
+			// metric groups shown by the link, all if empty
+			&core.JSONField{Name: "metrics", MaxSize: 1024},
+			&core.DateField{Name: "expires", Required: true},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		collection.AddIndex("idx_share_links_token", true, "`token`", "")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("share_links")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the enrollment_tokens collection for one-time tokens that agents use to
+// register themselves, and the labels field of systems
+func init() {
+	m.Register(func(app core.App) error {
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		systems.Fields.Add(&core.JSONField{Name: "labels", MaxSize: 2048})
+		if err := app.Save(systems); err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("enrollment_tokens")
+		ownerRule := `@request.auth.id != "" && user = @request.auth.id`
+		collection.ListRule = types.Pointer(ownerRule)
+		collection.ViewRule = types.Pointer(ownerRule)
+		collection.CreateRule = types.Pointer(ownerRule + ` && @request.auth.role != "readonly"`)
+		collection.DeleteRule = types.Pointer(ownerRule)
+		collection.Fields.Add(
+			&core.RelationField{Name: "user", CollectionId: users.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.TextField{Name: "token", Min: 40, Max: 40, Pattern: `^[a-zA-Z0-9]+$`, AutogeneratePattern: `[a-zA-Z0-9]{40}`, Required: true},
+			&core.TextField{Name: "name", Max: 64},
+			// labels added to the registered system
+			&core.JSONField{Name: "labels", MaxSize: 2048},
+			&core.DateField{Name: "expires", Required: true},
+			// set when an agent registers with the token
+			&core.DateField{Name: "used"},
+			&core.RelationField{Name: "system", CollectionId: systems.Id, MaxSelect: 1},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		collection.AddIndex("idx_enrollment_tokens_token", true, "`token`", "")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		if collection, err := app.FindCollectionByNameOrId("enrollment_tokens"); err == nil {
+			if err := app.Delete(collection); err != nil {
+				return err
+			}
+		}
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		systems.Fields.RemoveByName("labels")
+		return app.Save(systems)
+	})
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the status_pages collection for public status pages of selected systems
+func init() {
+	m.Register(func(app core.App) error {
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("status_pages")
+		ownerRule := `@request.auth.id != "" && user = @request.auth.id`
+		collection.ListRule = types.Pointer(ownerRule)
+		collection.ViewRule = types.Pointer(ownerRule)
+		collection.CreateRule = types.Pointer(ownerRule + ` && @request.auth.role != "readonly"`)
+		collection.UpdateRule = types.Pointer(ownerRule + ` && @request.auth.role != "readonly" && @request.body.user:isset = false`)
+		collection.DeleteRule = types.Pointer(ownerRule)
+		collection.Fields.Add(
+			&core.RelationField{Name: "user", CollectionId: users.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.TextField{Name: "name", Max: 64, Required: true},
+			// path of the page, /status/<slug>
+			&core.TextField{Name: "slug", Min: 1, Max: 64, Pattern: `^[a-z0-9][a-z0-9-]*$`, Required: true},
+			// optional host that redirects to the page, e.g. status.example.com
+			&core.TextField{Name: "domain", Max: 253, Pattern: `^[a-z0-9.-]+$`},
+			&core.RelationField{Name: "systems", CollectionId: systems.Id, MaxSelect: 100, Required: true},
+			// sensor names shown for each system
+			&core.JSONField{Name: "sensors", MaxSize: 4096},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		collection.AddIndex("idx_status_pages_slug", true, "`slug`", "")
+		collection.AddIndex("idx_status_pages_domain", true, "`domain`", "`domain` != ''")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("status_pages")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the alert_rules collection for alerts with compound conditions
+func init() {
+	m.Register(func(app core.App) error {
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("alert_rules")
+		ownerRule := `@request.auth.id != "" && user = @request.auth.id`
+		collection.ListRule = types.Pointer(ownerRule)
+		collection.ViewRule = types.Pointer(ownerRule)
+		collection.CreateRule = types.Pointer(ownerRule + ` && @request.auth.role != "readonly"`)
+		collection.UpdateRule = types.Pointer(ownerRule + ` && @request.auth.role != "readonly" && @request.body.user:isset = false`)
+		collection.DeleteRule = types.Pointer(ownerRule)
+		collection.Fields.Add(
+			&core.RelationField{Name: "user", CollectionId: users.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.TextField{Name: "name", Max: 64, Required: true},
+			// conditions that trigger the rule, joined with AND (all) or OR (any)
+			&core.SelectField{Name: "match", Values: []string{"all", "any"}, MaxSelect: 1},
+			&core.JSONField{Name: "conditions", MaxSize: 4096, Required: true},
+			// minutes the conditions must hold to trigger
+			&core.NumberField{Name: "duration", Min: types.Pointer(1.0), Max: types.Pointer(60.0), OnlyInt: true},
+			// conditions that resolve the rule, the trigger conditions not holding if empty
+			&core.SelectField{Name: "resolve_match", Values: []string{"all", "any"}, MaxSelect: 1},
+			&core.JSONField{Name: "resolve_conditions", MaxSize: 4096},
+			// minutes the resolve conditions must hold to resolve
+			&core.NumberField{Name: "resolve_duration", Min: types.Pointer(1.0), Max: types.Pointer(60.0), OnlyInt: true},
+			// systems the rule applies to, and labels of other systems it applies to.
+			// The rule applies to all of the user's systems if both are empty.
+			&core.RelationField{Name: "systems", CollectionId: systems.Id, MaxSelect: 1000},
+			&core.JSONField{Name: "labels", MaxSize: 2048},
+			// IDs of the systems the rule is triggered for
+			&core.JSONField{Name: "triggered", MaxSize: 1 << 16},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("alert_rules")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the maintenance_windows collection for silencing alerts
+func init() {
+	m.Register(func(app core.App) error {
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("maintenance_windows")
+		ownerRule := `@request.auth.id != "" && user = @request.auth.id`
+		collection.ListRule = types.Pointer(ownerRule)
+		collection.ViewRule = types.Pointer(ownerRule)
+		collection.CreateRule = types.Pointer(ownerRule + ` && @request.auth.role != "readonly"`)
+		collection.UpdateRule = types.Pointer(ownerRule + ` && @request.auth.role != "readonly" && @request.body.user:isset = false`)
+		collection.DeleteRule = types.Pointer(ownerRule + ` && @request.auth.role != "readonly"`)
+		collection.Fields.Add(
+			&core.RelationField{Name: "user", CollectionId: users.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.TextField{Name: "name", Max: 64, Required: true},
+			// suppress notifications, or send them marked as maintenance
+			&core.SelectField{Name: "mode", Values: []string{"suppress", "mark"}, MaxSelect: 1, Required: true},
+			// one-off window
+			&core.DateField{Name: "start"},
+			&core.DateField{Name: "end"},
+			// recurring window starting at the times of a cron expression
+			&core.TextField{Name: "schedule", Max: 128},
+			// minutes each recurring window lasts
+			&core.NumberField{Name: "duration", Min: types.Pointer(1.0), Max: types.Pointer(10080.0), OnlyInt: true},
+			// IANA time zone of the schedule, UTC if empty
+			&core.TextField{Name: "timezone", Max: 64},
+			// systems the window applies to, and labels of other systems it applies to.
+			// The window applies to all of the user's systems if both are empty.
+			&core.RelationField{Name: "systems", CollectionId: systems.Id, MaxSelect: 1000},
+			&core.JSONField{Name: "labels", MaxSize: 2048},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		collection.AddIndex("idx_maintenance_windows_user", false, "user", "")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("maintenance_windows")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
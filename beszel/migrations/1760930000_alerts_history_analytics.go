@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// adds when alerts were acknowledged and how long they lasted to the alert
+// history, and sets the duration of resolved alerts
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("alerts_history")
+		if err != nil {
+			return err
+		}
+		collection.Fields.Add(&core.DateField{Name: "acknowledged"})
+		collection.Fields.Add(&core.NumberField{Name: "duration", OnlyInt: true})
+		collection.AddIndex("idx_alerts_history_user_created", false, "`user`, `created`", "")
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+		_, err = app.DB().NewQuery(
+			"UPDATE alerts_history SET duration = CAST(ROUND((julianday(resolved) - julianday(created)) * 86400) AS INTEGER) WHERE resolved != ''",
+		).Execute()
+		return err
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("alerts_history")
+		if err != nil {
+			return err
+		}
+		collection.Fields.RemoveByName("acknowledged")
+		collection.Fields.RemoveByName("duration")
+		collection.RemoveIndex("idx_alerts_history_user_created")
+		return app.Save(collection)
+	})
+}
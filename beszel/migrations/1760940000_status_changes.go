@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"slices"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the status_changes collection for system uptime, starting with the
+// current status of each system, and the Uptime alert for SLA targets
+func init() {
+	m.Register(func(app core.App) error {
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("status_changes")
+		collection.ListRule = types.Pointer(`@request.auth.id != "" && system.users.id ?= @request.auth.id`)
+		collection.Fields.Add(
+			&core.RelationField{Name: "system", CollectionId: systems.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.TextField{Name: "status", Max: 16, Required: true},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		collection.AddIndex("idx_status_changes_created", false, "`system`, `created`", "")
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+		systemRecords, err := app.FindAllRecords("systems", dbx.In("status", "up", "down"))
+		if err != nil {
+			return err
+		}
+		for _, systemRecord := range systemRecords {
+			record := core.NewRecord(collection)
+			record.Set("system", systemRecord.Id)
+			record.Set("status", systemRecord.GetString("status"))
+			if err := app.Save(record); err != nil {
+				return err
+			}
+		}
+
+		alerts, field, err := findAlertNameField(app)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(field.Values, "Uptime") {
+			field.Values = append(field.Values, "Uptime")
+		}
+		return app.Save(alerts)
+	}, func(app core.App) error {
+		alerts, field, err := findAlertNameField(app)
+		if err != nil {
+			return err
+		}
+		field.Values = slices.DeleteFunc(field.Values, func(v string) bool { return v == "Uptime" })
+		if err := app.Save(alerts); err != nil {
+			return err
+		}
+		collection, err := app.FindCollectionByNameOrId("status_changes")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the api_keys collection for scoped keys that authenticate requests to
+// the /api/v1 REST API
+func init() {
+	m.Register(func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("api_keys")
+		ownerRule := `@request.auth.id != "" && user = @request.auth.id`
+		collection.ListRule = types.Pointer(ownerRule)
+		collection.ViewRule = types.Pointer(ownerRule)
+		collection.CreateRule = types.Pointer(ownerRule)
+		collection.DeleteRule = types.Pointer(ownerRule)
+		collection.Fields.Add(
+			&core.RelationField{Name: "user", CollectionId: users.Id, CascadeDelete: true, MaxSelect: 1, Required: true},
+			&core.TextField{Name: "token", Min: 40, Max: 40, Pattern: `^[a-zA-Z0-9]+$`, AutogeneratePattern: `[a-zA-Z0-9]{40}`, Required: true},
+			&core.TextField{Name: "name", Max: 64},
+			// scopes of the key, e.g. ["systems:read", "stats:read"]
+			&core.JSONField{Name: "scopes", MaxSize: 1024},
+			// never expires if empty
+			&core.DateField{Name: "expires"},
+			// updated at most once a minute
+			&core.DateField{Name: "last_used"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		collection.AddIndex("idx_api_keys_token", true, "`token`", "")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("api_keys")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,99 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	systemUsersRule = `system.users.id ?= @request.auth.id`
+	// members of the system's team have the same access as its users
+	systemUsersOrTeamRule = `(system.users.id ?= @request.auth.id || system.team.members.id ?= @request.auth.id)`
+)
+
+// adds the teams collection and the team of systems, so members of a team can
+// access its systems, and gives team members access to the records of their
+// systems in other collections
+func init() {
+	m.Register(func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("teams")
+		adminRule := `@request.auth.role = "admin"`
+		memberRule := `@request.auth.id != "" && (members.id ?= @request.auth.id || ` + adminRule + `)`
+		collection.ListRule = types.Pointer(memberRule)
+		collection.ViewRule = types.Pointer(memberRule)
+		collection.CreateRule = types.Pointer(adminRule)
+		collection.UpdateRule = types.Pointer(`@request.auth.id != "" && (admins.id ?= @request.auth.id || ` + adminRule + `)`)
+		collection.DeleteRule = types.Pointer(adminRule)
+		collection.Fields.Add(
+			&core.TextField{Name: "name", Max: 64, Required: true},
+			&core.RelationField{Name: "members", CollectionId: users.Id, MaxSelect: 1000},
+			// members who can change the team, and are always members
+			&core.RelationField{Name: "admins", CollectionId: users.Id, MaxSelect: 1000},
+			// notification channels that get the alerts of the team's systems
+			&core.JSONField{Name: "emails", MaxSize: 10000},
+			&core.JSONField{Name: "webhooks", MaxSize: 10000},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		systems.Fields.Add(&core.RelationField{Name: "team", CollectionId: collection.Id, MaxSelect: 1})
+		if err := app.Save(systems); err != nil {
+			return err
+		}
+		return replaceRules(app, systemUsersRule, systemUsersOrTeamRule)
+	}, func(app core.App) error {
+		if err := replaceRules(app, systemUsersOrTeamRule, systemUsersRule); err != nil {
+			return err
+		}
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		systems.Fields.RemoveByName("team")
+		if err := app.Save(systems); err != nil {
+			return err
+		}
+		collection, err := app.FindCollectionByNameOrId("teams")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
+
+// replaceRules replaces part of the API rules of all collections
+func replaceRules(app core.App, old, replacement string) error {
+	collections, err := app.FindAllCollections()
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		changed := false
+		for _, rule := range []*string{collection.ListRule, collection.ViewRule, collection.CreateRule, collection.UpdateRule, collection.DeleteRule} {
+			if rule != nil && strings.Contains(*rule, old) {
+				*rule = strings.ReplaceAll(*rule, old, replacement)
+				changed = true
+			}
+		}
+		if changed {
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	systemTeamMembersRule = `system.team.members.id ?= @request.auth.id`
+	// operators of the system's team can view its records, but not change them
+	systemTeamMembersOrOperatorsRule = `system.team.members.id ?= @request.auth.id || system.team.operators.id ?= @request.auth.id`
+)
+
+// adds operators to teams, who can view the team's systems and acknowledge
+// their alerts without changing them, who acknowledged alerts, and the
+// alert_changes collection, an audit of changes to alert thresholds
+func init() {
+	m.Register(func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		teams, err := app.FindCollectionByNameOrId("teams")
+		if err != nil {
+			return err
+		}
+		teams.Fields.Add(&core.RelationField{Name: "operators", CollectionId: users.Id, MaxSelect: 1000})
+		teamsReadRule := `@request.auth.id != "" && (members.id ?= @request.auth.id || operators.id ?= @request.auth.id || @request.auth.role = "admin")`
+		teams.ListRule = types.Pointer(teamsReadRule)
+		teams.ViewRule = types.Pointer(teamsReadRule)
+		if err := app.Save(teams); err != nil {
+			return err
+		}
+		if err := replaceReadRules(app, systemTeamMembersRule, systemTeamMembersOrOperatorsRule); err != nil {
+			return err
+		}
+
+		history, err := app.FindCollectionByNameOrId("alerts_history")
+		if err != nil {
+			return err
+		}
+		history.Fields.Add(&core.RelationField{Name: "acknowledged_by", CollectionId: users.Id, MaxSelect: 1})
+		historyReadRule := `@request.auth.id != "" && (user.id = @request.auth.id || system.team.operators.id ?= @request.auth.id)`
+		history.ListRule = types.Pointer(historyReadRule)
+		history.ViewRule = types.Pointer(historyReadRule)
+		if err := app.Save(history); err != nil {
+			return err
+		}
+
+		systems, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		collection := core.NewBaseCollection("alert_changes")
+		readRule := `@request.auth.id != "" && (user = @request.auth.id || system.users.id ?= @request.auth.id || ` +
+			systemTeamMembersOrOperatorsRule + ` || @request.auth.role = "admin")`
+		collection.ListRule = types.Pointer(readRule)
+		collection.ViewRule = types.Pointer(readRule)
+		collection.Fields.Add(
+			// the user of the alert, who is the only user who can change it
+			&core.RelationField{Name: "user", CollectionId: users.Id, MaxSelect: 1},
+			// email of the user when the alert was changed, as users can't view other users
+			&core.TextField{Name: "email", Max: 255},
+			&core.RelationField{Name: "system", CollectionId: systems.Id, MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.TextField{Name: "alert", Max: 15},
+			&core.TextField{Name: "name", Max: 100, Required: true},
+			&core.SelectField{Name: "action", Values: []string{"create", "update", "delete"}, MaxSelect: 1, Required: true},
+			&core.NumberField{Name: "old_value"},
+			&core.NumberField{Name: "value"},
+			&core.NumberField{Name: "old_min", OnlyInt: true},
+			&core.NumberField{Name: "min", OnlyInt: true},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		collection.AddIndex("idx_alert_changes_system_created", false, "`system`, `created`", "")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		if collection, err := app.FindCollectionByNameOrId("alert_changes"); err == nil {
+			if err := app.Delete(collection); err != nil {
+				return err
+			}
+		}
+		history, err := app.FindCollectionByNameOrId("alerts_history")
+		if err != nil {
+			return err
+		}
+		history.Fields.RemoveByName("acknowledged_by")
+		historyReadRule := `@request.auth.id != "" && user.id = @request.auth.id`
+		history.ListRule = types.Pointer(historyReadRule)
+		history.ViewRule = types.Pointer(historyReadRule)
+		if err := app.Save(history); err != nil {
+			return err
+		}
+		if err := replaceReadRules(app, systemTeamMembersOrOperatorsRule, systemTeamMembersRule); err != nil {
+			return err
+		}
+		teams, err := app.FindCollectionByNameOrId("teams")
+		if err != nil {
+			return err
+		}
+		teams.Fields.RemoveByName("operators")
+		teamsReadRule := `@request.auth.id != "" && (members.id ?= @request.auth.id || @request.auth.role = "admin")`
+		teams.ListRule = types.Pointer(teamsReadRule)
+		teams.ViewRule = types.Pointer(teamsReadRule)
+		return app.Save(teams)
+	})
+}
+
+// replaceReadRules replaces part of the list and view rules of all collections
+func replaceReadRules(app core.App, old, replacement string) error {
+	collections, err := app.FindAllCollections()
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		changed := false
+		for _, rule := range []*string{collection.ListRule, collection.ViewRule} {
+			if rule != nil && strings.Contains(*rule, old) {
+				*rule = strings.ReplaceAll(*rule, old, replacement)
+				changed = true
+			}
+		}
+		if changed {
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
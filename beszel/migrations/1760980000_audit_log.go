@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// adds the audit_log collection, which records who changed what in the hub.
+// Only admins can read it, and only the hub writes it.
+func init() {
+	m.Register(func(app core.App) error {
+		collection := core.NewBaseCollection("audit_log")
+		adminRule := `@request.auth.role = "admin"`
+		collection.ListRule = types.Pointer(adminRule)
+		collection.ViewRule = types.Pointer(adminRule)
+		collection.Fields.Add(
+			// id and email of the user or superuser, empty for anonymous requests
+			&core.TextField{Name: "actor", Max: 15},
+			&core.TextField{Name: "email", Max: 255},
+			// e.g. systems.create, users.login or alerts.acknowledge
+			&core.TextField{Name: "action", Max: 64, Required: true},
+			// collection, id and name of the changed record, if any
+			&core.TextField{Name: "collection", Max: 64},
+			&core.TextField{Name: "record", Max: 15},
+			&core.TextField{Name: "name", Max: 255},
+			// changed fields, auth method or request path, depending on the action
+			&core.JSONField{Name: "details", MaxSize: 20000},
+			&core.TextField{Name: "ip", Max: 64},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		collection.AddIndex("idx_audit_log_created", false, "created", "")
+		collection.AddIndex("idx_audit_log_actor_created", false, "actor, created", "")
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("audit_log")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// adds the ingest field of systems, set for systems that only receive stats
+// from the ingest API and aren't polled
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		collection.Fields.Add(&core.BoolField{Name: "ingest"})
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("systems")
+		if err != nil {
+			return err
+		}
+		collection.Fields.RemoveByName("ingest")
+		return app.Save(collection)
+	})
+}
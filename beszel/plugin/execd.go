@@ -0,0 +1,168 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default time an execd collector's last batch is used for before it's
+// reported as stale
+const DefaultExecdTimeout = time.Minute
+
+// Delays before restarting an execd process that exited. The delay doubles
+// after each quick exit and resets once a process has run for maxBackoff.
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+var errNoBatch = errors.New("no metrics received yet")
+
+// execdCollector runs a long-lived process that prints a JSON object of
+// metrics on each line of its output, like telegraf's execd input. The process
+// is restarted if it exits.
+type execdCollector struct {
+	name       string
+	path       string
+	timeout    time.Duration // max age of the last batch
+	minBackoff time.Duration
+
+	startOnce sync.Once
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	closed    bool
+	metrics   map[string]float64
+	updated   time.Time // time of the last batch
+	err       error     // last error of the process
+}
+
+// NewExecd returns a collector that starts an executable on its first
+// collection and keeps it running. Each line the executable prints replaces
+// the collector's metrics. If name is empty, the name of the file without its
+// extension is used. A zero timeout uses DefaultExecdTimeout.
+func NewExecd(name, path string, timeout time.Duration) Collector {
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if timeout <= 0 {
+		timeout = DefaultExecdTimeout
+	}
+	return &execdCollector{name: name, path: path, timeout: timeout, minBackoff: minBackoff}
+}
+
+func (c *execdCollector) Name() string {
+	return c.name
+}
+
+// Collect returns the metrics of the last batch, or an error if there wasn't
+// one within the timeout.
+func (c *execdCollector) Collect(ctx context.Context) (map[string]float64, error) {
+	c.startOnce.Do(func() { go c.supervise() })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.updated.IsZero() && c.err != nil:
+		return nil, c.err
+	case c.updated.IsZero():
+		return nil, errNoBatch
+	case time.Since(c.updated) > c.timeout:
+		err := fmt.Errorf("no metrics for %s", time.Since(c.updated).Round(time.Second))
+		if c.err != nil {
+			err = fmt.Errorf("%w: %w", err, c.err)
+		}
+		return nil, err
+	}
+	return maps.Clone(c.metrics), nil
+}
+
+// Close stops the process and doesn't restart it.
+func (c *execdCollector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.cmd != nil && c.cmd.Process != nil {
+		return c.cmd.Process.Kill()
+	}
+	return nil
+}
+
+// supervise runs the process until the collector is closed, restarting it
+// with a backoff when it exits.
+func (c *execdCollector) supervise() {
+	backoff := c.minBackoff
+	for {
+		started := time.Now()
+		err := c.run()
+		c.mu.Lock()
+		closed := c.closed
+		c.err = err
+		c.mu.Unlock()
+		if closed {
+			return
+		}
+		slog.Warn("Plugin exited", "plugin", c.name, "err", err, "restart", backoff)
+		if time.Since(started) >= maxBackoff {
+			backoff = c.minBackoff
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// run starts the process and reads batches until it exits.
+func (c *execdCollector) run() error {
+	cmd := exec.Command(c.path)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	err = cmd.Start()
+	c.cmd = cmd
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.path, err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		metrics, err := ParseOutput(line)
+		c.mu.Lock()
+		if err != nil {
+			c.err = err
+		} else {
+			c.metrics, c.updated, c.err = metrics, time.Now(), nil
+		}
+		c.mu.Unlock()
+	}
+	if err := scanner.Err(); err != nil {
+		// e.g. a line over 1 MB, so the output can't be read further
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("%s: %w", c.path, err)
+	}
+	// Wait closes stdout, so drain it first
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %w", c.path, err)
+	}
+	return fmt.Errorf("%s: exited", c.path)
+}
@@ -0,0 +1,73 @@
+//go:build testing
+// +build testing
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestExecd writes a shell script and returns an execd collector for it
+// that restarts quickly.
+func newTestExecd(t *testing.T, script string, timeout time.Duration) *execdCollector {
+	path := filepath.Join(t.TempDir(), "test.sh")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	collector := NewExecd("", path, timeout).(*execdCollector)
+	collector.minBackoff = 10 * time.Millisecond
+	t.Cleanup(func() { collector.Close() })
+	return collector
+}
+
+func TestExecdCollector(t *testing.T) {
+	collector := newTestExecd(t, `echo '{"depth": 1}'; echo '{"depth": 2, "lag": 0.5}'; exec sleep 10`, 0)
+	assert.Equal(t, "test", collector.Name())
+
+	metrics, err := collector.Collect(context.Background())
+	assert.Nil(t, metrics)
+	if err != nil {
+		assert.ErrorIs(t, err, errNoBatch)
+	}
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		metrics, err := collector.Collect(context.Background())
+		assert.NoError(c, err)
+		assert.Equal(c, map[string]float64{"depth": 2, "lag": 0.5}, metrics)
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestExecdCollectorRestarts(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "count")
+	collector := newTestExecd(t, `echo x >> `+counter+`; echo "{\"runs\": $(wc -l < `+counter+`)}"`, 0)
+	collector.Collect(context.Background())
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		metrics, err := collector.Collect(context.Background())
+		assert.NoError(c, err)
+		assert.GreaterOrEqual(c, metrics["runs"], 3.0)
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestExecdCollectorErrors(t *testing.T) {
+	collector := newTestExecd(t, `echo 'depth=1'; exec sleep 10`, 0)
+	collector.Collect(context.Background())
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		_, err := collector.Collect(context.Background())
+		assert.ErrorContains(c, err, "invalid plugin output")
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// stale after the timeout
+	collector = newTestExecd(t, `echo '{"depth": 1}'; exec sleep 10`, 50*time.Millisecond)
+	collector.Collect(context.Background())
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		_, err := collector.Collect(context.Background())
+		assert.NoError(c, err)
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	_, err := collector.Collect(context.Background())
+	assert.ErrorContains(t, err, "no metrics for")
+}
@@ -0,0 +1,108 @@
+// Package plugin lets custom builds of the agent and external executables add
+// metrics without changing the agent. Metrics are reported to the hub as
+// generic sensors named <collector>_<metric>.
+//
+// A Go collector registers itself in an init function of a package imported
+// by the custom build's main package:
+//
+//	func init() {
+//		plugin.Register(myCollector{})
+//	}
+//
+// An executable is added with the PLUGINS env var and prints a JSON object of
+// metric names and values each time it's run, e.g. {"queue": 12, "lag": 0.4}.
+// A long-running executable is added with the EXECD_PLUGINS env var instead
+// and prints such an object on a line whenever it has new values.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Collector collects metrics each time the agent gathers stats.
+type Collector interface {
+	// Name is the unique name of the collector, used as the prefix of its metrics.
+	Name() string
+	// Collect returns the current value of each metric.
+	Collect(ctx context.Context) (map[string]float64, error)
+}
+
+var (
+	mu         sync.RWMutex
+	collectors = make(map[string]Collector)
+)
+
+// Register makes a collector available to the agent. It panics if the name is
+// empty or already registered, like database/sql.Register.
+func Register(collector Collector) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := collector.Name()
+	if name == "" {
+		panic("plugin: Register collector with empty name")
+	}
+	if _, dup := collectors[name]; dup {
+		panic("plugin: Register called twice for collector " + name)
+	}
+	collectors[name] = collector
+}
+
+// Collectors returns the registered collectors sorted by name.
+func Collectors() []Collector {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	result := make([]Collector, len(names))
+	for i, name := range names {
+		result[i] = collectors[name]
+	}
+	return result
+}
+
+// execCollector runs an executable and parses the JSON object it prints.
+type execCollector struct {
+	name string
+	path string
+}
+
+// NewExec returns a collector that runs an executable. If name is empty, the
+// name of the file without its extension is used.
+func NewExec(name, path string) Collector {
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &execCollector{name: name, path: path}
+}
+
+func (c *execCollector) Name() string {
+	return c.name
+}
+
+func (c *execCollector) Collect(ctx context.Context) (map[string]float64, error) {
+	output, err := exec.CommandContext(ctx, c.path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.path, err)
+	}
+	return ParseOutput(output)
+}
+
+// ParseOutput parses the JSON object of metric names and numbers printed by
+// an executable. Values that aren't numbers are an error.
+func ParseOutput(output []byte) (map[string]float64, error) {
+	var metrics map[string]float64
+	if err := json.Unmarshal(output, &metrics); err != nil {
+		return nil, fmt.Errorf("invalid plugin output: %w", err)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,55 @@
+//go:build testing
+// +build testing
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type namedCollector string
+
+func (c namedCollector) Name() string { return string(c) }
+
+func (c namedCollector) Collect(context.Context) (map[string]float64, error) { return nil, nil }
+
+func TestRegister(t *testing.T) {
+	t.Cleanup(func() { collectors = make(map[string]Collector) })
+	Register(namedCollector("zeta"))
+	Register(namedCollector("alpha"))
+	assert.Equal(t, []Collector{namedCollector("alpha"), namedCollector("zeta")}, Collectors())
+
+	assert.Panics(t, func() { Register(namedCollector("alpha")) })
+	assert.Panics(t, func() { Register(namedCollector("")) })
+}
+
+func TestParseOutput(t *testing.T) {
+	metrics, err := ParseOutput([]byte(`{"queue": 12, "lag": 0.4}` + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"queue": 12, "lag": 0.4}, metrics)
+
+	_, err = ParseOutput([]byte(`{"state": "ok"}`))
+	assert.Error(t, err)
+	_, err = ParseOutput([]byte("queue=12"))
+	assert.Error(t, err)
+}
+
+func TestExecCollector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.sh")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho '{\"depth\": 3}'\n"), 0755))
+
+	collector := NewExec("", path)
+	assert.Equal(t, "queue", collector.Name())
+	metrics, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"depth": 3}, metrics)
+
+	_, err = NewExec("missing", filepath.Join(t.TempDir(), "missing")).Collect(context.Background())
+	assert.Error(t, err)
+}
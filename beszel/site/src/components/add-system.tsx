@@ -11,15 +11,25 @@ import {
 	DialogTrigger,
 } from "@/components/ui/dialog"
 import { Tabs, TabsContent, TabsList, TabsTrigger } from "@/components/ui/tabs"
+import { Checkbox } from "@/components/ui/checkbox"
 import { Input } from "@/components/ui/input"
 import { Label } from "@/components/ui/label"
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
 import { $publicKey, pb } from "@/lib/stores"
-import { cn, generateToken, isReadOnlyUser, tokenMap, useLocalStorage } from "@/lib/utils"
+import {
+	cn,
+	formatLabels,
+	generateToken,
+	isReadOnlyUser,
+	parseLabels,
+	tokenMap,
+	useLocalStorage,
+} from "@/lib/utils"
 import { useStore } from "@nanostores/react"
 import { ChevronDownIcon, ExternalLinkIcon, PlusIcon } from "lucide-react"
 import { memo, useEffect, useRef, useState } from "react"
 import { $router, basePath, Link, navigate } from "./router"
-import { SystemRecord } from "@/types"
+import { SystemRecord, TeamRecord } from "@/types"
 import { AppleIcon, DockerIcon, TuxIcon, WindowsIcon } from "./ui/icons"
 import { InputCopy } from "./ui/input-copy"
 import { getPagePath } from "@nanostores/router"
@@ -75,8 +85,17 @@ export const SystemDialog = ({ setOpen, system }: { setOpen: (open: boolean) =>
 	const port = useRef<HTMLInputElement>(null)
 	const [hostValue, setHostValue] = useState(system?.host ?? "")
 	const isUnixSocket = hostValue.startsWith("/")
+	const [ingest, setIngest] = useState(system?.ingest ?? false)
 	const [tab, setTab] = useLocalStorage("as-tab", "docker")
 	const [token, setToken] = useState(system?.token ?? "")
+	const [teams, setTeams] = useState([] as TeamRecord[])
+
+	useEffect(() => {
+		pb.collection<TeamRecord>("teams")
+			.getFullList({ fields: "id,name", sort: "name" })
+			.then(setTeams)
+			.catch(() => {})
+	}, [])
 
 	useEffect(() => {
 		;(async () => {
@@ -101,7 +120,15 @@ export const SystemDialog = ({ setOpen, system }: { setOpen: (open: boolean) =>
 		e.preventDefault()
 		const formData = new FormData(e.target as HTMLFormElement)
 		const data = Object.fromEntries(formData) as Record<string, any>
-		data.users = pb.authStore.record!.id
+		// keep the users of existing systems, which may be shared through a team
+		if (!system) {
+			data.users = pb.authStore.record!.id
+		}
+		data.labels = parseLabels(data.labels ?? "")
+		data.ingest = ingest
+		if (data.team === "none") {
+			data.team = ""
+		}
 		try {
 			setOpen(false)
 			if (system) {
@@ -127,6 +154,7 @@ export const SystemDialog = ({ setOpen, system }: { setOpen: (open: boolean) =>
 			className="w-[90%] sm:w-auto sm:ns-dialog max-w-full rounded-lg"
 			onCloseAutoFocus={() => {
 				setHostValue(system?.host ?? "")
+				setIngest(system?.ingest ?? false)
 			}}
 		>
 			<Tabs defaultValue={tab} onValueChange={setTab}>
@@ -204,6 +232,42 @@ export const SystemDialog = ({ setOpen, system }: { setOpen: (open: boolean) =>
 							required={!isUnixSocket}
 							className={cn(isUnixSocket && "hidden")}
 						/>
+						<Label htmlFor="labels" className="xs:text-end">
+							<Trans>Labels</Trans>
+						</Label>
+						<Input
+							id="labels"
+							name="labels"
+							defaultValue={formatLabels(system?.labels)}
+							placeholder="env=prod, site=berlin"
+						/>
+						<span className="hidden xs:block" />
+						<label htmlFor="ingest" className="flex items-center gap-2 text-sm cursor-pointer">
+							<Checkbox id="ingest" checked={ingest} onCheckedChange={(checked) => setIngest(!!checked)} />
+							<Trans>Only receives stats from the ingest API</Trans>
+						</label>
+						{teams.length > 0 && (
+							<>
+								<Label htmlFor="team" className="xs:text-end">
+									<Trans>Team</Trans>
+								</Label>
+								<Select name="team" defaultValue={system?.team || "none"}>
+									<SelectTrigger id="team">
+										<SelectValue />
+									</SelectTrigger>
+									<SelectContent>
+										<SelectItem value="none">
+											<Trans>None</Trans>
+										</SelectItem>
+										{teams.map((team) => (
+											<SelectItem key={team.id} value={team.id}>
+												{team.name}
+											</SelectItem>
+										))}
+									</SelectContent>
+								</Select>
+							</>
+						)}
 						<Label htmlFor="pkey" className="xs:text-end whitespace-pre">
 							<Trans comment="Use 'Key' if your language requires many more characters">Public Key</Trans>
 						</Label>
@@ -133,6 +133,27 @@ export const alertsHistoryColumns: ColumnDef<AlertsHistoryRecord>[] = [
 			)
 		},
 	},
+	{
+		accessorKey: "acknowledged",
+		enableSorting: true,
+		invertSorting: true,
+		header: ({ column }) => (
+			<Button variant="ghost" onClick={() => column.toggleSorting(column.getIsSorted() === "asc")}>
+				<Trans>Acknowledged</Trans>
+			</Button>
+		),
+		cell: ({ row, getValue }) => {
+			const acknowledged = getValue() as string | undefined
+			if (!acknowledged) {
+				return null
+			}
+			return (
+				<span className="ps-1 tabular-nums tracking-tight" title={`${row.original.acknowledged} UTC`}>
+					{formatShortDate(acknowledged)}
+				</span>
+			)
+		},
+	},
 	{
 		accessorKey: "duration",
 		invertSorting: true,
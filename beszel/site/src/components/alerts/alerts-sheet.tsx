@@ -14,6 +14,7 @@ import { Tabs, TabsList, TabsTrigger, TabsContent } from "@/components/ui/tabs"
 import { ServerIcon, GlobeIcon } from "lucide-react"
 import { $router, Link } from "@/components/router"
 import { DialogHeader } from "@/components/ui/dialog"
+import { Input } from "@/components/ui/input"
 
 const Slider = lazy(() => import("@/components/ui/slider"))
 
@@ -34,12 +35,24 @@ const failedUpdateToast = (error: unknown) => {
 
 /** Create or update alerts for a given name and systems */
 const upsertAlerts = debounce(
-	async ({ name, value, min, systems }: { name: string; value: number; min: number; systems: string[] }) => {
+	async ({
+		name,
+		value,
+		min,
+		target,
+		systems,
+	}: {
+		name: string
+		value: number
+		min: number
+		target?: string
+		systems: string[]
+	}) => {
 		try {
 			await pb.send<{ success: boolean }>(endpoint, {
 				method: "POST",
 				// overwrite is always true because we've done filtering client side
-				body: { name, value, min, systems, overwrite: true },
+				body: { name, value, min, target, systems, overwrite: true },
 			})
 		} catch (error) {
 			failedUpdateToast(error)
@@ -171,6 +184,7 @@ export function AlertContent({
 	const [checked, setChecked] = useState(global ? false : !!alert)
 	const [min, setMin] = useState(alert?.min || 10)
 	const [value, setValue] = useState(alert?.value || (singleDescription ? 0 : alertData.start ?? 80))
+	const [target, setTarget] = useState(alert?.target ?? "")
 
 	const Icon = alertData.icon
 
@@ -199,6 +213,7 @@ export function AlertContent({
 				name: alertKey,
 				value,
 				min,
+				target,
 				systems,
 			})
 	}
@@ -208,14 +223,16 @@ export function AlertContent({
 			<label
 				htmlFor={`s${name}`}
 				className={cn("flex flex-row items-center justify-between gap-4 cursor-pointer p-4", {
-					"pb-0": checked,
+					"pb-0": checked && !alertData.instant,
 				})}
 			>
 				<div className="grid gap-1 select-none">
 					<p className="font-semibold flex gap-3 items-center">
 						<Icon className="h-4 w-4 opacity-85" /> {alertData.name()}
 					</p>
-					{!checked && <span className="block text-sm text-muted-foreground">{alertData.desc()}</span>}
+					{(!checked || alertData.instant) && (
+						<span className="block text-sm text-muted-foreground">{alertData.desc()}</span>
+					)}
 				</div>
 				<Switch
 					id={`s${name}`}
@@ -238,19 +255,29 @@ export function AlertContent({
 					}}
 				/>
 			</label>
-			{checked && (
+			{checked && !alertData.instant && (
 				<div className="grid sm:grid-cols-2 mt-1.5 gap-5 px-4 pb-5 tabular-nums text-muted-foreground">
 					<Suspense fallback={<div className="h-10" />}>
 						{!singleDescription && (
 							<div>
 								<p id={`v${name}`} className="text-sm block h-8">
-									<Trans>
-										Average exceeds{" "}
-										<strong className="text-foreground">
-											{value}
-											{alertData.unit}
-										</strong>
-									</Trans>
+									{alertData.valueLabel ? (
+										<>
+											{alertData.valueLabel()}{" "}
+											<strong className="text-foreground">
+												{value}
+												{alertData.unit}
+											</strong>
+										</>
+									) : (
+										<Trans>
+											Average exceeds{" "}
+											<strong className="text-foreground">
+												{value}
+												{alertData.unit}
+											</strong>
+										</Trans>
+									)}
 								</p>
 								<div className="flex gap-3">
 									<Slider
@@ -265,31 +292,48 @@ export function AlertContent({
 								</div>
 							</div>
 						)}
-						<div className={cn(singleDescription && "col-span-full lowercase")}>
-							<p id={`t${name}`} className="text-sm block h-8 first-letter:uppercase">
-								{singleDescription && (
-									<>
-										{singleDescription}
-										{` `}
-									</>
-								)}
-								<Trans>
-									For <strong className="text-foreground">{min}</strong>{" "}
-									<Plural value={min} one="minute" other="minutes" />
-								</Trans>
-							</p>
-							<div className="flex gap-3">
-								<Slider
-									aria-labelledby={`v${name}`}
-									defaultValue={[min]}
-									onValueCommit={(minVal) => sendUpsert(minVal[0], value)}
-									onValueChange={(val) => setMin(val[0])}
-									min={1}
-									max={60}
-								/>
+						{!alertData.noDuration && (
+							<div className={cn(singleDescription && "col-span-full lowercase")}>
+								<p id={`t${name}`} className="text-sm block h-8 first-letter:uppercase">
+									{singleDescription && (
+										<>
+											{singleDescription}
+											{` `}
+										</>
+									)}
+									<Trans>
+										For <strong className="text-foreground">{min}</strong>{" "}
+										<Plural value={min} one="minute" other="minutes" />
+									</Trans>
+								</p>
+								<div className="flex gap-3">
+									<Slider
+										aria-labelledby={`v${name}`}
+										defaultValue={[min]}
+										onValueCommit={(minVal) => sendUpsert(minVal[0], value)}
+										onValueChange={(val) => setMin(val[0])}
+										min={1}
+										max={60}
+									/>
+								</div>
 							</div>
-						</div>
+						)}
 					</Suspense>
+					{alertData.target && (
+						<div className="col-span-full">
+							<label htmlFor={`g${name}`} className="text-sm block h-8">
+								{alertData.target()}
+							</label>
+							<Input
+								id={`g${name}`}
+								value={target}
+								placeholder={t`All`}
+								maxLength={128}
+								onChange={(e) => setTarget(e.target.value)}
+								onBlur={() => target !== (alert?.target ?? "") && sendUpsert(min, value)}
+							/>
+						</div>
+					)}
 				</div>
 			)}
 		</div>
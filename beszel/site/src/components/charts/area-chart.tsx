@@ -1,5 +1,5 @@
 import { Area, AreaChart, CartesianGrid, YAxis } from "recharts"
-import { ChartContainer, ChartTooltip, ChartTooltipContent, xAxis } from "@/components/ui/chart"
+import { ChartContainer, ChartTooltip, ChartTooltipContent, eventLines, xAxis } from "@/components/ui/chart"
 import { useYAxisWidth, cn, formatShortDate, chartMargin } from "@/lib/utils"
 import { ChartData, SystemStatsRecord } from "@/types"
 import { useMemo } from "react"
@@ -57,6 +57,7 @@ export default function AreaChartDefault({
 							axisLine={false}
 						/>
 						{xAxis(chartData)}
+						{eventLines(chartData)}
 						<ChartTooltip
 							animationEasing="ease-out"
 							animationDuration={150}
@@ -87,5 +88,5 @@ export default function AreaChartDefault({
 				</ChartContainer>
 			</div>
 		)
-	}, [chartData.systemStats.at(-1), yAxisWidth, maxToggled])
+	}, [chartData.systemStats.at(-1), chartData.events, yAxisWidth, maxToggled])
 }
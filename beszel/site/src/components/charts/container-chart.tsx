@@ -1,5 +1,5 @@
 import { Area, AreaChart, CartesianGrid, YAxis } from "recharts"
-import { ChartConfig, ChartContainer, ChartTooltip, ChartTooltipContent, xAxis } from "@/components/ui/chart"
+import { ChartConfig, ChartContainer, ChartTooltip, ChartTooltipContent, eventLines, xAxis } from "@/components/ui/chart"
 import { memo, useMemo } from "react"
 import { useYAxisWidth, cn, formatShortDate, chartMargin, toFixedFloat, formatBytes, decimalString } from "@/lib/utils"
 // import Spinner from '../spinner'
@@ -9,6 +9,17 @@ import { ChartData } from "@/types"
 import { Separator } from "../ui/separator"
 import { ChartType, Unit } from "@/lib/enums"
 
+/** Health of a container in the tooltip, if it's not healthy */
+function healthLabel(item: any, key: string) {
+	const health = item?.payload?.[key]?.h
+	const restarts = item?.payload?.[key]?.rc
+	let label = health && health !== "healthy" ? ` (${health})` : ""
+	if (restarts) {
+		label += `, ${restarts} restarts`
+	}
+	return label
+}
+
 export default memo(function ContainerChart({
 	dataKey,
 	chartData,
@@ -27,6 +38,10 @@ export default memo(function ContainerChart({
 	const { containerData } = chartData
 
 	const isNetChart = chartType === ChartType.Network
+	const isDiskIoChart = chartType === ChartType.DiskIO
+	// network and disk i/o charts stack two rates for each container: [received, sent] or [read, write]
+	const isIoChart = isNetChart || isDiskIoChart
+	const [inKey, outKey] = isNetChart ? ["nr", "ns"] : ["dr", "dw"]
 
 	const chartConfig = useMemo(() => {
 		const config = {} as Record<string, { label: string; color: string }>
@@ -38,8 +53,9 @@ export default memo(function ContainerChart({
 				if (!key || key === "created") continue
 
 				const currentTotal = totalUsage.get(key) ?? 0
-				const increment = isNetChart
-					? (stats[key]?.nr ?? 0) + (stats[key]?.ns ?? 0)
+				const increment = isIoChart
+					? // @ts-ignore
+					  (stats[key]?.[inKey] ?? 0) + (stats[key]?.[outKey] ?? 0)
 					: // @ts-ignore
 					  stats[key]?.[dataKey] ?? 0
 
@@ -77,25 +93,27 @@ export default memo(function ContainerChart({
 		} else {
 			const chartUnit = isNetChart ? userSettings.unitNet : Unit.Bytes
 			obj.tickFormatter = (val) => {
-				const { value, unit } = formatBytes(val, isNetChart, chartUnit, true)
+				const { value, unit } = formatBytes(val, isIoChart, chartUnit, true)
 				return updateYAxisWidth(toFixedFloat(value, value >= 10 ? 0 : 1) + " " + unit)
 			}
 		}
 		// tooltip formatter
-		if (isNetChart) {
+		if (isIoChart) {
+			const chartUnit = isNetChart ? userSettings.unitNet : Unit.Bytes
+			const [inLabel, outLabel] = isNetChart ? ["rx", "tx"] : ["read", "write"]
 			obj.toolTipFormatter = (item: any, key: string) => {
 				try {
-					const sent = item?.payload?.[key]?.ns ?? 0
-					const received = item?.payload?.[key]?.nr ?? 0
-					const { value: receivedValue, unit: receivedUnit } = formatBytes(received, true, userSettings.unitNet, true)
-					const { value: sentValue, unit: sentUnit } = formatBytes(sent, true, userSettings.unitNet, true)
+					const sent = item?.payload?.[key]?.[outKey] ?? 0
+					const received = item?.payload?.[key]?.[inKey] ?? 0
+					const { value: receivedValue, unit: receivedUnit } = formatBytes(received, true, chartUnit, true)
+					const { value: sentValue, unit: sentUnit } = formatBytes(sent, true, chartUnit, true)
 					return (
 						<span className="flex">
 							{decimalString(receivedValue)} {receivedUnit}
-							<span className="opacity-70 ms-0.5"> rx </span>
+							<span className="opacity-70 ms-0.5"> {inLabel} </span>
 							<Separator orientation="vertical" className="h-3 mx-1.5 bg-primary/40" />
 							{decimalString(sentValue)} {sentUnit}
-							<span className="opacity-70 ms-0.5"> tx</span>
+							<span className="opacity-70 ms-0.5"> {outLabel}</span>
 						</span>
 					)
 				} catch (e) {
@@ -103,16 +121,17 @@ export default memo(function ContainerChart({
 				}
 			}
 		} else if (chartType === ChartType.Memory) {
-			obj.toolTipFormatter = (item: any) => {
+			obj.toolTipFormatter = (item: any, key: string) => {
 				const { value, unit } = formatBytes(item.value, false, Unit.Bytes, true)
-				return decimalString(value) + " " + unit
+				return decimalString(value) + " " + unit + healthLabel(item, key)
 			}
 		} else {
-			obj.toolTipFormatter = (item: any) => decimalString(item.value) + unit
+			obj.toolTipFormatter = (item: any, key: string) => decimalString(item.value) + unit + healthLabel(item, key)
 		}
 		// data function
-		if (isNetChart) {
-			obj.dataFunction = (key: string, data: any) => (data[key] ? data[key].nr + data[key].ns : null)
+		if (isIoChart) {
+			obj.dataFunction = (key: string, data: any) =>
+				data[key] ? (data[key][inKey] ?? 0) + (data[key][outKey] ?? 0) : null
 		} else {
 			obj.dataFunction = (key: string, data: any) => data[key]?.[dataKey] ?? null
 		}
@@ -152,6 +171,7 @@ export default memo(function ContainerChart({
 						axisLine={false}
 					/>
 					{xAxis(chartData)}
+					{eventLines(chartData)}
 					<ChartTooltip
 						animationEasing="ease-out"
 						animationDuration={150}
@@ -0,0 +1,103 @@
+import { CartesianGrid, Line, LineChart, YAxis } from "recharts"
+
+import {
+	ChartContainer,
+	ChartLegend,
+	ChartLegendContent,
+	ChartTooltip,
+	ChartTooltipContent,
+	xAxis,
+} from "@/components/ui/chart"
+import { useYAxisWidth, cn, formatShortDate, toFixedFloat, decimalString, chartMargin } from "@/lib/utils"
+import { ChartData, SystemStats } from "@/types"
+import { memo, useMemo } from "react"
+
+/** Usage (cpc) or frequency (cpf) of each CPU core */
+export default memo(function CpuCoresChart({
+	chartData,
+	dataKey,
+}: {
+	chartData: ChartData
+	dataKey: keyof Pick<SystemStats, "cpc" | "cpf">
+}) {
+	const { yAxisWidth, updateYAxisWidth } = useYAxisWidth()
+	const unit = dataKey === "cpc" ? "%" : " MHz"
+
+	if (chartData.systemStats.length === 0) {
+		return null
+	}
+
+	/** Format core data for chart and assign colors */
+	const newChartData = useMemo(() => {
+		const newChartData = { data: [], colors: {} } as {
+			data: Record<string, number | string>[]
+			colors: Record<string, string>
+		}
+		let cores = 0
+		for (let data of chartData.systemStats) {
+			let newData = { created: data.created } as Record<string, number | string>
+			const values = data.stats?.[dataKey] ?? []
+			for (let i = 0; i < values.length; i++) {
+				newData[`cpu${i}`] = values[i]
+			}
+			cores = Math.max(cores, values.length)
+			newChartData.data.push(newData)
+		}
+		for (let i = 0; i < cores; i++) {
+			newChartData.colors[`cpu${i}`] = `hsl(${((i * 360) / cores) % 360}, 60%, 55%)`
+		}
+		return newChartData
+	}, [chartData, dataKey])
+
+	const colors = Object.keys(newChartData.colors)
+
+	return (
+		<div>
+			<ChartContainer
+				className={cn("h-full w-full absolute aspect-auto bg-card opacity-0 transition-opacity", {
+					"opacity-100": yAxisWidth,
+				})}
+			>
+				<LineChart accessibilityLayer data={newChartData.data} margin={chartMargin}>
+					<CartesianGrid vertical={false} />
+					<YAxis
+						direction="ltr"
+						orientation={chartData.orientation}
+						className="tracking-tighter"
+						domain={[0, "auto"]}
+						width={yAxisWidth}
+						tickFormatter={(val) => updateYAxisWidth(toFixedFloat(val, dataKey === "cpc" ? 2 : 0) + unit)}
+						tickLine={false}
+						axisLine={false}
+					/>
+					{xAxis(chartData)}
+					<ChartTooltip
+						animationEasing="ease-out"
+						animationDuration={150}
+						// @ts-ignore
+						itemSorter={(a, b) => b.value - a.value}
+						content={
+							<ChartTooltipContent
+								labelFormatter={(_, data) => formatShortDate(data[0].payload.created)}
+								contentFormatter={(item) => decimalString(item.value, dataKey === "cpc" ? 2 : 0) + unit}
+							/>
+						}
+					/>
+					{colors.map((key) => (
+						<Line
+							key={key}
+							dataKey={key}
+							name={key}
+							type="monotoneX"
+							dot={false}
+							strokeWidth={1.5}
+							stroke={newChartData.colors[key]}
+							isAnimationActive={false}
+						/>
+					))}
+					{colors.length > 1 && colors.length <= 16 && <ChartLegend content={<ChartLegendContent />} />}
+				</LineChart>
+			</ChartContainer>
+		</div>
+	)
+})
@@ -0,0 +1,88 @@
+import { t } from "@lingui/core/macro"
+
+import { Area, AreaChart, CartesianGrid, YAxis } from "recharts"
+import {
+	ChartContainer,
+	ChartLegend,
+	ChartLegendContent,
+	ChartTooltip,
+	ChartTooltipContent,
+	xAxis,
+} from "@/components/ui/chart"
+import { useYAxisWidth, cn, formatShortDate, decimalString, chartMargin, formatBytes, toFixedFloat } from "@/lib/utils"
+import { ChartData } from "@/types"
+import { memo } from "react"
+import { $userSettings } from "@/lib/stores"
+import { useStore } from "@nanostores/react"
+
+/** Disk space used by Docker images, containers, volumes and build cache */
+export default memo(function DockerDiskChart({ chartData }: { chartData: ChartData }) {
+	const { yAxisWidth, updateYAxisWidth } = useYAxisWidth()
+	const userSettings = useStore($userSettings)
+
+	if (chartData.systemStats.length === 0) {
+		return null
+	}
+
+	const dataKeys = [
+		{ key: "stats.ddu.i", name: t`Images`, color: 1 },
+		{ key: "stats.ddu.c", name: t`Containers`, color: 2 },
+		{ key: "stats.ddu.v", name: t`Volumes`, color: 3 },
+		{ key: "stats.ddu.b", name: t`Build cache`, color: 4 },
+	]
+
+	return (
+		<div>
+			<ChartContainer
+				className={cn("h-full w-full absolute aspect-auto bg-card opacity-0 transition-opacity", {
+					"opacity-100": yAxisWidth,
+				})}
+			>
+				<AreaChart accessibilityLayer data={chartData.systemStats} margin={chartMargin}>
+					<CartesianGrid vertical={false} />
+					<YAxis
+						direction="ltr"
+						orientation={chartData.orientation}
+						className="tracking-tighter"
+						width={yAxisWidth}
+						tickLine={false}
+						axisLine={false}
+						tickFormatter={(value) => {
+							const { value: convertedValue, unit } = formatBytes(value * 1024, false, userSettings.unitDisk, true)
+							return updateYAxisWidth(toFixedFloat(convertedValue, value >= 10 ? 0 : 1) + " " + unit)
+						}}
+					/>
+					{xAxis(chartData)}
+					<ChartTooltip
+						animationEasing="ease-out"
+						animationDuration={150}
+						content={
+							<ChartTooltipContent
+								labelFormatter={(_, data) => formatShortDate(data[0].payload.created)}
+								contentFormatter={({ value }) => {
+									// values are supplied as GB
+									const { value: convertedValue, unit } = formatBytes(value * 1024, false, userSettings.unitDisk, true)
+									return decimalString(convertedValue, convertedValue >= 100 ? 1 : 2) + " " + unit
+								}}
+							/>
+						}
+					/>
+					{dataKeys.map(({ key, name, color }) => (
+						<Area
+							key={key}
+							dataKey={key}
+							name={name}
+							type="monotoneX"
+							fill={`hsl(var(--chart-${color}))`}
+							fillOpacity={0.4}
+							stroke={`hsl(var(--chart-${color}))`}
+							stackId="a"
+							isAnimationActive={false}
+						/>
+					))}
+					<ChartLegend content={<ChartLegendContent />} />
+				</AreaChart>
+			</ChartContainer>
+		</div>
+	)
+})
@@ -43,9 +43,11 @@ export default memo(function GenericSensorChart({
 
 	/** Format generic sensor data for chart */
 	const newChartData = useMemo(() => {
-		const newChartData = { data: [], colors: {} } as {
+		const newChartData = { data: [], colors: {}, states: new Map() } as {
 			data: Record<string, number | string>[]
 			colors: Record<string, string>
+			/** state labels by code, for state sensors */
+			states: Map<number, string>
 		}
 		
 		for (let data of chartData.systemStats) {
@@ -53,7 +55,11 @@ export default memo(function GenericSensorChart({
 			
 			// Check if this sensor exists in the generic sensors data
 			if (data.stats?.gs && data.stats.gs[sensorName]) {
-				newData[sensorName] = data.stats.gs[sensorName].v
+				const sensor = data.stats.gs[sensorName]
+				newData[sensorName] = sensor.v
+				if (sensor.s) {
+					newChartData.states.set(sensor.v, sensor.s)
+				}
 			}
 			
 			newChartData.data.push(newData)
@@ -65,8 +71,15 @@ export default memo(function GenericSensorChart({
 		return newChartData
 	}, [chartData, sensorName])
 
+	// state sensors are charted as a timeline of their state labels
+	const states = newChartData.states
+	const isState = states.size > 0
+
 	// Format value for display
 	const formatValue = (val: number) => {
+		if (isState) {
+			return states.get(val) ?? ""
+		}
 		return toFixedFloat(val, 2) + " " + unit
 	}
 
@@ -90,6 +103,7 @@ export default memo(function GenericSensorChart({
 						orientation={chartData.orientation}
 						className="tracking-tighter"
 						domain={domain}
+						ticks={isState ? Array.from(states.keys()).sort((a, b) => a - b) : undefined}
 						width={yAxisWidth}
 						tickFormatter={(val) => updateYAxisWidth(formatValue(val))}
 						tickLine={false}
@@ -102,15 +116,17 @@ export default memo(function GenericSensorChart({
 						content={
 							<ChartTooltipContent
 								labelFormatter={(_, data) => formatShortDate(data[0].payload.created)}
-								contentFormatter={(item) => decimalString(item.value) + " " + unit}
+								contentFormatter={(item) =>
+									isState ? formatValue(item.value) : decimalString(item.value) + " " + unit
+								}
 								filter={filter}
 							/>
 						}
 					/>
 					<Line
 						dataKey={sensorName}
-						name={`${sensorName} (${unit})`}
-						type="monotoneX"
+						name={unit ? `${sensorName} (${unit})` : sensorName}
+						type={isState ? "stepAfter" : "monotoneX"}
 						dot={false}
 						strokeWidth={1.5}
 						stroke={newChartData.colors[sensorName]}
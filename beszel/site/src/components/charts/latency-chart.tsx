@@ -0,0 +1,80 @@
+import { Area, AreaChart, CartesianGrid, YAxis } from "recharts"
+
+import {
+	ChartContainer,
+	ChartLegend,
+	ChartLegendContent,
+	ChartTooltip,
+	ChartTooltipContent,
+	xAxis,
+} from "@/components/ui/chart"
+import { useYAxisWidth, cn, formatShortDate, toFixedFloat, decimalString, chartMargin } from "@/lib/utils"
+import { ChartData, SystemStats } from "@/types"
+import { memo } from "react"
+import { t } from "@lingui/core/macro"
+
+export default memo(function LatencyChart({ chartData }: { chartData: ChartData }) {
+	const { yAxisWidth, updateYAxisWidth } = useYAxisWidth()
+
+	const keys = [
+		{
+			color: "hsl(217, 91%, 60%)", // Blue
+			label: t({ message: `Agent to hub`, comment: "Pipeline latency" }),
+		},
+		{
+			color: "hsl(25, 95%, 53%)", // Orange
+			label: t({ message: `Hub to storage`, comment: "Pipeline latency" }),
+		},
+	]
+
+	return (
+		<div>
+			<ChartContainer
+				className={cn("h-full w-full absolute aspect-auto bg-card opacity-0 transition-opacity", {
+					"opacity-100": yAxisWidth,
+				})}
+			>
+				<AreaChart accessibilityLayer data={chartData.systemStats} margin={chartMargin}>
+					<CartesianGrid vertical={false} />
+					<YAxis
+						direction="ltr"
+						orientation={chartData.orientation}
+						className="tracking-tighter"
+						domain={[0, "auto"]}
+						width={yAxisWidth}
+						tickFormatter={(value) => {
+							return updateYAxisWidth(toFixedFloat(value, 0) + " ms")
+						}}
+						tickLine={false}
+						axisLine={false}
+					/>
+					{xAxis(chartData)}
+					<ChartTooltip
+						animationEasing="ease-out"
+						animationDuration={150}
+						content={
+							<ChartTooltipContent
+								labelFormatter={(_, data) => formatShortDate(data[0].payload.created)}
+								contentFormatter={(item) => decimalString(item.value) + " ms"}
+							/>
+						}
+					/>
+					{keys.map(({ color, label }, i) => (
+						<Area
+							key={i}
+							dataKey={(value: { stats: SystemStats }) => value.stats?.lat?.[i]}
+							name={label}
+							type="monotoneX"
+							fill={color}
+							fillOpacity={0.35}
+							stroke={color}
+							stackId="a"
+							isAnimationActive={false}
+						/>
+					))}
+					<ChartLegend content={<ChartLegendContent />} />
+				</AreaChart>
+			</ChartContainer>
+		</div>
+	)
+})
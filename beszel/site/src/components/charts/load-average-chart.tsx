@@ -1,4 +1,4 @@
-import { CartesianGrid, Line, LineChart, YAxis } from "recharts"
+import { CartesianGrid, Line, LineChart, ReferenceLine, YAxis } from "recharts"
 
 import {
 	ChartContainer,
@@ -13,7 +13,8 @@ import { ChartData, SystemStats } from "@/types"
 import { memo } from "react"
 import { t } from "@lingui/core/macro"
 
-export default memo(function LoadAverageChart({ chartData }: { chartData: ChartData }) {
+/** Load averages, with a line at the CPU count if set */
+export default memo(function LoadAverageChart({ chartData, cpus }: { chartData: ChartData; cpus?: number }) {
 	const { yAxisWidth, updateYAxisWidth } = useYAxisWidth()
 
 	const keys: { legacy: keyof SystemStats; color: string; label: string }[] = [
@@ -88,6 +89,21 @@ export default memo(function LoadAverageChart({ chartData }: { chartData: ChartD
 							/>
 						)
 					})}
+					{!!cpus && (
+						<ReferenceLine
+							y={cpus}
+							stroke="hsl(var(--muted-foreground))"
+							strokeDasharray="3 3"
+							strokeOpacity={0.6}
+							ifOverflow="extendDomain"
+							label={{
+								value: t`${cpus} CPUs`,
+								position: "insideTopRight",
+								fontSize: 10,
+								fill: "hsl(var(--muted-foreground))",
+							}}
+						/>
+					)}
 					<ChartLegend content={<ChartLegendContent />} />
 				</LineChart>
 			</ChartContainer>
@@ -1,5 +1,5 @@
 import { Area, AreaChart, CartesianGrid, YAxis } from "recharts"
-import { ChartContainer, ChartTooltip, ChartTooltipContent, xAxis } from "@/components/ui/chart"
+import { ChartContainer, ChartTooltip, ChartTooltipContent, eventLines, xAxis } from "@/components/ui/chart"
 import { useYAxisWidth, cn, decimalString, formatShortDate, chartMargin, formatBytes, toFixedFloat } from "@/lib/utils"
 import { memo } from "react"
 import { ChartData } from "@/types"
@@ -46,6 +46,7 @@ export default memo(function MemChart({ chartData }: { chartData: ChartData }) {
 						/>
 					)}
 					{xAxis(chartData)}
+					{eventLines(chartData)}
 					<ChartTooltip
 						// cursor={false}
 						animationEasing="ease-out"
@@ -99,6 +100,18 @@ export default memo(function MemChart({ chartData }: { chartData: ChartData }) {
 						stackId="1"
 						isAnimationActive={false}
 					/>
+					{chartData.systemStats.at(-1)?.stats.ma && (
+						<Area
+							name={t`Available`}
+							order={4}
+							dataKey="stats.ma"
+							type="monotoneX"
+							fillOpacity={0}
+							stroke="hsl(var(--muted-foreground))"
+							strokeDasharray="4 2"
+							isAnimationActive={false}
+						/>
+					)}
 				</AreaChart>
 			</ChartContainer>
 		</div>
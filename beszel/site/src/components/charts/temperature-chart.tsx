@@ -22,7 +22,7 @@ import { memo, useMemo } from "react"
 import { $temperatureFilter, $userSettings } from "@/lib/stores"
 import { useStore } from "@nanostores/react"
 
-export default memo(function TemperatureChart({ chartData }: { chartData: ChartData }) {
+export default memo(function TemperatureChart({ chartData, sensors }: { chartData: ChartData; sensors?: string[] }) {
 	const filter = useStore($temperatureFilter)
 	const userSettings = useStore($userSettings)
 	const { yAxisWidth, updateYAxisWidth } = useYAxisWidth()
@@ -40,11 +40,15 @@ export default memo(function TemperatureChart({ chartData }: { chartData: ChartD
 		const tempSums = {} as Record<string, number>
 		for (let data of chartData.systemStats) {
 			let newData = { created: data.created } as Record<string, number | string>
-			let keys = Object.keys(data.stats?.t ?? {})
+			let keys = sensors ?? Object.keys(data.stats?.t ?? {})
 			for (let i = 0; i < keys.length; i++) {
 				let key = keys[i]
-				newData[key] = data.stats.t![key]
-				tempSums[key] = (tempSums[key] ?? 0) + newData[key]
+				const value = data.stats?.t?.[key]
+				if (value === undefined) {
+					continue
+				}
+				newData[key] = value
+				tempSums[key] = (tempSums[key] ?? 0) + value
 			}
 			newChartData.data.push(newData)
 		}
@@ -53,7 +57,7 @@ export default memo(function TemperatureChart({ chartData }: { chartData: ChartD
 			newChartData.colors[key] = `hsl(${((keys.indexOf(key) * 360) / keys.length) % 360}, 60%, 55%)`
 		}
 		return newChartData
-	}, [chartData])
+	}, [chartData, sensors])
 
 	const colors = Object.keys(newChartData.colors)
 
@@ -1,6 +1,7 @@
 import {
 	AlertOctagonIcon,
 	BookIcon,
+	BoxIcon,
 	DatabaseBackupIcon,
 	FingerprintIcon,
 	LayoutDashboard,
@@ -8,6 +9,7 @@ import {
 	MailIcon,
 	Server,
 	SettingsIcon,
+	ThermometerIcon,
 	UsersIcon,
 } from "lucide-react"
 
@@ -21,13 +23,29 @@ import {
 	CommandSeparator,
 	CommandShortcut,
 } from "@/components/ui/command"
-import { memo, useEffect, useMemo } from "react"
-import { $systems } from "@/lib/stores"
+import { memo, useEffect, useMemo, useState } from "react"
+import { $systems, pb } from "@/lib/stores"
 import { getHostDisplayValue, isAdmin, listen } from "@/lib/utils"
 import { $router, basePath, navigate, prependBasePath } from "./router"
 import { Trans } from "@lingui/react/macro"
 import { t } from "@lingui/core/macro"
 import { getPagePath } from "@nanostores/router"
+import { SearchResult } from "@/types"
+
+/** Opens the chart of a search result on its system page */
+function openSearchResult(result: SearchResult) {
+	const system = $systems.get().find((system) => system.id === result.systemId)
+	if (!system) {
+		return
+	}
+	let path = getPagePath($router, "system", { name: system.name })
+	if (result.chart) {
+		// containers and temperatures are filtered to the result, other charts are one per sensor
+		const filter = result.chart === "containers" || result.chart === "temperature" ? `:${result.name}` : ""
+		path += `#${encodeURIComponent(result.chart + filter)}`
+	}
+	navigate(path)
+}
 
 export default memo(function CommandPalette({ open, setOpen }: { open: boolean; setOpen: (open: boolean) => void }) {
 	useEffect(() => {
@@ -40,6 +58,23 @@ export default memo(function CommandPalette({ open, setOpen }: { open: boolean;
 		return listen(document, "keydown", down)
 	}, [open, setOpen])
 
+	// search containers and sensors of all systems on the hub
+	const [search, setSearch] = useState("")
+	const [results, setResults] = useState([] as SearchResult[])
+	useEffect(() => {
+		const q = search.trim()
+		if (q.length < 2) {
+			setResults([])
+			return
+		}
+		const timeout = setTimeout(() => {
+			pb.send<SearchResult[]>("/api/beszel/search", { query: { q }, requestKey: "search" })
+				.then((results) => setResults(results.filter((result) => result.kind !== "system")))
+				.catch(() => {})
+		}, 200)
+		return () => clearTimeout(timeout)
+	}, [search])
+
 	return useMemo(() => {
 		const systems = $systems.get()
 		const SettingsShortcut = (
@@ -54,7 +89,11 @@ export default memo(function CommandPalette({ open, setOpen }: { open: boolean;
 		)
 		return (
 			<CommandDialog open={open} onOpenChange={setOpen}>
-				<CommandInput placeholder={t`Search for systems or settings...`} />
+				<CommandInput
+					placeholder={t`Search for systems, containers, sensors or settings...`}
+					value={search}
+					onValueChange={setSearch}
+				/>
 				<CommandList>
 					<CommandEmpty>
 						<Trans>No results found.</Trans>
@@ -79,6 +118,33 @@ export default memo(function CommandPalette({ open, setOpen }: { open: boolean;
 							<CommandSeparator className="mb-1.5" />
 						</>
 					)}
+					{results.length > 0 && (
+						<>
+							<CommandGroup heading={t`Containers / Sensors`}>
+								{results.map((result) => (
+									<CommandItem
+										key={`${result.systemId}/${result.kind}/${result.name}`}
+										value={`${result.kind} ${result.name} ${result.system}`}
+										// matched by the hub, so keep them when cmdk's own matching differs
+										keywords={[search]}
+										onSelect={() => {
+											openSearchResult(result)
+											setOpen(false)
+										}}
+									>
+										{result.kind === "container" ? (
+											<BoxIcon className="me-2 size-4" />
+										) : (
+											<ThermometerIcon className="me-2 size-4" />
+										)}
+										<span>{result.name}</span>
+										<CommandShortcut>{result.system}</CommandShortcut>
+									</CommandItem>
+								))}
+							</CommandGroup>
+							<CommandSeparator className="mb-1.5" />
+						</>
+					)}
 					<CommandGroup heading={t`Pages / Settings`}>
 						<CommandItem
 							keywords={["home"]}
@@ -217,5 +283,5 @@ export default memo(function CommandPalette({ open, setOpen }: { open: boolean;
 				</CommandList>
 			</CommandDialog>
 		)
-	}, [open])
+	}, [open, search, results])
 })
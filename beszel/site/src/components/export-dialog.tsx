@@ -0,0 +1,157 @@
+import { t } from "@lingui/core/macro"
+import { Trans } from "@lingui/react/macro"
+import { memo, useState } from "react"
+import { useStore } from "@nanostores/react"
+import { DownloadIcon } from "lucide-react"
+import { Button } from "@/components/ui/button"
+import {
+	Dialog,
+	DialogContent,
+	DialogDescription,
+	DialogFooter,
+	DialogHeader,
+	DialogTitle,
+	DialogTrigger,
+} from "@/components/ui/dialog"
+import { InputTags } from "@/components/ui/input-tags"
+import { Label } from "@/components/ui/label"
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
+import { toast } from "@/components/ui/use-toast"
+import { $chartTime, pb } from "@/lib/stores"
+import { chartTimeData } from "@/lib/utils"
+import { SystemRecord } from "@/types"
+
+const resolutions = {
+	"1m": () => t`Raw (1 minute)`,
+	"10m": () => t`10 minutes`,
+	"20m": () => t`20 minutes`,
+	"120m": () => t`2 hours`,
+	"480m": () => t`8 hours`,
+}
+
+export default memo(function ExportButton({ system }: { system: SystemRecord }) {
+	const [open, setOpen] = useState(false)
+
+	return (
+		<Dialog open={open} onOpenChange={setOpen}>
+			<DialogTrigger asChild>
+				<Button aria-label={t`Export`} variant="outline" size="icon" className="p-0 text-primary">
+					<DownloadIcon className="h-[1.2rem] w-[1.2rem] opacity-75" />
+				</Button>
+			</DialogTrigger>
+			{open && <ExportDialog system={system} />}
+		</Dialog>
+	)
+})
+
+function ExportDialog({ system }: { system: SystemRecord }) {
+	const chartTime = useStore($chartTime)
+	const [format, setFormat] = useState("csv")
+	const [resolution, setResolution] = useState<string>(chartTimeData[chartTime].type)
+	const [metrics, setMetrics] = useState([] as string[])
+	const [loading, setLoading] = useState(false)
+
+	async function download(e: React.FormEvent) {
+		e.preventDefault()
+		setLoading(true)
+		const now = new Date()
+		const params = new URLSearchParams({
+			from: chartTimeData[chartTime].getOffset(now).toISOString(),
+			to: now.toISOString(),
+			resolution,
+			format,
+			metrics: metrics.join(","),
+		})
+		try {
+			const res = await fetch(pb.buildURL(`/api/v1/systems/${system.id}/export?${params}`), {
+				headers: { Authorization: pb.authStore.token },
+			})
+			if (!res.ok) {
+				throw new Error((await res.json())?.message)
+			}
+			// file name from the hub, e.g. web1_1m_20250602-0700.csv
+			const filename = res.headers.get("Content-Disposition")?.match(/filename="(.+)"/)?.[1]
+			const url = URL.createObjectURL(await res.blob())
+			const a = document.createElement("a")
+			a.href = url
+			a.download = filename ?? `${system.name}.${format}`
+			a.click()
+			URL.revokeObjectURL(url)
+		} catch (error: any) {
+			toast({
+				title: t`Failed to export data`,
+				description: error?.message || t`Please check logs for more details.`,
+				variant: "destructive",
+			})
+		} finally {
+			setLoading(false)
+		}
+	}
+
+	return (
+		<DialogContent className="w-[90%] sm:max-w-lg rounded-lg">
+			<DialogHeader>
+				<DialogTitle>
+					<Trans>Export {system.name}</Trans>
+				</DialogTitle>
+				<DialogDescription>
+					<Trans>
+						Download the metrics of the selected time range ({chartTimeData[chartTime].label()}) for offline analysis
+						or archiving.
+					</Trans>
+				</DialogDescription>
+			</DialogHeader>
+			<form id="export" onSubmit={download} className="grid gap-4">
+				<div className="grid sm:grid-cols-2 gap-3">
+					<div className="grid gap-1.5">
+						<Label htmlFor="export-format">
+							<Trans>Format</Trans>
+						</Label>
+						<Select value={format} onValueChange={setFormat}>
+							<SelectTrigger id="export-format">
+								<SelectValue />
+							</SelectTrigger>
+							<SelectContent>
+								<SelectItem value="csv">CSV</SelectItem>
+								<SelectItem value="json">JSON</SelectItem>
+							</SelectContent>
+						</Select>
+					</div>
+					<div className="grid gap-1.5">
+						<Label htmlFor="export-resolution">
+							<Trans>Resolution</Trans>
+						</Label>
+						<Select value={resolution} onValueChange={setResolution}>
+							<SelectTrigger id="export-resolution">
+								<SelectValue />
+							</SelectTrigger>
+							<SelectContent>
+								{Object.entries(resolutions).map(([value, label]) => (
+									<SelectItem key={value} value={value}>
+										{label()}
+									</SelectItem>
+								))}
+							</SelectContent>
+						</Select>
+					</div>
+				</div>
+				<div className="grid gap-1.5">
+					<Label htmlFor="export-metrics">
+						<Trans>Metrics</Trans>
+					</Label>
+					<InputTags
+						id="export-metrics"
+						value={metrics}
+						onChange={setMetrics}
+						placeholder={t`All metrics, or e.g. cpu_usage_percent`}
+					/>
+				</div>
+			</form>
+			<DialogFooter>
+				<Button type="submit" form="export" disabled={loading}>
+					<Trans>Download</Trans>
+				</Button>
+			</DialogFooter>
+		</DialogContent>
+	)
+}
@@ -5,6 +5,8 @@ const routes = {
 	system: `/system/:name`,
 	settings: `/settings/:name?`,
 	forgot_password: `/forgot-password`,
+	share: `/share/:token`,
+	status: `/status/:slug`,
 } as const
 
 /**
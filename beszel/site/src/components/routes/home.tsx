@@ -1,11 +1,18 @@
-import { Suspense, lazy, memo, useEffect, useMemo } from "react"
+import { Suspense, lazy, memo, useEffect, useMemo, useState } from "react"
 import { Card, CardContent, CardHeader, CardTitle } from "../ui/card"
-import { $alerts, $systems, pb } from "@/lib/stores"
+import { $alerts, $systems, $userSettings, pb } from "@/lib/stores"
 import { useStore } from "@nanostores/react"
 import { GithubIcon } from "lucide-react"
 import { Separator } from "../ui/separator"
-import { alertInfo, getSystemNameFromId, updateRecordList, updateSystemList } from "@/lib/utils"
-import { AlertRecord, SystemRecord } from "@/types"
+import {
+	alertInfo,
+	decimalString,
+	formatTemperature,
+	getSystemNameFromId,
+	updateRecordList,
+	updateSystemList,
+} from "@/lib/utils"
+import { AlertRecord, FleetOverview, SystemRecord } from "@/types"
 import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert"
 import { $router, Link } from "../router"
 import { Plural, Trans, useLingui } from "@lingui/react/macro"
@@ -36,6 +43,7 @@ export const Home = memo(() => {
 	return useMemo(
 		() => (
 			<>
+				<FleetOverviewHeader />
 				<ActiveAlerts />
 				<Suspense>
 					<SystemsTable />
@@ -136,3 +144,64 @@ const ActiveAlerts = () => {
 		)
 	}, [alertsKey.join("")])
 }
+
+const FleetOverviewHeader = () => {
+	const { t } = useLingui()
+	const userSettings = useStore($userSettings)
+	const [overview, setOverview] = useState<FleetOverview>()
+
+	useEffect(() => {
+		const fetchOverview = () =>
+			pb
+				.send<FleetOverview>("/api/beszel/fleet-overview", {
+					query: { threshold: userSettings.colorCrit ?? 90 },
+					requestKey: "fleet-overview",
+				})
+				.then(setOverview)
+				.catch(() => {})
+		fetchOverview()
+		const interval = setInterval(fetchOverview, 60_000)
+		return () => clearInterval(interval)
+	}, [userSettings.colorCrit])
+
+	if (!overview || overview.total < 2) {
+		return null
+	}
+
+	const hottest = overview.hottest[0]
+	const items = [
+		{ label: t`Systems up`, value: `${overview.up} / ${overview.total}` },
+		{ label: t`Down`, value: overview.down },
+		{ label: t`Cores`, value: overview.cores },
+		...(overview.power ? [{ label: t`Power`, value: `${decimalString(overview.power, 0)} W` }] : []),
+		...(hottest?.dt
+			? [
+					{
+						label: t`Hottest`,
+						value: (() => {
+							const { value, unit } = formatTemperature(hottest.dt, userSettings.unitTemp)
+							return `${hottest.name} ${decimalString(value, 0)}${unit}`
+						})(),
+					},
+			  ]
+			: []),
+		{
+			label: t`Over threshold`,
+			value: overview.overThreshold.length,
+			title: overview.overThreshold.map((s) => s.name).join(", "),
+		},
+	]
+
+	return (
+		<Card className="mb-4">
+			<CardContent className="flex flex-wrap gap-x-8 gap-y-3 p-4 sm:px-6">
+				{items.map(({ label, value, title }) => (
+					<div key={label} title={title}>
+						<div className="text-xs text-muted-foreground">{label}</div>
+						<div className="text-lg font-semibold tabular-nums">{value}</div>
+					</div>
+				))}
+			</CardContent>
+		</Card>
+	)
+}
@@ -0,0 +1,101 @@
+import { t } from "@lingui/core/macro"
+import { Trans } from "@lingui/react/macro"
+import { memo, useEffect, useState } from "react"
+import { Separator } from "@/components/ui/separator"
+import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@/components/ui/table"
+import { toast } from "@/components/ui/use-toast"
+import { pb } from "@/lib/stores"
+import { alertInfo, formatShortDate } from "@/lib/utils"
+import { AlertChangeRecord } from "@/types"
+
+/** threshold and duration of an alert, e.g. "80% for 5 min" */
+function formatThreshold(name: string, value: number, min: number) {
+	const info = alertInfo[name]
+	if (info?.singleDesc) {
+		return ""
+	}
+	return `${value}${info?.unit ?? ""} ` + t`for ${min} min`
+}
+
+function formatChange(change: AlertChangeRecord) {
+	const previous = formatThreshold(change.name, change.old_value, change.old_min)
+	const current = formatThreshold(change.name, change.value, change.min)
+	switch (change.action) {
+		case "create":
+			return t`Created` + (current ? `: ${current}` : "")
+		case "delete":
+			return t`Deleted` + (previous ? `: ${previous}` : "")
+		default:
+			return `${previous} → ${current}`
+	}
+}
+
+export default memo(function SettingsAlertChanges() {
+	const [changes, setChanges] = useState([] as AlertChangeRecord[])
+
+	useEffect(() => {
+		pb.collection<AlertChangeRecord>("alert_changes")
+			.getList(1, 200, { sort: "-created", expand: "system", fields: "*,expand.system.name" })
+			.then(({ items }) => setChanges(items))
+			.catch((error) =>
+				toast({ title: t`Failed to load alert changes`, description: error?.message, variant: "destructive" })
+			)
+	}, [])
+
+	return (
+		<div>
+			<div>
+				<h3 className="text-xl font-medium mb-2">
+					<Trans>Alert Changes</Trans>
+				</h3>
+				<p className="text-sm text-muted-foreground leading-relaxed">
+					<Trans>See who created, changed or deleted the 200 most recent alert thresholds of your systems.</Trans>
+				</p>
+			</div>
+			<Separator className="my-4" />
+			<div className="rounded-md border overflow-hidden w-full">
+				<Table>
+					<TableHeader>
+						<TableRow>
+							<TableHead>
+								<Trans>Time</Trans>
+							</TableHead>
+							<TableHead>
+								<Trans>System</Trans>
+							</TableHead>
+							<TableHead>
+								<Trans>Alert</Trans>
+							</TableHead>
+							<TableHead>
+								<Trans>User</Trans>
+							</TableHead>
+							<TableHead>
+								<Trans>Change</Trans>
+							</TableHead>
+						</TableRow>
+					</TableHeader>
+					<TableBody>
+						{changes.map((change) => (
+							<TableRow key={change.id}>
+								<TableCell className="py-2.5 tabular-nums whitespace-nowrap">
+									{formatShortDate(change.created)}
+								</TableCell>
+								<TableCell className="py-2.5 font-medium">{change.expand?.system?.name}</TableCell>
+								<TableCell className="py-2.5">{alertInfo[change.name]?.name() ?? change.name}</TableCell>
+								<TableCell className="py-2.5">{change.email}</TableCell>
+								<TableCell className="py-2.5 tabular-nums">{formatChange(change)}</TableCell>
+							</TableRow>
+						))}
+						{changes.length === 0 && (
+							<TableRow>
+								<TableCell colSpan={5} className="h-24 text-center">
+									<Trans>No alert changes.</Trans>
+								</TableCell>
+							</TableRow>
+						)}
+					</TableBody>
+				</Table>
+			</div>
+		</div>
+	)
+})
@@ -0,0 +1,367 @@
+import { t } from "@lingui/core/macro"
+import { Trans } from "@lingui/react/macro"
+import { memo, useEffect, useState } from "react"
+import { useStore } from "@nanostores/react"
+import { redirectPage } from "@nanostores/router"
+import { PlusIcon, TrashIcon } from "lucide-react"
+import { $router } from "@/components/router"
+import { Button } from "@/components/ui/button"
+import { Checkbox } from "@/components/ui/checkbox"
+import { Input } from "@/components/ui/input"
+import { Label } from "@/components/ui/label"
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
+import { Separator } from "@/components/ui/separator"
+import { toast } from "@/components/ui/use-toast"
+import { $systems, pb } from "@/lib/stores"
+import { formatLabels, isReadOnlyUser, parseLabels } from "@/lib/utils"
+import { AlertRuleCondition, AlertRuleRecord } from "@/types"
+
+/** Metrics of alert rule conditions. Sensors are entered as "sensor:<name>". */
+const metrics = {
+	cpu: () => t`CPU usage (%)`,
+	memory: () => t`Memory usage (%)`,
+	disk: () => t`Disk usage (%)`,
+	swap: () => t`Swap usage (%)`,
+	bandwidth: () => t`Bandwidth (MB/s)`,
+	diskread: () => t`Disk read (MB/s)`,
+	diskwrite: () => t`Disk write (MB/s)`,
+	load1: () => t`Load average 1m`,
+	load5: () => t`Load average 5m`,
+	load15: () => t`Load average 15m`,
+	temperature: () => t`Highest temperature (°C)`,
+	latency: () => t`Latency (ms)`,
+	sensor: () => t`Sensor`,
+} as const
+
+const operators = [">", ">=", "<", "<="] as const
+
+const newCondition = (): AlertRuleCondition => ({ metric: "cpu", op: ">", value: 80 })
+
+/** Formats conditions, e.g. "cpu > 90 AND temperature > 80" */
+const formatConditions = (conditions: AlertRuleCondition[] | null, match: string) =>
+	(conditions ?? []).map((c) => `${c.metric} ${c.op} ${c.value}`).join(match === "any" ? " OR " : " AND ")
+
+function showError(error: any) {
+	toast({
+		title: t`Failed to update alert rules`,
+		description: error?.message,
+		variant: "destructive",
+	})
+}
+
+/** Editable list of conditions joined with AND or OR */
+function ConditionsInput({
+	conditions,
+	setConditions,
+	match,
+	setMatch,
+}: {
+	conditions: AlertRuleCondition[]
+	setConditions: (conditions: AlertRuleCondition[]) => void
+	match: string
+	setMatch: (match: "all" | "any") => void
+}) {
+	const update = (index: number, condition: Partial<AlertRuleCondition>) =>
+		setConditions(conditions.map((c, i) => (i === index ? { ...c, ...condition } : c)))
+
+	return (
+		<div className="grid gap-2">
+			<div className="flex items-center gap-2 text-sm">
+				<Trans>Match</Trans>
+				<Select value={match} onValueChange={(value) => setMatch(value as "all" | "any")}>
+					<SelectTrigger className="w-auto h-8">
+						<SelectValue />
+					</SelectTrigger>
+					<SelectContent>
+						<SelectItem value="all">
+							<Trans>all conditions</Trans>
+						</SelectItem>
+						<SelectItem value="any">
+							<Trans>any condition</Trans>
+						</SelectItem>
+					</SelectContent>
+				</Select>
+			</div>
+			{conditions.map((condition, i) => {
+				const isSensor = condition.metric.startsWith("sensor:")
+				return (
+					<div key={i} className="flex flex-wrap items-center gap-2">
+						<Select
+							value={isSensor ? "sensor" : condition.metric}
+							onValueChange={(metric) => update(i, { metric: metric === "sensor" ? "sensor:" : metric })}
+						>
+							<SelectTrigger className="w-52">
+								<SelectValue />
+							</SelectTrigger>
+							<SelectContent>
+								{Object.entries(metrics).map(([metric, label]) => (
+									<SelectItem key={metric} value={metric}>
+										{label()}
+									</SelectItem>
+								))}
+							</SelectContent>
+						</Select>
+						{isSensor && (
+							<Input
+								className="w-36"
+								required
+								placeholder={t`Sensor name`}
+								aria-label={t`Sensor name`}
+								value={condition.metric.slice("sensor:".length)}
+								onChange={(e) => update(i, { metric: "sensor:" + e.target.value })}
+							/>
+						)}
+						<Select value={condition.op} onValueChange={(op) => update(i, { op: op as AlertRuleCondition["op"] })}>
+							<SelectTrigger className="w-20">
+								<SelectValue />
+							</SelectTrigger>
+							<SelectContent>
+								{operators.map((op) => (
+									<SelectItem key={op} value={op}>
+										{op}
+									</SelectItem>
+								))}
+							</SelectContent>
+						</Select>
+						<Input
+							className="w-24"
+							type="number"
+							step="any"
+							required
+							aria-label={t`Value`}
+							value={condition.value}
+							onChange={(e) => update(i, { value: Number(e.target.value) })}
+						/>
+						<Button
+							type="button"
+							variant="ghost"
+							size="icon"
+							aria-label={t`Remove`}
+							title={t`Remove`}
+							onClick={() => setConditions(conditions.filter((_, j) => j !== i))}
+						>
+							<TrashIcon className="size-4" />
+						</Button>
+					</div>
+				)
+			})}
+			<div>
+				<Button
+					type="button"
+					variant="outline"
+					size="sm"
+					disabled={conditions.length >= 10}
+					onClick={() => setConditions([...conditions, newCondition()])}
+				>
+					<PlusIcon className="size-4 me-1" />
+					<Trans>Add condition</Trans>
+				</Button>
+			</div>
+		</div>
+	)
+}
+
+export default memo(function SettingsAlertRules() {
+	if (isReadOnlyUser()) {
+		redirectPage($router, "settings", { name: "general" })
+	}
+	const systems = useStore($systems)
+	const [rules, setRules] = useState([] as AlertRuleRecord[])
+	const [name, setName] = useState("")
+	const [match, setMatch] = useState<"all" | "any">("all")
+	const [conditions, setConditions] = useState([newCondition()])
+	const [duration, setDuration] = useState(5)
+	const [resolveMatch, setResolveMatch] = useState<"all" | "any">("all")
+	const [resolveConditions, setResolveConditions] = useState([] as AlertRuleCondition[])
+	const [resolveDuration, setResolveDuration] = useState(5)
+	const [systemIds, setSystemIds] = useState([] as string[])
+	const [labels, setLabels] = useState("")
+
+	function refresh() {
+		pb.collection<AlertRuleRecord>("alert_rules")
+			.getFullList({ sort: "name" })
+			.then(setRules)
+			.catch(showError)
+	}
+
+	useEffect(refresh, [])
+
+	async function createRule(e: React.FormEvent) {
+		e.preventDefault()
+		try {
+			await pb.collection("alert_rules").create({
+				user: pb.authStore.record!.id,
+				name,
+				match,
+				conditions,
+				duration,
+				resolve_match: resolveMatch,
+				resolve_conditions: resolveConditions,
+				resolve_duration: resolveDuration,
+				systems: systemIds,
+				labels: parseLabels(labels),
+			})
+			setName("")
+			setConditions([newCondition()])
+			setResolveConditions([])
+			setSystemIds([])
+			setLabels("")
+			refresh()
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	async function deleteRule(id: string) {
+		try {
+			await pb.collection("alert_rules").delete(id)
+			setRules(rules.filter((rule) => rule.id !== id))
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	const systemNames = (ids: string[] | null) =>
+		(ids ?? []).map((id) => systems.find((system) => system.id === id)?.name ?? id).join(", ")
+
+	return (
+		<div>
+			<div>
+				<h3 className="text-xl font-medium mb-2">
+					<Trans>Alert Rules</Trans>
+				</h3>
+				<p className="text-sm text-muted-foreground leading-relaxed">
+					<Trans>
+						Alert rules trigger when their conditions hold for a number of minutes, and resolve when the resolve
+						conditions hold for the resolve duration. Without resolve conditions, a rule resolves when its conditions
+						no longer hold. Rules apply to the selected systems and to systems with all of their labels, or to all
+						systems if neither is set.
+					</Trans>
+				</p>
+			</div>
+			<Separator className="my-4" />
+			<form onSubmit={createRule} className="grid gap-4">
+				<div className="grid sm:grid-cols-2 gap-3">
+					<div className="grid gap-1.5">
+						<Label htmlFor="rule-name">
+							<Trans>Name</Trans>
+						</Label>
+						<Input id="rule-name" required maxLength={64} value={name} onChange={(e) => setName(e.target.value)} />
+					</div>
+					<div className="grid gap-1.5">
+						<Label htmlFor="rule-duration">
+							<Trans>Duration (minutes)</Trans>
+						</Label>
+						<Input
+							id="rule-duration"
+							type="number"
+							required
+							min={1}
+							max={60}
+							value={duration}
+							onChange={(e) => setDuration(Number(e.target.value))}
+						/>
+					</div>
+				</div>
+				<div className="grid gap-1.5">
+					<Label>
+						<Trans>Conditions</Trans>
+					</Label>
+					<ConditionsInput conditions={conditions} setConditions={setConditions} match={match} setMatch={setMatch} />
+				</div>
+				<div className="grid gap-1.5">
+					<Label>
+						<Trans>Resolve conditions</Trans>
+					</Label>
+					<ConditionsInput
+						conditions={resolveConditions}
+						setConditions={setResolveConditions}
+						match={resolveMatch}
+						setMatch={setResolveMatch}
+					/>
+				</div>
+				<div className="grid gap-1.5 sm:w-1/2">
+					<Label htmlFor="rule-resolve-duration">
+						<Trans>Resolve duration (minutes)</Trans>
+					</Label>
+					<Input
+						id="rule-resolve-duration"
+						type="number"
+						required
+						min={1}
+						max={60}
+						value={resolveDuration}
+						onChange={(e) => setResolveDuration(Number(e.target.value))}
+					/>
+				</div>
+				<div className="grid gap-1.5">
+					<Label>
+						<Trans>Systems</Trans>
+					</Label>
+					<div className="flex flex-wrap gap-x-4 gap-y-2">
+						{systems.map((system) => (
+							<label key={system.id} className="flex items-center gap-2 text-sm">
+								<Checkbox
+									checked={systemIds.includes(system.id)}
+									onCheckedChange={(checked) =>
+										setSystemIds(checked ? [...systemIds, system.id] : systemIds.filter((id) => id !== system.id))
+									}
+								/>
+								{system.name}
+							</label>
+						))}
+					</div>
+				</div>
+				<div className="grid gap-1.5">
+					<Label htmlFor="rule-labels">
+						<Trans>Labels</Trans>
+					</Label>
+					<Input
+						id="rule-labels"
+						placeholder="env=prod, site=*"
+						value={labels}
+						onChange={(e) => setLabels(e.target.value)}
+					/>
+				</div>
+				<div>
+					<Button type="submit" disabled={!conditions.length}>
+						<Trans>Create rule</Trans>
+					</Button>
+				</div>
+			</form>
+			{rules.length > 0 && (
+				<div className="grid gap-2 border-t mt-5 pt-4">
+					{rules.map((rule) => (
+						<div key={rule.id} className="flex items-center gap-2 text-sm">
+							<div className="min-w-0 grow">
+								<div className="truncate font-medium">
+									{rule.name}
+									{!!rule.triggered?.length && (
+										<span className="text-red-500 font-normal">
+											{" · "}
+											<Trans>Triggered for {systemNames(rule.triggered)}</Trans>
+										</span>
+									)}
+								</div>
+								<div className="text-muted-foreground truncate">
+									{formatConditions(rule.conditions, rule.match)} · <Trans>{rule.duration} min</Trans>
+									{rule.systems.length > 0 && ` · ${systemNames(rule.systems)}`}
+									{rule.labels && Object.keys(rule.labels).length > 0 && ` · ${formatLabels(rule.labels)}`}
+								</div>
+							</div>
+							<Button
+								variant="ghost"
+								size="icon"
+								aria-label={t`Delete`}
+								title={t`Delete`}
+								onClick={() => deleteRule(rule.id)}
+							>
+								<TrashIcon className="size-4" />
+							</Button>
+						</div>
+					))}
+				</div>
+			)}
+		</div>
+	)
+})
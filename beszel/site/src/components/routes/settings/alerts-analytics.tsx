@@ -0,0 +1,139 @@
+import { t } from "@lingui/core/macro"
+import { Trans } from "@lingui/react/macro"
+import { useEffect, useState } from "react"
+import { pb } from "@/lib/stores"
+import { alertInfo, formatShortDate } from "@/lib/utils"
+import { AlertAnalytics } from "@/types"
+import { Card } from "@/components/ui/card"
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
+
+// time ranges of the analytics, in hours
+const ranges: Record<string, { hours: number; label: () => string }> = {
+	"24h": { hours: 24, label: () => t`Last 24 hours` },
+	"7d": { hours: 24 * 7, label: () => t`Last 7 days` },
+	"30d": { hours: 24 * 30, label: () => t`Last 30 days` },
+	"90d": { hours: 24 * 90, label: () => t`Last 90 days` },
+}
+
+/** Formats seconds as a short duration, such as 1h 5m */
+function formatSeconds(seconds: number) {
+	if (!seconds) {
+		return "-"
+	}
+	const hours = Math.floor(seconds / 3600)
+	const minutes = Math.round((seconds % 3600) / 60)
+	if (hours > 0) {
+		return minutes ? `${hours}h ${minutes}m` : `${hours}h`
+	}
+	return minutes ? `${minutes}m` : `${Math.round(seconds)}s`
+}
+
+/** Summary of the alert history, with the noisiest systems and most frequent alerts */
+export default function AlertsAnalytics() {
+	const [range, setRange] = useState("30d")
+	const [analytics, setAnalytics] = useState<AlertAnalytics | null>(null)
+
+	useEffect(() => {
+		const from = new Date(Date.now() - ranges[range].hours * 3600_000).toISOString()
+		pb.send<AlertAnalytics>("/api/beszel/alert-analytics", { query: { from }, requestKey: "alert-analytics" })
+			.then(setAnalytics)
+			.catch(() => setAnalytics(null))
+	}, [range])
+
+	if (!analytics) {
+		return null
+	}
+
+	const maxCount = Math.max(1, ...analytics.timeline.map((bucket) => bucket.count))
+	const stats = [
+		{ label: t`Alerts`, value: analytics.total },
+		{ label: t`Active`, value: analytics.active },
+		{ label: t`Mean time to acknowledge`, value: formatSeconds(analytics.mtta) },
+		{ label: t`Mean time to resolve`, value: formatSeconds(analytics.mttr) },
+	]
+
+	return (
+		<div className="grid gap-3 mb-6">
+			<div className="flex items-center justify-between gap-3">
+				<h4 className="font-medium">
+					<Trans>Analytics</Trans>
+				</h4>
+				<Select value={range} onValueChange={setRange}>
+					<SelectTrigger className="w-40">
+						<SelectValue />
+					</SelectTrigger>
+					<SelectContent>
+						{Object.entries(ranges).map(([key, { label }]) => (
+							<SelectItem key={key} value={key}>
+								{label()}
+							</SelectItem>
+						))}
+					</SelectContent>
+				</Select>
+			</div>
+			<div className="grid grid-cols-2 @3xl:grid-cols-4 gap-3">
+				{stats.map(({ label, value }) => (
+					<Card key={label} className="px-4 py-3">
+						<div className="text-sm text-muted-foreground">{label}</div>
+						<div className="text-2xl font-semibold tabular-nums">{value}</div>
+					</Card>
+				))}
+			</div>
+			<Card className="px-4 py-3">
+				<div className="text-sm text-muted-foreground mb-2">
+					<Trans>Alerts over time</Trans>
+				</div>
+				<div className="flex items-end gap-px h-20">
+					{analytics.timeline.map((bucket) => (
+						<div
+							key={bucket.time}
+							className="flex-1 bg-primary/70 rounded-t-sm min-h-px"
+							style={{ height: `${(bucket.count / maxCount) * 100}%` }}
+							title={`${formatShortDate(bucket.time)}: ${bucket.count}`}
+						/>
+					))}
+				</div>
+			</Card>
+			<div className="grid @3xl:grid-cols-2 gap-3">
+				<AnalyticsList
+					title={t`Noisiest systems`}
+					rows={analytics.systems.map((system) => ({ name: system.name, count: system.count, mttr: system.mttr }))}
+				/>
+				<AnalyticsList
+					title={t`Most frequent alerts`}
+					rows={analytics.alerts.map((alert) => ({
+						name: alertInfo[alert.name]?.name() ?? alert.name,
+						count: alert.count,
+						mttr: alert.mttr,
+					}))}
+				/>
+			</div>
+		</div>
+	)
+}
+
+function AnalyticsList({ title, rows }: { title: string; rows: { name: string; count: number; mttr: number }[] }) {
+	return (
+		<Card className="px-4 py-3">
+			<div className="flex justify-between text-sm text-muted-foreground mb-2">
+				<span>{title}</span>
+				<span>
+					<Trans>Alerts / MTTR</Trans>
+				</span>
+			</div>
+			{rows.length === 0 && (
+				<div className="text-sm text-muted-foreground">
+					<Trans>No alerts in this time range.</Trans>
+				</div>
+			)}
+			{rows.map((row) => (
+				<div key={row.name} className="flex justify-between gap-3 text-sm py-0.5">
+					<span className="truncate">{row.name}</span>
+					<span className="tabular-nums shrink-0">
+						{row.count} / {formatSeconds(row.mttr)}
+					</span>
+				</div>
+			))}
+		</Card>
+	)
+}
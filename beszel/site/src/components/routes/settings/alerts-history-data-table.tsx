@@ -16,6 +16,7 @@ import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@
 import { Button, buttonVariants } from "@/components/ui/button"
 import { Input } from "@/components/ui/input"
 import { alertsHistoryColumns } from "../../alerts-history-columns"
+import AlertsAnalytics from "./alerts-analytics"
 import { Checkbox } from "@/components/ui/checkbox"
 import { memo, useEffect, useState } from "react"
 import { Label } from "@/components/ui/label"
@@ -25,6 +26,7 @@ import {
 	ChevronRightIcon,
 	ChevronsLeftIcon,
 	ChevronsRightIcon,
+	CheckCheckIcon,
 	DownloadIcon,
 	Trash2Icon,
 } from "lucide-react"
@@ -50,7 +52,7 @@ const SectionIntro = memo(() => {
 				<Trans>Alert History</Trans>
 			</h3>
 			<p className="text-sm text-muted-foreground leading-relaxed">
-				<Trans>View your 200 most recent alerts.</Trans>
+				<Trans>View your 200 most recent alerts and how quickly they were acknowledged and resolved.</Trans>
 			</p>
 		</div>
 	)
@@ -70,7 +72,7 @@ export default function AlertsHistoryDataTable() {
 		let unsubscribe: (() => void) | undefined
 		const pbOptions = {
 			expand: "system",
-			fields: "id,name,value,state,created,resolved,expand.system.name",
+			fields: "id,name,value,state,created,resolved,acknowledged,expand.system.name",
 		}
 		// Initial load
 		pb.collection<AlertsHistoryRecord>("alerts_history")
@@ -183,6 +185,21 @@ export default function AlertsHistoryDataTable() {
 		}
 	}
 
+	// Bulk acknowledge handler
+	const handleAcknowledge = async () => {
+		const ids = table.getSelectedRowModel().rows.map((row) => row.original.id)
+		try {
+			await pb.send("/api/beszel/alert-history/ack", { method: "POST", body: { ids } })
+			table.resetRowSelection()
+		} catch (e) {
+			toast({
+				variant: "destructive",
+				title: t`Error`,
+				description: t`Failed to acknowledge alerts.`,
+			})
+		}
+	}
+
 	// Export to CSV handler
 	const handleExportCSV = () => {
 		const selectedRows = table.getSelectedRowModel().rows
@@ -193,6 +210,7 @@ export default function AlertsHistoryDataTable() {
 			value: (record) => record.value + (alertInfo[record.name]?.unit ?? ""),
 			state: (record) => (record.resolved ? t`Resolved` : t`Active`),
 			created: (record) => formatShortDate(record.created),
+			acknowledged: (record) => (record.acknowledged ? formatShortDate(record.acknowledged) : ""),
 			resolved: (record) => (record.resolved ? formatShortDate(record.resolved) : ""),
 			duration: (record) => (record.resolved ? formatDuration(record.created, record.resolved) : ""),
 		}
@@ -216,11 +234,14 @@ export default function AlertsHistoryDataTable() {
 
 	return (
 		<div className="@container w-full">
-			<div className="@3xl:flex items-end mb-4 gap-4">
+			<div className="mb-4">
 				<SectionIntro />
+			</div>
+			<AlertsAnalytics />
+			<div className="@3xl:flex items-end mb-4 gap-4">
 				<div className="flex items-center gap-2 ms-auto mt-3 @3xl:mt-0">
 					{table.getFilteredSelectedRowModel().rows.length > 0 && (
-						<div className="fixed bottom-0 left-0 w-full p-4 grid grid-cols-2 items-center gap-4 z-50 backdrop-blur-md shrink-0 @lg:static @lg:p-0 @lg:w-auto @lg:gap-3">
+						<div className="fixed bottom-0 left-0 w-full p-4 grid grid-cols-3 items-center gap-4 z-50 backdrop-blur-md shrink-0 @lg:static @lg:p-0 @lg:w-auto @lg:gap-3">
 							<AlertDialog open={deleteOpen} onOpenChange={(open) => setDeleteDialogOpen(open)}>
 								<AlertDialogTrigger asChild>
 									<Button variant="destructive" className="h-9 shrink-0">
@@ -252,6 +273,12 @@ export default function AlertsHistoryDataTable() {
 									</AlertDialogFooter>
 								</AlertDialogContent>
 							</AlertDialog>
+							<Button variant="outline" className="h-10" onClick={handleAcknowledge}>
+								<CheckCheckIcon className="size-4" />
+								<span className="ms-1">
+									<Trans>Acknowledge</Trans>
+								</span>
+							</Button>
 							<Button variant="outline" className="h-10" onClick={handleExportCSV}>
 								<DownloadIcon className="size-4" />
 								<span className="ms-1">
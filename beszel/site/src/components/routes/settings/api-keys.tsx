@@ -0,0 +1,150 @@
+import { t } from "@lingui/core/macro"
+import { Trans } from "@lingui/react/macro"
+import { memo, useEffect, useState } from "react"
+import { TrashIcon } from "lucide-react"
+import { Button } from "@/components/ui/button"
+import { Checkbox } from "@/components/ui/checkbox"
+import { Input } from "@/components/ui/input"
+import { InputCopy } from "@/components/ui/input-copy"
+import { Label } from "@/components/ui/label"
+import { Separator } from "@/components/ui/separator"
+import { toast } from "@/components/ui/use-toast"
+import { pb } from "@/lib/stores"
+import { formatShortDate, isReadOnlyUser } from "@/lib/utils"
+import { ApiKeyRecord } from "@/types"
+
+const scopes = ["systems:read", "stats:read", "alerts:read", "alerts:write"]
+
+function showError(error: any) {
+	toast({
+		title: t`Failed to update API keys`,
+		description: error?.message,
+		variant: "destructive",
+	})
+}
+
+export default memo(function SettingsApiKeys() {
+	const [keys, setKeys] = useState([] as ApiKeyRecord[])
+	const [name, setName] = useState("")
+	const [keyScopes, setKeyScopes] = useState(["systems:read", "stats:read"])
+	const [expires, setExpires] = useState("")
+	// readonly users can't change alerts
+	const availableScopes = isReadOnlyUser() ? scopes.filter((scope) => scope !== "alerts:write") : scopes
+
+	function refresh() {
+		pb.collection<ApiKeyRecord>("api_keys").getFullList({ sort: "-created" }).then(setKeys).catch(showError)
+	}
+
+	useEffect(refresh, [])
+
+	async function createKey(e: React.FormEvent) {
+		e.preventDefault()
+		try {
+			await pb.collection("api_keys").create({
+				user: pb.authStore.record!.id,
+				name,
+				scopes: keyScopes,
+				expires: expires ? new Date(expires).toISOString() : "",
+			})
+			setName("")
+			setExpires("")
+			refresh()
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	async function deleteKey(id: string) {
+		try {
+			await pb.collection("api_keys").delete(id)
+			setKeys(keys.filter((key) => key.id !== id))
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	return (
+		<div>
+			<div>
+				<h3 className="text-xl font-medium mb-2">
+					<Trans>API Keys</Trans>
+				</h3>
+				<p className="text-sm text-muted-foreground leading-relaxed">
+					<Trans>
+						API keys give scripts access to the REST API at <code className="font-mono">/api/v1</code> as you,
+						limited to the selected scopes. Send the key in the <code className="font-mono">X-API-Key</code>{" "}
+						header.
+					</Trans>
+				</p>
+			</div>
+			<Separator className="my-4" />
+			<form onSubmit={createKey} className="grid gap-4">
+				<div className="grid sm:grid-cols-2 gap-3">
+					<div className="grid gap-1.5">
+						<Label htmlFor="api-key-name">
+							<Trans>Name</Trans>
+						</Label>
+						<Input id="api-key-name" required maxLength={64} value={name} onChange={(e) => setName(e.target.value)} />
+					</div>
+					<div className="grid gap-1.5">
+						<Label htmlFor="api-key-expires">
+							<Trans>Expires</Trans>
+						</Label>
+						<Input id="api-key-expires" type="date" value={expires} onChange={(e) => setExpires(e.target.value)} />
+					</div>
+				</div>
+				<div className="grid gap-1.5">
+					<Label>
+						<Trans>Scopes</Trans>
+					</Label>
+					<div className="flex flex-wrap gap-x-4 gap-y-2">
+						{availableScopes.map((scope) => (
+							<label key={scope} className="flex items-center gap-2 text-sm font-mono">
+								<Checkbox
+									checked={keyScopes.includes(scope)}
+									onCheckedChange={(checked) =>
+										setKeyScopes(checked ? [...keyScopes, scope] : keyScopes.filter((s) => s !== scope))
+									}
+								/>
+								{scope}
+							</label>
+						))}
+					</div>
+				</div>
+				<div>
+					<Button type="submit" disabled={!keyScopes.length}>
+						<Trans>Create key</Trans>
+					</Button>
+				</div>
+			</form>
+			{keys.length > 0 && (
+				<div className="grid gap-2 border-t mt-5 pt-4">
+					{keys.map((key) => (
+						<div key={key.id} className="flex items-center gap-2 text-sm">
+							<div className="min-w-0 grow">
+								<div className="truncate font-medium">{key.name}</div>
+								<div className="text-muted-foreground truncate">
+									{key.scopes.join(", ")}
+									{key.expires && ` · ${t`Expires`} ${formatShortDate(key.expires)}`}
+									{key.last_used && ` · ${t`Last used`} ${formatShortDate(key.last_used)}`}
+								</div>
+							</div>
+							<div className="w-56 shrink-0">
+								<InputCopy value={key.token} id={`api-key-${key.id}`} name="api-key" />
+							</div>
+							<Button
+								variant="ghost"
+								size="icon"
+								aria-label={t`Delete`}
+								title={t`Delete`}
+								onClick={() => deleteKey(key.id)}
+							>
+								<TrashIcon className="size-4" />
+							</Button>
+						</div>
+					))}
+				</div>
+			)}
+		</div>
+	)
+})
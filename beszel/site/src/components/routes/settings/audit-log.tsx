@@ -0,0 +1,144 @@
+import { t } from "@lingui/core/macro"
+import { Trans } from "@lingui/react/macro"
+import { DownloadIcon } from "lucide-react"
+import { memo, useEffect, useState } from "react"
+import { Button } from "@/components/ui/button"
+import { Input } from "@/components/ui/input"
+import { Separator } from "@/components/ui/separator"
+import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@/components/ui/table"
+import { toast } from "@/components/ui/use-toast"
+import { pb } from "@/lib/stores"
+import { formatShortDate } from "@/lib/utils"
+import { AuditLogEntry } from "@/types"
+
+/** changed fields, login method or request path of an entry */
+function formatDetails(entry: AuditLogEntry) {
+	const details = entry.details
+	if (!details) {
+		return ""
+	}
+	if (details.changes) {
+		return Object.keys(details.changes).join(", ")
+	}
+	if (details.path) {
+		return `${details.method} ${details.path}`
+	}
+	return details.method ?? details.identity ?? details.token ?? ""
+}
+
+export default memo(function SettingsAuditLog() {
+	const [entries, setEntries] = useState([] as AuditLogEntry[])
+	const [filter, setFilter] = useState("")
+	const [loading, setLoading] = useState(false)
+
+	useEffect(() => {
+		pb.send<AuditLogEntry[]>("/api/beszel/audit-log", {})
+			.then(setEntries)
+			.catch((error) =>
+				toast({ title: t`Failed to load the audit log`, description: error?.message, variant: "destructive" })
+			)
+	}, [])
+
+	async function download() {
+		setLoading(true)
+		try {
+			const res = await fetch(pb.buildURL("/api/beszel/audit-log?format=csv"), {
+				headers: { Authorization: pb.authStore.token },
+			})
+			if (!res.ok) {
+				throw new Error((await res.json())?.message)
+			}
+			// file name from the hub, e.g. audit_log_20250602-0700.csv
+			const filename = res.headers.get("Content-Disposition")?.match(/filename="(.+)"/)?.[1]
+			const url = URL.createObjectURL(await res.blob())
+			const a = document.createElement("a")
+			a.href = url
+			a.download = filename ?? "audit_log.csv"
+			a.click()
+			URL.revokeObjectURL(url)
+		} catch (error: any) {
+			toast({
+				title: t`Failed to export data`,
+				description: error?.message || t`Please check logs for more details.`,
+				variant: "destructive",
+			})
+		} finally {
+			setLoading(false)
+		}
+	}
+
+	const search = filter.trim().toLowerCase()
+	const filtered = search
+		? entries.filter((entry) =>
+				[entry.email, entry.action, entry.name, entry.ip].some((value) => value?.toLowerCase().includes(search))
+			)
+		: entries
+
+	return (
+		<div>
+			<div>
+				<h3 className="text-xl font-medium mb-2">
+					<Trans>Audit Log</Trans>
+				</h3>
+				<p className="text-sm text-muted-foreground leading-relaxed">
+					<Trans>See who changed systems, alerts, users and tokens, and who logged in, in the last 30 days.</Trans>
+				</p>
+			</div>
+			<Separator className="my-4" />
+			<div className="flex gap-2 mb-3">
+				<Input placeholder={t`Filter...`} value={filter} onChange={(e) => setFilter(e.target.value)} />
+				<Button variant="outline" className="flex gap-1.5 shrink-0" onClick={download} disabled={loading}>
+					<DownloadIcon className="size-4" />
+					<Trans>Export CSV</Trans>
+				</Button>
+			</div>
+			<div className="rounded-md border overflow-hidden w-full">
+				<Table>
+					<TableHeader>
+						<TableRow>
+							<TableHead>
+								<Trans>Time</Trans>
+							</TableHead>
+							<TableHead>
+								<Trans>User</Trans>
+							</TableHead>
+							<TableHead>
+								<Trans>Action</Trans>
+							</TableHead>
+							<TableHead>
+								<Trans>Record</Trans>
+							</TableHead>
+							<TableHead>
+								<Trans>Details</Trans>
+							</TableHead>
+							<TableHead>
+								<Trans>IP</Trans>
+							</TableHead>
+						</TableRow>
+					</TableHeader>
+					<TableBody>
+						{filtered.map((entry) => (
+							<TableRow key={entry.id}>
+								<TableCell className="py-2.5 tabular-nums whitespace-nowrap">
+									{formatShortDate(entry.created)}
+								</TableCell>
+								<TableCell className="py-2.5">{entry.email}</TableCell>
+								<TableCell className="py-2.5 font-mono text-xs">{entry.action}</TableCell>
+								<TableCell className="py-2.5 font-medium">{entry.name}</TableCell>
+								<TableCell className="py-2.5 text-muted-foreground">{formatDetails(entry)}</TableCell>
+								<TableCell className="py-2.5 tabular-nums">{entry.ip}</TableCell>
+							</TableRow>
+						))}
+						{filtered.length === 0 && (
+							<TableRow>
+								<TableCell colSpan={6} className="h-24 text-center">
+									<Trans>No audit log entries.</Trans>
+								</TableCell>
+							</TableRow>
+						)}
+					</TableBody>
+				</Table>
+			</div>
+		</div>
+	)
+})
@@ -54,13 +54,13 @@ export default function ConfigYaml() {
 				<div className="mb-4">
 					<p className="text-sm text-muted-foreground leading-relaxed my-1">
 						<Trans>
-							Systems may be managed in a <code className="bg-muted rounded-sm px-1 text-primary">config.yml</code> file
-							inside your data directory.
+							Systems, users, and alerts may be managed in a{" "}
+							<code className="bg-muted rounded-sm px-1 text-primary">config.yml</code> file inside your data directory.
 						</Trans>
 					</p>
 					<p className="text-sm text-muted-foreground leading-relaxed">
 						<Trans>
-							On each restart, systems in the database will be updated to match the systems defined in the file.
+							On each restart, systems, users, and alerts in the database will be updated to match the file.
 						</Trans>
 					</p>
 					<Alert className="my-4 border-destructive text-destructive w-auto table md:pe-6">
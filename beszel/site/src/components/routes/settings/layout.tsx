@@ -7,7 +7,20 @@ import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/com
 import { useStore } from "@nanostores/react"
 import { $router } from "@/components/router.tsx"
 import { getPagePath, redirectPage } from "@nanostores/router"
-import { BellIcon, FileSlidersIcon, FingerprintIcon, SettingsIcon, AlertOctagonIcon } from "lucide-react"
+import {
+	BellIcon,
+	FileSlidersIcon,
+	FingerprintIcon,
+	SettingsIcon,
+	AlertOctagonIcon,
+	ActivityIcon,
+	SirenIcon,
+	WrenchIcon,
+	KeyRoundIcon,
+	UsersIcon,
+	HistoryIcon,
+	ScrollTextIcon,
+} from "lucide-react"
 import { $userSettings, pb } from "@/lib/stores.ts"
 import { toast } from "@/components/ui/use-toast.ts"
 import { UserSettings } from "@/types"
@@ -17,6 +30,13 @@ import ConfigYaml from "./config-yaml.tsx"
 import { useLingui } from "@lingui/react/macro"
 import Fingerprints from "./tokens-fingerprints.tsx"
 import AlertsHistoryDataTable from "./alerts-history-data-table"
+import StatusPages from "./status-pages.tsx"
+import AlertRules from "./alert-rules.tsx"
+import Maintenance from "./maintenance.tsx"
+import ApiKeys from "./api-keys.tsx"
+import Teams from "./teams.tsx"
+import AlertChanges from "./alert-changes.tsx"
+import AuditLog from "./audit-log.tsx"
 
 export async function saveSettings(newSettings: Partial<UserSettings>) {
 	try {
@@ -66,11 +86,50 @@ export default function SettingsLayout() {
 			icon: FingerprintIcon,
 			noReadOnly: true,
 		},
+		{
+			title: t`Alert Rules`,
+			href: getPagePath($router, "settings", { name: "alert-rules" }),
+			icon: SirenIcon,
+			noReadOnly: true,
+		},
+		{
+			title: t`Maintenance`,
+			href: getPagePath($router, "settings", { name: "maintenance" }),
+			icon: WrenchIcon,
+			noReadOnly: true,
+		},
+		{
+			title: t`Status Pages`,
+			href: getPagePath($router, "settings", { name: "status-pages" }),
+			icon: ActivityIcon,
+			noReadOnly: true,
+		},
+		{
+			title: t`API Keys`,
+			href: getPagePath($router, "settings", { name: "api-keys" }),
+			icon: KeyRoundIcon,
+		},
+		{
+			title: t`Teams`,
+			href: getPagePath($router, "settings", { name: "teams" }),
+			icon: UsersIcon,
+		},
 		{
 			title: t`Alert History`,
 			href: getPagePath($router, "settings", { name: "alert-history" }),
 			icon: AlertOctagonIcon,
 		},
+		{
+			title: t`Alert Changes`,
+			href: getPagePath($router, "settings", { name: "alert-changes" }),
+			icon: HistoryIcon,
+		},
+		{
+			title: t`Audit Log`,
+			href: getPagePath($router, "settings", { name: "audit-log" }),
+			icon: ScrollTextIcon,
+			admin: true,
+		},
 		{
 			title: t`YAML Config`,
 			href: getPagePath($router, "settings", { name: "config" }),
@@ -127,7 +186,21 @@ function SettingsContent({ name }: { name: string }) {
 			return <ConfigYaml />
 		case "tokens":
 			return <Fingerprints />
+		case "alert-rules":
+			return <AlertRules />
+		case "maintenance":
+			return <Maintenance />
+		case "status-pages":
+			return <StatusPages />
+		case "api-keys":
+			return <ApiKeys />
+		case "teams":
+			return <Teams />
 		case "alert-history":
 			return <AlertsHistoryDataTable />
+		case "alert-changes":
+			return <AlertChanges />
+		case "audit-log":
+			return <AuditLog />
 	}
 }
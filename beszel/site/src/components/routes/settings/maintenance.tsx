@@ -0,0 +1,280 @@
+import { t } from "@lingui/core/macro"
+import { Trans } from "@lingui/react/macro"
+import { memo, useEffect, useState } from "react"
+import { useStore } from "@nanostores/react"
+import { redirectPage } from "@nanostores/router"
+import { TrashIcon } from "lucide-react"
+import { $router } from "@/components/router"
+import { Button } from "@/components/ui/button"
+import { Checkbox } from "@/components/ui/checkbox"
+import { Input } from "@/components/ui/input"
+import { Label } from "@/components/ui/label"
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
+import { Separator } from "@/components/ui/separator"
+import { toast } from "@/components/ui/use-toast"
+import { $systems, pb } from "@/lib/stores"
+import { formatLabels, formatShortDate, isReadOnlyUser, parseLabels } from "@/lib/utils"
+import { MaintenanceWindowRecord } from "@/types"
+
+function showError(error: any) {
+	toast({
+		title: t`Failed to update maintenance windows`,
+		description: error?.message,
+		variant: "destructive",
+	})
+}
+
+/** Describes when a window is active, e.g. "0 3 * * 0 (Europe/Berlin) · 60 min" */
+function formatWhen(window: MaintenanceWindowRecord) {
+	if (window.schedule) {
+		return `${window.schedule} (${window.timezone || "UTC"}) · ${t`${window.duration} min`}`
+	}
+	return `${formatShortDate(window.start)} – ${formatShortDate(window.end)}`
+}
+
+export default memo(function SettingsMaintenance() {
+	if (isReadOnlyUser()) {
+		redirectPage($router, "settings", { name: "general" })
+	}
+	const systems = useStore($systems)
+	const [windows, setWindows] = useState([] as MaintenanceWindowRecord[])
+	const [name, setName] = useState("")
+	const [mode, setMode] = useState<"suppress" | "mark">("suppress")
+	const [recurring, setRecurring] = useState(false)
+	const [start, setStart] = useState("")
+	const [end, setEnd] = useState("")
+	const [schedule, setSchedule] = useState("0 3 * * 0")
+	const [duration, setDuration] = useState(60)
+	const [timezone, setTimezone] = useState(() => Intl.DateTimeFormat().resolvedOptions().timeZone)
+	const [systemIds, setSystemIds] = useState([] as string[])
+	const [labels, setLabels] = useState("")
+
+	function refresh() {
+		pb.collection<MaintenanceWindowRecord>("maintenance_windows")
+			.getFullList({ sort: "name" })
+			.then(setWindows)
+			.catch(showError)
+	}
+
+	useEffect(refresh, [])
+
+	async function createWindow(e: React.FormEvent) {
+		e.preventDefault()
+		try {
+			await pb.collection("maintenance_windows").create({
+				user: pb.authStore.record!.id,
+				name,
+				mode,
+				...(recurring
+					? { schedule, duration, timezone }
+					: { start: new Date(start).toISOString(), end: new Date(end).toISOString() }),
+				systems: systemIds,
+				labels: parseLabels(labels),
+			})
+			setName("")
+			setSystemIds([])
+			setLabels("")
+			refresh()
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	async function deleteWindow(id: string) {
+		try {
+			await pb.collection("maintenance_windows").delete(id)
+			setWindows(windows.filter((window) => window.id !== id))
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	const systemNames = (ids: string[] | null) =>
+		(ids ?? []).map((id) => systems.find((system) => system.id === id)?.name ?? id).join(", ")
+
+	return (
+		<div>
+			<div>
+				<h3 className="text-xl font-medium mb-2">
+					<Trans>Maintenance Windows</Trans>
+				</h3>
+				<p className="text-sm text-muted-foreground leading-relaxed">
+					<Trans>
+						Alerts are suppressed, or sent marked as maintenance, while a window is active. Windows are either one-off
+						or recur at the times of a cron expression. They apply to the selected systems and to systems with all of
+						their labels, or to all systems if neither is set.
+					</Trans>
+				</p>
+			</div>
+			<Separator className="my-4" />
+			<form onSubmit={createWindow} className="grid gap-4">
+				<div className="grid sm:grid-cols-2 gap-3">
+					<div className="grid gap-1.5">
+						<Label htmlFor="maintenance-name">
+							<Trans>Name</Trans>
+						</Label>
+						<Input id="maintenance-name" required maxLength={64} value={name} onChange={(e) => setName(e.target.value)} />
+					</div>
+					<div className="grid gap-1.5">
+						<Label htmlFor="maintenance-mode">
+							<Trans>Alerts</Trans>
+						</Label>
+						<Select value={mode} onValueChange={(value) => setMode(value as "suppress" | "mark")}>
+							<SelectTrigger id="maintenance-mode">
+								<SelectValue />
+							</SelectTrigger>
+							<SelectContent>
+								<SelectItem value="suppress">
+									<Trans>Suppress</Trans>
+								</SelectItem>
+								<SelectItem value="mark">
+									<Trans>Mark as maintenance</Trans>
+								</SelectItem>
+							</SelectContent>
+						</Select>
+					</div>
+				</div>
+				<label className="flex items-center gap-2 text-sm">
+					<Checkbox checked={recurring} onCheckedChange={(checked) => setRecurring(!!checked)} />
+					<Trans>Recurring</Trans>
+				</label>
+				{recurring ? (
+					<div className="grid sm:grid-cols-3 gap-3">
+						<div className="grid gap-1.5">
+							<Label htmlFor="maintenance-schedule">
+								<Trans>Cron expression</Trans>
+							</Label>
+							<Input
+								id="maintenance-schedule"
+								required
+								maxLength={128}
+								className="font-mono"
+								value={schedule}
+								onChange={(e) => setSchedule(e.target.value)}
+							/>
+						</div>
+						<div className="grid gap-1.5">
+							<Label htmlFor="maintenance-duration">
+								<Trans>Duration (minutes)</Trans>
+							</Label>
+							<Input
+								id="maintenance-duration"
+								type="number"
+								required
+								min={1}
+								max={10080}
+								value={duration}
+								onChange={(e) => setDuration(Number(e.target.value))}
+							/>
+						</div>
+						<div className="grid gap-1.5">
+							<Label htmlFor="maintenance-timezone">
+								<Trans>Time zone</Trans>
+							</Label>
+							<Input
+								id="maintenance-timezone"
+								maxLength={64}
+								placeholder="UTC"
+								value={timezone}
+								onChange={(e) => setTimezone(e.target.value)}
+							/>
+						</div>
+					</div>
+				) : (
+					<div className="grid sm:grid-cols-2 gap-3">
+						<div className="grid gap-1.5">
+							<Label htmlFor="maintenance-start">
+								<Trans>Start</Trans>
+							</Label>
+							<Input
+								id="maintenance-start"
+								type="datetime-local"
+								required
+								value={start}
+								onChange={(e) => setStart(e.target.value)}
+							/>
+						</div>
+						<div className="grid gap-1.5">
+							<Label htmlFor="maintenance-end">
+								<Trans>End</Trans>
+							</Label>
+							<Input
+								id="maintenance-end"
+								type="datetime-local"
+								required
+								min={start}
+								value={end}
+								onChange={(e) => setEnd(e.target.value)}
+							/>
+						</div>
+					</div>
+				)}
+				<div className="grid gap-1.5">
+					<Label>
+						<Trans>Systems</Trans>
+					</Label>
+					<div className="flex flex-wrap gap-x-4 gap-y-2">
+						{systems.map((system) => (
+							<label key={system.id} className="flex items-center gap-2 text-sm">
+								<Checkbox
+									checked={systemIds.includes(system.id)}
+									onCheckedChange={(checked) =>
+										setSystemIds(checked ? [...systemIds, system.id] : systemIds.filter((id) => id !== system.id))
+									}
+								/>
+								{system.name}
+							</label>
+						))}
+					</div>
+				</div>
+				<div className="grid gap-1.5">
+					<Label htmlFor="maintenance-labels">
+						<Trans>Labels</Trans>
+					</Label>
+					<Input
+						id="maintenance-labels"
+						placeholder="env=prod, site=*"
+						value={labels}
+						onChange={(e) => setLabels(e.target.value)}
+					/>
+				</div>
+				<div>
+					<Button type="submit">
+						<Trans>Create window</Trans>
+					</Button>
+				</div>
+			</form>
+			{windows.length > 0 && (
+				<div className="grid gap-2 border-t mt-5 pt-4">
+					{windows.map((window) => (
+						<div key={window.id} className="flex items-center gap-2 text-sm">
+							<div className="min-w-0 grow">
+								<div className="truncate font-medium">
+									{window.name}
+									<span className="text-muted-foreground font-normal">
+										{" · "}
+										{window.mode === "mark" ? <Trans>Mark as maintenance</Trans> : <Trans>Suppress</Trans>}
+									</span>
+								</div>
+								<div className="text-muted-foreground truncate">
+									{formatWhen(window)}
+									{window.systems.length > 0 && ` · ${systemNames(window.systems)}`}
+									{window.labels && Object.keys(window.labels).length > 0 && ` · ${formatLabels(window.labels)}`}
+								</div>
+							</div>
+							<Button
+								variant="ghost"
+								size="icon"
+								aria-label={t`Delete`}
+								title={t`Delete`}
+								onClick={() => deleteWindow(window.id)}
+							>
+								<TrashIcon className="size-4" />
+							</Button>
+						</div>
+					))}
+				</div>
+			)}
+		</div>
+	)
+})
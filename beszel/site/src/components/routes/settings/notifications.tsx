@@ -6,14 +6,17 @@ import { Label } from "@/components/ui/label"
 import { pb } from "@/lib/stores"
 import { Separator } from "@/components/ui/separator"
 import { Card } from "@/components/ui/card"
-import { BellIcon, LoaderCircleIcon, PlusIcon, SaveIcon, Trash2Icon } from "lucide-react"
+import { BellIcon, BracesIcon, LoaderCircleIcon, MailIcon, PlusIcon, SaveIcon, TagIcon, Trash2Icon } from "lucide-react"
 import { ChangeEventHandler, useEffect, useState } from "react"
 import { toast } from "@/components/ui/use-toast"
 import { InputTags } from "@/components/ui/input-tags"
-import { UserSettings } from "@/types"
+import { Checkbox } from "@/components/ui/checkbox"
+import { Textarea } from "@/components/ui/textarea"
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
+import { AlertSeverity, NotificationRoute, UserSettings, WebhookTemplate } from "@/types"
 import { saveSettings } from "./layout"
 import * as v from "valibot"
-import { isAdmin } from "@/lib/utils"
+import { formatLabels, isAdmin, parseLabels } from "@/lib/utils"
 import { prependBasePath } from "@/components/router"
 
 interface ShoutrrrUrlCardProps {
@@ -22,22 +25,140 @@ interface ShoutrrrUrlCardProps {
 	onRemove: () => void
 }
 
+// severities of alerts, from systems going down to notifications without an alert
+const severities: AlertSeverity[] = ["critical", "error", "warning", "info"]
+
 const NotificationSchema = v.object({
 	emails: v.array(v.pipe(v.string(), v.email())),
 	webhooks: v.array(v.pipe(v.string(), v.url())),
+	routes: v.array(
+		v.pipe(
+			v.object({
+				labels: v.record(v.string(), v.string()),
+				alerts: v.array(v.string()),
+				severities: v.array(v.picklist(severities)),
+				emails: v.array(v.pipe(v.string(), v.email())),
+				webhooks: v.array(v.pipe(v.string(), v.url())),
+			}),
+			v.check(
+				(route) => Object.keys(route.labels).length + route.alerts.length + route.severities.length > 0,
+				() => t`Routes require labels, alerts or severities`
+			)
+		)
+	),
+	flapWindow: v.pipe(v.number(), v.integer(), v.minValue(0), v.maxValue(1440)),
+	flapThreshold: v.pipe(v.number(), v.integer(), v.minValue(1), v.maxValue(100)),
+	webhookTemplates: v.array(
+		v.object({
+			name: v.pipe(
+				v.string(),
+				v.regex(/^[A-Za-z0-9-]+$/, () => t`Template names can only contain letters, digits and hyphens`)
+			),
+			url: v.pipe(v.string(), v.url()),
+			method: v.picklist(["POST", "PUT"]),
+			headers: v.record(v.string(), v.string()),
+			contentType: v.string(),
+			body: v.pipe(v.string(), v.nonEmpty()),
+		})
+	),
+	digest: v.picklist(["", "daily", "weekly"]),
 })
 
+// defaults of the hub when flap settings aren't set
+const defaultFlapWindow = 30
+const defaultFlapThreshold = 4
+
+/** A route being edited, with its labels as text and emails and URLs in one list */
+interface RouteInput {
+	labels: string
+	alerts: string[]
+	severities: AlertSeverity[]
+	destinations: string[]
+}
+
+const toRouteInput = (route: NotificationRoute): RouteInput => ({
+	labels: formatLabels(route.labels),
+	alerts: route.alerts ?? [],
+	severities: route.severities ?? [],
+	destinations: [...(route.emails ?? []), ...(route.webhooks ?? [])],
+})
+
+// destinations with a scheme are webhooks, others are email addresses
+const toRoute = (input: RouteInput): NotificationRoute => ({
+	labels: parseLabels(input.labels),
+	alerts: input.alerts,
+	severities: input.severities,
+	emails: input.destinations.filter((d) => !d.includes("://")),
+	webhooks: input.destinations.filter((d) => d.includes("://")),
+})
+
+/** A webhook template being edited, with its headers as "Name: value" lines */
+interface WebhookTemplateInput {
+	name: string
+	url: string
+	method: string
+	headers: string
+	contentType: string
+	body: string
+}
+
+const toTemplateInput = (template: WebhookTemplate): WebhookTemplateInput => ({
+	name: template.name,
+	url: template.url,
+	method: template.method || "POST",
+	headers: Object.entries(template.headers ?? {})
+		.map(([name, value]) => `${name}: ${value}`)
+		.join("\n"),
+	contentType: template.contentType || "application/json",
+	body: template.body,
+})
+
+const toTemplate = (input: WebhookTemplateInput): WebhookTemplate => {
+	const headers: Record<string, string> = {}
+	for (const line of input.headers.split("\n")) {
+		const [name, ...value] = line.split(":")
+		if (name.trim()) {
+			headers[name.trim()] = value.join(":").trim()
+		}
+	}
+	return { ...input, headers }
+}
+
+const newTemplateBody = `{
+  "title": {{json .Title}},
+  "message": {{json .Message}},
+  "system": {{json .System}},
+  "status": {{json .Status}}
+}`
+
 const SettingsNotificationsPage = ({ userSettings }: { userSettings: UserSettings }) => {
 	const [webhooks, setWebhooks] = useState(userSettings.webhooks ?? [])
 	const [emails, setEmails] = useState<string[]>(userSettings.emails ?? [])
+	const [routes, setRoutes] = useState<RouteInput[]>((userSettings.routes ?? []).map(toRouteInput))
+	const [flapWindow, setFlapWindow] = useState(userSettings.flapWindow ?? defaultFlapWindow)
+	const [flapThreshold, setFlapThreshold] = useState(userSettings.flapThreshold ?? defaultFlapThreshold)
+	const [templates, setTemplates] = useState<WebhookTemplateInput[]>(
+		(userSettings.webhookTemplates ?? []).map(toTemplateInput)
+	)
+	const [digest, setDigest] = useState(userSettings.digest ?? "")
 	const [isLoading, setIsLoading] = useState(false)
+	const [isSendingDigest, setIsSendingDigest] = useState(false)
 
 	// update values when userSettings changes
 	useEffect(() => {
 		setWebhooks(userSettings.webhooks ?? [])
 		setEmails(userSettings.emails ?? [])
+		setRoutes((userSettings.routes ?? []).map(toRouteInput))
+		setFlapWindow(userSettings.flapWindow ?? defaultFlapWindow)
+		setFlapThreshold(userSettings.flapThreshold ?? defaultFlapThreshold)
+		setTemplates((userSettings.webhookTemplates ?? []).map(toTemplateInput))
+		setDigest(userSettings.digest ?? "")
 	}, [userSettings])
 
+	function updateRoute(index: number, route: Partial<RouteInput>) {
+		setRoutes(routes.map((r, i) => (i === index ? { ...r, ...route } : r)))
+	}
+
 	function addWebhook() {
 		setWebhooks([...webhooks, ""])
 		// focus on the new input
@@ -57,7 +178,15 @@ const SettingsNotificationsPage = ({ userSettings }: { userSettings: UserSetting
 	async function updateSettings() {
 		setIsLoading(true)
 		try {
-			const parsedData = v.parse(NotificationSchema, { emails, webhooks })
+			const parsedData = v.parse(NotificationSchema, {
+				emails,
+				webhooks,
+				routes: routes.map(toRoute),
+				flapWindow,
+				flapThreshold,
+				webhookTemplates: templates.map(toTemplate),
+				digest,
+			})
 			await saveSettings(parsedData)
 		} catch (e: any) {
 			toast({
@@ -69,6 +198,23 @@ const SettingsNotificationsPage = ({ userSettings }: { userSettings: UserSetting
 		setIsLoading(false)
 	}
 
+	async function sendTestDigest() {
+		setIsSendingDigest(true)
+		try {
+			const res = await pb.send("/api/beszel/test-digest", {
+				method: "POST",
+				body: { period: digest || "daily", emails },
+			})
+			if (res.err) {
+				throw new Error(res.err)
+			}
+			toast({ title: t`Report sent`, description: t`Check your email inbox.` })
+		} catch (e: any) {
+			toast({ title: t`Failed to send report`, description: e.message, variant: "destructive" })
+		}
+		setIsSendingDigest(false)
+	}
+
 	return (
 		<div>
 			<div>
@@ -159,6 +305,188 @@ const SettingsNotificationsPage = ({ userSettings }: { userSettings: UserSetting
 					</Button>
 				</div>
 				<Separator />
+				<div className="space-y-3">
+					<div>
+						<h3 className="mb-1 text-lg font-medium">
+							<Trans>Notification routes</Trans>
+						</h3>
+						<p className="text-sm text-muted-foreground leading-relaxed">
+							<Trans>
+								Alerts that match a route are sent to its emails and URLs instead of the ones above. Routes match
+								systems with all of their labels, and can be limited to alert types and severities. Label values can
+								use wildcards, such as <code>site=ber*</code>.
+							</Trans>
+						</p>
+					</div>
+					{routes.length > 0 && (
+						<div className="grid gap-2.5">
+							{routes.map((route, index) => (
+								<RouteCard
+									key={index}
+									route={route}
+									onChange={(value) => updateRoute(index, value)}
+									onRemove={() => setRoutes(routes.filter((_, i) => i !== index))}
+								/>
+							))}
+						</div>
+					)}
+					<Button
+						type="button"
+						variant="outline"
+						size="sm"
+						className="mt-2 flex items-center gap-1"
+						onClick={() => setRoutes([...routes, { labels: "", alerts: [], severities: [], destinations: [] }])}
+					>
+						<PlusIcon className="h-4 w-4 -ms-0.5" />
+						<Trans>Add route</Trans>
+					</Button>
+				</div>
+				<Separator />
+				<div className="space-y-3">
+					<div>
+						<h3 className="mb-1 text-lg font-medium">
+							<Trans>Webhook templates</Trans>
+						</h3>
+						<p className="text-sm text-muted-foreground leading-relaxed">
+							<Trans>
+								Send alerts to services like n8n or Home Assistant with your own request body, written as a Go
+								template with fields such as <code>.Title</code>, <code>.System</code>, <code>.Status</code> and{" "}
+								<code>.Value</code>. Use <code>{"{{json .Title}}"}</code> to quote text in JSON. Add a template to
+								the URLs above or to routes as <code>template://name</code>.
+							</Trans>
+						</p>
+					</div>
+					{templates.length > 0 && (
+						<div className="grid gap-2.5">
+							{templates.map((template, index) => (
+								<WebhookTemplateCard
+									key={index}
+									template={template}
+									onChange={(value) =>
+										setTemplates(templates.map((tmpl, i) => (i === index ? { ...tmpl, ...value } : tmpl)))
+									}
+									onRemove={() => setTemplates(templates.filter((_, i) => i !== index))}
+								/>
+							))}
+						</div>
+					)}
+					<Button
+						type="button"
+						variant="outline"
+						size="sm"
+						className="mt-2 flex items-center gap-1"
+						onClick={() =>
+							setTemplates([
+								...templates,
+								{
+									name: "",
+									url: "",
+									method: "POST",
+									headers: "",
+									contentType: "application/json",
+									body: newTemplateBody,
+								},
+							])
+						}
+					>
+						<PlusIcon className="h-4 w-4 -ms-0.5" />
+						<Trans>Add template</Trans>
+					</Button>
+				</div>
+				<Separator />
+				<div className="grid gap-2">
+					<div className="mb-2">
+						<h3 className="mb-1 text-lg font-medium">
+							<Trans>Flapping alerts</Trans>
+						</h3>
+						<p className="text-sm text-muted-foreground leading-relaxed">
+							<Trans>
+								When an alert changes state more often than the threshold within the window, its notifications are
+								grouped into one with an occurrence count, sent once per window and when the alert settles. A window of
+								0 disables grouping.
+							</Trans>
+						</p>
+					</div>
+					<div className="grid sm:grid-cols-2 gap-3 sm:w-2/3">
+						<div className="grid gap-1.5">
+							<Label htmlFor="flap-window">
+								<Trans>Window (minutes)</Trans>
+							</Label>
+							<Input
+								id="flap-window"
+								type="number"
+								min={0}
+								max={1440}
+								value={flapWindow}
+								onChange={(e) => setFlapWindow(Number(e.target.value))}
+							/>
+						</div>
+						<div className="grid gap-1.5">
+							<Label htmlFor="flap-threshold">
+								<Trans>Threshold (notifications)</Trans>
+							</Label>
+							<Input
+								id="flap-threshold"
+								type="number"
+								min={1}
+								max={100}
+								value={flapThreshold}
+								onChange={(e) => setFlapThreshold(Number(e.target.value))}
+							/>
+						</div>
+					</div>
+				</div>
+				<Separator />
+				<div className="grid gap-2">
+					<div className="mb-2">
+						<h3 className="mb-1 text-lg font-medium">
+							<Trans>Digest reports</Trans>
+						</h3>
+						<p className="text-sm text-muted-foreground leading-relaxed">
+							<Trans>
+								Email a summary of your systems at 7:00 UTC, with uptime, average and peak CPU and temperature, disk
+								growth and alert counts. Weekly reports are sent on Mondays. Reports go to the emails above, or to
+								your account email if there are none.
+							</Trans>
+						</p>
+					</div>
+					<div className="flex items-center gap-2">
+						<Select
+							value={digest || "off"}
+							onValueChange={(value) => setDigest(value === "off" ? "" : (value as "daily" | "weekly"))}
+						>
+							<SelectTrigger className="w-40">
+								<SelectValue />
+							</SelectTrigger>
+							<SelectContent>
+								<SelectItem value="off">
+									<Trans>Off</Trans>
+								</SelectItem>
+								<SelectItem value="daily">
+									<Trans>Daily</Trans>
+								</SelectItem>
+								<SelectItem value="weekly">
+									<Trans>Weekly</Trans>
+								</SelectItem>
+							</SelectContent>
+						</Select>
+						<Button
+							type="button"
+							variant="outline"
+							className="flex items-center gap-1.5"
+							onClick={sendTestDigest}
+							disabled={isSendingDigest}
+						>
+							{isSendingDigest ? (
+								<LoaderCircleIcon className="h-4 w-4 animate-spin" />
+							) : (
+								<MailIcon className="h-4 w-4" />
+							)}
+							<Trans>Send now</Trans>
+						</Button>
+					</div>
+				</div>
+				<Separator />
 				<Button
 					type="button"
 					className="flex items-center gap-1.5 disabled:opacity-100"
@@ -173,6 +501,176 @@ const SettingsNotificationsPage = ({ userSettings }: { userSettings: UserSetting
 	)
 }
 
+interface WebhookTemplateCardProps {
+	template: WebhookTemplateInput
+	onChange: (template: Partial<WebhookTemplateInput>) => void
+	onRemove: () => void
+}
+
+const WebhookTemplateCard = ({ template, onChange, onRemove }: WebhookTemplateCardProps) => {
+	return (
+		<Card className="bg-muted/40 p-2 md:p-3 grid gap-2">
+			<div className="flex items-center gap-1">
+				<BracesIcon className="h-4 w-4 mx-1.5 shrink-0 text-muted-foreground" />
+				<Input
+					className="light:bg-card w-40 shrink-0"
+					placeholder={t`Name`}
+					value={template.name}
+					onChange={(e) => onChange({ name: e.target.value })}
+				/>
+				<Select value={template.method} onValueChange={(method) => onChange({ method })}>
+					<SelectTrigger className="light:bg-card w-24 shrink-0">
+						<SelectValue />
+					</SelectTrigger>
+					<SelectContent>
+						<SelectItem value="POST">POST</SelectItem>
+						<SelectItem value="PUT">PUT</SelectItem>
+					</SelectContent>
+				</Select>
+				<Input
+					type="url"
+					className="light:bg-card"
+					placeholder="https://n8n.example.com/webhook/alerts"
+					value={template.url}
+					onChange={(e) => onChange({ url: e.target.value })}
+				/>
+				<Button type="button" variant="outline" size="icon" className="shrink-0" aria-label="Delete" onClick={onRemove}>
+					<Trash2Icon className="h-4 w-4" />
+				</Button>
+			</div>
+			<div className="grid sm:grid-cols-2 gap-2">
+				<Textarea
+					dir="ltr"
+					spellCheck="false"
+					rows={2}
+					className="light:bg-card font-mono whitespace-pre"
+					placeholder="Authorization: Bearer token"
+					value={template.headers}
+					onChange={(e) => onChange({ headers: e.target.value })}
+				/>
+				<Input
+					className="light:bg-card"
+					placeholder="application/json"
+					value={template.contentType}
+					onChange={(e) => onChange({ contentType: e.target.value })}
+				/>
+			</div>
+			<Textarea
+				dir="ltr"
+				spellCheck="false"
+				rows={Math.min(15, Math.max(4, template.body.split("\n").length))}
+				className="light:bg-card font-mono whitespace-pre"
+				value={template.body}
+				onChange={(e) => onChange({ body: e.target.value })}
+			/>
+		</Card>
+	)
+}
+
+interface RouteCardProps {
+	route: RouteInput
+	onChange: (route: Partial<RouteInput>) => void
+	onRemove: () => void
+}
+
+const severityNames: Record<AlertSeverity, () => string> = {
+	critical: () => t`Critical`,
+	error: () => t`Error`,
+	warning: () => t`Warning`,
+	info: () => t`Info`,
+}
+
+const RouteCard = ({ route, onChange, onRemove }: RouteCardProps) => {
+	const [isLoading, setIsLoading] = useState(false)
+
+	const sendTestRoute = async () => {
+		setIsLoading(true)
+		try {
+			const res = await pb.send("/api/beszel/test-route", { method: "POST", body: toRoute(route) })
+			if ("err" in res && !res.err) {
+				toast({
+					title: t`Test notification sent`,
+					description: t`Check your notification services`,
+				})
+			} else {
+				toast({
+					title: t`Error`,
+					description: res.err ?? t`Failed to send test notification`,
+					variant: "destructive",
+				})
+			}
+		} catch (e: any) {
+			toast({ title: t`Error`, description: e.message, variant: "destructive" })
+		}
+		setIsLoading(false)
+	}
+
+	function toggleSeverity(severity: AlertSeverity, checked: boolean) {
+		onChange({
+			severities: severities.filter((s) => (s === severity ? checked : route.severities.includes(s))),
+		})
+	}
+
+	return (
+		<Card className="bg-muted/40 p-2 md:p-3 grid gap-2">
+			<div className="flex items-center gap-1">
+				<TagIcon className="h-4 w-4 mx-1.5 shrink-0 text-muted-foreground" />
+				<Input
+					className="light:bg-card"
+					placeholder="env=prod, site=berlin"
+					value={route.labels}
+					onChange={(e) => onChange({ labels: e.target.value })}
+				/>
+				<Button
+					type="button"
+					variant="outline"
+					disabled={isLoading || route.destinations.length === 0}
+					onClick={sendTestRoute}
+				>
+					{isLoading ? (
+						<LoaderCircleIcon className="h-4 w-4 animate-spin" />
+					) : (
+						<span>
+							<Trans>Test</Trans>
+						</span>
+					)}
+				</Button>
+				<Button type="button" variant="outline" size="icon" className="shrink-0" aria-label="Delete" onClick={onRemove}>
+					<Trash2Icon className="h-4 w-4" />
+				</Button>
+			</div>
+			<InputTags
+				value={route.alerts}
+				onChange={(value) => onChange({ alerts: typeof value === "function" ? value(route.alerts) : value })}
+				placeholder={t`All alerts, or alert names such as Status, CPU...`}
+				className="w-full light:bg-card"
+			/>
+			<div className="flex flex-wrap items-center gap-x-4 gap-y-1.5 px-1 text-sm">
+				<span className="text-muted-foreground">
+					<Trans>Severities</Trans>
+				</span>
+				{severities.map((severity) => (
+					<label key={severity} className="flex items-center gap-2">
+						<Checkbox
+							checked={route.severities.includes(severity)}
+							onCheckedChange={(checked) => toggleSeverity(severity, !!checked)}
+						/>
+						{severityNames[severity]()}
+					</label>
+				))}
+			</div>
+			<InputTags
+				value={route.destinations}
+				onChange={(value) =>
+					onChange({ destinations: typeof value === "function" ? value(route.destinations) : value })
+				}
+				placeholder={t`Enter email address or URL...`}
+				className="w-full light:bg-card"
+			/>
+		</Card>
+	)
+}
+
 const ShoutrrrUrlCard = ({ url, onUrlChange, onRemove }: ShoutrrrUrlCardProps) => {
 	const [isLoading, setIsLoading] = useState(false)
 
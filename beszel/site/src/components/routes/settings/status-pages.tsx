@@ -0,0 +1,193 @@
+import { t } from "@lingui/core/macro"
+import { Trans } from "@lingui/react/macro"
+import { memo, useEffect, useState } from "react"
+import { useStore } from "@nanostores/react"
+import { getPagePath, redirectPage } from "@nanostores/router"
+import { TrashIcon } from "lucide-react"
+import { $router } from "@/components/router"
+import { Button } from "@/components/ui/button"
+import { Checkbox } from "@/components/ui/checkbox"
+import { Input } from "@/components/ui/input"
+import { InputCopy } from "@/components/ui/input-copy"
+import { InputTags } from "@/components/ui/input-tags"
+import { Label } from "@/components/ui/label"
+import { Separator } from "@/components/ui/separator"
+import { toast } from "@/components/ui/use-toast"
+import { $systems, pb } from "@/lib/stores"
+import { isReadOnlyUser } from "@/lib/utils"
+import { StatusPageRecord } from "@/types"
+
+/** Absolute URL of a status page */
+function getStatusPageUrl(slug: string) {
+	return new URL(getPagePath($router, "status", { slug }), window.location.origin).href
+}
+
+function showError(error: any) {
+	toast({
+		title: t`Failed to update status pages`,
+		description: error?.response?.data?.slug ? t`The path is already used by another page.` : error?.message,
+		variant: "destructive",
+	})
+}
+
+export default memo(function SettingsStatusPages() {
+	if (isReadOnlyUser()) {
+		redirectPage($router, "settings", { name: "general" })
+	}
+	const systems = useStore($systems)
+	const [pages, setPages] = useState([] as StatusPageRecord[])
+	const [name, setName] = useState("")
+	const [slug, setSlug] = useState("")
+	const [domain, setDomain] = useState("")
+	const [systemIds, setSystemIds] = useState([] as string[])
+	const [sensors, setSensors] = useState([] as string[])
+
+	function refresh() {
+		pb.collection<StatusPageRecord>("status_pages")
+			.getFullList({ sort: "name" })
+			.then(setPages)
+			.catch(showError)
+	}
+
+	useEffect(refresh, [])
+
+	async function createPage(e: React.FormEvent) {
+		e.preventDefault()
+		try {
+			await pb.collection("status_pages").create({
+				user: pb.authStore.record!.id,
+				name,
+				slug,
+				domain,
+				systems: systemIds,
+				sensors,
+			})
+			setName("")
+			setSlug("")
+			setDomain("")
+			setSystemIds([])
+			setSensors([])
+			refresh()
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	async function deletePage(id: string) {
+		try {
+			await pb.collection("status_pages").delete(id)
+			setPages(pages.filter((page) => page.id !== id))
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	return (
+		<div>
+			<div>
+				<h3 className="text-xl font-medium mb-2">
+					<Trans>Status Pages</Trans>
+				</h3>
+				<p className="text-sm text-muted-foreground leading-relaxed">
+					<Trans>
+						Status pages show the status, CPU and memory usage, and chosen sensors of selected systems to anyone
+						with the link, without logging in.
+					</Trans>
+				</p>
+			</div>
+			<Separator className="my-4" />
+			<form onSubmit={createPage} className="grid gap-4">
+				<div className="grid sm:grid-cols-3 gap-3">
+					<div className="grid gap-1.5">
+						<Label htmlFor="status-name">
+							<Trans>Name</Trans>
+						</Label>
+						<Input id="status-name" required maxLength={64} value={name} onChange={(e) => setName(e.target.value)} />
+					</div>
+					<div className="grid gap-1.5">
+						<Label htmlFor="status-slug">
+							<Trans>Path</Trans>
+						</Label>
+						<Input
+							id="status-slug"
+							required
+							maxLength={64}
+							pattern="[a-z0-9][a-z0-9\-]*"
+							placeholder="acme"
+							value={slug}
+							onChange={(e) => setSlug(e.target.value.toLowerCase())}
+						/>
+					</div>
+					<div className="grid gap-1.5">
+						<Label htmlFor="status-domain">
+							<Trans>Custom domain</Trans>
+						</Label>
+						<Input
+							id="status-domain"
+							maxLength={253}
+							placeholder={t`Optional`}
+							value={domain}
+							onChange={(e) => setDomain(e.target.value.toLowerCase())}
+						/>
+					</div>
+				</div>
+				<div className="grid gap-1.5">
+					<Label>
+						<Trans>Systems</Trans>
+					</Label>
+					<div className="flex flex-wrap gap-x-4 gap-y-2">
+						{systems.map((system) => (
+							<label key={system.id} className="flex items-center gap-2 text-sm">
+								<Checkbox
+									checked={systemIds.includes(system.id)}
+									onCheckedChange={(checked) =>
+										setSystemIds(checked ? [...systemIds, system.id] : systemIds.filter((id) => id !== system.id))
+									}
+								/>
+								{system.name}
+							</label>
+						))}
+					</div>
+				</div>
+				<div className="grid gap-1.5">
+					<Label htmlFor="status-sensors">
+						<Trans>Sensors</Trans>
+					</Label>
+					<InputTags id="status-sensors" value={sensors} onChange={setSensors} placeholder={t`Sensor names`} />
+				</div>
+				<div>
+					<Button type="submit" disabled={!systemIds.length}>
+						<Trans>Create page</Trans>
+					</Button>
+				</div>
+			</form>
+			{pages.length > 0 && (
+				<div className="grid gap-2 border-t mt-5 pt-4">
+					{pages.map((page) => (
+						<div key={page.id} className="flex items-center gap-2 text-sm">
+							<div className="min-w-0 grow">
+								<div className="truncate font-medium">{page.name}</div>
+								<div className="text-muted-foreground truncate">
+									<Trans>{page.systems.length} systems</Trans>
+									{page.domain && ` · ${page.domain}`}
+								</div>
+							</div>
+							<div className="w-56 shrink-0">
+								<InputCopy value={getStatusPageUrl(page.slug)} id={`status-${page.id}`} name="status-url" />
+							</div>
+							<Button
+								variant="ghost"
+								size="icon"
+								aria-label={t`Delete`}
+								title={t`Delete`}
+								onClick={() => deletePage(page.id)}
+							>
+								<TrashIcon className="size-4" />
+							</Button>
+						</div>
+					))}
+				</div>
+			)}
+		</div>
+	)
+})
@@ -0,0 +1,245 @@
+import { t } from "@lingui/core/macro"
+import { Plural, Trans } from "@lingui/react/macro"
+import { memo, useEffect, useState } from "react"
+import { useStore } from "@nanostores/react"
+import { TrashIcon } from "lucide-react"
+import { Badge } from "@/components/ui/badge"
+import { Button } from "@/components/ui/button"
+import { Input } from "@/components/ui/input"
+import { InputTags } from "@/components/ui/input-tags"
+import { Label } from "@/components/ui/label"
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
+import { Separator } from "@/components/ui/separator"
+import { toast } from "@/components/ui/use-toast"
+import { $systems, pb } from "@/lib/stores"
+import { isAdmin } from "@/lib/utils"
+import { TeamMember, TeamRecord } from "@/types"
+
+function showError(error: any) {
+	toast({
+		title: t`Failed to update teams`,
+		description: error?.message,
+		variant: "destructive",
+	})
+}
+
+export default memo(function SettingsTeams() {
+	const [teams, setTeams] = useState([] as TeamRecord[])
+	const [name, setName] = useState("")
+
+	function refresh() {
+		pb.collection<TeamRecord>("teams").getFullList({ sort: "name" }).then(setTeams).catch(showError)
+	}
+
+	useEffect(refresh, [])
+
+	async function createTeam(e: React.FormEvent) {
+		e.preventDefault()
+		try {
+			await pb.collection("teams").create({ name })
+			setName("")
+			refresh()
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	async function deleteTeam(id: string) {
+		try {
+			await pb.collection("teams").delete(id)
+			setTeams(teams.filter((team) => team.id !== id))
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	return (
+		<div>
+			<div>
+				<h3 className="text-xl font-medium mb-2">
+					<Trans>Teams</Trans>
+				</h3>
+				<p className="text-sm text-muted-foreground leading-relaxed">
+					<Trans>
+						Members of a team can access the systems assigned to it in the system dialog, and the team's emails and
+						webhooks get the alerts of its systems. Team admins manage members and channels. Operators can view the
+						team's systems and acknowledge their alerts, but can't change them.
+					</Trans>
+				</p>
+			</div>
+			<Separator className="my-4" />
+			{isAdmin() && (
+				<form onSubmit={createTeam} className="flex items-end gap-3 mb-5">
+					<div className="grid gap-1.5 grow">
+						<Label htmlFor="team-name">
+							<Trans>Name</Trans>
+						</Label>
+						<Input id="team-name" required maxLength={64} value={name} onChange={(e) => setName(e.target.value)} />
+					</div>
+					<Button type="submit">
+						<Trans>Create team</Trans>
+					</Button>
+				</form>
+			)}
+			{teams.length === 0 && (
+				<p className="text-sm text-muted-foreground">
+					<Trans>You aren't a member of any team.</Trans>
+				</p>
+			)}
+			<div className="grid gap-4">
+				{teams.map((team) => (
+					<TeamCard key={team.id} team={team} onChange={refresh} onDelete={() => deleteTeam(team.id)} />
+				))}
+			</div>
+		</div>
+	)
+})
+
+function TeamCard({ team, onChange, onDelete }: { team: TeamRecord; onChange: () => void; onDelete: () => void }) {
+	const systems = useStore($systems).filter((system) => system.team === team.id)
+	const canManage = isAdmin() || team.admins.includes(pb.authStore.record!.id)
+	const [members, setMembers] = useState([] as TeamMember[])
+	const [email, setEmail] = useState("")
+	const [role, setRole] = useState("member" as "member" | "admin" | "operator")
+	const [emails, setEmails] = useState(team.emails ?? [])
+	const [webhooks, setWebhooks] = useState(team.webhooks ?? [])
+
+	function refreshMembers() {
+		pb.send<TeamMember[]>(`/api/beszel/teams/${team.id}/members`, {}).then(setMembers).catch(showError)
+	}
+
+	useEffect(refreshMembers, [team.id, team.members.length, team.operators?.length])
+
+	async function addMember(e: React.FormEvent) {
+		e.preventDefault()
+		try {
+			await pb.send(`/api/beszel/teams/${team.id}/members`, {
+				method: "POST",
+				body: { email, admin: role === "admin", operator: role === "operator" },
+			})
+			setEmail("")
+			setRole("member")
+			onChange()
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	async function removeMember(id: string) {
+		try {
+			await pb.send(`/api/beszel/teams/${team.id}/members/${id}`, { method: "DELETE" })
+			onChange()
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	async function saveChannels() {
+		try {
+			await pb.collection("teams").update(team.id, { emails, webhooks })
+			toast({ title: t`Settings saved` })
+		} catch (error) {
+			showError(error)
+		}
+	}
+
+	return (
+		<div className="rounded-md border p-4 grid gap-3">
+			<div className="flex items-center gap-2">
+				<div className="min-w-0 grow">
+					<div className="truncate font-medium">{team.name}</div>
+					<div className="text-sm text-muted-foreground truncate">
+						<Plural value={systems.length} one="# system" other="# systems" />
+						{systems.length > 0 && `: ${systems.map((system) => system.name).join(", ")}`}
+					</div>
+				</div>
+				{isAdmin() && (
+					<Button variant="ghost" size="icon" aria-label={t`Delete`} title={t`Delete`} onClick={onDelete}>
+						<TrashIcon className="size-4" />
+					</Button>
+				)}
+			</div>
+			<div className="grid gap-1">
+				{members.map((member) => (
+					<div key={member.id} className="flex items-center gap-2 text-sm h-8">
+						<span className="truncate">{member.email}</span>
+						{member.admin && (
+							<Badge variant="outline">
+								<Trans>Admin</Trans>
+							</Badge>
+						)}
+						{member.operator && (
+							<Badge variant="outline">
+								<Trans>Operator</Trans>
+							</Badge>
+						)}
+						{canManage && (
+							<Button
+								variant="ghost"
+								size="icon"
+								className="ms-auto size-8"
+								aria-label={t`Remove`}
+								title={t`Remove`}
+								onClick={() => removeMember(member.id)}
+							>
+								<TrashIcon className="size-4" />
+							</Button>
+						)}
+					</div>
+				))}
+			</div>
+			{canManage && (
+				<>
+					<form onSubmit={addMember} className="flex flex-wrap items-center gap-3">
+						<Input
+							type="email"
+							required
+							className="w-auto grow"
+							placeholder={t`Email of the user`}
+							value={email}
+							onChange={(e) => setEmail(e.target.value)}
+						/>
+						<Select value={role} onValueChange={(value) => setRole(value as typeof role)}>
+							<SelectTrigger className="w-36" aria-label={t`Role`}>
+								<SelectValue />
+							</SelectTrigger>
+							<SelectContent>
+								<SelectItem value="member">
+									<Trans>Member</Trans>
+								</SelectItem>
+								<SelectItem value="admin">
+									<Trans>Admin</Trans>
+								</SelectItem>
+								<SelectItem value="operator">
+									<Trans>Operator</Trans>
+								</SelectItem>
+							</SelectContent>
+						</Select>
+						<Button type="submit" variant="outline">
+							<Trans>Add member</Trans>
+						</Button>
+					</form>
+					<div className="grid sm:grid-cols-2 gap-3">
+						<div className="grid gap-1.5">
+							<Label htmlFor={`team-emails-${team.id}`}>
+								<Trans>Alert emails</Trans>
+							</Label>
+							<InputTags id={`team-emails-${team.id}`} value={emails} onChange={setEmails} type="email" />
+						</div>
+						<div className="grid gap-1.5">
+							<Label htmlFor={`team-webhooks-${team.id}`}>
+								<Trans>Alert webhooks</Trans>
+							</Label>
+							<InputTags id={`team-webhooks-${team.id}`} value={webhooks} onChange={setWebhooks} />
+						</div>
+					</div>
+					<div>
+						<Button variant="outline" onClick={saveChannels}>
+							<Trans>Save channels</Trans>
+						</Button>
+					</div>
+				</>
+			)}
+		</div>
+	)
+}
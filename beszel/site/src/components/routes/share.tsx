@@ -0,0 +1,239 @@
+import { Trans, useLingui } from "@lingui/react/macro"
+import { lazy, useEffect, useMemo, useState } from "react"
+import { useStore } from "@nanostores/react"
+import { $chartTime, $direction, $userSettings, pb } from "@/lib/stores"
+import { ChartData, ContainerStatsRecord, SharedSystem, SystemStatsRecord } from "@/types"
+import { ChartType } from "@/lib/enums"
+import { chartTimeData, cn, decimalString, formatBytes, formatShortDate, parseSemVer, toFixedFloat } from "@/lib/utils"
+import { Card } from "../ui/card"
+import ChartTimeSelect from "../charts/chart-time-select"
+import { addEmptyValues, ChartCard, getTimeData } from "./system"
+
+const AreaChartDefault = lazy(() => import("../charts/area-chart"))
+const ContainerChart = lazy(() => import("../charts/container-chart"))
+const MemChart = lazy(() => import("../charts/mem-chart"))
+const DiskChart = lazy(() => import("../charts/disk-chart"))
+const TemperatureChart = lazy(() => import("../charts/temperature-chart"))
+const NetworkInterfacesChart = lazy(() => import("../charts/network-interfaces-chart"))
+const CpuCoresChart = lazy(() => import("../charts/cpu-cores-chart"))
+
+// milliseconds between refreshes of the shared stats
+const refreshInterval = 60_000
+
+/** Read-only view of a system shared with a share link. Doesn't require login. */
+export default function SharedSystemPage({ token }: { token: string }) {
+	const { t } = useLingui()
+	const direction = useStore($direction)
+	const chartTime = useStore($chartTime)
+	const userSettings = useStore($userSettings)
+	const [shared, setShared] = useState<SharedSystem | null>(null)
+	const [notFound, setNotFound] = useState(false)
+
+	// get shared stats for the chart time and refresh them periodically
+	useEffect(() => {
+		const { type } = chartTimeData[chartTime]
+		const getShared = () =>
+			pb
+				.send<SharedSystem>(`/api/beszel/share/${encodeURIComponent(token)}`, { query: { type }, requestKey: null })
+				.then((data) => {
+					setShared(data)
+					setNotFound(false)
+				})
+				.catch((err) => err.status === 404 && setNotFound(true))
+		getShared()
+		const interval = setInterval(getShared, refreshInterval)
+		return () => clearInterval(interval)
+	}, [token, chartTime])
+
+	useEffect(() => {
+		if (shared) {
+			document.title = `${shared.name} / Beszel`
+		}
+	}, [shared?.name])
+
+	const chartData: ChartData = useMemo(() => {
+		const { expectedInterval } = chartTimeData[chartTime]
+		const systemStats = addEmptyValues([] as SystemStatsRecord[], shared?.stats ?? [], expectedInterval)
+		const containerStats = addEmptyValues([] as ContainerStatsRecord[], shared?.containers ?? [], expectedInterval)
+		const containerData = containerStats.map(({ created, stats }) => {
+			const data = { created } as ChartData["containerData"][0]
+			for (const container of stats ?? []) {
+				data[container.n] = container
+			}
+			return data
+		})
+		return {
+			systemStats,
+			containerData,
+			events: [],
+			chartTime,
+			orientation: direction === "rtl" ? "right" : "left",
+			...getTimeData(chartTime, (systemStats.at(-1)?.created as number) ?? 0),
+			agentVersion: parseSemVer(shared?.info.v),
+		}
+	}, [shared, direction])
+
+	if (notFound) {
+		return (
+			<div className="container my-14 text-center">
+				<h1 className="text-2xl font-semibold mb-2">
+					<Trans>Share link not found</Trans>
+				</h1>
+				<p className="text-muted-foreground">
+					<Trans>The link has expired or was revoked.</Trans>
+				</p>
+			</div>
+		)
+	}
+	if (!shared) {
+		return null
+	}
+
+	const has = (metric: SharedSystem["metrics"][number]) => shared.metrics.includes(metric)
+	const dataEmpty = chartData.systemStats.length === 0
+	const lastStats = chartData.systemStats.at(-1)?.stats
+	const hasNetworkInterfaces = chartData.systemStats.some((record) => Object.keys(record.stats?.ni ?? {}).length > 1)
+	const hasContainers = has("containers") && chartData.containerData.length > 0
+
+	return (
+		<div className="container my-6 grid gap-4">
+			<Card>
+				<div className="grid sm:flex gap-4 px-4 sm:px-6 pt-3 sm:pt-4 pb-5">
+					<div>
+						<h1 className="text-[1.6rem] font-semibold mb-1.5">{shared.name}</h1>
+						<div className="flex flex-wrap items-center gap-3 text-sm opacity-90">
+							<span
+								className={cn("inline-flex rounded-full h-3 w-3", {
+									"bg-green-500": shared.status === "up",
+									"bg-red-500": shared.status === "down",
+									"bg-primary/40": shared.status === "paused",
+									"bg-yellow-500": shared.status === "pending",
+								})}
+							></span>
+							<span className="capitalize">{shared.status}</span>
+							<span className="text-muted-foreground">
+								<Trans>Shared until {formatShortDate(shared.expires)}</Trans>
+							</span>
+						</div>
+					</div>
+					<div className="sm:ms-auto flex items-center">
+						<ChartTimeSelect className="w-full sm:w-40" />
+					</div>
+				</div>
+			</Card>
+
+			<div className="grid xl:grid-cols-2 gap-4">
+				{has("cpu") && (
+					<ChartCard grid empty={dataEmpty} title={t`CPU Usage`} description={t`Average system-wide CPU utilization`}>
+						<AreaChartDefault
+							chartData={chartData}
+							dataPoints={[{ label: t`CPU Usage`, dataKey: ({ stats }) => stats?.cpu, color: "1", opacity: 0.4 }]}
+							tickFormatter={(val) => toFixedFloat(val, 2) + "%"}
+							contentFormatter={({ value }) => decimalString(value) + "%"}
+						/>
+					</ChartCard>
+				)}
+
+				{has("cpu") && chartData.systemStats.some((record) => record.stats?.cpc?.length) && (
+					<ChartCard grid empty={dataEmpty} title={t`CPU Cores`} description={t`Average utilization of each CPU core`}>
+						<CpuCoresChart chartData={chartData} dataKey="cpc" />
+					</ChartCard>
+				)}
+
+				{has("memory") && (
+					<ChartCard grid empty={dataEmpty} title={t`Memory Usage`} description={t`Precise utilization at the recorded time`}>
+						<MemChart chartData={chartData} />
+					</ChartCard>
+				)}
+
+				{has("disk") && (
+					<>
+						<ChartCard grid empty={dataEmpty} title={t`Disk Usage`} description={t`Usage of root partition`}>
+							<DiskChart chartData={chartData} dataKey="stats.du" diskSize={lastStats?.d ?? NaN} />
+						</ChartCard>
+						<ChartCard grid empty={dataEmpty} title={t`Disk I/O`} description={t`Throughput of root filesystem`}>
+							<AreaChartDefault
+								chartData={chartData}
+								dataPoints={[
+									{
+										label: t({ message: "Write", comment: "Disk write" }),
+										dataKey: ({ stats }) => stats?.dw,
+										color: "3",
+										opacity: 0.3,
+									},
+									{
+										label: t({ message: "Read", comment: "Disk read" }),
+										dataKey: ({ stats }) => stats?.dr,
+										color: "1",
+										opacity: 0.3,
+									},
+								]}
+								tickFormatter={(val) => {
+									const { value, unit } = formatBytes(val, true, userSettings.unitDisk, true)
+									return toFixedFloat(value, value >= 10 ? 0 : 1) + " " + unit
+								}}
+								contentFormatter={({ value }) => {
+									const { value: convertedValue, unit } = formatBytes(value, true, userSettings.unitDisk, true)
+									return decimalString(convertedValue, convertedValue >= 100 ? 1 : 2) + " " + unit
+								}}
+							/>
+						</ChartCard>
+					</>
+				)}
+
+				{has("network") && (
+					<ChartCard grid empty={dataEmpty} title={t`Bandwidth`} description={t`Network traffic of public interfaces`}>
+						<AreaChartDefault
+							chartData={chartData}
+							dataPoints={[
+								{ label: t`Sent`, dataKey: ({ stats }) => stats?.b?.[0], color: "5", opacity: 0.2 },
+								{ label: t`Received`, dataKey: ({ stats }) => stats?.b?.[1], color: "2", opacity: 0.2 },
+							]}
+							tickFormatter={(val) => {
+								const { value, unit } = formatBytes(val, true, userSettings.unitNet, false)
+								return toFixedFloat(value, value >= 10 ? 0 : 1) + " " + unit
+							}}
+							contentFormatter={(data) => {
+								const { value, unit } = formatBytes(data.value, true, userSettings.unitNet, false)
+								return decimalString(value, value >= 100 ? 1 : 2) + " " + unit
+							}}
+						/>
+					</ChartCard>
+				)}
+
+				{has("network") && hasNetworkInterfaces && (
+					<ChartCard
+						grid
+						empty={dataEmpty}
+						title={t`Network Interfaces`}
+						description={t`Traffic sent and received by each network interface`}
+					>
+						<NetworkInterfacesChart chartData={chartData} />
+					</ChartCard>
+				)}
+
+				{has("sensors") && Object.keys(lastStats?.t ?? {}).length > 0 && (
+					<ChartCard grid empty={dataEmpty} title={t`Temperature`} description={t`Temperatures of system sensors`}>
+						<TemperatureChart chartData={chartData} />
+					</ChartCard>
+				)}
+
+				{hasContainers && (
+					<>
+						<ChartCard grid empty={dataEmpty} title={t`Docker CPU Usage`} description={t`Average CPU utilization of containers`}>
+							<ContainerChart chartData={chartData} dataKey="c" chartType={ChartType.CPU} />
+						</ChartCard>
+						<ChartCard
+							grid
+							empty={dataEmpty}
+							title={t`Docker Memory Usage`}
+							description={t`Memory usage of docker containers`}
+						>
+							<ContainerChart chartData={chartData} dataKey="m" chartType={ChartType.Memory} />
+						</ChartCard>
+					</>
+				)}
+			</div>
+		</div>
+	)
+}
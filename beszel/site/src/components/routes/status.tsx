@@ -0,0 +1,100 @@
+import { Trans, useLingui } from "@lingui/react/macro"
+import { useEffect, useState } from "react"
+import { pb } from "@/lib/stores"
+import { StatusPage } from "@/types"
+import { cn, decimalString } from "@/lib/utils"
+import { Card } from "../ui/card"
+
+// milliseconds between refreshes of the status page
+const refreshInterval = 60_000
+
+/** Public status page of selected systems. Doesn't require login. */
+export default function StatusPageView({ slug }: { slug: string }) {
+	const { t } = useLingui()
+	const [page, setPage] = useState<StatusPage | null>(null)
+	const [notFound, setNotFound] = useState(false)
+
+	useEffect(() => {
+		const getPage = () =>
+			pb
+				.send<StatusPage>(`/api/beszel/status/${encodeURIComponent(slug)}`, { requestKey: null })
+				.then((data) => {
+					setPage(data)
+					setNotFound(false)
+				})
+				.catch((err) => err.status === 404 && setNotFound(true))
+		getPage()
+		const interval = setInterval(getPage, refreshInterval)
+		return () => clearInterval(interval)
+	}, [slug])
+
+	useEffect(() => {
+		if (page) {
+			document.title = page.name
+		}
+	}, [page?.name])
+
+	if (notFound) {
+		return (
+			<div className="container my-14 text-center">
+				<h1 className="text-2xl font-semibold">
+					<Trans>Status page not found</Trans>
+				</h1>
+			</div>
+		)
+	}
+	if (!page) {
+		return null
+	}
+
+	const downCount = page.systems.filter((system) => system.status === "down").length
+
+	return (
+		<div className="container max-w-4xl my-10 grid gap-4">
+			<div className="mb-2">
+				<h1 className="text-[1.6rem] font-semibold mb-1.5">{page.name}</h1>
+				<p className={cn("text-sm", downCount ? "text-red-500" : "text-muted-foreground")}>
+					{downCount ? t`${downCount} of ${page.systems.length} systems down` : t`All systems operational`}
+				</p>
+			</div>
+			{page.systems.map((system, i) => (
+				<Card key={i} className="flex flex-wrap items-center gap-x-6 gap-y-2 px-5 py-4">
+					<div className="flex items-center gap-3 min-w-40 grow">
+						<span
+							className={cn("inline-flex rounded-full h-3 w-3 shrink-0", {
+								"bg-green-500": system.status === "up",
+								"bg-red-500": system.status === "down",
+								"bg-primary/40": system.status === "paused",
+								"bg-yellow-500": system.status === "pending",
+							})}
+						></span>
+						<span className="font-medium truncate">{system.name}</span>
+						<span className="text-sm text-muted-foreground capitalize">{system.status}</span>
+					</div>
+					{system.status === "up" && (
+						<div className="flex flex-wrap gap-x-5 gap-y-1 text-sm tabular-nums">
+							<span>
+								<span className="text-muted-foreground">
+									<Trans>CPU</Trans>
+								</span>{" "}
+								{decimalString(system.cpu, 1)}%
+							</span>
+							<span>
+								<span className="text-muted-foreground">
+									<Trans>Memory</Trans>
+								</span>{" "}
+								{decimalString(system.mp, 1)}%
+							</span>
+							{system.sensors?.map((sensor) => (
+								<span key={sensor.name}>
+									<span className="text-muted-foreground">{sensor.name}</span> {decimalString(sensor.value, 1)}
+									{sensor.unit}
+								</span>
+							))}
+						</div>
+					)}
+				</Card>
+			))}
+		</div>
+	)
+}
@@ -5,19 +5,44 @@ import {
 	pb,
 	$chartTime,
 	$containerFilter,
+	$containerGroupByProject,
 	$userSettings,
 	$direction,
 	$maxValues,
 	$temperatureFilter,
 	$genericSensorFilter,
 } from "@/lib/stores"
-import { ChartData, ChartTimes, ContainerStatsRecord, GPUData, SystemRecord, SystemStatsRecord } from "@/types"
-import { ChartType, Unit, Os } from "@/lib/enums"
-import React, { lazy, memo, useCallback, useEffect, useMemo, useRef, useState, type JSX } from "react"
+import {
+	ChartData,
+	ChartTimes,
+	ContainerStats,
+	ContainerStatsRecord,
+	GenericSensorData,
+	GPUData,
+	SystemEventRecord,
+	SystemRecord,
+	SystemStats,
+	SystemStatsRecord,
+} from "@/types"
+import { ChartType, Unit, Os, CollectorErrorKind } from "@/lib/enums"
+import React, { lazy, memo, Suspense, useCallback, useEffect, useMemo, useRef, useState, type JSX } from "react"
 import { Card, CardHeader, CardTitle, CardDescription } from "../ui/card"
 import { useStore } from "@nanostores/react"
 import Spinner from "../spinner"
-import { ClockArrowUp, CpuIcon, GlobeIcon, LayoutGridIcon, MonitorIcon, XIcon } from "lucide-react"
+import {
+	ClockArrowUp,
+	CpuIcon,
+	EarthIcon,
+	GlobeIcon,
+	HardDriveIcon,
+	HeartPulseIcon,
+	LayersIcon,
+	LayoutGridIcon,
+	MonitorIcon,
+	ServerCogIcon,
+	TriangleAlertIcon,
+	XIcon,
+} from "lucide-react"
 import ChartTimeSelect from "../charts/chart-time-select"
 import {
 	chartTimeData,
@@ -26,6 +51,7 @@ import {
 	formatBytes,
 	getHostDisplayValue,
 	getPbTimestamp,
+	isReadOnlyUser,
 	listen,
 	parseSemVer,
 	toFixedFloat,
@@ -48,15 +74,73 @@ const ContainerChart = lazy(() => import("../charts/container-chart"))
 const MemChart = lazy(() => import("../charts/mem-chart"))
 const DiskChart = lazy(() => import("../charts/disk-chart"))
 const SwapChart = lazy(() => import("../charts/swap-chart"))
+const DockerDiskChart = lazy(() => import("../charts/docker-disk-chart"))
 const TemperatureChart = lazy(() => import("../charts/temperature-chart"))
 const GenericSensorChart = lazy(() => import("../charts/generic-sensor-chart"))
 const GpuPowerChart = lazy(() => import("../charts/gpu-power-chart"))
+const NetworkInterfacesChart = lazy(() => import("../charts/network-interfaces-chart"))
+const CpuCoresChart = lazy(() => import("../charts/cpu-cores-chart"))
 const LoadAverageChart = lazy(() => import("../charts/load-average-chart"))
+const LatencyChart = lazy(() => import("../charts/latency-chart"))
+const SensorSummaryTable = lazy(() => import("../sensor-summary"))
+const UptimeCard = lazy(() => import("../uptime-card"))
+const ShareButton = lazy(() => import("../share-dialog"))
+const ExportButton = lazy(() => import("../export-dialog"))
 
 const cache = new Map<string, any>()
 
+/** Groups temperature sensors by category. Returns a single ungrouped entry if there are no categories. */
+function getTemperatureGroups(stats?: SystemStats) {
+	const temps = Object.keys(stats?.t ?? {})
+	if (!temps.length) {
+		return []
+	}
+	const categories = stats?.sc
+	if (!categories || !temps.some((key) => categories[key])) {
+		return [{ category: "", sensors: temps }]
+	}
+	const groups = new Map<string, string[]>()
+	for (const key of temps) {
+		const category = categories[key] ?? "other"
+		groups.set(category, [...(groups.get(category) ?? []), key])
+	}
+	return Array.from(groups, ([category, sensors]) => ({ category, sensors }))
+}
+
+function collectorErrorLabel(kind: CollectorErrorKind) {
+	switch (kind) {
+		case CollectorErrorKind.Unavailable:
+			return t`Unavailable`
+		case CollectorErrorKind.PermissionDenied:
+			return t`Permission denied`
+		case CollectorErrorKind.Timeout:
+			return t`Timeout`
+		case CollectorErrorKind.ParseError:
+			return t`Parse error`
+		case CollectorErrorKind.Stale:
+			return t`Stale`
+		default:
+			return t`Error`
+	}
+}
+
+function sensorCategoryLabel(category: string) {
+	switch (category) {
+		case "cpu":
+			return t`CPU`
+		case "chassis":
+			return t`Chassis`
+		case "ambient":
+			return t`Ambient`
+		case "custom":
+			return t`Custom`
+		default:
+			return t`Other`
+	}
+}
+
 // create ticks and domain for charts
-function getTimeData(chartTime: ChartTimes, lastCreated: number) {
+export function getTimeData(chartTime: ChartTimes, lastCreated: number) {
 	const cached = cache.get("td")
 	if (cached && cached.chartTime === chartTime) {
 		if (!lastCreated || cached.time >= lastCreated) {
@@ -76,7 +160,7 @@ function getTimeData(chartTime: ChartTimes, lastCreated: number) {
 }
 
 // add empty values between records to make gaps if interval is too large
-function addEmptyValues<T extends SystemStatsRecord | ContainerStatsRecord>(
+export function addEmptyValues<T extends SystemStatsRecord | ContainerStatsRecord>(
 	prevRecords: T[],
 	newRecords: T[],
 	expectedInterval: number
@@ -100,21 +184,35 @@ function addEmptyValues<T extends SystemStatsRecord | ContainerStatsRecord>(
 	return modifiedRecords
 }
 
+// stats records are read through the hub's storage driver, which may not be the hub's database
 async function getStats<T>(collection: string, system: SystemRecord, chartTime: ChartTimes): Promise<T[]> {
 	const lastCached = cache.get(`${system.id}_${chartTime}_${collection}`)?.at(-1)?.created as number
-	return await pb.collection<T>(collection).getFullList({
-		filter: pb.filter("system={:id} && created > {:created} && type={:type}", {
-			id: system.id,
-			created: getPbTimestamp(chartTime, lastCached ? new Date(lastCached + 1000) : undefined),
+	return await pb.send<T[]>("/api/beszel/stats", {
+		query: {
+			system: system.id,
+			collection,
+			since: getPbTimestamp(chartTime, lastCached ? new Date(lastCached + 1000) : undefined),
 			type: chartTimeData[chartTime].type,
+		},
+	})
+}
+
+// events reported to the agent's event API in the chart time range
+async function getEvents(system: SystemRecord, chartTime: ChartTimes): Promise<SystemEventRecord[]> {
+	return await pb.collection<SystemEventRecord>("system_events").getFullList({
+		filter: pb.filter("system={:id} && time > {:time}", {
+			id: system.id,
+			time: getPbTimestamp(chartTime),
 		}),
-		fields: "created,stats",
-		sort: "created",
+		fields: "id,name,message,value,time",
+		sort: "time",
 	})
 }
 
 function dockerOrPodman(str: string, system: SystemRecord) {
-	if (system.info.p) {
+	if (system.info.rt) {
+		str = str.replace("docker", system.info.rt).replace("Docker", system.info.rt)
+	} else if (system.info.p) {
 		str = str.replace("docker", "podman").replace("Docker", "Podman")
 	}
 	return str
@@ -126,11 +224,33 @@ export default function SystemDetail({ name }: { name: string }) {
 	const systems = useStore($systems)
 	const chartTime = useStore($chartTime)
 	const maxValues = useStore($maxValues)
+	const groupByProject = useStore($containerGroupByProject)
 	const [grid, setGrid] = useLocalStorage("grid", true)
 	const [system, setSystem] = useState({} as SystemRecord)
 	const [systemStats, setSystemStats] = useState([] as SystemStatsRecord[])
 	const [containerData, setContainerData] = useState([] as ChartData["containerData"])
-	const netCardRef = useRef<HTMLDivElement>(null)
+	const [events, setEvents] = useState([] as SystemEventRecord[])
+	// agents before block i/o was added don't report it
+	const hasContainerDiskIo = useMemo(
+		() => containerData.some((stats) => Object.values(stats).some((value: any) => value?.dr || value?.dw)),
+		[containerData]
+	)
+	// per-core charts, from agents that report them
+	const hasCpuCores = useMemo(() => systemStats.some((record) => record.stats?.cpc?.length), [systemStats])
+	const hasCpuFreq = useMemo(() => systemStats.some((record) => record.stats?.cpf?.length), [systemStats])
+	const hasCpuThrottling = useMemo(() => systemStats.some((record) => record.stats?.cth), [systemStats])
+	const hasSwapIo = useMemo(
+		() => systemStats.some((record) => record.stats?.sio?.[0] || record.stats?.sio?.[1]),
+		[systemStats]
+	)
+	const hasHugePages = useMemo(() => systemStats.some((record) => record.stats?.mh), [systemStats])
+	const hasPressure = useMemo(() => systemStats.some((record) => record.stats?.psi), [systemStats])
+	// per-interface chart is only useful with more than one interface
+	const hasNetworkInterfaces = useMemo(
+		() => systemStats.some((record) => Object.keys(record.stats?.ni ?? {}).length > 1),
+		[systemStats]
+	)
+		const netCardRef = useRef<HTMLDivElement>(null)
 	const persistChartTime = useRef(false)
 	const [containerFilterBar, setContainerFilterBar] = useState(null as null | JSX.Element)
 	const [bottomSpacing, setBottomSpacing] = useState(0)
@@ -191,12 +311,13 @@ export default function SystemDetail({ name }: { name: string }) {
 		return {
 			systemStats,
 			containerData,
+			events,
 			chartTime,
 			orientation: direction === "rtl" ? "right" : "left",
 			...getTimeData(chartTime, lastCreated),
 			agentVersion: parseSemVer(system?.info?.v),
 		}
-	}, [systemStats, containerData, direction])
+	}, [systemStats, containerData, events, direction])
 
 	// get stats
 	useEffect(() => {
@@ -208,9 +329,11 @@ export default function SystemDetail({ name }: { name: string }) {
 		Promise.allSettled([
 			getStats<SystemStatsRecord>("system_stats", system, chartTime),
 			getStats<ContainerStatsRecord>("container_stats", system, chartTime),
-		]).then(([systemStats, containerStats]) => {
+			getEvents(system, chartTime),
+		]).then(([systemStats, containerStats, events]) => {
 			// loading: false
 			setChartLoading(false)
+			setEvents(events.status === "fulfilled" ? events.value : [])
 
 			const { expectedInterval } = chartTimeData[chartTime]
 			// make new system stats
@@ -243,8 +366,29 @@ export default function SystemDetail({ name }: { name: string }) {
 		})
 	}, [system, chartTime])
 
+	// scroll to the chart in the url hash once loaded, filtered to the name after
+	// the colon, e.g. #containers:vaultwarden from a search result
+	useEffect(() => {
+		if (chartLoading || !window.location.hash) {
+			return
+		}
+		const [chart, filter] = decodeURIComponent(window.location.hash.slice(1)).split(/:(.*)/s)
+		const filterStore = { containers: $containerFilter, temperature: $temperatureFilter }[chart]
+		if (filter && filterStore) {
+			filterStore.set(filter)
+		}
+		document.getElementById(chart)?.scrollIntoView({ behavior: "smooth" })
+	}, [chartLoading, system.id])
+
+	// regroup container stats when toggling grouping by compose project
+	useEffect(() => {
+		system.id &&
+				makeContainerData((cache.get(`${system.id}_${chartTime}_container_stats`) || []) as ContainerStatsRecord[])
+	}, [groupByProject])
+
 	// make container stats for charts
 	const makeContainerData = useCallback((containers: ContainerStatsRecord[]) => {
+		const groupByProject = $containerGroupByProject.get()
 		const containerData = [] as ChartData["containerData"]
 		for (let { created, stats } of containers) {
 			if (!created) {
@@ -256,7 +400,9 @@ export default function SystemDetail({ name }: { name: string }) {
 			// @ts-ignore not dealing with this rn
 			let containerStats: ChartData["containerData"][0] = { created }
 			for (let container of stats) {
-				containerStats[container.n] = container
+				const key = (groupByProject && container.p) || container.n
+				const existing = containerStats[key] as ContainerStats | undefined
+				containerStats[key] = existing ? sumContainerStats(existing, container) : container
 			}
 			containerData.push(containerStats)
 		}
@@ -289,6 +435,16 @@ export default function SystemDetail({ name }: { name: string }) {
 			},
 		}
 
+		const collectorErrors = Object.entries(system.info.cs ?? {}).filter(([_, status]) => status.k !== CollectorErrorKind.Ok)
+		const raidArrays = system.info.raid ?? []
+		const degradedArrays = raidArrays.filter((array) => array.a < array.d)
+		const smartDisks = system.info.smart ?? []
+		const failingDisks = smartDisks.filter((disk) => !disk.p)
+		const services = system.info.svc ?? []
+		const failedServices = services.filter((unit) => unit.s === "failed")
+		const checks = system.info.chk ?? []
+		const failingChecks = checks.filter((check) => !check.u)
+
 		let uptime: React.ReactNode
 		if (system.info.u < 172800) {
 			const hours = Math.trunc(system.info.u / 3600)
@@ -305,6 +461,7 @@ export default function SystemDetail({ name }: { name: string }) {
 				// hide if hostname is same as host or name
 				hide: system.info.h === system.host || system.info.h === system.name,
 			},
+			{ value: system.info.pip, Icon: EarthIcon, label: t`Public IP`, hide: !system.info.pip },
 			{ value: uptime, Icon: ClockArrowUp, label: t`Uptime`, hide: !system.info.u },
 			osInfo[system.info.os ?? Os.Linux],
 			{
@@ -312,6 +469,83 @@ export default function SystemDetail({ name }: { name: string }) {
 				Icon: CpuIcon,
 				hide: !system.info.m,
 			},
+			{
+				value: <Plural value={collectorErrors.length} one="# collector error" other="# collector errors" />,
+				Icon: TriangleAlertIcon,
+				label: collectorErrors
+					.map(([name, status]) => `${name}: ${collectorErrorLabel(status.k)}${status.e ? ` (${status.e})` : ""}`)
+					.join("\n"),
+				hide: !collectorErrors.length,
+			},
+			{
+				value: degradedArrays.length ? (
+					<Plural value={degradedArrays.length} one="# degraded RAID array" other="# degraded RAID arrays" />
+				) : (
+					<Plural value={raidArrays.length} one="# RAID array" other="# RAID arrays" />
+				),
+				Icon: degradedArrays.length ? TriangleAlertIcon : HardDriveIcon,
+				label: raidArrays
+					.map(
+						(array) =>
+							`${array.n}${array.l ? ` (${array.l})` : ""}: ${array.s}, ${array.a}/${array.d}` +
+							(array.sy ? `, ${array.sy}${array.sp ? ` ${array.sp}%` : ""}` : "")
+					)
+					.join("\n"),
+				hide: !raidArrays.length,
+			},
+			{
+				value: failingDisks.length ? (
+					<Plural value={failingDisks.length} one="# failing disk" other="# failing disks" />
+				) : (
+					<Plural value={smartDisks.length} one="# healthy disk" other="# healthy disks" />
+				),
+				Icon: failingDisks.length ? TriangleAlertIcon : HardDriveIcon,
+				label: smartDisks
+					.map(
+						(disk) =>
+							`${disk.n}${disk.m ? ` (${disk.m})` : ""}: ${disk.p ? "PASSED" : "FAILED"}` +
+							(disk.t ? `, ${disk.t}°C` : "") +
+							(disk.h ? `, ${disk.h}h` : "") +
+							(disk.ps ? `, ${disk.ps} pending` : "") +
+							(disk.rs ? `, ${disk.rs} reallocated` : "") +
+							(disk.me ? `, ${disk.me} media errors` : "")
+					)
+					.join("\n"),
+				hide: !smartDisks.length,
+			},
+			{
+				value: failedServices.length ? (
+					<Plural value={failedServices.length} one="# failed service" other="# failed services" />
+				) : (
+					<Plural value={services.length} one="# service" other="# services" />
+				),
+				Icon: failedServices.length ? TriangleAlertIcon : ServerCogIcon,
+				label: services
+					.map(
+						(unit) =>
+							`${unit.n}: ${unit.s}${unit.ss ? ` (${unit.ss})` : ""}` +
+							(unit.r ? `, ${unit.r} restarts` : "")
+					)
+					.join("\n"),
+				hide: !services.length,
+			},
+			{
+				value: failingChecks.length ? (
+					<Plural value={failingChecks.length} one="# failing check" other="# failing checks" />
+				) : (
+					<Plural value={checks.length} one="# check" other="# checks" />
+				),
+				Icon: failingChecks.length ? TriangleAlertIcon : HeartPulseIcon,
+				label: checks
+					.map(
+						(check) =>
+							`${check.n} (${check.t}): ${check.u ? "up" : "down"}` +
+							(check.l ? `, ${check.l} ms` : "") +
+							(check.e ? `, ${check.e}` : "")
+					)
+					.join("\n"),
+				hide: !checks.length,
+			},
 		] as {
 			value: string | number | undefined
 			label?: string
@@ -382,6 +616,7 @@ export default function SystemDetail({ name }: { name: string }) {
 	const lastGpuVals = Object.values(systemStats.at(-1)?.stats.g ?? {})
 	const hasGpuData = lastGpuVals.length > 0
 	const hasGpuPowerData = lastGpuVals.some((gpu) => gpu.p !== undefined)
+	const temperatureGroups = getTemperatureGroups(systemStats.at(-1)?.stats)
 
 	let translatedStatus: string = system.status
 	if (system.status === "up") {
@@ -434,7 +669,7 @@ export default function SystemDetail({ name }: { name: string }) {
 												<TooltipProvider>
 													<Tooltip delayDuration={150}>
 														<TooltipTrigger asChild>{content}</TooltipTrigger>
-														<TooltipContent>{label}</TooltipContent>
+														<TooltipContent className="whitespace-pre-line">{label}</TooltipContent>
 													</Tooltip>
 												</TooltipProvider>
 											) : (
@@ -447,6 +682,14 @@ export default function SystemDetail({ name }: { name: string }) {
 						</div>
 						<div className="xl:ms-auto flex items-center gap-2 max-sm:-mb-1">
 							<ChartTimeSelect className="w-full xl:w-40" />
+							{!isReadOnlyUser() && (
+								<Suspense>
+									<ShareButton system={system} />
+								</Suspense>
+							)}
+							<Suspense>
+								<ExportButton system={system} />
+							</Suspense>
 							<TooltipProvider delayDuration={100}>
 								<Tooltip>
 									<TooltipTrigger asChild>
@@ -471,6 +714,11 @@ export default function SystemDetail({ name }: { name: string }) {
 					</div>
 				</Card>
 
+				{/* uptime over the last 24 hours to 90 days */}
+				<Suspense>
+					<UptimeCard systemId={system.id} status={system.status} />
+				</Suspense>
+
 				{/* main charts */}
 				<div className="grid xl:grid-cols-2 gap-4">
 					<ChartCard
@@ -498,6 +746,7 @@ export default function SystemDetail({ name }: { name: string }) {
 
 					{containerFilterBar && (
 						<ChartCard
+							id="containers"
 							empty={dataEmpty}
 							grid={grid}
 							title={dockerOrPodman(t`Docker CPU Usage`, system)}
@@ -614,6 +863,17 @@ export default function SystemDetail({ name }: { name: string }) {
 						/>
 					</ChartCard>
 
+					{hasNetworkInterfaces && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={t`Network Interfaces`}
+							description={t`Traffic sent and received by each network interface`}
+						>
+							<NetworkInterfacesChart chartData={chartData} />
+						</ChartCard>
+					)}
+
 					{containerFilterBar && containerData.length > 0 && (
 						<div
 							ref={netCardRef}
@@ -633,6 +893,66 @@ export default function SystemDetail({ name }: { name: string }) {
 						</div>
 					)}
 
+					{containerFilterBar && hasContainerDiskIo && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={dockerOrPodman(t`Docker Disk I/O`, system)}
+							description={dockerOrPodman(t`Block device reads and writes of docker containers`, system)}
+							cornerEl={containerFilterBar}
+						>
+							{/* @ts-ignore */}
+							<ContainerChart chartData={chartData} chartType={ChartType.DiskIO} dataKey="d" />
+						</ChartCard>
+					)}
+
+					{/* Docker disk usage chart */}
+					{systemStats.at(-1)?.stats.ddu && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={dockerOrPodman(t`Docker Disk Usage`, system)}
+							description={dockerOrPodman(
+								t`Disk space used by docker images, containers, volumes and build cache`,
+								system
+							)}
+						>
+							<DockerDiskChart chartData={chartData} />
+						</ChartCard>
+					)}
+
+					{/* Hugepages chart */}
+					{hasHugePages && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={t`Huge Pages`}
+							description={t`Memory preallocated as hugepages and the amount in use`}
+						>
+							<AreaChartDefault
+								chartData={chartData}
+								max={systemStats.at(-1)?.stats.mh}
+								dataPoints={[
+									{
+										label: t`Used`,
+										dataKey: ({ stats }) => stats?.mhu,
+										color: "2",
+										opacity: 0.4,
+									},
+								]}
+								tickFormatter={(val) => {
+									const { value, unit } = formatBytes(val * 1024, false, userSettings.unitDisk, true)
+									return toFixedFloat(value, value >= 10 ? 0 : 1) + " " + unit
+								}}
+								contentFormatter={({ value }) => {
+									// mem values are supplied as GB
+									const { value: convertedValue, unit } = formatBytes(value * 1024, false, userSettings.unitDisk, true)
+									return decimalString(convertedValue, convertedValue >= 100 ? 1 : 2) + " " + unit
+								}}
+							/>
+						</ChartCard>
+					)}
+
 					{/* Swap chart */}
 					{(systemStats.at(-1)?.stats.su ?? 0) > 0 && (
 						<ChartCard
@@ -645,6 +965,42 @@ export default function SystemDetail({ name }: { name: string }) {
 						</ChartCard>
 					)}
 
+					{/* Swap activity chart */}
+					{hasSwapIo && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={t`Swap Activity`}
+							description={t`Memory swapped in and out, a sign of memory pressure`}
+						>
+							<AreaChartDefault
+								chartData={chartData}
+								dataPoints={[
+									{
+										label: t({ message: "In", comment: "Swap in" }),
+										dataKey: ({ stats }) => stats?.sio?.[0],
+										color: "2",
+										opacity: 0.3,
+									},
+									{
+										label: t({ message: "Out", comment: "Swap out" }),
+										dataKey: ({ stats }) => stats?.sio?.[1],
+										color: "5",
+										opacity: 0.3,
+									},
+								]}
+								tickFormatter={(val) => {
+									const { value, unit } = formatBytes(val, true, Unit.Bytes, false)
+									return toFixedFloat(value, value >= 10 ? 0 : 1) + " " + unit
+								}}
+								contentFormatter={({ value }) => {
+									const { value: convertedValue, unit } = formatBytes(value, true, Unit.Bytes, false)
+									return decimalString(convertedValue, convertedValue >= 100 ? 1 : 2) + " " + unit
+								}}
+							/>
+						</ChartCard>
+					)}
+
 					{/* Load Average chart */}
 					{chartData.agentVersion?.minor >= 12 && (
 						<ChartCard
@@ -653,34 +1009,119 @@ export default function SystemDetail({ name }: { name: string }) {
 							title={t`Load Average`}
 							description={t`System load averages over time`}
 						>
-							<LoadAverageChart chartData={chartData} />
+							<LoadAverageChart chartData={chartData} cpus={system.info?.t || system.info?.c} />
+						</ChartCard>
+					)}
+
+					{/* Per-core CPU charts */}
+					{hasCpuCores && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={t`CPU Cores`}
+							description={t`Average utilization of each CPU core`}
+						>
+							<CpuCoresChart chartData={chartData} dataKey="cpc" />
+						</ChartCard>
+					)}
+
+					{hasCpuFreq && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={t`CPU Frequency`}
+							description={t`Current frequency of each CPU core`}
+						>
+							<CpuCoresChart chartData={chartData} dataKey="cpf" />
+						</ChartCard>
+					)}
+
+					{hasCpuThrottling && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={t`CPU Throttling`}
+							description={t`Cores throttled below their max frequency, usually by heat`}
+						>
+							<AreaChartDefault
+								chartData={chartData}
+								dataPoints={[
+									{
+										label: t`Throttled cores`,
+										dataKey: ({ stats }) => stats?.cth ?? 0,
+										color: "4",
+										opacity: 0.3,
+									},
+								]}
+								tickFormatter={(val) => toFixedFloat(val, 1)}
+								contentFormatter={({ value }) => decimalString(value)}
+							/>
+						</ChartCard>
+					)}
+
+					{/* Pressure stall chart */}
+					{hasPressure && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={t`Pressure Stall`}
+							description={t`Share of time tasks waited for CPU, memory or I/O`}
+						>
+							<AreaChartDefault
+								chartData={chartData}
+								dataPoints={[
+									{ label: t`CPU`, dataKey: ({ stats }) => stats?.psi?.c[0], color: "1", opacity: 0.3 },
+									{ label: t`Memory`, dataKey: ({ stats }) => stats?.psi?.m[0], color: "2", opacity: 0.3 },
+									{ label: t`I/O`, dataKey: ({ stats }) => stats?.psi?.i[0], color: "3", opacity: 0.3 },
+								]}
+								tickFormatter={(val) => toFixedFloat(val, 2) + "%"}
+								contentFormatter={({ value }) => decimalString(value) + "%"}
+							/>
+						</ChartCard>
+					)}
+
+					{/* Pipeline latency chart */}
+					{systemStats.at(-1)?.stats.lat && (
+						<ChartCard
+							empty={dataEmpty}
+							grid={grid}
+							title={t`Pipeline Latency`}
+							description={t`Time from collection on the agent to storage on the hub`}
+						>
+							<LatencyChart chartData={chartData} />
 						</ChartCard>
 					)}
 
-					{/* Temperature chart */}
-					{systemStats.at(-1)?.stats.t && (
+					{/* Temperature charts, grouped by sensor category if the agent sends categories */}
+					{temperatureGroups.map(({ category, sensors }, i) => (
 						<ChartCard
+							key={category}
+							id={i === 0 ? "temperature" : undefined}
 							empty={dataEmpty}
 							grid={grid}
-							title={t`Temperature`}
+							title={category ? `${t`Temperature`} (${sensorCategoryLabel(category)})` : t`Temperature`}
 							description={t`Temperatures of system sensors`}
 							cornerEl={<FilterBar store={$temperatureFilter} />}
 						>
-							<TemperatureChart chartData={chartData} />
+							<TemperatureChart chartData={chartData} sensors={category ? sensors : undefined} />
 						</ChartCard>
-					)}
+					))}
+
+					{/* Daily sensor min, max and rate of change from the hub */}
+					{(temperatureGroups.length > 0 || systemStats.at(-1)?.stats.gs) && <SensorSummaryTable systemId={system.id} />}
 
 					{/* Generic sensor charts */}
 					{systemStats.at(-1)?.stats.gs && 
 						Object.entries(systemStats.at(-1)?.stats.gs ?? {}).map(([sensorName, sensorData]) => {
-							const sensor = sensorData as { v: number; u: string; min: number; max: number }
+							const sensor = sensorData as GenericSensorData
 							return (
 								<div key={sensorName} className="contents">
 									<ChartCard
+										id={`sensor-${sensorName}`}
 										empty={dataEmpty}
 										grid={grid}
-										title={`${sensorName} (${sensor.u})`}
-										description={`${sensorName} sensor readings`}
+										title={sensor.u ? `${sensorName} (${sensor.u})` : sensorName}
+										description={sensor.s ? t`${sensorName} state over time` : `${sensorName} sensor readings`}
 										cornerEl={<FilterBar store={$genericSensorFilter} />}
 									>
 										<GenericSensorChart 
@@ -834,8 +1275,24 @@ export default function SystemDetail({ name }: { name: string }) {
 	)
 }
 
+/** Sum the stats of containers of the same compose project */
+function sumContainerStats(a: ContainerStats, b: ContainerStats): ContainerStats {
+	return {
+		n: a.p ?? a.n,
+		p: a.p,
+		c: a.c + b.c,
+		m: a.m + b.m,
+		ns: a.ns + b.ns,
+		nr: a.nr + b.nr,
+		dr: (a.dr ?? 0) + (b.dr ?? 0),
+		dw: (a.dw ?? 0) + (b.dw ?? 0),
+		rc: (a.rc ?? 0) + (b.rc ?? 0),
+	}
+}
+
 function FilterBar({ store = $containerFilter }: { store?: typeof $containerFilter }) {
 	const containerFilter = useStore(store)
+	const groupByProject = useStore($containerGroupByProject)
 	const { t } = useLingui()
 
 	const handleChange = useCallback((e: React.ChangeEvent<HTMLInputElement>) => {
@@ -844,7 +1301,29 @@ function FilterBar({ store = $containerFilter }: { store?: typeof $containerFilt
 
 	return (
 		<>
-			<Input placeholder={t`Filter...`} className="ps-4 pe-8" value={containerFilter} onChange={handleChange} />
+			<Input
+				placeholder={t`Filter...`}
+				className={cn("ps-4 pe-8", store === $containerFilter && "ps-9")}
+				value={containerFilter}
+				onChange={handleChange}
+			/>
+			{store === $containerFilter && (
+				<Button
+					type="button"
+					variant="ghost"
+					size="icon"
+					aria-label={t`Group by compose project`}
+					title={t`Group by compose project`}
+					aria-pressed={groupByProject}
+					className={cn(
+						"absolute start-1 top-1/2 -translate-y-1/2 h-7 w-7 text-gray-500 hover:text-gray-900 dark:text-gray-400 dark:hover:text-gray-100",
+						groupByProject && "text-foreground dark:text-foreground"
+					)}
+					onClick={() => $containerGroupByProject.set(!groupByProject)}
+				>
+					<LayersIcon className="h-4 w-4" />
+				</Button>
+			)}
 			{containerFilter && (
 				<Button
 					type="button"
@@ -881,7 +1360,8 @@ const SelectAvgMax = memo(({ max }: { max: boolean }) => {
 	)
 })
 
-function ChartCard({
+export function ChartCard({
+	id,
 	title,
 	description,
 	children,
@@ -889,6 +1369,8 @@ function ChartCard({
 	empty,
 	cornerEl,
 }: {
+	/** anchor of the chart, linked from search results */
+	id?: string
 	title: string
 	description: string
 	children: React.ReactNode
@@ -899,7 +1381,11 @@ function ChartCard({
 	const { isIntersecting, ref } = useIntersectionObserver()
 
 	return (
-		<Card className={cn("pb-2 sm:pb-4 odd:last-of-type:col-span-full", { "col-span-full": !grid })} ref={ref}>
+		<Card
+			id={id}
+			className={cn("pb-2 sm:pb-4 odd:last-of-type:col-span-full scroll-mt-4", { "col-span-full": !grid })}
+			ref={ref}
+		>
 			<CardHeader className="pb-5 pt-4 gap-1 relative max-sm:py-3 max-sm:px-4">
 				<CardTitle className="text-xl sm:text-2xl">{title}</CardTitle>
 				<CardDescription>{description}</CardDescription>
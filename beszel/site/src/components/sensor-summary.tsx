@@ -0,0 +1,77 @@
+import { Trans, useLingui } from "@lingui/react/macro"
+import { memo, useEffect, useState } from "react"
+import { pb } from "@/lib/stores"
+import { decimalString, hourWithMinutes } from "@/lib/utils"
+import { SensorSummary } from "@/types"
+import { Card, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
+import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@/components/ui/table"
+
+/** Today's min, max, average and fastest rise of each sensor of a system, from the hub's daily rollups */
+export default memo(function SensorSummaryTable({ systemId }: { systemId: string }) {
+	const { t } = useLingui()
+	const [summaries, setSummaries] = useState([] as SensorSummary[])
+
+	useEffect(() => {
+		pb.send<SensorSummary[]>("/api/beszel/sensor-summary", { query: { system: systemId } })
+			.then(setSummaries)
+			.catch(() => setSummaries([]))
+	}, [systemId])
+
+	if (!summaries.length) {
+		return null
+	}
+
+	const value = (num: number, unit?: string) => decimalString(num, Math.abs(num) >= 100 ? 1 : 2) + (unit ?? "")
+
+	return (
+		<Card className="col-span-full">
+			<CardHeader className="pb-3 pt-4 gap-1 max-sm:py-3 max-sm:px-4">
+				<CardTitle className="text-xl sm:text-2xl">
+					<Trans>Sensor Summary</Trans>
+				</CardTitle>
+				<CardDescription>
+					<Trans>Lowest, highest and average readings of each sensor today</Trans>
+				</CardDescription>
+			</CardHeader>
+			<div className="px-3 sm:px-6 pb-4 overflow-x-auto">
+				<Table>
+					<TableHeader>
+						<TableRow>
+							<TableHead>{t`Sensor`}</TableHead>
+							<TableHead>{t`Min`}</TableHead>
+							<TableHead>{t`Max`}</TableHead>
+							<TableHead>{t`Average`}</TableHead>
+							<TableHead>{t`Fastest rise`}</TableHead>
+						</TableRow>
+					</TableHeader>
+					<TableBody className="whitespace-nowrap">
+						{summaries.map((summary) => (
+							<TableRow key={summary.type + summary.sensor}>
+								<TableCell className="font-medium">{summary.sensor}</TableCell>
+								<TableCell>
+									{value(summary.min, summary.unit)}{" "}
+									<span className="text-muted-foreground">{hourWithMinutes(summary.minAt)}</span>
+								</TableCell>
+								<TableCell>
+									{value(summary.max, summary.unit)}{" "}
+									<span className="text-muted-foreground">{hourWithMinutes(summary.maxAt)}</span>
+								</TableCell>
+								<TableCell>{value(summary.avg, summary.unit)}</TableCell>
+								<TableCell>
+									{summary.maxRateAt ? (
+										<>
+											{value(summary.maxRate, summary.unit)}/min{" "}
+											<span className="text-muted-foreground">{hourWithMinutes(summary.maxRateAt)}</span>
+										</>
+									) : (
+										"-"
+									)}
+								</TableCell>
+							</TableRow>
+						))}
+					</TableBody>
+				</Table>
+			</div>
+		</Card>
+	)
+})
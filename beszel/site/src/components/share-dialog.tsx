@@ -0,0 +1,218 @@
+import { t } from "@lingui/core/macro"
+import { Trans } from "@lingui/react/macro"
+import { memo, useEffect, useState } from "react"
+import { Share2Icon, TrashIcon } from "lucide-react"
+import { getPagePath } from "@nanostores/router"
+import { Button } from "@/components/ui/button"
+import {
+	Dialog,
+	DialogContent,
+	DialogDescription,
+	DialogFooter,
+	DialogHeader,
+	DialogTitle,
+	DialogTrigger,
+} from "@/components/ui/dialog"
+import { Input } from "@/components/ui/input"
+import { Label } from "@/components/ui/label"
+import { Checkbox } from "@/components/ui/checkbox"
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
+import { toast } from "@/components/ui/use-toast"
+import { InputCopy } from "./ui/input-copy"
+import { $router } from "./router"
+import { pb } from "@/lib/stores"
+import { formatShortDate } from "@/lib/utils"
+import { ShareLinkRecord, ShareMetric, SystemRecord } from "@/types"
+
+/** Metric groups a share link can be limited to */
+export const shareMetrics: { metric: ShareMetric; label: () => string }[] = [
+	{ metric: "cpu", label: () => t`CPU` },
+	{ metric: "memory", label: () => t`Memory` },
+	{ metric: "disk", label: () => t`Disk` },
+	{ metric: "network", label: () => t`Network` },
+	{ metric: "sensors", label: () => t`Sensors` },
+	{ metric: "containers", label: () => t`Containers` },
+]
+
+const expirations = {
+	"1": () => t`1 day`,
+	"7": () => t`7 days`,
+	"30": () => t`30 days`,
+	"90": () => t`90 days`,
+}
+
+/** Absolute URL of a share link */
+export function getShareUrl(token: string) {
+	return new URL(getPagePath($router, "share", { token }), window.location.origin).href
+}
+
+function showError() {
+	toast({
+		title: t`Failed to update share links`,
+		description: t`Please check logs for more details.`,
+		variant: "destructive",
+	})
+}
+
+export default memo(function ShareButton({ system }: { system: SystemRecord }) {
+	const [open, setOpen] = useState(false)
+
+	return (
+		<Dialog open={open} onOpenChange={setOpen}>
+			<DialogTrigger asChild>
+				<Button aria-label={t`Share`} variant="outline" size="icon" className="p-0 text-primary">
+					<Share2Icon className="h-[1.2rem] w-[1.2rem] opacity-75" />
+				</Button>
+			</DialogTrigger>
+			{open && <ShareDialog system={system} />}
+		</Dialog>
+	)
+})
+
+function ShareDialog({ system }: { system: SystemRecord }) {
+	const [links, setLinks] = useState([] as ShareLinkRecord[])
+	const [name, setName] = useState("")
+	const [days, setDays] = useState("7")
+	const [metrics, setMetrics] = useState(shareMetrics.map(({ metric }) => metric))
+	const [created, setCreated] = useState("")
+
+	function refresh() {
+		pb.collection<ShareLinkRecord>("share_links")
+			.getFullList({
+				filter: pb.filter("system={:id} && expires > @now", { id: system.id }),
+				fields: "id,token,name,metrics,expires",
+				sort: "-created",
+			})
+			.then(setLinks)
+			.catch(showError)
+	}
+
+	useEffect(refresh, [system.id])
+
+	async function createLink(e: React.FormEvent) {
+		e.preventDefault()
+		const expires = new Date(Date.now() + Number(days) * 86_400_000)
+		try {
+			const link = await pb.collection<ShareLinkRecord>("share_links").create({
+				user: pb.authStore.record!.id,
+				system: system.id,
+				name,
+				// an empty list shares all metrics, including ones added later
+				metrics: metrics.length === shareMetrics.length ? [] : metrics,
+				expires: expires.toISOString(),
+			})
+			setCreated(getShareUrl(link.token))
+			setName("")
+			refresh()
+		} catch {
+			showError()
+		}
+	}
+
+	async function revokeLink(id: string) {
+		try {
+			await pb.collection("share_links").delete(id)
+			setLinks(links.filter((link) => link.id !== id))
+		} catch {
+			showError()
+		}
+	}
+
+	return (
+		<DialogContent className="w-[90%] sm:max-w-lg rounded-lg">
+			<DialogHeader>
+				<DialogTitle>
+					<Trans>Share {system.name}</Trans>
+				</DialogTitle>
+				<DialogDescription>
+					<Trans>Anyone with the link can view the selected charts until it expires or is revoked.</Trans>
+				</DialogDescription>
+			</DialogHeader>
+			<form id="share" onSubmit={createLink} className="grid gap-4">
+				<div className="grid sm:grid-cols-2 gap-3">
+					<div className="grid gap-1.5">
+						<Label htmlFor="share-name">
+							<Trans>Name</Trans>
+						</Label>
+						<Input
+							id="share-name"
+							maxLength={64}
+							placeholder={t`Optional`}
+							value={name}
+							onChange={(e) => setName(e.target.value)}
+						/>
+					</div>
+					<div className="grid gap-1.5">
+						<Label htmlFor="share-expires">
+							<Trans>Expires after</Trans>
+						</Label>
+						<Select value={days} onValueChange={setDays}>
+							<SelectTrigger id="share-expires">
+								<SelectValue />
+							</SelectTrigger>
+							<SelectContent>
+								{Object.entries(expirations).map(([value, label]) => (
+									<SelectItem key={value} value={value}>
+										{label()}
+									</SelectItem>
+								))}
+							</SelectContent>
+						</Select>
+					</div>
+				</div>
+				<div className="flex flex-wrap gap-x-4 gap-y-2">
+					{shareMetrics.map(({ metric, label }) => (
+						<label key={metric} className="flex items-center gap-2 text-sm">
+							<Checkbox
+								checked={metrics.includes(metric)}
+								onCheckedChange={(checked) =>
+									setMetrics(checked ? [...metrics, metric] : metrics.filter((m) => m !== metric))
+								}
+							/>
+							{label()}
+						</label>
+					))}
+				</div>
+				{created && <InputCopy value={created} id="share-url" name="share-url" />}
+			</form>
+			<DialogFooter>
+				<Button type="submit" form="share" disabled={!metrics.length}>
+					<Trans>Create link</Trans>
+				</Button>
+			</DialogFooter>
+			{links.length > 0 && (
+				<div className="grid gap-2 border-t pt-4">
+					{links.map((link) => (
+						<div key={link.id} className="flex items-center gap-2 text-sm">
+							<div className="min-w-0 grow">
+								<div className="truncate font-medium">{link.name || link.token.slice(0, 8)}</div>
+								<div className="text-muted-foreground truncate">
+									{link.metrics?.length
+										? shareMetrics
+												.filter(({ metric }) => link.metrics!.includes(metric))
+												.map(({ label }) => label())
+												.join(", ")
+										: t`All metrics`}
+									{" · "}
+									<Trans>Expires {formatShortDate(link.expires)}</Trans>
+								</div>
+							</div>
+							<div className="w-40 shrink-0">
+								<InputCopy value={getShareUrl(link.token)} id={`share-${link.id}`} name="share-url" />
+							</div>
+							<Button
+								variant="ghost"
+								size="icon"
+								aria-label={t`Revoke`}
+								title={t`Revoke`}
+								onClick={() => revokeLink(link.id)}
+							>
+								<TrashIcon className="size-4" />
+							</Button>
+						</div>
+					))}
+				</div>
+			)}
+		</DialogContent>
+	)
+}
@@ -4,6 +4,7 @@ import { ClassValue } from "clsx"
 import {
 	ActivityIcon,
 	ArrowUpDownIcon,
+	BellOffIcon,
 	CopyIcon,
 	CpuIcon,
 	HardDriveIcon,
@@ -13,6 +14,7 @@ import {
 	PenBoxIcon,
 	PlayCircleIcon,
 	ServerIcon,
+	TagIcon,
 	Trash2Icon,
 	WifiIcon,
 } from "lucide-react"
@@ -25,7 +27,9 @@ import {
 	formatTemperature,
 	getMeterState,
 	isReadOnlyUser,
+	matchesLabels,
 	parseSemVer,
+	formatLabels,
 } from "@/lib/utils"
 import { EthernetIcon, GpuIcon, HourglassIcon, ThermometerIcon } from "../ui/icons"
 import { useStore } from "@nanostores/react"
@@ -58,6 +62,7 @@ import { t } from "@lingui/core/macro"
 import { MeterState } from "@/lib/enums"
 import { $router, Link } from "../router"
 import { getPagePath } from "@nanostores/router"
+import { toast } from "../ui/use-toast"
 
 const STATUS_COLORS = {
 	up: "bg-green-500",
@@ -88,12 +93,23 @@ export default function SystemsTableColumns(viewMode: "table" | "grid"): ColumnD
 					paused: t`Paused`.toLowerCase(),
 				} as const
 
-				// match filter value against name or translated status
+				let labelTerms: string[] = []
+
+				// match filter value against name or translated status, and
+				// "key=value" terms against labels
 				return (row, _, newFilterInput) => {
 					const { name, status } = row.original
 					if (newFilterInput !== filterInput) {
 						filterInput = newFilterInput
-						filterInputLower = newFilterInput.toLowerCase()
+						const terms = newFilterInput.trim().split(/\s+/)
+						labelTerms = terms.filter((term) => term.includes("="))
+						filterInputLower = terms
+							.filter((term) => !term.includes("="))
+							.join(" ")
+							.toLowerCase()
+					}
+					if (labelTerms.length && !matchesLabels(row.original, labelTerms)) {
+						return false
 					}
 					let nameLower = nameCache.get(name)
 					if (nameLower === undefined) {
@@ -286,6 +302,32 @@ export default function SystemsTableColumns(viewMode: "table" | "grid"): ColumnD
 				}
 			},
 		},
+		{
+			accessorFn: ({ labels }) => formatLabels(labels),
+			id: "labels",
+			name: () => t`Labels`,
+			size: 50,
+			Icon: TagIcon,
+			hideSort: true,
+			header: sortableHeader,
+			cell(info) {
+				const { labels } = info.row.original
+				if (!labels || Object.keys(labels).length === 0) {
+					return null
+				}
+				return (
+					<span className={cn("flex flex-wrap gap-1 max-w-60", viewMode === "table" && "ps-0.5")}>
+						{Object.entries(labels)
+							.sort(([a], [b]) => a.localeCompare(b))
+							.map(([key, value]) => (
+								<span key={key} className="rounded-sm bg-muted px-1.5 text-xs whitespace-nowrap">
+									{key}={value}
+								</span>
+							))}
+					</span>
+				)
+			},
+		},
 		{
 			accessorFn: ({ info }) => info.v,
 			id: "agent",
@@ -382,6 +424,32 @@ export function IndicatorDot({ system, className }: { system: SystemRecord; clas
 	)
 }
 
+/** Suppresses the system's alerts for two hours with a one-off maintenance window */
+async function silenceSystem({ id, name }: SystemRecord) {
+	const start = new Date()
+	const end = new Date(start.getTime() + 2 * 60 * 60 * 1000)
+	try {
+		await pb.collection("maintenance_windows").create({
+			user: pb.authStore.record!.id,
+			name: `Silence ${name}`.slice(0, 64),
+			mode: "suppress",
+			systems: [id],
+			start: start.toISOString(),
+			end: end.toISOString(),
+		})
+		toast({
+			title: t`Alerts silenced`,
+			description: t`Alerts for ${name} are silenced for 2 hours.`,
+		})
+	} catch (error: any) {
+		toast({
+			title: t`Failed to silence alerts`,
+			description: error?.message,
+			variant: "destructive",
+		})
+	}
+}
+
 export const ActionsButton = memo(({ system }: { system: SystemRecord }) => {
 	const [deleteOpen, setDeleteOpen] = useState(false)
 	const [editOpen, setEditOpen] = useState(false)
@@ -433,6 +501,10 @@ export const ActionsButton = memo(({ system }: { system: SystemRecord }) => {
 								</>
 							)}
 						</DropdownMenuItem>
+						<DropdownMenuItem className={cn(isReadOnlyUser() && "hidden")} onClick={() => silenceSystem(system)}>
+							<BellOffIcon className="me-2.5 size-4" />
+							<Trans>Silence for 2 hours</Trans>
+						</DropdownMenuItem>
 						<DropdownMenuItem onClick={() => copyToClipboard(name)}>
 							<CopyIcon className="me-2.5 size-4" />
 							<Trans>Copy name</Trans>
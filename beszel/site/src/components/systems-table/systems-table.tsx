@@ -36,8 +36,9 @@ import {
 	ArrowUpIcon,
 	Settings2Icon,
 	EyeIcon,
+	TagIcon,
 } from "lucide-react"
-import { memo, useEffect, useMemo, useState } from "react"
+import { Fragment, memo, useEffect, useMemo, useState } from "react"
 import { $systems } from "@/lib/stores"
 import { useStore } from "@nanostores/react"
 import { cn, useLocalStorage } from "@/lib/utils"
@@ -51,6 +52,21 @@ import AlertButton from "../alerts/alert-button"
 
 type ViewMode = "table" | "grid"
 
+/** Groups rows by the value of a label, keeping their order. Systems without the label are last. */
+function groupRows(rows: Row<SystemRecord>[], groupBy: string) {
+	const groups = new Map<string, Row<SystemRecord>[]>()
+	for (const row of rows) {
+		const value = row.original.labels?.[groupBy] ?? ""
+		const group = groups.get(value)
+		if (group) {
+			group.push(row)
+		} else {
+			groups.set(value, [row])
+		}
+	}
+	return [...groups].sort(([a], [b]) => (!a ? 1 : !b ? -1 : a.localeCompare(b)))
+}
+
 export default function SystemsTable() {
 	const data = useStore($systems)
 	const { i18n, t } = useLingui()
@@ -59,6 +75,7 @@ export default function SystemsTable() {
 	const [columnFilters, setColumnFilters] = useState<ColumnFiltersState>([])
 	const [columnVisibility, setColumnVisibility] = useLocalStorage<VisibilityState>("cols", {})
 	const [viewMode, setViewMode] = useLocalStorage<ViewMode>("viewMode", window.innerWidth > 1024 ? "table" : "grid")
+	const [groupBy, setGroupBy] = useLocalStorage<string>("groupBy", "")
 
 	const locale = i18n.locale
 
@@ -97,6 +114,16 @@ export default function SystemsTable() {
 	const rows = table.getRowModel().rows
 	const columns = table.getAllColumns()
 	const visibleColumns = table.getVisibleLeafColumns()
+	const labelKeys = useMemo(
+		() => [...new Set(data.flatMap((system) => Object.keys(system.labels ?? {})))].sort(),
+		[data]
+	)
+	// the saved label may no longer be used by any system
+	const activeGroupBy = labelKeys.includes(groupBy) ? groupBy : ""
+	const groups = useMemo(
+		() => (activeGroupBy ? groupRows(rows, activeGroupBy) : [["", rows] as const]),
+		[rows, activeGroupBy]
+	)
 	// TODO: hiding temp then gpu messes up table headers
 	const CardHead = useMemo(() => {
 		return (
@@ -141,6 +168,30 @@ export default function SystemsTable() {
 												<Trans>Grid</Trans>
 											</DropdownMenuRadioItem>
 										</DropdownMenuRadioGroup>
+										{labelKeys.length > 0 && (
+											<>
+												<DropdownMenuSeparator />
+												<DropdownMenuLabel className="pt-2 px-3.5 flex items-center gap-2">
+													<TagIcon className="size-4" />
+													<Trans>Group By</Trans>
+												</DropdownMenuLabel>
+												<DropdownMenuSeparator />
+												<DropdownMenuRadioGroup
+													className="px-1 pb-1"
+													value={activeGroupBy}
+													onValueChange={setGroupBy}
+												>
+													<DropdownMenuRadioItem value="" onSelect={(e) => e.preventDefault()}>
+														<Trans>None</Trans>
+													</DropdownMenuRadioItem>
+													{labelKeys.map((key) => (
+														<DropdownMenuRadioItem key={key} value={key} onSelect={(e) => e.preventDefault()}>
+															{key}
+														</DropdownMenuRadioItem>
+													))}
+												</DropdownMenuRadioGroup>
+											</>
+										)}
 									</div>
 
 									<div>
@@ -209,7 +260,7 @@ export default function SystemsTable() {
 				</div>
 			</CardHeader>
 		)
-	}, [visibleColumns.length, sorting, viewMode, locale])
+	}, [visibleColumns.length, sorting, viewMode, locale, labelKeys, activeGroupBy])
 
 	return (
 		<Card>
@@ -218,15 +269,33 @@ export default function SystemsTable() {
 				{viewMode === "table" ? (
 					// table layout
 					<div className="rounded-md border overflow-hidden">
-						<AllSystemsTable table={table} rows={rows} colLength={visibleColumns.length} />
+						<AllSystemsTable
+							table={table}
+							rows={rows}
+							groups={groups}
+							groupBy={activeGroupBy}
+							colLength={visibleColumns.length}
+						/>
 					</div>
 				) : (
 					// grid layout
 					<div className="grid gap-4 grid-cols-1 sm:grid-cols-2 lg:grid-cols-3">
 						{rows?.length ? (
-							rows.map((row) => {
-								return <SystemCard key={row.original.id} row={row} table={table} colLength={visibleColumns.length} />
-							})
+							groups.map(([value, groupedRows]) => (
+								<Fragment key={value}>
+									{activeGroupBy && (
+										<GroupHeading
+											className="col-span-full"
+											groupBy={activeGroupBy}
+											value={value}
+											count={groupedRows.length}
+										/>
+									)}
+									{groupedRows.map((row) => (
+										<SystemCard key={row.original.id} row={row} table={table} colLength={visibleColumns.length} />
+									))}
+								</Fragment>
+							))
 						) : (
 							<div className="col-span-full text-center py-8">
 								<Trans>No systems found.</Trans>
@@ -239,15 +308,59 @@ export default function SystemsTable() {
 	)
 }
 
+/** Heading of a group of systems with the same label value */
+function GroupHeading({
+	groupBy,
+	value,
+	count,
+	className,
+}: {
+	groupBy: string
+	value: string
+	count: number
+	className?: string
+}) {
+	return (
+		<div className={cn("flex items-center gap-2 text-sm font-medium", className)}>
+			<TagIcon className="size-4 text-muted-foreground" />
+			{value ? `${groupBy}=${value}` : <Trans>No {groupBy} label</Trans>}
+			<span className="text-muted-foreground">({count})</span>
+		</div>
+	)
+}
+
 const AllSystemsTable = memo(
-	({ table, rows, colLength }: { table: TableType<SystemRecord>; rows: Row<SystemRecord>[]; colLength: number }) => {
+	({
+		table,
+		rows,
+		groups,
+		groupBy,
+		colLength,
+	}: {
+		table: TableType<SystemRecord>
+		rows: Row<SystemRecord>[]
+		groups: (readonly [string, Row<SystemRecord>[]])[]
+		groupBy: string
+		colLength: number
+	}) => {
 		return (
 			<Table>
 				<SystemsTableHead table={table} colLength={colLength} />
 				<TableBody>
 					{rows.length ? (
-						rows.map((row) => (
-							<SystemTableRow key={row.original.id} row={row} length={rows.length} colLength={colLength} />
+						groups.map(([value, groupedRows]) => (
+							<Fragment key={value}>
+								{groupBy && (
+									<TableRow className="bg-muted/30 hover:bg-muted/30">
+										<TableCell colSpan={colLength} className="py-2">
+											<GroupHeading groupBy={groupBy} value={value} count={groupedRows.length} />
+										</TableCell>
+									</TableRow>
+								)}
+								{groupedRows.map((row) => (
+									<SystemTableRow key={row.original.id} row={row} length={rows.length} colLength={colLength} />
+								))}
+							</Fragment>
 						))
 					) : (
 						<TableRow>
@@ -352,6 +352,29 @@ const xAxis = function ({ domain, ticks, chartTime }: ChartData) {
 	return cachedAxis
 }
 
+/** Vertical lines marking events in the chart time range */
+const eventLines = function ({ events, domain }: ChartData) {
+	return events
+		.map((event) => ({ event, time: new Date(event.time).getTime() }))
+		.filter(({ time }) => time >= domain[0])
+		.map(({ event, time }) => (
+			<RechartsPrimitive.ReferenceLine
+				key={event.id}
+				x={time}
+				stroke="hsl(var(--muted-foreground))"
+				strokeDasharray="3 3"
+				strokeOpacity={0.6}
+				ifOverflow="hidden"
+				label={{
+					value: event.message || event.name,
+					position: "insideTopLeft",
+					fontSize: 10,
+					fill: "hsl(var(--muted-foreground))",
+				}}
+			/>
+		))
+}
+
 export {
 	ChartContainer,
 	ChartTooltip,
@@ -359,5 +382,6 @@ export {
 	ChartLegend,
 	ChartLegendContent,
 	xAxis,
+	eventLines,
 	// ChartStyle,
 }
@@ -0,0 +1,65 @@
+import { Plural, Trans } from "@lingui/react/macro"
+import { memo, useEffect, useState } from "react"
+import { pb } from "@/lib/stores"
+import { cn, decimalString } from "@/lib/utils"
+import { SystemRecord, SystemUptime } from "@/types"
+import { Card, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
+
+/** Formats seconds of downtime as a short duration, such as 1h 5m */
+function formatDowntime(seconds: number) {
+	const minutes = Math.round(seconds / 60)
+	if (minutes < 60) {
+		return `${minutes}m`
+	}
+	const hours = Math.floor(minutes / 60)
+	return minutes % 60 ? `${hours}h ${minutes % 60}m` : `${hours}h`
+}
+
+/** Uptime of a system over the last 24 hours, 7, 30 and 90 days, from its status changes */
+export default memo(function UptimeCard({ systemId, status }: { systemId: string; status: SystemRecord["status"] }) {
+	const [uptime, setUptime] = useState<SystemUptime | null>(null)
+
+	// reload when the status changes
+	useEffect(() => {
+		pb.send<SystemUptime>("/api/beszel/uptime", { query: { system: systemId }, requestKey: "uptime" })
+			.then(setUptime)
+			.catch(() => setUptime(null))
+	}, [systemId, status])
+
+	// hide until the system was up or down
+	if (!uptime?.uptime.some((window) => window.up + window.down > 0)) {
+		return null
+	}
+
+	return (
+		<Card>
+			<CardHeader className="pb-3 pt-4 gap-1 max-sm:py-3 max-sm:px-4">
+				<CardTitle className="text-xl sm:text-2xl">
+					<Trans>Uptime</Trans>
+				</CardTitle>
+				<CardDescription>
+					<Trans>Share of time the system was up, not counting time it was paused</Trans>
+				</CardDescription>
+			</CardHeader>
+			<div className="grid grid-cols-2 md:grid-cols-4 gap-4 px-3 sm:px-6 pb-4">
+				{uptime.uptime.map((window) => (
+					<div key={window.window}>
+						<div className="text-sm text-muted-foreground">{window.window}</div>
+						<div
+							className={cn("text-2xl font-semibold tabular-nums", {
+								"text-yellow-500": window.percent < 99.9,
+								"text-red-500": window.percent < 99,
+							})}
+						>
+							{decimalString(window.percent, window.percent === 100 ? 0 : 3)}%
+						</div>
+						<div className="text-xs text-muted-foreground">
+							<Plural value={window.incidents} one="# incident" other="# incidents" />
+							{window.down > 0 && `, ${formatDowntime(window.down)}`}
+						</div>
+					</div>
+				))}
+			</div>
+		</Card>
+	)
+})
@@ -6,12 +6,24 @@ export enum Os {
 	FreeBSD,
 }
 
+/** Kind of error reported by an agent stats collector */
+export enum CollectorErrorKind {
+	Ok,
+	Error,
+	Unavailable,
+	PermissionDenied,
+	Timeout,
+	ParseError,
+	Stale,
+}
+
 /** Type of chart */
 export enum ChartType {
 	Memory,
 	Disk,
 	Network,
 	CPU,
+	DiskIO,
 }
 
 /** Unit of measurement */
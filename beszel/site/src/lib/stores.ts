@@ -49,6 +49,9 @@ $userSettings.subscribe((value) => $chartTime.set(value.chartTime))
 /** Container chart filter */
 export const $containerFilter = atom("")
 
+/** Whether to group container charts by compose project */
+export const $containerGroupByProject = atom(false)
+
 /** Temperature chart filter */
 export const $temperatureFilter = atom("")
 
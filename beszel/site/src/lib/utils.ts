@@ -17,7 +17,22 @@ import { RecordModel, RecordSubscription } from "pocketbase"
 import { WritableAtom } from "nanostores"
 import { timeDay, timeHour } from "d3-time"
 import { useEffect, useState } from "react"
-import { CpuIcon, HardDriveIcon, MemoryStickIcon, ServerIcon } from "lucide-react"
+import {
+	ActivityIcon,
+	BadgeCheckIcon,
+	ContainerIcon,
+	CpuIcon,
+	DatabaseIcon,
+	EarthIcon,
+	GaugeIcon,
+	HardDriveIcon,
+	HeartPulseIcon,
+	MemoryStickIcon,
+	ServerCogIcon,
+	ServerIcon,
+	TimerIcon,
+	ToggleRightIcon,
+} from "lucide-react"
 import { EthernetIcon, HourglassIcon, ThermometerIcon } from "@/components/ui/icons"
 import { prependBasePath } from "@/components/router"
 import { MeterState, Unit } from "./enums"
@@ -68,7 +83,7 @@ export const updateSystemList = (() => {
 		try {
 			const records = await pb
 				.collection<SystemRecord>("systems")
-				.getFullList({ sort: "+name", fields: "id,name,host,port,info,status" })
+				.getFullList({ sort: "+name", fields: "id,name,host,port,info,status,labels,team" })
 
 			if (records.length) {
 				$systems.set(records)
@@ -394,6 +409,7 @@ export const alertInfo: Record<string, AlertInfo> = {
 		icon: EthernetIcon,
 		desc: () => t`Triggers when combined up/down exceeds a threshold`,
 		max: 125,
+		target: () => t`Network interface`,
 	},
 	Temperature: {
 		name: () => t`Temperature`,
@@ -401,6 +417,15 @@ export const alertInfo: Record<string, AlertInfo> = {
 		icon: ThermometerIcon,
 		desc: () => t`Triggers when any sensor exceeds a threshold`,
 	},
+	TemperatureRise: {
+		name: () => t`Temperature Rise`,
+		unit: "°C/min",
+		icon: ThermometerIcon,
+		max: 20,
+		start: 2,
+		step: 0.1,
+		desc: () => t`Triggers when any sensor climbs faster than a rate`,
+	},
 	LoadAvg1: {
 		name: () => t`Load Average 1m`,
 		unit: "",
@@ -431,6 +456,148 @@ export const alertInfo: Record<string, AlertInfo> = {
 		step: 0.1,
 		desc: () => t`Triggers when 15 minute load average exceeds a threshold`,
 	},
+	SwapIo: {
+		name: () => t`Swap Activity`,
+		unit: " MB/s",
+		icon: MemoryStickIcon,
+		max: 500,
+		start: 10,
+		desc: () => t`Triggers when combined swap in/out exceeds a threshold`,
+	},
+	LoadCores: {
+		name: () => t`Load Per Core`,
+		unit: "× cores",
+		icon: HourglassIcon,
+		max: 10,
+		min: 0.1,
+		start: 1.5,
+		step: 0.1,
+		desc: () => t`Triggers when load average exceeds a multiple of the CPU count`,
+		target: () => t`Load average (1, 5 or 15 minutes)`,
+	},
+	Latency: {
+		name: () => t`Pipeline Latency`,
+		unit: " ms",
+		icon: TimerIcon,
+		max: 60000,
+		start: 5000,
+		step: 100,
+		desc: () => t`Triggers when time from collection to storage exceeds a threshold`,
+	},
+	Raid: {
+		name: () => t`RAID`,
+		unit: "",
+		icon: HardDriveIcon,
+		desc: () => t`Triggers when a software RAID array loses a member`,
+		singleDesc: () => t`RAID array degraded`,
+	},
+	Smart: {
+		name: () => t`Disk Health`,
+		unit: "",
+		icon: HardDriveIcon,
+		desc: () => t`Triggers when a disk fails its S.M.A.R.T. health check`,
+		singleDesc: () => t`Disk health failing`,
+	},
+	Services: {
+		name: () => t`Services`,
+		unit: "",
+		icon: ServerCogIcon,
+		desc: () => t`Triggers when a watched systemd unit enters the failed state`,
+		singleDesc: () => t`Service failed`,
+	},
+	Checks: {
+		name: () => t`Health Checks`,
+		unit: "",
+		icon: HeartPulseIcon,
+		desc: () => t`Triggers when a local port, HTTP or process check fails`,
+		singleDesc: () => t`Health check failing`,
+	},
+	SensorState: {
+		name: () => t`Sensor States`,
+		unit: "",
+		icon: ToggleRightIcon,
+		desc: () => t`Triggers when a state sensor stays in an alert state, like a door left open`,
+		singleDesc: () => t`Sensor in alert state`,
+	},
+	SensorStale: {
+		name: () => t`Stale Sensors`,
+		unit: "",
+		icon: HourglassIcon,
+		desc: () => t`Triggers when a generic sensor stops updating within its stale timeout`,
+		singleDesc: () => t`Sensor stale`,
+	},
+	Containers: {
+		name: () => t`Container Health`,
+		unit: " restarts",
+		icon: ContainerIcon,
+		max: 50,
+		start: 3,
+		desc: () => t`Triggers when a container is unhealthy or restarts more times in an hour than a threshold`,
+		target: () => t`Compose project`,
+	},
+	Pressure: {
+		name: () => t`Pressure Stall`,
+		unit: "%",
+		icon: GaugeIcon,
+		max: 100,
+		start: 20,
+		desc: () => t`Triggers when tasks stall waiting for CPU, memory or I/O for more than a share of time`,
+		target: () => t`Resource (cpu, memory or io)`,
+	},
+	PublicIp: {
+		name: () => t`Public IP`,
+		unit: "",
+		icon: EarthIcon,
+		desc: () => t`Triggers when the public IP address changes`,
+		singleDesc: () => t`Public IP changed`,
+		instant: true,
+	},
+	BuildCache: {
+		name: () => t`Build Cache`,
+		unit: " GB",
+		icon: DatabaseIcon,
+		max: 500,
+		start: 20,
+		desc: () => t`Triggers when the Docker build cache exceeds a threshold`,
+	},
+	Anomaly: {
+		name: () => t`Anomaly`,
+		unit: " σ",
+		icon: ActivityIcon,
+		max: 10,
+		min: 1,
+		start: 3,
+		step: 0.5,
+		desc: () =>
+			t`Triggers when a metric is further from its usual level at that time of day than a number of standard deviations, learned from the past week`,
+		valueLabel: () => t`Deviation exceeds`,
+		target: () => t`Metric (cpu, memory, diskwrite, bandwidth, sensor:<name>…)`,
+	},
+	DiskFull: {
+		name: () => t`Disk Full Forecast`,
+		unit: " days",
+		icon: HardDriveIcon,
+		max: 90,
+		start: 7,
+		desc: () =>
+			t`Triggers when a filesystem is projected to fill up within a number of days, from its usage trend over the past week`,
+		valueLabel: () => t`Full within`,
+		noDuration: true,
+		target: () => t`Filesystem (root or extra filesystem name)`,
+	},
+	Uptime: {
+		name: () => t`Uptime SLA`,
+		unit: "%",
+		icon: BadgeCheckIcon,
+		max: 100,
+		min: 90,
+		start: 99.9,
+		step: 0.01,
+		desc: () => t`Triggers when uptime over a window drops below an SLA target, checked every 10 minutes`,
+		valueLabel: () => t`Uptime below`,
+		noDuration: true,
+		target: () => t`Window (24h, 7d, 30d or 90d, 30d by default)`,
+	},
 } as const
 
 /**
@@ -441,6 +608,34 @@ export const alertInfo: Record<string, AlertInfo> = {
  */
 export const getHostDisplayValue = (system: SystemRecord): string => system.host.slice(system.host.lastIndexOf("/") + 1)
 
+/** Parse labels in the format "env=prod, site=berlin", skipping entries without a key */
+export function parseLabels(value: string): Record<string, string> {
+	const labels: Record<string, string> = {}
+	for (const entry of value.split(",")) {
+		const [key, ...rest] = entry.split("=")
+		if (key.trim() && rest.length) {
+			labels[key.trim()] = rest.join("=").trim()
+		}
+	}
+	return labels
+}
+
+/** Format labels as "env=prod, site=berlin", sorted by key */
+export const formatLabels = (labels?: Record<string, string> | null) =>
+	Object.entries(labels ?? {})
+		.sort(([a], [b]) => a.localeCompare(b))
+		.map(([key, value]) => `${key}=${value}`)
+		.join(", ")
+
+/** Check whether a system has all labels of filter terms such as ["env=prod", "site=berlin"], ignoring case */
+export function matchesLabels(system: SystemRecord, terms: string[]): boolean {
+	const labels = system.labels ?? {}
+	return terms.every((term) => {
+		const [key, ...rest] = term.split("=")
+		return key in labels && labels[key].toLowerCase() === rest.join("=").toLowerCase()
+	})
+}
+
 /** Generate a random token for the agent */
 export const generateToken = () => crypto?.randomUUID() ?? (performance.now() * Math.random()).toString(16)
 
@@ -20,6 +20,8 @@ import { getLocale, dynamicActivate } from "./lib/i18n.ts"
 const LoginPage = lazy(() => import("./components/login/login.tsx"))
 const CopyToClipboardDialog = lazy(() => import("./components/copy-to-clipboard.tsx"))
 const Settings = lazy(() => import("./components/routes/settings/layout.tsx"))
+const SharedSystemPage = lazy(() => import("./components/routes/share.tsx"))
+const StatusPage = lazy(() => import("./components/routes/status.tsx"))
 
 const App = memo(() => {
 	const page = useStore($router)
@@ -77,6 +79,7 @@ const App = memo(() => {
 })
 
 const Layout = () => {
+	const page = useStore($router)
 	const authenticated = useStore($authenticated)
 	const copyContent = useStore($copyContent)
 	const direction = useStore($direction)
@@ -87,7 +90,17 @@ const Layout = () => {
 
 	return (
 		<DirectionProvider dir={direction}>
-			{!authenticated ? (
+			{page?.route === "share" ? (
+				// share links are viewed without logging in
+				<Suspense>
+					<SharedSystemPage token={page.params.token} />
+				</Suspense>
+			) : page?.route === "status" ? (
+				// status pages are public
+				<Suspense>
+					<StatusPage slug={page.params.slug} />
+				</Suspense>
+			) : !authenticated ? (
 				<Suspense>
 					<LoginPage />
 				</Suspense>
@@ -1,5 +1,5 @@
 import { RecordModel } from "pocketbase"
-import { Unit, Os } from "./lib/enums"
+import { Unit, Os, CollectorErrorKind } from "./lib/enums"
 
 // global window properties
 declare global {
@@ -29,6 +29,12 @@ export interface SystemRecord extends RecordModel {
 	port: string
 	info: SystemInfo
 	v: string
+	/** labels set when the system was registered by its agent */
+	labels?: Record<string, string> | null
+	/** id of the team whose members can access the system */
+	team?: string
+	/** only receives stats from the ingest API and is never polled */
+	ingest?: boolean
 }
 
 export interface SystemInfo {
@@ -68,12 +74,101 @@ export interface SystemInfo {
 	v: string
 	/** system is using podman */
 	p?: boolean
+	/** CRI container runtime, e.g. containerd */
+	rt?: string
 	/** highest gpu utilization */
 	g?: number
 	/** dashboard display temperature */
 	dt?: number
 	/** operating system */
 	os?: Os
+	/** status of each stats collector */
+	cs?: Record<string, CollectorStatus>
+	/** docker version */
+	dv?: string
+	/** unix ms when the agent collected the data */
+	ca?: number
+	/** pipeline latency (ms) */
+	lat?: number
+	/** software raid arrays */
+	raid?: RaidArray[]
+	/** disk SMART health */
+	smart?: SmartDisk[]
+	/** watched systemd units */
+	svc?: ServiceUnit[]
+	/** local health checks */
+	chk?: CheckResult[]
+	/** public IP address */
+	pip?: string
+}
+
+export interface RaidArray {
+	/** name */
+	n: string
+	/** level */
+	l?: string
+	/** state */
+	s: string
+	/** members the array should have */
+	d: number
+	/** members in sync */
+	a: number
+	/** failed members */
+	f?: number
+	/** sync action in progress */
+	sy?: string
+	/** sync progress percent */
+	sp?: number
+}
+
+export interface SmartDisk {
+	/** device name */
+	n: string
+	/** model */
+	m?: string
+	/** health check passed */
+	p: boolean
+	/** temperature */
+	t?: number
+	/** power on hours */
+	h?: number
+	/** pending sectors */
+	ps?: number
+	/** reallocated sectors */
+	rs?: number
+	/** nvme media errors */
+	me?: number
+}
+
+export interface ServiceUnit {
+	/** unit name */
+	n: string
+	/** active state, or not-found */
+	s: string
+	/** sub state */
+	ss?: string
+	/** restarts */
+	r?: number
+}
+
+export interface CheckResult {
+	/** check name */
+	n: string
+	/** type: tcp, http or process */
+	t: string
+	/** up */
+	u: boolean
+	/** latency in ms */
+	l?: number
+	/** error */
+	e?: string
+}
+
+export interface CollectorStatus {
+	/** error kind */
+	k: CollectorErrorKind
+	/** error message */
+	e?: string
 }
 
 export interface SystemStats {
@@ -100,6 +195,16 @@ export interface SystemStats {
 	mb: number
 	/** zfs arc memory (gb) */
 	mz?: number
+	/** page cache (gb) */
+	mc?: number
+	/** buffers (gb) */
+	mbf?: number
+	/** memory available without swapping, including zfs arc (gb) */
+	ma?: number
+	/** memory preallocated as hugepages (gb) */
+	mh?: number
+	/** hugepages in use (gb) */
+	mhu?: number
 	/** swap space (gb) */
 	s: number
 	/** swap used (gb) */
@@ -134,10 +239,97 @@ export interface SystemStats {
 	t?: Record<string, number>
 	/** generic sensors */
 	gs?: Record<string, GenericSensorData>
+	/** sensor categories (cpu, chassis, ambient, custom) */
+	sc?: Record<string, string>
 	/** extra filesystems */
 	efs?: Record<string, ExtraFsStats>
 	/** GPU data */
 	g?: Record<string, GPUData>
+	/** pipeline latency (ms) [collection to hub, hub to record write] */
+	lat?: [number, number]
+	/** members missing from software raid arrays */
+	rm?: number
+	/** disks failing their SMART health check */
+	sf?: number
+	/** watched systemd units in failed state */
+	svf?: number
+	/** local health checks that failed */
+	cf?: number
+	/** state sensors in an alert state */
+	sa?: number
+	/** disk space used by docker */
+	ddu?: DockerDiskUsage
+	/** fastest temperature rise (°C/min) since the previous record, set by the hub */
+	tr?: number
+	/** bandwidth of each network interface [sent bytes, recv bytes] per second */
+	ni?: Record<string, [number, number]>
+	/** usage percent of each cpu core */
+	cpc?: number[]
+	/** current frequency of each cpu core (MHz) */
+	cpf?: number[]
+	/** cpu cores throttled below their max frequency */
+	cth?: number
+	/** pressure stall information */
+	psi?: Pressure
+	/** swap activity [in bytes, out bytes] per second */
+	sio?: [number, number]
+}
+
+/** Summary of a sensor's daily rollups from /api/beszel/sensor-summary */
+export interface SensorSummary {
+	type: "temperature" | "generic"
+	sensor: string
+	unit?: string
+	min: number
+	minAt: string
+	max: number
+	maxAt: string
+	avg: number
+	/** fastest rise per minute */
+	maxRate: number
+	maxRateAt?: string
+}
+
+/** Uptime of a system over a window from /api/beszel/uptime */
+export interface Uptime {
+	window: "24h" | "7d" | "30d" | "90d"
+	/** share of the time the system was up or down that it was up, 100 if neither */
+	percent: number
+	/** seconds up */
+	up: number
+	/** seconds down */
+	down: number
+	/** times the system went down */
+	incidents: number
+}
+
+export interface SystemUptime {
+	status: SystemRecord["status"]
+	uptime: Uptime[]
+}
+
+/** Disk space used by docker in GB */
+/** Share of time (%) that [some, all] tasks stalled waiting for a resource, 60 second average */
+export interface Pressure {
+	/** cpu */
+	c: [number, number]
+	/** memory */
+	m: [number, number]
+	/** io */
+	i: [number, number]
+}
+
+export interface DockerDiskUsage {
+	/** images */
+	i: number
+	/** writable layers of containers */
+	c: number
+	/** volumes */
+	v: number
+	/** build cache */
+	b: number
+	/** unused images, volumes and build cache */
+	r: number
 }
 
 export interface GPUData {
@@ -162,6 +354,10 @@ export interface GenericSensorData {
 	min: number
 	/** maximum value */
 	max: number
+	/** state label of a state sensor, whose value is the state's code */
+	s?: string
+	/** state is an alert state */
+	a?: boolean
 }
 
 export interface ExtraFsStats {
@@ -185,7 +381,7 @@ export interface ContainerStatsRecord extends RecordModel {
 	created: string | number
 }
 
-interface ContainerStats {
+export interface ContainerStats {
 	/** name */
 	n: string
 	/** cpu percent */
@@ -196,6 +392,26 @@ interface ContainerStats {
 	ns: number
 	// network received (mb)
 	nr: number
+	// block i/o read (mb)
+	dr?: number
+	// block i/o written (mb)
+	dw?: number
+	/** health check status, or "restarting" */
+	h?: "starting" | "healthy" | "unhealthy" | "restarting"
+	/** restarts since the container was created */
+	rc?: number
+	/** compose project */
+	p?: string
+}
+
+export interface SystemEventRecord extends RecordModel {
+	system: string
+	/** event name, e.g. "backup" */
+	name: string
+	message: string
+	/** optional numeric payload */
+	value: number | null
+	time: string
 }
 
 export interface SystemStatsRecord extends RecordModel {
@@ -204,6 +420,136 @@ export interface SystemStatsRecord extends RecordModel {
 	created: string | number
 }
 
+/** system, container or sensor from GET /api/beszel/search */
+export interface SearchResult {
+	kind: "system" | "container" | "sensor"
+	name: string
+	/** name of the system */
+	system: string
+	systemId: string
+	/** id of the chart showing the result on the system page */
+	chart?: string
+	score: number
+}
+
+/** metric group shown by a share link */
+export type ShareMetric = "cpu" | "memory" | "disk" | "network" | "sensors" | "containers"
+
+export interface ShareLinkRecord extends RecordModel {
+	system: string
+	token: string
+	name: string
+	/** metric groups shown by the link, all if empty */
+	metrics: ShareMetric[] | null
+	expires: string
+}
+
+/** system shared with a share link, from GET /api/beszel/share/{token} */
+export interface SharedSystem {
+	name: string
+	status: SystemRecord["status"]
+	expires: string
+	metrics: ShareMetric[]
+	info: SystemInfo
+	stats: SystemStatsRecord[]
+	containers?: ContainerStatsRecord[]
+}
+
+export interface StatusPageRecord extends RecordModel {
+	name: string
+	/** path of the page, /status/<slug> */
+	slug: string
+	/** optional host that redirects to the page */
+	domain: string
+	systems: string[]
+	/** sensor names shown for each system */
+	sensors: string[] | null
+}
+
+/** scoped key for the /api/v1 REST API */
+export interface ApiKeyRecord extends RecordModel {
+	name: string
+	token: string
+	scopes: string[]
+	/** never expires if empty */
+	expires: string
+	last_used: string
+}
+
+export interface TeamRecord extends RecordModel {
+	name: string
+	/** ids of members, including admins */
+	members: string[]
+	/** ids of members who can change the team */
+	admins: string[]
+	/** ids of users who can view the team's systems and acknowledge their alerts, but not change them */
+	operators: string[]
+	/** channels that get the alerts of the team's systems */
+	emails: string[] | null
+	webhooks: string[] | null
+}
+
+export interface TeamMember {
+	id: string
+	email: string
+	admin: boolean
+	operator: boolean
+}
+
+/** condition of an alert rule, e.g. cpu > 90. Sensors use the "sensor:<name>" metric. */
+export interface AlertRuleCondition {
+	metric: string
+	op: ">" | ">=" | "<" | "<="
+	value: number
+}
+
+export interface AlertRuleRecord extends RecordModel {
+	name: string
+	match: "all" | "any"
+	conditions: AlertRuleCondition[]
+	/** minutes the conditions must hold to trigger */
+	duration: number
+	resolve_match: "all" | "any" | ""
+	/** resolves when the trigger conditions don't hold if empty */
+	resolve_conditions: AlertRuleCondition[] | null
+	resolve_duration: number
+	/** systems the rule applies to, along with systems with its labels */
+	systems: string[]
+	labels: Record<string, string> | null
+	/** ids of systems the rule is triggered for */
+	triggered: string[] | null
+}
+
+export interface MaintenanceWindowRecord extends RecordModel {
+	name: string
+	/** suppress notifications, or send them marked as maintenance */
+	mode: "suppress" | "mark"
+	/** one-off window */
+	start: string
+	end: string
+	/** cron expression of the starts of a recurring window */
+	schedule: string
+	/** minutes each recurring window lasts */
+	duration: number
+	/** IANA time zone of the schedule, UTC if empty */
+	timezone: string
+	/** systems the window applies to, along with systems with its labels */
+	systems: string[]
+	labels: Record<string, string> | null
+}
+
+/** public status page, from GET /api/beszel/status/{slug} */
+export interface StatusPage {
+	name: string
+	systems: {
+		name: string
+		status: SystemRecord["status"]
+		cpu: number
+		mp: number
+		sensors?: { name: string; value: number; unit: string }[]
+	}[]
+}
+
 export interface AlertRecord extends RecordModel {
 	id: string
 	system: string
@@ -211,6 +557,8 @@ export interface AlertRecord extends RecordModel {
 	triggered: boolean
 	value: number
 	min: number
+	/** optional target of the alert, e.g. a compose project */
+	target?: string
 	// user: string
 }
 
@@ -222,6 +570,77 @@ export interface AlertsHistoryRecord extends RecordModel {
 	val: number
 	created: string
 	resolved?: string | null
+	/** when the alert was acknowledged */
+	acknowledged?: string
+	/** id of the user who acknowledged the alert */
+	acknowledged_by?: string
+	/** seconds from trigger to resolution */
+	duration?: number
+}
+
+/** change of an alert's threshold, recorded by the hub */
+export interface AlertChangeRecord extends RecordModel {
+	/** user of the alert, who made the change */
+	user: string
+	email: string
+	system: string
+	/** id of the alert, which may be deleted */
+	alert: string
+	name: string
+	action: "create" | "update" | "delete"
+	old_value: number
+	value: number
+	old_min: number
+	min: number
+	created: string
+	expand?: { system?: { name: string } }
+}
+
+/** entry of the hub's audit log */
+export interface AuditLogEntry {
+	id: string
+	/** id of the user or superuser, empty for anonymous requests */
+	actor: string
+	email: string
+	/** e.g. systems.create, users.login or alerts.acknowledge */
+	action: string
+	collection: string
+	record: string
+	name: string
+	details: {
+		changes?: Record<string, [unknown, unknown] | null>
+		method?: string
+		path?: string
+		identity?: string
+		token?: string
+	} | null
+	ip: string
+	created: string
+}
+
+export interface AlertGroupStats {
+	/** system id, for systems */
+	id?: string
+	name: string
+	count: number
+	/** mean seconds to resolve */
+	mttr: number
+}
+
+export interface AlertAnalytics {
+	from: string
+	to: string
+	total: number
+	active: number
+	acknowledged: number
+	/** mean seconds from trigger to resolution */
+	mttr: number
+	/** mean seconds from trigger to acknowledgement */
+	mtta: number
+	systems: AlertGroupStats[]
+	alerts: AlertGroupStats[]
+	/** alerts by hour or day */
+	timeline: { time: string; count: number }[]
 }
 
 export type ChartTimes = "1h" | "12h" | "24h" | "1w" | "30d"
@@ -241,6 +660,16 @@ export interface UserSettings {
 	chartTime: ChartTimes
 	emails?: string[]
 	webhooks?: string[]
+	/** send alerts of systems with matching labels to other destinations */
+	routes?: NotificationRoute[]
+	/** minutes state changes of an alert are counted in to detect flapping, 0 disables */
+	flapWindow?: number
+	/** notifications of an alert sent within the flap window before the rest are grouped */
+	flapThreshold?: number
+	/** webhooks with Go template bodies, used with template://<name> URLs */
+	webhookTemplates?: WebhookTemplate[]
+	/** emails a summary of the user's systems every day or on Mondays */
+	digest?: "" | "daily" | "weekly"
 	unitTemp?: Unit
 	unitNet?: Unit
 	unitDisk?: Unit
@@ -248,6 +677,59 @@ export interface UserSettings {
 	colorCrit?: number
 }
 
+export interface WebhookTemplate {
+	name: string
+	url: string
+	/** POST if empty */
+	method?: string
+	headers?: Record<string, string>
+	/** application/json if empty */
+	contentType?: string
+	/** Go template executed with the alert */
+	body: string
+}
+
+export type AlertSeverity = "critical" | "error" | "warning" | "info"
+
+export interface NotificationRoute {
+	/** labels the system must have, values can be glob patterns */
+	labels: Record<string, string>
+	/** alert names the route applies to, all if empty */
+	alerts?: string[]
+	/** severities the route applies to, all if empty */
+	severities?: AlertSeverity[]
+	emails?: string[]
+	webhooks?: string[]
+}
+
+export interface FleetSystemInfo {
+	id: string
+	name: string
+	/** cpu percent */
+	cpu: number
+	/** memory percent */
+	mp: number
+	/** disk percent */
+	dp: number
+	/** dashboard temperature */
+	dt?: number
+}
+
+/** Aggregates from /api/beszel/fleet-overview */
+export interface FleetOverview {
+	total: number
+	up: number
+	down: number
+	paused: number
+	pending: number
+	cores: number
+	threads: number
+	/** total power in watts */
+	power: number
+	hottest: FleetSystemInfo[]
+	overThreshold: FleetSystemInfo[]
+}
+
 type ChartDataContainer = {
 	created: number | null
 } & {
@@ -264,6 +746,8 @@ export interface ChartData {
 	agentVersion: SemVer
 	systemStats: SystemStatsRecord[]
 	containerData: ChartDataContainer[]
+	/** events reported to the agent's event API, shown as chart annotations */
+	events: SystemEventRecord[]
 	orientation: "right" | "left"
 	ticks: number[]
 	domain: number[]
@@ -281,6 +765,14 @@ interface AlertInfo {
 	start?: number
 	/** Single value description (when there's only one value, like status) */
 	singleDesc?: () => string
+	/** Label of the optional target input, if the alert can be limited to a target */
+	target?: () => string
+	/** Alert notifies each change immediately, so it has no duration */
+	instant?: boolean
+	/** Label of the value slider, "Average exceeds" by default */
+	valueLabel?: () => string
+	/** Alert has a value but no duration */
+	noDuration?: boolean
 }
 
 export type AlertMap = Record<string, Map<string, AlertRecord>>